@@ -17,6 +17,10 @@ import (
 	"github.com/nulzo/model-router-api/internal/cli"
 	"github.com/nulzo/model-router-api/internal/config"
 	"github.com/nulzo/model-router-api/internal/gateway"
+	"github.com/nulzo/model-router-api/internal/httpclient"
+	"github.com/nulzo/model-router-api/internal/keys"
+	"github.com/nulzo/model-router-api/internal/llm"
+	"github.com/nulzo/model-router-api/internal/platform/crypto"
 	"github.com/nulzo/model-router-api/internal/platform/logger"
 	"github.com/nulzo/model-router-api/internal/server"
 	"github.com/nulzo/model-router-api/internal/server/validator"
@@ -28,10 +32,17 @@ import (
 
 	_ "github.com/nulzo/model-router-api/internal/llm/anthropic"
 	_ "github.com/nulzo/model-router-api/internal/llm/bfl"
+	_ "github.com/nulzo/model-router-api/internal/llm/compatible"
+	_ "github.com/nulzo/model-router-api/internal/llm/deepseek"
+	_ "github.com/nulzo/model-router-api/internal/llm/elevenlabs"
 	_ "github.com/nulzo/model-router-api/internal/llm/google"
+	_ "github.com/nulzo/model-router-api/internal/llm/groq"
+	_ "github.com/nulzo/model-router-api/internal/llm/llamacpp"
 	_ "github.com/nulzo/model-router-api/internal/llm/moonshot"
 	_ "github.com/nulzo/model-router-api/internal/llm/ollama"
 	_ "github.com/nulzo/model-router-api/internal/llm/openai"
+	_ "github.com/nulzo/model-router-api/internal/llm/openrouter"
+	_ "github.com/nulzo/model-router-api/internal/llm/perplexity"
 	_ "expvar"
 	_ "net/http/pprof"
 )
@@ -74,6 +85,7 @@ func main() {
 		panic("failed to initialize logger: " + err.Error())
 	}
 	logger.SetGlobal(log)
+	logger.SetRedactedHeaders(cfg.Server.RedactHeaders)
 	defer func() {
 		_ = log.Sync()
 	}()
@@ -98,34 +110,30 @@ func main() {
 		_ = repo.Close()
 	}()
 
-	// Sync models to DB
 	ctx := context.Background()
-	if err := repo.WithTx(ctx, func(r store.Repository) error {
-		// Sync Providers first
-		var dbProviders []model.Provider
-		for _, p := range cfg.Providers {
-			// Encrypt API key? For now, we store as is or placeholder if config is source of truth.
-			// Since we load from config on every boot, we might just store "CONFIGURED" or similar to avoid saving secrets in plaintext DB if that's a concern.
-			// However, for functionality, if we want to move to dynamic config later, we'd need the real key.
-			// Assuming local SQLite is secured or we trust the env vars.
-			// Let's store a masked version or just empty if we rely on config-loaded instances.
-			// Actually, the Service uses the IN-MEMORY providers loaded from config.
-			// This DB sync is mainly for "Reporting" and "Audit" purposes so we know what providers existed.
-			
-			dbP := model.Provider{
-				ID:         p.ID,
-				Name:       p.ID, // Or mapped name
-				BaseURL:    "config", // We don't have base URL handy in the simple config struct sometimes?
-				IsEnabled:  p.Enabled,
-				Priority:   0, // Config doesn't specify priority explicitly usually?
-				ConfigJSON: "{}", 
-			}
-			dbProviders = append(dbProviders, dbP)
-		}
-		if err := r.Providers().SyncProviders(ctx, dbProviders); err != nil {
-			return err
+
+	// Providers live in the database, the config file only seeds the first run
+	var cipher *crypto.Cipher
+	if cfg.Database.EncryptionKey != "" {
+		cipher, err = crypto.NewCipher(cfg.Database.EncryptionKey)
+		if err != nil {
+			logger.Fatal("Failed to initialize encryption", zap.Error(err))
 		}
+	} else {
+		log.Warn("No database encryption key configured, provider API keys are read from the config file")
+	}
 
+	providerStore := gateway.NewProviderStore(repo, cipher, cfg.Providers, cfg.Models, cfg.ProviderPolicy())
+	if err := providerStore.Bootstrap(ctx); err != nil {
+		logger.Fatal("Failed to bootstrap providers", zap.Error(err))
+	}
+	providers, err := providerStore.Load(ctx)
+	if err != nil {
+		logger.Fatal("Failed to load providers", zap.Error(err))
+	}
+
+	// Sync models to DB
+	if err := repo.WithTx(ctx, func(r store.Repository) error {
 		var dbModels []model.Model
 		for _, m := range cfg.Models {
 			// Ensure upstream ID is set
@@ -137,14 +145,16 @@ func main() {
 			}
 
 			dbM := model.Model{
-				ID:                    m.ID,
-				ProviderID:            m.ProviderID,
-				ProviderModelID:       upstreamID,
-				IsEnabled:             m.Enabled,
-				IsPublic:              true, // Default to true as config implies availability
-				InputCostMicrosPer1k:  parseCost(m.Pricing.Prompt),
-				OutputCostMicrosPer1k: parseCost(m.Pricing.Completion),
-				ContextWindow:         m.ContextLength,
+				ID:                        m.ID,
+				ProviderID:                m.ProviderID,
+				ProviderModelID:           upstreamID,
+				IsEnabled:                 m.Enabled,
+				IsPublic:                  true, // Default to true as config implies availability
+				InputCostMicrosPer1k:      parseCost(m.Pricing.Prompt),
+				OutputCostMicrosPer1k:     parseCost(m.Pricing.Completion),
+				CacheReadCostMicrosPer1k:  parseCost(m.Pricing.InputCacheRead),
+				CacheWriteCostMicrosPer1k: parseCost(m.Pricing.InputCacheWrite),
+				ContextWindow:             m.ContextLength,
 			}
 			dbModels = append(dbModels, dbM)
 		}
@@ -154,22 +164,32 @@ func main() {
 	}
 
 	// Initialize Analytics Ingestor
-	ingestor := analytics.NewIngestor(log, repo)
+	ingestor := analytics.NewIngestor(log, repo, cfg.Analytics)
 	ingestor.Start(context.Background())
 	defer ingestor.Stop()
 
-	routerService := gateway.NewService(log, repo, ingestor, cacheService)
+	// All provider clients share one outbound transport, as does the
+	// service's pre-flight client, so it is configured before either is built
+	httpclient.Configure(cfg.HTTPClient)
+	llm.SetDefaultBaseURLs(cfg.Gateway.DefaultBaseURLs)
+
+	routerService := gateway.NewService(log, repo, ingestor, cacheService, cfg.Gateway)
 	analyticsService := analytics.NewService(repo)
 
 	// Bootstrap providers
-	gateway.BootstrapProviders(ctx, routerService, cfg.Providers, log)
+	if _, err := gateway.BootstrapProviders(ctx, routerService, providers, log); err != nil {
+		logger.Fatal("Failed to register providers", zap.Error(err))
+	}
 
-	apiServer := server.New(cfg, log, repo, routerService, analyticsService, val)
+	healthCtx, stopHealthChecks := context.WithCancel(context.Background())
+	defer stopHealthChecks()
+	routerService.StartHealthChecks(healthCtx, cfg.Gateway.HealthCheckInterval)
+	providerStore.Watch(healthCtx, routerService, cfg.Gateway.ProviderReloadInterval, log)
+	keys.NewExpiryJob(log, repo, cfg.KeyExpiry).Start(healthCtx)
 
-	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%d", cfg.Server.Port),
-		Handler: apiServer.Handler(),
-	}
+	apiServer := server.New(cfg, log, repo, cacheService, routerService, analyticsService, val)
+
+	srv := apiServer.HTTPServer()
 
 	// Start pprof server
 	go func() {
@@ -180,7 +200,7 @@ func main() {
 	}()
 
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		if err := apiServer.ListenAndServe(srv); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			logger.Fatal("Server start failure", zap.Error(err))
 		}
 	}()
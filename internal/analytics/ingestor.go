@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/nulzo/model-router-api/internal/config"
 	"github.com/nulzo/model-router-api/internal/store"
 	"github.com/nulzo/model-router-api/internal/store/model"
 	"go.uber.org/zap"
@@ -17,20 +18,39 @@ type Ingestor interface {
 }
 
 type ingestor struct {
-	logger    *zap.Logger
-	repo      store.Repository
-	logChan   chan *model.RequestLog
-	batchSize int
-	flushTime time.Duration
+	logger       *zap.Logger
+	repo         store.Repository
+	logChan      chan *model.RequestLog
+	done         chan struct{}
+	batchSize    int
+	flushTime    time.Duration
+	batchInserts bool
+
+	// usage details are written after the main log and retried on their own,
+	// so a transient failure never re-inserts the request log itself
+	detailsAttempts int
+	detailsBackoff  time.Duration
 }
 
-func NewIngestor(logger *zap.Logger, repo store.Repository) Ingestor {
+func NewIngestor(logger *zap.Logger, repo store.Repository, cfg config.AnalyticsConfig) Ingestor {
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = 50
+	}
+	flushTime := cfg.FlushInterval
+	if flushTime <= 0 {
+		flushTime = 5 * time.Second
+	}
+
 	return &ingestor{
-		logger:    logger,
-		repo:      repo,
-		logChan:   make(chan *model.RequestLog, 10000),
-		batchSize: 50,
-		flushTime: 5 * time.Second,
+		logger:          logger,
+		repo:            repo,
+		logChan:         make(chan *model.RequestLog, 10000),
+		batchSize:       batchSize,
+		flushTime:       flushTime,
+		batchInserts:    cfg.BatchInserts,
+		detailsAttempts: 3,
+		detailsBackoff:  100 * time.Millisecond,
 	}
 }
 
@@ -43,11 +63,19 @@ func (i *ingestor) Log(log *model.RequestLog) {
 }
 
 func (i *ingestor) Start(ctx context.Context) {
-	go i.worker(ctx)
+	i.done = make(chan struct{})
+	go func() {
+		defer close(i.done)
+		i.worker(ctx)
+	}()
 }
 
+// Stop flushes the buffered logs and waits for them to be written.
 func (i *ingestor) Stop() {
 	close(i.logChan)
+	if i.done != nil {
+		<-i.done
+	}
 }
 
 func (i *ingestor) worker(ctx context.Context) {
@@ -60,9 +88,11 @@ func (i *ingestor) worker(ctx context.Context) {
 			return
 		}
 
-		for _, log := range batch {
-			if err := i.repo.Requests().Log(context.Background(), log); err != nil {
-				i.logger.Error("Failed to persist request log", zap.String("id", log.ID), zap.Error(err))
+		if i.batchInserts {
+			i.persistBatch(ctx, batch)
+		} else {
+			for _, log := range batch {
+				i.persist(ctx, log)
 			}
 		}
 		batch = batch[:0]
@@ -87,3 +117,102 @@ func (i *ingestor) worker(ctx context.Context) {
 		}
 	}
 }
+
+// persistBatch writes a whole batch, its routing and usage details in one
+// transaction. If the transaction fails the logs are written one by one so a
+// single bad row does not lose the rest of the batch.
+func (i *ingestor) persistBatch(ctx context.Context, batch []*model.RequestLog) {
+	err := i.repo.WithTx(context.Background(), func(tx store.Repository) error {
+		if err := tx.Requests().LogBatch(context.Background(), batch); err != nil {
+			return err
+		}
+
+		var routing []model.RoutingAttempt
+		for _, log := range batch {
+			for j := range log.Routing {
+				log.Routing[j].RequestID = log.ID
+			}
+			routing = append(routing, log.Routing...)
+		}
+		if len(routing) > 0 {
+			if err := tx.Requests().LogRouting(context.Background(), routing); err != nil {
+				return err
+			}
+		}
+
+		for _, log := range batch {
+			if log.UsageDetails == nil {
+				continue
+			}
+			log.UsageDetails.RequestID = log.ID
+			if err := tx.Requests().LogUsageDetails(context.Background(), log.UsageDetails); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		return
+	}
+
+	i.logger.Warn("Failed to persist request log batch, writing logs individually",
+		zap.Int("size", len(batch)),
+		zap.Error(err),
+	)
+	for _, log := range batch {
+		i.persist(ctx, log)
+	}
+}
+
+// persist writes a single request log followed by its usage details. The
+// usage details retries stop waiting once ctx is done.
+func (i *ingestor) persist(ctx context.Context, log *model.RequestLog) {
+	if err := i.repo.Requests().Log(context.Background(), log); err != nil {
+		i.logger.Error("Failed to persist request log", zap.String("id", log.ID), zap.Error(err))
+		return
+	}
+
+	if len(log.Routing) > 0 {
+		for i := range log.Routing {
+			log.Routing[i].RequestID = log.ID
+		}
+		if err := i.repo.Requests().LogRouting(context.Background(), log.Routing); err != nil {
+			i.logger.Error("Failed to persist request routing", zap.String("id", log.ID), zap.Error(err))
+		}
+	}
+
+	if log.UsageDetails == nil {
+		return
+	}
+	log.UsageDetails.RequestID = log.ID
+
+	var err error
+	attempt := 1
+	for ; ; attempt++ {
+		if err = i.repo.Requests().LogUsageDetails(context.Background(), log.UsageDetails); err == nil {
+			return
+		}
+		if attempt >= i.detailsAttempts || !wait(ctx, time.Duration(attempt)*i.detailsBackoff) {
+			break
+		}
+	}
+
+	i.logger.Error("Failed to persist usage details",
+		zap.String("id", log.ID),
+		zap.Int("attempts", attempt),
+		zap.Error(err),
+	)
+}
+
+// wait blocks for d, reporting false when ctx is done first.
+func wait(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,158 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/internal/store/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fakeRepo struct {
+	store.Repository
+	requests *fakeRequestRepo
+}
+
+func (r *fakeRepo) Requests() store.RequestRepository { return r.requests }
+
+// fakeRequestRepo fails the first detailsFailures usage-details inserts.
+type fakeRequestRepo struct {
+	store.RequestRepository
+	detailsFailures int
+
+	logCalls     int
+	detailsCalls int
+	stored       []*model.UsageDetails
+}
+
+func (r *fakeRequestRepo) Log(ctx context.Context, log *model.RequestLog) error {
+	r.logCalls++
+	return nil
+}
+
+func (r *fakeRequestRepo) LogUsageDetails(ctx context.Context, details *model.UsageDetails) error {
+	r.detailsCalls++
+	if r.detailsCalls <= r.detailsFailures {
+		return errors.New("database is locked")
+	}
+	r.stored = append(r.stored, details)
+	return nil
+}
+
+func newTestIngestor(requests *fakeRequestRepo) *ingestor {
+	i := NewIngestor(zap.NewNop(), &fakeRepo{requests: requests}, config.AnalyticsConfig{}).(*ingestor)
+	i.detailsBackoff = 0
+	return i
+}
+
+func TestPersist_RetriesUsageDetailsWithoutDuplicatingLog(t *testing.T) {
+	requests := &fakeRequestRepo{detailsFailures: 1}
+	i := newTestIngestor(requests)
+
+	i.persist(context.Background(), &model.RequestLog{
+		ID:           "req-1",
+		UsageDetails: &model.UsageDetails{CompletionTokensReasoning: 42},
+	})
+
+	assert.Equal(t, 1, requests.logCalls)
+	assert.Equal(t, 2, requests.detailsCalls)
+	if assert.Len(t, requests.stored, 1) {
+		assert.Equal(t, "req-1", requests.stored[0].RequestID)
+		assert.Equal(t, 42, requests.stored[0].CompletionTokensReasoning)
+	}
+}
+
+func TestPersist_GivesUpAfterMaxAttempts(t *testing.T) {
+	requests := &fakeRequestRepo{detailsFailures: 10}
+	i := newTestIngestor(requests)
+
+	i.persist(context.Background(), &model.RequestLog{ID: "req-1", UsageDetails: &model.UsageDetails{}})
+
+	assert.Equal(t, 1, requests.logCalls)
+	assert.Equal(t, i.detailsAttempts, requests.detailsCalls)
+	assert.Empty(t, requests.stored)
+}
+
+func TestPersist_StopsRetryingOnShutdown(t *testing.T) {
+	requests := &fakeRequestRepo{detailsFailures: 10}
+	i := newTestIngestor(requests)
+	i.detailsBackoff = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	i.persist(ctx, &model.RequestLog{ID: "req-1", UsageDetails: &model.UsageDetails{}})
+
+	assert.Equal(t, 1, requests.logCalls)
+	assert.Equal(t, 1, requests.detailsCalls, "the backoff is not waited out")
+}
+
+// txCountingRepo counts transactions and request log inserts.
+type txCountingRepo struct {
+	store.Repository
+	txs     int
+	inserts int
+}
+
+func (r *txCountingRepo) WithTx(ctx context.Context, fn func(repo store.Repository) error) error {
+	r.txs++
+	return r.Repository.WithTx(ctx, fn)
+}
+
+func (r *txCountingRepo) Requests() store.RequestRepository {
+	return &insertCountingRequests{RequestRepository: r.Repository.Requests(), repo: r}
+}
+
+type insertCountingRequests struct {
+	store.RequestRepository
+	repo *txCountingRepo
+}
+
+func (r *insertCountingRequests) Log(ctx context.Context, log *model.RequestLog) error {
+	r.repo.inserts++
+	return r.RequestRepository.Log(ctx, log)
+}
+
+func TestIngestor_BatchInserts(t *testing.T) {
+	db, err := sqlite.NewSQLiteStorage(":memory:", zap.NewNop())
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	repo := &txCountingRepo{Repository: db}
+	i := NewIngestor(zap.NewNop(), repo, config.AnalyticsConfig{
+		BatchInserts:  true,
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+	})
+	i.Start(context.Background())
+
+	const n = 25
+	for j := 0; j < n; j++ {
+		i.Log(&model.RequestLog{
+			ID:           fmt.Sprintf("req-%d", j),
+			StatusCode:   200,
+			CreatedAt:    time.Now(),
+			UsageDetails: &model.UsageDetails{CompletionTokensReasoning: j},
+		})
+	}
+	// the last partial batch is written on shutdown
+	i.Stop()
+
+	// two full batches plus the remainder, no per-request inserts
+	assert.Equal(t, 3, repo.txs)
+	assert.Zero(t, repo.inserts)
+
+	for j := 0; j < n; j++ {
+		log, err := db.Requests().GetByID(context.Background(), fmt.Sprintf("req-%d", j))
+		require.NoError(t, err)
+		require.NotNil(t, log.UsageDetails)
+		assert.Equal(t, j, log.UsageDetails.CompletionTokensReasoning)
+	}
+}
@@ -0,0 +1,217 @@
+// Package bench load tests a running gateway. It drives chat completion
+// requests at a fixed rate, optionally streams them, disconnects clients at
+// random to exercise cancellation, samples the gateway's resource usage and
+// returns the outcome as a structured Result.
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const (
+	defaultModel  = "openai/gpt-3.5-turbo"
+	defaultAPIKey = "bench-key-12345"
+)
+
+// Options configures a benchmark run.
+type Options struct {
+	// TargetURL is the base URL of the gateway, e.g. http://localhost:8081.
+	TargetURL string
+	Duration  time.Duration
+	// Rate is the number of requests sent per second.
+	Rate   int
+	Stream bool
+	// Chaos runs concurrent streaming clients that disconnect after a random
+	// 1-200ms alongside the load.
+	Chaos bool
+
+	Model  string
+	APIKey string
+
+	// MetricsURL is the expvar endpoint sampled for memory usage every
+	// second, empty disables sampling.
+	MetricsURL string
+	// PID is the gateway process whose CPU usage is sampled with ps, zero
+	// when the gateway is not a local process.
+	PID int
+}
+
+// Latencies summarizes the request latencies of a run.
+type Latencies struct {
+	Mean time.Duration `json:"mean"`
+	P50  time.Duration `json:"p50"`
+	P95  time.Duration `json:"p95"`
+	P99  time.Duration `json:"p99"`
+	Max  time.Duration `json:"max"`
+}
+
+// Result is the outcome of a benchmark run.
+type Result struct {
+	Stream   bool   `json:"stream"`
+	Requests uint64 `json:"requests"`
+	// Success is the ratio of requests answered with a 2xx.
+	Success float64 `json:"success"`
+	// Throughput is the rate of successful requests per second.
+	Throughput  float64        `json:"throughput"`
+	Latencies   Latencies      `json:"latencies"`
+	StatusCodes map[string]int `json:"status_codes"`
+	// Errors lists every distinct error seen.
+	Errors    []string         `json:"errors,omitempty"`
+	Resources []ResourceSample `json:"resources,omitempty"`
+	// ChaosRequests is the number of requests made by the chaos clients.
+	ChaosRequests int64 `json:"chaos_requests,omitempty"`
+}
+
+func (o *Options) validate() error {
+	if o.TargetURL == "" {
+		return errors.New("bench: a target URL is required")
+	}
+	if o.Duration <= 0 {
+		return errors.New("bench: duration must be positive")
+	}
+	if o.Rate <= 0 {
+		return errors.New("bench: rate must be positive")
+	}
+	if o.Model == "" {
+		o.Model = defaultModel
+	}
+	if o.APIKey == "" {
+		o.APIKey = defaultAPIKey
+	}
+	return nil
+}
+
+func (o *Options) completionsURL() string {
+	return strings.TrimRight(o.TargetURL, "/") + "/api/v1/chat/completions"
+}
+
+func (o *Options) body(stream bool, prompt string) []byte {
+	return []byte(fmt.Sprintf(`{"model": %s, "stream": %t, "messages": [{"role": "user", "content": %s}]}`,
+		strconv.Quote(o.Model), stream, strconv.Quote(prompt)))
+}
+
+// Run benchmarks the gateway at opts.TargetURL until the duration elapses or
+// ctx is canceled.
+func Run(ctx context.Context, opts Options) (*Result, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg        sync.WaitGroup
+		resources []ResourceSample
+		chaos     int64
+	)
+	if opts.MetricsURL != "" || opts.PID > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resources = monitorResources(ctx, opts.MetricsURL, opts.PID, time.Second)
+		}()
+	}
+	if opts.Chaos {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			chaos = runChaos(ctx, opts, chaosConcurrency(opts.Rate))
+		}()
+	}
+
+	body := opts.body(opts.Stream, "Hello")
+	header := http.Header{
+		"Content-Type":  []string{"application/json"},
+		"Authorization": []string{"Bearer " + opts.APIKey},
+	}
+	targeter := vegeta.NewStaticTargeter(vegeta.Target{
+		Method: http.MethodPost,
+		URL:    opts.completionsURL(),
+		Body:   body,
+		Header: header,
+	})
+
+	attacker := vegeta.NewAttacker(vegeta.KeepAlive(true))
+	go func() {
+		<-ctx.Done()
+		attacker.Stop()
+	}()
+
+	var metrics vegeta.Metrics
+	for res := range attacker.Attack(targeter, vegeta.Rate{Freq: opts.Rate, Per: time.Second}, opts.Duration, "Benchmark") {
+		metrics.Add(res)
+	}
+	metrics.Close()
+
+	// stop the monitor and chaos clients
+	cancel()
+	wg.Wait()
+
+	return &Result{
+		Stream:     opts.Stream,
+		Requests:   metrics.Requests,
+		Success:    metrics.Success,
+		Throughput: metrics.Throughput,
+		Latencies: Latencies{
+			Mean: metrics.Latencies.Mean,
+			P50:  metrics.Latencies.P50,
+			P95:  metrics.Latencies.P95,
+			P99:  metrics.Latencies.P99,
+			Max:  metrics.Latencies.Max,
+		},
+		StatusCodes:   metrics.StatusCodes,
+		Errors:        metrics.Errors,
+		Resources:     resources,
+		ChaosRequests: chaos,
+	}, nil
+}
+
+// Print writes a human readable report of the result.
+func (r *Result) Print(w io.Writer) {
+	mode := "Unary"
+	if r.Stream {
+		mode = "Streaming"
+	}
+
+	if len(r.Resources) > 0 {
+		_, _ = fmt.Fprintln(w, "\n--- Resource Usage (expvar + ps) ---")
+		_, _ = fmt.Fprintf(w, "% -10s % -10s % -10s % -10s\n", "Time", "Heap(MB)", "Alloc(MB)", "CPU(%)")
+		for _, s := range r.Resources {
+			_, _ = fmt.Fprintf(w, "% -10s % -10.2f % -10.2f % -10.2f\n", s.Time.Format("15:04:05"), s.HeapInuseMB, s.AllocMB, s.CPUPercent)
+		}
+	}
+
+	_, _ = fmt.Fprintln(w, "--------------------------------------------------")
+	_, _ = fmt.Fprintf(w, "Mode:             %s\n", mode)
+	_, _ = fmt.Fprintf(w, "Requests:         %d\n", r.Requests)
+	_, _ = fmt.Fprintln(w, "99th percentile: ", r.Latencies.P99)
+	_, _ = fmt.Fprintln(w, "Mean:            ", r.Latencies.Mean)
+	_, _ = fmt.Fprintln(w, "Max:             ", r.Latencies.Max)
+	_, _ = fmt.Fprintf(w, "Success:         %.2f%%\n", r.Success*100)
+	_, _ = fmt.Fprintf(w, "Throughput:      %.2f req/s\n", r.Throughput)
+	if r.ChaosRequests > 0 {
+		_, _ = fmt.Fprintf(w, "Chaos requests:   %d\n", r.ChaosRequests)
+	}
+	_, _ = fmt.Fprintln(w, "--------------------------------------------------")
+
+	if len(r.Errors) > 0 {
+		_, _ = fmt.Fprintln(w, "Error Set (first 5 unique):")
+		for i, msg := range r.Errors {
+			if i == 5 {
+				break
+			}
+			_, _ = fmt.Fprintln(w, msg)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package bench
+
+import (
+	"context"
+	"expvar"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/analytics"
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/gateway"
+	"github.com/nulzo/model-router-api/internal/llm/openai"
+	"github.com/nulzo/model-router-api/internal/server"
+	"github.com/nulzo/model-router-api/internal/server/validator"
+	"github.com/nulzo/model-router-api/internal/store/cache"
+	"github.com/nulzo/model-router-api/internal/store/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// newTestGateway serves the gateway in process, proxying to the mock upstream.
+func newTestGateway(t *testing.T) string {
+	t.Helper()
+
+	upstream := httptest.NewServer(MockUpstream())
+	t.Cleanup(upstream.Close)
+
+	repo, err := sqlite.NewSQLiteStorage(":memory:", zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = repo.Close() })
+
+	// never started, logs of streams the chaos clients cut short may arrive
+	// after the test is done
+	ingestor := analytics.NewIngestor(zap.NewNop(), repo, config.AnalyticsConfig{})
+
+	svc := gateway.NewService(zap.NewNop(), repo, ingestor, cache.NewMemoryCache(), config.GatewayConfig{})
+	provider, err := openai.NewAdapter(config.ProviderConfig{ID: "openai", Type: "openai", APIKey: "mock-key", BaseURL: upstream.URL + "/v1"})
+	require.NoError(t, err)
+	require.NoError(t, svc.RegisterProvider(context.Background(), provider))
+
+	cfg := &config.Config{Server: config.ServerConfig{Env: "development"}}
+	srv := httptest.NewServer(server.New(cfg, zap.NewNop(), repo, cache.NewMemoryCache(), svc, nil, validator.New()).Handler())
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestRun(t *testing.T) {
+	target := newTestGateway(t)
+	metrics := httptest.NewServer(expvar.Handler())
+	defer metrics.Close()
+
+	result, err := Run(context.Background(), Options{
+		TargetURL:  target,
+		Duration:   1500 * time.Millisecond,
+		Rate:       20,
+		MetricsURL: metrics.URL,
+	})
+	require.NoError(t, err)
+
+	assert.NotZero(t, result.Requests)
+	assert.Equal(t, 1.0, result.Success, result.Errors)
+	assert.Equal(t, int(result.Requests), result.StatusCodes["200"])
+	assert.Positive(t, result.Throughput)
+	assert.Positive(t, result.Latencies.Mean)
+	assert.GreaterOrEqual(t, result.Latencies.Max, result.Latencies.P99)
+	require.NotEmpty(t, result.Resources)
+	assert.Positive(t, result.Resources[0].HeapInuseMB)
+}
+
+func TestRun_StreamWithChaos(t *testing.T) {
+	target := newTestGateway(t)
+
+	result, err := Run(context.Background(), Options{
+		TargetURL: target,
+		Duration:  500 * time.Millisecond,
+		Rate:      10,
+		Stream:    true,
+		Chaos:     true,
+	})
+	require.NoError(t, err)
+
+	assert.True(t, result.Stream)
+	assert.NotZero(t, result.Requests)
+	assert.Equal(t, 1.0, result.Success, result.Errors)
+	assert.Positive(t, result.ChaosRequests)
+}
+
+func TestRun_RequiresTarget(t *testing.T) {
+	_, err := Run(context.Background(), Options{Duration: time.Second, Rate: 1})
+	assert.Error(t, err)
+}
@@ -0,0 +1,65 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// chaosConcurrency scales the number of disconnecting clients with the rate,
+// between 5 and 50.
+func chaosConcurrency(rate int) int {
+	return min(max(rate/10, 5), 50)
+}
+
+// runChaos runs concurrent streaming clients that each disconnect after a
+// random 1-200ms, until ctx is done. It returns the number of requests made.
+func runChaos(ctx context.Context, opts Options, concurrency int) int64 {
+	var (
+		wg       sync.WaitGroup
+		requests atomic.Int64
+	)
+	url, payload := opts.completionsURL(), opts.body(true, "Chaos Request")
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client := &http.Client{
+				Transport: &http.Transport{
+					MaxIdleConns:        100,
+					MaxIdleConnsPerHost: 100,
+				},
+			}
+			defer client.CloseIdleConnections()
+
+			for ctx.Err() == nil {
+				timeout := time.Duration(rand.Intn(200)+1) * time.Millisecond
+				reqCtx, cancel := context.WithTimeout(ctx, timeout)
+				req, _ := http.NewRequestWithContext(reqCtx, http.MethodPost, url, bytes.NewReader(payload))
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set("Authorization", "Bearer "+opts.APIKey)
+
+				resp, err := client.Do(req)
+				if err == nil {
+					_ = resp.Body.Close()
+				}
+				cancel()
+				requests.Add(1)
+
+				// pace each client
+				select {
+				case <-ctx.Done():
+				case <-time.After(time.Duration(rand.Intn(50)) * time.Millisecond):
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	return requests.Load()
+}
@@ -0,0 +1,63 @@
+package bench
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+var (
+	streamChunks = [][]byte{
+		[]byte(`data: {"choices":[{"delta":{"content":"Bench"}}]}` + "\n\n"),
+		[]byte(`data: {"choices":[{"delta":{"content":"mark"}}]}` + "\n\n"),
+		[]byte(`data: {"choices":[{"delta":{"content":" safe"}}]}` + "\n\n"),
+		[]byte(`data: {"choices":[{"delta":{"content":" response"}}]}` + "\n\n"),
+	}
+	streamDone = []byte("data: [DONE]\n\n")
+	unaryResp  = []byte(`{"id":"bench-123","choices":[{"message":{"content":"Hello"}}]}`)
+)
+
+// MockUpstream returns an OpenAI compatible upstream serving gpt-3.5-turbo
+// under /v1 for the gateway to proxy during a benchmark. Unary completions
+// answer after 10ms, streams send four chunks 50ms apart.
+func MockUpstream() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/v1/models", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"object": "list",
+			"data": [
+				{"id": "gpt-3.5-turbo", "object": "model", "created": 1687882411, "owned_by": "openai"}
+			]
+		}`))
+	})
+
+	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Stream bool `json:"stream"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+
+		if req.Stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			flusher, _ := w.(http.Flusher)
+			for _, chunk := range streamChunks {
+				time.Sleep(50 * time.Millisecond)
+				_, _ = w.Write(chunk)
+				if flusher != nil {
+					flusher.Flush()
+				}
+			}
+			_, _ = w.Write(streamDone)
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(unaryResp)
+	})
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	return mux
+}
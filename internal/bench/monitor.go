@@ -0,0 +1,89 @@
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ResourceSample is the gateway's resource usage at one point of a run.
+type ResourceSample struct {
+	Time        time.Time `json:"time"`
+	HeapInuseMB float64   `json:"heap_inuse_mb"`
+	AllocMB     float64   `json:"alloc_mb"`
+	CPUPercent  float64   `json:"cpu_percent"`
+}
+
+// monitorResources samples memory from the expvar endpoint at metricsURL and
+// CPU of process pid every interval until ctx is done. Samples the endpoint
+// could not answer are skipped.
+func monitorResources(ctx context.Context, metricsURL string, pid int, interval time.Duration) []ResourceSample {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var samples []ResourceSample
+	for {
+		select {
+		case <-ctx.Done():
+			return samples
+		case <-ticker.C:
+			sample := ResourceSample{Time: time.Now()}
+			if metricsURL != "" {
+				heap, alloc, err := readMemStats(ctx, metricsURL)
+				if err != nil {
+					continue
+				}
+				sample.HeapInuseMB = float64(heap) / 1024 / 1024
+				sample.AllocMB = float64(alloc) / 1024 / 1024
+			}
+			if pid > 0 {
+				sample.CPUPercent = processCPU(pid)
+			}
+			samples = append(samples, sample)
+		}
+	}
+}
+
+func readMemStats(ctx context.Context, metricsURL string) (uint64, uint64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metricsURL, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var vars struct {
+		MemStats struct {
+			HeapInuse uint64 `json:"HeapInuse"`
+			Alloc     uint64 `json:"Alloc"`
+		} `json:"memstats"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&vars); err != nil {
+		return 0, 0, err
+	}
+	return vars.MemStats.HeapInuse, vars.MemStats.Alloc, nil
+}
+
+// processCPU returns the CPU usage of pid as reported by ps, zero when it
+// can not be read.
+func processCPU(pid int) float64 {
+	out, err := exec.Command("ps", "-p", strconv.Itoa(pid), "-o", "%cpu").Output()
+	if err != nil {
+		return 0
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) < 2 {
+		return 0
+	}
+	cpu, _ := strconv.ParseFloat(strings.TrimSpace(lines[1]), 64)
+	return cpu
+}
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/joho/godotenv"
@@ -16,7 +18,7 @@ import (
 // ProviderConfig represents the configuration for a single AI provider.
 type ProviderConfig struct {
 	ID           string                `json:"id" yaml:"id" mapstructure:"id" validate:"required"`
-	Type         string                `json:"type" yaml:"type" mapstructure:"type" validate:"required,oneof=openai anthropic google ollama bfl moonshot"`
+	Type         string                `json:"type" yaml:"type" mapstructure:"type" validate:"required,oneof=openai anthropic google ollama bfl moonshot perplexity groq deepseek openai-compatible elevenlabs openrouter llamacpp"`
 	Name         string                `json:"name" yaml:"name" mapstructure:"name" validate:"required"`
 	APIKey       string                `json:"api_key" yaml:"api_key" mapstructure:"api_key" validate:"required_if=RequiresAuth true"`
 	BaseURL      string                `json:"base_url" yaml:"base_url" mapstructure:"base_url" validate:"omitempty,url"`
@@ -25,22 +27,69 @@ type ProviderConfig struct {
 	Config       map[string]string     `json:"config" yaml:"config" mapstructure:"config"`
 	Enabled      bool                  `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
 	RequiresAuth bool                  `json:"requires_auth" yaml:"requires_auth" mapstructure:"requires_auth"`
+	// MaxAttempts overrides http_client.max_attempts for this provider.
+	MaxAttempts int `json:"max_attempts" yaml:"max_attempts" mapstructure:"max_attempts" validate:"min=0"`
 }
 
-// RouteConfig allows defining rules for specific models
+// ProviderPolicy allows or denies providers by type or ID. With Allow set,
+// only the providers it lists are permitted, Deny wins over Allow.
+type ProviderPolicy struct {
+	Env   string   `mapstructure:"-"`
+	Allow []string `mapstructure:"allow"`
+	Deny  []string `mapstructure:"deny"`
+}
+
+// ProviderPolicy returns the policy of the current environment.
+func (c *Config) ProviderPolicy() ProviderPolicy {
+	policy := c.ProviderPolicies[c.Server.Env]
+	policy.Env = c.Server.Env
+	return policy
+}
+
+// Permits reports whether the provider may be configured.
+func (p ProviderPolicy) Permits(provider ProviderConfig) bool {
+	listed := func(list []string) bool {
+		for _, entry := range list {
+			if entry == provider.Type || entry == provider.ID {
+				return true
+			}
+		}
+		return false
+	}
+	if listed(p.Deny) {
+		return false
+	}
+	return len(p.Allow) == 0 || listed(p.Allow)
+}
+
+// RouteConfig force-routes every model whose ID matches Pattern to the
+// provider TargetID. Match selects how Pattern is read: "glob" (the default,
+// where * matches any run of characters, slashes included), "prefix" or
+// "regex".
 type RouteConfig struct {
 	Pattern  string `json:"pattern" yaml:"pattern" mapstructure:"pattern" validate:"required"`
 	TargetID string `json:"target_id" yaml:"target_id" mapstructure:"target_id" validate:"required"`
+	Match    string `json:"match" yaml:"match" mapstructure:"match" validate:"omitempty,oneof=glob prefix regex"`
 }
 
 type Config struct {
-	Server    ServerConfig          `mapstructure:"server" validate:"required"`
-	Redis     RedisConfig           `mapstructure:"redis" validate:"required"`
-	RateLimit RateLimitConfig       `mapstructure:"rate_limit" validate:"required"`
-	Database  DatabaseConfig        `mapstructure:"database" validate:"required"`
-	Providers []ProviderConfig      `mapstructure:"providers"`
-	Routes    []RouteConfig         `mapstructure:"routes" validate:"dive"`
-	Models    []api.ModelDefinition `mapstructure:"models"`
+	Server          ServerConfig          `mapstructure:"server" validate:"required"`
+	HTTPClient      HTTPClientConfig      `mapstructure:"http_client"`
+	Redis           RedisConfig           `mapstructure:"redis" validate:"required"`
+	RateLimit       RateLimitConfig       `mapstructure:"rate_limit" validate:"required"`
+	Database        DatabaseConfig        `mapstructure:"database" validate:"required"`
+	Gateway         GatewayConfig         `mapstructure:"gateway"`
+	Analytics       AnalyticsConfig       `mapstructure:"analytics"`
+	Signing         SigningConfig         `mapstructure:"signing"`
+	KeyExpiry       KeyExpiryConfig       `mapstructure:"key_expiry"`
+	BaseURLOverride BaseURLOverrideConfig `mapstructure:"base_url_override"`
+	Providers       []ProviderConfig      `mapstructure:"providers"`
+	// ProviderPolicies restricts, per server.env, the providers that may be
+	// configured, e.g. only mocks in staging. Environments without a policy
+	// accept every provider.
+	ProviderPolicies map[string]ProviderPolicy `mapstructure:"provider_policies"`
+	Routes          []RouteConfig         `mapstructure:"routes" validate:"dive"`
+	Models          []api.ModelDefinition `mapstructure:"models"`
 }
 
 type RateLimitConfig struct {
@@ -50,6 +99,9 @@ type RateLimitConfig struct {
 
 type DatabaseConfig struct {
 	Path string `mapstructure:"path" validate:"required"`
+	// EncryptionKey encrypts provider API keys stored in the database. When
+	// empty, keys are not persisted and are read from the config file.
+	EncryptionKey string `mapstructure:"encryption_key" json:"-"`
 }
 
 type ServerConfig struct {
@@ -57,6 +109,495 @@ type ServerConfig struct {
 	Env         string   `mapstructure:"env" validate:"required,oneof=development production staging"`
 	AuthEnabled bool     `mapstructure:"auth_enabled"`
 	APIKeys     []string `mapstructure:"api_keys" validate:"dive,min=10"`
+
+	// Timeouts guard against slow clients holding connections open.
+	// WriteTimeout must leave room for long running streams.
+	ReadTimeout       time.Duration `mapstructure:"read_timeout"`
+	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout"`
+	WriteTimeout      time.Duration `mapstructure:"write_timeout"`
+	IdleTimeout       time.Duration `mapstructure:"idle_timeout"`
+
+	// StreamFlushInterval coalesces stream chunks arriving within this window
+	// into a single flush. Zero flushes every chunk. Models may override it.
+	StreamFlushInterval time.Duration `mapstructure:"stream_flush_interval"`
+
+	// StreamFirstChunkTimeout bounds how long a stream may take to produce
+	// its first chunk. Streams that fail or time out before it are answered
+	// with a plain JSON error instead of an event stream. Zero waits forever.
+	StreamFirstChunkTimeout time.Duration `mapstructure:"stream_first_chunk_timeout"`
+
+	// RedactHeaders extends the built-in list of headers whose values are
+	// replaced before being logged or echoed.
+	RedactHeaders []string `mapstructure:"redact_headers"`
+
+	// MessagesEndpoint serves the Anthropic compatible /messages endpoint, so
+	// Anthropic SDK clients can use the gateway.
+	MessagesEndpoint bool `mapstructure:"messages_endpoint"`
+
+	StreamResume StreamResumeConfig `mapstructure:"stream_resume"`
+
+	// StripResponseFields removes fields from chat responses and stream
+	// chunks before they reach the client, e.g. "system_fingerprint", "usage"
+	// or "choices.logprobs". Request logs still record them.
+	StripResponseFields []string `mapstructure:"strip_response_fields"`
+
+	TLS TLSConfig `mapstructure:"tls"`
+}
+
+// StreamResumeConfig lets clients resume interrupted streams. Every event
+// gets an SSE id and recent events are buffered in the cache, a client
+// reconnecting with Last-Event-ID receives the ones it missed.
+type StreamResumeConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// BufferSize is the number of recent events kept per stream.
+	BufferSize int `mapstructure:"buffer_size" validate:"min=0"`
+	// TTL is how long events are kept, and how long a stream keeps running
+	// after its client disconnected.
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// TLSConfig enables in-process TLS termination.
+type TLSConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	CertFile string `mapstructure:"cert_file" validate:"required_if=Enabled true"`
+	KeyFile  string `mapstructure:"key_file" validate:"required_if=Enabled true"`
+	// MinVersion is the lowest protocol version accepted, "1.2" or "1.3".
+	MinVersion string `mapstructure:"min_version" validate:"omitempty,oneof=1.2 1.3"`
+	HTTP2      bool   `mapstructure:"http2"`
+}
+
+// HTTPClientConfig tunes the transport shared by all outbound provider clients.
+type HTTPClientConfig struct {
+	MaxIdleConns        int           `mapstructure:"max_idle_conns" validate:"min=0"`
+	MaxIdleConnsPerHost int           `mapstructure:"max_idle_conns_per_host" validate:"min=0"`
+	MaxConnsPerHost     int           `mapstructure:"max_conns_per_host" validate:"min=0"`
+	IdleConnTimeout     time.Duration `mapstructure:"idle_conn_timeout"`
+
+	// MaxResponseBytes bounds the non streaming response bodies read into
+	// memory. Zero uses the 64MB default.
+	MaxResponseBytes int64 `mapstructure:"max_response_bytes" validate:"min=0"`
+	// NetworkRetries is how often a non streaming request is retried when
+	// sending it or reading its response fails at the network level. Off by
+	// default, a request cut off mid response may already have been billed.
+	NetworkRetries int `mapstructure:"network_retries" validate:"min=0"`
+	// RetryBackoff is the delay before the first retry, growing linearly for
+	// network retries and exponentially, with jitter, for status retries.
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"`
+	// MaxAttempts is how often a provider request answered with a 429, 500,
+	// 502 or 503 is sent in total, unless the provider sets max_attempts.
+	// Zero or one disables status retries.
+	MaxAttempts int `mapstructure:"max_attempts" validate:"min=0"`
+	// MaxRetryDelay caps the backoff between status retries. An upstream
+	// Retry-After longer than it is not waited for, the error is returned
+	// so the gateway can route elsewhere. Zero uses 10s.
+	MaxRetryDelay time.Duration `mapstructure:"max_retry_delay"`
+}
+
+// GatewayConfig tunes the request handling behaviour of the gateway service.
+type GatewayConfig struct {
+	// PersistPrompts stores the prompt messages and the assembled completion
+	// alongside each request log.
+	PersistPrompts bool `mapstructure:"persist_prompts"`
+
+	// MaxPartChars caps the characters of a single message content part.
+	// Zero disables the check.
+	MaxPartChars int `mapstructure:"max_part_chars" validate:"min=0"`
+
+	// MaxMessageChars caps the combined characters of all parts in a message.
+	// Zero disables the check.
+	MaxMessageChars int `mapstructure:"max_message_chars" validate:"min=0"`
+
+	// MaxTools caps the number of tools in a request. Zero disables the check.
+	MaxTools int `mapstructure:"max_tools" validate:"min=0"`
+
+	// MaxToolSchemaBytes caps the combined encoded size of all tool
+	// definitions. Zero disables the check.
+	MaxToolSchemaBytes int `mapstructure:"max_tool_schema_bytes" validate:"min=0"`
+
+	// BalanceTokenCap clamps max_tokens to the output the caller's wallet can
+	// still pay for, rejecting requests that can not afford
+	// MinAffordableTokens with a 402.
+	BalanceTokenCap     bool `mapstructure:"balance_token_cap"`
+	MinAffordableTokens int  `mapstructure:"min_affordable_tokens" validate:"min=0"`
+
+	// MaxActiveStreams caps the number of streams open at once, further
+	// streams are rejected with a 503. Zero disables the cap.
+	MaxActiveStreams int `mapstructure:"max_active_streams" validate:"min=0"`
+
+	// HealthCheckInterval is how often provider health is refreshed in the
+	// background. Zero disables the checker.
+	HealthCheckInterval time.Duration `mapstructure:"health_check_interval"`
+	// HealthFailureThreshold is the number of consecutive failed checks after
+	// which a provider is unhealthy and skipped by routing, fewer leave it
+	// degraded. HealthDegradedLatency marks providers whose passing check is
+	// slower than it degraded, zero disables it. Degraded providers are only
+	// routed to when no healthy one serves the model.
+	HealthFailureThreshold int           `mapstructure:"health_failure_threshold" validate:"min=0"`
+	HealthDegradedLatency  time.Duration `mapstructure:"health_degraded_latency"`
+
+	// AuthFailureThreshold is the number of consecutive 401s after which a
+	// provider's API key is considered bad: requests to it fail fast with a
+	// 503 and an alert is logged until a probe, run every AuthProbeInterval,
+	// passes again. Zero disables key health tracking.
+	AuthFailureThreshold int           `mapstructure:"auth_failure_threshold" validate:"min=0"`
+	AuthProbeInterval    time.Duration `mapstructure:"auth_probe_interval"`
+
+	// ProviderReloadInterval is how often the providers table is polled for
+	// changes, which are then applied without a restart. Zero disables it.
+	ProviderReloadInterval time.Duration `mapstructure:"provider_reload_interval"`
+
+	// CostDiscrepancyThreshold is the relative difference (0.05 = 5%) between
+	// the upstream reported cost and our computed cost above which a warning
+	// is logged. Zero disables reconciliation.
+	CostDiscrepancyThreshold float64 `mapstructure:"cost_discrepancy_threshold" validate:"min=0"`
+
+	// EmptyResponseAction decides what happens when a provider answers
+	// successfully without any content: "passthrough" returns it as is,
+	// "error" fails with a 502 and "retry" calls the provider again.
+	EmptyResponseAction string `mapstructure:"empty_response_action" validate:"omitempty,oneof=passthrough error retry"`
+
+	// EmptyResponseRetries is the number of extra attempts made by the
+	// "retry" action before giving up with an error.
+	EmptyResponseRetries int `mapstructure:"empty_response_retries" validate:"min=0"`
+
+	// MaxTokensDefaults lists the providers that mandate max_tokens, keyed by
+	// provider ID or type, with the value filled in when a request omits it.
+	MaxTokensDefaults map[string]int `mapstructure:"max_tokens_defaults" validate:"dive,gt=0"`
+
+	// InferMaxTokens sets max_tokens on requests that omit it to the smaller
+	// of this cap and the model's context window minus the estimated prompt,
+	// so responses stay predictable. Zero disables it.
+	InferMaxTokens int `mapstructure:"infer_max_tokens" validate:"min=0"`
+
+	// ContextOverflow handles prompts estimated to exceed the model's context
+	// window: "reject" answers a 400, "truncate" drops messages per
+	// TruncateStrategy until the prompt fits, "reroute" skips to the first
+	// fallback it fits and "off" (the default) sends them as is.
+	ContextOverflow string `mapstructure:"context_overflow" validate:"omitempty,oneof=off reject truncate reroute"`
+	// TruncateStrategy picks the messages truncation drops: "oldest" (the
+	// default) or "middle", which keeps the first message of the conversation.
+	// System messages and the latest message are always kept.
+	TruncateStrategy string `mapstructure:"truncate_strategy" validate:"omitempty,oneof=oldest middle"`
+
+	// DefaultBaseURLs overrides, per provider type, the upstream used by
+	// providers that leave base_url empty.
+	DefaultBaseURLs map[string]string `mapstructure:"default_base_urls" validate:"dive,url"`
+
+	// Fallbacks lists, per model, the models tried in order when the primary
+	// fails with a 429 or 5xx. A list rather than a map since viper would
+	// split model IDs on their dots.
+	Fallbacks []FallbackConfig `mapstructure:"fallbacks" validate:"dive"`
+
+	// WeightedRoutes spreads a model over several providers by weight, e.g.
+	// 80% to openai-primary and 20% to azure-backup. Each request picks one
+	// of the healthy targets.
+	WeightedRoutes []WeightedRoute `mapstructure:"weighted_routes" validate:"dive"`
+
+	// ModelAliases are other names clients can request a model by, such as
+	// "fast" for "anthropic/claude-3-haiku". They are resolved before routing
+	// and can also be managed at runtime through the admin API.
+	ModelAliases []ModelAlias `mapstructure:"model_aliases" validate:"dive"`
+
+	// ListModelAliases lists every alias in /v1/models as a copy of the
+	// model it resolves to.
+	ListModelAliases bool `mapstructure:"list_model_aliases"`
+
+	// Routes are the top-level pattern routes, copied here when the
+	// configuration is loaded.
+	Routes []RouteConfig `mapstructure:"-"`
+
+	// DefaultProvider is the provider prefix tried for bare model names, such
+	// as "gpt-4o", that match no registered model. Empty disables it.
+	DefaultProvider string `mapstructure:"default_provider"`
+
+	// FallthroughProvider serves models that match no registered model, with
+	// the requested model ID sent upstream as is. Meant for meta-providers
+	// such as OpenRouter, empty disables it.
+	FallthroughProvider string `mapstructure:"fallthrough_provider"`
+
+	// RoutingStrategy orders a model and its fallbacks: "priority" keeps the
+	// configured order, "cost" tries the cheapest healthy one first based on
+	// stored pricing and the estimated request size. "latency" sends a model
+	// served by several providers to the one with the lowest recent p95
+	// latency, or time to first token for streams.
+	RoutingStrategy string `mapstructure:"routing_strategy" validate:"omitempty,oneof=priority cost latency"`
+
+	// CapabilityCheck validates requests against the image_support, tool_use
+	// and streaming_support of the model definition before dispatch: "reject"
+	// answers a 400, "reroute" skips to the first capable fallback, "off" (the
+	// default) sends requests as is.
+	CapabilityCheck string `mapstructure:"capability_check" validate:"omitempty,oneof=off reject reroute"`
+
+	// LatencyWindow is how far back latency routing looks, 5m when zero.
+	LatencyWindow time.Duration `mapstructure:"latency_window" validate:"min=0"`
+
+	// HedgeDelay is how long a non streaming request may go unanswered
+	// before it is also sent to a second provider, the first success wins.
+	// Only requests with the hedging flag on are hedged, zero disables it.
+	HedgeDelay time.Duration `mapstructure:"hedge_delay" validate:"min=0"`
+
+	// ShadowTraffic mirrors a share of the requests for a model to another
+	// model in the background. Shadow responses are logged for comparison
+	// but never returned to clients.
+	ShadowTraffic []ShadowRoute `mapstructure:"shadow_traffic" validate:"dive"`
+
+	// AutoRouter serves a virtual model that picks a concrete one per
+	// request from what the prompt needs.
+	AutoRouter AutoRouterConfig `mapstructure:"auto_router"`
+
+	// Admission caps the requests in flight per provider. Requests past a
+	// cap wait in a bounded queue and are let through by the priority of
+	// their API key, then in arrival order.
+	Admission AdmissionConfig `mapstructure:"admission"`
+
+	// MinQuality skips, under cost routing, candidates whose model quality
+	// score is below it. Zero disables the floor.
+	MinQuality float64 `mapstructure:"min_quality" validate:"min=0"`
+
+	// ProviderAliases maps other names of a provider, e.g. the literal name
+	// an adapter reports, to the provider ID model routes are indexed under.
+	// Config IDs of bootstrapped providers are aliased automatically.
+	ProviderAliases map[string]string `mapstructure:"provider_aliases"`
+
+	// SystemDisclaimer is prepended to the system prompt of every request,
+	// inside any model level system prefix, and injected as a system message
+	// when there is none. Empty disables it.
+	SystemDisclaimer string `mapstructure:"system_disclaimer"`
+
+	// RecordRouting stores every provider attempt made for a request in the
+	// request_routing audit trail.
+	RecordRouting bool `mapstructure:"record_routing"`
+
+	// NormalizeResponses sets the assistant role and choice index on every
+	// response message and stream delta that lacks them, and fills blank
+	// id, object, created and model fields on stream chunks.
+	NormalizeResponses bool `mapstructure:"normalize_responses"`
+
+	// StreamChunkObject is the object type set on normalized stream chunks
+	// that leave it empty.
+	StreamChunkObject string `mapstructure:"stream_chunk_object"`
+
+	// StreamPassthrough forwards the upstream stream bytes unchanged for
+	// providers that support it, skipping normalization. Usage is still
+	// tapped for logging but completions are not persisted.
+	StreamPassthrough bool `mapstructure:"stream_passthrough"`
+
+	// StreamImageChunkBytes splits generated images larger than this many
+	// bytes across several stream deltas, so slow clients see progress.
+	// Zero sends every image in a single delta.
+	StreamImageChunkBytes int `mapstructure:"stream_image_chunk_bytes" validate:"min=0"`
+
+	// ExcludeReasoning strips model reasoning from responses unless a
+	// request asks for it with include_reasoning. It is still logged.
+	ExcludeReasoning bool `mapstructure:"exclude_reasoning"`
+
+	// VerifySeeds records a hash of every seeded completion, keyed by model,
+	// seed and prompt, and warns when an identical request diverges from it.
+	VerifySeeds bool `mapstructure:"verify_seeds"`
+
+	// SeedRecordTTL is how long a seeded completion hash is kept.
+	SeedRecordTTL time.Duration `mapstructure:"seed_record_ttl"`
+
+	// SelfTestTimeout bounds each provider's canary request in a self-test,
+	// SelfTestConcurrency is how many providers are tested at once.
+	SelfTestTimeout     time.Duration `mapstructure:"self_test_timeout"`
+	SelfTestConcurrency int           `mapstructure:"self_test_concurrency" validate:"min=0"`
+
+	// Flags toggles gateway behaviors globally or per API key or model.
+	// Overrides stored in the cache at runtime take precedence.
+	Flags FlagsConfig `mapstructure:"flags"`
+
+	// CorrelationHeaders are set on every upstream request so provider side
+	// logs can be matched with the gateway's.
+	CorrelationHeaders []CorrelationHeader `mapstructure:"correlation_headers" validate:"dive"`
+
+	// FallbackContent is the canned answer returned, with the "fallback"
+	// finish reason, to requests opting in with use_fallback_content when
+	// every provider failed. Empty disables it.
+	FallbackContent string `mapstructure:"fallback_content"`
+
+	// Preflight calls an external policy webhook before each request is
+	// dispatched.
+	Preflight PreflightConfig `mapstructure:"preflight"`
+
+	// MonthlyBudget caps what each user may spend per calendar month.
+	MonthlyBudget MonthlyBudgetConfig `mapstructure:"monthly_budget"`
+
+	// DebugEcho controls the debug.echo_upstream_body request option.
+	DebugEcho DebugEchoConfig `mapstructure:"debug_echo"`
+}
+
+// MonthlyBudgetConfig gates requests on a running monthly spend counter
+// kept in the cache per user, and per key for keys with their own
+// monthly_limit_micros. The counters are rebuilt from the request logs when
+// missing and at least every ReconcileInterval, so drift is corrected
+// without summing the logs on every request.
+type MonthlyBudgetConfig struct {
+	// LimitMicros is what a user may spend per month, zero disables the gate.
+	LimitMicros       int64         `mapstructure:"limit_micros" validate:"min=0"`
+	ReconcileInterval time.Duration `mapstructure:"reconcile_interval"`
+}
+
+// CorrelationHeader sends a value taken from the request context upstream in
+// the header Name. Value is one of request_id, the ID of the request log for
+// unary requests, key_hash, a hash of the calling API key ID, user_hash, a
+// hash of its owner, or app_name.
+type CorrelationHeader struct {
+	Name  string `mapstructure:"name" validate:"required"`
+	Value string `mapstructure:"value" validate:"required,oneof=request_id key_hash user_hash app_name"`
+}
+
+// PreflightConfig points at a webhook that allows, denies or rewrites requests
+// before dispatch. It receives the key, model and estimated cost of each
+// request. An empty URL disables it.
+type PreflightConfig struct {
+	URL     string        `mapstructure:"url" validate:"omitempty,url"`
+	Timeout time.Duration `mapstructure:"timeout"`
+	// FailOpen lets requests through when the webhook errors or times out,
+	// otherwise they are rejected with a 503.
+	FailOpen bool `mapstructure:"fail_open"`
+}
+
+// DebugEchoConfig lets trusted callers see the request body sent upstream.
+// The echo is redacted for secrets and truncated to MaxBytes. It is only
+// returned to the keys listed in KeyIDs or granted the "debug" scope.
+type DebugEchoConfig struct {
+	Enabled  bool     `mapstructure:"enabled"`
+	MaxBytes int      `mapstructure:"max_bytes" validate:"min=0"`
+	KeyIDs   []string `mapstructure:"key_ids"`
+}
+
+// FlagsConfig sets the default value of each feature flag and the overrides
+// scoped to an API key or model.
+type FlagsConfig struct {
+	Defaults  map[string]bool `mapstructure:"defaults"`
+	Overrides []FlagOverride  `mapstructure:"overrides" validate:"dive"`
+}
+
+// FlagOverride sets Flag for the API key KeyID or, when no key is given, for
+// the model Model.
+type FlagOverride struct {
+	Flag    string `mapstructure:"flag" validate:"required"`
+	KeyID   string `mapstructure:"key_id" validate:"required_without=Model"`
+	Model   string `mapstructure:"model"`
+	Enabled bool   `mapstructure:"enabled"`
+}
+
+// FallbackConfig names the models to try when Model cannot be served.
+type FallbackConfig struct {
+	Model     string   `mapstructure:"model" validate:"required"`
+	Fallbacks []string `mapstructure:"fallbacks" validate:"required,min=1"`
+}
+
+// WeightedRoute maps the public model ID Model to weighted provider targets.
+type WeightedRoute struct {
+	Model   string        `mapstructure:"model" validate:"required"`
+	Targets []RouteTarget `mapstructure:"targets" validate:"required,min=1,dive"`
+}
+
+// RouteTarget is a provider serving a weighted route. UpstreamID is the
+// model name sent to it, defaulting to the model's upstream ID.
+type RouteTarget struct {
+	Provider   string `mapstructure:"provider" validate:"required"`
+	UpstreamID string `mapstructure:"upstream_id"`
+	Weight     int    `mapstructure:"weight" validate:"min=1"`
+}
+
+// AutoRouterConfig configures the auto-router virtual model. Tiers are
+// matched in order against the classified prompt, the first match serves the
+// request. No tiers disables the virtual model.
+type AutoRouterConfig struct {
+	// Model is the virtual model ID, "prism/auto" when empty.
+	Model string     `mapstructure:"model"`
+	Tiers []AutoTier `mapstructure:"tiers" validate:"dive"`
+}
+
+// AutoTier sends prompts matching every condition it sets to Model. A tier
+// without conditions matches every prompt.
+type AutoTier struct {
+	Model string `mapstructure:"model" validate:"required"`
+	// Vision, Tools and Code require images, tool definitions or code in the prompt.
+	Vision bool `mapstructure:"vision"`
+	Tools  bool `mapstructure:"tools"`
+	Code   bool `mapstructure:"code"`
+	// MinPromptTokens and MaxPromptTokens bound the estimated prompt size,
+	// zero leaves a bound open.
+	MinPromptTokens int `mapstructure:"min_prompt_tokens" validate:"min=0"`
+	MaxPromptTokens int `mapstructure:"max_prompt_tokens" validate:"min=0"`
+}
+
+// AdmissionConfig configures per-provider concurrency caps and the queue
+// requests wait in once a cap is reached. No limits disables admission.
+type AdmissionConfig struct {
+	Limits []ProviderLimit `mapstructure:"limits" validate:"dive"`
+	// QueueSize is how many requests may wait per provider, further ones
+	// move on to the next candidate with a 503. Zero queues none.
+	QueueSize int `mapstructure:"queue_size" validate:"min=0"`
+	// QueueTimeout is how long a request waits for a slot before moving on,
+	// zero waits as long as the request lasts.
+	QueueTimeout time.Duration `mapstructure:"queue_timeout"`
+}
+
+// ProviderLimit caps the requests in flight to Provider, streams count until
+// they end.
+type ProviderLimit struct {
+	Provider      string `mapstructure:"provider" validate:"required"`
+	MaxConcurrent int    `mapstructure:"max_concurrent" validate:"min=1"`
+}
+
+// ShadowRoute mirrors Percent of the requests served by Model to Shadow.
+type ShadowRoute struct {
+	Model   string  `mapstructure:"model" validate:"required"`
+	Shadow  string  `mapstructure:"shadow" validate:"required"`
+	Percent float64 `mapstructure:"percent" validate:"gt=0,lte=100"`
+}
+
+// ModelAlias makes requests for Alias go to the model Model.
+type ModelAlias struct {
+	Alias string `mapstructure:"alias" validate:"required"`
+	Model string `mapstructure:"model" validate:"required"`
+}
+
+// AnalyticsConfig tunes how request logs are written to the database.
+type AnalyticsConfig struct {
+	// BatchInserts writes every flushed batch with multi-row inserts in a
+	// single transaction instead of one insert per request.
+	BatchInserts bool `mapstructure:"batch_inserts"`
+	// BatchSize is the number of buffered logs that triggers a flush.
+	BatchSize int `mapstructure:"batch_size" validate:"min=0"`
+	// FlushInterval flushes whatever is buffered at least this often.
+	FlushInterval time.Duration `mapstructure:"flush_interval"`
+}
+
+// KeyExpiryConfig drives the background job that deactivates expired API
+// keys and warns ahead of expiry.
+type KeyExpiryConfig struct {
+	// CheckInterval is how often keys are checked. Zero disables the job.
+	CheckInterval time.Duration `mapstructure:"check_interval"`
+	// RemindBefore is how long before expiry a reminder is sent, once per key.
+	// Zero disables reminders.
+	RemindBefore time.Duration `mapstructure:"remind_before"`
+	// WebhookURL receives reminders as JSON posts in addition to the log.
+	WebhookURL string `mapstructure:"webhook_url" validate:"omitempty,url"`
+}
+
+// SigningConfig controls HMAC request signature verification for API keys
+// that carry a signing secret.
+type SigningConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// MaxClockSkew is how far a request timestamp may drift from server time.
+	// Nonces are remembered for twice this window.
+	MaxClockSkew time.Duration `mapstructure:"max_clock_skew"`
+}
+
+// BaseURLOverrideConfig controls the X-Provider-Base-URL header that points a
+// single request at another upstream, e.g. a staging sandbox. Only keys with
+// allow_base_url_override in their settings may use it.
+type BaseURLOverrideConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// AllowedHosts lists the hosts requests may be sent to. Empty allows
+	// none.
+	AllowedHosts []string `mapstructure:"allowed_hosts"`
 }
 
 type RedisConfig struct {
@@ -82,10 +623,71 @@ func LoadConfig() (*Config, error) {
 	// Default Values
 	v.SetDefault("server.port", 8080)
 	v.SetDefault("server.env", "development")
+	v.SetDefault("server.read_timeout", "30s")
+	v.SetDefault("server.read_header_timeout", "10s")
+	v.SetDefault("server.write_timeout", "10m")
+	v.SetDefault("server.idle_timeout", "120s")
+	v.SetDefault("server.stream_flush_interval", 0)
+	v.SetDefault("server.stream_first_chunk_timeout", 0)
+	v.SetDefault("server.messages_endpoint", false)
+	v.SetDefault("server.stream_resume.enabled", false)
+	v.SetDefault("server.stream_resume.buffer_size", 512)
+	v.SetDefault("server.stream_resume.ttl", "5m")
+	v.SetDefault("server.tls.enabled", false)
+	v.SetDefault("server.tls.min_version", "1.2")
+	v.SetDefault("server.tls.http2", true)
+	v.SetDefault("http_client.max_idle_conns", 500)
+	v.SetDefault("http_client.max_idle_conns_per_host", 100)
+	v.SetDefault("http_client.max_conns_per_host", 500)
+	v.SetDefault("http_client.idle_conn_timeout", "90s")
+	v.SetDefault("http_client.max_response_bytes", 64<<20)
+	v.SetDefault("http_client.network_retries", 0)
+	v.SetDefault("http_client.retry_backoff", "200ms")
 	v.SetDefault("redis.enabled", false)
 	v.SetDefault("rate_limit.requests_per_second", 10.0)
 	v.SetDefault("rate_limit.burst", 20)
 	v.SetDefault("database.path", "./router.db")
+	v.SetDefault("database.encryption_key", "")
+	v.SetDefault("analytics.batch_inserts", true)
+	v.SetDefault("analytics.batch_size", 50)
+	v.SetDefault("analytics.flush_interval", "5s")
+	v.SetDefault("key_expiry.check_interval", "1h")
+	v.SetDefault("key_expiry.remind_before", "168h")
+	v.SetDefault("signing.enabled", false)
+	v.SetDefault("signing.max_clock_skew", "5m")
+	v.SetDefault("base_url_override.enabled", false)
+	v.SetDefault("gateway.persist_prompts", false)
+	v.SetDefault("gateway.max_part_chars", 0)
+	v.SetDefault("gateway.max_message_chars", 0)
+	v.SetDefault("gateway.max_tools", 0)
+	v.SetDefault("gateway.max_tool_schema_bytes", 0)
+	v.SetDefault("gateway.max_active_streams", 0)
+	v.SetDefault("gateway.balance_token_cap", false)
+	v.SetDefault("gateway.min_affordable_tokens", 16)
+	v.SetDefault("gateway.health_check_interval", "30s")
+	v.SetDefault("gateway.health_failure_threshold", 1)
+	v.SetDefault("gateway.provider_reload_interval", "30s")
+	v.SetDefault("gateway.cost_discrepancy_threshold", 0.05)
+	v.SetDefault("gateway.empty_response_action", "passthrough")
+	v.SetDefault("gateway.empty_response_retries", 1)
+	v.SetDefault("gateway.max_tokens_defaults", map[string]int{"anthropic": 4096})
+	v.SetDefault("gateway.routing_strategy", "priority")
+	v.SetDefault("gateway.min_quality", 0)
+	v.SetDefault("gateway.provider_aliases", map[string]string{})
+	v.SetDefault("gateway.record_routing", true)
+	v.SetDefault("gateway.normalize_responses", true)
+	v.SetDefault("gateway.stream_chunk_object", "chat.completion.chunk")
+	v.SetDefault("gateway.stream_passthrough", false)
+	v.SetDefault("gateway.exclude_reasoning", false)
+	v.SetDefault("gateway.verify_seeds", false)
+	v.SetDefault("gateway.seed_record_ttl", "24h")
+	v.SetDefault("gateway.self_test_timeout", "15s")
+	v.SetDefault("gateway.self_test_concurrency", 4)
+	v.SetDefault("gateway.debug_echo.max_bytes", 4096)
+	v.SetDefault("gateway.monthly_budget.reconcile_interval", "15m")
+	v.SetDefault("gateway.preflight.timeout", "2s")
+	v.SetDefault("gateway.admission.queue_size", 100)
+	v.SetDefault("gateway.admission.queue_timeout", "30s")
 
 	// Allow explicit config file override for safety
 	if envConfigFile := os.Getenv("CONFIG_FILE"); envConfigFile != "" {
@@ -134,10 +736,27 @@ func LoadConfig() (*Config, error) {
 	if err := validate.Struct(&cfg); err != nil {
 		return nil, fmt.Errorf("configuration validation failed: %w", err)
 	}
+	if err := validateRoutes(cfg.Routes); err != nil {
+		return nil, fmt.Errorf("configuration validation failed: %w", err)
+	}
 
 	return &cfg, nil
 }
 
+// validateRoutes compiles the regex pattern routes, which the struct tags
+// can not check.
+func validateRoutes(routes []RouteConfig) error {
+	for _, r := range routes {
+		if r.Match != "regex" {
+			continue
+		}
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			return fmt.Errorf("invalid route regex %q: %w", r.Pattern, err)
+		}
+	}
+	return nil
+}
+
 // resolveConfiguration handles post-load logic like env var injection and model mapping
 func resolveConfiguration(cfg *Config, v *viper.Viper, allModels []api.ModelDefinition) {
 	for i, p := range cfg.Providers {
@@ -170,6 +789,9 @@ func resolveConfiguration(cfg *Config, v *viper.Viper, allModels []api.ModelDefi
 		}
 		cfg.Providers[i].StaticModels = providerModels
 	}
+
+	// pattern routes are applied by the gateway
+	cfg.Gateway.Routes = cfg.Routes
 }
 
 // loadModels discovers and loads model definitions from yaml files
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -20,6 +21,14 @@ func TestLoadConfig_Defaults(t *testing.T) {
 	assert.Equal(t, 9090, cfg.Server.Port)
 	assert.Equal(t, "development", cfg.Server.Env)
 	assert.True(t, cfg.Redis.Enabled)
+
+	assert.Equal(t, 30*time.Second, cfg.Server.ReadTimeout)
+	assert.Equal(t, 10*time.Minute, cfg.Server.WriteTimeout)
+	assert.Equal(t, 120*time.Second, cfg.Server.IdleTimeout)
+	assert.Equal(t, 90*time.Second, cfg.HTTPClient.IdleConnTimeout)
+	assert.Equal(t, 500, cfg.HTTPClient.MaxConnsPerHost)
+	assert.False(t, cfg.Server.TLS.Enabled)
+	assert.Equal(t, "1.2", cfg.Server.TLS.MinVersion)
 }
 
 func TestLoadConfig_APIKeyResolution(t *testing.T) {
@@ -43,3 +52,13 @@ providers:
 	assert.NoError(t, err)
 	_ = f.Close()
 }
+
+func TestValidateRoutes(t *testing.T) {
+	assert.NoError(t, validateRoutes([]RouteConfig{
+		{Pattern: "anthropic/*", TargetID: "anthropic-backup"},
+		{Pattern: `^openai/o[0-9]`, TargetID: "openai", Match: "regex"},
+	}))
+
+	err := validateRoutes([]RouteConfig{{Pattern: "(", TargetID: "google", Match: "regex"}})
+	assert.ErrorContains(t, err, `invalid route regex "("`)
+}
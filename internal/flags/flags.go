@@ -0,0 +1,156 @@
+// Package flags toggles gateway behaviors at runtime. A flag resolves, most
+// specific first, from overrides stored in the cache (per API key, per model,
+// then global) and then from the configuration (per key, per model, then the
+// default), so behaviors can be rolled out gradually without a redeploy.
+package flags
+
+import (
+	"context"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/cache"
+	"github.com/nulzo/model-router-api/internal/store/model"
+)
+
+// Flags consulted by the gateway.
+const (
+	// Fallback tries the configured fallback models when the requested one fails.
+	Fallback = "fallback"
+	// VerifySeeds warns when a seeded completion diverges.
+	VerifySeeds = "verify_seeds"
+	// BalanceTokenCap clamps max_tokens to the caller's wallet balance.
+	BalanceTokenCap = "balance_token_cap"
+	// Hedging sends slow requests to a second provider after hedge_delay.
+	Hedging = "hedging"
+)
+
+type modelKey struct{}
+
+// WithModel returns a context whose flag lookups honour overrides for modelID.
+func WithModel(ctx context.Context, modelID string) context.Context {
+	return context.WithValue(ctx, modelKey{}, modelID)
+}
+
+// Override scopes a flag value to an API key or a model. With neither set it
+// applies to everyone.
+type Override struct {
+	Flag    string `json:"flag"`
+	KeyID   string `json:"key_id,omitempty"`
+	Model   string `json:"model,omitempty"`
+	Enabled bool   `json:"enabled"`
+}
+
+// Flags resolves feature flags. The zero cache disables runtime overrides.
+type Flags struct {
+	cache    cache.CacheService
+	defaults map[string]bool
+	keys     map[string]map[string]bool // flag -> key ID -> enabled
+	models   map[string]map[string]bool // flag -> model ID -> enabled
+}
+
+func New(c cache.CacheService, cfg config.FlagsConfig) *Flags {
+	f := &Flags{
+		cache:    c,
+		defaults: cfg.Defaults,
+		keys:     make(map[string]map[string]bool),
+		models:   make(map[string]map[string]bool),
+	}
+	for _, o := range cfg.Overrides {
+		switch {
+		case o.KeyID != "":
+			set(f.keys, o.Flag, o.KeyID, o.Enabled)
+		case o.Model != "":
+			set(f.models, o.Flag, o.Model, o.Enabled)
+		}
+	}
+	return f
+}
+
+func set(m map[string]map[string]bool, flag, id string, enabled bool) {
+	if m[flag] == nil {
+		m[flag] = make(map[string]bool)
+	}
+	m[flag][id] = enabled
+}
+
+// Enabled reports whether the flag is on for the calling key and model.
+// Unknown flags are off.
+func (f *Flags) Enabled(ctx context.Context, name string) bool {
+	enabled, _ := f.Lookup(ctx, name)
+	return enabled
+}
+
+// Lookup returns the flag's value for the calling key and model, and whether
+// it is set at all, so callers can fall back to their own default.
+func (f *Flags) Lookup(ctx context.Context, name string) (bool, bool) {
+	var keyID string
+	if apiKey, ok := ctx.Value(store.ContextKeyAPIKey).(*model.APIKey); ok {
+		keyID = apiKey.ID
+	}
+	modelID, _ := ctx.Value(modelKey{}).(string)
+
+	if f.cache != nil {
+		for _, key := range []string{keyScope(name, keyID), modelScope(name, modelID), globalScope(name)} {
+			if key == "" {
+				continue
+			}
+			var enabled bool
+			if err := f.cache.Get(ctx, key, &enabled); err == nil {
+				return enabled, true
+			}
+		}
+	}
+
+	if enabled, ok := f.keys[name][keyID]; ok && keyID != "" {
+		return enabled, true
+	}
+	if enabled, ok := f.models[name][modelID]; ok && modelID != "" {
+		return enabled, true
+	}
+	enabled, ok := f.defaults[name]
+	return enabled, ok
+}
+
+// Set stores a runtime override, taking precedence over the configuration.
+// A zero ttl keeps it for a year.
+func (f *Flags) Set(ctx context.Context, o Override, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = 365 * 24 * time.Hour
+	}
+	return f.cache.Set(ctx, overrideKey(o), o.Enabled, ttl)
+}
+
+// Clear removes a runtime override.
+func (f *Flags) Clear(ctx context.Context, o Override) error {
+	return f.cache.Delete(ctx, overrideKey(o))
+}
+
+func overrideKey(o Override) string {
+	switch {
+	case o.KeyID != "":
+		return keyScope(o.Flag, o.KeyID)
+	case o.Model != "":
+		return modelScope(o.Flag, o.Model)
+	}
+	return globalScope(o.Flag)
+}
+
+func keyScope(flag, keyID string) string {
+	if keyID == "" {
+		return ""
+	}
+	return "flag:" + flag + ":key:" + keyID
+}
+
+func modelScope(flag, modelID string) string {
+	if modelID == "" {
+		return ""
+	}
+	return "flag:" + flag + ":model:" + modelID
+}
+
+func globalScope(flag string) string {
+	return "flag:" + flag
+}
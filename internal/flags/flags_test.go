@@ -0,0 +1,43 @@
+package flags
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/cache"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withKey(keyID string) context.Context {
+	return context.WithValue(context.Background(), store.ContextKeyAPIKey, &model.APIKey{ID: keyID})
+}
+
+func TestEnabled_KeyOverridesGlobalDefault(t *testing.T) {
+	c := cache.NewMemoryCache()
+	f := New(c, config.FlagsConfig{
+		Defaults: map[string]bool{BalanceTokenCap: false},
+		Overrides: []config.FlagOverride{
+			{Flag: BalanceTokenCap, KeyID: "key-beta", Enabled: true},
+		},
+	})
+
+	assert.True(t, f.Enabled(withKey("key-beta"), BalanceTokenCap))
+	assert.False(t, f.Enabled(withKey("key-other"), BalanceTokenCap))
+	assert.False(t, f.Enabled(context.Background(), BalanceTokenCap))
+
+	// a runtime override turns it on for another key without touching the config
+	ctx := context.Background()
+	require.NoError(t, f.Set(ctx, Override{Flag: BalanceTokenCap, KeyID: "key-other", Enabled: true}, 0))
+	assert.True(t, f.Enabled(withKey("key-other"), BalanceTokenCap))
+	assert.False(t, f.Enabled(withKey("key-third"), BalanceTokenCap))
+
+	// and takes precedence over the configured key override until cleared
+	require.NoError(t, f.Set(ctx, Override{Flag: BalanceTokenCap, KeyID: "key-beta", Enabled: false}, 0))
+	assert.False(t, f.Enabled(withKey("key-beta"), BalanceTokenCap))
+	require.NoError(t, f.Clear(ctx, Override{Flag: BalanceTokenCap, KeyID: "key-beta"}))
+	assert.True(t, f.Enabled(withKey("key-beta"), BalanceTokenCap))
+}
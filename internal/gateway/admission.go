@@ -0,0 +1,173 @@
+package gateway
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+// admissionWaiter is a request queued for a provider slot.
+type admissionWaiter struct {
+	priority int
+	seq      uint64
+	index    int           // position in the queue, -1 once admitted
+	admitted chan struct{} // closed when a slot is handed over
+}
+
+// waitQueue is a heap of waiters, highest priority first, then in arrival
+// order.
+type waitQueue []*admissionWaiter
+
+func (q waitQueue) Len() int { return len(q) }
+func (q waitQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+func (q waitQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index, q[j].index = i, j
+}
+func (q *waitQueue) Push(x any) {
+	w := x.(*admissionWaiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+func (q *waitQueue) Pop() any {
+	old := *q
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*q = old[:len(old)-1]
+	return w
+}
+
+// providerGate caps the requests in flight to one provider. A released slot
+// is handed straight to the first waiter, so a request arriving meanwhile can
+// not take it ahead of the queue.
+type providerGate struct {
+	providerID string
+	limit      int
+	queueSize  int
+	timeout    time.Duration
+
+	mu       sync.Mutex
+	inflight int
+	waiting  waitQueue
+	seq      uint64
+}
+
+// newGates returns the gates of the providers with a configured cap, keyed by
+// the provider ID they were configured under.
+func newGates(cfg config.AdmissionConfig) map[string]*providerGate {
+	gates := make(map[string]*providerGate, len(cfg.Limits))
+	for _, l := range cfg.Limits {
+		gates[l.Provider] = &providerGate{
+			providerID: l.Provider,
+			limit:      l.MaxConcurrent,
+			queueSize:  cfg.QueueSize,
+			timeout:    cfg.QueueTimeout,
+		}
+	}
+	return gates
+}
+
+// acquire takes a slot, waiting for one in the queue when the provider is at
+// its cap. Every successful call must be paired with release.
+func (g *providerGate) acquire(ctx context.Context, priority int) error {
+	g.mu.Lock()
+	if g.inflight < g.limit && len(g.waiting) == 0 {
+		g.inflight++
+		g.mu.Unlock()
+		return nil
+	}
+	if len(g.waiting) >= g.queueSize {
+		g.mu.Unlock()
+		return api.NewError(http.StatusServiceUnavailable, "Provider Queue Full",
+			fmt.Sprintf("provider '%s' is at its limit of %d concurrent requests with %d queued", g.providerID, g.limit, len(g.waiting)),
+			api.WithExtension("provider", g.providerID),
+		)
+	}
+	g.seq++
+	w := &admissionWaiter{priority: priority, seq: g.seq, admitted: make(chan struct{})}
+	heap.Push(&g.waiting, w)
+	g.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if g.timeout > 0 {
+		timer := time.NewTimer(g.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var err error
+	select {
+	case <-w.admitted:
+		return nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timeout:
+		err = api.NewError(http.StatusServiceUnavailable, "Provider Busy",
+			fmt.Sprintf("no slot of provider '%s' freed up within %s", g.providerID, g.timeout),
+			api.WithExtension("provider", g.providerID),
+		)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if w.index < 0 {
+		// admitted while giving up, pass the slot on
+		g.releaseLocked()
+	} else {
+		heap.Remove(&g.waiting, w.index)
+	}
+	return err
+}
+
+func (g *providerGate) release() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.releaseLocked()
+}
+
+func (g *providerGate) releaseLocked() {
+	if len(g.waiting) > 0 {
+		close(heap.Pop(&g.waiting).(*admissionWaiter).admitted)
+		return
+	}
+	g.inflight--
+}
+
+// keyPriority is the admission priority of the API key found in the
+// context, zero without one.
+func keyPriority(ctx context.Context) int {
+	if apiKey, ok := ctx.Value(store.ContextKeyAPIKey).(*model.APIKey); ok {
+		return apiKey.Priority
+	}
+	return 0
+}
+
+// admit takes a slot of providerID for the request, when the provider has a
+// concurrency cap, and returns the func giving it back.
+func (s *service) admit(ctx context.Context, providerID string) (func(), error) {
+	for id, gate := range s.gates {
+		if s.registry.canonicalProviderID(id) != providerID {
+			continue
+		}
+		if err := gate.acquire(ctx, keyPriority(ctx)); err != nil {
+			return nil, err
+		}
+		var once sync.Once
+		return func() { once.Do(gate.release) }, nil
+	}
+	return func() {}, nil
+}
@@ -0,0 +1,231 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gatedProvider reports each request as it arrives and holds it until told
+// to proceed.
+type gatedProvider struct {
+	*mockProvider
+	entered chan string // the first message of each request
+	proceed chan struct{}
+}
+
+func (p *gatedProvider) Chat(ctx context.Context, req *api.ChatRequest) (*api.ChatResponse, error) {
+	p.entered <- req.Messages[0].Content.Text
+	<-p.proceed
+	return p.mockProvider.Chat(ctx, req)
+}
+
+func waiting(g *providerGate) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.waiting)
+}
+
+func TestProviderGate_AdmitsByPriority(t *testing.T) {
+	gate := &providerGate{providerID: "p", limit: 1, queueSize: 10}
+	require.NoError(t, gate.acquire(context.Background(), 0))
+
+	admitted := make(chan string, 4)
+	enqueue := func(name string, priority int) {
+		queued := waiting(gate)
+		go func() {
+			if gate.acquire(context.Background(), priority) == nil {
+				admitted <- name
+			}
+		}()
+		require.Eventually(t, func() bool { return waiting(gate) == queued+1 }, time.Second, time.Millisecond)
+	}
+	enqueue("free-1", 0)
+	enqueue("enterprise", 2)
+	enqueue("free-2", 0)
+	enqueue("standard", 1)
+
+	var order []string
+	for range 4 {
+		gate.release()
+		order = append(order, <-admitted)
+	}
+	assert.Equal(t, []string{"enterprise", "standard", "free-1", "free-2"}, order)
+
+	gate.release()
+	assert.Zero(t, gate.inflight)
+}
+
+func TestProviderGate_QueueLimits(t *testing.T) {
+	gate := &providerGate{providerID: "p", limit: 1, queueSize: 1, timeout: 20 * time.Millisecond}
+	require.NoError(t, gate.acquire(context.Background(), 0))
+
+	// nobody frees the slot in time
+	err := gate.acquire(context.Background(), 0)
+	assert.Equal(t, http.StatusServiceUnavailable, errorStatus(err))
+	assert.ErrorContains(t, err, "freed up within")
+	assert.Zero(t, waiting(gate))
+
+	// the queue holds one waiter
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- gate.acquire(ctx, 0) }()
+	require.Eventually(t, func() bool { return waiting(gate) == 1 }, time.Second, time.Millisecond)
+	err = gate.acquire(context.Background(), 5)
+	assert.ErrorContains(t, err, "with 1 queued")
+
+	// a cancelled waiter leaves the queue
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	assert.Zero(t, waiting(gate))
+
+	gate.release()
+	assert.Zero(t, gate.inflight)
+}
+
+func TestChat_AdmissionByKeyPriority(t *testing.T) {
+	provider := &gatedProvider{
+		mockProvider: &mockProvider{id: "local", models: []api.ModelDefinition{{ID: "local/model", ProviderID: "local"}}},
+		entered:      make(chan string),
+		proceed:      make(chan struct{}),
+	}
+	svc, _ := newTestService(t, config.GatewayConfig{
+		Admission: config.AdmissionConfig{
+			Limits:    []config.ProviderLimit{{Provider: "local", MaxConcurrent: 1}},
+			QueueSize: 10,
+		},
+	})
+	require.NoError(t, svc.RegisterProvider(context.Background(), provider))
+	gate := svc.gates["local"]
+
+	var wg sync.WaitGroup
+	send := func(text string, priority int) {
+		ctx := context.WithValue(context.Background(), store.ContextKeyAPIKey, &model.APIKey{ID: "key-" + text, UserID: "user-1", Priority: priority})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.Chat(ctx, &api.ChatRequest{
+				Model:    "local/model",
+				Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: text}}},
+			})
+			assert.NoError(t, err)
+		}()
+	}
+
+	send("first", 0)
+	assert.Equal(t, "first", <-provider.entered)
+	send("free", 0)
+	require.Eventually(t, func() bool { return waiting(gate) == 1 }, time.Second, time.Millisecond)
+	send("enterprise", 2)
+	require.Eventually(t, func() bool { return waiting(gate) == 2 }, time.Second, time.Millisecond)
+
+	provider.proceed <- struct{}{}
+	assert.Equal(t, "enterprise", <-provider.entered)
+	provider.proceed <- struct{}{}
+	assert.Equal(t, "free", <-provider.entered)
+	provider.proceed <- struct{}{}
+	wg.Wait()
+	assert.Zero(t, gate.inflight)
+}
+
+func TestChat_AdmissionQueueFullFallsBack(t *testing.T) {
+	local := &gatedProvider{
+		mockProvider: &mockProvider{id: "local", models: []api.ModelDefinition{{ID: "local/model", ProviderID: "local"}}},
+		entered:      make(chan string),
+		proceed:      make(chan struct{}),
+	}
+	backup := &mockProvider{id: "backup", models: []api.ModelDefinition{{ID: "backup/model", ProviderID: "backup"}}}
+	svc, _ := newTestService(t, config.GatewayConfig{
+		Fallbacks: []config.FallbackConfig{{Model: "local/model", Fallbacks: []string{"backup/model"}}},
+		Admission: config.AdmissionConfig{Limits: []config.ProviderLimit{{Provider: "local", MaxConcurrent: 1}}},
+	}, backup)
+	require.NoError(t, svc.RegisterProvider(context.Background(), local))
+
+	req := func(text string) *api.ChatRequest {
+		return &api.ChatRequest{Model: "local/model", Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: text}}}}
+	}
+	done := make(chan error)
+	go func() {
+		_, err := svc.Chat(context.Background(), req("first"))
+		done <- err
+	}()
+	assert.Equal(t, "first", <-local.entered)
+
+	// no queue configured, the capped provider is routed around
+	_, err := svc.Chat(context.Background(), req("second"))
+	require.NoError(t, err)
+	assert.Len(t, backup.requests, 1)
+
+	local.proceed <- struct{}{}
+	require.NoError(t, <-done)
+}
+
+func TestStreamChat_HoldsSlotUntilStreamEnds(t *testing.T) {
+	provider := &mockProvider{
+		id:         "local",
+		models:     []api.ModelDefinition{{ID: "local/model", ProviderID: "local"}},
+		streamResp: []api.StreamResult{textDelta("Hello", "")},
+	}
+	svc, _ := newTestService(t, config.GatewayConfig{
+		Admission: config.AdmissionConfig{Limits: []config.ProviderLimit{{Provider: "local", MaxConcurrent: 1}}},
+	}, provider)
+	gate := svc.gates["local"]
+
+	ch, err := svc.StreamChat(context.Background(), &api.ChatRequest{
+		Model:    "local/model",
+		Stream:   true,
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+	gate.mu.Lock()
+	assert.Equal(t, 1, gate.inflight)
+	gate.mu.Unlock()
+
+	drain(t, ch)
+	gate.mu.Lock()
+	assert.Zero(t, gate.inflight)
+	gate.mu.Unlock()
+}
+
+func TestChat_HedgeTakesProviderSlot(t *testing.T) {
+	svc, _, primary, backup := newHedgeService(t, true, 100*time.Millisecond)
+	svc.gates = newGates(config.AdmissionConfig{Limits: []config.ProviderLimit{{Provider: "backup", MaxConcurrent: 1}}})
+	require.NoError(t, svc.gates["backup"].acquire(context.Background(), 0))
+
+	// the backup is at its cap, the hedge is turned away and the primary
+	// serves the request
+	_, err := svc.Chat(context.Background(), hedgeRequest())
+	require.NoError(t, err)
+	assert.Len(t, primary.requests, 1)
+	assert.Empty(t, backup.requests)
+}
+
+func TestSpeech_TakesProviderSlot(t *testing.T) {
+	tts := &speechProvider{mockProvider: &mockProvider{id: "tts", models: []api.ModelDefinition{{ID: "tts/voice", ProviderID: "tts"}}}}
+	svc, _ := newTestService(t, config.GatewayConfig{
+		Admission: config.AdmissionConfig{Limits: []config.ProviderLimit{{Provider: "tts", MaxConcurrent: 1}}},
+	})
+	require.NoError(t, svc.RegisterProvider(context.Background(), tts))
+	gate := svc.gates["tts"]
+	require.NoError(t, gate.acquire(context.Background(), 0))
+
+	_, err := svc.Speech(context.Background(), &api.SpeechRequest{Model: "tts/voice", Input: "Hello"})
+	assert.Equal(t, http.StatusServiceUnavailable, errorStatus(err))
+	assert.Nil(t, tts.last)
+
+	gate.release()
+	_, err = svc.Speech(context.Background(), &api.SpeechRequest{Model: "tts/voice", Input: "Hello"})
+	require.NoError(t, err)
+	gate.mu.Lock()
+	assert.Zero(t, gate.inflight, "the slot is given back")
+	gate.mu.Unlock()
+}
@@ -0,0 +1,45 @@
+package gateway
+
+import (
+	"strings"
+
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+// streamAggregator reassembles the full completion from streamed deltas so the
+// final request log can store what the client actually received.
+// Content and reasoning are accumulated separately, mirroring the split the
+// adapters perform when extracting <think> blocks.
+type streamAggregator struct {
+	contentBuf   strings.Builder
+	reasoningBuf strings.Builder
+}
+
+func (a *streamAggregator) add(resp *api.ChatResponse) {
+	if resp == nil {
+		return
+	}
+
+	for _, choice := range resp.Choices {
+		// only the primary choice is persisted
+		if choice.Index != 0 || choice.Delta == nil {
+			continue
+		}
+
+		a.contentBuf.WriteString(choice.Delta.Content.Text)
+		for _, part := range choice.Delta.Content.Parts {
+			if part.Type == "text" {
+				a.contentBuf.WriteString(part.Text)
+			}
+		}
+		a.reasoningBuf.WriteString(choice.Delta.Reasoning)
+	}
+}
+
+func (a *streamAggregator) content() string {
+	return a.contentBuf.String()
+}
+
+func (a *streamAggregator) reasoning() string {
+	return a.reasoningBuf.String()
+}
@@ -0,0 +1,51 @@
+package gateway
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+// ModelAliases returns every model alias and the model it resolves to.
+func (s *service) ModelAliases() map[string]string {
+	return s.registry.listModelAliases()
+}
+
+// SetModelAlias makes requests for alias go to modelID. The alias can not
+// shadow a registered model, and modelID must be routable and not an alias
+// itself.
+func (s *service) SetModelAlias(alias, modelID string) error {
+	if alias == "" || modelID == "" {
+		return api.BadRequestError("alias and model are required")
+	}
+	if alias == modelID {
+		return api.BadRequestError(fmt.Sprintf("alias '%s' can not point to itself", alias))
+	}
+	if _, ok := s.registry.getModel(alias); ok {
+		return api.NewError(http.StatusConflict, "Alias Conflict",
+			fmt.Sprintf("'%s' is a registered model and can not be used as an alias", alias))
+	}
+	if target := s.registry.resolveModelAlias(modelID); target != modelID {
+		return api.BadRequestError(fmt.Sprintf("'%s' is an alias of '%s', aliases can not point to other aliases", modelID, target))
+	}
+	if _, err := s.registry.ResolveRoute(modelID); err != nil {
+		return api.BadRequestError(fmt.Sprintf("alias target '%s' is not a known model", modelID))
+	}
+
+	s.registry.setModelAlias(alias, modelID)
+	return nil
+}
+
+// DeleteModelAlias removes alias, reporting whether it existed.
+func (s *service) DeleteModelAlias(alias string) bool {
+	return s.registry.deleteModelAlias(alias)
+}
+
+// resolveModelAlias points a request for an alias at the model it stands
+// for and returns the model ID the client asked for.
+func (s *service) resolveModelAlias(req *api.ChatRequest) string {
+	requested := req.Model
+	req.Model = s.registry.resolveModelAlias(req.Model)
+	return requested
+}
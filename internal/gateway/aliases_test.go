@@ -0,0 +1,107 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func aliasTestProviders() (*mockProvider, *mockProvider) {
+	openai := &mockProvider{id: "openai", models: []api.ModelDefinition{{ID: "openai/gpt-4o", ProviderID: "openai", UpstreamID: "gpt-4o"}}}
+	anthropic := &mockProvider{id: "anthropic", models: []api.ModelDefinition{{ID: "anthropic/claude-3-haiku", ProviderID: "anthropic", UpstreamID: "claude-3-haiku"}}}
+	return openai, anthropic
+}
+
+func TestChat_ResolvesModelAlias(t *testing.T) {
+	openai, anthropic := aliasTestProviders()
+	svc, ingestor := newTestService(t, config.GatewayConfig{
+		ModelAliases: []config.ModelAlias{{Alias: "fast", Model: "anthropic/claude-3-haiku"}},
+	}, openai, anthropic)
+
+	_, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "fast",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "claude-3-haiku", anthropic.lastRequest().Model)
+	log := ingestor.last(t)
+	assert.Equal(t, "fast", log.RequestedModelID)
+	assert.Equal(t, "anthropic/claude-3-haiku", log.ModelID)
+
+	// models requested by their own ID are recorded as requested
+	_, err = svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "openai/gpt-4o",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "openai/gpt-4o", ingestor.last(t).RequestedModelID)
+}
+
+func TestStreamChat_ResolvesModelAlias(t *testing.T) {
+	openai, anthropic := aliasTestProviders()
+	openai.streamResp = []api.StreamResult{textDelta("Hello", "")}
+	svc, ingestor := newTestService(t, config.GatewayConfig{}, openai, anthropic)
+	require.NoError(t, svc.SetModelAlias("gpt-4", "openai/gpt-4o"))
+
+	ch, err := svc.StreamChat(context.Background(), &api.ChatRequest{
+		Model:    "gpt-4",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+	drain(t, ch)
+
+	assert.Equal(t, "gpt-4o", openai.lastRequest().Model)
+	log := ingestor.last(t)
+	assert.Equal(t, "gpt-4", log.RequestedModelID)
+	assert.Equal(t, "openai/gpt-4o", log.ModelID)
+}
+
+func TestSetModelAlias_Validation(t *testing.T) {
+	openai, anthropic := aliasTestProviders()
+	svc, _ := newTestService(t, config.GatewayConfig{}, openai, anthropic)
+
+	// an alias can not shadow a registered model
+	err := svc.SetModelAlias("openai/gpt-4o", "anthropic/claude-3-haiku")
+	assert.Equal(t, http.StatusConflict, errorStatus(err))
+
+	err = svc.SetModelAlias("fast", "unknown/model")
+	assert.Equal(t, http.StatusBadRequest, errorStatus(err))
+
+	require.NoError(t, svc.SetModelAlias("fast", "anthropic/claude-3-haiku"))
+	err = svc.SetModelAlias("quick", "fast")
+	assert.Equal(t, http.StatusBadRequest, errorStatus(err), "aliases resolve one level")
+
+	// repointing an alias replaces its target
+	require.NoError(t, svc.SetModelAlias("fast", "openai/gpt-4o"))
+	assert.Equal(t, map[string]string{"fast": "openai/gpt-4o"}, svc.ModelAliases())
+
+	assert.True(t, svc.DeleteModelAlias("fast"))
+	assert.False(t, svc.DeleteModelAlias("fast"))
+	assert.Empty(t, svc.ModelAliases())
+}
+
+func TestListAllModels_ListsAliases(t *testing.T) {
+	openai, anthropic := aliasTestProviders()
+	cfg := config.GatewayConfig{
+		ModelAliases: []config.ModelAlias{{Alias: "fast", Model: "anthropic/claude-3-haiku"}},
+	}
+
+	svc, _ := newTestService(t, cfg, openai, anthropic)
+	models, err := svc.ListAllModels(context.Background(), api.ModelFilter{})
+	require.NoError(t, err)
+	assert.Len(t, models, 2, "aliases are hidden unless listing them is enabled")
+
+	cfg.ListModelAliases = true
+	svc, _ = newTestService(t, cfg, openai, anthropic)
+	models, err = svc.ListAllModels(context.Background(), api.ModelFilter{ID: "fast"})
+	require.NoError(t, err)
+	require.Len(t, models, 1)
+	assert.Equal(t, "fast", models[0].ID)
+	assert.Equal(t, "anthropic/claude-3-haiku", models[0].AliasOf)
+	assert.Equal(t, "anthropic", models[0].Provider)
+}
@@ -0,0 +1,46 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+// appNameFromContext returns the app the request is attributed to. An
+// explicit X-App-Name wins over the OpenRouter style X-Title.
+func appNameFromContext(ctx context.Context) string {
+	if val, ok := ctx.Value(store.ContextKeyAppName).(string); ok && val != "" {
+		return val
+	}
+	if val, ok := ctx.Value(store.ContextKeyAppTitle).(string); ok {
+		return val
+	}
+	return ""
+}
+
+// requestMeta builds the meta_json tags stored alongside the request log,
+// including the request metadata and any citations the provider returned.
+func requestMeta(ctx context.Context, req *api.ChatRequest, citations []string) string {
+	meta := make(map[string]interface{})
+	if val, ok := ctx.Value(store.ContextKeyAppReferer).(string); ok && val != "" {
+		meta["referer"] = val
+	}
+	if len(req.Metadata) > 0 {
+		meta["metadata"] = req.Metadata
+	}
+	if len(citations) > 0 {
+		meta["citations"] = citations
+	}
+
+	if len(meta) == 0 {
+		return ""
+	}
+
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
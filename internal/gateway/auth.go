@@ -0,0 +1,148 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/llm"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"go.uber.org/zap"
+)
+
+// defaultAuthProbeInterval applies when no probe interval is configured.
+const defaultAuthProbeInterval = time.Minute
+
+// authState is the key health of a provider, driven by its 401 responses.
+type authState struct {
+	failures  int // consecutive 401s
+	unhealthy bool
+	lastError string
+	since     time.Time
+}
+
+// authHealth tracks, per provider, whether its API key is still accepted.
+// Only 401s count: transient 5xx and rate limits say nothing about the key.
+type authHealth struct {
+	mu     sync.Mutex
+	states map[string]*authState
+}
+
+func newAuthHealth() *authHealth {
+	return &authHealth{states: make(map[string]*authState)}
+}
+
+func (a *authHealth) get(providerID string) (authState, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	state, ok := a.states[providerID]
+	if !ok {
+		return authState{}, false
+	}
+	return *state, true
+}
+
+// failure records a 401 and reports whether it just made the key unhealthy.
+func (a *authHealth) failure(providerID, msg string, threshold int) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	state, ok := a.states[providerID]
+	if !ok {
+		state = &authState{}
+		a.states[providerID] = state
+	}
+	state.failures++
+	state.lastError = msg
+	if state.unhealthy || state.failures < threshold {
+		return false
+	}
+	state.unhealthy = true
+	state.since = time.Now()
+	return true
+}
+
+func (a *authHealth) reset(providerID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.states, providerID)
+}
+
+// checkAuth short-circuits requests to a provider whose key was rejected,
+// until the background probe re-validates it. The 503 lets routing move on
+// to the next candidate.
+func (s *service) checkAuth(providerID string) error {
+	state, ok := s.auth.get(providerID)
+	if !ok || !state.unhealthy {
+		return nil
+	}
+	return api.NewError(http.StatusServiceUnavailable, "Provider Authentication Failed",
+		fmt.Sprintf("provider '%s' rejected its API key %d times in a row, requests are paused until the key is re-validated", providerID, state.failures),
+		api.WithExtension("provider", providerID),
+		api.WithExtension("unhealthy_since", state.since),
+	)
+}
+
+// recordAuth updates the key health of p with the outcome of a request. Once
+// AuthFailureThreshold consecutive 401s are seen the key is marked unhealthy,
+// an alert is logged and a probe is started to re-validate it.
+func (s *service) recordAuth(p llm.Provider, err error) {
+	threshold := s.config.AuthFailureThreshold
+	if threshold <= 0 {
+		return
+	}
+	if err == nil {
+		if _, ok := s.auth.get(p.Name()); ok {
+			s.auth.reset(p.Name())
+		}
+		return
+	}
+	if errorStatus(err) != http.StatusUnauthorized {
+		return
+	}
+
+	if s.auth.failure(p.Name(), err.Error(), threshold) {
+		s.logger.Error("Provider API key rejected, pausing requests to it",
+			zap.String("alert", "provider_auth_failed"),
+			zap.String("provider", p.Name()),
+			zap.Int("consecutive_failures", threshold),
+			zap.Error(err),
+		)
+		go s.probeAuth(p)
+	}
+}
+
+// probeAuth runs the provider health check on AuthProbeInterval until it
+// passes, which marks the key healthy again, or the provider is unregistered.
+func (s *service) probeAuth(p llm.Provider) {
+	interval := s.config.AuthProbeInterval
+	if interval <= 0 {
+		interval = defaultAuthProbeInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.mu.RLock()
+		registered := s.providers[p.Name()] == p
+		s.mu.RUnlock()
+		if !registered {
+			s.auth.reset(p.Name())
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := p.Health(ctx)
+		cancel()
+		if err == nil {
+			s.auth.reset(p.Name())
+			s.logger.Info("Provider API key re-validated, resuming requests", zap.String("provider", p.Name()))
+			return
+		}
+		s.logger.Debug("Provider API key still rejected", zap.String("provider", p.Name()), zap.Error(err))
+	}
+}
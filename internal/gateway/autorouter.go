@@ -0,0 +1,121 @@
+package gateway
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"go.uber.org/zap"
+)
+
+// defaultAutoModel is the ID of the auto-router virtual model when none is
+// configured.
+const defaultAutoModel = "prism/auto"
+
+// codePattern matches lines that look like source code: fenced blocks,
+// declarations and statements of common languages.
+var codePattern = regexp.MustCompile("(?m)^\\s*(```|(func|def|class|import|from|package|public|private|const|let|var|return|#include|SELECT|fn)\\b)|[;{}]\\s*$")
+
+// promptTraits is what the auto-router classifies a prompt by.
+type promptTraits struct {
+	tokens int
+	vision bool
+	tools  bool
+	code   bool
+}
+
+// classifyPrompt estimates the size of req and whether it carries images,
+// tool definitions or code. Code needs at least two matching lines, so prose
+// mentioning a keyword is not taken for it.
+func classifyPrompt(req *api.ChatRequest) promptTraits {
+	traits := promptTraits{vision: hasImages(req), tools: len(req.Tools) > 0}
+	traits.tokens, _ = estimateTokens(req)
+
+	lines := 0
+	for _, m := range req.Messages {
+		if m.Role == "system" {
+			continue
+		}
+		text := m.Content.Text
+		for _, part := range m.Content.Parts {
+			text += "\n" + part.Text
+		}
+		lines += len(codePattern.FindAllStringIndex(text, -1))
+	}
+	traits.code = lines >= 2
+	return traits
+}
+
+// tierMatches reports whether a prompt meets every condition the tier sets.
+func tierMatches(tier config.AutoTier, traits promptTraits) bool {
+	switch {
+	case tier.Vision && !traits.vision,
+		tier.Tools && !traits.tools,
+		tier.Code && !traits.code,
+		tier.MinPromptTokens > 0 && traits.tokens < tier.MinPromptTokens,
+		tier.MaxPromptTokens > 0 && traits.tokens > tier.MaxPromptTokens:
+		return false
+	}
+	return true
+}
+
+// autoModel returns the ID of the auto-router virtual model, empty when no
+// tiers are configured.
+func (s *service) autoModel() string {
+	if len(s.config.AutoRouter.Tiers) == 0 {
+		return ""
+	}
+	if s.config.AutoRouter.Model != "" {
+		return s.config.AutoRouter.Model
+	}
+	return defaultAutoModel
+}
+
+// applyAutoRouter replaces the auto-router virtual model with the model of
+// the first tier the prompt matches.
+func (s *service) applyAutoRouter(req *api.ChatRequest) error {
+	if auto := s.autoModel(); auto == "" || req.Model != auto {
+		return nil
+	}
+
+	traits := classifyPrompt(req)
+	for i, tier := range s.config.AutoRouter.Tiers {
+		if !tierMatches(tier, traits) {
+			continue
+		}
+		s.logger.Debug("Auto-router picked a model",
+			zap.String("model", tier.Model),
+			zap.Int("tier", i),
+			zap.Int("estimated_tokens", traits.tokens),
+			zap.Bool("vision", traits.vision),
+			zap.Bool("tools", traits.tools),
+			zap.Bool("code", traits.code),
+		)
+		req.Model = s.registry.resolveModelAlias(tier.Model)
+		return nil
+	}
+
+	needs := []string{}
+	if traits.vision {
+		needs = append(needs, "vision")
+	}
+	if traits.tools {
+		needs = append(needs, "tools")
+	}
+	if traits.code {
+		needs = append(needs, "code")
+	}
+	return api.BadRequestError(
+		fmt.Sprintf("no auto-router tier matches this prompt (about %d tokens, needs: [%s])", traits.tokens, strings.Join(needs, ", ")),
+		api.WithExtension("estimated_tokens", traits.tokens),
+	)
+}
+
+// reportsServedModel reports whether the response of req names the model
+// that served it rather than the upstream's name for it: when the client let
+// the gateway choose, through a models list or the auto-router.
+func (s *service) reportsServedModel(req *api.ChatRequest, requestedModel string) bool {
+	return len(req.Models) > 0 || (requestedModel != "" && requestedModel == s.autoModel())
+}
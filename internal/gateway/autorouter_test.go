@@ -0,0 +1,80 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyPrompt(t *testing.T) {
+	code := "Why does this fail?\n```go\nfunc main() {\n\tfmt.Println(x)\n}\n```"
+	traits := classifyPrompt(&api.ChatRequest{Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: code}}}})
+	assert.True(t, traits.code)
+	assert.False(t, traits.vision)
+	assert.False(t, traits.tools)
+
+	prose := "Can you return the book I lent you? Let me know what class you are in."
+	traits = classifyPrompt(&api.ChatRequest{Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: prose}}}})
+	assert.False(t, traits.code)
+	assert.Equal(t, (len(prose)+3)/4, traits.tokens)
+
+	traits = classifyPrompt(imageRequest("any"))
+	assert.True(t, traits.vision)
+}
+
+func TestChat_AutoRouter(t *testing.T) {
+	providers := []*mockProvider{
+		{id: "vision", models: []api.ModelDefinition{{ID: "vision/model", ProviderID: "vision"}}},
+		{id: "coder", models: []api.ModelDefinition{{ID: "coder/model", ProviderID: "coder"}}},
+		{id: "long", models: []api.ModelDefinition{{ID: "long/model", ProviderID: "long"}}},
+		{id: "cheap", models: []api.ModelDefinition{{ID: "cheap/model", ProviderID: "cheap"}}},
+	}
+	svc, ingestor := newTestService(t, config.GatewayConfig{
+		AutoRouter: config.AutoRouterConfig{Tiers: []config.AutoTier{
+			{Model: "vision/model", Vision: true},
+			{Model: "coder/model", Code: true},
+			{Model: "long/model", MinPromptTokens: 1000},
+			{Model: "cheap/model", MaxPromptTokens: 500},
+		}},
+	}, providers...)
+
+	tests := []struct {
+		name string
+		req  *api.ChatRequest
+		want string
+	}{
+		{"vision", imageRequest("prism/auto"), "vision/model"},
+		{"short", &api.ChatRequest{Messages: []api.ChatMessage{message("user", 1)}}, "cheap/model"},
+		{"long", &api.ChatRequest{Messages: []api.ChatMessage{message("user", 2000)}}, "long/model"},
+		{"code", &api.ChatRequest{Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "package main\n\nimport \"fmt\""}}}}, "coder/model"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.Model = "prism/auto"
+			resp, err := svc.Chat(context.Background(), tt.req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, resp.Model, "the chosen model is reported")
+
+			log := ingestor.last(t)
+			assert.Equal(t, "prism/auto", log.RequestedModelID)
+			assert.Equal(t, tt.want, log.ModelID)
+		})
+	}
+
+	// between the tiers' bounds nothing matches
+	_, err := svc.Chat(context.Background(), &api.ChatRequest{Model: "prism/auto", Messages: []api.ChatMessage{message("user", 700)}})
+	assert.Equal(t, http.StatusBadRequest, errorStatus(err))
+	assert.ErrorContains(t, err, "no auto-router tier matches")
+}
+
+func TestChat_AutoRouterDisabledWithoutTiers(t *testing.T) {
+	svc, _ := newTestService(t, config.GatewayConfig{})
+
+	_, err := svc.Chat(context.Background(), &api.ChatRequest{Model: "prism/auto", Messages: []api.ChatMessage{message("user", 1)}})
+	assert.Equal(t, http.StatusBadRequest, errorStatus(err), "prism/auto is an unknown model")
+}
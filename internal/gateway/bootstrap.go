@@ -3,6 +3,8 @@ package gateway
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -12,8 +14,14 @@ import (
 	"go.uber.org/zap"
 )
 
-// BootstrapProviders initializes and registers all enabled providers from configuration.
-func BootstrapProviders(ctx context.Context, service Service, providers []config.ProviderConfig, log *zap.Logger) int {
+// BootstrapProviders initializes and registers all enabled providers from
+// configuration. It fails before registering anything when a provider type
+// has no registered factory.
+func BootstrapProviders(ctx context.Context, service Service, providers []config.ProviderConfig, log *zap.Logger) (int, error) {
+	if err := CheckProviderFactories(providers); err != nil {
+		return 0, err
+	}
+
 	registeredCount := 0
 	validate := validator.New()
 
@@ -21,85 +29,121 @@ func BootstrapProviders(ctx context.Context, service Service, providers []config
 		if !pCfg.Enabled {
 			continue
 		}
-
-		// validate provider configuration
-		if err := validate.Struct(&pCfg); err != nil {
-			log.Warn(fmt.Sprintf("%s %s %s",
-				cli.CrossMark(),
-				cli.Stylize(fmt.Sprintf("%s\t", pCfg.ID), cli.Black),
-				cli.Stylize(err.Error(), cli.Yellow),
-			))
-			continue
+		if registerProvider(ctx, service, validate, pCfg, log) {
+			registeredCount++
 		}
+	}
 
-		factoryFunc, err := llm.Get(pCfg.Type)
-		if err != nil {
-			log.Error("Unknown provider type", zap.String("type", pCfg.Type))
-			continue
-		}
+	if registeredCount == 0 {
+		log.Warn("No providers were registered. API will not function correctly.")
+	}
 
-		providerInstance, err := factoryFunc(pCfg)
-		if err != nil {
-			log.Error("Failed to initialize provider",
-				zap.String("id", pCfg.ID),
-				zap.Error(err),
-			)
-			continue
+	return registeredCount, nil
+}
+
+// CheckProviderFactories verifies that every configured provider type has a
+// factory registered with llm.Register, listing all missing types at once.
+// Disabled providers are checked too since they may be enabled at runtime.
+func CheckProviderFactories(providers []config.ProviderConfig) error {
+	missing := make(map[string][]string)
+	for _, p := range providers {
+		if _, err := llm.Get(p.Type); err != nil {
+			missing[p.Type] = append(missing[p.Type], p.ID)
 		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
 
-		models, err := providerInstance.Models(ctx)
+	types := make([]string, 0, len(missing))
+	for t := range missing {
+		types = append(types, t)
+	}
+	slices.Sort(types)
 
-		if err != nil {
-			msg := fmt.Sprintf("%s %s %s",
-				cli.CrossMark(),
-				cli.Stylize(pCfg.ID, cli.Red),
-				cli.Stylize(fmt.Sprintf("(Failed: %v)", err), cli.Red),
-			)
-			log.Error(msg)
-		}
+	parts := make([]string, len(types))
+	for i, t := range types {
+		parts[i] = fmt.Sprintf("%q (providers: %s)", t, strings.Join(missing[t], ", "))
+	}
+	return fmt.Errorf("no provider factory registered for type %s; registered types are: %s",
+		strings.Join(parts, ", "), strings.Join(llm.Types(), ", "))
+}
 
-		if len(models) == 0 {
-			msg := fmt.Sprintf("%s %s %s",
-				cli.CrossMark(),
-				cli.Stylize(pCfg.ID, cli.Cyan),
-				cli.Stylize("0 models found", cli.Red),
-			)
-			log.Warn(msg)
-			continue
-		}
+// registerProvider builds, checks and registers a single provider, logging why
+// it was skipped otherwise.
+func registerProvider(ctx context.Context, service Service, validate *validator.Validate, pCfg config.ProviderConfig, log *zap.Logger) bool {
+	// validate provider configuration
+	if err := validate.Struct(&pCfg); err != nil {
+		log.Warn(fmt.Sprintf("%s %s %s",
+			cli.CrossMark(),
+			cli.Stylize(fmt.Sprintf("%s\t", pCfg.ID), cli.Black),
+			cli.Stylize(err.Error(), cli.Yellow),
+		))
+		return false
+	}
 
-		// perform health checks
-		healthCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-		if err := providerInstance.Health(healthCtx); err != nil {
-			cancel()
-			log.Error("Provider unhealthy, skipping registration",
-				zap.String("id", pCfg.ID),
-				zap.Error(err))
-			continue
-		}
-		cancel()
+	factoryFunc, err := llm.Get(pCfg.Type)
+	if err != nil {
+		log.Error("Unknown provider type", zap.String("type", pCfg.Type))
+		return false
+	}
 
-		// register with the service
-		if err := service.RegisterProvider(ctx, providerInstance); err != nil {
-			log.Error("Failed to register provider", zap.String("id", pCfg.ID), zap.Error(err))
-			continue
-		}
+	providerInstance, err := factoryFunc(pCfg)
+	if err != nil {
+		log.Error("Failed to initialize provider",
+			zap.String("id", pCfg.ID),
+			zap.Error(err),
+		)
+		return false
+	}
 
-		msg := fmt.Sprintf("%s %s %s %s",
-			cli.CheckMark(),
-			cli.Stylize(fmt.Sprintf("%s\t", pCfg.ID), cli.Green),
-			"registered with: ",
-			cli.Stylize(fmt.Sprintf("%d models", len(models)), cli.White),
+	models, err := providerInstance.Models(ctx)
+
+	if err != nil {
+		msg := fmt.Sprintf("%s %s %s",
+			cli.CrossMark(),
+			cli.Stylize(pCfg.ID, cli.Red),
+			cli.Stylize(fmt.Sprintf("(Failed: %v)", err), cli.Red),
 		)
+		log.Error(msg)
+	}
 
-		log.Info(msg)
+	if len(models) == 0 {
+		msg := fmt.Sprintf("%s %s %s",
+			cli.CrossMark(),
+			cli.Stylize(pCfg.ID, cli.Cyan),
+			cli.Stylize("0 models found", cli.Red),
+		)
+		log.Warn(msg)
+		return false
+	}
 
-		registeredCount++
+	// perform health checks
+	healthCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	if err := providerInstance.Health(healthCtx); err != nil {
+		cancel()
+		log.Error("Provider unhealthy, skipping registration",
+			zap.String("id", pCfg.ID),
+			zap.Error(err))
+		return false
 	}
+	cancel()
 
-	if registeredCount == 0 {
-		log.Warn("No providers were registered. API will not function correctly.")
+	// register with the service, the config ID routes to the adapter even
+	// when its Name() differs
+	if err := service.RegisterProvider(ctx, providerInstance, pCfg.ID); err != nil {
+		log.Error("Failed to register provider", zap.String("id", pCfg.ID), zap.Error(err))
+		return false
 	}
 
-	return registeredCount
+	msg := fmt.Sprintf("%s %s %s %s",
+		cli.CheckMark(),
+		cli.Stylize(fmt.Sprintf("%s\t", pCfg.ID), cli.Green),
+		"registered with: ",
+		cli.Stylize(fmt.Sprintf("%d models", len(models)), cli.White),
+	)
+
+	log.Info(msg)
+
+	return true
 }
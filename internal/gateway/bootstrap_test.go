@@ -0,0 +1,30 @@
+package gateway
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	_ "github.com/nulzo/model-router-api/internal/llm/openai"
+)
+
+func TestBootstrapProviders_UnregisteredType(t *testing.T) {
+	svc, _ := newTestService(t, config.GatewayConfig{})
+
+	registered, err := BootstrapProviders(context.Background(), svc, []config.ProviderConfig{
+		{ID: "main", Type: "openai", Enabled: true},
+		{ID: "mistral-a", Type: "mistral", Enabled: true},
+		{ID: "mistral-b", Type: "mistral", Enabled: false},
+		{ID: "cohere", Type: "cohere", Enabled: true},
+	}, zap.NewNop())
+
+	require.Error(t, err)
+	assert.Zero(t, registered)
+	assert.Contains(t, err.Error(), `"cohere" (providers: cohere), "mistral" (providers: mistral-a, mistral-b)`)
+	assert.Contains(t, err.Error(), "openai")
+	assert.Empty(t, svc.Capabilities(), "nothing is registered when a factory is missing")
+}
@@ -0,0 +1,143 @@
+package gateway
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/nulzo/model-router-api/internal/flags"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"go.uber.org/zap"
+)
+
+// defaultMinAffordableTokens applies when no minimum is configured.
+const defaultMinAffordableTokens = 16
+
+// capTokensToBalance clamps the request's output tokens to what the calling
+// user's wallet can pay for at the output price of the most expensive model
+// the request may be routed to, after the estimated prompt cost. Requests
+// that can not afford the configured minimum completion are rejected with a
+// 402. Callers without a key or wallet, and models without output pricing,
+// are left alone, except for models falling through to the fallthrough
+// provider: nothing bounds what they cost, so they are rejected.
+func (s *service) capTokensToBalance(ctx context.Context, req *api.ChatRequest) error {
+	if !s.featureEnabled(ctx, flags.BalanceTokenCap, req.Model, s.config.BalanceTokenCap) {
+		return nil
+	}
+	apiKey, ok := ctx.Value(store.ContextKeyAPIKey).(*model.APIKey)
+	if !ok {
+		return nil
+	}
+
+	priced := make(map[string]*model.Model)
+	var unpriced string // the first unpriced fallthrough model
+	for _, modelID := range s.routeCandidates(req) {
+		pricing, err := s.repo.Providers().GetModelPricing(ctx, modelID)
+		if err == nil && pricing.OutputCostMicrosPer1k > 0 {
+			priced[modelID] = pricing
+		} else if unpriced == "" && s.fallsThrough(modelID) {
+			unpriced = modelID
+		}
+	}
+	if len(priced) == 0 && unpriced == "" {
+		return nil
+	}
+	wallet, err := s.repo.Users().GetWallet(ctx, apiKey.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		s.logger.Warn("Failed to load wallet for token cap", zap.String("user_id", apiKey.UserID), zap.Error(err))
+		return nil
+	}
+	if unpriced != "" {
+		return api.NewError(http.StatusPaymentRequired, "Model Not Priced",
+			fmt.Sprintf("model '%s' has no pricing and can not be paid for from the balance", unpriced),
+			api.WithExtension("model", unpriced),
+		)
+	}
+
+	// a fallback may serve the request, so the priciest candidate sets the cap
+	prompt, _ := estimateTokens(req)
+	affordable, limiting := -1, ""
+	for modelID, pricing := range priced {
+		remaining := wallet.BalanceMicros - costMicros(pricing, prompt, 0, nil)
+		tokens := int(max(remaining, 0) * 1000 / pricing.OutputCostMicrosPer1k)
+		if affordable < 0 || tokens < affordable || (tokens == affordable && modelID < limiting) {
+			affordable, limiting = tokens, modelID
+		}
+	}
+
+	minTokens := s.config.MinAffordableTokens
+	if minTokens <= 0 {
+		minTokens = defaultMinAffordableTokens
+	}
+	if affordable < minTokens {
+		return api.NewError(http.StatusPaymentRequired, "Insufficient Balance",
+			fmt.Sprintf("the remaining balance affords %d output tokens on '%s', below the minimum of %d", affordable, limiting, minTokens),
+			api.WithExtension("balance_micros", wallet.BalanceMicros),
+			api.WithExtension("affordable_tokens", affordable),
+		)
+	}
+
+	// max_completion_tokens takes precedence upstream, so it is the one clamped when set
+	limit := &req.MaxTokens
+	if req.MaxCompletionTokens > 0 {
+		limit = &req.MaxCompletionTokens
+	}
+	if *limit == 0 || *limit > affordable {
+		*limit = affordable
+	}
+	return nil
+}
+
+// fallsThrough reports whether modelID is unknown to the registry and would
+// be sent to the fallthrough provider.
+func (s *service) fallsThrough(modelID string) bool {
+	if s.config.FallthroughProvider == "" {
+		return false
+	}
+	_, err := s.registry.ResolveRoute(modelID)
+	return err != nil
+}
+
+// checkSpeechBalance rejects a speech request whose input the calling user's
+// wallet can not pay for, speech being billed per input character up front.
+// It is gated like capTokensToBalance and leaves callers without a key or
+// wallet, and unpriced models, alone.
+func (s *service) checkSpeechBalance(ctx context.Context, req *api.SpeechRequest) error {
+	if !s.featureEnabled(ctx, flags.BalanceTokenCap, req.Model, s.config.BalanceTokenCap) {
+		return nil
+	}
+	apiKey, ok := ctx.Value(store.ContextKeyAPIKey).(*model.APIKey)
+	if !ok {
+		return nil
+	}
+	pricing, err := s.repo.Providers().GetModelPricing(ctx, req.Model)
+	if err != nil || pricing.InputCostMicrosPer1k <= 0 {
+		return nil
+	}
+	wallet, err := s.repo.Users().GetWallet(ctx, apiKey.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		s.logger.Warn("Failed to load wallet for speech balance check", zap.String("user_id", apiKey.UserID), zap.Error(err))
+		return nil
+	}
+
+	cost := costMicros(pricing, utf8.RuneCountInString(req.Input), 0, nil)
+	if cost > wallet.BalanceMicros {
+		return api.NewError(http.StatusPaymentRequired, "Insufficient Balance",
+			fmt.Sprintf("the remaining balance does not cover the %d micros '%s' charges for the input", cost, req.Model),
+			api.WithExtension("balance_micros", wallet.BalanceMicros),
+			api.WithExtension("cost_micros", cost),
+		)
+	}
+	return nil
+}
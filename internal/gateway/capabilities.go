@@ -0,0 +1,111 @@
+package gateway
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/nulzo/model-router-api/internal/llm"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+// ProviderCapabilities is a registered provider and the features it supports.
+type ProviderCapabilities struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	llm.Capabilities
+}
+
+func (s *service) Capabilities() []ProviderCapabilities {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matrix := make([]ProviderCapabilities, 0, len(s.providers))
+	for id, p := range s.providers {
+		matrix = append(matrix, ProviderCapabilities{
+			ID:           id,
+			Type:         p.Type(),
+			Capabilities: llm.DetectCapabilities(p, s.registry.providerModels(id)),
+		})
+	}
+
+	sort.Slice(matrix, func(i, j int) bool { return matrix[i].ID < matrix[j].ID })
+	return matrix
+}
+
+const (
+	// CapabilityCheckReject answers requests a model can not serve with a 400.
+	CapabilityCheckReject = "reject"
+	// CapabilityCheckReroute skips models that can not serve a request.
+	CapabilityCheckReroute = "reroute"
+)
+
+// checkModelCapabilities returns why modelID can not serve req according to
+// its definition, if it can not. Models without a definition are not checked.
+func (s *service) checkModelCapabilities(modelID string, req *api.ChatRequest) error {
+	def, ok := s.registry.getModel(modelID)
+	if !ok {
+		return nil
+	}
+
+	var missing []string
+	if hasImages(req) && !def.Config.ImageSupport && !slices.Contains(def.Architecture.InputModalities, "image") {
+		missing = append(missing, "images")
+	}
+	if len(req.Tools) > 0 && !def.Config.ToolUse {
+		missing = append(missing, "tools")
+	}
+	if req.Stream && !def.Config.StreamingSupport {
+		missing = append(missing, "streaming")
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return api.BadRequestError(
+		fmt.Sprintf("model '%s' does not support %s", modelID, strings.Join(missing, ", ")),
+		api.WithExtension("model", modelID),
+		api.WithExtension("unsupported", missing),
+	)
+}
+
+// capableCandidates applies the capability check to the models a request may
+// be routed to. Under "reject" an incapable requested model fails the
+// request, under "reroute" it is skipped like incapable fallbacks are.
+func (s *service) capableCandidates(req *api.ChatRequest, candidates []string) ([]string, error) {
+	mode := s.config.CapabilityCheck
+	if mode != CapabilityCheckReject && mode != CapabilityCheckReroute {
+		return candidates, nil
+	}
+
+	var capable []string
+	var firstErr error
+	for i, modelID := range candidates {
+		if err := s.checkModelCapabilities(modelID, req); err != nil {
+			if i == 0 && mode == CapabilityCheckReject {
+				return nil, err
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		capable = append(capable, modelID)
+	}
+	if len(capable) == 0 {
+		return nil, firstErr
+	}
+	return capable, nil
+}
+
+// hasImages reports whether any message of req carries an image.
+func hasImages(req *api.ChatRequest) bool {
+	for _, m := range req.Messages {
+		for _, part := range m.Content.Parts {
+			if part.Type == "image_url" || part.ImageURL != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
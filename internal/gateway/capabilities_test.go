@@ -0,0 +1,86 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func imageRequest(modelID string) *api.ChatRequest {
+	return &api.ChatRequest{
+		Model: modelID,
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Parts: []api.ContentPart{
+			{Type: "text", Text: "What is this?"},
+			{Type: "image_url", ImageURL: &api.ImageURL{URL: "https://example.com/cat.png"}},
+		}}}},
+	}
+}
+
+func capabilityTestService(t *testing.T, mode string) (*service, *mockProvider, *mockProvider) {
+	text := &mockProvider{id: "text", models: []api.ModelDefinition{{ID: "text/model", ProviderID: "text"}}}
+	vision := &mockProvider{id: "vision", models: []api.ModelDefinition{{
+		ID:           "vision/model",
+		ProviderID:   "vision",
+		Config:       api.ModelConfig{StreamingSupport: true},
+		Architecture: api.ModelArchitecture{InputModalities: []string{"text", "image"}},
+	}}}
+	svc, _ := newTestService(t, config.GatewayConfig{
+		CapabilityCheck: mode,
+		Fallbacks:       []config.FallbackConfig{{Model: "text/model", Fallbacks: []string{"vision/model"}}},
+	}, text, vision)
+	return svc, text, vision
+}
+
+func TestChat_CapabilityCheckRejects(t *testing.T) {
+	svc, text, vision := capabilityTestService(t, CapabilityCheckReject)
+
+	_, err := svc.Chat(context.Background(), imageRequest("text/model"))
+	assert.Equal(t, http.StatusBadRequest, errorStatus(err))
+	assert.ErrorContains(t, err, "does not support images")
+	assert.Empty(t, text.requests)
+	assert.Empty(t, vision.requests)
+
+	_, err = svc.StreamChat(context.Background(), &api.ChatRequest{
+		Model:    "text/model",
+		Stream:   true,
+		Tools:    []api.Tool{{Type: "function", Function: api.FunctionDescription{Name: "lookup"}}},
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	assert.ErrorContains(t, err, "does not support tools, streaming")
+
+	// text only requests are unaffected
+	_, err = svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "text/model",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+}
+
+func TestChat_CapabilityCheckReroutes(t *testing.T) {
+	svc, text, vision := capabilityTestService(t, CapabilityCheckReroute)
+
+	resp, err := svc.Chat(context.Background(), imageRequest("text/model"))
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+	assert.Empty(t, text.requests)
+	assert.Len(t, vision.requests, 1)
+
+	// no capable candidate left
+	req := imageRequest("text/model")
+	req.Tools = []api.Tool{{Type: "function", Function: api.FunctionDescription{Name: "lookup"}}}
+	_, err = svc.Chat(context.Background(), req)
+	assert.Equal(t, http.StatusBadRequest, errorStatus(err))
+}
+
+func TestChat_CapabilityCheckOff(t *testing.T) {
+	svc, text, _ := capabilityTestService(t, "")
+
+	_, err := svc.Chat(context.Background(), imageRequest("text/model"))
+	require.NoError(t, err)
+	assert.Len(t, text.requests, 1)
+}
@@ -0,0 +1,155 @@
+package gateway
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/nulzo/model-router-api/pkg/api"
+	"go.uber.org/zap"
+)
+
+const (
+	// ContextOverflowReject answers prompts larger than the model's context
+	// window with a 400.
+	ContextOverflowReject = "reject"
+	// ContextOverflowTruncate drops messages until the prompt fits.
+	ContextOverflowTruncate = "truncate"
+	// ContextOverflowReroute skips to the first candidate whose window fits.
+	ContextOverflowReroute = "reroute"
+
+	// TruncateOldest drops the oldest messages first.
+	TruncateOldest = "oldest"
+	// TruncateMiddle keeps the first message after the system prompt and
+	// drops the ones following it.
+	TruncateMiddle = "middle"
+)
+
+// contextWindow returns the context window of a model definition in tokens,
+// zero when unknown.
+func contextWindow(def api.ModelDefinition) int {
+	if def.ContextLength > 0 {
+		return def.ContextLength
+	}
+	return def.Config.ContextWindow
+}
+
+// checkContextWindow returns a 400 when the estimated prompt of req does not
+// fit the context window of modelID. Models without a known window pass.
+func (s *service) checkContextWindow(modelID string, req *api.ChatRequest) error {
+	def, ok := s.registry.getModel(modelID)
+	window := contextWindow(def)
+	if !ok || window <= 0 {
+		return nil
+	}
+	prompt, _ := estimateTokens(req)
+	if prompt <= window {
+		return nil
+	}
+	return api.NewError(http.StatusBadRequest, "Context Length Exceeded",
+		fmt.Sprintf("the prompt is about %d tokens, exceeding the %d token context window of model '%s'", prompt, window, modelID),
+		api.WithExtension("model", modelID),
+		api.WithExtension("estimated_tokens", prompt),
+		api.WithExtension("context_window", window),
+	)
+}
+
+// fittingCandidates applies the context_overflow policy to the models a
+// request may be routed to. Under "reject" an oversized prompt for the
+// requested model fails the request, under "reroute" the models it does not
+// fit are skipped. Truncation happens per route in upstreamRequest.
+func (s *service) fittingCandidates(req *api.ChatRequest, candidates []string) ([]string, error) {
+	mode := s.config.ContextOverflow
+	if mode != ContextOverflowReject && mode != ContextOverflowReroute {
+		return candidates, nil
+	}
+
+	var fitting []string
+	var firstErr error
+	for i, modelID := range candidates {
+		if err := s.checkContextWindow(modelID, req); err != nil {
+			if i == 0 && mode == ContextOverflowReject {
+				return nil, err
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		fitting = append(fitting, modelID)
+	}
+	if len(fitting) == 0 {
+		return nil, firstErr
+	}
+	return fitting, nil
+}
+
+// truncateToContext drops messages from an upstream request whose prompt and
+// completion do not fit the context window of modelID together. System
+// messages and the last message are always kept, a prompt they alone
+// overflow is sent as is for the provider to reject.
+func (s *service) truncateToContext(modelID string, req *api.ChatRequest) {
+	if s.config.ContextOverflow != ContextOverflowTruncate {
+		return
+	}
+	def, ok := s.registry.getModel(modelID)
+	window := contextWindow(def)
+	if !ok || window <= 0 {
+		return
+	}
+	prompt, completion := estimateTokens(req)
+	budget := window - completion
+	if budget <= 0 {
+		budget = window
+	}
+	if prompt <= budget {
+		return
+	}
+
+	// the conversation, without system messages, in the order it is dropped
+	var droppable []int
+	for i, m := range req.Messages[:len(req.Messages)-1] {
+		if m.Role != "system" {
+			droppable = append(droppable, i)
+		}
+	}
+	if s.config.TruncateStrategy == TruncateMiddle && len(droppable) > 0 {
+		droppable = droppable[1:]
+	}
+
+	dropped := make(map[int]bool)
+	for n, i := range droppable {
+		if prompt <= budget {
+			// tool results can not outlive the assistant message that called them
+			if req.Messages[i].Role != "tool" || n == 0 || !dropped[droppable[n-1]] {
+				break
+			}
+		}
+		dropped[i] = true
+		prompt -= messageTokens(req.Messages[i])
+	}
+	if prompt > window {
+		return
+	}
+
+	messages := make([]api.ChatMessage, 0, len(req.Messages)-len(dropped))
+	for i, m := range req.Messages {
+		if !dropped[i] {
+			messages = append(messages, m)
+		}
+	}
+	s.logger.Info("Truncated prompt to fit the context window",
+		zap.String("model", modelID),
+		zap.Int("dropped_messages", len(dropped)),
+		zap.Int("context_window", window),
+	)
+	req.Messages = messages
+}
+
+// messageTokens is the estimateTokens prompt size of a single message.
+func messageTokens(m api.ChatMessage) int {
+	chars := len(m.Content.Text)
+	for _, part := range m.Content.Parts {
+		chars += len(part.Text)
+	}
+	return (chars + 3) / 4
+}
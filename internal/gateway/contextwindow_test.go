@@ -0,0 +1,112 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// message is a chat message of about tokens estimated tokens.
+func message(role string, tokens int) api.ChatMessage {
+	return api.ChatMessage{Role: role, Content: api.Content{Text: strings.Repeat("abcd", tokens)}}
+}
+
+func contextTestService(t *testing.T, cfg config.GatewayConfig) (*service, *mockProvider, *mockProvider) {
+	small := &mockProvider{id: "small", models: []api.ModelDefinition{{ID: "small/model", ProviderID: "small", ContextLength: 1000}}}
+	large := &mockProvider{id: "large", models: []api.ModelDefinition{{ID: "large/model", ProviderID: "large", ContextLength: 100000}}}
+	cfg.Fallbacks = []config.FallbackConfig{{Model: "small/model", Fallbacks: []string{"large/model"}}}
+	svc, _ := newTestService(t, cfg, small, large)
+	return svc, small, large
+}
+
+func TestChat_ContextOverflowRejects(t *testing.T) {
+	svc, small, large := contextTestService(t, config.GatewayConfig{ContextOverflow: ContextOverflowReject})
+
+	_, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "small/model",
+		Messages: []api.ChatMessage{message("user", 1500)},
+	})
+	assert.Equal(t, http.StatusBadRequest, errorStatus(err))
+	assert.ErrorContains(t, err, "1000 token context window")
+	assert.Empty(t, small.requests)
+	assert.Empty(t, large.requests)
+}
+
+func TestChat_ContextOverflowReroutes(t *testing.T) {
+	svc, small, large := contextTestService(t, config.GatewayConfig{ContextOverflow: ContextOverflowReroute})
+
+	_, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "small/model",
+		Messages: []api.ChatMessage{message("user", 1500)},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, small.requests)
+	assert.Len(t, large.requests, 1)
+}
+
+func TestChat_ContextOverflowTruncates(t *testing.T) {
+	messages := []api.ChatMessage{
+		message("system", 100),
+		message("user", 300),
+		message("assistant", 300),
+		message("user", 300),
+		message("assistant", 300),
+		message("user", 100),
+	}
+
+	t.Run("oldest", func(t *testing.T) {
+		svc, small, _ := contextTestService(t, config.GatewayConfig{ContextOverflow: ContextOverflowTruncate})
+
+		_, err := svc.Chat(context.Background(), &api.ChatRequest{Model: "small/model", MaxTokens: 100, Messages: messages})
+		require.NoError(t, err)
+
+		sent := small.lastRequest().Messages
+		require.Len(t, sent, 4)
+		assert.Equal(t, messages[0], sent[0], "system messages are kept")
+		assert.Equal(t, messages[3:], sent[1:])
+		assert.Len(t, messages, 6, "the client's request is left untouched")
+	})
+
+	t.Run("middle", func(t *testing.T) {
+		svc, small, _ := contextTestService(t, config.GatewayConfig{ContextOverflow: ContextOverflowTruncate, TruncateStrategy: TruncateMiddle})
+
+		_, err := svc.Chat(context.Background(), &api.ChatRequest{Model: "small/model", MaxTokens: 100, Messages: messages})
+		require.NoError(t, err)
+
+		sent := small.lastRequest().Messages
+		require.Len(t, sent, 4)
+		assert.Equal(t, messages[:2], sent[:2], "the first message is kept")
+		assert.Equal(t, messages[4:], sent[2:])
+	})
+
+	t.Run("room for the completion", func(t *testing.T) {
+		svc, small, _ := contextTestService(t, config.GatewayConfig{ContextOverflow: ContextOverflowTruncate})
+		fits := []api.ChatMessage{message("user", 400), message("assistant", 400), message("user", 100)}
+
+		// the prompt fits the window alone, not with the completion
+		_, err := svc.Chat(context.Background(), &api.ChatRequest{Model: "small/model", MaxTokens: 200, Messages: fits})
+		require.NoError(t, err)
+		assert.Equal(t, fits[1:], small.lastRequest().Messages)
+	})
+
+	t.Run("orphaned tool results", func(t *testing.T) {
+		svc, small, _ := contextTestService(t, config.GatewayConfig{ContextOverflow: ContextOverflowTruncate})
+		withTools := []api.ChatMessage{
+			message("user", 100),
+			message("assistant", 700),
+			message("tool", 10),
+			message("assistant", 200),
+			message("user", 100),
+		}
+
+		_, err := svc.Chat(context.Background(), &api.ChatRequest{Model: "small/model", MaxTokens: 100, Messages: withTools})
+		require.NoError(t, err)
+		assert.Equal(t, withTools[3:], small.lastRequest().Messages)
+	})
+}
@@ -0,0 +1,55 @@
+package gateway
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/httpclient"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+// cooldowns holds, per provider, until when it asked not to be sent
+// requests. Providers that answer a 429 with a Retry-After longer than the
+// client retries wait are skipped by routing until it passed.
+type cooldowns struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+func newCooldowns() *cooldowns {
+	return &cooldowns{until: make(map[string]time.Time)}
+}
+
+func (c *cooldowns) set(providerID string, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.until[providerID] = time.Now().Add(d)
+}
+
+// get returns until when providerID is cooling down, if it is.
+func (c *cooldowns) get(providerID string) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	until, ok := c.until[providerID]
+	if ok && !time.Now().Before(until) {
+		delete(c.until, providerID)
+		return time.Time{}, false
+	}
+	return until, ok
+}
+
+// retryAfter returns the wait the upstream asked for along with err, zero
+// when it set none. Adapters keep the upstream error as the problem's log.
+func retryAfter(err error) time.Duration {
+	var upstreamErr *httpclient.UpstreamError
+	if errors.As(err, &upstreamErr) {
+		return upstreamErr.RetryAfter
+	}
+	var problem *api.Problem
+	if errors.As(err, &problem) && problem.Log != nil && errors.As(problem.Log, &upstreamErr) {
+		return upstreamErr.RetryAfter
+	}
+	return 0
+}
@@ -0,0 +1,53 @@
+package gateway
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/nulzo/model-router-api/internal/httpclient"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/model"
+)
+
+// withCorrelation returns a context whose upstream requests carry the
+// configured correlation headers. Identifiers are hashed so provider logs
+// never see raw key or user IDs, headers without a value are left out.
+func (s *service) withCorrelation(ctx context.Context, requestID string) context.Context {
+	if len(s.config.CorrelationHeaders) == 0 {
+		return ctx
+	}
+
+	var keyID, userID string
+	if apiKey, ok := ctx.Value(store.ContextKeyAPIKey).(*model.APIKey); ok {
+		keyID, userID = apiKey.ID, apiKey.UserID
+	}
+
+	headers := make(http.Header)
+	for _, h := range s.config.CorrelationHeaders {
+		var value string
+		switch h.Value {
+		case "request_id":
+			value = requestID
+		case "key_hash":
+			value = correlationHash(keyID)
+		case "user_hash":
+			value = correlationHash(userID)
+		case "app_name":
+			value = appNameFromContext(ctx)
+		}
+		if value != "" {
+			headers.Set(h.Name, value)
+		}
+	}
+	return httpclient.WithHeaders(ctx, headers)
+}
+
+func correlationHash(id string) string {
+	if id == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:8])
+}
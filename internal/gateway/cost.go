@@ -0,0 +1,80 @@
+package gateway
+
+import (
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"go.uber.org/zap"
+)
+
+// upstreamCostMicros returns the total cost reported by the provider, if any.
+// The BYOK inference cost is preferred since it reflects what the upstream
+// actually billed, otherwise the OpenRouter style `usage.cost` is used.
+func upstreamCostMicros(usage *api.ResponseUsage) *int64 {
+	var cost float64
+	switch {
+	case usage.CostDetails != nil && usage.CostDetails.UpstreamInferenceCost != nil:
+		cost = *usage.CostDetails.UpstreamInferenceCost
+	case usage.Cost != nil:
+		cost = *usage.Cost
+	default:
+		return nil
+	}
+
+	micros := int64(cost * 1000000)
+	return &micros
+}
+
+// costMicros prices a request from our pricing table. Cached prompt tokens
+// (reads and writes) are billed at their own rate when the model defines one,
+// the remaining prompt tokens at the regular input rate.
+func costMicros(pricing *model.Model, promptTokens, completionTokens int, details *api.PromptTokensDetails) int64 {
+	var cached, written int
+	if details != nil {
+		cached, written = details.CachedTokens, details.CacheWriteTokens
+	}
+
+	cacheRead, cacheWrite := pricing.InputCostMicrosPer1k, pricing.InputCostMicrosPer1k
+	if pricing.CacheReadCostMicrosPer1k > 0 {
+		cacheRead = pricing.CacheReadCostMicrosPer1k
+	}
+	if pricing.CacheWriteCostMicrosPer1k > 0 {
+		cacheWrite = pricing.CacheWriteCostMicrosPer1k
+	}
+
+	uncached := max(promptTokens-cached-written, 0)
+	inputCost := (int64(uncached)*pricing.InputCostMicrosPer1k +
+		int64(cached)*cacheRead +
+		int64(written)*cacheWrite) / 1000
+	outputCost := (int64(completionTokens) * pricing.OutputCostMicrosPer1k) / 1000
+	return inputCost + outputCost
+}
+
+// reconcileCost compares the upstream reported cost against the cost computed
+// from our pricing table and logs a warning when they drift apart by more than
+// the configured relative threshold.
+func (s *service) reconcileCost(log *model.RequestLog) {
+	threshold := s.config.CostDiscrepancyThreshold
+	if threshold <= 0 || log.UsageDetails == nil || log.UsageDetails.UpstreamCostMicros == nil {
+		return
+	}
+
+	upstream := *log.UsageDetails.UpstreamCostMicros
+	computed := log.TotalCostMicros
+
+	diff, base := upstream-computed, max(upstream, computed)
+	if diff < 0 {
+		diff = -diff
+	}
+	if base == 0 || float64(diff)/float64(base) <= threshold {
+		return
+	}
+
+	s.logger.Warn("Upstream cost differs from computed cost",
+		zap.String("request_id", log.ID),
+		zap.String("provider", log.ProviderID),
+		zap.String("model", log.ModelID),
+		zap.Int64("upstream_cost_micros", upstream),
+		zap.Int64("computed_cost_micros", computed),
+		zap.Int64("difference_micros", diff),
+	)
+}
@@ -0,0 +1,105 @@
+package gateway
+
+import (
+	"context"
+	"sort"
+
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+const (
+	// RoutingPriority tries the requested model, then its fallbacks in order.
+	RoutingPriority = "priority"
+	// RoutingCost tries the cheapest healthy candidate first.
+	RoutingCost = "cost"
+)
+
+// defaultCompletionEstimate is the completion size assumed when a request
+// sets no max tokens.
+const defaultCompletionEstimate = 256
+
+// estimateTokens gives a rough prompt and completion size for a request,
+// about four characters per prompt token. It is only used to rank candidates,
+// so it does not need to match the provider's tokenizer.
+func estimateTokens(req *api.ChatRequest) (prompt, completion int) {
+	chars := 0
+	for _, m := range req.Messages {
+		chars += len(m.Content.Text)
+		for _, part := range m.Content.Parts {
+			chars += len(part.Text)
+		}
+	}
+	prompt = (chars + 3) / 4
+
+	switch {
+	case req.MaxCompletionTokens > 0:
+		completion = req.MaxCompletionTokens
+	case req.MaxTokens > 0:
+		completion = req.MaxTokens
+	default:
+		completion = defaultCompletionEstimate
+	}
+	return prompt, completion
+}
+
+// costCandidate is a route candidate ranked by its estimated cost.
+type costCandidate struct {
+	modelID   string
+	cost      int64
+	priced    bool
+	unhealthy bool
+}
+
+// orderByCost reorders candidates cheapest first using the stored pricing and
+// the request's estimated size. Ties keep the configured priority order,
+// unpriced candidates follow the priced ones and providers failing health
+// checks go last. Candidates below the configured quality floor are dropped,
+// unless that would leave none.
+func (s *service) orderByCost(ctx context.Context, req *api.ChatRequest, candidates []string) []string {
+	prompt, completion := estimateTokens(req)
+
+	ranked := make([]costCandidate, 0, len(candidates))
+	for _, modelID := range candidates {
+		if s.config.MinQuality > 0 {
+			if def, ok := s.registry.getModel(modelID); ok && def.Config.Quality < s.config.MinQuality {
+				continue
+			}
+		}
+
+		c := costCandidate{modelID: modelID}
+		// a model spread over several providers is unhealthy once all of them are
+		if targets, err := s.registry.ResolveRoute(modelID); err == nil {
+			c.unhealthy = true
+			for _, t := range targets {
+				if status, ok := s.health.get(t.providerID); !ok || status.Healthy {
+					c.unhealthy = false
+					break
+				}
+			}
+		}
+		if pricing, err := s.repo.Providers().GetModelPricing(ctx, modelID); err == nil {
+			c.cost, c.priced = costMicros(pricing, prompt, completion, nil), true
+		}
+		ranked = append(ranked, c)
+	}
+	if len(ranked) == 0 {
+		return candidates
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.unhealthy != b.unhealthy {
+			return b.unhealthy
+		}
+		if a.priced != b.priced {
+			return a.priced
+		}
+		return a.cost < b.cost
+	})
+
+	ordered := make([]string, len(ranked))
+	for i, c := range ranked {
+		ordered[i] = c.modelID
+	}
+	return ordered
+}
@@ -0,0 +1,110 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nulzo/model-router-api/internal/platform/logger"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+const (
+	// DebugScope lets an API key use debug options such as the upstream echo.
+	DebugScope = "debug"
+
+	defaultDebugEchoMaxBytes = 4096
+)
+
+var (
+	// secretFields are object keys whose values are never echoed.
+	secretFields = []string{"key", "token", "secret", "password", "authorization", "credential"}
+	// secretValues match credentials embedded in free text.
+	secretValues = regexp.MustCompile(`(?i)\b(sk-[a-z0-9_\-]{8,}|bearer\s+[a-z0-9._\-]+)`)
+)
+
+// debugEcho returns the request handed to the provider when the caller asked
+// for it with debug.echo_upstream_body and may see it: the echo must be
+// enabled and the calling key listed or granted the debug scope. Secrets are
+// redacted and the body is capped at MaxBytes.
+func (s *service) debugEcho(ctx context.Context, req, upstreamReq *api.ChatRequest) *api.DebugInfo {
+	cfg := s.config.DebugEcho
+	if !cfg.Enabled || req.Debug == nil || !req.Debug.EchoUpstreamBody {
+		return nil
+	}
+	apiKey, ok := ctx.Value(store.ContextKeyAPIKey).(*model.APIKey)
+	if !ok || !(slices.Contains(cfg.KeyIDs, apiKey.ID) || apiKey.HasScope(DebugScope)) {
+		return nil
+	}
+
+	data, err := json.Marshal(upstreamReq)
+	if err != nil {
+		return nil
+	}
+	var body any
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil
+	}
+	if data, err = json.Marshal(redactSecrets(body)); err != nil {
+		return nil
+	}
+
+	maxBytes := cfg.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultDebugEchoMaxBytes
+	}
+	echo, truncated := truncateUTF8(string(data), maxBytes)
+	return &api.DebugInfo{EchoUpstreamBody: echo, Truncated: truncated}
+}
+
+// redactSecrets replaces the values of secret looking keys and credentials
+// found in strings, recursively.
+func redactSecrets(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, val := range v {
+			if isSecretField(k) {
+				v[k] = logger.Redacted
+				continue
+			}
+			v[k] = redactSecrets(val)
+		}
+	case []any:
+		for i, val := range v {
+			v[i] = redactSecrets(val)
+		}
+	case string:
+		return secretValues.ReplaceAllString(v, logger.Redacted)
+	}
+	return v
+}
+
+func isSecretField(name string) bool {
+	name = strings.ToLower(name)
+	// max_tokens and friends are not secrets
+	if strings.HasSuffix(name, "_tokens") {
+		return false
+	}
+	for _, f := range secretFields {
+		if strings.Contains(name, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a rune.
+func truncateUTF8(s string, n int) (string, bool) {
+	if len(s) <= n {
+		return s, false
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n], true
+}
@@ -0,0 +1,29 @@
+package gateway
+
+import (
+	"github.com/nulzo/model-router-api/pkg/api"
+	"go.uber.org/zap"
+)
+
+// deprecationWarning returns the warning for a request served by a deprecated
+// model, or nil. Each use is logged so operators can find the callers left to
+// migrate.
+func (s *service) deprecationWarning(modelID, apiKeyID string) *api.DeprecationWarning {
+	def, ok := s.registry.getModel(modelID)
+	if !ok || def.Deprecation == nil {
+		return nil
+	}
+	dep := def.Deprecation
+	s.logger.Warn("Request served by a deprecated model",
+		zap.String("model", modelID),
+		zap.String("api_key_id", apiKeyID),
+		zap.String("sunset", dep.Sunset),
+		zap.String("replacement", dep.Replacement),
+	)
+	return &api.DeprecationWarning{
+		Model:       modelID,
+		Notice:      dep.Notice(modelID),
+		Sunset:      dep.Sunset,
+		Replacement: dep.Replacement,
+	}
+}
@@ -0,0 +1,72 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/nulzo/model-router-api/internal/llm"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"go.uber.org/zap"
+)
+
+// Actions for a successful upstream response that carries no content.
+const (
+	EmptyResponsePassthrough = "passthrough"
+	EmptyResponseError       = "error"
+	EmptyResponseRetry       = "retry"
+)
+
+// isEmptyResponse reports whether a successful response has nothing to give
+// the client: no choices, or choices without text, parts, tool calls or images.
+func isEmptyResponse(resp *api.ChatResponse) bool {
+	if resp == nil {
+		return true
+	}
+
+	for _, choice := range resp.Choices {
+		m := choice.Message
+		if m == nil {
+			continue
+		}
+		if m.Content.Text != "" || len(m.Content.Parts) > 0 || len(m.ToolCalls) > 0 || len(m.Images) > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// chatWithEmptyCheck calls the provider and applies the configured empty
+// response action, retrying the same provider when asked to.
+func (s *service) chatWithEmptyCheck(ctx context.Context, provider llm.Provider, req *api.ChatRequest) (*api.ChatResponse, error) {
+	attempts := 1
+	if s.config.EmptyResponseAction == EmptyResponseRetry {
+		attempts += s.config.EmptyResponseRetries
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := provider.Chat(ctx, req)
+		if err != nil || !isEmptyResponse(resp) {
+			return resp, err
+		}
+
+		switch s.config.EmptyResponseAction {
+		case "", EmptyResponsePassthrough:
+			return resp, nil
+		}
+
+		if attempt >= attempts {
+			return nil, api.NewError(http.StatusBadGateway, "Empty Upstream Response",
+				fmt.Sprintf("provider '%s' returned no content after %d attempt(s)", provider.Name(), attempt),
+				api.WithExtension("provider", provider.Name()),
+			)
+		}
+
+		s.logger.Warn("Provider returned an empty response, retrying",
+			zap.String("provider", provider.Name()),
+			zap.String("model", req.Model),
+			zap.Int("attempt", attempt),
+		)
+	}
+}
@@ -0,0 +1,42 @@
+package gateway
+
+import (
+	"time"
+
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+// fallbackResponse returns the configured canned answer for a request that
+// opted in and failed on every provider, or nil when the error should be
+// returned as is. Only failures routing would move past are answered, client
+// errors are the caller's to fix and cancelled requests have nobody left to
+// answer.
+func (s *service) fallbackResponse(req *api.ChatRequest, id string, err error) *api.ChatResponse {
+	content := s.config.FallbackContent
+	if content == "" || req.UseFallbackContent == nil || !*req.UseFallbackContent {
+		return nil
+	}
+	if !isRoutableFailure(err) {
+		return nil
+	}
+	return &api.ChatResponse{
+		ID:      id,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   req.Model,
+		Choices: []api.Choice{{
+			Message:      &api.ChatMessage{Role: "assistant", Content: api.Content{Text: content}},
+			FinishReason: string(api.FinishReasonFallback),
+		}},
+	}
+}
+
+// fallbackStream sends the fallback answer as a single chunk.
+func fallbackStream(resp *api.ChatResponse) <-chan api.StreamResult {
+	resp.Object = "chat.completion.chunk"
+	resp.Choices[0].Delta, resp.Choices[0].Message = resp.Choices[0].Message, nil
+	out := make(chan api.StreamResult, 1)
+	out <- api.StreamResult{Response: resp}
+	close(out)
+	return out
+}
@@ -0,0 +1,16 @@
+package gateway
+
+import (
+	"context"
+
+	"github.com/nulzo/model-router-api/internal/flags"
+)
+
+// featureEnabled resolves a feature flag for the calling key and modelID.
+// Flags that are not set anywhere keep the configured behavior.
+func (s *service) featureEnabled(ctx context.Context, name, modelID string, configured bool) bool {
+	if enabled, ok := s.flags.Lookup(flags.WithModel(ctx, modelID), name); ok {
+		return enabled
+	}
+	return configured
+}
@@ -0,0 +1,226 @@
+package gateway
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/llm"
+	"go.uber.org/zap"
+)
+
+const (
+	// HealthHealthy providers are routed to as usual.
+	HealthHealthy = "healthy"
+	// HealthDegraded providers are slow or have started failing checks, they
+	// are only routed to when no healthy provider serves the model.
+	HealthDegraded = "degraded"
+	// HealthUnhealthy providers are skipped by routing.
+	HealthUnhealthy = "unhealthy"
+)
+
+// ProviderHealth is the last known health of a registered provider.
+type ProviderHealth struct {
+	ProviderID string `json:"provider_id"`
+	// Healthy is false only when Status is unhealthy, degraded providers are
+	// still routed to.
+	Healthy             bool      `json:"healthy"`
+	Status              string    `json:"status"`
+	Error               string    `json:"error,omitempty"`
+	ConsecutiveFailures int       `json:"consecutive_failures,omitempty"`
+	LatencyMS           int64     `json:"latency_ms"`
+	CheckedAt           time.Time `json:"checked_at"`
+	// AuthFailed is set while requests are paused after repeated 401s.
+	AuthFailed bool `json:"auth_failed,omitempty"`
+}
+
+// healthCache holds provider health as an immutable map that is swapped on
+// every update, so the router can read it without taking a lock.
+type healthCache struct {
+	statuses atomic.Pointer[map[string]ProviderHealth]
+	mu       sync.Mutex // serializes writers
+}
+
+func newHealthCache() *healthCache {
+	h := &healthCache{}
+	h.statuses.Store(&map[string]ProviderHealth{})
+	return h
+}
+
+func (h *healthCache) get(providerID string) (ProviderHealth, bool) {
+	status, ok := (*h.statuses.Load())[providerID]
+	return status, ok
+}
+
+func (h *healthCache) set(status ProviderHealth) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	current := *h.statuses.Load()
+	next := make(map[string]ProviderHealth, len(current)+1)
+	for k, v := range current {
+		next[k] = v
+	}
+	next[status.ProviderID] = status
+
+	h.statuses.Store(&next)
+}
+
+func (h *healthCache) delete(providerID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	current := *h.statuses.Load()
+	next := make(map[string]ProviderHealth, len(current))
+	for k, v := range current {
+		if k != providerID {
+			next[k] = v
+		}
+	}
+
+	h.statuses.Store(&next)
+}
+
+func (h *healthCache) snapshot() []ProviderHealth {
+	current := *h.statuses.Load()
+
+	out := make([]ProviderHealth, 0, len(current))
+	for _, v := range current {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ProviderID < out[j].ProviderID })
+
+	return out
+}
+
+// HealthStatus returns the cached health of every checked provider, with
+// providers whose API key was rejected reported unhealthy.
+func (s *service) HealthStatus() []ProviderHealth {
+	statuses := s.health.snapshot()
+	for i, status := range statuses {
+		if state, ok := s.auth.get(status.ProviderID); ok && state.unhealthy {
+			statuses[i].Healthy = false
+			statuses[i].Status = HealthUnhealthy
+			statuses[i].AuthFailed = true
+			statuses[i].Error = state.lastError
+		}
+	}
+	return statuses
+}
+
+// CheckHealth runs a health check against every registered provider and
+// records the results in the cache used by routing.
+func (s *service) CheckHealth(ctx context.Context) {
+	s.mu.RLock()
+	providers := make([]llm.Provider, 0, len(s.providers))
+	for _, p := range s.providers {
+		providers = append(providers, p)
+	}
+	s.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, p := range providers {
+		wg.Add(1)
+		go func(p llm.Provider) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+
+			start := time.Now()
+			err := p.Health(checkCtx)
+			s.health.set(s.nextHealth(p.Name(), err, time.Since(start)))
+		}(p)
+	}
+	wg.Wait()
+}
+
+// nextHealth derives the status of a provider from the outcome of a check,
+// counting failures on from its previous status.
+func (s *service) nextHealth(providerID string, err error, latency time.Duration) ProviderHealth {
+	status := ProviderHealth{
+		ProviderID: providerID,
+		Healthy:    true,
+		Status:     HealthHealthy,
+		LatencyMS:  latency.Milliseconds(),
+		CheckedAt:  time.Now(),
+	}
+
+	if err == nil {
+		if limit := s.config.HealthDegradedLatency; limit > 0 && latency > limit {
+			status.Status = HealthDegraded
+			s.logger.Warn("Provider health check is slow", zap.String("provider", providerID), zap.Duration("latency", latency))
+		}
+		return status
+	}
+
+	previous, _ := s.health.get(providerID)
+	status.ConsecutiveFailures = previous.ConsecutiveFailures + 1
+	status.Error = err.Error()
+	status.Status = HealthDegraded
+	if status.ConsecutiveFailures >= max(s.config.HealthFailureThreshold, 1) {
+		status.Healthy = false
+		status.Status = HealthUnhealthy
+	}
+	s.logger.Warn("Provider health check failed",
+		zap.String("provider", providerID),
+		zap.String("status", status.Status),
+		zap.Int("consecutive_failures", status.ConsecutiveFailures),
+		zap.Error(err),
+	)
+	return status
+}
+
+// degraded reports whether providerID passed its last check only slowly or
+// has failed too few checks yet to be skipped.
+func (s *service) degraded(providerID string) bool {
+	status, ok := s.health.get(providerID)
+	return ok && status.Status == HealthDegraded
+}
+
+// modelAvailability reports whether any provider serving modelID is routed
+// to, and whether the best of them is only degraded. Callers hold
+// s.registry.mu.
+func (s *service) modelAvailability(modelID string) (available, degraded bool) {
+	targets, err := s.registry.resolveRoute(modelID)
+	if err != nil {
+		return false, false
+	}
+	for _, t := range targets {
+		if state, ok := s.auth.get(t.providerID); ok && state.unhealthy {
+			continue
+		}
+		status, ok := s.health.get(t.providerID)
+		switch {
+		case !ok, status.Status == HealthHealthy:
+			return true, false
+		case status.Healthy:
+			available, degraded = true, true
+		}
+	}
+	return available, degraded
+}
+
+// StartHealthChecks refreshes provider health every interval until ctx is done.
+// A non-positive interval disables the background checker.
+func (s *service) StartHealthChecks(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.CheckHealth(ctx)
+			}
+		}
+	}()
+}
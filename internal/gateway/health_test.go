@@ -0,0 +1,113 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func healthOf(t *testing.T, svc *service, providerID string) ProviderHealth {
+	t.Helper()
+	status, ok := svc.health.get(providerID)
+	require.True(t, ok, "expected %s to have been checked", providerID)
+	return status
+}
+
+func TestCheckHealth_DegradesBeforeUnhealthy(t *testing.T) {
+	provider := &mockProvider{id: "mock", models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock"}}}
+	svc, _ := newTestService(t, config.GatewayConfig{HealthFailureThreshold: 2}, provider)
+	provider.healthErr = errors.New("connection refused")
+
+	svc.CheckHealth(context.Background())
+	status := healthOf(t, svc, "mock")
+	assert.Equal(t, HealthDegraded, status.Status)
+	assert.True(t, status.Healthy, "degraded providers are still routed to")
+	assert.Equal(t, 1, status.ConsecutiveFailures)
+	assert.Equal(t, "connection refused", status.Error)
+
+	svc.CheckHealth(context.Background())
+	status = healthOf(t, svc, "mock")
+	assert.Equal(t, HealthUnhealthy, status.Status)
+	assert.False(t, status.Healthy)
+	assert.Equal(t, 2, status.ConsecutiveFailures)
+
+	_, _, err := svc.GetProviderForModel(context.Background(), "mock/model")
+	assert.Equal(t, http.StatusServiceUnavailable, errorStatus(err))
+
+	// a single passing check recovers the provider
+	provider.healthErr = nil
+	svc.CheckHealth(context.Background())
+	status = healthOf(t, svc, "mock")
+	assert.Equal(t, HealthHealthy, status.Status)
+	assert.Zero(t, status.ConsecutiveFailures)
+}
+
+func TestCheckHealth_SlowCheckDegrades(t *testing.T) {
+	provider := &mockProvider{id: "mock"}
+	svc, _ := newTestService(t, config.GatewayConfig{HealthDegradedLatency: time.Millisecond}, provider)
+
+	status := svc.nextHealth("mock", nil, 50*time.Millisecond)
+	assert.Equal(t, HealthDegraded, status.Status)
+	assert.True(t, status.Healthy)
+	assert.Equal(t, int64(50), status.LatencyMS)
+
+	status = svc.nextHealth("mock", nil, 0)
+	assert.Equal(t, HealthHealthy, status.Status)
+}
+
+func TestRouting_PrefersHealthyOverDegraded(t *testing.T) {
+	primary := &mockProvider{id: "primary"}
+	backup := &mockProvider{id: "backup"}
+	svc, _ := newTestService(t, config.GatewayConfig{
+		WeightedRoutes: []config.WeightedRoute{{
+			Model: "shared/model",
+			Targets: []config.RouteTarget{
+				{Provider: "primary", Weight: 9},
+				{Provider: "backup", Weight: 1},
+			},
+		}},
+	}, primary, backup)
+
+	svc.health.set(ProviderHealth{ProviderID: "primary", Healthy: true, Status: HealthDegraded})
+	for range 20 {
+		p, _, err := svc.GetProviderForModel(context.Background(), "shared/model")
+		require.NoError(t, err)
+		assert.Equal(t, "backup", p.Name())
+	}
+
+	// a degraded provider still serves when it is the only one left
+	svc.health.set(ProviderHealth{ProviderID: "backup", Healthy: false, Status: HealthUnhealthy})
+	p, _, err := svc.GetProviderForModel(context.Background(), "shared/model")
+	require.NoError(t, err)
+	assert.Equal(t, "primary", p.Name())
+}
+
+func TestListAllModels_Availability(t *testing.T) {
+	up := &mockProvider{id: "up", models: []api.ModelDefinition{{ID: "up/model", ProviderID: "up"}}}
+	slow := &mockProvider{id: "slow", models: []api.ModelDefinition{{ID: "slow/model", ProviderID: "slow"}}}
+	down := &mockProvider{id: "down", models: []api.ModelDefinition{{ID: "down/model", ProviderID: "down"}}}
+	svc, _ := newTestService(t, config.GatewayConfig{}, up, slow, down)
+	svc.health.set(ProviderHealth{ProviderID: "up", Healthy: true, Status: HealthHealthy})
+	svc.health.set(ProviderHealth{ProviderID: "slow", Healthy: true, Status: HealthDegraded})
+	svc.health.set(ProviderHealth{ProviderID: "down", Healthy: false, Status: HealthUnhealthy})
+
+	models, err := svc.ListAllModels(context.Background(), api.ModelFilter{})
+	require.NoError(t, err)
+	byID := make(map[string]api.Model)
+	for _, m := range models {
+		byID[m.ID] = m
+	}
+
+	assert.True(t, byID["up/model"].Available)
+	assert.False(t, byID["up/model"].Degraded)
+	assert.True(t, byID["slow/model"].Available)
+	assert.True(t, byID["slow/model"].Degraded)
+	assert.False(t, byID["down/model"].Available)
+}
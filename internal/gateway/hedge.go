@@ -0,0 +1,260 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nulzo/model-router-api/internal/flags"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+// hedgeMeta marks both attempts of a hedged request in their meta_json.
+type hedgeMeta struct {
+	Role    string `json:"role"` // "primary" or "hedge"
+	Won     bool   `json:"won"`
+	DelayMS int64  `json:"delay_ms"`
+}
+
+// hedgeOutcome is how both attempts of a hedged request ended. The attempt
+// that lost was cancelled, unless it failed before the other succeeded.
+type hedgeOutcome struct {
+	primary, hedge               route
+	hedgeWon                     bool
+	primaryErr, hedgeErr         error
+	primaryLatency, hedgeLatency time.Duration
+	hedgeStarted                 time.Time
+}
+
+// hedgeRoute returns the route a request hedged against primary is sent to:
+// the first usable provider other than primary's serving the requested
+// model, or else one of its fallbacks. Hedging is opt-in through the hedging
+// flag and needs hedge_delay set, requests that disallow fallbacks, or with
+// the fallback flag off, are never hedged.
+func (s *service) hedgeRoute(ctx context.Context, req *api.ChatRequest, primary route) (route, bool) {
+	if s.config.HedgeDelay <= 0 || !allowsFallbacks(req.Provider) || !s.featureEnabled(ctx, flags.Hedging, req.Model, false) {
+		return route{}, false
+	}
+	if !s.featureEnabled(ctx, flags.Fallback, req.Model, true) {
+		return route{}, false
+	}
+	check := s.parameterCheck(req)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	candidates, err := s.capableCandidates(req, allowedCandidates(ctx, s.routeCandidates(req)))
+	if err != nil {
+		return route{}, false
+	}
+	if candidates, err = s.fittingCandidates(req, candidates); err != nil {
+		return route{}, false
+	}
+	for _, modelID := range candidates {
+		targets, err := s.registry.ResolveRoute(modelID)
+		if err != nil {
+			continue
+		}
+		for _, t := range targets {
+			if t.providerID == primary.provider.Name() || s.checkProvider(t.providerID) != nil || s.checkAuth(t.providerID) != nil {
+				continue
+			}
+			if check != nil && check(t.providerID) != nil {
+				continue
+			}
+			return route{provider: s.providers[t.providerID], modelID: modelID, upstreamModelID: t.upstreamID}, true
+		}
+	}
+	return route{}, false
+}
+
+// hedgedChat sends upstreamReq to primary and, when it has not answered
+// within hedge_delay, the request to hedge as well. The first success is
+// returned and the other attempt cancelled. A failure only wins when both
+// attempts failed, the primary's error is returned then. The outcome is nil
+// when the primary answered before the hedge was due.
+func (s *service) hedgedChat(ctx context.Context, req *api.ChatRequest, primary route, upstreamReq *api.ChatRequest, hedge route) (*api.ChatResponse, *hedgeOutcome, error) {
+	type result struct {
+		resp    *api.ChatResponse
+		err     error
+		hedge   bool
+		latency time.Duration
+	}
+	results := make(chan result, 2)
+
+	// the loser is cancelled when we return
+	attemptCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	start := time.Now()
+	go func() {
+		resp, err := s.chatWithEmptyCheck(attemptCtx, primary.provider, upstreamReq)
+		results <- result{resp: resp, err: err, latency: time.Since(start)}
+	}()
+
+	timer := time.NewTimer(s.config.HedgeDelay)
+	defer timer.Stop()
+	select {
+	case r := <-results:
+		return r.resp, nil, r.err
+	case <-timer.C:
+	}
+
+	outcome := &hedgeOutcome{primary: primary, hedge: hedge, hedgeStarted: time.Now()}
+	go func() {
+		// the hedge takes a provider slot like any attempt
+		release, err := s.admit(attemptCtx, hedge.provider.Name())
+		if err != nil {
+			results <- result{err: err, hedge: true, latency: time.Since(outcome.hedgeStarted)}
+			return
+		}
+		defer release()
+		resp, err := s.chatWithEmptyCheck(attemptCtx, hedge.provider, s.upstreamRequest(req, hedge))
+		s.recordAuth(hedge.provider, err)
+		results <- result{resp: resp, err: err, hedge: true, latency: time.Since(outcome.hedgeStarted)}
+	}()
+
+	record := func(r result) {
+		if r.hedge {
+			outcome.hedgeErr, outcome.hedgeLatency = r.err, r.latency
+		} else {
+			outcome.primaryErr, outcome.primaryLatency = r.err, r.latency
+		}
+	}
+
+	winner := <-results
+	record(winner)
+	if winner.err != nil {
+		// the other attempt may still answer
+		other := <-results
+		record(other)
+		if other.err == nil {
+			outcome.hedgeWon = other.hedge
+			return other.resp, outcome, nil
+		}
+		return nil, outcome, outcome.primaryErr
+	}
+
+	outcome.hedgeWon = winner.hedge
+	if winner.hedge {
+		outcome.primaryErr, outcome.primaryLatency = context.Canceled, time.Since(start)
+	} else {
+		outcome.hedgeErr, outcome.hedgeLatency = context.Canceled, time.Since(outcome.hedgeStarted)
+	}
+	return winner.resp, outcome, nil
+}
+
+// attempts returns the routing audit trail with the hedge recorded after the
+// primary attempt, which routing saw as a single call, so the attempt that
+// served the request stays last.
+func (o *hedgeOutcome) attempts(attempts []model.RoutingAttempt) []model.RoutingAttempt {
+	i := 0
+	for i < len(attempts) && (attempts[i].ProviderID != o.primary.provider.Name() || attempts[i].ModelID != o.primary.modelID) {
+		i++
+	}
+	if i == len(attempts) {
+		return attempts
+	}
+
+	primary := &attempts[i]
+	primary.LatencyMS = o.primaryLatency.Milliseconds()
+	setAttemptError(primary, o.primaryErr)
+
+	hedge := model.RoutingAttempt{
+		ModelID:         o.hedge.modelID,
+		ProviderID:      o.hedge.provider.Name(),
+		UpstreamModelID: o.hedge.upstreamModelID,
+		LatencyMS:       o.hedgeLatency.Milliseconds(),
+		CreatedAt:       o.hedgeStarted,
+	}
+	setAttemptError(&hedge, o.hedgeErr)
+
+	at := i + 1 // after the primary, unless the primary won
+	if o.primaryErr == nil {
+		at = i
+	}
+	out := make([]model.RoutingAttempt, 0, len(attempts)+1)
+	out = append(out, attempts[:at]...)
+	out = append(out, hedge)
+	out = append(out, attempts[at:]...)
+	for n := range out {
+		out[n].Attempt = n + 1
+	}
+	return out
+}
+
+func setAttemptError(attempt *model.RoutingAttempt, err error) {
+	attempt.StatusCode, attempt.ErrorMessage = http.StatusOK, ""
+	if err == nil {
+		return
+	}
+	attempt.StatusCode = errorStatus(err)
+	if errors.Is(err, context.Canceled) {
+		attempt.StatusCode = 499
+	}
+	attempt.ErrorMessage = err.Error()
+}
+
+// logHedge marks log as one attempt of a hedged request and logs the other
+// attempt next to it, without usage since it was cut off or failed. When
+// both failed and a fallback served the request, both are logged next to it.
+func (s *service) logHedge(log *model.RequestLog, o *hedgeOutcome) {
+	delay := s.config.HedgeDelay.Milliseconds()
+	legs := []struct {
+		role    string
+		r       route
+		err     error
+		latency time.Duration
+	}{
+		{"primary", o.primary, o.primaryErr, o.primaryLatency},
+		{"hedge", o.hedge, o.hedgeErr, o.hedgeLatency},
+	}
+
+	for _, leg := range legs {
+		if leg.r.provider.Name() == log.ProviderID && leg.r.modelID == log.ModelID {
+			log.MetaJSON = withMeta(log.MetaJSON, "hedge", hedgeMeta{Role: leg.role, Won: leg.err == nil, DelayMS: delay})
+			continue
+		}
+
+		statusCode, finishReason := errorStatus(leg.err), api.FinishReasonError
+		if errors.Is(leg.err, context.Canceled) {
+			statusCode, finishReason = 499, api.FinishReasonClientDisconnect
+		}
+		other := &model.RequestLog{
+			ID:              uuid.NewString(),
+			UserID:          log.UserID,
+			APIKeyID:        log.APIKeyID,
+			AppName:         log.AppName,
+			MetaJSON:        withMeta(log.MetaJSON, "hedge", hedgeMeta{Role: leg.role, DelayMS: delay}),
+			ProviderID:      leg.r.provider.Name(),
+			ModelID:         leg.r.modelID,
+			UpstreamModelID: leg.r.upstreamModelID,
+			FinishReason:    string(finishReason),
+			StatusCode:      statusCode,
+			LatencyMS:       leg.latency.Milliseconds(),
+			CreatedAt:       time.Now(),
+		}
+		if leg.err != nil && statusCode != 499 {
+			other.ErrorMessage = leg.err.Error()
+		}
+		s.ingestor.Log(other)
+	}
+}
+
+// withMeta sets key in the meta_json object metaJSON.
+func withMeta(metaJSON, key string, value interface{}) string {
+	meta := make(map[string]interface{})
+	if metaJSON != "" {
+		_ = json.Unmarshal([]byte(metaJSON), &meta)
+	}
+	meta[key] = value
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return metaJSON
+	}
+	return string(data)
+}
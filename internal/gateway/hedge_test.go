@@ -0,0 +1,168 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/flags"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowProvider answers after delay, or with the context's error when the
+// request is cancelled first.
+type slowProvider struct {
+	*mockProvider
+	delay time.Duration
+}
+
+func (p *slowProvider) Chat(ctx context.Context, req *api.ChatRequest) (*api.ChatResponse, error) {
+	select {
+	case <-time.After(p.delay):
+		return p.mockProvider.Chat(ctx, req)
+	case <-ctx.Done():
+		p.record(req)
+		return nil, ctx.Err()
+	}
+}
+
+func hedgeMetaOf(t *testing.T, metaJSON string) hedgeMeta {
+	t.Helper()
+	var meta struct {
+		Hedge hedgeMeta `json:"hedge"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(metaJSON), &meta))
+	return meta.Hedge
+}
+
+func newHedgeService(t *testing.T, hedging bool, primaryDelay time.Duration) (*service, *captureIngestor, *mockProvider, *mockProvider) {
+	primary := &mockProvider{id: "primary", models: []api.ModelDefinition{{ID: "primary/model", ProviderID: "primary", UpstreamID: "model-a"}}}
+	backup := &mockProvider{
+		id:       "backup",
+		models:   []api.ModelDefinition{{ID: "backup/model", ProviderID: "backup", UpstreamID: "model-b"}},
+		chatResp: &api.ChatResponse{ID: "backup-id", Choices: []api.Choice{{Message: &api.ChatMessage{Role: "assistant", Content: api.Content{Text: "From backup"}}, FinishReason: "stop"}}},
+	}
+	svc, ingestor := newTestService(t, config.GatewayConfig{
+		RecordRouting: true,
+		HedgeDelay:    20 * time.Millisecond,
+		Fallbacks:     []config.FallbackConfig{{Model: "primary/model", Fallbacks: []string{"backup/model"}}},
+		Flags:         config.FlagsConfig{Defaults: map[string]bool{flags.Hedging: hedging}},
+	}, backup)
+	require.NoError(t, svc.RegisterProvider(context.Background(), &slowProvider{mockProvider: primary, delay: primaryDelay}))
+	return svc, ingestor, primary, backup
+}
+
+func hedgeRequest() *api.ChatRequest {
+	return &api.ChatRequest{Model: "primary/model", Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}}}
+}
+
+func TestChat_HedgeWinsOverSlowPrimary(t *testing.T) {
+	svc, ingestor, primary, backup := newHedgeService(t, true, time.Second)
+
+	start := time.Now()
+	resp, err := svc.Chat(context.Background(), hedgeRequest())
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "the slow primary is not waited for")
+	assert.Equal(t, "From backup", resp.Choices[0].Message.Content.Text)
+	assert.Equal(t, "model-b", backup.lastRequest().Model)
+
+	require.Eventually(t, func() bool {
+		primary.mu.Lock()
+		defer primary.mu.Unlock()
+		return len(primary.requests) == 1
+	}, time.Second, 5*time.Millisecond, "the losing attempt is cancelled")
+
+	ingestor.mu.Lock()
+	logs := ingestor.logs
+	ingestor.mu.Unlock()
+	require.Len(t, logs, 2, "both attempts are logged")
+
+	loser, winner := logs[0], logs[1]
+	assert.Equal(t, "primary", loser.ProviderID)
+	assert.Equal(t, 499, loser.StatusCode)
+	assert.Equal(t, hedgeMeta{Role: "primary", DelayMS: 20}, hedgeMetaOf(t, loser.MetaJSON))
+
+	assert.Equal(t, "backup", winner.ProviderID)
+	assert.Equal(t, "backup/model", winner.ModelID)
+	assert.Equal(t, 200, winner.StatusCode)
+	assert.Equal(t, hedgeMeta{Role: "hedge", Won: true, DelayMS: 20}, hedgeMetaOf(t, winner.MetaJSON))
+
+	require.Len(t, winner.Routing, 2)
+	assert.Equal(t, 499, winner.Routing[0].StatusCode)
+	assert.Equal(t, "backup", winner.Routing[1].ProviderID, "the serving attempt stays last")
+	assert.Equal(t, 200, winner.Routing[1].StatusCode)
+}
+
+func TestChat_HedgeRespectsKeyModelPolicy(t *testing.T) {
+	svc, _, primary, backup := newHedgeService(t, true, 100*time.Millisecond)
+
+	_, err := svc.Chat(policyContext(`["primary/*"]`, ""), hedgeRequest())
+	require.NoError(t, err)
+	assert.Len(t, primary.requests, 1)
+	assert.Empty(t, backup.requests, "the key may not call the hedge's model")
+}
+
+func TestChat_NoHedgeWhenPrimaryIsFast(t *testing.T) {
+	svc, ingestor, primary, backup := newHedgeService(t, true, 0)
+
+	resp, err := svc.Chat(context.Background(), hedgeRequest())
+	require.NoError(t, err)
+	assert.Equal(t, "Mock Response", resp.Choices[0].Message.Content.Text)
+	assert.Len(t, primary.requests, 1)
+	assert.Empty(t, backup.requests)
+	assert.Empty(t, ingestor.last(t).MetaJSON)
+}
+
+func TestChat_NoHedgeWithFallbackFlagOff(t *testing.T) {
+	primary := &mockProvider{id: "primary", models: []api.ModelDefinition{{ID: "primary/model", ProviderID: "primary"}}}
+	backup := &mockProvider{id: "backup", models: []api.ModelDefinition{{ID: "backup/model", ProviderID: "backup"}}}
+	svc, _ := newTestService(t, config.GatewayConfig{
+		HedgeDelay: 20 * time.Millisecond,
+		Fallbacks:  []config.FallbackConfig{{Model: "primary/model", Fallbacks: []string{"backup/model"}}},
+		Flags:      config.FlagsConfig{Defaults: map[string]bool{flags.Hedging: true, flags.Fallback: false}},
+	}, backup)
+	require.NoError(t, svc.RegisterProvider(context.Background(), &slowProvider{mockProvider: primary, delay: 60 * time.Millisecond}))
+
+	_, err := svc.Chat(context.Background(), hedgeRequest())
+	require.NoError(t, err)
+	assert.Len(t, primary.requests, 1)
+	assert.Empty(t, backup.requests)
+}
+
+func TestChat_FallbacksAreNotHedged(t *testing.T) {
+	primary := &mockProvider{
+		id:      "primary",
+		models:  []api.ModelDefinition{{ID: "primary/model", ProviderID: "primary"}},
+		chatErr: api.NewError(http.StatusServiceUnavailable, "Upstream Provider Error", "overloaded"),
+	}
+	backup := &mockProvider{id: "backup", models: []api.ModelDefinition{{ID: "backup/model", ProviderID: "backup"}}}
+	spare := &mockProvider{id: "spare", models: []api.ModelDefinition{{ID: "spare/model", ProviderID: "spare"}}}
+	svc, _ := newTestService(t, config.GatewayConfig{
+		HedgeDelay: 20 * time.Millisecond,
+		Fallbacks:  []config.FallbackConfig{{Model: "primary/model", Fallbacks: []string{"backup/model", "spare/model"}}},
+		Flags:      config.FlagsConfig{Defaults: map[string]bool{flags.Hedging: true}},
+	}, primary, spare)
+	require.NoError(t, svc.RegisterProvider(context.Background(), &slowProvider{mockProvider: backup, delay: 60 * time.Millisecond}))
+
+	// the primary fails before the hedge is due, the fallback it moves on
+	// to is not hedged in turn
+	_, err := svc.Chat(context.Background(), hedgeRequest())
+	require.NoError(t, err)
+	assert.Len(t, primary.requests, 1)
+	assert.Len(t, backup.requests, 1)
+	assert.Empty(t, spare.requests)
+}
+
+func TestChat_HedgingIsOptIn(t *testing.T) {
+	svc, _, primary, backup := newHedgeService(t, false, 60*time.Millisecond)
+
+	_, err := svc.Chat(context.Background(), hedgeRequest())
+	require.NoError(t, err)
+	assert.Len(t, primary.requests, 1)
+	assert.Empty(t, backup.requests)
+}
@@ -0,0 +1,74 @@
+package gateway
+
+import "github.com/nulzo/model-router-api/pkg/api"
+
+// imageIndexes counts the images streamed so far per choice index.
+type imageIndexes map[int]int
+
+// chunkImages splits the images of a stream chunk whose URL is longer than
+// size bytes into fragments, one per delta. Images are indexed in the order
+// they appear across the whole stream, indexes carrying the count over from
+// earlier chunks: the first fragment replaces the image in resp, the
+// following ones come in extra chunks and append to the URL of the image with
+// the same index. The finish reason and usage move to the last chunk.
+func chunkImages(resp *api.ChatResponse, size int, indexes imageIndexes) []*api.ChatResponse {
+	out := []*api.ChatResponse{resp}
+	if size <= 0 {
+		return out
+	}
+
+	for i := range resp.Choices {
+		choice := &resp.Choices[i]
+		if choice.Delta == nil || len(choice.Delta.Images) == 0 {
+			continue
+		}
+
+		images := make([]api.ContentPart, len(choice.Delta.Images))
+		var rest []api.ContentPart // remaining fragments, in order
+		for j, img := range choice.Delta.Images {
+			index := indexes[choice.Index]
+			indexes[choice.Index]++
+			img.Index = &index
+			if img.ImageURL != nil && len(img.ImageURL.URL) > size {
+				url := img.ImageURL.URL
+				img.ImageURL = &api.ImageURL{URL: url[:size], Detail: img.ImageURL.Detail}
+				for off := size; off < len(url); off += size {
+					end := min(off+size, len(url))
+					rest = append(rest, api.ContentPart{
+						Type:     img.Type,
+						ImageURL: &api.ImageURL{URL: url[off:end]},
+						Index:    &index,
+					})
+				}
+			}
+			images[j] = img
+		}
+		delta := *choice.Delta
+		delta.Images = images
+		choice.Delta = &delta
+		if len(rest) == 0 {
+			continue
+		}
+
+		finishReason := choice.FinishReason
+		choice.FinishReason = ""
+		for _, fragment := range rest {
+			out = append(out, &api.ChatResponse{
+				ID:      resp.ID,
+				Object:  resp.Object,
+				Created: resp.Created,
+				Model:   resp.Model,
+				Choices: []api.Choice{{
+					Index: choice.Index,
+					Delta: &api.ChatMessage{Role: delta.Role, Images: []api.ContentPart{fragment}},
+				}},
+			})
+		}
+		out[len(out)-1].Choices[0].FinishReason = finishReason
+	}
+
+	if last := out[len(out)-1]; last != resp && resp.Usage != nil {
+		last.Usage, resp.Usage = resp.Usage, nil
+	}
+	return out
+}
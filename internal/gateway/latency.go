@@ -0,0 +1,106 @@
+package gateway
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// RoutingLatency sends a model served by several providers to the one with
+// the lowest recent p95 latency, or time to first token for streams.
+const RoutingLatency = "latency"
+
+const (
+	// defaultLatencyWindow is how long latency samples count, unless
+	// latency_window says otherwise.
+	defaultLatencyWindow = 5 * time.Minute
+	// maxLatencySamples caps the samples kept per provider and model.
+	maxLatencySamples = 200
+)
+
+// latencyKey identifies the samples of a model as served by one provider.
+type latencyKey struct {
+	providerID string
+	modelID    string
+}
+
+// latencySample is one successful request. ttft is zero for requests that
+// were not streamed.
+type latencySample struct {
+	at      time.Time
+	latency time.Duration
+	ttft    time.Duration
+}
+
+// latencyTracker keeps the recent latencies of every provider and model.
+// Samples older than the window are ignored, so a provider that was slow
+// once is tried again after a while.
+type latencyTracker struct {
+	window  time.Duration
+	mu      sync.Mutex
+	samples map[latencyKey][]latencySample
+}
+
+func newLatencyTracker(window time.Duration) *latencyTracker {
+	if window <= 0 {
+		window = defaultLatencyWindow
+	}
+	return &latencyTracker{window: window, samples: make(map[latencyKey][]latencySample)}
+}
+
+// record adds a successful request of modelID served by providerID.
+func (t *latencyTracker) record(providerID, modelID string, latency, ttft time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := latencyKey{providerID, modelID}
+	samples := append(t.samples[key], latencySample{at: time.Now(), latency: latency, ttft: ttft})
+	if len(samples) > maxLatencySamples {
+		samples = slices.Clone(samples[len(samples)-maxLatencySamples:])
+	}
+	t.samples[key] = samples
+}
+
+// p95 returns the 95th percentile time to first token of streamed requests,
+// or latency of the others, within the window. It reports false when there
+// are no such samples.
+func (t *latencyTracker) p95(providerID, modelID string, stream bool) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cutoff := time.Now().Add(-t.window)
+	var values []time.Duration
+	for _, s := range t.samples[latencyKey{providerID, modelID}] {
+		if s.at.Before(cutoff) {
+			continue
+		}
+		switch {
+		case stream && s.ttft > 0:
+			values = append(values, s.ttft)
+		case !stream && s.ttft == 0:
+			values = append(values, s.latency)
+		}
+	}
+	if len(values) == 0 {
+		return 0, false
+	}
+
+	slices.Sort(values)
+	return values[(len(values)*95+99)/100-1], true
+}
+
+// fastest returns the target of modelID with the lowest p95. Targets
+// without recent samples come first, in order, so they get measured.
+func (t *latencyTracker) fastest(modelID string, targets []routeTarget, stream bool) routeTarget {
+	best, bestP95 := -1, time.Duration(0)
+	for i, target := range targets {
+		p95, ok := t.p95(target.providerID, modelID, stream)
+		if !ok {
+			return target
+		}
+		if best < 0 || p95 < bestP95 {
+			best, bestP95 = i, p95
+		}
+	}
+	return targets[best]
+}
@@ -0,0 +1,79 @@
+package gateway
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencyTracker_P95(t *testing.T) {
+	tracker := newLatencyTracker(time.Minute)
+	for i := 1; i <= 100; i++ {
+		tracker.record("openai", "openai/gpt-4o", time.Duration(i)*time.Millisecond, 0)
+	}
+	tracker.record("openai", "openai/gpt-4o", 3*time.Second, 40*time.Millisecond)
+
+	p95, ok := tracker.p95("openai", "openai/gpt-4o", false)
+	require.True(t, ok)
+	assert.Equal(t, 95*time.Millisecond, p95)
+
+	ttft, ok := tracker.p95("openai", "openai/gpt-4o", true)
+	require.True(t, ok)
+	assert.Equal(t, 40*time.Millisecond, ttft, "streams are ranked by time to first token")
+
+	_, ok = tracker.p95("azure", "openai/gpt-4o", false)
+	assert.False(t, ok)
+
+	// samples outside the window no longer count
+	tracker.window = 0
+	_, ok = tracker.p95("openai", "openai/gpt-4o", false)
+	assert.False(t, ok)
+}
+
+func TestChat_LatencyRoutingPrefersFastestProvider(t *testing.T) {
+	primary := &mockProvider{id: "openai-primary", models: []api.ModelDefinition{{ID: "openai/gpt-4o", ProviderID: "openai-primary", UpstreamID: "gpt-4o"}}}
+	backup := &mockProvider{id: "azure-backup"}
+	svc, _ := newTestService(t, config.GatewayConfig{
+		RoutingStrategy: RoutingLatency,
+		WeightedRoutes: []config.WeightedRoute{{
+			Model: "openai/gpt-4o",
+			Targets: []config.RouteTarget{
+				{Provider: "openai-primary", Weight: 1},
+				{Provider: "azure-backup", Weight: 1},
+			},
+		}},
+	}, primary, backup)
+
+	req := func(stream bool) *api.ChatRequest {
+		return &api.ChatRequest{Model: "openai/gpt-4o", Stream: stream, Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}}}
+	}
+
+	// unmeasured providers are tried first, in order
+	_, err := svc.Chat(context.Background(), req(false))
+	require.NoError(t, err)
+	assert.Len(t, primary.requests, 1)
+	_, err = svc.Chat(context.Background(), req(false))
+	require.NoError(t, err)
+	assert.Len(t, backup.requests, 1)
+
+	for range 20 {
+		svc.latency.record("openai-primary", "openai/gpt-4o", 900*time.Millisecond, 0)
+		svc.latency.record("azure-backup", "openai/gpt-4o", 300*time.Millisecond, 0)
+		svc.latency.record("openai-primary", "openai/gpt-4o", 2*time.Second, 100*time.Millisecond)
+		svc.latency.record("azure-backup", "openai/gpt-4o", time.Second, 400*time.Millisecond)
+	}
+
+	_, err = svc.Chat(context.Background(), req(false))
+	require.NoError(t, err)
+	assert.Len(t, backup.requests, 2, "the lowest p95 latency wins")
+
+	ch, err := svc.StreamChat(context.Background(), req(true))
+	require.NoError(t, err)
+	drain(t, ch)
+	assert.Len(t, primary.requests, 2, "streams go to the lowest p95 time to first token")
+}
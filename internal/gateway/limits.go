@@ -0,0 +1,107 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+// checkContentLimits enforces the configured per-part and per-message
+// character caps and the tool limits. Plain string content counts as a single
+// part at index 0.
+func (s *service) checkContentLimits(req *api.ChatRequest) error {
+	if err := s.checkTools(req); err != nil {
+		return err
+	}
+
+	maxPart, maxMessage := s.config.MaxPartChars, s.config.MaxMessageChars
+	if maxPart <= 0 && maxMessage <= 0 {
+		return nil
+	}
+
+	for i, m := range req.Messages {
+		parts := m.Content.Parts
+		if len(parts) == 0 {
+			parts = []api.ContentPart{{Type: "text", Text: m.Content.Text}}
+		}
+
+		total := 0
+		for j, part := range parts {
+			n := utf8.RuneCountInString(part.Text)
+			if maxPart > 0 && n > maxPart {
+				return api.BadRequestError(
+					fmt.Sprintf("messages[%d].content[%d] is %d characters, exceeding the per-part limit of %d", i, j, n, maxPart),
+					api.WithExtension("message_index", i),
+					api.WithExtension("part_index", j),
+					api.WithExtension("limit", maxPart),
+				)
+			}
+			total += n
+		}
+
+		if maxMessage > 0 && total > maxMessage {
+			return api.BadRequestError(
+				fmt.Sprintf("messages[%d] is %d characters, exceeding the per-message limit of %d", i, total, maxMessage),
+				api.WithExtension("message_index", i),
+				api.WithExtension("limit", maxMessage),
+			)
+		}
+	}
+
+	return nil
+}
+
+// checkTools enforces the configured tool count and total schema size caps,
+// and rejects tools that are not functions, lack a name, or whose parameters
+// declare a root type other than object.
+func (s *service) checkTools(req *api.ChatRequest) error {
+	if len(req.Tools) == 0 {
+		return nil
+	}
+
+	if maxTools := s.config.MaxTools; maxTools > 0 && len(req.Tools) > maxTools {
+		return api.BadRequestError(
+			fmt.Sprintf("request has %d tools, exceeding the limit of %d", len(req.Tools), maxTools),
+			api.WithExtension("limit", maxTools),
+		)
+	}
+
+	size := 0
+	for i, tool := range req.Tools {
+		if err := validateToolSchema(tool); err != nil {
+			return api.BadRequestError(
+				fmt.Sprintf("tools[%d]: %s", i, err),
+				api.WithExtension("tool_index", i),
+			)
+		}
+		b, _ := json.Marshal(tool.Function)
+		size += len(b)
+	}
+
+	if maxBytes := s.config.MaxToolSchemaBytes; maxBytes > 0 && size > maxBytes {
+		return api.BadRequestError(
+			fmt.Sprintf("tool definitions are %d bytes, exceeding the limit of %d", size, maxBytes),
+			api.WithExtension("limit", maxBytes),
+		)
+	}
+
+	return nil
+}
+
+// validateToolSchema checks the shape of a function tool. Parameters that
+// declare a root type must declare an object; schemas without one ($ref,
+// anyOf, bare properties) are left to the provider to validate.
+func validateToolSchema(tool api.Tool) error {
+	if tool.Type != "" && tool.Type != "function" {
+		return fmt.Errorf("unsupported tool type %q", tool.Type)
+	}
+	if tool.Function.Name == "" {
+		return fmt.Errorf("function name is required")
+	}
+	if typ, ok := tool.Function.Parameters["type"]; ok && typ != "object" {
+		return fmt.Errorf("parameters must be a JSON schema object, got type %v", typ)
+	}
+	return nil
+}
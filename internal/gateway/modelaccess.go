@@ -0,0 +1,70 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+// keyAllowsModel reports whether the API key found in the context may call
+// modelID under its model policy. Denied globs win over allowed ones, and
+// requests without a key are not restricted.
+func keyAllowsModel(ctx context.Context, modelID string) (bool, error) {
+	apiKey, ok := ctx.Value(store.ContextKeyAPIKey).(*model.APIKey)
+	if !ok {
+		return true, nil
+	}
+
+	allowed, denied, err := apiKey.ModelPolicy()
+	if err != nil {
+		return false, api.InternalError("Invalid API key model policy", fmt.Sprintf("model policy for key '%s' could not be decoded", apiKey.ID), api.WithLog(err))
+	}
+	for _, glob := range denied {
+		if globRegexp(glob).MatchString(modelID) {
+			return false, nil
+		}
+	}
+	if len(allowed) == 0 {
+		return true, nil
+	}
+	for _, glob := range allowed {
+		if globRegexp(glob).MatchString(modelID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// checkModelAccess returns a 403 when the API key found in the context may not
+// call modelID.
+func checkModelAccess(ctx context.Context, modelID string) error {
+	ok, err := keyAllowsModel(ctx, modelID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return api.NewError(http.StatusForbidden, "Model Not Allowed",
+			fmt.Sprintf("this API key may not call model '%s'", modelID),
+			api.WithExtension("model", modelID),
+		)
+	}
+	return nil
+}
+
+// allowedCandidates drops the fallbacks the API key may not call from the
+// models a request may be routed to. The requested model, first, has already
+// passed checkModelAccess.
+func allowedCandidates(ctx context.Context, candidates []string) []string {
+	allowed := make([]string, 1, len(candidates))
+	allowed[0] = candidates[0]
+	for _, modelID := range candidates[1:] {
+		if ok, _ := keyAllowsModel(ctx, modelID); ok {
+			allowed = append(allowed, modelID)
+		}
+	}
+	return allowed
+}
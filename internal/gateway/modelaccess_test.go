@@ -0,0 +1,95 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func policyContext(allowed, denied string) context.Context {
+	return context.WithValue(context.Background(), store.ContextKeyAPIKey, &model.APIKey{
+		ID:            "key-1",
+		UserID:        "user-1",
+		AllowedModels: allowed,
+		DeniedModels:  denied,
+	})
+}
+
+func TestKeyAllowsModel(t *testing.T) {
+	tests := []struct {
+		allowed, denied string
+		modelID         string
+		want            bool
+	}{
+		{"", "", "openai/gpt-4o", true},
+		{"[]", "[]", "openai/gpt-4o", true},
+		{`["ollama/*", "openai/gpt-4o-mini"]`, "[]", "ollama/llama3", true},
+		{`["ollama/*", "openai/gpt-4o-mini"]`, "[]", "openai/gpt-4o-mini", true},
+		{`["ollama/*", "openai/gpt-4o-mini"]`, "[]", "openai/gpt-4o", false},
+		{"[]", `["openai/*"]`, "openai/gpt-4o", false},
+		{"[]", `["openai/*"]`, "anthropic/claude-3-haiku", true},
+		{`["openai/*"]`, `["openai/o1*"]`, "openai/o1-preview", false},
+	}
+	for _, tt := range tests {
+		ok, err := keyAllowsModel(policyContext(tt.allowed, tt.denied), tt.modelID)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, ok, "allowed %s denied %s model %s", tt.allowed, tt.denied, tt.modelID)
+	}
+
+	// requests without a key are not restricted
+	ok, err := keyAllowsModel(context.Background(), "openai/gpt-4o")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// a policy that can not be read fails closed
+	_, err = keyAllowsModel(policyContext("not json", ""), "openai/gpt-4o")
+	assert.Equal(t, http.StatusInternalServerError, errorStatus(err))
+}
+
+func TestChat_KeyModelPolicy(t *testing.T) {
+	local := &mockProvider{id: "ollama", models: []api.ModelDefinition{{ID: "ollama/llama3", ProviderID: "ollama"}}}
+	paid := &mockProvider{id: "openai", models: []api.ModelDefinition{{ID: "openai/gpt-4o", ProviderID: "openai"}}}
+	svc, _ := newTestService(t, config.GatewayConfig{
+		Fallbacks: []config.FallbackConfig{{Model: "ollama/llama3", Fallbacks: []string{"openai/gpt-4o"}}},
+	}, local, paid)
+	ctx := policyContext(`["ollama/*"]`, "")
+
+	_, err := svc.Chat(ctx, &api.ChatRequest{
+		Model:    "openai/gpt-4o",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	assert.Equal(t, http.StatusForbidden, errorStatus(err))
+	assert.Empty(t, paid.requests)
+
+	_, err = svc.StreamChat(ctx, &api.ChatRequest{
+		Model:    "openai/gpt-4o",
+		Stream:   true,
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	assert.Equal(t, http.StatusForbidden, errorStatus(err))
+
+	// fallbacks the key may not call are skipped
+	local.chatErr = api.NewError(http.StatusServiceUnavailable, "Upstream Provider Error", "overloaded")
+	_, err = svc.Chat(ctx, &api.ChatRequest{
+		Model:    "ollama/llama3",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	assert.Equal(t, http.StatusServiceUnavailable, errorStatus(err))
+	assert.Len(t, local.requests, 1)
+	assert.Empty(t, paid.requests)
+
+	// unrestricted keys fall back as usual
+	_, err = svc.Chat(policyContext("", ""), &api.ChatRequest{
+		Model:    "ollama/llama3",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+	assert.Len(t, paid.requests, 1)
+}
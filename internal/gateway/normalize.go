@@ -0,0 +1,152 @@
+package gateway
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+// chunkObject is the OpenAI object type of a stream chunk.
+const chunkObject = "chat.completion.chunk"
+
+// normalizeResponse fills in fields some providers leave out so strict
+// clients always see the OpenAI shape: every message and delta carries the
+// assistant role and every choice its index. Works for full responses and
+// stream chunks alike.
+func normalizeResponse(resp *api.ChatResponse) {
+	if resp == nil {
+		return
+	}
+
+	for i := range resp.Choices {
+		choice := &resp.Choices[i]
+		// a zero index past the first position means the provider left it out
+		if choice.Index == 0 && i > 0 {
+			choice.Index = i
+		}
+		if choice.Message != nil && choice.Message.Role == "" {
+			choice.Message.Role = "assistant"
+		}
+		if choice.Delta != nil && choice.Delta.Role == "" {
+			choice.Delta.Role = "assistant"
+		}
+	}
+}
+
+// chunkDefaults are the stream level values copied onto chunks that leave
+// them blank. ID starts empty and is taken from the first chunk that has one,
+// so every chunk of a stream shares the same ID.
+type chunkDefaults struct {
+	ID      string
+	Object  string
+	Created int64
+	Model   string
+}
+
+// fillChunkDefaults sets the object, id, created and model fields of a
+// stream chunk when the adapter left them empty.
+func fillChunkDefaults(resp *api.ChatResponse, d *chunkDefaults) {
+	if resp == nil {
+		return
+	}
+
+	if resp.ID == "" {
+		if d.ID == "" {
+			d.ID = uuid.NewString()
+		}
+		resp.ID = d.ID
+	} else if d.ID == "" {
+		d.ID = resp.ID
+	}
+	if resp.Object == "" {
+		resp.Object = d.Object
+		if resp.Object == "" {
+			resp.Object = chunkObject
+		}
+	}
+	if resp.Created == 0 {
+		resp.Created = d.Created
+	}
+	if resp.Model == "" {
+		resp.Model = d.Model
+	}
+}
+
+// includeReasoning reports whether reasoning is returned to the client for
+// req, the request flag taking precedence over the gateway default.
+func (s *service) includeReasoning(req *api.ChatRequest) bool {
+	if req.IncludeReasoning != nil {
+		return *req.IncludeReasoning
+	}
+	return !s.config.ExcludeReasoning
+}
+
+// stripReasoning removes the reasoning from every message and delta of resp.
+func stripReasoning(resp *api.ChatResponse) {
+	for i := range resp.Choices {
+		if m := resp.Choices[i].Message; m != nil {
+			m.Reasoning = ""
+		}
+		if d := resp.Choices[i].Delta; d != nil {
+			d.Reasoning = ""
+		}
+	}
+}
+
+// progressOnly reports whether resp only carries generation progress, which
+// is not output and so does not count towards the time to first token.
+func progressOnly(resp *api.ChatResponse) bool {
+	if len(resp.Choices) == 0 {
+		return false
+	}
+	for _, choice := range resp.Choices {
+		if choice.Delta == nil || choice.Delta.Progress == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// sanitizeUTF8 replaces invalid UTF-8 in the text of resp with U+FFFD so it
+// encodes cleanly, and reports whether anything had to be replaced.
+func sanitizeUTF8(resp *api.ChatResponse) bool {
+	if resp == nil {
+		return false
+	}
+
+	replaced := false
+	fix := func(s *string) {
+		if !utf8.ValidString(*s) {
+			*s = strings.ToValidUTF8(*s, string(utf8.RuneError))
+			replaced = true
+		}
+	}
+
+	for i := range resp.Choices {
+		for _, m := range []*api.ChatMessage{resp.Choices[i].Message, resp.Choices[i].Delta} {
+			if m == nil {
+				continue
+			}
+			fix(&m.Content.Text)
+			fix(&m.Reasoning)
+			for j := range m.Content.Parts {
+				fix(&m.Content.Parts[j].Text)
+			}
+			for j := range m.ToolCalls {
+				fix(&m.ToolCalls[j].Function.Arguments)
+			}
+		}
+	}
+	return replaced
+}
+
+// sanitizeRawUTF8 is sanitizeUTF8 for passthrough chunks.
+func sanitizeRawUTF8(raw []byte) ([]byte, bool) {
+	if utf8.Valid(raw) {
+		return raw, false
+	}
+	return bytes.ToValidUTF8(raw, []byte(string(utf8.RuneError))), true
+}
@@ -0,0 +1,75 @@
+package gateway
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/nulzo/model-router-api/internal/config"
+)
+
+// patternRoute force-routes the models whose ID matches a pattern to one
+// provider.
+type patternRoute struct {
+	pattern    string
+	providerID string
+	match      func(modelID string) bool
+	// sourcePrefix is the provider segment the pattern spells out, e.g.
+	// "anthropic/" of "anthropic/*", empty when it has none
+	sourcePrefix string
+}
+
+// globRegexp compiles a model ID glob: anchored, with * spanning slashes and
+// ? matching any one character.
+func globRegexp(pattern string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(pattern)
+	quoted = strings.ReplaceAll(quoted, `\*`, ".*")
+	quoted = strings.ReplaceAll(quoted, `\?`, ".")
+	return regexp.MustCompile("^" + quoted + "$")
+}
+
+// newPatternRoute compiles a configured route. Globs are anchored and their
+// * spans slashes, so "*-preview" matches "google/gemini-2.5-pro-preview".
+func newPatternRoute(r config.RouteConfig) (patternRoute, error) {
+	route := patternRoute{pattern: r.Pattern, providerID: r.TargetID}
+
+	switch r.Match {
+	case "prefix":
+		route.match = func(modelID string) bool { return strings.HasPrefix(modelID, r.Pattern) }
+		route.sourcePrefix = providerSegment(r.Pattern)
+		return route, nil
+	case "regex":
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return patternRoute{}, fmt.Errorf("invalid route regex %q: %w", r.Pattern, err)
+		}
+		route.match = re.MatchString
+		return route, nil
+	case "", "glob":
+		route.match = globRegexp(r.Pattern).MatchString
+		literal, _, _ := strings.Cut(strings.ReplaceAll(r.Pattern, "?", "*"), "*")
+		route.sourcePrefix = providerSegment(literal)
+		return route, nil
+	default:
+		return patternRoute{}, fmt.Errorf("unknown route match %q", r.Match)
+	}
+}
+
+// providerSegment returns the leading "provider/" of a literal model ID
+// prefix, empty when the prefix holds no slash.
+func providerSegment(literal string) string {
+	if i := strings.Index(literal, "/"); i >= 0 {
+		return literal[:i+1]
+	}
+	return ""
+}
+
+// upstreamID is the ID an unregistered model matching the route is sent
+// under: the model ID without the provider segment it was matched on, or else
+// without the target's own prefix.
+func (p patternRoute) upstreamID(modelID string) string {
+	if p.sourcePrefix != "" {
+		return strings.TrimPrefix(modelID, p.sourcePrefix)
+	}
+	return strings.TrimPrefix(modelID, p.providerID+"/")
+}
@@ -0,0 +1,82 @@
+package gateway
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatternRoute_Match(t *testing.T) {
+	tests := []struct {
+		route   config.RouteConfig
+		modelID string
+		want    bool
+	}{
+		{config.RouteConfig{Pattern: "anthropic/*"}, "anthropic/claude-3-haiku", true},
+		{config.RouteConfig{Pattern: "anthropic/*"}, "openai/gpt-4o", false},
+		{config.RouteConfig{Pattern: "*-preview"}, "google/gemini-2.5-pro-preview", true},
+		{config.RouteConfig{Pattern: "*-preview"}, "google/gemini-2.5-pro-preview-2", false},
+		{config.RouteConfig{Pattern: "gpt-4?"}, "gpt-4o", true},
+		{config.RouteConfig{Pattern: "openai/gpt-4o"}, "openai/gpt-4o-mini", false},
+		{config.RouteConfig{Pattern: "openai/gpt-4", Match: "prefix"}, "openai/gpt-4o-mini", true},
+		{config.RouteConfig{Pattern: `^openai/o[0-9]`, Match: "regex"}, "openai/o3-mini", true},
+		{config.RouteConfig{Pattern: `^openai/o[0-9]`, Match: "regex"}, "openai/gpt-4o", false},
+	}
+	for _, tt := range tests {
+		route, err := newPatternRoute(tt.route)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, route.match(tt.modelID), "%s %q against %q", tt.route.Match, tt.route.Pattern, tt.modelID)
+	}
+
+	_, err := newPatternRoute(config.RouteConfig{Pattern: "(", Match: "regex"})
+	assert.Error(t, err)
+}
+
+func TestGetProviderForModel_PatternRoutes(t *testing.T) {
+	anthropic := &mockProvider{id: "anthropic", models: []api.ModelDefinition{{ID: "anthropic/claude-3-haiku", ProviderID: "anthropic", UpstreamID: "claude-3-haiku"}}}
+	backup := &mockProvider{id: "anthropic-backup"}
+	google := &mockProvider{id: "google", models: []api.ModelDefinition{{ID: "google/gemini-2.5-pro", ProviderID: "google", UpstreamID: "gemini-2.5-pro"}}}
+	svc, _ := newTestService(t, config.GatewayConfig{
+		Routes: []config.RouteConfig{
+			{Pattern: "anthropic/*", TargetID: "anthropic-backup"},
+			{Pattern: "*-preview", TargetID: "google"},
+			{Pattern: "(", TargetID: "google", Match: "regex"}, // ignored
+		},
+		WeightedRoutes: []config.WeightedRoute{{
+			Model:   "anthropic/claude-3-opus",
+			Targets: []config.RouteTarget{{Provider: "anthropic", Weight: 1}},
+		}},
+	}, anthropic, backup, google)
+
+	// a registered model is taken from the provider of its definition
+	p, upstreamID, err := svc.GetProviderForModel(context.Background(), "anthropic/claude-3-haiku")
+	require.NoError(t, err)
+	assert.Equal(t, "anthropic-backup", p.Name())
+	assert.Equal(t, "claude-3-haiku", upstreamID)
+
+	// an unregistered model is sent as requested
+	p, upstreamID, err = svc.GetProviderForModel(context.Background(), "google/gemini-2.5-pro-preview")
+	require.NoError(t, err)
+	assert.Equal(t, "google", p.Name())
+	assert.Equal(t, "gemini-2.5-pro-preview", upstreamID)
+
+	// the provider segment the pattern matched is not sent to the target
+	p, upstreamID, err = svc.GetProviderForModel(context.Background(), "anthropic/claude-x")
+	require.NoError(t, err)
+	assert.Equal(t, "anthropic-backup", p.Name())
+	assert.Equal(t, "claude-x", upstreamID)
+
+	// exact weighted routes win over patterns
+	p, _, err = svc.GetProviderForModel(context.Background(), "anthropic/claude-3-opus")
+	require.NoError(t, err)
+	assert.Equal(t, "anthropic", p.Name())
+
+	// models matching no pattern route as before
+	p, _, err = svc.GetProviderForModel(context.Background(), "google/gemini-2.5-pro")
+	require.NoError(t, err)
+	assert.Equal(t, "google", p.Name())
+}
@@ -0,0 +1,122 @@
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/nulzo/model-router-api/internal/llm"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+// titleUnsupportedParameters marks the error of a model none of whose
+// providers support the request under require_parameters.
+const titleUnsupportedParameters = "Unsupported Parameters"
+
+// routeStep is one routing attempt: a candidate model and, when the request
+// orders providers, the ones it may be sent to (nil allows every target).
+type routeStep struct {
+	modelID   string
+	providers []string
+}
+
+// allowsFallbacks reports whether a request may be served by providers and
+// models other than the ones it asked for, the default.
+func allowsFallbacks(prefs *api.ProviderPreferences) bool {
+	return prefs == nil || prefs.AllowFallbacks == nil || *prefs.AllowFallbacks
+}
+
+// routeSteps expands candidate models into routing attempts. Without a
+// provider order every candidate is one attempt. With one, each candidate is
+// tried on the ordered providers serving it in turn, then, when fallbacks
+// are allowed, on the rest of its providers.
+func (s *service) routeSteps(req *api.ChatRequest, candidates []string) ([]routeStep, error) {
+	var order []string
+	if req.Provider != nil {
+		for _, id := range req.Provider.Order {
+			order = append(order, s.registry.canonicalProviderID(id))
+		}
+	}
+
+	steps := make([]routeStep, 0, len(candidates))
+	if len(order) == 0 {
+		for _, modelID := range candidates {
+			steps = append(steps, routeStep{modelID: modelID})
+		}
+		return steps, nil
+	}
+
+	fallbacks := allowsFallbacks(req.Provider)
+	for _, modelID := range candidates {
+		targets, err := s.registry.ResolveRoute(modelID)
+		if err != nil {
+			// left to the fallthrough provider, which is not ordered
+			if fallbacks {
+				steps = append(steps, routeStep{modelID: modelID})
+			}
+			continue
+		}
+
+		var rest []string
+		for _, t := range targets {
+			if !slices.Contains(order, t.providerID) && !slices.Contains(rest, t.providerID) {
+				rest = append(rest, t.providerID)
+			}
+		}
+		for _, providerID := range order {
+			if slices.ContainsFunc(targets, func(t routeTarget) bool { return t.providerID == providerID }) {
+				steps = append(steps, routeStep{modelID: modelID, providers: []string{providerID}})
+			}
+		}
+		if fallbacks && len(rest) > 0 {
+			steps = append(steps, routeStep{modelID: modelID, providers: rest})
+		}
+	}
+
+	if len(steps) == 0 {
+		return nil, api.BadRequestError(fmt.Sprintf("none of the providers in provider.order serve model '%s'", req.Model))
+	}
+	return steps, nil
+}
+
+// parameterCheck returns the check a provider must pass to serve req, nil
+// unless the request sets require_parameters.
+func (s *service) parameterCheck(req *api.ChatRequest) func(providerID string) error {
+	if req.Provider == nil || !req.Provider.RequireParameters {
+		return nil
+	}
+	return func(providerID string) error {
+		return s.supportsParameters(providerID, req)
+	}
+}
+
+// supportsParameters returns why providerID can not honor every parameter
+// of req, if it can not. Callers hold s.mu.
+func (s *service) supportsParameters(providerID string, req *api.ChatRequest) error {
+	caps := llm.DetectCapabilities(s.providers[providerID], s.registry.providerModels(providerID))
+
+	var missing []string
+	if len(req.Tools) > 0 && !caps.Tools {
+		missing = append(missing, "tools")
+	}
+	if f := req.ResponseFormat; f != nil && (f.Type == "json_object" || f.Type == "json_schema") && !caps.JSONMode {
+		missing = append(missing, "response_format")
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return api.NewError(http.StatusBadRequest, titleUnsupportedParameters,
+		fmt.Sprintf("provider '%s' does not support %s", providerID, strings.Join(missing, ", ")),
+		api.WithExtension("provider", providerID),
+		api.WithExtension("parameters", missing),
+	)
+}
+
+// isUnsupportedParameters reports whether err is a supportsParameters
+// rejection, which moves routing on to the next candidate.
+func isUnsupportedParameters(err error) bool {
+	var problem *api.Problem
+	return errors.As(err, &problem) && problem.Title == titleUnsupportedParameters
+}
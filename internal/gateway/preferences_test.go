@@ -0,0 +1,128 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// preferenceTestService spreads shared/model over "first" and "second", with
+// "backup/model" configured as its fallback. Only "second" supports tools.
+func preferenceTestService(t *testing.T) (*service, *captureIngestor, *mockProvider, *mockProvider, *mockProvider) {
+	first := &mockProvider{id: "first", models: []api.ModelDefinition{
+		{ID: "shared/model", ProviderID: "first", UpstreamID: "model"},
+		{ID: "first/plain", ProviderID: "first"},
+	}}
+	second := &mockProvider{id: "second", models: []api.ModelDefinition{{ID: "second/tools", ProviderID: "second", Config: api.ModelConfig{ToolUse: true}}}}
+	backup := &mockProvider{id: "backup", models: []api.ModelDefinition{{ID: "backup/model", ProviderID: "backup", Config: api.ModelConfig{ToolUse: true}}}}
+	svc, ingestor := newTestService(t, config.GatewayConfig{
+		RecordRouting: true,
+		Fallbacks:     []config.FallbackConfig{{Model: "shared/model", Fallbacks: []string{"backup/model"}}},
+		WeightedRoutes: []config.WeightedRoute{{
+			Model: "shared/model",
+			Targets: []config.RouteTarget{
+				{Provider: "first", Weight: 1},
+				{Provider: "second", Weight: 1},
+			},
+		}},
+	}, first, second, backup)
+	return svc, ingestor, first, second, backup
+}
+
+func TestChat_ProviderOrder(t *testing.T) {
+	svc, ingestor, first, second, _ := preferenceTestService(t)
+	second.chatErr = api.NewError(http.StatusServiceUnavailable, "Upstream Provider Error", "overloaded")
+
+	_, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "shared/model",
+		Provider: &api.ProviderPreferences{Order: []string{"second", "first"}},
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+	require.Len(t, first.requests, 1)
+	assert.Nil(t, first.lastRequest().Provider, "preferences are not forwarded upstream")
+
+	log := ingestor.last(t)
+	assert.Equal(t, "first", log.ProviderID)
+	require.Len(t, log.Routing, 2)
+	assert.Equal(t, "second", log.Routing[0].ProviderID)
+	assert.Equal(t, "first", log.Routing[1].ProviderID)
+}
+
+func TestChat_ProviderOrderWithoutFallbacks(t *testing.T) {
+	svc, _, first, second, backup := preferenceTestService(t)
+	second.chatErr = api.NewError(http.StatusServiceUnavailable, "Upstream Provider Error", "overloaded")
+	noFallbacks := false
+
+	_, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "shared/model",
+		Provider: &api.ProviderPreferences{Order: []string{"second"}, AllowFallbacks: &noFallbacks},
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	assert.Equal(t, http.StatusServiceUnavailable, errorStatus(err))
+	assert.Len(t, second.requests, 1)
+	assert.Empty(t, first.requests, "providers outside the order are not tried")
+	assert.Empty(t, backup.requests, "configured fallbacks are not tried")
+
+	// an order naming no provider of the model is rejected
+	_, err = svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "shared/model",
+		Provider: &api.ProviderPreferences{Order: []string{"backup"}, AllowFallbacks: &noFallbacks},
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	assert.Equal(t, http.StatusBadRequest, errorStatus(err))
+}
+
+func TestChat_RequireParameters(t *testing.T) {
+	svc, _, first, second, backup := preferenceTestService(t)
+	tools := []api.Tool{{Type: "function", Function: api.FunctionDescription{Name: "lookup"}}}
+
+	// only the provider supporting tools is picked for the model
+	for range 10 {
+		_, err := svc.Chat(context.Background(), &api.ChatRequest{
+			Model:    "shared/model",
+			Tools:    tools,
+			Provider: &api.ProviderPreferences{RequireParameters: true},
+			Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+		})
+		require.NoError(t, err)
+	}
+	assert.Empty(t, first.requests)
+	assert.Len(t, second.requests, 10)
+
+	// a model none of whose providers support the request moves on to the next
+	resp, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "first/plain",
+		Models:   []string{"backup/model"},
+		Tools:    tools,
+		Provider: &api.ProviderPreferences{RequireParameters: true},
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "backup/model", resp.Model)
+	assert.Empty(t, first.requests)
+
+	// mock providers do not declare JSON mode
+	_, err = svc.Chat(context.Background(), &api.ChatRequest{
+		Model:          "shared/model",
+		ResponseFormat: &api.ResponseFormat{Type: "json_object"},
+		Provider:       &api.ProviderPreferences{RequireParameters: true},
+		Messages:       []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	assert.Equal(t, http.StatusBadRequest, errorStatus(err))
+	assert.True(t, isUnsupportedParameters(err))
+	assert.Len(t, backup.requests, 1)
+
+	// without require_parameters the request is routed as usual
+	_, err = svc.Chat(context.Background(), &api.ChatRequest{
+		Model:          "shared/model",
+		ResponseFormat: &api.ResponseFormat{Type: "json_object"},
+		Messages:       []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+}
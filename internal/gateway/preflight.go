@@ -0,0 +1,106 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/httpclient"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"go.uber.org/zap"
+)
+
+// defaultPreflightTimeout applies when no timeout is configured.
+const defaultPreflightTimeout = 2 * time.Second
+
+// PreflightRequest is posted to the pre-flight webhook before a request is
+// dispatched.
+type PreflightRequest struct {
+	KeyID               string `json:"key_id,omitempty"`
+	UserID              string `json:"user_id,omitempty"`
+	AppName             string `json:"app_name,omitempty"`
+	Model               string `json:"model"`
+	Stream              bool   `json:"stream"`
+	Messages            int    `json:"messages"`
+	EstimatedTokens     int    `json:"estimated_tokens"`
+	EstimatedCostMicros int64  `json:"estimated_cost_micros,omitempty"`
+}
+
+// PreflightDecision is the webhook's answer. An allowed request may have its
+// model or max_tokens replaced before dispatch, the changed request is still
+// held to the key's model policy and balance.
+type PreflightDecision struct {
+	Allow     bool   `json:"allow"`
+	Reason    string `json:"reason,omitempty"`
+	Model     string `json:"model,omitempty"`
+	MaxTokens int    `json:"max_tokens,omitempty"`
+}
+
+// preflight asks the configured webhook whether req may be dispatched and
+// applies its changes. When the webhook can not be reached in time the request
+// is let through or rejected with a 503, per the fail_open setting.
+func (s *service) preflight(ctx context.Context, req *api.ChatRequest) error {
+	cfg := s.config.Preflight
+	if cfg.URL == "" {
+		return nil
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultPreflightTimeout
+	}
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var decision PreflightDecision
+	err := httpclient.SendRequest(callCtx, s.preflightClient, http.MethodPost, cfg.URL, nil, s.preflightRequest(ctx, req), &decision)
+	if err != nil {
+		// the caller going away is not a webhook failure
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if cfg.FailOpen {
+			s.logger.Warn("Pre-flight webhook failed, allowing request", zap.String("model", req.Model), zap.Error(err))
+			return nil
+		}
+		return api.NewError(http.StatusServiceUnavailable, "Pre-flight Unavailable",
+			"the request could not be validated, try again later", api.WithLog(err))
+	}
+
+	if !decision.Allow {
+		detail := decision.Reason
+		if detail == "" {
+			detail = "the request was denied by the gateway policy"
+		}
+		return api.NewError(http.StatusForbidden, "Request Denied", detail)
+	}
+	if decision.Model != "" {
+		req.Model = decision.Model
+	}
+	if decision.MaxTokens > 0 {
+		req.MaxTokens = decision.MaxTokens
+	}
+	return nil
+}
+
+// preflightRequest describes req for the webhook, the cost is estimated from
+// the stored pricing when there is one.
+func (s *service) preflightRequest(ctx context.Context, req *api.ChatRequest) *PreflightRequest {
+	prompt, completion := estimateTokens(req)
+	body := &PreflightRequest{
+		AppName:         appNameFromContext(ctx),
+		Model:           req.Model,
+		Stream:          req.Stream,
+		Messages:        len(req.Messages),
+		EstimatedTokens: prompt + completion,
+	}
+	if apiKey, ok := ctx.Value(store.ContextKeyAPIKey).(*model.APIKey); ok {
+		body.KeyID, body.UserID = apiKey.ID, apiKey.UserID
+	}
+	if pricing, err := s.repo.Providers().GetModelPricing(ctx, req.Model); err == nil {
+		body.EstimatedCostMicros = costMicros(pricing, prompt, completion, nil)
+	}
+	return body
+}
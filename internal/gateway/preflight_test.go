@@ -0,0 +1,127 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// preflightWebhook answers every pre-flight call with decision and keeps the
+// last request it received.
+func preflightWebhook(t *testing.T, decision PreflightDecision, delay time.Duration) (*httptest.Server, *PreflightRequest) {
+	var received PreflightRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		time.Sleep(delay)
+		_ = json.NewEncoder(w).Encode(decision)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &received
+}
+
+func TestChat_PreflightWebhook(t *testing.T) {
+	newProvider := func() *mockProvider {
+		return &mockProvider{
+			id: "mock",
+			models: []api.ModelDefinition{
+				{ID: "mock/large", ProviderID: "mock", UpstreamID: "large"},
+				{ID: "mock/small", ProviderID: "mock", UpstreamID: "small"},
+			},
+		}
+	}
+	ctx := context.WithValue(context.Background(), store.ContextKeyAPIKey, &model.APIKey{ID: "key-1", UserID: "user-1"})
+	req := func() *api.ChatRequest {
+		return &api.ChatRequest{
+			Model:    "mock/large",
+			Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+		}
+	}
+
+	t.Run("denied", func(t *testing.T) {
+		srv, received := preflightWebhook(t, PreflightDecision{Allow: false, Reason: "quota exhausted"}, 0)
+		p := newProvider()
+		svc, _ := newTestService(t, config.GatewayConfig{Preflight: config.PreflightConfig{URL: srv.URL}}, p)
+
+		_, err := svc.Chat(ctx, req())
+		require.Error(t, err)
+		assert.Equal(t, http.StatusForbidden, errorStatus(err))
+		assert.Contains(t, err.Error(), "quota exhausted")
+		assert.Empty(t, p.requests, "denied requests are not dispatched")
+
+		assert.Equal(t, "key-1", received.KeyID)
+		assert.Equal(t, "user-1", received.UserID)
+		assert.Equal(t, "mock/large", received.Model)
+		assert.Positive(t, received.EstimatedTokens)
+	})
+
+	t.Run("allowed with changes", func(t *testing.T) {
+		srv, _ := preflightWebhook(t, PreflightDecision{Allow: true, Model: "mock/small", MaxTokens: 64}, 0)
+		p := newProvider()
+		svc, _ := newTestService(t, config.GatewayConfig{Preflight: config.PreflightConfig{URL: srv.URL}}, p)
+
+		_, err := svc.Chat(ctx, req())
+		require.NoError(t, err)
+		assert.Equal(t, "small", p.lastRequest().Model)
+		assert.Equal(t, 64, p.lastRequest().MaxTokens)
+	})
+
+	t.Run("rewrite to a model the key may not call", func(t *testing.T) {
+		srv, _ := preflightWebhook(t, PreflightDecision{Allow: true, Model: "mock/small"}, 0)
+		p := newProvider()
+		svc, _ := newTestService(t, config.GatewayConfig{Preflight: config.PreflightConfig{URL: srv.URL}}, p)
+
+		_, err := svc.Chat(policyContext(`["mock/large"]`, ""), req())
+		assert.Equal(t, http.StatusForbidden, errorStatus(err))
+		assert.Empty(t, p.requests)
+	})
+
+	t.Run("timeouts follow fail_open", func(t *testing.T) {
+		srv, _ := preflightWebhook(t, PreflightDecision{Allow: false}, 200*time.Millisecond)
+
+		closed, _ := newTestService(t, config.GatewayConfig{Preflight: config.PreflightConfig{URL: srv.URL, Timeout: 20 * time.Millisecond}}, newProvider())
+		_, err := closed.Chat(ctx, req())
+		require.Error(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, errorStatus(err))
+
+		open, _ := newTestService(t, config.GatewayConfig{Preflight: config.PreflightConfig{URL: srv.URL, Timeout: 20 * time.Millisecond, FailOpen: true}}, newProvider())
+		_, err = open.Chat(ctx, req())
+		require.NoError(t, err)
+	})
+}
+
+func TestChat_PreflightChangesAreCappedToBalance(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	require.NoError(t, repo.Providers().SyncProviders(ctx, []model.Provider{{ID: "mock", Name: "mock", ConfigJSON: "{}", IsEnabled: true}}))
+	require.NoError(t, repo.Providers().SyncModels(ctx, []model.Model{{
+		ID: "mock/small", ProviderID: "mock", ProviderModelID: "small", IsEnabled: true,
+		InputCostMicrosPer1k: 1000, OutputCostMicrosPer1k: 2000,
+	}}))
+	now := time.Now()
+	require.NoError(t, repo.Users().Create(ctx, &model.User{ID: "user-1", Email: "user-1@example.com", Name: "user-1", Role: "user", CreatedAt: now, UpdatedAt: now}))
+	require.NoError(t, repo.Users().CreateWallet(ctx, &model.Wallet{ID: "wallet-1", UserID: "user-1", BalanceMicros: 1000, Currency: "USD", CreatedAt: now, UpdatedAt: now}))
+
+	srv, _ := preflightWebhook(t, PreflightDecision{Allow: true, Model: "mock/small", MaxTokens: 4096}, 0)
+	p := &mockProvider{id: "mock", models: []api.ModelDefinition{
+		{ID: "mock/large", ProviderID: "mock", UpstreamID: "large"},
+		{ID: "mock/small", ProviderID: "mock", UpstreamID: "small"},
+	}}
+	svc, _ := newTestServiceWith(t, zap.NewNop(), repo, config.GatewayConfig{BalanceTokenCap: true, Preflight: config.PreflightConfig{URL: srv.URL}}, p)
+
+	keyCtx := context.WithValue(ctx, store.ContextKeyAPIKey, &model.APIKey{ID: "key-1", UserID: "user-1"})
+	_, err := svc.Chat(keyCtx, &api.ChatRequest{Model: "mock/large", Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}}})
+	require.NoError(t, err)
+	assert.Equal(t, "small", p.lastRequest().Model)
+	assert.Equal(t, 499, p.lastRequest().MaxTokens, "the webhook's limit is capped to the balance")
+}
@@ -0,0 +1,291 @@
+package gateway
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/platform/crypto"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"go.uber.org/zap"
+)
+
+// ProviderStore keeps provider configuration in the database, which is the
+// source of truth once it has been seeded from the config file. API keys are
+// stored encrypted and only when a cipher is configured; otherwise the key
+// from the config file is used.
+type ProviderStore struct {
+	repo   store.Repository
+	cipher *crypto.Cipher
+	file   map[string]config.ProviderConfig
+	models []api.ModelDefinition
+	policy config.ProviderPolicy
+
+	// seen holds the updated_at of every loaded row, used by Watch.
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// NewProviderStore creates a store seeded by the config file providers. cipher
+// may be nil, in which case API keys are never written to the database.
+// Providers the policy does not permit are neither saved nor loaded.
+func NewProviderStore(repo store.Repository, cipher *crypto.Cipher, providers []config.ProviderConfig, models []api.ModelDefinition, policy config.ProviderPolicy) *ProviderStore {
+	file := make(map[string]config.ProviderConfig, len(providers))
+	for _, p := range providers {
+		file[p.ID] = p
+	}
+
+	return &ProviderStore{
+		repo:   repo,
+		cipher: cipher,
+		file:   file,
+		models: models,
+		policy: policy,
+		seen:   make(map[string]time.Time),
+	}
+}
+
+// Bootstrap writes config file providers that the database does not know yet.
+// Rows already holding a full configuration are left alone so changes made
+// at runtime survive a restart.
+func (ps *ProviderStore) Bootstrap(ctx context.Context) error {
+	for _, p := range ps.file {
+		existing, err := ps.repo.Providers().GetByID(ctx, p.ID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to get provider %s: %w", p.ID, err)
+		}
+		if existing != nil && existing.Type != "" {
+			continue
+		}
+		if err := ps.Save(ctx, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Save creates or replaces a provider in the database.
+func (ps *ProviderStore) Save(ctx context.Context, p config.ProviderConfig) error {
+	if err := ps.checkPolicy(p); err != nil {
+		return err
+	}
+
+	keyEnc := ""
+	if ps.cipher != nil && p.APIKey != "" {
+		enc, err := ps.cipher.Encrypt(p.APIKey)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt api key for %s: %w", p.ID, err)
+		}
+		keyEnc = enc
+	}
+
+	configJSON := "{}"
+	if len(p.Config) > 0 {
+		b, err := json.Marshal(p.Config)
+		if err != nil {
+			return fmt.Errorf("failed to encode config for %s: %w", p.ID, err)
+		}
+		configJSON = string(b)
+	}
+
+	return ps.repo.Providers().Upsert(ctx, &model.Provider{
+		ID:           p.ID,
+		Type:         p.Type,
+		Name:         p.Name,
+		BaseURL:      p.BaseURL,
+		APIKeyEnc:    keyEnc,
+		ConfigJSON:   configJSON,
+		RequiresAuth: p.RequiresAuth,
+		Timeout:      p.Timeout,
+		IsEnabled:    p.Enabled,
+	})
+}
+
+// Load returns the configuration of every provider in the database.
+// Placeholder rows without a type are skipped.
+func (ps *ProviderStore) Load(ctx context.Context) ([]config.ProviderConfig, error) {
+	rows, err := ps.repo.Providers().ListAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list providers: %w", err)
+	}
+
+	providers := make([]config.ProviderConfig, 0, len(rows))
+	for i := range rows {
+		if rows[i].Type == "" {
+			continue
+		}
+		p, err := ps.toConfig(&rows[i])
+		if err != nil {
+			return nil, err
+		}
+		if err := ps.checkPolicy(p); err != nil {
+			return nil, err
+		}
+		ps.markSeen(rows[i].ID, rows[i].UpdatedAt)
+		providers = append(providers, p)
+	}
+	return providers, nil
+}
+
+// Reload re-reads a single provider and applies it to the service, swapping
+// out the running instance. Disabled or deleted providers are unregistered.
+func (ps *ProviderStore) Reload(ctx context.Context, service Service, id string, log *zap.Logger) error {
+	row, err := ps.repo.Providers().GetByID(ctx, id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to get provider %s: %w", id, err)
+	}
+
+	if row == nil || row.Type == "" {
+		service.UnregisterProvider(id)
+		ps.forget(id)
+		return nil
+	}
+	ps.markSeen(id, row.UpdatedAt)
+	if !row.IsEnabled {
+		service.UnregisterProvider(id)
+		return nil
+	}
+
+	p, err := ps.toConfig(row)
+	if err != nil {
+		return err
+	}
+	if err := ps.checkPolicy(p); err != nil {
+		service.UnregisterProvider(id)
+		return err
+	}
+	// the running instance is only replaced once the new one registered, a
+	// bad change leaves it serving
+	if !registerProvider(ctx, service, validator.New(), p, log) {
+		return fmt.Errorf("provider %s could not be registered", id)
+	}
+	return nil
+}
+
+// checkPolicy rejects providers the environment does not permit, so a
+// staging deployment can not be pointed at a paid upstream by mistake.
+func (ps *ProviderStore) checkPolicy(p config.ProviderConfig) error {
+	if ps.policy.Permits(p) {
+		return nil
+	}
+	return api.NewError(http.StatusForbidden, "Provider Not Permitted",
+		fmt.Sprintf("provider '%s' of type '%s' is not permitted in the '%s' environment", p.ID, p.Type, ps.policy.Env),
+		api.WithExtension("provider", p.ID),
+	)
+}
+
+// Watch polls the providers table and reloads every provider whose row was
+// added, changed or removed since it was last loaded.
+func (ps *ProviderStore) Watch(ctx context.Context, service Service, interval time.Duration, log *zap.Logger) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				ps.reloadChanged(ctx, service, log)
+			}
+		}
+	}()
+}
+
+func (ps *ProviderStore) reloadChanged(ctx context.Context, service Service, log *zap.Logger) {
+	rows, err := ps.repo.Providers().ListAll(ctx)
+	if err != nil {
+		log.Error("Failed to poll providers", zap.Error(err))
+		return
+	}
+
+	ps.mu.Lock()
+	var changed []string
+	current := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		if row.Type == "" {
+			continue
+		}
+		current[row.ID] = true
+		if seen, ok := ps.seen[row.ID]; !ok || !seen.Equal(row.UpdatedAt) {
+			changed = append(changed, row.ID)
+		}
+	}
+	for id := range ps.seen {
+		if !current[id] {
+			changed = append(changed, id)
+		}
+	}
+	ps.mu.Unlock()
+
+	for _, id := range changed {
+		log.Info("Provider configuration changed, reloading", zap.String("id", id))
+		if err := ps.Reload(ctx, service, id, log); err != nil {
+			log.Error("Failed to reload provider", zap.String("id", id), zap.Error(err))
+		}
+	}
+}
+
+func (ps *ProviderStore) markSeen(id string, updatedAt time.Time) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.seen[id] = updatedAt
+}
+
+func (ps *ProviderStore) forget(id string) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	delete(ps.seen, id)
+}
+
+func (ps *ProviderStore) toConfig(row *model.Provider) (config.ProviderConfig, error) {
+	p := config.ProviderConfig{
+		ID:           row.ID,
+		Type:         row.Type,
+		Name:         row.Name,
+		BaseURL:      row.BaseURL,
+		Timeout:      row.Timeout,
+		Enabled:      row.IsEnabled,
+		RequiresAuth: row.RequiresAuth,
+	}
+
+	if row.ConfigJSON != "" && row.ConfigJSON != "{}" {
+		if err := json.Unmarshal([]byte(row.ConfigJSON), &p.Config); err != nil {
+			return p, fmt.Errorf("failed to decode config for %s: %w", row.ID, err)
+		}
+	}
+
+	switch {
+	case row.APIKeyEnc != "" && ps.cipher == nil:
+		return p, fmt.Errorf("provider %s has a stored api key but no encryption key is configured", row.ID)
+	case row.APIKeyEnc != "":
+		key, err := ps.cipher.Decrypt(row.APIKeyEnc)
+		if err != nil {
+			return p, fmt.Errorf("failed to decrypt api key for %s: %w", row.ID, err)
+		}
+		p.APIKey = key
+	default:
+		// keys are not persisted without a cipher, use the config file one
+		p.APIKey = ps.file[row.ID].APIKey
+	}
+
+	for _, m := range ps.models {
+		if m.ProviderID == row.ID {
+			p.StaticModels = append(p.StaticModels, m)
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,164 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/platform/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	_ "github.com/nulzo/model-router-api/internal/llm/openai"
+)
+
+func TestProviderStore_LoadsDBProviderOnStartup(t *testing.T) {
+	var authorization atomic.Value
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization.Store(r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":[{"id":"db-model"}]}`))
+	}))
+	defer upstream.Close()
+
+	ctx := context.Background()
+	repo := newTestRepo(t)
+	cipher, err := crypto.NewCipher("test-encryption-key")
+	require.NoError(t, err)
+
+	// a provider that only exists in the database, not in the config file
+	require.NoError(t, NewProviderStore(repo, cipher, nil, nil, config.ProviderPolicy{}).Save(ctx, config.ProviderConfig{
+		ID:           "db-openai",
+		Type:         "openai",
+		Name:         "DB OpenAI",
+		APIKey:       "sk-from-db",
+		BaseURL:      upstream.URL,
+		Enabled:      true,
+		RequiresAuth: true,
+	}))
+
+	stored, err := repo.Providers().GetByID(ctx, "db-openai")
+	require.NoError(t, err)
+	assert.NotEmpty(t, stored.APIKeyEnc)
+	assert.NotContains(t, stored.APIKeyEnc, "sk-from-db")
+
+	// startup: a fresh store with the same key loads and registers it
+	providerStore := NewProviderStore(repo, cipher, nil, nil, config.ProviderPolicy{})
+	providers, err := providerStore.Load(ctx)
+	require.NoError(t, err)
+	require.Len(t, providers, 1)
+	assert.Equal(t, "sk-from-db", providers[0].APIKey)
+
+	svc, _ := newTestServiceWith(t, zap.NewNop(), repo, config.GatewayConfig{})
+	registered, err := BootstrapProviders(ctx, svc, providers, zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, 1, registered)
+
+	p, upstreamModel, err := svc.GetProviderForModel(ctx, "db-openai/db-model")
+	require.NoError(t, err)
+	assert.Equal(t, "db-openai", p.Name())
+	assert.Equal(t, "db-model", upstreamModel)
+	assert.Equal(t, "Bearer sk-from-db", authorization.Load())
+}
+
+func TestProviderStore_BootstrapKeepsExistingRows(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepo(t)
+
+	file := config.ProviderConfig{ID: "openai", Type: "openai", Name: "OpenAI", Enabled: true}
+	require.NoError(t, NewProviderStore(repo, nil, nil, nil, config.ProviderPolicy{}).Save(ctx, config.ProviderConfig{
+		ID: "openai", Type: "openai", Name: "Changed at runtime", Enabled: false,
+	}))
+
+	providerStore := NewProviderStore(repo, nil, []config.ProviderConfig{file}, nil, config.ProviderPolicy{})
+	require.NoError(t, providerStore.Bootstrap(ctx))
+
+	providers, err := providerStore.Load(ctx)
+	require.NoError(t, err)
+	require.Len(t, providers, 1)
+	assert.Equal(t, "Changed at runtime", providers[0].Name)
+	assert.False(t, providers[0].Enabled)
+}
+
+func TestProviderStore_ReloadSwapsRunningInstance(t *testing.T) {
+	var models atomic.Value
+	models.Store(`{"data":[{"id":"kept"},{"id":"dropped"}]}`)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := models.Load().(string)
+		if body == "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	defer upstream.Close()
+
+	ctx := context.Background()
+	repo := newTestRepo(t)
+	providerStore := NewProviderStore(repo, nil, nil, nil, config.ProviderPolicy{})
+	save := func(name string) {
+		require.NoError(t, providerStore.Save(ctx, config.ProviderConfig{
+			ID: "db-openai", Type: "openai", Name: name, BaseURL: upstream.URL, Enabled: true,
+		}))
+	}
+	save("v1")
+
+	svc, _ := newTestServiceWith(t, zap.NewNop(), repo, config.GatewayConfig{})
+	require.NoError(t, providerStore.Reload(ctx, svc, "db-openai", zap.NewNop()))
+	_, _, err := svc.GetProviderForModel(ctx, "db-openai/dropped")
+	require.NoError(t, err)
+
+	// the new instance replaces the old one, models it no longer serves go
+	models.Store(`{"data":[{"id":"kept"},{"id":"added"}]}`)
+	save("v2")
+	require.NoError(t, providerStore.Reload(ctx, svc, "db-openai", zap.NewNop()))
+	for _, id := range []string{"db-openai/kept", "db-openai/added"} {
+		_, _, err := svc.GetProviderForModel(ctx, id)
+		assert.NoError(t, err, id)
+	}
+	_, _, err = svc.GetProviderForModel(ctx, "db-openai/dropped")
+	assert.Error(t, err)
+
+	// a change that can not be registered leaves the running instance serving
+	models.Store("")
+	save("v3")
+	assert.Error(t, providerStore.Reload(ctx, svc, "db-openai", zap.NewNop()))
+	_, _, err = svc.GetProviderForModel(ctx, "db-openai/kept")
+	assert.NoError(t, err)
+}
+
+func TestProviderStore_RejectsProvidersTheEnvironmentDenies(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepo(t)
+
+	cfg := &config.Config{
+		Server:           config.ServerConfig{Env: "staging"},
+		ProviderPolicies: map[string]config.ProviderPolicy{"staging": {Allow: []string{"mock-openai"}}},
+	}
+	mock := config.ProviderConfig{ID: "mock-openai", Type: "openai", Name: "Mock", BaseURL: "http://localhost:9091/v1", Enabled: true}
+	paid := config.ProviderConfig{ID: "openai", Type: "openai", Name: "OpenAI", Enabled: true}
+
+	providerStore := NewProviderStore(repo, nil, []config.ProviderConfig{mock}, nil, cfg.ProviderPolicy())
+	require.NoError(t, providerStore.Bootstrap(ctx))
+
+	err := NewProviderStore(repo, nil, []config.ProviderConfig{mock, paid}, nil, cfg.ProviderPolicy()).Bootstrap(ctx)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusForbidden, errorStatus(err))
+	assert.Contains(t, err.Error(), "'openai' of type 'openai' is not permitted in the 'staging' environment")
+
+	// rows saved before the policy was set are refused too
+	require.NoError(t, NewProviderStore(repo, nil, nil, nil, config.ProviderPolicy{}).Save(ctx, paid))
+	_, err = providerStore.Load(ctx)
+	require.Error(t, err)
+
+	// other environments are not restricted
+	cfg.Server.Env = "production"
+	providers, err := NewProviderStore(repo, nil, nil, nil, cfg.ProviderPolicy()).Load(ctx)
+	require.NoError(t, err)
+	assert.Len(t, providers, 2)
+}
@@ -13,41 +13,265 @@ import (
 // It is thread-safe.
 type registry struct {
 	models map[string]api.ModelDefinition
-	mu     sync.RWMutex
+	// aliases maps other names of a provider, such as the config ID of an
+	// adapter whose Name differs from it, to the ID it is registered under.
+	aliases map[string]string
+	// routes spreads model IDs over several providers by weight, in place of
+	// the provider of the model's definition.
+	routes map[string][]routeTarget
+	// patterns force-routes every model matching one to its provider, the
+	// first match wins. Exact weighted routes take precedence.
+	patterns []patternRoute
+	// modelAliases maps other names of a model, such as "fast", to the model
+	// ID requests for them are routed as.
+	modelAliases map[string]string
+	mu           sync.RWMutex
+}
+
+// routeTarget is a provider a model can be sent to and its share of the
+// model's traffic.
+type routeTarget struct {
+	providerID string
+	upstreamID string
+	weight     int
 }
 
 func newRegistry() *registry {
 	return &registry{
-		models: make(map[string]api.ModelDefinition),
+		models:  make(map[string]api.ModelDefinition),
+		aliases: make(map[string]string),
+		routes:  make(map[string][]routeTarget),
+
+		modelAliases: make(map[string]string),
 	}
 }
 
-func (r *registry) addModel(m api.ModelDefinition) {
+// setRoute spreads modelID over targets. Targets without an upstream ID are
+// sent the model's own upstream ID.
+func (r *registry) setRoute(modelID string, targets []routeTarget) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes[modelID] = targets
+}
+
+// addPatternRoute appends a pattern route, matched after those added before.
+func (r *registry) addPatternRoute(route patternRoute) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.patterns = append(r.patterns, route)
+}
+
+// setModelAlias makes requests for alias go to modelID.
+func (r *registry) setModelAlias(alias, modelID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.modelAliases[alias] = modelID
+}
+
+// deleteModelAlias removes alias, reporting whether it existed.
+func (r *registry) deleteModelAlias(alias string) bool {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	_, ok := r.modelAliases[alias]
+	delete(r.modelAliases, alias)
+	return ok
+}
+
+// listModelAliases returns a copy of every model alias and its target.
+func (r *registry) listModelAliases() map[string]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	aliases := make(map[string]string, len(r.modelAliases))
+	for alias, modelID := range r.modelAliases {
+		aliases[alias] = modelID
+	}
+	return aliases
+}
+
+// resolveModelAlias returns the model an alias stands for, or id itself when
+// it is not an alias. Aliases resolve one level, never to another alias.
+func (r *registry) resolveModelAlias(id string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if modelID, ok := r.modelAliases[id]; ok {
+		return modelID
+	}
+	return id
+}
+
+// addAlias makes alias resolve to providerID, the canonical identity every
+// model route is indexed under.
+func (r *registry) addAlias(alias, providerID string) {
+	if alias == "" || alias == providerID {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.aliases[alias] = providerID
+}
+
+// canonicalProviderID returns the registered identity of a provider name.
+func (r *registry) canonicalProviderID(id string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.canonical(id)
+}
+
+// canonical is canonicalProviderID for callers holding the lock.
+func (r *registry) canonical(id string) string {
+	if canonical, ok := r.aliases[id]; ok {
+		return canonical
+	}
+	return id
+}
+
+// addModel registers a model definition served by providerID.
+// A definition that names its ProviderID explicitly and was not discovered
+// automatically is pinned: it can only be replaced by a definition for the
+// same provider, so another instance that happens to list the same model ID
+// never takes over its route. It reports whether the definition was stored.
+func (r *registry) addModel(providerID string, m api.ModelDefinition) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if m.ProviderID == "" {
+		m.ProviderID = providerID
+	}
+	m.ProviderID = r.canonical(m.ProviderID)
+
+	if existing, ok := r.models[m.ID]; ok && isPinned(existing) && existing.ProviderID != m.ProviderID {
+		return false
+	}
+
 	r.models[m.ID] = m
+	return true
 }
 
-// func (r *registry) getModel(id string) (api.ModelDefinition, bool) {
-// 	r.mu.RLock()
-// 	defer r.mu.RUnlock()
-// 	m, ok := r.models[id]
-// 	return m, ok
-// }
+// isPinned reports whether a model is bound to an exact provider instance.
+func isPinned(m api.ModelDefinition) bool {
+	return m.ProviderID != "" && m.Source != "auto"
+}
+
+// removeProvider drops every model served by providerID. Its aliases are
+// kept so a provider registered again under the same name resolves as before.
+func (r *registry) removeProvider(providerID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	providerID = r.canonical(providerID)
+	for id, m := range r.models {
+		if m.ProviderID == providerID {
+			delete(r.models, id)
+		}
+	}
+}
+
+// removeStaleModels drops the models of providerID missing from served,
+// left behind by the instance it replaced.
+func (r *registry) removeStaleModels(providerID string, served map[string]bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, m := range r.models {
+		if m.ProviderID == providerID && !served[id] {
+			delete(r.models, id)
+		}
+	}
+}
+
+// providerModels returns the models served by providerID.
+func (r *registry) providerModels(providerID string) []api.ModelDefinition {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var models []api.ModelDefinition
+	for _, m := range r.models {
+		if m.ProviderID == providerID {
+			models = append(models, m)
+		}
+	}
+	return models
+}
+
+func (r *registry) getModel(id string) (api.ModelDefinition, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	m, ok := r.models[id]
+	return m, ok
+}
 
-func (r *registry) ResolveRoute(modelID string) (string, string, error) {
+// ResolveRoute returns the providers modelID can be sent to with their
+// weights: the targets of its weighted route if it has one, else the target
+// of the first pattern route it matches, else the provider of its definition.
+func (r *registry) ResolveRoute(modelID string) ([]routeTarget, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
+	return r.resolveRoute(modelID)
+}
+
+// resolveRoute is ResolveRoute for callers holding r.mu.
+func (r *registry) resolveRoute(modelID string) ([]routeTarget, error) {
+	m, registered := r.models[modelID]
+	upstreamID := m.UpstreamID
+	if upstreamID == "" {
+		upstreamID = modelID
+	}
 
-	if m, ok := r.models[modelID]; ok {
-		upstreamID := m.UpstreamID
-		if upstreamID == "" {
-			upstreamID = modelID
+	if targets, ok := r.routes[modelID]; ok {
+		resolved := make([]routeTarget, len(targets))
+		for i, t := range targets {
+			resolved[i] = routeTarget{providerID: r.canonical(t.providerID), upstreamID: t.upstreamID, weight: t.weight}
+			if resolved[i].upstreamID == "" {
+				resolved[i].upstreamID = upstreamID
+			}
+		}
+		return resolved, nil
+	}
+
+	for _, p := range r.patterns {
+		if !p.match(modelID) {
+			continue
+		}
+		// unregistered models are sent as requested, minus their provider prefix
+		if !registered {
+			upstreamID = p.upstreamID(modelID)
 		}
-		return m.ProviderID, upstreamID, nil
+		return []routeTarget{{providerID: r.canonical(p.providerID), upstreamID: upstreamID, weight: 1}}, nil
 	}
 
-	return "", "", fmt.Errorf("model not found: %s", modelID)
+	if registered {
+		return []routeTarget{{providerID: r.canonical(m.ProviderID), upstreamID: upstreamID, weight: 1}}, nil
+	}
+
+	return nil, fmt.Errorf("model not found: %s", modelID)
+}
+
+// listedModel is an entry of the model list, a registered model or an alias
+// listed as a copy of its target.
+type listedModel struct {
+	id      string
+	aliasOf string
+	def     api.ModelDefinition
+}
+
+// listed returns every registered model, followed by the aliases of
+// registered models when withAliases is set. Callers hold r.mu.
+func (r *registry) listed(withAliases bool) []listedModel {
+	entries := make([]listedModel, 0, len(r.models))
+	for _, def := range r.models {
+		entries = append(entries, listedModel{id: def.ID, def: def})
+	}
+	if !withAliases {
+		return entries
+	}
+	for alias, modelID := range r.modelAliases {
+		// aliases of weighted routes or fallthrough models have no definition to copy
+		if def, ok := r.models[modelID]; ok {
+			entries = append(entries, listedModel{id: alias, aliasOf: modelID, def: def})
+		}
+	}
+	return entries
 }
 
 // listAndFilter converts internal definitions to the public API response format
@@ -58,9 +282,10 @@ func (s *service) ListAllModels(ctx context.Context, filter api.ModelFilter) ([]
 
 	var results []api.Model
 
-	for _, def := range s.registry.models {
+	for _, entry := range s.registry.listed(s.config.ListModelAliases) {
+		def := entry.def
 		m := api.Model{
-			ID:            def.ID,
+			ID:            entry.id,
 			Name:          def.Name,
 			Provider:      def.ProviderID,
 			Description:   def.Description,
@@ -87,7 +312,13 @@ func (s *service) ListAllModels(ctx context.Context, filter api.ModelFilter) ([]
 				IsModerated:         def.TopProvider.IsModerated,
 			},
 			OwnedBy: "system",
+			AliasOf: entry.aliasOf,
+		}
+		routed := entry.id
+		if entry.aliasOf != "" {
+			routed = entry.aliasOf
 		}
+		m.Available, m.Degraded = s.modelAvailability(routed)
 
 		if filter.Provider != "" && !strings.EqualFold(m.Provider, filter.Provider) {
 			continue
@@ -0,0 +1,202 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/flags"
+	"github.com/nulzo/model-router-api/internal/llm"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"go.uber.org/zap"
+)
+
+// route is the provider and model that handled a request.
+type route struct {
+	provider        llm.Provider
+	modelID         string
+	upstreamModelID string
+	// release gives back the provider slot the served request holds, set
+	// on the route routeWithFallback returns when it succeeded.
+	release func()
+}
+
+// applyDefaultProvider prefixes a bare model name that matches no registered
+// model with the configured default provider, when that resolves, so clients
+// sending "gpt-4o" reach "openai/gpt-4o".
+func (s *service) applyDefaultProvider(req *api.ChatRequest) {
+	req.Model = s.withDefaultProvider(req.Model)
+}
+
+// withDefaultProvider is applyDefaultProvider for a single model ID.
+func (s *service) withDefaultProvider(modelID string) string {
+	if s.config.DefaultProvider == "" || modelID == "" || strings.Contains(modelID, "/") {
+		return modelID
+	}
+	if _, ok := s.registry.getModel(modelID); ok {
+		return modelID
+	}
+	prefixed := s.config.DefaultProvider + "/" + modelID
+	if _, ok := s.registry.getModel(prefixed); ok {
+		return prefixed
+	}
+	return modelID
+}
+
+// routeCandidates returns the models to try for a request: the requested
+// model followed by the request's own models list when it sends one, as
+// OpenRouter does, else by the configured fallbacks.
+func (s *service) routeCandidates(req *api.ChatRequest) []string {
+	candidates := []string{req.Model}
+	if len(req.Models) > 0 {
+		for _, m := range req.Models {
+			m = s.withDefaultProvider(s.registry.resolveModelAlias(m))
+			if m != "" && !slices.Contains(candidates, m) {
+				candidates = append(candidates, m)
+			}
+		}
+		return candidates
+	}
+	for _, f := range s.config.Fallbacks {
+		if f.Model == req.Model {
+			candidates = append(candidates, f.Fallbacks...)
+			break
+		}
+	}
+	return candidates
+}
+
+// isRoutableFailure reports whether a failed attempt should move on to the
+// next candidate. Rate limits and upstream/server errors are routed around,
+// client errors and cancellations are returned as is.
+func isRoutableFailure(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	status := errorStatus(err)
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// routeWithFallback runs call against each candidate for req.Model until one
+// succeeds or fails with an error that is not routable. call receives a copy
+// of the request rewritten for the resolved route. It returns the route of
+// the last candidate that resolved to a provider (nil provider when none did)
+// and the ordered attempts.
+func (s *service) routeWithFallback(ctx context.Context, req *api.ChatRequest, call func(r route, upstreamReq *api.ChatRequest) error) (route, []model.RoutingAttempt, error) {
+	var (
+		served   route
+		attempts []model.RoutingAttempt
+		err      error
+	)
+
+	candidates := s.routeCandidates(req)
+	// configured fallbacks are other providers' models, a client's own list is kept
+	if !s.featureEnabled(ctx, flags.Fallback, req.Model, true) || (!allowsFallbacks(req.Provider) && len(req.Models) == 0) {
+		candidates = candidates[:1]
+	}
+	candidates = allowedCandidates(ctx, candidates)
+	candidates, err = s.capableCandidates(req, candidates)
+	if err != nil {
+		return served, nil, err
+	}
+	candidates, err = s.fittingCandidates(req, candidates)
+	if err != nil {
+		return served, nil, err
+	}
+	// a models list sent by the client is tried in its order
+	if s.config.RoutingStrategy == RoutingCost && len(req.Models) == 0 {
+		candidates = s.orderByCost(ctx, req, candidates)
+	}
+
+	steps, err := s.routeSteps(req, candidates)
+	if err != nil {
+		return served, nil, err
+	}
+	check := s.parameterCheck(req)
+
+	for i, step := range steps {
+		if i > 0 {
+			s.logger.Warn("Falling back to next model",
+				zap.String("model", req.Model),
+				zap.String("fallback", step.modelID),
+				zap.Strings("providers", step.providers),
+				zap.Error(err),
+			)
+		}
+
+		start := time.Now()
+		attempt := model.RoutingAttempt{Attempt: i + 1, ModelID: step.modelID, CreatedAt: start}
+
+		var provider llm.Provider
+		var upstreamModelID string
+		provider, upstreamModelID, err = s.resolveProvider(step.modelID, req.Stream, step.providers, check)
+		if err == nil {
+			served = route{provider: provider, modelID: step.modelID, upstreamModelID: upstreamModelID}
+			attempt.ProviderID = provider.Name()
+			attempt.UpstreamModelID = upstreamModelID
+
+			var release func()
+			if err = s.checkAuth(provider.Name()); err == nil {
+				release, err = s.admit(ctx, provider.Name())
+			}
+			if err == nil {
+				err = call(served, s.upstreamRequest(req, served))
+				// the caller gives the slot back once done with the response,
+				// an open stream keeps it until it ends
+				if err == nil {
+					served.release = release
+				} else {
+					release()
+				}
+				s.recordAuth(provider, err)
+				// the client already retried what fit in max_retry_delay
+				if d := retryAfter(err); d > 0 && errorStatus(err) == http.StatusTooManyRequests {
+					s.cooldowns.set(provider.Name(), d)
+				}
+			}
+		}
+
+		attempt.LatencyMS = time.Since(start).Milliseconds()
+		attempt.StatusCode = http.StatusOK
+		if err != nil {
+			attempt.StatusCode = errorStatus(err)
+			if errors.Is(err, context.Canceled) {
+				attempt.StatusCode = 499
+			}
+			attempt.ErrorMessage = err.Error()
+		}
+		attempts = append(attempts, attempt)
+
+		// a model without a provider supporting the request moves on to the next
+		if err == nil || (!isRoutableFailure(err) && !isUnsupportedParameters(err)) {
+			break
+		}
+	}
+
+	return served, attempts, err
+}
+
+// upstreamRequest returns a copy of req rewritten for the provider of r.
+func (s *service) upstreamRequest(req *api.ChatRequest, r route) *api.ChatRequest {
+	upstreamReq := *req
+	upstreamReq.Model = r.upstreamModelID
+	upstreamReq.IncludeReasoning = nil // applied by the gateway
+	upstreamReq.Debug = nil            // answered by the gateway
+	upstreamReq.Models = nil           // fallbacks are tried by the gateway
+	upstreamReq.Route = ""
+	upstreamReq.Provider = nil // preferences are applied by the gateway
+	s.truncateToContext(r.modelID, &upstreamReq)
+	s.sanitize(r.provider, r.modelID, &upstreamReq)
+	return &upstreamReq
+}
+
+// withRouting attaches the routing audit trail to a request log when enabled.
+func (s *service) withRouting(log *model.RequestLog, attempts []model.RoutingAttempt) {
+	if s.config.RecordRouting {
+		log.Routing = attempts
+	}
+}
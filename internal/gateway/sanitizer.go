@@ -0,0 +1,189 @@
+package gateway
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/nulzo/model-router-api/internal/llm"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+// sanitize applies the provider specific request policies to the upstream
+// request before it is handed to the adapter, so adapters do not need to
+// hardcode their own defaults.
+func (s *service) sanitize(provider llm.Provider, modelID string, req *api.ChatRequest) {
+	s.applyMaxTokensDefault(provider, modelID, req)
+
+	// store and metadata are OpenAI extensions other upstreams may reject
+	if provider.Type() != "openai" {
+		req.Store, req.Metadata = false, nil
+	}
+
+	// search parameters are Perplexity extensions
+	if provider.Type() != "perplexity" {
+		req.SearchDomainFilter, req.ReturnCitations = nil, nil
+	}
+
+	// cache breakpoints are read by Anthropic and forwarded by OpenRouter,
+	// OpenAI and others reject the unknown field
+	if t := provider.Type(); t != "anthropic" && t != "openrouter" {
+		stripCacheControl(req)
+	}
+
+	// the disclaimer goes first so model wrappers still enclose the whole prompt
+	applyDisclaimer(req, s.config.SystemDisclaimer)
+
+	m, ok := s.registry.getModel(modelID)
+	if ok {
+		applySystemWrappers(req, m.Config.SystemPrefix, m.Config.SystemSuffix)
+	}
+
+	// n asks image models for several images, OpenAI compatible upstreams
+	// would read it as a number of choices
+	if !ok || !slices.Contains(m.Architecture.OutputModalities, "image") {
+		req.N = 0
+	}
+}
+
+// stripCacheControl drops the prompt cache breakpoints from the content parts.
+// Messages carrying one are copied so the caller's request is left untouched.
+func stripCacheControl(req *api.ChatRequest) {
+	copied := false
+	for i, m := range req.Messages {
+		if !slices.ContainsFunc(m.Content.Parts, func(p api.ContentPart) bool { return p.CacheControl != nil }) {
+			continue
+		}
+		if !copied {
+			req.Messages = slices.Clone(req.Messages)
+			copied = true
+		}
+
+		parts := slices.Clone(m.Content.Parts)
+		for j := range parts {
+			parts[j].CacheControl = nil
+		}
+		req.Messages[i].Content.Parts = parts
+	}
+}
+
+// applyMaxTokensDefault fills max_tokens for providers that mandate it.
+// A provider mandates max_tokens when it has an entry in MaxTokensDefaults,
+// keyed by provider ID or type. A model level default_max_tokens takes
+// precedence over the provider default. With InferMaxTokens set, requests
+// to other providers get that cap too, and the value is lowered to what the
+// model's context window leaves after the estimated prompt.
+func (s *service) applyMaxTokensDefault(provider llm.Provider, modelID string, req *api.ChatRequest) {
+	if req.MaxTokens > 0 {
+		return
+	}
+	if req.MaxCompletionTokens > 0 {
+		req.MaxTokens = req.MaxCompletionTokens
+		return
+	}
+
+	def, ok := s.config.MaxTokensDefaults[provider.Name()]
+	if !ok {
+		def, ok = s.config.MaxTokensDefaults[provider.Type()]
+	}
+	infer := s.config.InferMaxTokens > 0
+	if !ok && !infer {
+		return
+	}
+
+	m, found := s.registry.getModel(modelID)
+	switch {
+	case found && m.Config.DefaultMaxTokens > 0:
+		def = m.Config.DefaultMaxTokens
+	case !ok:
+		def = s.config.InferMaxTokens
+	}
+
+	if infer && found {
+		def = inferMaxTokens(req, m.ContextLength, def)
+	}
+	req.MaxTokens = def
+}
+
+// inferMaxTokens lowers limit to the room the context window leaves after
+// the estimated prompt. An unknown window, or a prompt that already fills
+// it, keeps limit and leaves the provider to reject oversized requests.
+func inferMaxTokens(req *api.ChatRequest, contextLength, limit int) int {
+	if contextLength <= 0 {
+		return limit
+	}
+	prompt, _ := estimateTokens(req)
+	if remaining := contextLength - prompt; remaining > 0 {
+		return min(limit, remaining)
+	}
+	return limit
+}
+
+// applySystemWrappers wraps the first system message with the model's prefix
+// and suffix, or injects a system message when the client did not send one.
+// The messages slice is copied so the caller's request is left untouched.
+func applySystemWrappers(req *api.ChatRequest, prefix, suffix string) {
+	if prefix == "" && suffix == "" {
+		return
+	}
+
+	messages := make([]api.ChatMessage, len(req.Messages))
+	copy(messages, req.Messages)
+	req.Messages = messages
+
+	for i, m := range messages {
+		if m.Role != "system" {
+			continue
+		}
+
+		if len(m.Content.Parts) > 0 {
+			parts := make([]api.ContentPart, 0, len(m.Content.Parts)+2)
+			if prefix != "" {
+				parts = append(parts, api.ContentPart{Type: "text", Text: prefix})
+			}
+			parts = append(parts, m.Content.Parts...)
+			if suffix != "" {
+				parts = append(parts, api.ContentPart{Type: "text", Text: suffix})
+			}
+			messages[i].Content.Parts = parts
+		} else {
+			messages[i].Content.Text = joinNonEmpty(prefix, m.Content.Text, suffix)
+		}
+		return
+	}
+
+	system := api.ChatMessage{Role: "system", Content: api.Content{Text: joinNonEmpty(prefix, suffix)}}
+	req.Messages = append([]api.ChatMessage{system}, messages...)
+}
+
+// applyDisclaimer prepends the disclaimer to the system prompt unless a system
+// message already carries it, as happens when a client sends back the history
+// of a multi-turn conversation.
+func applyDisclaimer(req *api.ChatRequest, disclaimer string) {
+	if disclaimer == "" {
+		return
+	}
+	for _, m := range req.Messages {
+		if m.Role != "system" {
+			continue
+		}
+		if strings.Contains(m.Content.Text, disclaimer) {
+			return
+		}
+		for _, p := range m.Content.Parts {
+			if strings.Contains(p.Text, disclaimer) {
+				return
+			}
+		}
+	}
+	applySystemWrappers(req, disclaimer, "")
+}
+
+func joinNonEmpty(parts ...string) string {
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p != "" {
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, "\n")
+}
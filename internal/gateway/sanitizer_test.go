@@ -0,0 +1,239 @@
+package gateway
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplySystemWrappers(t *testing.T) {
+	withSystem := func() []api.ChatMessage {
+		return []api.ChatMessage{
+			{Role: "system", Content: api.Content{Text: "You are helpful."}},
+			{Role: "user", Content: api.Content{Text: "Hi"}},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		prefix   string
+		suffix   string
+		messages []api.ChatMessage
+		want     []api.ChatMessage
+	}{
+		{
+			name:     "prefix only",
+			prefix:   "<|begin|>",
+			messages: withSystem(),
+			want: []api.ChatMessage{
+				{Role: "system", Content: api.Content{Text: "<|begin|>\nYou are helpful."}},
+				{Role: "user", Content: api.Content{Text: "Hi"}},
+			},
+		},
+		{
+			name:     "suffix only",
+			suffix:   "Answer in English.",
+			messages: withSystem(),
+			want: []api.ChatMessage{
+				{Role: "system", Content: api.Content{Text: "You are helpful.\nAnswer in English."}},
+				{Role: "user", Content: api.Content{Text: "Hi"}},
+			},
+		},
+		{
+			name:     "prefix and suffix",
+			prefix:   "<|begin|>",
+			suffix:   "<|end|>",
+			messages: withSystem(),
+			want: []api.ChatMessage{
+				{Role: "system", Content: api.Content{Text: "<|begin|>\nYou are helpful.\n<|end|>"}},
+				{Role: "user", Content: api.Content{Text: "Hi"}},
+			},
+		},
+		{
+			name:     "injected without a system message",
+			prefix:   "<|begin|>",
+			suffix:   "<|end|>",
+			messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+			want: []api.ChatMessage{
+				{Role: "system", Content: api.Content{Text: "<|begin|>\n<|end|>"}},
+				{Role: "user", Content: api.Content{Text: "Hi"}},
+			},
+		},
+		{
+			name:   "multipart system message",
+			prefix: "<|begin|>",
+			messages: []api.ChatMessage{
+				{Role: "system", Content: api.Content{Parts: []api.ContentPart{{Type: "text", Text: "You are helpful."}}}},
+			},
+			want: []api.ChatMessage{
+				{Role: "system", Content: api.Content{Parts: []api.ContentPart{
+					{Type: "text", Text: "<|begin|>"},
+					{Type: "text", Text: "You are helpful."},
+				}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := make([]api.ChatMessage, len(tt.messages))
+			copy(original, tt.messages)
+
+			req := &api.ChatRequest{Messages: tt.messages}
+			applySystemWrappers(req, tt.prefix, tt.suffix)
+
+			assert.Equal(t, tt.want, req.Messages)
+			assert.Equal(t, original, tt.messages, "client messages must not be mutated")
+		})
+	}
+}
+
+func TestChat_AppliesModelSystemWrappers(t *testing.T) {
+	provider := &mockProvider{
+		id: "mock",
+		models: []api.ModelDefinition{{
+			ID: "mock/model", ProviderID: "mock", UpstreamID: "model",
+			Config: api.ModelConfig{SystemPrefix: "[INST]", SystemSuffix: "[/INST]"},
+		}},
+	}
+	svc, _ := newTestService(t, config.GatewayConfig{}, provider)
+
+	req := &api.ChatRequest{
+		Model: "mock/model",
+		Messages: []api.ChatMessage{
+			{Role: "system", Content: api.Content{Text: "Be brief."}},
+			{Role: "user", Content: api.Content{Text: "Hi"}},
+		},
+	}
+	_, err := svc.Chat(context.Background(), req)
+	require.NoError(t, err)
+
+	sent := provider.lastRequest()
+	require.Len(t, sent.Messages, 2)
+	assert.Equal(t, "[INST]\nBe brief.\n[/INST]", sent.Messages[0].Content.Text)
+	assert.Equal(t, "Be brief.", req.Messages[0].Content.Text)
+}
+
+func TestChat_SystemDisclaimerAppliedOnce(t *testing.T) {
+	const disclaimer = "Responses are AI generated and not professional advice."
+	provider := &mockProvider{
+		id: "mock",
+		models: []api.ModelDefinition{{
+			ID: "mock/model", ProviderID: "mock", UpstreamID: "model",
+			Config: api.ModelConfig{SystemPrefix: "[INST]", SystemSuffix: "[/INST]"},
+		}},
+	}
+	svc, _ := newTestService(t, config.GatewayConfig{SystemDisclaimer: disclaimer}, provider)
+
+	// the first turn has no system prompt, one is injected
+	_, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "mock/model",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+	first := provider.lastRequest()
+	require.Len(t, first.Messages, 2)
+	assert.Equal(t, "[INST]\n"+disclaimer+"\n[/INST]", first.Messages[0].Content.Text)
+
+	// a later turn sends the history back with the disclaimer already in it
+	_, err = svc.Chat(context.Background(), &api.ChatRequest{
+		Model: "mock/model",
+		Messages: []api.ChatMessage{
+			{Role: "system", Content: api.Content{Text: disclaimer + "\nBe brief."}},
+			{Role: "user", Content: api.Content{Text: "Hi"}},
+			{Role: "assistant", Content: api.Content{Text: "Hello!"}},
+			{Role: "user", Content: api.Content{Text: "How are you?"}},
+		},
+	})
+	require.NoError(t, err)
+	sent := provider.lastRequest()
+	require.Len(t, sent.Messages, 4)
+	assert.Equal(t, "[INST]\n"+disclaimer+"\nBe brief.\n[/INST]", sent.Messages[0].Content.Text)
+
+	var count int
+	for _, m := range sent.Messages {
+		count += strings.Count(m.Content.Text, disclaimer)
+	}
+	assert.Equal(t, 1, count)
+}
+
+func TestChat_ImageCountOnlyForImageModels(t *testing.T) {
+	provider := &mockProvider{
+		id: "mock",
+		models: []api.ModelDefinition{
+			{ID: "mock/text", ProviderID: "mock", UpstreamID: "text"},
+			{ID: "mock/image", ProviderID: "mock", UpstreamID: "image",
+				Architecture: api.ModelArchitecture{OutputModalities: []string{"image"}}},
+		},
+	}
+	svc, _ := newTestService(t, config.GatewayConfig{}, provider)
+
+	for model, want := range map[string]int{"mock/text": 0, "mock/image": 3} {
+		_, err := svc.Chat(context.Background(), &api.ChatRequest{
+			Model:    model,
+			Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Draw a cat"}}},
+			N:        3,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, want, provider.lastRequest().N, model)
+	}
+}
+
+func TestChat_InfersMaxTokensFromRemainingContext(t *testing.T) {
+	p := &mockProvider{
+		id: "mock",
+		models: []api.ModelDefinition{
+			{ID: "mock/large", ProviderID: "mock", ContextLength: 128000},
+			{ID: "mock/small", ProviderID: "mock", ContextLength: 1200},
+			{ID: "mock/unknown", ProviderID: "mock"},
+		},
+	}
+	svc, _ := newTestService(t, config.GatewayConfig{InferMaxTokens: 1000}, p)
+
+	// about 1000 prompt tokens at four characters each
+	prompt := strings.Repeat("abcd", 1000)
+	chat := func(model string, maxTokens int) int {
+		_, err := svc.Chat(context.Background(), &api.ChatRequest{
+			Model:     model,
+			MaxTokens: maxTokens,
+			Messages:  []api.ChatMessage{{Role: "user", Content: api.Content{Text: prompt}}},
+		})
+		require.NoError(t, err)
+		return p.lastRequest().MaxTokens
+	}
+
+	assert.Equal(t, 1000, chat("mock/large", 0), "capped by the configured limit")
+	assert.Equal(t, 200, chat("mock/small", 0), "capped by the remaining context")
+	assert.Equal(t, 1000, chat("mock/unknown", 0), "no window to respect")
+	assert.Equal(t, 5000, chat("mock/small", 5000), "explicit values are kept")
+
+	// disabled by default
+	svc, _ = newTestService(t, config.GatewayConfig{}, p)
+	assert.Equal(t, 0, chat("mock/large", 0))
+}
+
+func TestSanitize_CacheControlOnlyForProvidersThatReadIt(t *testing.T) {
+	openai := &mockProvider{id: "openai", models: []api.ModelDefinition{{ID: "openai/model", ProviderID: "openai"}}}
+	claude := &mockProvider{id: "claude", models: []api.ModelDefinition{{ID: "claude/model", ProviderID: "claude"}}}
+	svc, _ := newTestService(t, config.GatewayConfig{}, openai)
+	require.NoError(t, svc.RegisterProvider(context.Background(), anthropicMock{claude}))
+
+	messages := []api.ChatMessage{{Role: "user", Content: api.Content{Parts: []api.ContentPart{
+		{Type: "text", Text: "<long context>", CacheControl: &api.CacheControl{Type: "ephemeral"}},
+		{Type: "text", Text: "Question"},
+	}}}}
+
+	_, err := svc.Chat(context.Background(), &api.ChatRequest{Model: "openai/model", Messages: messages})
+	require.NoError(t, err)
+	assert.Nil(t, openai.lastRequest().Messages[0].Content.Parts[0].CacheControl)
+	assert.NotNil(t, messages[0].Content.Parts[0].CacheControl, "the caller's request is left untouched")
+
+	_, err = svc.Chat(context.Background(), &api.ChatRequest{Model: "claude/model", Messages: messages})
+	require.NoError(t, err)
+	assert.NotNil(t, claude.lastRequest().Messages[0].Content.Parts[0].CacheControl)
+}
@@ -0,0 +1,94 @@
+package gateway
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/flags"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"go.uber.org/zap"
+)
+
+// defaultSeedRecordTTL applies when no record TTL is configured.
+const defaultSeedRecordTTL = 24 * time.Hour
+
+// seedRecord is the first completion seen for a seeded request.
+type seedRecord struct {
+	Hash        string `json:"hash"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	RequestID   string `json:"request_id"`
+}
+
+// promptHash hashes everything in the request that influences the output.
+// Routing and client bookkeeping fields are cleared so they do not count.
+func promptHash(req *api.ChatRequest) string {
+	r := *req
+	r.Model, r.Models, r.Route, r.Provider = "", nil, "", nil
+	r.Stream, r.StreamOptions = false, nil
+	r.User, r.Debug = "", nil
+
+	b, _ := json.Marshal(&r)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// completionHash hashes the content and tool calls of every choice.
+func completionHash(resp *api.ChatResponse) string {
+	h := sha256.New()
+	for _, choice := range resp.Choices {
+		if choice.Message == nil {
+			continue
+		}
+		b, _ := json.Marshal(struct {
+			Content   api.Content    `json:"content"`
+			ToolCalls []api.ToolCall `json:"tool_calls,omitempty"`
+		}{choice.Message.Content, choice.Message.ToolCalls})
+		h.Write(b)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func seedCacheKey(modelID string, req *api.ChatRequest) string {
+	return fmt.Sprintf("seed:%s:%d:%s", modelID, req.Seed, promptHash(req))
+}
+
+// verifySeed compares a seeded completion with the first one recorded for the
+// same model, seed and prompt, and warns when they differ. A change of the
+// upstream system fingerprint is expected to change the output, so the record
+// is replaced instead. It reports whether the completion diverged.
+func (s *service) verifySeed(ctx context.Context, modelID, requestID string, req *api.ChatRequest, resp *api.ChatResponse) bool {
+	if !s.featureEnabled(ctx, flags.VerifySeeds, modelID, s.config.VerifySeeds) || req.Seed == 0 || s.cache == nil {
+		return false
+	}
+
+	key := seedCacheKey(modelID, req)
+	current := seedRecord{Hash: completionHash(resp), Fingerprint: resp.SystemFingerprint, RequestID: requestID}
+
+	var recorded seedRecord
+	if err := s.cache.Get(ctx, key, &recorded); err == nil && recorded.Fingerprint == current.Fingerprint {
+		if recorded.Hash == current.Hash {
+			return false
+		}
+		s.logger.Warn("Seeded completion diverged from an earlier identical request",
+			zap.String("model", modelID),
+			zap.Int("seed", req.Seed),
+			zap.String("request_id", requestID),
+			zap.String("first_request_id", recorded.RequestID),
+			zap.String("system_fingerprint", current.Fingerprint),
+		)
+		return true
+	}
+
+	ttl := s.config.SeedRecordTTL
+	if ttl <= 0 {
+		ttl = defaultSeedRecordTTL
+	}
+	if err := s.cache.Set(ctx, key, current, ttl); err != nil {
+		s.logger.Warn("Failed to record seeded completion", zap.String("model", modelID), zap.Error(err))
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package gateway
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/store/cache"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func seededAnswer(text string) *api.ChatResponse {
+	return &api.ChatResponse{
+		SystemFingerprint: "fp_1",
+		Choices: []api.Choice{{
+			Message:      &api.ChatMessage{Role: "assistant", Content: api.Content{Text: text}},
+			FinishReason: "stop",
+		}},
+	}
+}
+
+func TestChat_DetectsSeedDivergence(t *testing.T) {
+	provider := &mockProvider{
+		id:      "mock",
+		models:  []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+		chatSeq: []*api.ChatResponse{seededAnswer("four"), seededAnswer("four"), seededAnswer("4")},
+	}
+	core, logs := observer.New(zap.WarnLevel)
+	svc, _ := newTestServiceWith(t, zap.New(core), newTestRepo(t), config.GatewayConfig{VerifySeeds: true}, provider)
+	svc.cache = cache.NewMemoryCache()
+
+	ask := func() {
+		_, err := svc.Chat(context.Background(), &api.ChatRequest{
+			Model:    "mock/model",
+			Seed:     42,
+			Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "2+2?"}}},
+		})
+		require.NoError(t, err)
+	}
+
+	ask()
+	ask()
+	assert.Zero(t, logs.FilterMessageSnippet("diverged").Len(), "identical completions are reproducible")
+
+	ask()
+	diverged := logs.FilterMessageSnippet("diverged").All()
+	require.Len(t, diverged, 1)
+	fields := diverged[0].ContextMap()
+	assert.Equal(t, "mock/model", fields["model"])
+	assert.EqualValues(t, 42, fields["seed"])
+}
+
+func TestPromptHash_IgnoresRouting(t *testing.T) {
+	req := &api.ChatRequest{Model: "a/model", Seed: 1, Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "hi"}}}}
+	routed := *req
+	routed.Model, routed.Stream, routed.User = "b/model", true, "someone"
+	assert.Equal(t, promptHash(req), promptHash(&routed))
+
+	changed := *req
+	changed.Temperature = 0.7
+	assert.NotEqual(t, promptHash(req), promptHash(&changed))
+}
@@ -0,0 +1,142 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/llm"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+const (
+	defaultSelfTestTimeout     = 15 * time.Second
+	defaultSelfTestConcurrency = 4
+
+	// selfTestPrompt is the canary sent to every provider, kept tiny so a run
+	// costs next to nothing.
+	selfTestPrompt    = "Reply with OK."
+	selfTestMaxTokens = 8
+)
+
+// SelfTestResult is the outcome of the canary request sent to one provider.
+type SelfTestResult struct {
+	ProviderID string `json:"provider_id"`
+	ModelID    string `json:"model_id,omitempty"`
+	Success    bool   `json:"success"`
+	LatencyMS  int64  `json:"latency_ms"`
+	CostMicros int64  `json:"cost_micros"`
+	Error      string `json:"error,omitempty"`
+}
+
+// SelfTest sends a tiny fixed prompt to the cheapest text model of every
+// registered provider, a few providers at a time, each bounded by the
+// configured timeout. Canaries bypass routing and are not logged as requests.
+func (s *service) SelfTest(ctx context.Context) []SelfTestResult {
+	s.mu.RLock()
+	providers := make([]llm.Provider, 0, len(s.providers))
+	for _, p := range s.providers {
+		providers = append(providers, p)
+	}
+	s.mu.RUnlock()
+
+	timeout, concurrency := s.config.SelfTestTimeout, s.config.SelfTestConcurrency
+	if timeout <= 0 {
+		timeout = defaultSelfTestTimeout
+	}
+	if concurrency <= 0 {
+		concurrency = defaultSelfTestConcurrency
+	}
+
+	results := make([]SelfTestResult, len(providers))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, p := range providers {
+		wg.Add(1)
+		go func(i int, p llm.Provider) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			testCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			results[i] = s.canary(testCtx, p)
+		}(i, p)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].ProviderID < results[j].ProviderID })
+	return results
+}
+
+func (s *service) canary(ctx context.Context, p llm.Provider) SelfTestResult {
+	result := SelfTestResult{ProviderID: p.Name()}
+
+	m, ok := s.cheapestTextModel(ctx, p.Name())
+	if !ok {
+		result.Error = "provider serves no text models"
+		return result
+	}
+	result.ModelID = m.ID
+
+	req := &api.ChatRequest{
+		Model:     m.UpstreamID,
+		MaxTokens: selfTestMaxTokens,
+		Messages:  []api.ChatMessage{{Role: "user", Content: api.Content{Text: selfTestPrompt}}},
+	}
+	if req.Model == "" {
+		req.Model = m.ID
+	}
+	s.sanitize(p, m.ID, req)
+
+	start := time.Now()
+	resp, err := p.Chat(ctx, req)
+	result.LatencyMS = time.Since(start).Milliseconds()
+	if err == nil && (resp == nil || len(resp.Choices) == 0) {
+		err = errors.New("provider returned no choices")
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Success = true
+	if pricing, err := s.repo.Providers().GetModelPricing(ctx, m.ID); err == nil && resp.Usage != nil {
+		result.CostMicros = costMicros(pricing, resp.Usage.PromptTokens, resp.Usage.CompletionTokens, resp.Usage.PromptTokensDetails)
+	}
+	return result
+}
+
+// cheapestTextModel returns the provider's model with the lowest stored
+// input plus output price. Unpriced models are only picked when none is
+// priced, and models that do not output text are skipped.
+func (s *service) cheapestTextModel(ctx context.Context, providerID string) (api.ModelDefinition, bool) {
+	models := s.registry.providerModels(providerID)
+	sort.Slice(models, func(i, j int) bool { return models[i].ID < models[j].ID })
+
+	var (
+		best      api.ModelDefinition
+		bestCost  int64
+		found     bool
+		bestPrice bool
+	)
+	for _, m := range models {
+		if out := m.Architecture.OutputModalities; len(out) > 0 && !slices.Contains(out, "text") {
+			continue
+		}
+
+		cost, priced := int64(0), false
+		if pricing, err := s.repo.Providers().GetModelPricing(ctx, m.ID); err == nil {
+			cost, priced = pricing.InputCostMicrosPer1k+pricing.OutputCostMicrosPer1k, true
+		}
+
+		switch {
+		case !found, priced && !bestPrice, priced == bestPrice && cost < bestCost:
+			best, bestCost, bestPrice, found = m, cost, priced, true
+		}
+	}
+	return best, found
+}
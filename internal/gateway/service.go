@@ -3,13 +3,21 @@ package gateway
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/nulzo/model-router-api/internal/analytics"
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/flags"
+	"github.com/nulzo/model-router-api/internal/httpclient"
 	"github.com/nulzo/model-router-api/internal/llm"
 	"github.com/nulzo/model-router-api/internal/platform/logger"
 	"github.com/nulzo/model-router-api/internal/store"
@@ -26,16 +34,50 @@ var (
 
 // Service defines the business logic for routing requests.
 type Service interface {
-	// RegisterProvider registers a new model provider and syncs its models
-	RegisterProvider(ctx context.Context, p llm.Provider) error
+	// RegisterProvider registers a new model provider and syncs its models.
+	// Aliases, such as a config ID differing from p.Name(), route to it too.
+	RegisterProvider(ctx context.Context, p llm.Provider, aliases ...string) error
+	// UnregisterProvider removes a provider along with its models and health
+	UnregisterProvider(providerID string)
+
+	// ApplyKeySettings applies the default model and parameter overrides of the calling API key
+	ApplyKeySettings(ctx context.Context, req *api.ChatRequest) error
 
 	GetProviderForModel(ctx context.Context, modelID string) (llm.Provider, string, error)
+	// GetModel returns the definition of a registered model
+	GetModel(modelID string) (api.ModelDefinition, bool)
+
+	// ModelAliases returns every model alias and the model it resolves to
+	ModelAliases() map[string]string
+	// SetModelAlias makes requests for alias go to modelID
+	SetModelAlias(alias, modelID string) error
+	// DeleteModelAlias removes a model alias, reporting whether it existed
+	DeleteModelAlias(alias string) bool
+
 	ListAllModels(ctx context.Context, filter api.ModelFilter) ([]api.Model, error)
 	Chat(ctx context.Context, req *api.ChatRequest) (*api.ChatResponse, error)
 	StreamChat(ctx context.Context, req *api.ChatRequest) (<-chan api.StreamResult, error)
+	// Speech synthesizes text with a model whose provider supports speech
+	Speech(ctx context.Context, req *api.SpeechRequest) (*api.SpeechResponse, error)
+	// Transcribe transcribes audio with a model whose provider supports transcription
+	Transcribe(ctx context.Context, req *api.TranscriptionRequest) (*api.TranscriptionResponse, error)
+
+	// CheckHealth refreshes the cached health of all registered providers
+	CheckHealth(ctx context.Context)
+	// StartHealthChecks runs CheckHealth in the background on the given interval
+	StartHealthChecks(ctx context.Context, interval time.Duration)
+	// HealthStatus returns the cached provider health used for routing
+	HealthStatus() []ProviderHealth
+	// Capabilities returns the feature matrix of every registered provider
+	Capabilities() []ProviderCapabilities
+	// StreamStats returns the number of open streams and the configured cap
+	StreamStats() StreamStats
+	// SelfTest sends a canary request through every registered provider
+	SelfTest(ctx context.Context) []SelfTestResult
 }
 
 type service struct {
+	config    config.GatewayConfig
 	logger    *zap.Logger
 	repo      store.Repository
 	ingestor  analytics.Ingestor
@@ -43,94 +85,215 @@ type service struct {
 	mu        sync.RWMutex
 	providers map[string]llm.Provider
 	registry  *registry
+	health    *healthCache
+	streams   atomic.Int64 // open streams
+	flags     *flags.Flags
+	auth      *authHealth
+	latency   *latencyTracker
+	cooldowns *cooldowns
+	gates     map[string]*providerGate // providers with a concurrency cap
+
+	shadowSlots chan struct{} // shadow requests in flight
+
+	preflightClient *http.Client
 }
 
-func NewService(logger *zap.Logger, repo store.Repository, ingestor analytics.Ingestor, cache cache.CacheService) Service {
+func NewService(logger *zap.Logger, repo store.Repository, ingestor analytics.Ingestor, cache cache.CacheService, cfg config.GatewayConfig) Service {
+	reg := newRegistry()
+	for alias, providerID := range cfg.ProviderAliases {
+		reg.addAlias(alias, providerID)
+	}
+	for _, r := range cfg.WeightedRoutes {
+		targets := make([]routeTarget, len(r.Targets))
+		for i, t := range r.Targets {
+			targets[i] = routeTarget{providerID: t.Provider, upstreamID: t.UpstreamID, weight: t.Weight}
+		}
+		reg.setRoute(r.Model, targets)
+	}
+	for _, r := range cfg.Routes {
+		route, err := newPatternRoute(r)
+		if err != nil {
+			logger.Warn("Ignoring pattern route", zap.String("target", r.TargetID), zap.Error(err))
+			continue
+		}
+		reg.addPatternRoute(route)
+	}
+	// providers register later, config aliases are not checked against their models
+	for _, a := range cfg.ModelAliases {
+		reg.setModelAlias(a.Alias, a.Model)
+	}
+
 	return &service{
+		config:    cfg,
 		logger:    logger,
 		repo:      repo,
 		ingestor:  ingestor,
 		cache:     cache,
 		providers: make(map[string]llm.Provider),
-		registry:  newRegistry(),
+		registry:  reg,
+		health:    newHealthCache(),
+		flags:     flags.New(cache, cfg.Flags),
+		auth:      newAuthHealth(),
+		latency:   newLatencyTracker(cfg.LatencyWindow),
+		cooldowns: newCooldowns(),
+		gates:     newGates(cfg.Admission),
+
+		shadowSlots: make(chan struct{}, maxShadowRequests),
+		// the plain transport, the webhook must not follow client base URL overrides
+		preflightClient: &http.Client{Transport: httpclient.Transport()},
 	}
 }
 
-func (s *service) RegisterProvider(ctx context.Context, p llm.Provider) error {
+func (s *service) RegisterProvider(ctx context.Context, p llm.Provider, aliases ...string) error {
 	models, _ := p.Models(ctx)
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	// p.Name() is the canonical identity, models naming an alias are indexed under it
+	for _, alias := range aliases {
+		s.registry.addAlias(alias, p.Name())
+	}
+	if _, replaced := s.providers[p.Name()]; replaced {
+		s.health.delete(p.Name())
+	}
 	s.providers[p.Name()] = p
 
+	// models of a replaced instance stay routable until the new ones are in,
+	// only those it no longer serves are dropped afterwards
+	served := make(map[string]bool, len(models))
 	for _, m := range models {
-		s.registry.addModel(m)
+		if !s.registry.addModel(p.Name(), m) {
+			s.logger.Warn("Model is pinned to another provider, ignoring",
+				zap.String("model", m.ID),
+				zap.String("provider", p.Name()),
+			)
+			continue
+		}
+		served[m.ID] = true
 	}
+	s.registry.removeStaleModels(p.Name(), served)
 
 	return nil
 }
 
+func (s *service) GetModel(modelID string) (api.ModelDefinition, bool) {
+	return s.registry.getModel(modelID)
+}
+
+func (s *service) UnregisterProvider(providerID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	providerID = s.registry.canonicalProviderID(providerID)
+	delete(s.providers, providerID)
+	s.registry.removeProvider(providerID)
+	s.health.delete(providerID)
+	s.auth.reset(providerID)
+}
+
 func (s *service) Chat(ctx context.Context, req *api.ChatRequest) (*api.ChatResponse, error) {
-	provider, upstreamModelID, err := s.GetProviderForModel(ctx, req.Model)
-	if err != nil {
+	if err := s.ApplyKeySettings(ctx, req); err != nil {
+		return nil, err
+	}
+	requestedModel := s.resolveModelAlias(req)
+	if err := s.applyAutoRouter(req); err != nil {
+		return nil, err
+	}
+	s.applyDefaultProvider(req)
+	// the webhook's changes go through every check below
+	if err := s.preflight(ctx, req); err != nil {
+		return nil, err
+	}
+	if err := checkModelAccess(ctx, req.Model); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkContentLimits(req); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkMonthlyBudget(ctx); err != nil {
 		return nil, err
 	}
 
-	reqClone := *req
-	reqClone.Model = upstreamModelID
+	if err := s.capTokensToBalance(ctx, req); err != nil {
+		return nil, err
+	}
 
 	u, err := uuid.NewRandom()
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate UUID: %v", err)
 	}
 
+	ctx = s.withCorrelation(ctx, u.String())
+	shadow := s.mirror(ctx, req, u.String())
+
 	start := time.Now()
-	resp, err := provider.Chat(ctx, &reqClone)
+	var resp *api.ChatResponse
+	var echo *api.DebugInfo
+	var hedged *hedgeOutcome
+	attempted := false
+	served, attempts, err := s.routeWithFallback(ctx, req, func(r route, upstreamReq *api.ChatRequest) error {
+		var callErr error
+		echo = s.debugEcho(ctx, req, upstreamReq)
+		// only the first attempt is hedged, later ones already follow a failure
+		if !attempted {
+			attempted = true
+			if hedge, ok := s.hedgeRoute(ctx, req, r); ok {
+				resp, hedged, callErr = s.hedgedChat(ctx, req, r, upstreamReq, hedge)
+				return callErr
+			}
+		}
+		resp, callErr = s.chatWithEmptyCheck(ctx, r.provider, upstreamReq)
+		return callErr
+	})
 	latency := time.Since(start)
+	if served.release != nil {
+		served.release()
+	}
 
-	var userID, apiKeyID, appName string
-	if val, ok := ctx.Value(store.ContextKeyAppName).(string); ok {
-		appName = val
+	// no candidate resolved to a provider, nothing was sent upstream
+	if served.provider == nil {
+		return nil, err
 	}
-	if apiKey, ok := ctx.Value(store.ContextKeyAPIKey).(*model.APIKey); ok {
-		userID = apiKey.UserID
-		apiKeyID = apiKey.ID
-	} else {
-		if appName != "" {
-			userID = string(api.Anonymous)
-			apiKeyID = string(api.Anonymous)
-		} else {
-			userID = string(api.System)
-			apiKeyID = string(api.System)
+	if hedged != nil {
+		attempts = hedged.attempts(attempts)
+		if hedged.hedgeWon {
+			served = hedged.hedge
 		}
 	}
+	provider, upstreamModelID := served.provider, served.upstreamModelID
+
+	userID, apiKeyID, appName := requestIdentity(ctx)
 
 	if err != nil {
-		statusCode := 500
-		finishReason := "error"
-		if errors.Is(err, context.Canceled) {
-			statusCode = 499
-			finishReason = "canceled"
+		log := failedRequestLog(ctx, req, u.String(), requestedModel, served, latency, err)
+		if hedged != nil {
+			s.logHedge(log, hedged)
+		}
+		s.withRouting(log, attempts)
+		withShadow(log, shadow)
+		s.ingestor.Log(log)
+		if fallback := s.fallbackResponse(req, u.String(), err); fallback != nil {
+			return fallback, nil
 		}
-
-		s.ingestor.Log(&model.RequestLog{
-			ID:              u.String(),
-			UserID:          userID,
-			APIKeyID:        apiKeyID,
-			AppName:         appName,
-			ProviderID:      provider.Name(),
-			ModelID:         req.Model,
-			UpstreamModelID: upstreamModelID,
-			FinishReason:    finishReason,
-			StatusCode:      statusCode,
-			LatencyMS:       latency.Milliseconds(),
-			IsStreamed:      false,
-			CreatedAt:       time.Now(),
-		})
 		return nil, fmt.Errorf("provider execution failed: %w", err)
 	}
 
+	if s.config.NormalizeResponses {
+		normalizeResponse(resp)
+	}
+	if sanitizeUTF8(resp) {
+		s.logger.Warn("Replaced invalid UTF-8 in provider response",
+			zap.String("provider", provider.Name()),
+			zap.String("model", served.modelID),
+		)
+	}
+	s.verifySeed(ctx, served.modelID, u.String(), req, resp)
+	// the serving attempt alone, without the candidates that failed before it
+	s.latency.record(provider.Name(), served.modelID, time.Duration(attempts[len(attempts)-1].LatencyMS)*time.Millisecond, 0)
+
 	finishReason := ""
 	if len(resp.Choices) > 0 {
 		finishReason = resp.Choices[0].FinishReason
@@ -141,8 +304,10 @@ func (s *service) Chat(ctx context.Context, req *api.ChatRequest) (*api.ChatResp
 		UserID:           userID,
 		APIKeyID:         apiKeyID,
 		AppName:          appName,
+		MetaJSON:         requestMeta(ctx, req, resp.Citations),
 		ProviderID:       provider.Name(),
-		ModelID:          req.Model,
+		RequestedModelID: requestedModel,
+		ModelID:          served.modelID,
 		UpstreamModelID:  upstreamModelID,
 		UpstreamRemoteID: resp.ID,
 		FinishReason:     finishReason,
@@ -153,87 +318,140 @@ func (s *service) Chat(ctx context.Context, req *api.ChatRequest) (*api.ChatResp
 	}
 
 	resp.ID = u.String()
+	if s.reportsServedModel(req, requestedModel) {
+		resp.Model = served.modelID
+	}
 
-	if resp.Usage != nil {
-		log.InputTokens = resp.Usage.PromptTokens
-		log.OutputTokens = resp.Usage.CompletionTokens
-		log.CachedTokens = 0 // Will be updated from details
-
-		details := &model.UsageDetails{
-			WebSearchRequests: 0, // Default
-		}
-
-		if resp.Usage.PromptTokensDetails != nil {
-			details.PromptTokensCached = resp.Usage.PromptTokensDetails.CachedTokens
-			details.PromptTokensCacheWrite = resp.Usage.PromptTokensDetails.CacheWriteTokens
-			details.PromptTokensAudio = resp.Usage.PromptTokensDetails.AudioTokens
-			details.PromptTokensVideo = resp.Usage.PromptTokensDetails.VideoTokens
-			log.CachedTokens = details.PromptTokensCached
-		}
-
-		if resp.Usage.CompletionTokensDetails != nil {
-			details.CompletionTokensReasoning = resp.Usage.CompletionTokensDetails.ReasoningTokens
-			details.CompletionTokensImage = resp.Usage.CompletionTokensDetails.ImageTokens
-		}
-
-		if resp.Usage.ServerToolUse != nil {
-			details.WebSearchRequests = resp.Usage.ServerToolUse.WebSearchRequests
-		}
-
-		if resp.Usage.CostDetails != nil {
-			details.UpstreamPromptCostMicros = int64(resp.Usage.CostDetails.UpstreamInferencePromptCost * 1000000)
-			details.UpstreamCompletionCostMicros = int64(resp.Usage.CostDetails.UpstreamInferenceCompletionCost * 1000000)
-			if resp.Usage.CostDetails.UpstreamInferenceCost != nil {
-				cost := int64(*resp.Usage.CostDetails.UpstreamInferenceCost * 1000000)
-				details.UpstreamCostMicros = &cost
-			}
-		}
-
-		if resp.Usage.IsBYOK != nil {
-			details.IsBYOK = *resp.Usage.IsBYOK
+	if s.config.PersistPrompts {
+		log.PromptJSON = marshalPrompt(req.Messages)
+		if len(resp.Choices) > 0 && resp.Choices[0].Message != nil {
+			log.Completion = resp.Choices[0].Message.Content.Text
+			log.Reasoning = resp.Choices[0].Message.Reasoning
 		}
-
-		log.UsageDetails = details
+	}
+	if !s.includeReasoning(req) {
+		stripReasoning(resp)
 	}
 
-	pricing, err := s.repo.Providers().GetModelPricing(context.Background(), req.Model)
-	if err == nil && pricing != nil && resp.Usage != nil {
-		inputCost := (int64(resp.Usage.PromptTokens) * pricing.InputCostMicrosPer1k) / 1000
-		outputCost := (int64(resp.Usage.CompletionTokens) * pricing.OutputCostMicrosPer1k) / 1000
-		log.TotalCostMicros = inputCost + outputCost
-
-		if log.UsageDetails != nil {
-			log.UsageDetails.CostMicros = &log.TotalCostMicros
-		}
+	if hedged != nil {
+		s.logHedge(log, hedged)
 	}
+	s.accountUsage(log, served.modelID, resp.Usage)
 
+	s.withRouting(log, attempts)
+	withShadow(log, shadow)
+	s.recordSpend(log)
 	s.ingestor.Log(log)
 
+	resp.Debug = echo
+	resp.Deprecation = s.deprecationWarning(served.modelID, apiKeyID)
 	return resp, nil
 }
 
-// GetProviderForModel finds the best provider for a given model ID and returns the provider and the upstream model ID
+// GetProviderForModel finds the best provider for a given model ID and returns the provider and the upstream model ID.
+// A model spread over several providers is sent to one of the usable ones, picked by weight.
 func (s *service) GetProviderForModel(ctx context.Context, modelID string) (llm.Provider, string, error) {
+	return s.resolveProvider(modelID, false, nil, nil)
+}
+
+// resolveProvider is GetProviderForModel for a request that is streamed or
+// not, which latency routing ranks providers by. A non-nil only limits the
+// pick to those providers, check rejects providers that can not serve the
+// request on top of checkProvider.
+func (s *service) resolveProvider(modelID string, stream bool, only []string, check func(providerID string) error) (llm.Provider, string, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	providerID, upstreamModelID, err := s.registry.ResolveRoute(modelID)
+	targets, err := s.registry.ResolveRoute(modelID)
 	if err != nil {
-		return nil, "", api.BadRequestError(fmt.Sprintf("route resolution failed for model '%s': %v", modelID, err))
+		fallthroughID := s.registry.canonicalProviderID(s.config.FallthroughProvider)
+		if _, ok := s.providers[fallthroughID]; s.config.FallthroughProvider == "" || !ok {
+			return nil, "", api.BadRequestError(fmt.Sprintf("route resolution failed for model '%s': %v", modelID, err))
+		}
+		// unknown models are sent upstream as requested, minus our own prefix
+		targets = []routeTarget{{
+			providerID: fallthroughID,
+			upstreamID: strings.TrimPrefix(modelID, s.config.FallthroughProvider+"/"),
+			weight:     1,
+		}}
+	}
+
+	// targets that can not take the request are left out of the pick, the
+	// first one's error is returned when none can
+	var usable []routeTarget
+	var firstErr error
+	for _, t := range targets {
+		if only != nil && !slices.Contains(only, t.providerID) {
+			continue
+		}
+		err := s.checkProvider(t.providerID)
+		if err == nil && check != nil {
+			err = check(t.providerID)
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		usable = append(usable, t)
+	}
+	if len(usable) == 0 {
+		return nil, "", firstErr
+	}
+	// degraded targets only serve when no healthy one can
+	if healthy := slices.DeleteFunc(slices.Clone(usable), func(t routeTarget) bool { return s.degraded(t.providerID) }); len(healthy) > 0 {
+		usable = healthy
 	}
 
-	if p, exists := s.providers[providerID]; exists {
-		return p, upstreamModelID, nil
+	var t routeTarget
+	if s.config.RoutingStrategy == RoutingLatency {
+		t = s.latency.fastest(modelID, usable, stream)
+	} else {
+		t = pickWeighted(usable)
 	}
+	return s.providers[t.providerID], t.upstreamID, nil
+}
 
-	return nil, "", api.ProviderError(fmt.Sprintf("provider '%s' configured but not active/loaded", providerID), nil)
+// checkProvider returns why providerID can not take a request right now, if
+// anything. Callers hold s.mu.
+func (s *service) checkProvider(providerID string) error {
+	p, exists := s.providers[providerID]
+	if !exists {
+		return api.ProviderError(fmt.Sprintf("provider '%s' configured but not active/loaded", providerID), nil)
+	}
+
+	// routing only consults the cached status, health checks never run on the hot path
+	if status, ok := s.health.get(providerID); ok && !status.Healthy {
+		return api.NewError(http.StatusServiceUnavailable, "Provider Unavailable",
+			fmt.Sprintf("provider '%s' is currently failing health checks", providerID),
+			api.WithExtension("provider", providerID),
+			api.WithExtension("checked_at", status.CheckedAt),
+		)
+	}
+	// the provider asked to be left alone for a while with its last 429
+	if until, ok := s.cooldowns.get(providerID); ok {
+		return api.NewError(http.StatusTooManyRequests, "Provider Rate Limited",
+			fmt.Sprintf("provider '%s' asked not to be retried before %s", providerID, until.UTC().Format(time.RFC3339)),
+			api.WithExtension("provider", providerID),
+			api.WithExtension("retry_after", int(time.Until(until).Seconds()+1)),
+		)
+	}
+	// a saturated provider would only queue the request, let routing move on
+	if load, ok := p.(llm.LoadReporter); ok && load.Saturated() {
+		return api.NewError(http.StatusServiceUnavailable, "Provider Saturated",
+			fmt.Sprintf("provider '%s' has no free capacity", providerID),
+			api.WithExtension("provider", providerID),
+		)
+	}
+	return nil
 }
 
 func (s *service) GetProvider(providerID string) (llm.Provider, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	if p, exists := s.providers[providerID]; exists {
+	if p, exists := s.providers[s.registry.canonicalProviderID(providerID)]; exists {
 		return p, nil
 	}
 
@@ -241,65 +459,168 @@ func (s *service) GetProvider(providerID string) (llm.Provider, error) {
 }
 
 func (s *service) StreamChat(ctx context.Context, req *api.ChatRequest) (<-chan api.StreamResult, error) {
-	provider, upstreamID, err := s.GetProviderForModel(ctx, req.Model)
-	if err != nil {
-		logger.Warn("Provider routing failed for stream", zap.String("model", req.Model), zap.Error(err))
+	if err := s.ApplyKeySettings(ctx, req); err != nil {
+		return nil, err
+	}
+	requestedModel := s.resolveModelAlias(req)
+	if err := s.applyAutoRouter(req); err != nil {
+		return nil, err
+	}
+	s.applyDefaultProvider(req)
+	// the webhook's changes go through every check below
+	if err := s.preflight(ctx, req); err != nil {
+		return nil, err
+	}
+	if err := checkModelAccess(ctx, req.Model); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkContentLimits(req); err != nil {
 		return nil, err
 	}
 
-	reqClone := *req
-	reqClone.Model = upstreamID
+	if err := s.checkMonthlyBudget(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := s.capTokensToBalance(ctx, req); err != nil {
+		return nil, err
+	}
+
+	requestID := uuid.NewString()
+	ctx = s.withCorrelation(ctx, requestID)
 
-	streamChan, err := provider.Stream(ctx, &reqClone)
+	if err := s.acquireStream(); err != nil {
+		return nil, err
+	}
+	shadow := s.mirror(ctx, req, requestID)
+
+	// raw passthrough chunks can not have their reasoning stripped
+	includeReasoning := s.includeReasoning(req)
+	passthrough := s.config.StreamPassthrough && includeReasoning
+
+	// only failures to open the stream fall back, once it is open the
+	// outcome is decided by what the provider sends
+	var streamChan <-chan api.StreamResult
+	var echo *api.DebugInfo
+	start := time.Now()
+	served, attempts, err := s.routeWithFallback(ctx, req, func(r route, upstreamReq *api.ChatRequest) error {
+		var callErr error
+		echo = s.debugEcho(ctx, req, upstreamReq)
+		if raw, ok := r.provider.(llm.RawStreamer); ok && passthrough {
+			streamChan, callErr = raw.StreamRaw(ctx, upstreamReq)
+		} else {
+			streamChan, callErr = r.provider.Stream(ctx, upstreamReq)
+		}
+		return callErr
+	})
 	if err != nil {
+		s.releaseStream()
+		logger.Warn("Provider routing failed for stream", zap.String("model", req.Model), zap.Error(err))
+		if served.provider == nil {
+			return nil, err
+		}
+		// a stream that never opened is logged like a failed unary request,
+		// also when the fallback answers in its place
+		log := failedRequestLog(ctx, req, requestID, requestedModel, served, time.Since(start), err)
+		log.IsStreamed = true
+		s.withRouting(log, attempts)
+		withShadow(log, shadow)
+		s.ingestor.Log(log)
+		if fallback := s.fallbackResponse(req, requestID, err); fallback != nil {
+			return fallbackStream(fallback), nil
+		}
 		return nil, err
 	}
+	provider, upstreamID := served.provider, served.upstreamModelID
 
 	// Intercept stream for logging
 	outChan := make(chan api.StreamResult)
 
 	go func() {
 		defer close(outChan)
+		defer s.releaseStream() // before close, so drained streams are no longer counted
+		defer served.release()
 
 		start := time.Now()
 		var ttft *time.Duration
-		var inputTokens, outputTokens int
 		var finalUsage *api.ResponseUsage
 		var finishReason string
 		var lastID string
+		var citations []string
+		var aggregate streamAggregator
+		var streamErr error
+		var sanitized bool // invalid UTF-8 is only logged once per stream
+		imageIndex := imageIndexes{}
+		chunk := chunkDefaults{
+			Object:  s.config.StreamChunkObject,
+			Created: start.Unix(),
+			Model:   served.modelID,
+		}
 
 		// Capture identity context before loop (context might be cancelled but values persist)
-		var userID, apiKeyID, appName string
-		if val, ok := ctx.Value(store.ContextKeyAppName).(string); ok {
-			appName = val
-		}
-		if apiKey, ok := ctx.Value(store.ContextKeyAPIKey).(*model.APIKey); ok {
-			userID = apiKey.UserID
-			apiKeyID = apiKey.ID
-		} else {
-			if appName != "" {
-				userID = string(api.Anonymous)
-				apiKeyID = string(api.Anonymous)
-			} else {
-				userID = string(api.System)
-				apiKeyID = string(api.System)
+		userID, apiKeyID, appName := requestIdentity(ctx)
+
+		// the debug echo and deprecation warning go out ahead of the provider's chunks
+		deprecation := s.deprecationWarning(served.modelID, apiKeyID)
+		if echo != nil || deprecation != nil {
+			select {
+			case outChan <- api.StreamResult{Response: &api.ChatResponse{
+				Object:      "chat.completion.chunk",
+				Created:     chunk.Created,
+				Model:       served.modelID,
+				Choices:     []api.Choice{},
+				Debug:       echo,
+				Deprecation: deprecation,
+			}}:
+			case <-ctx.Done():
 			}
 		}
 
 		for result := range streamChan {
 			// Record TTFT on first successful token
-			if ttft == nil && result.Response != nil {
+			if ttft == nil && result.Response != nil && !progressOnly(result.Response) {
 				dur := time.Since(start)
 				ttft = &dur
 			}
 
 			if result.Response != nil {
+				var replaced bool
+				if result.Raw != nil {
+					result.Raw, replaced = sanitizeRawUTF8(result.Raw)
+				} else {
+					replaced = sanitizeUTF8(result.Response)
+				}
+				if replaced && !sanitized {
+					sanitized = true
+					s.logger.Warn("Replaced invalid UTF-8 in provider stream",
+						zap.String("provider", provider.Name()),
+						zap.String("model", served.modelID),
+					)
+				}
+
+				// passthrough chunks are sent as is, their Response is only a tap
+				if s.config.NormalizeResponses && result.Raw == nil {
+					normalizeResponse(result.Response)
+					fillChunkDefaults(result.Response, &chunk)
+				}
+				if s.reportsServedModel(req, requestedModel) && result.Raw == nil {
+					result.Response.Model = served.modelID
+				}
 				lastID = result.Response.ID
+				if len(result.Response.Citations) > 0 {
+					citations = result.Response.Citations
+				}
+
+				if s.config.PersistPrompts {
+					aggregate.add(result.Response)
+				}
+				if !includeReasoning {
+					stripReasoning(result.Response)
+				}
 
 				// Capture usage if provided (some providers send it in last chunk)
 				if result.Response.Usage != nil {
-					inputTokens = result.Response.Usage.PromptTokens
-					outputTokens = result.Response.Usage.CompletionTokens
 					finalUsage = result.Response.Usage
 				}
 
@@ -311,15 +632,42 @@ func (s *service) StreamChat(ctx context.Context, req *api.ChatRequest) (<-chan
 				}
 			}
 
+			if result.Err != nil {
+				streamErr = result.Err
+			}
+
+			if result.Response != nil && result.Raw == nil && s.config.StreamImageChunkBytes > 0 {
+				chunks := chunkImages(result.Response, s.config.StreamImageChunkBytes, imageIndex)
+				for _, c := range chunks[:len(chunks)-1] {
+					select {
+					case outChan <- api.StreamResult{Response: c}:
+					case <-ctx.Done():
+						goto finalize
+					}
+				}
+				result.Response = chunks[len(chunks)-1]
+			}
+
 			select {
 			case outChan <- result:
 			case <-ctx.Done():
 				// Stop sending tokens if client disconnected
 				goto finalize
 			}
+
+			// the client stops reading after an error, log what was generated so far
+			if streamErr != nil {
+				goto finalize
+			}
 		}
 
 	finalize:
+		// unblock the provider in case we stopped reading early
+		go func() {
+			for range streamChan {
+			}
+		}()
+
 		// Log after stream closes
 		latency := time.Since(start)
 		var ttftMS sql.NullInt64
@@ -328,20 +676,39 @@ func (s *service) StreamChat(ctx context.Context, req *api.ChatRequest) (<-chan
 		}
 
 		statusCode := 200
-		if ctx.Err() != nil {
+		var errorMessage string
+		if streamErr != nil {
+			statusCode = errorStatus(streamErr)
+			finishReason = string(api.FinishReasonError)
+			errorMessage = streamErr.Error()
+		} else if ctx.Err() != nil {
 			statusCode = 499
 			if finishReason == "" {
-				finishReason = "canceled"
+				finishReason = string(api.FinishReasonClientDisconnect)
+			}
+			// the handler gave up waiting on the provider
+			if cause := context.Cause(ctx); errorStatus(cause) == http.StatusGatewayTimeout {
+				statusCode = http.StatusGatewayTimeout
+				finishReason = string(api.FinishReasonTimeout)
+				errorMessage = cause.Error()
 			}
 		}
 
+		logID := lastID
+		if logID == "" {
+			// the stream failed before the provider sent an ID
+			logID = requestID
+		}
+
 		log := &model.RequestLog{
-			ID:               lastID, // Might be empty if stream failed immediately
+			ID:               logID,
 			UserID:           userID,
 			APIKeyID:         apiKeyID,
 			AppName:          appName,
+			MetaJSON:         requestMeta(ctx, req, citations),
 			ProviderID:       provider.Name(),
-			ModelID:          req.Model,
+			RequestedModelID: requestedModel,
+			ModelID:          served.modelID,
 			UpstreamModelID:  upstreamID,
 			UpstreamRemoteID: lastID,
 			FinishReason:     finishReason,
@@ -350,59 +717,97 @@ func (s *service) StreamChat(ctx context.Context, req *api.ChatRequest) (<-chan
 			TTFTMS:           ttftMS,
 			IsStreamed:       true,
 			CreatedAt:        time.Now(),
-			InputTokens:      inputTokens,
-			OutputTokens:     outputTokens,
+			ErrorMessage:     errorMessage,
 		}
 
-		if finalUsage != nil {
-			details := &model.UsageDetails{}
-			if finalUsage.PromptTokensDetails != nil {
-				details.PromptTokensCached = finalUsage.PromptTokensDetails.CachedTokens
-				details.PromptTokensCacheWrite = finalUsage.PromptTokensDetails.CacheWriteTokens
-				details.PromptTokensAudio = finalUsage.PromptTokensDetails.AudioTokens
-				details.PromptTokensVideo = finalUsage.PromptTokensDetails.VideoTokens
-				log.CachedTokens = details.PromptTokensCached
-			}
-			if finalUsage.CompletionTokensDetails != nil {
-				details.CompletionTokensReasoning = finalUsage.CompletionTokensDetails.ReasoningTokens
-				details.CompletionTokensImage = finalUsage.CompletionTokensDetails.ImageTokens
-			}
-			if finalUsage.ServerToolUse != nil {
-				details.WebSearchRequests = finalUsage.ServerToolUse.WebSearchRequests
-			}
-			if finalUsage.CostDetails != nil {
-				details.UpstreamPromptCostMicros = int64(finalUsage.CostDetails.UpstreamInferencePromptCost * 1000000)
-				details.UpstreamCompletionCostMicros = int64(finalUsage.CostDetails.UpstreamInferenceCompletionCost * 1000000)
-				if finalUsage.CostDetails.UpstreamInferenceCost != nil {
-					cost := int64(*finalUsage.CostDetails.UpstreamInferenceCost * 1000000)
-					details.UpstreamCostMicros = &cost
-				}
-			}
-			if finalUsage.IsBYOK != nil {
-				details.IsBYOK = *finalUsage.IsBYOK
-			}
-			log.UsageDetails = details
+		if s.config.PersistPrompts {
+			log.PromptJSON = marshalPrompt(req.Messages)
+			log.Completion = aggregate.content()
+			log.Reasoning = aggregate.reasoning()
 		}
 
 		if log.ID == "" {
 			log.ID = fmt.Sprintf("stream-fail-%d", time.Now().UnixNano())
-			log.StatusCode = 500
+			if log.StatusCode == 200 {
+				log.StatusCode = 500
+			}
 		}
 
-		// Calculate cost
-		pricing, err := s.repo.Providers().GetModelPricing(context.Background(), req.Model)
-		if err == nil && pricing != nil {
-			inputCost := (int64(inputTokens) * pricing.InputCostMicrosPer1k) / 1000
-			outputCost := (int64(outputTokens) * pricing.OutputCostMicrosPer1k) / 1000
-			log.TotalCostMicros = inputCost + outputCost
-
-			if log.UsageDetails != nil {
-				log.UsageDetails.CostMicros = &log.TotalCostMicros
-			}
+		// the serving attempt only opened the stream, record how it ended
+		last := &attempts[len(attempts)-1]
+		last.StatusCode = log.StatusCode
+		last.ErrorMessage = errorMessage
+		last.LatencyMS = time.Since(last.CreatedAt).Milliseconds()
+		s.withRouting(log, attempts)
+		withShadow(log, shadow)
+		if log.StatusCode == http.StatusOK && ttft != nil {
+			s.latency.record(provider.Name(), served.modelID, latency, *ttft)
 		}
 
+		s.accountUsage(log, served.modelID, finalUsage)
+		s.recordSpend(log)
 		s.ingestor.Log(log)
 	}()
 
 	return outChan, nil
 }
+
+// marshalPrompt serializes the request messages for prompt persistence.
+func marshalPrompt(messages []api.ChatMessage) string {
+	data, err := json.Marshal(messages)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// failedRequestLog is the log of a request that failed on served after
+// latency, without usage.
+func failedRequestLog(ctx context.Context, req *api.ChatRequest, id, requestedModel string, served route, latency time.Duration, err error) *model.RequestLog {
+	statusCode, finishReason := errorStatus(err), api.FinishReasonError
+	switch {
+	case errors.Is(err, context.Canceled):
+		statusCode = 499
+		finishReason = api.FinishReasonClientDisconnect
+	case errors.Is(err, context.DeadlineExceeded):
+		finishReason = api.FinishReasonTimeout
+	}
+
+	userID, apiKeyID, appName := requestIdentity(ctx)
+	log := &model.RequestLog{
+		ID:               id,
+		UserID:           userID,
+		APIKeyID:         apiKeyID,
+		AppName:          appName,
+		MetaJSON:         requestMeta(ctx, req, nil),
+		ProviderID:       served.provider.Name(),
+		RequestedModelID: requestedModel,
+		ModelID:          served.modelID,
+		UpstreamModelID:  served.upstreamModelID,
+		FinishReason:     string(finishReason),
+		StatusCode:       statusCode,
+		LatencyMS:        latency.Milliseconds(),
+		CreatedAt:        time.Now(),
+	}
+	if statusCode != 499 {
+		log.ErrorMessage = err.Error()
+	}
+	return log
+}
+
+// errorStatus maps an upstream failure to the status code recorded in the request log.
+func errorStatus(err error) int {
+	var problem *api.Problem
+	if errors.As(err, &problem) {
+		return problem.Status
+	}
+	var appErr *api.Error
+	if errors.As(err, &appErr) {
+		return appErr.Code
+	}
+	var upstreamErr *httpclient.UpstreamError
+	if errors.As(err, &upstreamErr) {
+		return upstreamErr.StatusCode
+	}
+	return http.StatusBadGateway
+}
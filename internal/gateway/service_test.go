@@ -0,0 +1,2079 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/httpclient"
+	"github.com/nulzo/model-router-api/internal/llm/openai"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/internal/store/sqlite"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// --- Mocks ---
+
+type mockProvider struct {
+	id         string
+	chatResp   *api.ChatResponse
+	chatSeq    []*api.ChatResponse // consumed in order before falling back to chatResp
+	chatErr    error
+	streamResp []api.StreamResult
+	streamErr  error // returned by Stream instead of opening the stream
+	models     []api.ModelDefinition
+	healthErr  error
+
+	mu          sync.Mutex
+	requests    []api.ChatRequest
+	healthCalls int
+}
+
+func (m *mockProvider) Name() string { return m.id }
+func (m *mockProvider) Type() string { return "mock" }
+
+func (m *mockProvider) Chat(ctx context.Context, req *api.ChatRequest) (*api.ChatResponse, error) {
+	m.record(req)
+	if m.chatErr != nil {
+		return nil, m.chatErr
+	}
+	m.mu.Lock()
+	if len(m.chatSeq) > 0 {
+		resp := m.chatSeq[0]
+		m.chatSeq = m.chatSeq[1:]
+		m.mu.Unlock()
+		return resp, nil
+	}
+	m.mu.Unlock()
+	if m.chatResp != nil {
+		resp := *m.chatResp
+		return &resp, nil
+	}
+	return &api.ChatResponse{
+		ID:     "mock-id",
+		Object: "chat.completion",
+		Choices: []api.Choice{
+			{Message: &api.ChatMessage{Role: "assistant", Content: api.Content{Text: "Mock Response"}}, FinishReason: "stop"},
+		},
+	}, nil
+}
+
+func (m *mockProvider) Stream(ctx context.Context, req *api.ChatRequest) (<-chan api.StreamResult, error) {
+	m.record(req)
+	if m.streamErr != nil {
+		return nil, m.streamErr
+	}
+	ch := make(chan api.StreamResult)
+	go func() {
+		defer close(ch)
+		for _, r := range m.streamResp {
+			ch <- r
+		}
+	}()
+	return ch, nil
+}
+
+func (m *mockProvider) Models(ctx context.Context) ([]api.ModelDefinition, error) {
+	return m.models, nil
+}
+
+func (m *mockProvider) Health(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.healthCalls++
+	return m.healthErr
+}
+
+func (m *mockProvider) record(req *api.ChatRequest) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, *req)
+}
+
+func (m *mockProvider) lastRequest() api.ChatRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.requests[len(m.requests)-1]
+}
+
+// captureIngestor records logs synchronously so tests can inspect them.
+type captureIngestor struct {
+	mu   sync.Mutex
+	logs []*model.RequestLog
+}
+
+func (c *captureIngestor) Log(log *model.RequestLog) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.logs = append(c.logs, log)
+}
+
+func (c *captureIngestor) Start(ctx context.Context) {}
+func (c *captureIngestor) Stop()                     {}
+
+func (c *captureIngestor) last(t *testing.T) *model.RequestLog {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	require.NotEmpty(t, c.logs, "expected a request log to be written")
+	return c.logs[len(c.logs)-1]
+}
+
+// --- Helpers ---
+
+func newTestRepo(t *testing.T) store.Repository {
+	repo, err := sqlite.NewSQLiteStorage(":memory:", zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = repo.Close() })
+	return repo
+}
+
+func newTestService(t *testing.T, cfg config.GatewayConfig, providers ...*mockProvider) (*service, *captureIngestor) {
+	return newTestServiceWith(t, zap.NewNop(), newTestRepo(t), cfg, providers...)
+}
+
+func newTestServiceWith(t *testing.T, logger *zap.Logger, repo store.Repository, cfg config.GatewayConfig, providers ...*mockProvider) (*service, *captureIngestor) {
+	ingestor := &captureIngestor{}
+	svc := NewService(logger, repo, ingestor, nil, cfg).(*service)
+
+	for _, p := range providers {
+		require.NoError(t, svc.RegisterProvider(context.Background(), p))
+	}
+
+	return svc, ingestor
+}
+
+func drain(t *testing.T, ch <-chan api.StreamResult) []api.StreamResult {
+	var results []api.StreamResult
+	for r := range ch {
+		results = append(results, r)
+	}
+	return results
+}
+
+func textDelta(content, reasoning string) api.StreamResult {
+	return api.StreamResult{Response: &api.ChatResponse{
+		ID: "upstream-id",
+		Choices: []api.Choice{{
+			Delta: &api.ChatMessage{Content: api.Content{Text: content}, Reasoning: reasoning},
+		}},
+	}}
+}
+
+// --- Tests ---
+
+func TestStreamChat_PersistsAssembledCompletion(t *testing.T) {
+	provider := &mockProvider{
+		id:     "mock",
+		models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+		streamResp: []api.StreamResult{
+			textDelta("", "Let me think"),
+			textDelta("", " about it."),
+			textDelta("Hello", ""),
+			textDelta(", world", ""),
+			textDelta("!", ""),
+		},
+	}
+	svc, ingestor := newTestService(t, config.GatewayConfig{PersistPrompts: true}, provider)
+
+	ch, err := svc.StreamChat(context.Background(), &api.ChatRequest{
+		Model:    "mock/model",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Say hello"}}},
+	})
+	require.NoError(t, err)
+
+	var want string
+	for _, r := range drain(t, ch) {
+		want += r.Response.Choices[0].Delta.Content.Text
+	}
+
+	log := ingestor.last(t)
+	assert.Equal(t, want, log.Completion)
+	assert.Equal(t, "Hello, world!", log.Completion)
+	assert.Equal(t, "Let me think about it.", log.Reasoning)
+	assert.Contains(t, log.PromptJSON, "Say hello")
+}
+
+func TestStreamChat_DoesNotPersistWhenDisabled(t *testing.T) {
+	provider := &mockProvider{
+		id:         "mock",
+		models:     []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+		streamResp: []api.StreamResult{textDelta("Hello", "")},
+	}
+	svc, ingestor := newTestService(t, config.GatewayConfig{}, provider)
+
+	ch, err := svc.StreamChat(context.Background(), &api.ChatRequest{
+		Model:    "mock/model",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Say hello"}}},
+	})
+	require.NoError(t, err)
+	drain(t, ch)
+
+	log := ingestor.last(t)
+	assert.Empty(t, log.Completion)
+	assert.Empty(t, log.PromptJSON)
+}
+
+func TestChat_UsesKeyDefaultModel(t *testing.T) {
+	provider := &mockProvider{
+		id:     "mock",
+		models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+	}
+	svc, ingestor := newTestService(t, config.GatewayConfig{}, provider)
+
+	key := &model.APIKey{
+		ID:           "key-1",
+		UserID:       "user-1",
+		SettingsJSON: `{"default_model": "mock/model", "overrides": {"temperature": 0.2}}`,
+	}
+	ctx := context.WithValue(context.Background(), store.ContextKeyAPIKey, key)
+
+	_, err := svc.Chat(ctx, &api.ChatRequest{
+		Temperature: 1.5,
+		Messages:    []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+
+	sent := provider.lastRequest()
+	assert.Equal(t, "model", sent.Model)
+	assert.Equal(t, 0.2, sent.Temperature)
+	assert.Equal(t, "mock/model", ingestor.last(t).ModelID)
+}
+
+func TestChat_RejectsPartOverCap(t *testing.T) {
+	provider := &mockProvider{
+		id:     "mock",
+		models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+	}
+	svc, _ := newTestService(t, config.GatewayConfig{MaxPartChars: 10, MaxMessageChars: 100}, provider)
+
+	_, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Model: "mock/model",
+		Messages: []api.ChatMessage{
+			{Role: "system", Content: api.Content{Text: "Be brief."}},
+			{Role: "user", Content: api.Content{Parts: []api.ContentPart{
+				{Type: "text", Text: "short"},
+				{Type: "text", Text: "this part is far too long"},
+			}}},
+		},
+	})
+
+	var problem *api.Problem
+	require.ErrorAs(t, err, &problem)
+	assert.Equal(t, 400, problem.Status)
+	assert.Equal(t, 1, problem.Extensions["message_index"])
+	assert.Equal(t, 1, problem.Extensions["part_index"])
+	assert.Contains(t, problem.Detail, "messages[1].content[1]")
+	assert.Empty(t, provider.requests, "request should not reach the provider")
+}
+
+func TestChat_RejectsMessageOverCap(t *testing.T) {
+	provider := &mockProvider{
+		id:     "mock",
+		models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+	}
+	svc, _ := newTestService(t, config.GatewayConfig{MaxPartChars: 10, MaxMessageChars: 15}, provider)
+
+	_, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Model: "mock/model",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Parts: []api.ContentPart{
+			{Type: "text", Text: "0123456789"},
+			{Type: "text", Text: "0123456789"},
+		}}}},
+	})
+
+	var problem *api.Problem
+	require.ErrorAs(t, err, &problem)
+	assert.Equal(t, 400, problem.Status)
+	assert.Equal(t, 0, problem.Extensions["message_index"])
+	assert.NotContains(t, problem.Extensions, "part_index")
+}
+
+func weatherTool(name string) api.Tool {
+	return api.Tool{Type: "function", Function: api.FunctionDescription{
+		Name: name,
+		Parameters: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"city": map[string]interface{}{"type": "string"}},
+			"required":   []interface{}{"city"},
+		},
+	}}
+}
+
+func TestChat_RejectsTooManyTools(t *testing.T) {
+	provider := &mockProvider{
+		id:     "mock",
+		models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+	}
+	svc, _ := newTestService(t, config.GatewayConfig{MaxTools: 2}, provider)
+
+	_, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "mock/model",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+		Tools:    []api.Tool{weatherTool("a"), weatherTool("b"), weatherTool("c")},
+	})
+
+	var problem *api.Problem
+	require.ErrorAs(t, err, &problem)
+	assert.Equal(t, 400, problem.Status)
+	assert.Equal(t, 2, problem.Extensions["limit"])
+	assert.Contains(t, problem.Detail, "3 tools")
+	assert.Empty(t, provider.requests, "request should not reach the provider")
+}
+
+func TestChat_RejectsToolSchemaOverCap(t *testing.T) {
+	provider := &mockProvider{
+		id:     "mock",
+		models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+	}
+	svc, _ := newTestService(t, config.GatewayConfig{MaxToolSchemaBytes: 100}, provider)
+
+	_, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "mock/model",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+		Tools:    []api.Tool{weatherTool("get_weather"), weatherTool("get_forecast")},
+	})
+
+	var problem *api.Problem
+	require.ErrorAs(t, err, &problem)
+	assert.Equal(t, 400, problem.Status)
+	assert.Contains(t, problem.Detail, "exceeding the limit of 100")
+}
+
+func TestChat_RejectsInvalidToolSchema(t *testing.T) {
+	provider := &mockProvider{
+		id:     "mock",
+		models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+	}
+	svc, _ := newTestService(t, config.GatewayConfig{}, provider)
+
+	invalid := map[string]api.Tool{
+		"unsupported type":  {Type: "retrieval", Function: api.FunctionDescription{Name: "f"}},
+		"missing name":      {Type: "function"},
+		"non-object root":   {Type: "function", Function: api.FunctionDescription{Name: "f", Parameters: map[string]interface{}{"type": "string"}}},
+		"invalid root type": {Type: "function", Function: api.FunctionDescription{Name: "f", Parameters: map[string]interface{}{"type": 42}}},
+	}
+
+	for name, tool := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := svc.Chat(context.Background(), &api.ChatRequest{
+				Model:    "mock/model",
+				Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+				Tools:    []api.Tool{weatherTool("ok"), tool},
+			})
+
+			var problem *api.Problem
+			require.ErrorAs(t, err, &problem)
+			assert.Equal(t, 400, problem.Status)
+			assert.Equal(t, 1, problem.Extensions["tool_index"])
+		})
+	}
+	assert.Empty(t, provider.requests, "request should not reach the provider")
+
+	// schemas without a root type are left to the provider
+	for _, params := range []map[string]interface{}{
+		{"type": "object", "required": []interface{}{"city"}},
+		{"properties": map[string]interface{}{"city": map[string]interface{}{"type": "string"}}},
+		{"$ref": "#/$defs/query", "$defs": map[string]interface{}{"query": map[string]interface{}{"type": "object"}}},
+		{"anyOf": []interface{}{map[string]interface{}{"type": "object"}}},
+	} {
+		_, err := svc.Chat(context.Background(), &api.ChatRequest{
+			Model:    "mock/model",
+			Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+			Tools:    []api.Tool{{Type: "function", Function: api.FunctionDescription{Name: "f", Parameters: params}}},
+		})
+		require.NoError(t, err, params)
+	}
+}
+
+func TestRouting_UsesCachedHealth(t *testing.T) {
+	provider := &mockProvider{
+		id:        "mock",
+		models:    []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+		healthErr: errors.New("upstream down"),
+	}
+	svc, _ := newTestService(t, config.GatewayConfig{}, provider)
+	req := func() *api.ChatRequest {
+		return &api.ChatRequest{
+			Model:    "mock/model",
+			Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+		}
+	}
+
+	// no status cached yet, the provider is assumed healthy
+	_, err := svc.Chat(context.Background(), req())
+	require.NoError(t, err)
+
+	svc.CheckHealth(context.Background())
+	require.Equal(t, 1, provider.healthCalls)
+
+	_, err = svc.Chat(context.Background(), req())
+	var problem *api.Problem
+	require.ErrorAs(t, err, &problem)
+	assert.Equal(t, 503, problem.Status)
+
+	// recovery is only picked up by the next check, not by the router
+	provider.healthErr = nil
+	_, err = svc.Chat(context.Background(), req())
+	require.Error(t, err)
+
+	svc.CheckHealth(context.Background())
+	_, err = svc.Chat(context.Background(), req())
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, provider.healthCalls, "routing must not invoke Health")
+
+	statuses := svc.HealthStatus()
+	require.Len(t, statuses, 1)
+	assert.True(t, statuses[0].Healthy)
+}
+
+func TestRouting_PinnedModelKeepsItsProvider(t *testing.T) {
+	pinned := &mockProvider{
+		id:     "openai-eu",
+		models: []api.ModelDefinition{{ID: "openai/gpt-4o", ProviderID: "openai-eu", UpstreamID: "gpt-4o"}},
+	}
+	// a second instance discovers the same model upstream and could serve it
+	other := &mockProvider{
+		id:     "openai-us",
+		models: []api.ModelDefinition{{ID: "openai/gpt-4o", ProviderID: "openai-us", UpstreamID: "gpt-4o", Source: "auto"}},
+	}
+	svc, _ := newTestService(t, config.GatewayConfig{}, pinned, other)
+
+	for i := 0; i < 3; i++ {
+		p, upstream, err := svc.GetProviderForModel(context.Background(), "openai/gpt-4o")
+		require.NoError(t, err)
+		assert.Equal(t, "openai-eu", p.Name())
+		assert.Equal(t, "gpt-4o", upstream)
+	}
+
+	_, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "openai/gpt-4o",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+	assert.Len(t, pinned.requests, 1)
+	assert.Empty(t, other.requests)
+}
+
+func TestUsageAccountingMatchesAcrossStreaming(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	require.NoError(t, repo.Providers().SyncProviders(ctx, []model.Provider{{ID: "mock", Name: "mock", ConfigJSON: "{}", IsEnabled: true}}))
+	require.NoError(t, repo.Providers().SyncModels(ctx, []model.Model{{
+		ID: "mock/model", ProviderID: "mock", ProviderModelID: "model", IsEnabled: true,
+		InputCostMicrosPer1k: 1000, OutputCostMicrosPer1k: 2000, CacheReadCostMicrosPer1k: 100,
+	}}))
+
+	cost := 0.0031
+	usage := func() *api.ResponseUsage {
+		return &api.ResponseUsage{
+			PromptTokens: 1200, CompletionTokens: 900, TotalTokens: 2100, Cost: &cost,
+			PromptTokensDetails:     &api.PromptTokensDetails{CachedTokens: 400},
+			CompletionTokensDetails: &api.CompletionTokensDetails{ReasoningTokens: 300},
+		}
+	}
+	provider := &mockProvider{
+		id:     "mock",
+		models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+		chatResp: &api.ChatResponse{
+			ID:      "upstream-id",
+			Choices: []api.Choice{{Message: &api.ChatMessage{Role: "assistant", Content: api.Content{Text: "Hi"}}, FinishReason: "stop"}},
+			Usage:   usage(),
+		},
+		streamResp: []api.StreamResult{
+			textDelta("Hi", ""),
+			// usage arrives on its own final chunk
+			{Response: &api.ChatResponse{ID: "upstream-id", Choices: []api.Choice{{Delta: &api.ChatMessage{}, FinishReason: "stop"}}, Usage: usage()}},
+		},
+	}
+	svc, ingestor := newTestServiceWith(t, zap.NewNop(), repo, config.GatewayConfig{}, provider)
+
+	req := func(stream bool) *api.ChatRequest {
+		return &api.ChatRequest{
+			Model:    "mock/model",
+			Stream:   stream,
+			Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+		}
+	}
+
+	_, err := svc.Chat(ctx, req(false))
+	require.NoError(t, err)
+	unary := ingestor.last(t)
+
+	ch, err := svc.StreamChat(ctx, req(true))
+	require.NoError(t, err)
+	drain(t, ch)
+	streamed := ingestor.last(t)
+	require.True(t, streamed.IsStreamed)
+
+	// 800 uncached + 400 cached prompt tokens, 900 completion tokens
+	assert.Equal(t, int64(800+40+1800), unary.TotalCostMicros)
+	assert.Equal(t, unary.TotalCostMicros, streamed.TotalCostMicros)
+	assert.Equal(t, unary.InputTokens, streamed.InputTokens)
+	assert.Equal(t, unary.OutputTokens, streamed.OutputTokens)
+	assert.Equal(t, unary.CachedTokens, streamed.CachedTokens)
+	assert.Equal(t, unary.UsageDetails, streamed.UsageDetails)
+}
+
+func TestChat_ReconcilesUpstreamCost(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	require.NoError(t, repo.Providers().SyncProviders(ctx, []model.Provider{{ID: "mock", Name: "mock", ConfigJSON: "{}", IsEnabled: true}}))
+	// $1 / 1M input tokens and $2 / 1M output tokens
+	require.NoError(t, repo.Providers().SyncModels(ctx, []model.Model{{
+		ID: "mock/model", ProviderID: "mock", ProviderModelID: "model", IsEnabled: true,
+		InputCostMicrosPer1k: 1000, OutputCostMicrosPer1k: 2000,
+	}}))
+
+	tests := []struct {
+		name         string
+		upstreamCost float64
+		wantWarning  bool
+	}{
+		{name: "matching cost", upstreamCost: 0.003, wantWarning: false},
+		{name: "drifted cost", upstreamCost: 0.006, wantWarning: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cost := tt.upstreamCost
+			provider := &mockProvider{
+				id:     "mock",
+				models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+				chatResp: &api.ChatResponse{
+					ID: "upstream-id",
+					Choices: []api.Choice{
+						{Message: &api.ChatMessage{Role: "assistant", Content: api.Content{Text: "Hi"}}, FinishReason: "stop"},
+					},
+					Usage: &api.ResponseUsage{PromptTokens: 1000, CompletionTokens: 1000, TotalTokens: 2000, Cost: &cost},
+				},
+			}
+			core, logs := observer.New(zap.WarnLevel)
+			svc, ingestor := newTestServiceWith(t, zap.New(core), repo, config.GatewayConfig{CostDiscrepancyThreshold: 0.05}, provider)
+
+			_, err := svc.Chat(ctx, &api.ChatRequest{
+				Model:    "mock/model",
+				Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+			})
+			require.NoError(t, err)
+
+			log := ingestor.last(t)
+			assert.Equal(t, int64(3000), log.TotalCostMicros)
+			require.NotNil(t, log.UsageDetails)
+			require.NotNil(t, log.UsageDetails.UpstreamCostMicros)
+			assert.Equal(t, int64(cost*1000000), *log.UsageDetails.UpstreamCostMicros)
+
+			warnings := logs.FilterMessage("Upstream cost differs from computed cost")
+			if tt.wantWarning {
+				require.Equal(t, 1, warnings.Len())
+				assert.Equal(t, int64(6000), warnings.All()[0].ContextMap()["upstream_cost_micros"])
+			} else {
+				assert.Zero(t, warnings.Len())
+			}
+		})
+	}
+}
+
+func TestChat_EmptyChoices(t *testing.T) {
+	empty := func() *api.ChatResponse { return &api.ChatResponse{ID: "upstream-id", Choices: []api.Choice{}} }
+	req := func() *api.ChatRequest {
+		return &api.ChatRequest{
+			Model:    "mock/model",
+			Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+		}
+	}
+	newProvider := func(seq ...*api.ChatResponse) *mockProvider {
+		return &mockProvider{
+			id:      "mock",
+			models:  []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+			chatSeq: seq,
+		}
+	}
+
+	t.Run("error", func(t *testing.T) {
+		provider := newProvider(empty())
+		svc, ingestor := newTestService(t, config.GatewayConfig{EmptyResponseAction: EmptyResponseError}, provider)
+
+		_, err := svc.Chat(context.Background(), req())
+
+		var problem *api.Problem
+		require.ErrorAs(t, err, &problem)
+		assert.Equal(t, 502, problem.Status)
+		assert.Len(t, provider.requests, 1)
+		assert.Equal(t, 502, ingestor.last(t).StatusCode)
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		// the second attempt falls through to the default mock response
+		provider := newProvider(empty())
+		svc, _ := newTestService(t, config.GatewayConfig{EmptyResponseAction: EmptyResponseRetry, EmptyResponseRetries: 2}, provider)
+
+		resp, err := svc.Chat(context.Background(), req())
+		require.NoError(t, err)
+		assert.Equal(t, "Mock Response", resp.Choices[0].Message.Content.Text)
+		assert.Len(t, provider.requests, 2)
+	})
+
+	t.Run("retry exhausted", func(t *testing.T) {
+		provider := newProvider(empty(), empty(), empty())
+		svc, _ := newTestService(t, config.GatewayConfig{EmptyResponseAction: EmptyResponseRetry, EmptyResponseRetries: 1}, provider)
+
+		_, err := svc.Chat(context.Background(), req())
+
+		var problem *api.Problem
+		require.ErrorAs(t, err, &problem)
+		assert.Equal(t, 502, problem.Status)
+		assert.Len(t, provider.requests, 2)
+	})
+
+	t.Run("passthrough", func(t *testing.T) {
+		provider := newProvider(empty())
+		svc, _ := newTestService(t, config.GatewayConfig{EmptyResponseAction: EmptyResponsePassthrough}, provider)
+
+		resp, err := svc.Chat(context.Background(), req())
+		require.NoError(t, err)
+		assert.Empty(t, resp.Choices)
+	})
+}
+
+func TestChat_AppAttribution(t *testing.T) {
+	provider := &mockProvider{
+		id:     "mock",
+		models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+	}
+	svc, ingestor := newTestService(t, config.GatewayConfig{}, provider)
+
+	ctx := context.WithValue(context.Background(), store.ContextKeyAppTitle, "My Cool App")
+	ctx = context.WithValue(ctx, store.ContextKeyAppReferer, "https://example.com/chat")
+
+	_, err := svc.Chat(ctx, &api.ChatRequest{
+		Model:    "mock/model",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+
+	log := ingestor.last(t)
+	assert.Equal(t, "My Cool App", log.AppName)
+	assert.JSONEq(t, `{"referer": "https://example.com/chat"}`, log.MetaJSON)
+}
+
+// anthropicMock reports the anthropic provider type so type keyed policies apply.
+type anthropicMock struct{ *mockProvider }
+
+func (anthropicMock) Type() string { return "anthropic" }
+
+func TestChat_FillsMandatoryMaxTokens(t *testing.T) {
+	inner := &mockProvider{
+		id: "claude",
+		models: []api.ModelDefinition{
+			{ID: "anthropic/claude-sonnet", ProviderID: "claude", UpstreamID: "claude-sonnet"},
+			{ID: "anthropic/claude-haiku", ProviderID: "claude", UpstreamID: "claude-haiku", Config: api.ModelConfig{DefaultMaxTokens: 1024}},
+		},
+	}
+	svc := NewService(zap.NewNop(), newTestRepo(t), &captureIngestor{}, nil, config.GatewayConfig{
+		MaxTokensDefaults: map[string]int{"anthropic": 2048},
+	}).(*service)
+	require.NoError(t, svc.RegisterProvider(context.Background(), anthropicMock{inner}))
+
+	chat := func(model string, maxTokens int) api.ChatRequest {
+		_, err := svc.Chat(context.Background(), &api.ChatRequest{
+			Model:     model,
+			MaxTokens: maxTokens,
+			Messages:  []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+		})
+		require.NoError(t, err)
+		return inner.lastRequest()
+	}
+
+	assert.Equal(t, 2048, chat("anthropic/claude-sonnet", 0).MaxTokens, "provider default")
+	assert.Equal(t, 1024, chat("anthropic/claude-haiku", 0).MaxTokens, "model default wins")
+	assert.Equal(t, 300, chat("anthropic/claude-sonnet", 300).MaxTokens, "client value is kept")
+}
+
+func TestChat_MaxTokensLeftUnsetWhenNotMandated(t *testing.T) {
+	provider := &mockProvider{
+		id:     "mock",
+		models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+	}
+	svc, _ := newTestService(t, config.GatewayConfig{MaxTokensDefaults: map[string]int{"anthropic": 2048}}, provider)
+
+	_, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "mock/model",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+	assert.Zero(t, provider.lastRequest().MaxTokens)
+}
+
+func TestStreamChat_LogsPartialGenerationOnError(t *testing.T) {
+	provider := &mockProvider{
+		id:     "mock",
+		models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+		streamResp: []api.StreamResult{
+			textDelta("Hello", ""),
+			textDelta(", wor", ""),
+			{Err: errors.New("upstream connection reset")},
+			textDelta("never sent", ""),
+		},
+	}
+	svc, ingestor := newTestService(t, config.GatewayConfig{PersistPrompts: true}, provider)
+
+	ch, err := svc.StreamChat(context.Background(), &api.ChatRequest{
+		Model:    "mock/model",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Say hello"}}},
+	})
+	require.NoError(t, err)
+
+	results := drain(t, ch)
+	require.Len(t, results, 3)
+	require.Error(t, results[2].Err)
+
+	log := ingestor.last(t)
+	assert.Equal(t, "error", log.FinishReason)
+	assert.Equal(t, 502, log.StatusCode)
+	assert.Equal(t, "upstream connection reset", log.ErrorMessage)
+	assert.Equal(t, "Hello, wor", log.Completion)
+	assert.Equal(t, "upstream-id", log.ID)
+	assert.True(t, log.TTFTMS.Valid)
+}
+
+func TestChat_PricesCachedPromptTokens(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	require.NoError(t, repo.Providers().SyncProviders(ctx, []model.Provider{{ID: "mock", Name: "mock", ConfigJSON: "{}", IsEnabled: true}}))
+	// $1 / 1M input, $0.10 / 1M cache reads, $1.25 / 1M cache writes, $2 / 1M output
+	require.NoError(t, repo.Providers().SyncModels(ctx, []model.Model{{
+		ID: "mock/model", ProviderID: "mock", ProviderModelID: "model", IsEnabled: true,
+		InputCostMicrosPer1k: 1000, OutputCostMicrosPer1k: 2000,
+		CacheReadCostMicrosPer1k: 100, CacheWriteCostMicrosPer1k: 1250,
+	}}))
+
+	provider := &mockProvider{
+		id:     "mock",
+		models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+		chatResp: &api.ChatResponse{
+			ID: "upstream-id",
+			Choices: []api.Choice{
+				{Message: &api.ChatMessage{Role: "assistant", Content: api.Content{Text: "Hi"}}, FinishReason: "stop"},
+			},
+			Usage: &api.ResponseUsage{
+				PromptTokens: 12000, CompletionTokens: 1000, TotalTokens: 13000,
+				PromptTokensDetails: &api.PromptTokensDetails{CachedTokens: 10000, CacheWriteTokens: 1000},
+			},
+		},
+	}
+	svc, ingestor := newTestServiceWith(t, zap.NewNop(), repo, config.GatewayConfig{}, provider)
+
+	_, err := svc.Chat(ctx, &api.ChatRequest{
+		Model:    "mock/model",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+
+	log := ingestor.last(t)
+	// 1000 uncached + 10000 cache reads + 1000 cache writes + 1000 output
+	assert.Equal(t, int64(1000+1000+1250+2000), log.TotalCostMicros)
+	assert.Equal(t, 10000, log.CachedTokens)
+	require.NotNil(t, log.UsageDetails)
+	assert.Equal(t, 1000, log.UsageDetails.PromptTokensCacheWrite)
+}
+
+func TestChat_FallbackRecordsRoutingAttempts(t *testing.T) {
+	primary := &mockProvider{
+		id:      "primary",
+		models:  []api.ModelDefinition{{ID: "primary/model", ProviderID: "primary", UpstreamID: "model-a"}},
+		chatErr: api.NewError(http.StatusServiceUnavailable, "Upstream Provider Error", "overloaded"),
+	}
+	backup := &mockProvider{
+		id:     "backup",
+		models: []api.ModelDefinition{{ID: "backup/model", ProviderID: "backup", UpstreamID: "model-b"}},
+	}
+	svc, ingestor := newTestService(t, config.GatewayConfig{
+		RecordRouting: true,
+		Fallbacks:     []config.FallbackConfig{{Model: "primary/model", Fallbacks: []string{"backup/model"}}},
+	}, primary, backup)
+
+	resp, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "primary/model",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Mock Response", resp.Choices[0].Message.Content.Text)
+	assert.Equal(t, "model-b", backup.lastRequest().Model)
+
+	log := ingestor.last(t)
+	assert.Equal(t, "backup", log.ProviderID)
+	assert.Equal(t, "backup/model", log.ModelID)
+	require.Len(t, log.Routing, 2)
+
+	assert.Equal(t, 1, log.Routing[0].Attempt)
+	assert.Equal(t, "primary", log.Routing[0].ProviderID)
+	assert.Equal(t, "primary/model", log.Routing[0].ModelID)
+	assert.Equal(t, "model-a", log.Routing[0].UpstreamModelID)
+	assert.Equal(t, http.StatusServiceUnavailable, log.Routing[0].StatusCode)
+	assert.Contains(t, log.Routing[0].ErrorMessage, "overloaded")
+
+	assert.Equal(t, 2, log.Routing[1].Attempt)
+	assert.Equal(t, "backup", log.Routing[1].ProviderID)
+	assert.Equal(t, "backup/model", log.Routing[1].ModelID)
+	assert.Equal(t, http.StatusOK, log.Routing[1].StatusCode)
+	assert.Empty(t, log.Routing[1].ErrorMessage)
+}
+
+func TestChat_FallbackContentAfterAllProvidersFail(t *testing.T) {
+	overloaded := api.NewError(http.StatusServiceUnavailable, "Upstream Provider Error", "overloaded")
+	primary := &mockProvider{
+		id:      "primary",
+		models:  []api.ModelDefinition{{ID: "primary/model", ProviderID: "primary", UpstreamID: "model-a"}},
+		chatErr: overloaded,
+	}
+	backup := &mockProvider{
+		id:      "backup",
+		models:  []api.ModelDefinition{{ID: "backup/model", ProviderID: "backup", UpstreamID: "model-b"}},
+		chatErr: overloaded,
+	}
+	svc, ingestor := newTestService(t, config.GatewayConfig{
+		FallbackContent: "We are having trouble right now, please try again.",
+		Fallbacks:       []config.FallbackConfig{{Model: "primary/model", Fallbacks: []string{"backup/model"}}},
+	}, primary, backup)
+
+	chat := func(ctx context.Context, useFallback *bool) (*api.ChatResponse, error) {
+		return svc.Chat(ctx, &api.ChatRequest{
+			Model:              "primary/model",
+			Messages:           []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+			UseFallbackContent: useFallback,
+		})
+	}
+
+	_, err := chat(context.Background(), nil)
+	require.Error(t, err, "the fallback content is opt-in")
+
+	enabled := true
+	resp, err := chat(context.Background(), &enabled)
+	require.NoError(t, err)
+	require.Len(t, resp.Choices, 1)
+	assert.Equal(t, "We are having trouble right now, please try again.", resp.Choices[0].Message.Content.Text)
+	assert.Equal(t, string(api.FinishReasonFallback), resp.Choices[0].FinishReason)
+	assert.Len(t, backup.requests, 2, "every fallback is tried first")
+
+	log := ingestor.last(t)
+	assert.Equal(t, http.StatusServiceUnavailable, log.StatusCode)
+	assert.Equal(t, string(api.FinishReasonError), log.FinishReason)
+
+	// keys may opt in for all their requests, and requests may opt back out
+	keyCtx := context.WithValue(context.Background(), store.ContextKeyAPIKey,
+		&model.APIKey{ID: "key-1", UserID: "user-1", SettingsJSON: `{"use_fallback_content":true}`})
+	resp, err = chat(keyCtx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, string(api.FinishReasonFallback), resp.Choices[0].FinishReason)
+
+	disabled := false
+	_, err = chat(keyCtx, &disabled)
+	require.Error(t, err)
+}
+
+func TestStreamChat_FallbackContent(t *testing.T) {
+	provider := &mockProvider{
+		id:        "primary",
+		models:    []api.ModelDefinition{{ID: "primary/model", ProviderID: "primary", UpstreamID: "model-a"}},
+		streamErr: api.NewError(http.StatusServiceUnavailable, "Upstream Provider Error", "overloaded"),
+	}
+	svc, ingestor := newTestService(t, config.GatewayConfig{FallbackContent: "Try again later."}, provider)
+	enabled := true
+	req := func() *api.ChatRequest {
+		return &api.ChatRequest{
+			Model:              "primary/model",
+			Stream:             true,
+			Messages:           []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+			UseFallbackContent: &enabled,
+		}
+	}
+
+	ch, err := svc.StreamChat(context.Background(), req())
+	require.NoError(t, err)
+	results := drain(t, ch)
+	require.Len(t, results, 1)
+	assert.Equal(t, "Try again later.", results[0].Response.Choices[0].Delta.Content.Text)
+
+	// the failed request is still logged
+	log := ingestor.last(t)
+	assert.Equal(t, "primary", log.ProviderID)
+	assert.Equal(t, http.StatusServiceUnavailable, log.StatusCode)
+	assert.Equal(t, string(api.FinishReasonError), log.FinishReason)
+	assert.True(t, log.IsStreamed)
+
+	// client errors are not papered over
+	provider.streamErr = api.BadRequestError("invalid tool schema")
+	_, err = svc.StreamChat(context.Background(), req())
+	assert.Equal(t, http.StatusBadRequest, errorStatus(err))
+}
+
+func TestStreamChat_LogsStreamsThatFailToOpen(t *testing.T) {
+	provider := &mockProvider{
+		id:        "primary",
+		models:    []api.ModelDefinition{{ID: "primary/model", ProviderID: "primary", UpstreamID: "model-a"}},
+		streamErr: api.NewError(http.StatusTooManyRequests, "Upstream Provider Error", "rate limited"),
+	}
+	svc, ingestor := newTestService(t, config.GatewayConfig{}, provider)
+
+	_, err := svc.StreamChat(context.Background(), &api.ChatRequest{
+		Model:    "primary/model",
+		Stream:   true,
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.Error(t, err)
+
+	log := ingestor.last(t)
+	assert.Equal(t, "primary", log.ProviderID)
+	assert.Equal(t, "model-a", log.UpstreamModelID)
+	assert.Equal(t, http.StatusTooManyRequests, log.StatusCode)
+	assert.Equal(t, string(api.FinishReasonError), log.FinishReason)
+	assert.Contains(t, log.ErrorMessage, "rate limited")
+	assert.True(t, log.IsStreamed)
+}
+
+func TestChat_CostRoutingPicksCheapestProvider(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	require.NoError(t, repo.Providers().SyncProviders(ctx, []model.Provider{
+		{ID: "primary", Name: "primary", ConfigJSON: "{}", IsEnabled: true},
+		{ID: "backup", Name: "backup", ConfigJSON: "{}", IsEnabled: true},
+	}))
+
+	tests := []struct {
+		name       string
+		primaryOut int64
+		backupOut  int64
+		minQuality float64
+		want       string
+	}{
+		{name: "cheaper fallback goes first", primaryOut: 4000, backupOut: 2000, want: "backup/model"},
+		{name: "ties keep priority order", primaryOut: 2000, backupOut: 2000, want: "primary/model"},
+		{name: "quality floor skips cheaper model", primaryOut: 4000, backupOut: 2000, minQuality: 0.5, want: "primary/model"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, repo.Providers().SyncModels(ctx, []model.Model{
+				{ID: "primary/model", ProviderID: "primary", ProviderModelID: "model-a", IsEnabled: true, InputCostMicrosPer1k: 1000, OutputCostMicrosPer1k: tt.primaryOut},
+				{ID: "backup/model", ProviderID: "backup", ProviderModelID: "model-b", IsEnabled: true, InputCostMicrosPer1k: 1000, OutputCostMicrosPer1k: tt.backupOut},
+			}))
+
+			primary := &mockProvider{
+				id:     "primary",
+				models: []api.ModelDefinition{{ID: "primary/model", ProviderID: "primary", UpstreamID: "model-a", Config: api.ModelConfig{Quality: 0.9}}},
+			}
+			backup := &mockProvider{
+				id:     "backup",
+				models: []api.ModelDefinition{{ID: "backup/model", ProviderID: "backup", UpstreamID: "model-b", Config: api.ModelConfig{Quality: 0.3}}},
+			}
+			svc, ingestor := newTestServiceWith(t, zap.NewNop(), repo, config.GatewayConfig{
+				RoutingStrategy: "cost",
+				MinQuality:      tt.minQuality,
+				Fallbacks:       []config.FallbackConfig{{Model: "primary/model", Fallbacks: []string{"backup/model"}}},
+			}, primary, backup)
+
+			_, err := svc.Chat(ctx, &api.ChatRequest{
+				Model:     "primary/model",
+				MaxTokens: 100,
+				Messages:  []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+			})
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, ingestor.last(t).ModelID)
+			if tt.want == "backup/model" {
+				assert.Len(t, backup.requests, 1)
+				assert.Empty(t, primary.requests)
+			} else {
+				assert.Len(t, primary.requests, 1)
+				assert.Empty(t, backup.requests)
+			}
+		})
+	}
+}
+
+func TestChat_ClientErrorDoesNotFallBack(t *testing.T) {
+	primary := &mockProvider{
+		id:      "primary",
+		models:  []api.ModelDefinition{{ID: "primary/model", ProviderID: "primary", UpstreamID: "model-a"}},
+		chatErr: api.BadRequestError("invalid temperature"),
+	}
+	backup := &mockProvider{
+		id:     "backup",
+		models: []api.ModelDefinition{{ID: "backup/model", ProviderID: "backup", UpstreamID: "model-b"}},
+	}
+	svc, ingestor := newTestService(t, config.GatewayConfig{
+		RecordRouting: true,
+		Fallbacks:     []config.FallbackConfig{{Model: "primary/model", Fallbacks: []string{"backup/model"}}},
+	}, primary, backup)
+
+	_, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "primary/model",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.Error(t, err)
+	assert.Empty(t, backup.requests)
+
+	log := ingestor.last(t)
+	assert.Equal(t, http.StatusBadRequest, log.StatusCode)
+	require.Len(t, log.Routing, 1)
+}
+
+func TestChat_CitationsStoredInMeta(t *testing.T) {
+	provider := &mockProvider{
+		id:     "mock",
+		models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+		chatResp: &api.ChatResponse{
+			ID:        "upstream-id",
+			Citations: []string{"https://example.com/a"},
+			Choices: []api.Choice{
+				{Message: &api.ChatMessage{Role: "assistant", Content: api.Content{Text: "Hi [1]"}}, FinishReason: "stop"},
+			},
+		},
+	}
+	svc, ingestor := newTestService(t, config.GatewayConfig{}, provider)
+
+	resp, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "mock/model",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://example.com/a"}, resp.Citations)
+
+	log := ingestor.last(t)
+	assert.JSONEq(t, `{"citations": ["https://example.com/a"]}`, log.MetaJSON)
+}
+
+func TestChat_NormalizesAssistantRole(t *testing.T) {
+	provider := &mockProvider{
+		id:     "mock",
+		models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+		chatResp: &api.ChatResponse{
+			ID: "upstream-id",
+			Choices: []api.Choice{
+				{Message: &api.ChatMessage{Content: api.Content{Text: "first"}}, FinishReason: "stop"},
+				{Message: &api.ChatMessage{Content: api.Content{Text: "second"}}, FinishReason: "stop"},
+			},
+		},
+	}
+	svc, _ := newTestService(t, config.GatewayConfig{NormalizeResponses: true}, provider)
+
+	resp, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "mock/model",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, resp.Choices, 2)
+	for i, choice := range resp.Choices {
+		assert.Equal(t, i, choice.Index)
+		assert.Equal(t, "assistant", choice.Message.Role)
+	}
+}
+
+func TestStreamChat_NormalizesAssistantRole(t *testing.T) {
+	provider := &mockProvider{
+		id:         "mock",
+		models:     []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+		streamResp: []api.StreamResult{textDelta("Hel", ""), textDelta("lo", "")},
+	}
+	svc, _ := newTestService(t, config.GatewayConfig{NormalizeResponses: true}, provider)
+
+	ch, err := svc.StreamChat(context.Background(), &api.ChatRequest{
+		Model:    "mock/model",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+
+	results := drain(t, ch)
+	require.Len(t, results, 2)
+	for _, r := range results {
+		assert.Equal(t, "assistant", r.Response.Choices[0].Delta.Role)
+	}
+}
+
+func TestChat_NormalizationDisabled(t *testing.T) {
+	provider := &mockProvider{
+		id:     "mock",
+		models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+		chatResp: &api.ChatResponse{
+			ID:      "upstream-id",
+			Choices: []api.Choice{{Message: &api.ChatMessage{Content: api.Content{Text: "Hi"}}, FinishReason: "stop"}},
+		},
+	}
+	svc, _ := newTestService(t, config.GatewayConfig{}, provider)
+
+	resp, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "mock/model",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, resp.Choices[0].Message.Role)
+}
+
+func TestStreamChat_FillsChunkDefaults(t *testing.T) {
+	provider := &mockProvider{
+		id:     "mock",
+		models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+		streamResp: []api.StreamResult{
+			{Response: &api.ChatResponse{Choices: []api.Choice{{Delta: &api.ChatMessage{Content: api.Content{Text: "Hel"}}}}}},
+			{Response: &api.ChatResponse{Choices: []api.Choice{{Delta: &api.ChatMessage{Content: api.Content{Text: "lo"}}}}}},
+		},
+	}
+	svc, _ := newTestService(t, config.GatewayConfig{NormalizeResponses: true}, provider)
+
+	ch, err := svc.StreamChat(context.Background(), &api.ChatRequest{
+		Model:    "mock/model",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+
+	results := drain(t, ch)
+	require.Len(t, results, 2)
+	for _, r := range results {
+		assert.Equal(t, "chat.completion.chunk", r.Response.Object)
+		assert.Equal(t, "mock/model", r.Response.Model)
+		assert.NotZero(t, r.Response.Created)
+		assert.NotEmpty(t, r.Response.ID)
+	}
+	// every chunk of a stream shares one id
+	assert.Equal(t, results[0].Response.ID, results[1].Response.ID)
+}
+
+func TestChat_RecordsNativeTokens(t *testing.T) {
+	provider := &mockProvider{
+		id:     "mock",
+		models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+		chatResp: &api.ChatResponse{
+			ID:      "upstream-id",
+			Choices: []api.Choice{{Message: &api.ChatMessage{Role: "assistant", Content: api.Content{Text: "Hi"}}, FinishReason: "stop"}},
+			Usage: &api.ResponseUsage{
+				PromptTokens:     1120,
+				CompletionTokens: 50,
+				NativeTokens:     &api.NativeTokens{Prompt: 20, Completion: 50},
+			},
+		},
+	}
+	svc, ingestor := newTestService(t, config.GatewayConfig{}, provider)
+
+	_, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "mock/model",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+
+	log := ingestor.last(t)
+	assert.Equal(t, 1120, log.InputTokens)
+	require.NotNil(t, log.UsageDetails)
+	require.NotNil(t, log.UsageDetails.NativePromptTokens)
+	assert.Equal(t, 20, *log.UsageDetails.NativePromptTokens)
+	require.NotNil(t, log.UsageDetails.NativeCompletionTokens)
+	assert.Equal(t, 50, *log.UsageDetails.NativeCompletionTokens)
+}
+
+func TestStreamChat_PassthroughRelaysUpstreamBytes(t *testing.T) {
+	// fields the gateway does not model must survive untouched
+	chunks := []string{
+		`{"id":"chatcmpl-1","object":"chat.completion.chunk","model":"gpt","choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}],"x_vendor":{"shard":7}}`,
+		`{"id":"chatcmpl-1","object":"chat.completion.chunk","model":"gpt","choices":[{"index":0,"delta":{"content":"lo"},"logprobs":null}]}`,
+		`{"id":"chatcmpl-1","object":"chat.completion.chunk","model":"gpt","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+		`{"id":"chatcmpl-1","object":"chat.completion.chunk","model":"gpt","choices":[],"usage":{"prompt_tokens":12,"completion_tokens":2,"total_tokens":14}}`,
+	}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/models" {
+			_, _ = w.Write([]byte(`{"data":[{"id":"gpt"}]}`))
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, c := range chunks {
+			_, _ = w.Write([]byte("data: " + c + "\n\n"))
+		}
+		_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer upstream.Close()
+
+	provider, err := openai.NewAdapter(config.ProviderConfig{ID: "up", Type: "openai", APIKey: "sk", BaseURL: upstream.URL})
+	require.NoError(t, err)
+
+	svc, ingestor := newTestService(t, config.GatewayConfig{StreamPassthrough: true, NormalizeResponses: true})
+	require.NoError(t, svc.RegisterProvider(context.Background(), provider))
+
+	ch, err := svc.StreamChat(context.Background(), &api.ChatRequest{
+		Model:    "up/gpt",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+
+	results := drain(t, ch)
+	require.Len(t, results, len(chunks))
+	for i, r := range results {
+		assert.Equal(t, chunks[i], string(r.Raw))
+	}
+
+	log := ingestor.last(t)
+	assert.Equal(t, "chatcmpl-1", log.ID)
+	assert.Equal(t, "stop", log.FinishReason)
+	assert.Equal(t, 12, log.InputTokens)
+	assert.Equal(t, 2, log.OutputTokens)
+}
+
+func TestChat_IncludeReasoning(t *testing.T) {
+	include, exclude := true, false
+	tests := []struct {
+		name    string
+		cfg     config.GatewayConfig
+		flag    *bool
+		wantOut string
+	}{
+		{"default includes", config.GatewayConfig{}, nil, "thinking"},
+		{"request excludes", config.GatewayConfig{}, &exclude, ""},
+		{"config excludes", config.GatewayConfig{ExcludeReasoning: true}, nil, ""},
+		{"request overrides config", config.GatewayConfig{ExcludeReasoning: true}, &include, "thinking"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &mockProvider{
+				id:     "mock",
+				models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+				chatResp: &api.ChatResponse{Choices: []api.Choice{{
+					Message:      &api.ChatMessage{Role: "assistant", Content: api.Content{Text: "4"}, Reasoning: "thinking"},
+					FinishReason: "stop",
+				}}},
+			}
+			tt.cfg.PersistPrompts = true
+			svc, ingestor := newTestService(t, tt.cfg, provider)
+
+			resp, err := svc.Chat(context.Background(), &api.ChatRequest{
+				Model:            "mock/model",
+				Messages:         []api.ChatMessage{{Role: "user", Content: api.Content{Text: "2+2?"}}},
+				IncludeReasoning: tt.flag,
+			})
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantOut, resp.Choices[0].Message.Reasoning)
+			assert.Equal(t, "4", resp.Choices[0].Message.Content.Text)
+			// reasoning is always logged and never forwarded as a flag
+			assert.Equal(t, "thinking", ingestor.last(t).Reasoning)
+			assert.Nil(t, provider.lastRequest().IncludeReasoning)
+		})
+	}
+}
+
+func TestStreamChat_ExcludeReasoning(t *testing.T) {
+	exclude := false
+	provider := &mockProvider{
+		id:     "mock",
+		models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+		streamResp: []api.StreamResult{
+			{Response: &api.ChatResponse{Choices: []api.Choice{{Delta: &api.ChatMessage{Reasoning: "hmm"}}}}},
+			{Response: &api.ChatResponse{Choices: []api.Choice{{Delta: &api.ChatMessage{Content: api.Content{Text: "4"}}}}}},
+		},
+	}
+	svc, ingestor := newTestService(t, config.GatewayConfig{PersistPrompts: true}, provider)
+
+	ch, err := svc.StreamChat(context.Background(), &api.ChatRequest{
+		Model:            "mock/model",
+		Messages:         []api.ChatMessage{{Role: "user", Content: api.Content{Text: "2+2?"}}},
+		IncludeReasoning: &exclude,
+	})
+	require.NoError(t, err)
+
+	for _, r := range drain(t, ch) {
+		assert.Empty(t, r.Response.Choices[0].Delta.Reasoning)
+	}
+	assert.Equal(t, "hmm", ingestor.last(t).Reasoning)
+	assert.Equal(t, "4", ingestor.last(t).Completion)
+}
+
+func TestChat_SanitizesInvalidUTF8(t *testing.T) {
+	provider := &mockProvider{
+		id:     "mock",
+		models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+		chatResp: &api.ChatResponse{Choices: []api.Choice{{
+			Message: &api.ChatMessage{
+				Role:      "assistant",
+				Content:   api.Content{Text: "caf\xe9 \xff\xfeok"},
+				Reasoning: "fine",
+			},
+			FinishReason: "stop",
+		}}},
+	}
+	core, logs := observer.New(zap.WarnLevel)
+	svc, _ := newTestServiceWith(t, zap.New(core), newTestRepo(t), config.GatewayConfig{}, provider)
+
+	resp, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "mock/model",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+
+	text := resp.Choices[0].Message.Content.Text
+	assert.True(t, utf8.ValidString(text))
+	assert.Equal(t, "caf� �ok", text)
+	assert.Equal(t, "fine", resp.Choices[0].Message.Reasoning)
+
+	body, err := json.Marshal(resp)
+	require.NoError(t, err)
+	assert.True(t, utf8.Valid(body))
+	assert.Equal(t, 1, logs.FilterMessageSnippet("invalid UTF-8").Len())
+}
+
+func TestStreamChat_SanitizesInvalidUTF8(t *testing.T) {
+	provider := &mockProvider{
+		id:     "mock",
+		models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+		streamResp: []api.StreamResult{
+			{Response: &api.ChatResponse{Choices: []api.Choice{{Delta: &api.ChatMessage{Content: api.Content{Text: "a\xc3"}}}}}},
+			{Response: &api.ChatResponse{Choices: []api.Choice{{Delta: &api.ChatMessage{Content: api.Content{Text: "\x28b"}}}}}},
+			{Response: &api.ChatResponse{Choices: []api.Choice{{Delta: &api.ChatMessage{Content: api.Content{Text: "\xffc"}}}}}},
+		},
+	}
+	core, logs := observer.New(zap.WarnLevel)
+	svc, _ := newTestServiceWith(t, zap.New(core), newTestRepo(t), config.GatewayConfig{}, provider)
+
+	ch, err := svc.StreamChat(context.Background(), &api.ChatRequest{
+		Model:    "mock/model",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+
+	for _, r := range drain(t, ch) {
+		assert.True(t, utf8.ValidString(r.Response.Choices[0].Delta.Content.Text))
+	}
+	// logged once per stream, not per chunk
+	assert.Equal(t, 1, logs.FilterMessageSnippet("invalid UTF-8").Len())
+}
+
+func TestStreamChat_RejectsStreamsOverLimit(t *testing.T) {
+	provider := &mockProvider{
+		id:         "mock",
+		models:     []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+		streamResp: []api.StreamResult{textDelta("Hi", "")},
+	}
+	svc, _ := newTestService(t, config.GatewayConfig{MaxActiveStreams: 2}, provider)
+	newReq := func() *api.ChatRequest {
+		return &api.ChatRequest{
+			Model:    "mock/model",
+			Stream:   true,
+			Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+		}
+	}
+
+	// both streams stay open until they are read
+	first, err := svc.StreamChat(context.Background(), newReq())
+	require.NoError(t, err)
+	second, err := svc.StreamChat(context.Background(), newReq())
+	require.NoError(t, err)
+	assert.Equal(t, StreamStats{Active: 2, Max: 2}, svc.StreamStats())
+
+	_, err = svc.StreamChat(context.Background(), newReq())
+	var problem *api.Problem
+	require.ErrorAs(t, err, &problem)
+	assert.Equal(t, http.StatusServiceUnavailable, problem.Status)
+	assert.Len(t, provider.requests, 2)
+
+	drain(t, first)
+	drain(t, second)
+	assert.Equal(t, int64(0), svc.StreamStats().Active)
+
+	// capacity is available again once streams complete
+	third, err := svc.StreamChat(context.Background(), newReq())
+	require.NoError(t, err)
+	drain(t, third)
+	assert.Equal(t, int64(0), svc.StreamStats().Active)
+}
+
+func TestChat_CapsMaxTokensToBalance(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	require.NoError(t, repo.Providers().SyncProviders(ctx, []model.Provider{{ID: "mock", Name: "mock", ConfigJSON: "{}", IsEnabled: true}}))
+	// $1 / 1M input tokens and $2 / 1M output tokens
+	require.NoError(t, repo.Providers().SyncModels(ctx, []model.Model{{
+		ID: "mock/model", ProviderID: "mock", ProviderModelID: "model", IsEnabled: true,
+		InputCostMicrosPer1k: 1000, OutputCostMicrosPer1k: 2000,
+	}, {
+		ID: "mock/pricey", ProviderID: "mock", ProviderModelID: "pricey", IsEnabled: true,
+		InputCostMicrosPer1k: 1000, OutputCostMicrosPer1k: 4000,
+	}}))
+
+	tests := []struct {
+		name          string
+		balance       int64
+		maxTokens     int
+		models        []string
+		wantMaxTokens int
+		wantStatus    int
+	}{
+		// "Hi" is estimated at one prompt token, leaving 999 micros for output
+		{name: "clamped to balance", balance: 1000, maxTokens: 4096, wantMaxTokens: 499},
+		{name: "unset is filled in", balance: 1000, wantMaxTokens: 499},
+		{name: "affordable limit is kept", balance: 1000, maxTokens: 100, wantMaxTokens: 100},
+		{name: "below minimum is rejected", balance: 10, maxTokens: 100, wantStatus: http.StatusPaymentRequired},
+		{name: "priced for the priciest fallback", balance: 1000, maxTokens: 4096, models: []string{"mock/pricey"}, wantMaxTokens: 249},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID := fmt.Sprintf("user-%d", i)
+			now := time.Now()
+			require.NoError(t, repo.Users().Create(ctx, &model.User{ID: userID, Email: userID + "@example.com", Name: userID, Role: "user", CreatedAt: now, UpdatedAt: now}))
+			require.NoError(t, repo.Users().CreateWallet(ctx, &model.Wallet{ID: "wallet-" + userID, UserID: userID, BalanceMicros: tt.balance, Currency: "USD", CreatedAt: now, UpdatedAt: now}))
+
+			provider := &mockProvider{
+				id: "mock",
+				models: []api.ModelDefinition{
+					{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"},
+					{ID: "mock/pricey", ProviderID: "mock", UpstreamID: "pricey"},
+				},
+			}
+			svc, _ := newTestServiceWith(t, zap.NewNop(), repo, config.GatewayConfig{BalanceTokenCap: true, MinAffordableTokens: 16}, provider)
+
+			keyCtx := context.WithValue(ctx, store.ContextKeyAPIKey, &model.APIKey{ID: "key-" + userID, UserID: userID})
+			_, err := svc.Chat(keyCtx, &api.ChatRequest{
+				Model:     "mock/model",
+				Models:    tt.models,
+				MaxTokens: tt.maxTokens,
+				Messages:  []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+			})
+
+			if tt.wantStatus != 0 {
+				var problem *api.Problem
+				require.ErrorAs(t, err, &problem)
+				assert.Equal(t, tt.wantStatus, problem.Status)
+				assert.Empty(t, provider.requests)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantMaxTokens, provider.lastRequest().MaxTokens)
+		})
+	}
+}
+
+func TestSelfTest_ReportsEveryProvider(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	require.NoError(t, repo.Providers().SyncProviders(ctx, []model.Provider{
+		{ID: "healthy", Name: "healthy", ConfigJSON: "{}", IsEnabled: true},
+		{ID: "broken", Name: "broken", ConfigJSON: "{}", IsEnabled: true},
+	}))
+	require.NoError(t, repo.Providers().SyncModels(ctx, []model.Model{
+		{ID: "healthy/large", ProviderID: "healthy", ProviderModelID: "large", IsEnabled: true, InputCostMicrosPer1k: 10000, OutputCostMicrosPer1k: 30000},
+		{ID: "healthy/small", ProviderID: "healthy", ProviderModelID: "small", IsEnabled: true, InputCostMicrosPer1k: 1000, OutputCostMicrosPer1k: 2000},
+	}))
+
+	healthy := &mockProvider{
+		id: "healthy",
+		models: []api.ModelDefinition{
+			{ID: "healthy/large", ProviderID: "healthy", UpstreamID: "large"},
+			{ID: "healthy/small", ProviderID: "healthy", UpstreamID: "small"},
+			{ID: "healthy/image", ProviderID: "healthy", UpstreamID: "image", Architecture: api.ModelArchitecture{OutputModalities: []string{"image"}}},
+		},
+		chatResp: &api.ChatResponse{
+			Choices: []api.Choice{{Message: &api.ChatMessage{Role: "assistant", Content: api.Content{Text: "OK"}}, FinishReason: "stop"}},
+			Usage:   &api.ResponseUsage{PromptTokens: 1000, CompletionTokens: 1000, TotalTokens: 2000},
+		},
+	}
+	broken := &mockProvider{
+		id:      "broken",
+		models:  []api.ModelDefinition{{ID: "broken/model", ProviderID: "broken", UpstreamID: "model"}},
+		chatErr: api.NewError(http.StatusUnauthorized, "Upstream Provider Error", "invalid api key"),
+	}
+	svc, ingestor := newTestServiceWith(t, zap.NewNop(), repo, config.GatewayConfig{SelfTestTimeout: time.Second, SelfTestConcurrency: 1}, healthy, broken)
+
+	results := svc.SelfTest(ctx)
+	require.Len(t, results, 2)
+
+	assert.Equal(t, "broken", results[0].ProviderID)
+	assert.Equal(t, "broken/model", results[0].ModelID)
+	assert.False(t, results[0].Success)
+	assert.Contains(t, results[0].Error, "invalid api key")
+
+	assert.Equal(t, "healthy", results[1].ProviderID)
+	assert.Equal(t, "healthy/small", results[1].ModelID)
+	assert.True(t, results[1].Success)
+	assert.Empty(t, results[1].Error)
+	assert.Equal(t, int64(3000), results[1].CostMicros)
+
+	sent := healthy.lastRequest()
+	assert.Equal(t, "small", sent.Model)
+	assert.Equal(t, selfTestMaxTokens, sent.MaxTokens)
+
+	// canaries are not request traffic
+	assert.Empty(t, ingestor.logs)
+}
+
+func TestChat_RoutesModelsNamingProviderAlias(t *testing.T) {
+	t.Run("config ID differs from adapter name", func(t *testing.T) {
+		// the adapter reports a literal name while its models carry the config ID
+		adapter := &mockProvider{
+			id:     "openai",
+			models: []api.ModelDefinition{{ID: "main/gpt", ProviderID: "openai-main", UpstreamID: "gpt"}},
+		}
+		svc, ingestor := newTestService(t, config.GatewayConfig{})
+		require.NoError(t, svc.RegisterProvider(context.Background(), adapter, "openai-main"))
+
+		_, err := svc.Chat(context.Background(), &api.ChatRequest{
+			Model:    "main/gpt",
+			Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "gpt", adapter.lastRequest().Model)
+		assert.Equal(t, "openai", ingestor.last(t).ProviderID)
+
+		def, ok := svc.GetModel("main/gpt")
+		require.True(t, ok)
+		assert.Equal(t, "openai", def.ProviderID, "models are indexed under the canonical identity")
+
+		p, err := svc.GetProvider("openai-main")
+		require.NoError(t, err)
+		assert.Same(t, adapter, p)
+
+		svc.UnregisterProvider("openai-main")
+		_, ok = svc.GetModel("main/gpt")
+		assert.False(t, ok)
+	})
+
+	t.Run("configured alias", func(t *testing.T) {
+		adapter := &mockProvider{
+			id:     "local",
+			models: []api.ModelDefinition{{ID: "local/llama", ProviderID: "ollama", UpstreamID: "llama"}},
+		}
+		svc, _ := newTestService(t, config.GatewayConfig{ProviderAliases: map[string]string{"ollama": "local"}}, adapter)
+
+		_, err := svc.Chat(context.Background(), &api.ChatRequest{
+			Model:    "local/llama",
+			Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+		})
+		require.NoError(t, err)
+		assert.Len(t, adapter.requests, 1)
+	})
+}
+
+func TestChat_RepeatedUnauthorizedShortCircuits(t *testing.T) {
+	p := &mockProvider{
+		id:      "openai",
+		models:  []api.ModelDefinition{{ID: "openai/gpt-4o", ProviderID: "openai", UpstreamID: "gpt-4o"}},
+		chatErr: api.NewError(http.StatusInternalServerError, "Upstream Error", "overloaded"),
+	}
+	svc, _ := newTestService(t, config.GatewayConfig{AuthFailureThreshold: 2, AuthProbeInterval: time.Hour}, p)
+	chat := func() error {
+		_, err := svc.Chat(context.Background(), &api.ChatRequest{
+			Model:    "openai/gpt-4o",
+			Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+		})
+		return err
+	}
+
+	// transient server errors say nothing about the key
+	for range 3 {
+		require.Error(t, chat())
+	}
+	p.chatErr = api.NewError(http.StatusUnauthorized, "Upstream Provider Error", "Incorrect API key provided")
+	for range 2 {
+		assert.Equal(t, http.StatusUnauthorized, errorStatus(chat()))
+	}
+	require.Len(t, p.requests, 5)
+
+	err := chat()
+	assert.Equal(t, http.StatusServiceUnavailable, errorStatus(err))
+	assert.Contains(t, err.Error(), "Provider Authentication Failed")
+	assert.Len(t, p.requests, 5, "the provider is not called while its key is unhealthy")
+}
+
+func TestChat_ForwardsStoreAndMetadata(t *testing.T) {
+	var upstreamBody map[string]any
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/models" {
+			_, _ = w.Write([]byte(`{"data":[{"id":"gpt"}]}`))
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&upstreamBody)
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}]}`))
+	}))
+	defer upstream.Close()
+
+	provider, err := openai.NewAdapter(config.ProviderConfig{ID: "up", Type: "openai", APIKey: "sk", BaseURL: upstream.URL})
+	require.NoError(t, err)
+	svc, ingestor := newTestService(t, config.GatewayConfig{})
+	require.NoError(t, svc.RegisterProvider(context.Background(), provider))
+
+	_, err = svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "up/gpt",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+		Store:    true,
+		Metadata: map[string]string{"ticket": "T-42"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, true, upstreamBody["store"])
+	assert.Equal(t, map[string]any{"ticket": "T-42"}, upstreamBody["metadata"])
+	assert.JSONEq(t, `{"metadata": {"ticket": "T-42"}}`, ingestor.last(t).MetaJSON)
+
+	// other upstreams do not receive them
+	mock := &mockProvider{id: "mock", models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock"}}}
+	require.NoError(t, svc.RegisterProvider(context.Background(), mock))
+	_, err = svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "mock/model",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+		Store:    true,
+		Metadata: map[string]string{"ticket": "T-42"},
+	})
+	require.NoError(t, err)
+	assert.False(t, mock.lastRequest().Store)
+	assert.Nil(t, mock.lastRequest().Metadata)
+}
+
+func TestChat_DropsPerplexityParametersForOtherProviders(t *testing.T) {
+	mock := &mockProvider{id: "mock", models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock"}}}
+	svc, _ := newTestService(t, config.GatewayConfig{}, mock)
+	citations := true
+
+	_, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Model:              "mock/model",
+		Messages:           []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+		SearchDomainFilter: []string{"example.com"},
+		ReturnCitations:    &citations,
+	})
+	require.NoError(t, err)
+	assert.Nil(t, mock.lastRequest().SearchDomainFilter)
+	assert.Nil(t, mock.lastRequest().ReturnCitations)
+}
+
+func TestChat_BareModelUsesDefaultProvider(t *testing.T) {
+	p := &mockProvider{
+		id:     "openai",
+		models: []api.ModelDefinition{{ID: "openai/gpt-4o", ProviderID: "openai", UpstreamID: "gpt-4o"}},
+	}
+	svc, ingestor := newTestService(t, config.GatewayConfig{DefaultProvider: "openai"}, p)
+
+	_, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "gpt-4o",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4o", p.lastRequest().Model)
+	assert.Equal(t, "openai/gpt-4o", ingestor.last(t).ModelID)
+
+	// a bare name the default provider does not serve still fails
+	_, err = svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "claude-3",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	assert.Equal(t, http.StatusBadRequest, errorStatus(err))
+}
+
+func TestChat_DebugEchoIsCappedAndRedacted(t *testing.T) {
+	p := &mockProvider{
+		id:       "mock",
+		chatResp: &api.ChatResponse{Choices: []api.Choice{{Message: &api.ChatMessage{Role: "assistant", Content: api.Content{Text: "Hi"}}}}},
+		models:   []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock"}},
+	}
+	svc, _ := newTestService(t, config.GatewayConfig{DebugEcho: config.DebugEchoConfig{Enabled: true, MaxBytes: 120, KeyIDs: []string{"key-listed"}}}, p)
+
+	req := func() *api.ChatRequest {
+		return &api.ChatRequest{
+			Model: "mock/model",
+			Messages: []api.ChatMessage{
+				{Role: "user", Content: api.Content{Text: "my key is sk-abcdef1234567890"}},
+				{Role: "user", Content: api.Content{Text: strings.Repeat("long prompt ", 50)}},
+			},
+			Debug: &api.DebugOptions{EchoUpstreamBody: true},
+		}
+	}
+
+	for _, key := range []*model.APIKey{{ID: "key-listed"}, {ID: "key-scoped", Scopes: `["chat", "debug"]`}} {
+		ctx := context.WithValue(context.Background(), store.ContextKeyAPIKey, key)
+		resp, err := svc.Chat(ctx, req())
+		require.NoError(t, err)
+		require.NotNil(t, resp.Debug, key.ID)
+		assert.LessOrEqual(t, len(resp.Debug.EchoUpstreamBody), 120)
+		assert.True(t, resp.Debug.Truncated)
+		assert.Contains(t, resp.Debug.EchoUpstreamBody, "my key is ***")
+		assert.NotContains(t, resp.Debug.EchoUpstreamBody, "sk-abcdef")
+		assert.Nil(t, p.lastRequest().Debug, "debug options are not forwarded")
+	}
+
+	// secret looking fields are redacted whatever their value
+	redacted := redactSecrets(map[string]any{"api_key": "plain", "max_tokens": 10.0, "tools": []any{map[string]any{"Authorization": "x"}}})
+	assert.Equal(t, map[string]any{"api_key": "***", "max_tokens": 10.0, "tools": []any{map[string]any{"Authorization": "***"}}}, redacted)
+
+	// other keys get no echo
+	ctx := context.WithValue(context.Background(), store.ContextKeyAPIKey, &model.APIKey{ID: "key-other"})
+	resp, err := svc.Chat(ctx, req())
+	require.NoError(t, err)
+	assert.Nil(t, resp.Debug)
+}
+
+func TestStreamChat_ChunksLargeImages(t *testing.T) {
+	large := "data:image/png;base64," + strings.Repeat("iVBORw0KGgo", 300)
+	small := "data:image/png;base64,AAAA"
+	provider := &mockProvider{
+		id:     "mock",
+		models: []api.ModelDefinition{{ID: "mock/image", ProviderID: "mock"}},
+		streamResp: []api.StreamResult{{Response: &api.ChatResponse{
+			Choices: []api.Choice{{
+				Delta: &api.ChatMessage{Role: "assistant", Images: []api.ContentPart{
+					{Type: "image_url", ImageURL: &api.ImageURL{URL: large}},
+					{Type: "image_url", ImageURL: &api.ImageURL{URL: small}},
+				}},
+				FinishReason: "stop",
+			}},
+			Usage: &api.ResponseUsage{PromptTokens: 3, CompletionTokens: 1, TotalTokens: 4},
+		}}},
+	}
+	svc, ingestor := newTestService(t, config.GatewayConfig{StreamImageChunkBytes: 1000}, provider)
+
+	ch, err := svc.StreamChat(context.Background(), &api.ChatRequest{
+		Model:    "mock/image",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Draw a cat"}}},
+	})
+	require.NoError(t, err)
+	results := drain(t, ch)
+
+	require.Len(t, results, 4, "the large image is split in 4 deltas")
+	images := map[int]string{}
+	for i, r := range results {
+		require.NoError(t, r.Err)
+		choice := r.Response.Choices[0]
+		for _, img := range choice.Delta.Images {
+			require.NotNil(t, img.Index)
+			assert.LessOrEqual(t, len(img.ImageURL.URL), 1000)
+			images[*img.Index] += img.ImageURL.URL
+		}
+		if i < len(results)-1 {
+			assert.Empty(t, choice.FinishReason)
+			assert.Nil(t, r.Response.Usage)
+		}
+	}
+	assert.Equal(t, map[int]string{0: large, 1: small}, images)
+
+	last := results[len(results)-1].Response
+	assert.Equal(t, "stop", last.Choices[0].FinishReason)
+	require.NotNil(t, last.Usage)
+	assert.Equal(t, 4, last.Usage.TotalTokens)
+	assert.Equal(t, "stop", ingestor.last(t).FinishReason)
+}
+
+func TestStreamChat_ImageIndexesRunAcrossChunks(t *testing.T) {
+	first := "data:image/png;base64," + strings.Repeat("iVBORw0KGgo", 150)
+	second := "data:image/png;base64," + strings.Repeat("R0lGODlh", 150)
+	image := func(url string) api.StreamResult {
+		return api.StreamResult{Response: &api.ChatResponse{Choices: []api.Choice{{
+			Delta: &api.ChatMessage{Role: "assistant", Images: []api.ContentPart{{Type: "image_url", ImageURL: &api.ImageURL{URL: url}}}},
+		}}}}
+	}
+	provider := &mockProvider{
+		id:         "mock",
+		models:     []api.ModelDefinition{{ID: "mock/image", ProviderID: "mock"}},
+		streamResp: []api.StreamResult{image(first), image(second)},
+	}
+	svc, _ := newTestService(t, config.GatewayConfig{StreamImageChunkBytes: 1000}, provider)
+
+	ch, err := svc.StreamChat(context.Background(), &api.ChatRequest{
+		Model:    "mock/image",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Draw two cats"}}},
+	})
+	require.NoError(t, err)
+
+	images := map[int]string{}
+	for _, r := range drain(t, ch) {
+		require.NoError(t, r.Err)
+		for _, img := range r.Response.Choices[0].Delta.Images {
+			require.NotNil(t, img.Index)
+			images[*img.Index] += img.ImageURL.URL
+		}
+	}
+	assert.Equal(t, map[int]string{0: first, 1: second}, images)
+}
+
+func TestChat_InjectsCorrelationHeaders(t *testing.T) {
+	var upstreamHeaders http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/models" {
+			_, _ = w.Write([]byte(`{"data":[{"id":"gpt"}]}`))
+			return
+		}
+		upstreamHeaders = r.Header.Clone()
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}]}`))
+	}))
+	defer upstream.Close()
+
+	provider, err := openai.NewAdapter(config.ProviderConfig{ID: "up", Type: "openai", APIKey: "sk", BaseURL: upstream.URL})
+	require.NoError(t, err)
+	svc, ingestor := newTestService(t, config.GatewayConfig{CorrelationHeaders: []config.CorrelationHeader{
+		{Name: "X-Request-ID", Value: "request_id"},
+		{Name: "X-Tenant-ID", Value: "key_hash"},
+		{Name: "X-App", Value: "app_name"},
+	}})
+	require.NoError(t, svc.RegisterProvider(context.Background(), provider))
+
+	ctx := context.WithValue(context.Background(), store.ContextKeyAPIKey, &model.APIKey{ID: "key-1", UserID: "user-1"})
+	resp, err := svc.Chat(ctx, &api.ChatRequest{
+		Model:    "up/gpt",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, ingestor.last(t).ID, upstreamHeaders.Get("X-Request-ID"))
+	assert.Equal(t, resp.ID, upstreamHeaders.Get("X-Request-ID"))
+	assert.Equal(t, correlationHash("key-1"), upstreamHeaders.Get("X-Tenant-ID"))
+	assert.NotContains(t, upstreamHeaders.Get("X-Tenant-ID"), "key-1")
+	assert.Empty(t, upstreamHeaders.Values("X-App"), "headers without a value are left out")
+	assert.Equal(t, "Bearer sk", upstreamHeaders.Get("Authorization"))
+}
+
+func TestChat_FallsThroughForUnknownModels(t *testing.T) {
+	cost := 0.0042
+	meta := &mockProvider{
+		id: "openrouter",
+		chatResp: &api.ChatResponse{
+			Choices: []api.Choice{{Message: &api.ChatMessage{Role: "assistant", Content: api.Content{Text: "Hi"}}, FinishReason: "stop"}},
+			Usage:   &api.ResponseUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15, Cost: &cost},
+		},
+	}
+	local := &mockProvider{id: "mock", models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock"}}}
+	ctx := context.Background()
+
+	svc, ingestor := newTestService(t, config.GatewayConfig{FallthroughProvider: "openrouter"}, local, meta)
+
+	for _, modelID := range []string{"mistralai/mistral-large", "openrouter/mistralai/mistral-large"} {
+		_, err := svc.Chat(ctx, &api.ChatRequest{Model: modelID, Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}}})
+		require.NoError(t, err)
+		assert.Equal(t, "mistralai/mistral-large", meta.lastRequest().Model)
+
+		log := ingestor.last(t)
+		assert.Equal(t, "openrouter", log.ProviderID)
+		require.NotNil(t, log.UsageDetails.UpstreamCostMicros)
+		assert.Equal(t, int64(4200), *log.UsageDetails.UpstreamCostMicros)
+		assert.Equal(t, int64(4200), log.TotalCostMicros, "unpriced models are billed the reported cost")
+	}
+
+	_, err := svc.Chat(ctx, &api.ChatRequest{Model: "mock/model", Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}}})
+	require.NoError(t, err)
+	assert.Len(t, local.requests, 1, "registered models are not sent to the fallthrough provider")
+
+	svc, _ = newTestService(t, config.GatewayConfig{}, local, meta)
+	_, err = svc.Chat(ctx, &api.ChatRequest{Model: "mistralai/mistral-large", Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}}})
+	require.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, errorStatus(err))
+}
+
+func TestChat_RejectsUnpricedFallthroughUnderBalanceCap(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	now := time.Now()
+	require.NoError(t, repo.Users().Create(ctx, &model.User{ID: "user-1", Email: "user-1@example.com", Name: "user-1", Role: "user", CreatedAt: now, UpdatedAt: now}))
+	require.NoError(t, repo.Users().CreateWallet(ctx, &model.Wallet{ID: "wallet-1", UserID: "user-1", BalanceMicros: 1000, Currency: "USD", CreatedAt: now, UpdatedAt: now}))
+
+	meta := &mockProvider{id: "openrouter"}
+	svc, _ := newTestServiceWith(t, zap.NewNop(), repo, config.GatewayConfig{FallthroughProvider: "openrouter", BalanceTokenCap: true}, meta)
+	req := func() *api.ChatRequest {
+		return &api.ChatRequest{Model: "mistralai/mistral-large", Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}}}
+	}
+
+	keyCtx := context.WithValue(ctx, store.ContextKeyAPIKey, &model.APIKey{ID: "key-1", UserID: "user-1"})
+	_, err := svc.Chat(keyCtx, req())
+	assert.Equal(t, http.StatusPaymentRequired, errorStatus(err))
+	assert.ErrorContains(t, err, "has no pricing")
+	assert.Empty(t, meta.requests)
+
+	// callers without a wallet are not held to a balance
+	_, err = svc.Chat(ctx, req())
+	require.NoError(t, err)
+}
+
+// saturatedProvider reports whether it has free capacity.
+type saturatedProvider struct {
+	*mockProvider
+	saturated bool
+}
+
+func (p *saturatedProvider) Saturated() bool { return p.saturated }
+
+func TestChat_SkipsSaturatedProvider(t *testing.T) {
+	local := &saturatedProvider{
+		mockProvider: &mockProvider{id: "local", models: []api.ModelDefinition{{ID: "local/model", ProviderID: "local", UpstreamID: "model-a"}}},
+		saturated:    true,
+	}
+	backup := &mockProvider{
+		id:     "backup",
+		models: []api.ModelDefinition{{ID: "backup/model", ProviderID: "backup", UpstreamID: "model-b"}},
+	}
+	svc, ingestor := newTestService(t, config.GatewayConfig{
+		RecordRouting: true,
+		Fallbacks:     []config.FallbackConfig{{Model: "local/model", Fallbacks: []string{"backup/model"}}},
+	}, backup)
+	require.NoError(t, svc.RegisterProvider(context.Background(), local))
+
+	req := func() *api.ChatRequest {
+		return &api.ChatRequest{Model: "local/model", Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}}}
+	}
+	_, err := svc.Chat(context.Background(), req())
+	require.NoError(t, err)
+	assert.Empty(t, local.requests, "a saturated provider is not sent the request")
+	assert.Equal(t, "model-b", backup.lastRequest().Model)
+
+	log := ingestor.last(t)
+	require.Len(t, log.Routing, 2)
+	assert.Equal(t, http.StatusServiceUnavailable, log.Routing[0].StatusCode)
+
+	local.saturated = false
+	_, err = svc.Chat(context.Background(), req())
+	require.NoError(t, err)
+	assert.Equal(t, "model-a", local.lastRequest().Model)
+}
+
+func TestGetProviderForModel_WeightedRoute(t *testing.T) {
+	primary := &mockProvider{id: "openai-primary", models: []api.ModelDefinition{{ID: "openai/gpt-4o", ProviderID: "openai-primary", UpstreamID: "gpt-4o"}}}
+	backup := &mockProvider{id: "azure-backup"}
+	svc, _ := newTestService(t, config.GatewayConfig{
+		WeightedRoutes: []config.WeightedRoute{{
+			Model: "openai/gpt-4o",
+			Targets: []config.RouteTarget{
+				{Provider: "openai-primary", Weight: 80},
+				{Provider: "azure-backup", UpstreamID: "gpt-4o-prod", Weight: 20},
+			},
+		}},
+	}, primary, backup)
+
+	picked := map[string]int{}
+	for range 2000 {
+		p, upstreamID, err := svc.GetProviderForModel(context.Background(), "openai/gpt-4o")
+		require.NoError(t, err)
+		if p.Name() == "azure-backup" {
+			assert.Equal(t, "gpt-4o-prod", upstreamID)
+		} else {
+			assert.Equal(t, "gpt-4o", upstreamID, "targets default to the model's upstream ID")
+		}
+		picked[p.Name()]++
+	}
+	assert.InDelta(t, 1600, picked["openai-primary"], 150)
+	assert.InDelta(t, 400, picked["azure-backup"], 150)
+
+	// an unhealthy target is left out of the pick
+	svc.health.set(ProviderHealth{ProviderID: "openai-primary", Healthy: false})
+	for range 20 {
+		p, _, err := svc.GetProviderForModel(context.Background(), "openai/gpt-4o")
+		require.NoError(t, err)
+		assert.Equal(t, "azure-backup", p.Name())
+	}
+
+	svc.health.set(ProviderHealth{ProviderID: "azure-backup", Healthy: false})
+	_, _, err := svc.GetProviderForModel(context.Background(), "openai/gpt-4o")
+	assert.Equal(t, http.StatusServiceUnavailable, errorStatus(err))
+}
+
+func TestChat_HonorsUpstreamRetryAfter(t *testing.T) {
+	upstreamErr := &httpclient.UpstreamError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Minute}
+	primary := &mockProvider{
+		id:      "primary",
+		models:  []api.ModelDefinition{{ID: "primary/model", ProviderID: "primary", UpstreamID: "model-a"}},
+		chatErr: api.NewError(http.StatusTooManyRequests, "Upstream Provider Error", "slow down", api.WithLog(upstreamErr)),
+	}
+	backup := &mockProvider{id: "backup", models: []api.ModelDefinition{{ID: "backup/model", ProviderID: "backup", UpstreamID: "model-b"}}}
+	svc, _ := newTestService(t, config.GatewayConfig{
+		Fallbacks: []config.FallbackConfig{{Model: "primary/model", Fallbacks: []string{"backup/model"}}},
+	}, primary, backup)
+
+	req := func() *api.ChatRequest {
+		return &api.ChatRequest{Model: "primary/model", Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}}}
+	}
+	for range 3 {
+		_, err := svc.Chat(context.Background(), req())
+		require.NoError(t, err)
+	}
+	assert.Len(t, primary.requests, 1, "the provider is skipped until its Retry-After passed")
+	assert.Len(t, backup.requests, 3)
+
+	_, _, err := svc.GetProviderForModel(context.Background(), "primary/model")
+	assert.Equal(t, http.StatusTooManyRequests, errorStatus(err))
+}
+
+func TestChat_RequestModelsFallBackInOrder(t *testing.T) {
+	overloaded := api.NewError(http.StatusServiceUnavailable, "Upstream Provider Error", "overloaded")
+	primary := &mockProvider{
+		id:      "primary",
+		models:  []api.ModelDefinition{{ID: "primary/model", ProviderID: "primary", UpstreamID: "model-a"}},
+		chatErr: overloaded,
+	}
+	second := &mockProvider{
+		id:      "second",
+		models:  []api.ModelDefinition{{ID: "second/model", ProviderID: "second", UpstreamID: "model-b"}},
+		chatErr: overloaded,
+	}
+	third := &mockProvider{
+		id:     "third",
+		models: []api.ModelDefinition{{ID: "third/model", ProviderID: "third", UpstreamID: "model-c"}},
+	}
+	configured := &mockProvider{
+		id:     "configured",
+		models: []api.ModelDefinition{{ID: "configured/model", ProviderID: "configured", UpstreamID: "model-d"}},
+	}
+	svc, ingestor := newTestService(t, config.GatewayConfig{
+		RecordRouting: true,
+		// the request's own list replaces the configured fallbacks
+		Fallbacks: []config.FallbackConfig{{Model: "primary/model", Fallbacks: []string{"configured/model"}}},
+	}, primary, second, third, configured)
+
+	resp, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "primary/model",
+		Models:   []string{"second/model", "third/model", "configured/model"},
+		Route:    "fallback",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "third/model", resp.Model)
+
+	upstream := third.lastRequest()
+	assert.Equal(t, "model-c", upstream.Model)
+	assert.Empty(t, upstream.Models, "fallbacks are not forwarded upstream")
+	assert.Empty(t, upstream.Route)
+	assert.Empty(t, configured.requests)
+
+	log := ingestor.last(t)
+	assert.Equal(t, "third/model", log.ModelID)
+	require.Len(t, log.Routing, 3)
+	assert.Equal(t, "primary/model", log.Routing[0].ModelID)
+	assert.Equal(t, "second/model", log.Routing[1].ModelID)
+	assert.Equal(t, "third/model", log.Routing[2].ModelID)
+}
+
+func TestStreamChat_RequestModelsReportServingModel(t *testing.T) {
+	primary := &mockProvider{
+		id:      "primary",
+		models:  []api.ModelDefinition{{ID: "primary/model", ProviderID: "primary", UpstreamID: "model-a"}},
+		chatErr: api.NewError(http.StatusServiceUnavailable, "Upstream Provider Error", "overloaded"),
+	}
+	backup := &mockProvider{
+		id:         "backup",
+		models:     []api.ModelDefinition{{ID: "backup/model", ProviderID: "backup", UpstreamID: "model-b"}},
+		streamResp: []api.StreamResult{textDelta("Hello", "")},
+	}
+	svc, _ := newTestService(t, config.GatewayConfig{}, primary, backup)
+
+	ch, err := svc.StreamChat(context.Background(), &api.ChatRequest{
+		Model:    "backup/model",
+		Models:   []string{"primary/model"},
+		Stream:   true,
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+	results := drain(t, ch)
+	require.Len(t, results, 1)
+	assert.Equal(t, "backup/model", results[0].Response.Model)
+}
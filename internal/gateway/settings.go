@@ -0,0 +1,59 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+// ApplyKeySettings applies the per-key settings of the API key found in the
+// context to the request: the key's default model fills in a missing model and
+// any parameter overrides replace the client supplied values.
+// It is safe to call more than once on the same request.
+func (s *service) ApplyKeySettings(ctx context.Context, req *api.ChatRequest) error {
+	apiKey, ok := ctx.Value(store.ContextKeyAPIKey).(*model.APIKey)
+	if !ok {
+		return nil
+	}
+
+	settings, err := apiKey.Settings()
+	if err != nil {
+		return api.InternalError("Invalid API key settings", fmt.Sprintf("settings for key '%s' could not be decoded", apiKey.ID), api.WithLog(err))
+	}
+
+	if req.Model == "" {
+		req.Model = settings.DefaultModel
+	}
+	if req.UseFallbackContent == nil && settings.UseFallbackContent {
+		req.UseFallbackContent = &settings.UseFallbackContent
+	}
+
+	if o := settings.Overrides; o != nil {
+		if o.Temperature != nil {
+			req.Temperature = *o.Temperature
+		}
+		if o.TopP != nil {
+			req.TopP = *o.TopP
+		}
+		if o.TopK != nil {
+			req.TopK = *o.TopK
+		}
+		if o.MaxTokens != nil {
+			req.MaxTokens = *o.MaxTokens
+		}
+		if o.FrequencyPenalty != nil {
+			req.FrequencyPenalty = *o.FrequencyPenalty
+		}
+		if o.PresencePenalty != nil {
+			req.PresencePenalty = *o.PresencePenalty
+		}
+		if o.Seed != nil {
+			req.Seed = *o.Seed
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxShadowRequests caps the shadow requests in flight, requests past it
+	// are not mirrored so shadow traffic can not pile up behind a slow model.
+	maxShadowRequests = 32
+	// shadowTimeout bounds a shadow request, which outlives the client's.
+	shadowTimeout = 2 * time.Minute
+)
+
+// shadowMeta links a shadow request log and the log of the request it
+// mirrors, under shadow_of and shadowed_by respectively.
+type shadowMeta struct {
+	RequestID string `json:"request_id"`
+	Model     string `json:"model"`
+}
+
+// mirror sends a copy of req to the shadow model configured for req.Model,
+// for the configured share of requests, without waiting for it. requestID is
+// the ID of the request it mirrors. It returns the log ID and model of the
+// shadow request, nil when the request is not mirrored.
+func (s *service) mirror(ctx context.Context, req *api.ChatRequest, requestID string) *shadowMeta {
+	for _, shadow := range s.config.ShadowTraffic {
+		if shadow.Model != req.Model || rand.Float64()*100 >= shadow.Percent {
+			continue
+		}
+		select {
+		case s.shadowSlots <- struct{}{}:
+		default:
+			s.logger.Debug("Shadow traffic saturated, not mirroring", zap.String("model", req.Model))
+			return nil
+		}
+
+		// the client going away does not cancel the shadow
+		shadowCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shadowTimeout)
+		shadowReq := *req
+		shadowReq.Model = shadow.Shadow
+		shadowReq.Stream = false
+		shadowReq.StreamOptions = nil
+		shadowReq.Models = nil
+		shadowReq.Provider = nil
+		shadowID := uuid.NewString()
+		go func() {
+			defer func() { <-s.shadowSlots }()
+			defer cancel()
+			s.shadowChat(shadowCtx, &shadowReq, shadowID, requestID, req.Model)
+		}()
+		return &shadowMeta{RequestID: shadowID, Model: shadow.Shadow}
+	}
+	return nil
+}
+
+// withShadow links the log of a mirrored request to its shadow request.
+func withShadow(log *model.RequestLog, shadow *shadowMeta) {
+	if shadow != nil {
+		log.MetaJSON = withMeta(log.MetaJSON, "shadowed_by", *shadow)
+	}
+}
+
+// shadowChat serves a shadow request and logs its outcome under the system
+// identity, so shadow usage is kept apart from the client's.
+func (s *service) shadowChat(ctx context.Context, req *api.ChatRequest, id, requestID, mirroredModel string) {
+	provider, upstreamID, err := s.resolveProvider(req.Model, false, nil, nil)
+	if err != nil {
+		s.logger.Warn("Shadow model could not be routed", zap.String("model", req.Model), zap.Error(err))
+		return
+	}
+
+	r := route{provider: provider, modelID: req.Model, upstreamModelID: upstreamID}
+	start := time.Now()
+	var resp *api.ChatResponse
+	release, err := s.admit(ctx, provider.Name())
+	if err == nil {
+		resp, err = s.chatWithEmptyCheck(ctx, provider, s.upstreamRequest(req, r))
+		release()
+	}
+	latency := time.Since(start)
+
+	log := &model.RequestLog{
+		ID:               id,
+		UserID:           string(api.System),
+		APIKeyID:         string(api.System),
+		MetaJSON:         withMeta(requestMeta(ctx, req, nil), "shadow_of", shadowMeta{RequestID: requestID, Model: mirroredModel}),
+		ProviderID:       provider.Name(),
+		RequestedModelID: req.Model,
+		ModelID:          req.Model,
+		UpstreamModelID:  upstreamID,
+		StatusCode:       http.StatusOK,
+		LatencyMS:        latency.Milliseconds(),
+		CreatedAt:        time.Now(),
+	}
+	if err != nil {
+		log.StatusCode = errorStatus(err)
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.StatusCode = http.StatusGatewayTimeout
+		}
+		log.FinishReason = string(api.FinishReasonError)
+		log.ErrorMessage = err.Error()
+		s.ingestor.Log(log)
+		return
+	}
+
+	log.UpstreamRemoteID = resp.ID
+	if len(resp.Choices) > 0 {
+		log.FinishReason = resp.Choices[0].FinishReason
+	}
+	if s.config.PersistPrompts {
+		log.PromptJSON = marshalPrompt(req.Messages)
+		if len(resp.Choices) > 0 && resp.Choices[0].Message != nil {
+			log.Completion = resp.Choices[0].Message.Content.Text
+			log.Reasoning = resp.Choices[0].Message.Reasoning
+		}
+	}
+	// priced like any request, but not charged to anyone's monthly budget
+	s.accountUsage(log, req.Model, resp.Usage)
+	s.ingestor.Log(log)
+}
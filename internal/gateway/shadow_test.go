@@ -0,0 +1,144 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func shadowMetaOf(t *testing.T, metaJSON string) (shadowOf, shadowedBy *shadowMeta) {
+	t.Helper()
+	var meta struct {
+		ShadowOf   *shadowMeta `json:"shadow_of"`
+		ShadowedBy *shadowMeta `json:"shadowed_by"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(metaJSON), &meta))
+	return meta.ShadowOf, meta.ShadowedBy
+}
+
+func newShadowService(t *testing.T) (*service, *captureIngestor, *mockProvider, *mockProvider) {
+	primary := &mockProvider{id: "primary", models: []api.ModelDefinition{
+		{ID: "primary/model", ProviderID: "primary"},
+		{ID: "primary/other", ProviderID: "primary"},
+	}}
+	candidate := &mockProvider{
+		id:       "candidate",
+		models:   []api.ModelDefinition{{ID: "candidate/model", ProviderID: "candidate", UpstreamID: "model-c"}},
+		chatResp: &api.ChatResponse{ID: "shadow-id", Choices: []api.Choice{{Message: &api.ChatMessage{Content: api.Content{Text: "From candidate"}}, FinishReason: "stop"}}},
+	}
+	svc, ingestor := newTestService(t, config.GatewayConfig{
+		ShadowTraffic: []config.ShadowRoute{{Model: "primary/model", Shadow: "candidate/model", Percent: 100}},
+	}, primary, candidate)
+	return svc, ingestor, primary, candidate
+}
+
+// waitForLogs waits for n request logs, the shadow one being written in the
+// background.
+func waitForLogs(t *testing.T, ingestor *captureIngestor, n int) []*model.RequestLog {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		ingestor.mu.Lock()
+		defer ingestor.mu.Unlock()
+		return len(ingestor.logs) == n
+	}, time.Second, 5*time.Millisecond)
+
+	ingestor.mu.Lock()
+	defer ingestor.mu.Unlock()
+	return append([]*model.RequestLog(nil), ingestor.logs...)
+}
+
+func TestChat_ShadowTraffic(t *testing.T) {
+	svc, ingestor, primary, candidate := newShadowService(t)
+
+	resp, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "primary/model",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Mock Response", resp.Choices[0].Message.Content.Text, "the shadow response is never returned")
+	assert.Len(t, primary.requests, 1)
+
+	logs := waitForLogs(t, ingestor, 2)
+	var served, shadow *model.RequestLog
+	for _, log := range logs {
+		if log.ProviderID == "candidate" {
+			shadow = log
+		} else {
+			served = log
+		}
+	}
+	require.NotNil(t, served)
+	require.NotNil(t, shadow)
+	assert.Equal(t, "model-c", candidate.lastRequest().Model)
+
+	assert.Equal(t, string(api.System), shadow.UserID)
+	assert.Equal(t, string(api.System), shadow.APIKeyID)
+	assert.Equal(t, "candidate/model", shadow.ModelID)
+	assert.Equal(t, "shadow-id", shadow.UpstreamRemoteID)
+	assert.Equal(t, http.StatusOK, shadow.StatusCode)
+
+	shadowOf, _ := shadowMetaOf(t, shadow.MetaJSON)
+	require.NotNil(t, shadowOf)
+	assert.Equal(t, shadowMeta{RequestID: served.ID, Model: "primary/model"}, *shadowOf)
+	_, shadowedBy := shadowMetaOf(t, served.MetaJSON)
+	require.NotNil(t, shadowedBy)
+	assert.Equal(t, shadowMeta{RequestID: shadow.ID, Model: "candidate/model"}, *shadowedBy)
+
+	// other models are not mirrored
+	_, err = svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "primary/other",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+	waitForLogs(t, ingestor, 3)
+	assert.Len(t, candidate.requests, 1)
+}
+
+func TestChat_ShadowFailureIsLoggedOnly(t *testing.T) {
+	svc, ingestor, _, candidate := newShadowService(t)
+	candidate.chatErr = api.NewError(http.StatusServiceUnavailable, "Upstream Provider Error", "overloaded")
+
+	_, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Model:    "primary/model",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+
+	logs := waitForLogs(t, ingestor, 2)
+	for _, log := range logs {
+		if log.ProviderID == "candidate" {
+			assert.Equal(t, http.StatusServiceUnavailable, log.StatusCode)
+			assert.Equal(t, string(api.FinishReasonError), log.FinishReason)
+		} else {
+			assert.Equal(t, http.StatusOK, log.StatusCode)
+		}
+	}
+}
+
+func TestStreamChat_ShadowTraffic(t *testing.T) {
+	svc, ingestor, primary, candidate := newShadowService(t)
+	primary.streamResp = []api.StreamResult{textDelta("Hello", "")}
+
+	ch, err := svc.StreamChat(context.Background(), &api.ChatRequest{
+		Model:    "primary/model",
+		Stream:   true,
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+	results := drain(t, ch)
+	require.NotEmpty(t, results)
+	assert.Equal(t, "Hello", results[0].Response.Choices[0].Delta.Content.Text)
+
+	waitForLogs(t, ingestor, 2)
+	req := candidate.lastRequest()
+	assert.False(t, req.Stream, "shadow requests are not streamed")
+	assert.Nil(t, req.StreamOptions)
+}
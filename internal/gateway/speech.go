@@ -0,0 +1,97 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/nulzo/model-router-api/internal/llm"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+// Speech synthesizes req.Input with the provider serving req.Model. It is
+// gated, logged and billed like a chat request, speech models are priced per
+// 1k input characters through their input token rate.
+func (s *service) Speech(ctx context.Context, req *api.SpeechRequest) (*api.SpeechResponse, error) {
+	if err := s.checkMonthlyBudget(ctx); err != nil {
+		return nil, err
+	}
+	if err := checkModelAccess(ctx, req.Model); err != nil {
+		return nil, err
+	}
+	if err := s.checkSpeechBalance(ctx, req); err != nil {
+		return nil, err
+	}
+
+	provider, upstreamModelID, err := s.GetProviderForModel(ctx, req.Model)
+	if err != nil {
+		return nil, err
+	}
+	synth, ok := provider.(llm.SpeechSynthesizer)
+	if !ok {
+		return nil, api.NewError(http.StatusBadRequest, "Speech Not Supported",
+			fmt.Sprintf("model '%s' can not synthesize speech", req.Model))
+	}
+
+	upstreamReq := *req
+	upstreamReq.Model = upstreamModelID
+
+	start := time.Now()
+	var resp *api.SpeechResponse
+	release, err := s.admit(ctx, provider.Name())
+	if err == nil {
+		resp, err = synth.Speech(ctx, &upstreamReq)
+		release()
+	}
+	latency := time.Since(start)
+
+	userID, apiKeyID, appName := requestIdentity(ctx)
+	log := &model.RequestLog{
+		ID:              uuid.NewString(),
+		UserID:          userID,
+		APIKeyID:        apiKeyID,
+		AppName:         appName,
+		ProviderID:      provider.Name(),
+		ModelID:         req.Model,
+		UpstreamModelID: upstreamModelID,
+		FinishReason:    string(api.FinishReasonStop),
+		StatusCode:      http.StatusOK,
+		LatencyMS:       latency.Milliseconds(),
+		CreatedAt:       time.Now(),
+	}
+	if err != nil {
+		log.StatusCode = errorStatus(err)
+		log.FinishReason = string(api.FinishReasonError)
+		log.ErrorMessage = err.Error()
+		s.ingestor.Log(log)
+		return nil, err
+	}
+
+	if resp.Characters == 0 {
+		resp.Characters = utf8.RuneCountInString(req.Input)
+	}
+	s.accountUsage(log, req.Model, &api.ResponseUsage{PromptTokens: resp.Characters, TotalTokens: resp.Characters})
+	s.recordSpend(log)
+	s.ingestor.Log(log)
+
+	return resp, nil
+}
+
+// requestIdentity returns who a request is logged against: the calling key
+// and its user, anonymous for app attributed requests without a key, and the
+// system otherwise.
+func requestIdentity(ctx context.Context) (userID, apiKeyID, appName string) {
+	appName = appNameFromContext(ctx)
+	if apiKey, ok := ctx.Value(store.ContextKeyAPIKey).(*model.APIKey); ok {
+		return apiKey.UserID, apiKey.ID, appName
+	}
+	if appName != "" {
+		return string(api.Anonymous), string(api.Anonymous), appName
+	}
+	return string(api.System), string(api.System), appName
+}
@@ -0,0 +1,87 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// speechProvider synthesizes fixed audio.
+type speechProvider struct {
+	*mockProvider
+	last *api.SpeechRequest
+}
+
+func (p *speechProvider) Speech(ctx context.Context, req *api.SpeechRequest) (*api.SpeechResponse, error) {
+	p.last = req
+	return &api.SpeechResponse{Audio: []byte("audio"), ContentType: "audio/mpeg"}, nil
+}
+
+func TestSpeech_IsLoggedAndBilled(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	require.NoError(t, repo.Providers().SyncProviders(ctx, []model.Provider{{ID: "tts", Name: "tts", ConfigJSON: "{}", IsEnabled: true}}))
+	// $300 per 1M characters
+	require.NoError(t, repo.Providers().SyncModels(ctx, []model.Model{{
+		ID: "tts/voice", ProviderID: "tts", ProviderModelID: "voice-v2", IsEnabled: true, InputCostMicrosPer1k: 300000,
+	}}))
+
+	tts := &speechProvider{mockProvider: &mockProvider{id: "tts", models: []api.ModelDefinition{{ID: "tts/voice", ProviderID: "tts", UpstreamID: "voice-v2"}}}}
+	chat := &mockProvider{id: "chat", models: []api.ModelDefinition{{ID: "chat/model", ProviderID: "chat"}}}
+	svc, ingestor := newTestServiceWith(t, zap.NewNop(), repo, config.GatewayConfig{}, chat)
+	require.NoError(t, svc.RegisterProvider(ctx, tts))
+
+	keyCtx := context.WithValue(ctx, store.ContextKeyAPIKey, &model.APIKey{ID: "key-1", UserID: "user-1"})
+	resp, err := svc.Speech(keyCtx, &api.SpeechRequest{Model: "tts/voice", Input: "Hello there"})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("audio"), resp.Audio)
+	assert.Equal(t, "voice-v2", tts.last.Model)
+
+	log := ingestor.last(t)
+	assert.Equal(t, "tts", log.ProviderID)
+	assert.Equal(t, "tts/voice", log.ModelID)
+	assert.Equal(t, "key-1", log.APIKeyID)
+	assert.Equal(t, 11, log.InputTokens, "characters are billed as input")
+	assert.Equal(t, int64(11*300000/1000), log.TotalCostMicros)
+
+	_, err = svc.Speech(keyCtx, &api.SpeechRequest{Model: "chat/model", Input: "Hello"})
+	require.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, errorStatus(err))
+}
+
+func TestSpeech_RejectsInputBeyondBalance(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	require.NoError(t, repo.Providers().SyncProviders(ctx, []model.Provider{{ID: "tts", Name: "tts", ConfigJSON: "{}", IsEnabled: true}}))
+	require.NoError(t, repo.Providers().SyncModels(ctx, []model.Model{{
+		ID: "tts/voice", ProviderID: "tts", ProviderModelID: "voice-v2", IsEnabled: true, InputCostMicrosPer1k: 300000,
+	}}))
+	now := time.Now()
+	require.NoError(t, repo.Users().Create(ctx, &model.User{ID: "user-1", Email: "user-1@example.com", Name: "user-1", Role: "user", CreatedAt: now, UpdatedAt: now}))
+	require.NoError(t, repo.Users().CreateWallet(ctx, &model.Wallet{ID: "wallet-1", UserID: "user-1", BalanceMicros: 1000, Currency: "USD", CreatedAt: now, UpdatedAt: now}))
+
+	tts := &speechProvider{mockProvider: &mockProvider{id: "tts", models: []api.ModelDefinition{{ID: "tts/voice", ProviderID: "tts", UpstreamID: "voice-v2"}}}}
+	svc, ingestor := newTestServiceWith(t, zap.NewNop(), repo, config.GatewayConfig{BalanceTokenCap: true})
+	require.NoError(t, svc.RegisterProvider(ctx, tts))
+	keyCtx := context.WithValue(ctx, store.ContextKeyAPIKey, &model.APIKey{ID: "key-1", UserID: "user-1"})
+
+	// 11 characters cost 3300 micros
+	_, err := svc.Speech(keyCtx, &api.SpeechRequest{Model: "tts/voice", Input: "Hello there"})
+	require.Error(t, err)
+	assert.Equal(t, http.StatusPaymentRequired, errorStatus(err))
+	assert.Nil(t, tts.last, "nothing is synthesized")
+
+	// 3 characters cost 900 micros
+	_, err = svc.Speech(keyCtx, &api.SpeechRequest{Model: "tts/voice", Input: "Hey"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(900), ingestor.last(t).TotalCostMicros)
+}
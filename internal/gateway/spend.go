@@ -0,0 +1,139 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/cache"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"go.uber.org/zap"
+)
+
+// defaultSpendReconcileInterval applies when no interval is configured.
+const defaultSpendReconcileInterval = 15 * time.Minute
+
+// checkMonthlyBudget rejects the request with a 402 once the calling user
+// spent the configured monthly limit, or the calling key its own
+// monthly_limit_micros. Callers without a key, and deployments without a
+// cache, are not gated.
+func (s *service) checkMonthlyBudget(ctx context.Context) error {
+	apiKey, ok := ctx.Value(store.ContextKeyAPIKey).(*model.APIKey)
+	if !ok || s.cache == nil {
+		return nil
+	}
+	now := time.Now()
+
+	if limit := s.config.MonthlyBudget.LimitMicros; limit > 0 {
+		spent, err := s.monthlySpend(ctx, apiKey.UserID, now)
+		if err != nil {
+			// an unavailable counter must not take the gateway down
+			s.logger.Warn("Failed to load monthly spend", zap.String("user_id", apiKey.UserID), zap.Error(err))
+		} else if spent >= limit {
+			return budgetExceeded("spent %d of the %d micros allowed this month", spent, limit)
+		}
+	}
+
+	if apiKey.MonthlyLimitMicros.Valid {
+		limit := apiKey.MonthlyLimitMicros.Int64
+		spent, err := s.keyMonthlySpend(ctx, apiKey.ID, now)
+		if err != nil {
+			s.logger.Warn("Failed to load monthly key spend", zap.String("key_id", apiKey.ID), zap.Error(err))
+		} else if spent >= limit {
+			return budgetExceeded("this API key spent %d of the %d micros allowed this month", spent, limit)
+		}
+	}
+	return nil
+}
+
+func budgetExceeded(format string, spent, limit int64) error {
+	return api.NewError(http.StatusPaymentRequired, "Monthly Budget Exceeded",
+		fmt.Sprintf(format, spent, limit),
+		api.WithExtension("spent_micros", spent),
+		api.WithExtension("limit_micros", limit),
+	)
+}
+
+// monthlySpend returns the user's spend this month from the cache counter,
+// rebuilding it from the request logs when it is missing or due for
+// reconciliation. Logs still buffered by the ingestor are missed by a
+// rebuild until the next one.
+func (s *service) monthlySpend(ctx context.Context, userID string, now time.Time) (int64, error) {
+	return s.spendCounter(ctx, spendKey(userID, now), now, func(since time.Time) (int64, error) {
+		return s.repo.Requests().SumUserCost(ctx, userID, since)
+	})
+}
+
+// keyMonthlySpend is monthlySpend for a single API key.
+func (s *service) keyMonthlySpend(ctx context.Context, keyID string, now time.Time) (int64, error) {
+	return s.spendCounter(ctx, keySpendKey(keyID, now), now, func(since time.Time) (int64, error) {
+		return s.repo.Requests().SumKeyCost(ctx, keyID, since)
+	})
+}
+
+// spendCounter reads the counter at key, rebuilding it with sum when it is
+// missing. The rebuilt value is only stored when no other request stored
+// one meanwhile, which may already have been incremented.
+func (s *service) spendCounter(ctx context.Context, key string, now time.Time, sum func(since time.Time) (int64, error)) (int64, error) {
+	var spent int64
+	if err := s.cache.Get(ctx, key, &spent); err == nil {
+		return spent, nil
+	}
+
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	spent, err := sum(monthStart)
+	if err != nil {
+		return 0, err
+	}
+
+	ttl := s.config.MonthlyBudget.ReconcileInterval
+	if ttl <= 0 {
+		ttl = defaultSpendReconcileInterval
+	}
+	// a new month starts a new counter
+	ttl = min(ttl, monthStart.AddDate(0, 1, 0).Sub(now))
+	stored, err := s.cache.SetNX(ctx, key, spent, ttl)
+	if err != nil {
+		return 0, err
+	}
+	if !stored {
+		if err := s.cache.Get(ctx, key, &spent); err != nil {
+			return 0, err
+		}
+	}
+	return spent, nil
+}
+
+// recordSpend adds the cost of a finished request to its user's and key's
+// counters. A missing counter is left for the next budget check to rebuild.
+func (s *service) recordSpend(log *model.RequestLog) {
+	if s.cache == nil || log.TotalCostMicros <= 0 {
+		return
+	}
+	if s.config.MonthlyBudget.LimitMicros > 0 {
+		s.incrSpend(spendKey(log.UserID, log.CreatedAt), log.TotalCostMicros)
+	}
+	// only keys with a limit have a counter
+	if log.APIKeyID != "" {
+		s.incrSpend(keySpendKey(log.APIKeyID, log.CreatedAt), log.TotalCostMicros)
+	}
+}
+
+func (s *service) incrSpend(key string, cost int64) {
+	_, err := s.cache.IncrBy(context.Background(), key, cost)
+	if err != nil && !errors.Is(err, cache.ErrNotFound) {
+		s.logger.Warn("Failed to record monthly spend", zap.String("counter", key), zap.Error(err))
+	}
+}
+
+func spendKey(userID string, t time.Time) string {
+	return "spend:" + userID + ":" + t.Format("2006-01")
+}
+
+func keySpendKey(keyID string, t time.Time) string {
+	return "keyspend:" + keyID + ":" + t.Format("2006-01")
+}
@@ -0,0 +1,150 @@
+package gateway
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/cache"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// sumCountingRepo counts the spend aggregations run against the request logs.
+type sumCountingRepo struct {
+	store.Repository
+	sums atomic.Int32
+}
+
+func (r *sumCountingRepo) Requests() store.RequestRepository {
+	return &sumCountingRequests{RequestRepository: r.Repository.Requests(), repo: r}
+}
+
+type sumCountingRequests struct {
+	store.RequestRepository
+	repo *sumCountingRepo
+}
+
+func (r *sumCountingRequests) SumUserCost(ctx context.Context, userID string, since time.Time) (int64, error) {
+	r.repo.sums.Add(1)
+	return r.RequestRepository.SumUserCost(ctx, userID, since)
+}
+
+func TestChat_MonthlyBudgetTracksSpendInCache(t *testing.T) {
+	ctx := context.Background()
+	repo := &sumCountingRepo{Repository: newTestRepo(t)}
+	require.NoError(t, repo.Providers().SyncProviders(ctx, []model.Provider{{ID: "mock", Name: "mock", ConfigJSON: "{}", IsEnabled: true}}))
+	require.NoError(t, repo.Providers().SyncModels(ctx, []model.Model{{
+		ID: "mock/model", ProviderID: "mock", ProviderModelID: "model", IsEnabled: true,
+		InputCostMicrosPer1k: 1000, OutputCostMicrosPer1k: 2000,
+	}}))
+	// spent earlier this month, before the counter existed
+	require.NoError(t, repo.Requests().Log(ctx, &model.RequestLog{
+		ID: "earlier", UserID: "user-1", APIKeyID: "key-1", ProviderID: "mock", ModelID: "mock/model",
+		TotalCostMicros: 2000, StatusCode: 200, CreatedAt: time.Now(),
+	}))
+
+	// every request costs 1000 + 2000 micros
+	provider := &mockProvider{
+		id:     "mock",
+		models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+		chatResp: &api.ChatResponse{
+			Choices: []api.Choice{{Message: &api.ChatMessage{Role: "assistant", Content: api.Content{Text: "Hi"}}, FinishReason: "stop"}},
+			Usage:   &api.ResponseUsage{PromptTokens: 1000, CompletionTokens: 1000, TotalTokens: 2000},
+		},
+	}
+	svc, _ := newTestServiceWith(t, zap.NewNop(), repo, config.GatewayConfig{
+		MonthlyBudget: config.MonthlyBudgetConfig{LimitMicros: 7000, ReconcileInterval: time.Hour},
+	}, provider)
+	svc.cache = cache.NewMemoryCache()
+
+	keyCtx := context.WithValue(ctx, store.ContextKeyAPIKey, &model.APIKey{ID: "key-1", UserID: "user-1"})
+	chat := func() error {
+		_, err := svc.Chat(keyCtx, &api.ChatRequest{
+			Model:    "mock/model",
+			Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+		})
+		return err
+	}
+
+	require.NoError(t, chat())
+	spent, err := svc.monthlySpend(ctx, "user-1", time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(5000), spent)
+
+	require.NoError(t, chat())
+	err = chat()
+	require.Error(t, err)
+	assert.Equal(t, http.StatusPaymentRequired, errorStatus(err))
+	assert.Len(t, provider.requests, 2, "the over budget request is not sent")
+
+	assert.Equal(t, int32(1), repo.sums.Load(), "the logs are only summed to build the counter")
+}
+
+func TestChat_KeyMonthlyLimit(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepo(t)
+	require.NoError(t, repo.Providers().SyncProviders(ctx, []model.Provider{{ID: "mock", Name: "mock", ConfigJSON: "{}", IsEnabled: true}}))
+	require.NoError(t, repo.Providers().SyncModels(ctx, []model.Model{{
+		ID: "mock/model", ProviderID: "mock", ProviderModelID: "model", IsEnabled: true,
+		InputCostMicrosPer1k: 1000, OutputCostMicrosPer1k: 2000,
+	}}))
+
+	// every request costs 1000 + 2000 micros
+	provider := &mockProvider{
+		id:     "mock",
+		models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock", UpstreamID: "model"}},
+		chatResp: &api.ChatResponse{
+			Choices: []api.Choice{{Message: &api.ChatMessage{Role: "assistant", Content: api.Content{Text: "Hi"}}, FinishReason: "stop"}},
+			Usage:   &api.ResponseUsage{PromptTokens: 1000, CompletionTokens: 1000, TotalTokens: 2000},
+		},
+	}
+	// no user limit configured, the key's own limit applies
+	svc, _ := newTestServiceWith(t, zap.NewNop(), repo, config.GatewayConfig{}, provider)
+	svc.cache = cache.NewMemoryCache()
+
+	limited := &model.APIKey{ID: "key-1", UserID: "user-1", MonthlyLimitMicros: sql.NullInt64{Int64: 5000, Valid: true}}
+	chat := func(key *model.APIKey) error {
+		_, err := svc.Chat(context.WithValue(ctx, store.ContextKeyAPIKey, key), &api.ChatRequest{
+			Model:    "mock/model",
+			Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+		})
+		return err
+	}
+
+	require.NoError(t, chat(limited))
+	require.NoError(t, chat(limited))
+	err := chat(limited)
+	assert.Equal(t, http.StatusPaymentRequired, errorStatus(err))
+	assert.ErrorContains(t, err, "this API key spent 6000 of the 5000 micros")
+
+	// the user's other keys are not held to it
+	require.NoError(t, chat(&model.APIKey{ID: "key-2", UserID: "user-1"}))
+}
+
+func TestSpendCounter_RebuildKeepsConcurrentCounter(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := newTestService(t, config.GatewayConfig{})
+	svc.cache = cache.NewMemoryCache()
+	now := time.Now()
+
+	spent, err := svc.spendCounter(ctx, "spend:user-1", now, func(time.Time) (int64, error) {
+		// another request rebuilt and incremented the counter meanwhile
+		require.NoError(t, svc.cache.Set(ctx, "spend:user-1", int64(9000), time.Hour))
+		return 2000, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(9000), spent)
+
+	var stored int64
+	require.NoError(t, svc.cache.Get(ctx, "spend:user-1", &stored))
+	assert.Equal(t, int64(9000), stored, "the newer counter is not overwritten")
+}
@@ -0,0 +1,38 @@
+package gateway
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+// StreamStats is the number of open streams and the configured cap, zero
+// when streams are not capped.
+type StreamStats struct {
+	Active int64 `json:"active"`
+	Max    int   `json:"max"`
+}
+
+func (s *service) StreamStats() StreamStats {
+	return StreamStats{Active: s.streams.Load(), Max: s.config.MaxActiveStreams}
+}
+
+// acquireStream counts a new stream, rejecting it with a 503 when the
+// configured cap is already reached. Every successful call must be paired
+// with releaseStream.
+func (s *service) acquireStream() error {
+	limit := int64(s.config.MaxActiveStreams)
+	if n := s.streams.Add(1); limit > 0 && n > limit {
+		s.streams.Add(-1)
+		return api.NewError(http.StatusServiceUnavailable, "Too Many Streams",
+			fmt.Sprintf("the gateway is serving its maximum of %d concurrent streams, retry shortly", limit),
+			api.WithExtension("limit", limit),
+		)
+	}
+	return nil
+}
+
+func (s *service) releaseStream() {
+	s.streams.Add(-1)
+}
@@ -0,0 +1,77 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nulzo/model-router-api/internal/llm"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+// Transcribe transcribes the uploaded audio with the provider serving
+// req.Model. Transcription models are priced per 1k seconds of audio through
+// their input token rate, partial seconds are rounded up.
+func (s *service) Transcribe(ctx context.Context, req *api.TranscriptionRequest) (*api.TranscriptionResponse, error) {
+	if err := s.checkMonthlyBudget(ctx); err != nil {
+		return nil, err
+	}
+	if err := checkModelAccess(ctx, req.Model); err != nil {
+		return nil, err
+	}
+
+	provider, upstreamModelID, err := s.GetProviderForModel(ctx, req.Model)
+	if err != nil {
+		return nil, err
+	}
+	transcriber, ok := provider.(llm.Transcriber)
+	if !ok {
+		return nil, api.NewError(http.StatusBadRequest, "Transcription Not Supported",
+			fmt.Sprintf("model '%s' can not transcribe audio", req.Model))
+	}
+
+	upstreamReq := *req
+	upstreamReq.Model = upstreamModelID
+
+	start := time.Now()
+	var resp *api.TranscriptionResponse
+	release, err := s.admit(ctx, provider.Name())
+	if err == nil {
+		resp, err = transcriber.Transcribe(ctx, &upstreamReq)
+		release()
+	}
+	latency := time.Since(start)
+
+	userID, apiKeyID, appName := requestIdentity(ctx)
+	log := &model.RequestLog{
+		ID:              uuid.NewString(),
+		UserID:          userID,
+		APIKeyID:        apiKeyID,
+		AppName:         appName,
+		ProviderID:      provider.Name(),
+		ModelID:         req.Model,
+		UpstreamModelID: upstreamModelID,
+		FinishReason:    string(api.FinishReasonStop),
+		StatusCode:      http.StatusOK,
+		LatencyMS:       latency.Milliseconds(),
+		CreatedAt:       time.Now(),
+	}
+	if err != nil {
+		log.StatusCode = errorStatus(err)
+		log.FinishReason = string(api.FinishReasonError)
+		log.ErrorMessage = err.Error()
+		s.ingestor.Log(log)
+		return nil, err
+	}
+
+	seconds := int(math.Ceil(resp.Duration))
+	s.accountUsage(log, req.Model, &api.ResponseUsage{PromptTokens: seconds, TotalTokens: seconds})
+	s.recordSpend(log)
+	s.ingestor.Log(log)
+
+	return resp, nil
+}
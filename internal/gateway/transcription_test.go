@@ -0,0 +1,57 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// transcriptionProvider transcribes every file to the same text.
+type transcriptionProvider struct {
+	*mockProvider
+	last *api.TranscriptionRequest
+}
+
+func (p *transcriptionProvider) Transcribe(ctx context.Context, req *api.TranscriptionRequest) (*api.TranscriptionResponse, error) {
+	p.last = req
+	return &api.TranscriptionResponse{Text: "Hello there", Duration: 41.2}, nil
+}
+
+func TestTranscribe_IsLoggedAndBilled(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	require.NoError(t, repo.Providers().SyncProviders(ctx, []model.Provider{{ID: "asr", Name: "asr", ConfigJSON: "{}", IsEnabled: true}}))
+	// $0.006 per minute, $100 per 1M seconds
+	require.NoError(t, repo.Providers().SyncModels(ctx, []model.Model{{
+		ID: "asr/whisper", ProviderID: "asr", ProviderModelID: "whisper-1", IsEnabled: true, InputCostMicrosPer1k: 100000,
+	}}))
+
+	asr := &transcriptionProvider{mockProvider: &mockProvider{id: "asr", models: []api.ModelDefinition{{ID: "asr/whisper", ProviderID: "asr", UpstreamID: "whisper-1"}}}}
+	chat := &mockProvider{id: "chat", models: []api.ModelDefinition{{ID: "chat/model", ProviderID: "chat"}}}
+	svc, ingestor := newTestServiceWith(t, zap.NewNop(), repo, config.GatewayConfig{}, chat)
+	require.NoError(t, svc.RegisterProvider(ctx, asr))
+
+	keyCtx := context.WithValue(ctx, store.ContextKeyAPIKey, &model.APIKey{ID: "key-1", UserID: "user-1"})
+	resp, err := svc.Transcribe(keyCtx, &api.TranscriptionRequest{Model: "asr/whisper", File: []byte("audio"), Filename: "a.mp3"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello there", resp.Text)
+	assert.Equal(t, "whisper-1", asr.last.Model)
+
+	log := ingestor.last(t)
+	assert.Equal(t, "asr", log.ProviderID)
+	assert.Equal(t, "key-1", log.APIKeyID)
+	assert.Equal(t, 42, log.InputTokens, "seconds are billed as input, rounded up")
+	assert.Equal(t, int64(42*100000/1000), log.TotalCostMicros)
+
+	_, err = svc.Transcribe(keyCtx, &api.TranscriptionRequest{Model: "chat/model", File: []byte("audio")})
+	require.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, errorStatus(err))
+}
@@ -0,0 +1,81 @@
+package gateway
+
+import (
+	"context"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+// accountUsage records the usage a provider reported for a request on its log
+// and prices it. Unary and streamed requests both go through it, so the same
+// usage is logged and billed the same way whichever way it was delivered.
+// Without reported usage nothing is billed, models we have no pricing for
+// are billed the cost the provider reported, if any.
+func (s *service) accountUsage(log *model.RequestLog, modelID string, usage *api.ResponseUsage) {
+	if usage == nil {
+		return
+	}
+
+	log.InputTokens = usage.PromptTokens
+	log.OutputTokens = usage.CompletionTokens
+	log.UsageDetails = usageDetails(usage)
+	log.CachedTokens = log.UsageDetails.PromptTokensCached
+
+	pricing, err := s.repo.Providers().GetModelPricing(context.Background(), modelID)
+	if err != nil || pricing == nil {
+		if upstream := log.UsageDetails.UpstreamCostMicros; upstream != nil {
+			log.TotalCostMicros = *upstream
+			log.UsageDetails.CostMicros = &log.TotalCostMicros
+		}
+		return
+	}
+	log.TotalCostMicros = costMicros(pricing, usage.PromptTokens, usage.CompletionTokens, usage.PromptTokensDetails)
+	log.UsageDetails.CostMicros = &log.TotalCostMicros
+	s.reconcileCost(log)
+}
+
+// usageDetails maps the reported usage breakdown onto the request log.
+func usageDetails(usage *api.ResponseUsage) *model.UsageDetails {
+	details := &model.UsageDetails{}
+
+	if d := usage.PromptTokensDetails; d != nil {
+		details.PromptTokensCached = d.CachedTokens
+		details.PromptTokensCacheWrite = d.CacheWriteTokens
+		details.PromptTokensAudio = d.AudioTokens
+		details.PromptTokensVideo = d.VideoTokens
+	}
+	if d := usage.CompletionTokensDetails; d != nil {
+		details.CompletionTokensReasoning = d.ReasoningTokens
+		details.CompletionTokensImage = d.ImageTokens
+	}
+	if usage.ServerToolUse != nil {
+		details.WebSearchRequests = usage.ServerToolUse.WebSearchRequests
+	}
+	if usage.CostDetails != nil {
+		details.UpstreamPromptCostMicros = int64(usage.CostDetails.UpstreamInferencePromptCost * 1000000)
+		details.UpstreamCompletionCostMicros = int64(usage.CostDetails.UpstreamInferenceCompletionCost * 1000000)
+	}
+	details.UpstreamCostMicros = upstreamCostMicros(usage)
+	if usage.IsBYOK != nil {
+		details.IsBYOK = *usage.IsBYOK
+	}
+	if native := usage.NativeTokens; native != nil {
+		details.NativePromptTokens = &native.Prompt
+		details.NativeCompletionTokens = &native.Completion
+	}
+	if timing := usage.Timing; timing != nil {
+		details.UpstreamQueueMS = durationMS(timing.Queue)
+		details.UpstreamPromptMS = durationMS(timing.Prompt)
+		details.UpstreamCompletionMS = durationMS(timing.Completion)
+		details.UpstreamTotalMS = durationMS(timing.Total)
+	}
+
+	return details
+}
+
+func durationMS(d time.Duration) *int64 {
+	ms := d.Milliseconds()
+	return &ms
+}
@@ -0,0 +1,24 @@
+package gateway
+
+import "math/rand/v2"
+
+// pickWeighted picks one of targets at random, each with a chance
+// proportional to its weight.
+func pickWeighted(targets []routeTarget) routeTarget {
+	total := 0
+	for _, t := range targets {
+		total += t.weight
+	}
+	if total <= 0 {
+		return targets[0]
+	}
+
+	n := rand.IntN(total)
+	for _, t := range targets {
+		if n < t.weight {
+			return t
+		}
+		n -= t.weight
+	}
+	return targets[len(targets)-1]
+}
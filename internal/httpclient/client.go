@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 )
 
 // HTTPClient defines the interface for an HTTP http
@@ -16,19 +17,68 @@ type HTTPClient interface {
 }
 
 // SendRequest handles the common logic of creating a request, sending it, and checking the status code.
+// The response body is read in full (up to the configured limit) before it is
+// decoded, so a body cut off by the network fails as a network error while a
+// complete but invalid body fails with a *DecodeError carrying the raw bytes.
+// Network failures are retried per the configured policy.
 func SendRequest(ctx context.Context, client HTTPClient, method, url string, headers map[string]string, body interface{}, response interface{}) error {
-	var bodyReader io.Reader
+	var jsonBody []byte
 	if body != nil {
-		jsonBody, err := json.Marshal(body)
+		var err error
+		jsonBody, err = json.Marshal(body)
 		if err != nil {
 			return fmt.Errorf("failed to marshal request body: %w", err)
 		}
-		bodyReader = bytes.NewBuffer(jsonBody)
+	}
+
+	policy := currentPolicy()
+
+	var (
+		status   int
+		header   http.Header
+		respBody []byte
+		err      error
+	)
+	for attempt := 0; ; attempt++ {
+		status, header, respBody, err = send(ctx, client, method, url, headers, jsonBody, policy.maxBodyBytes)
+		if err == nil || attempt >= policy.retries || !isNetworkError(err) || ctx.Err() != nil {
+			break
+		}
+
+		select {
+		case <-time.After(time.Duration(attempt+1) * policy.backoff):
+		case <-ctx.Done():
+			return err
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	// Check for non-200 status codes
+	if status < 200 || status >= 300 {
+		return newUpstreamError(status, header, respBody, url)
+	}
+
+	if response != nil {
+		if err := json.Unmarshal(respBody, response); err != nil {
+			return &DecodeError{Body: respBody, URL: url, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// send performs a single attempt and returns the status, headers and the full body.
+func send(ctx context.Context, client HTTPClient, method, url string, headers map[string]string, jsonBody []byte, maxBodyBytes int64) (int, http.Header, []byte, error) {
+	var bodyReader io.Reader
+	if jsonBody != nil {
+		bodyReader = bytes.NewReader(jsonBody)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return 0, nil, nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -38,29 +88,21 @@ func SendRequest(ctx context.Context, client HTTPClient, method, url string, hea
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+		return 0, nil, nil, &networkError{err: fmt.Errorf("request failed: %w", err)}
 	}
 	defer func() {
 		_ = resp.Body.Close()
 	}()
 
-	// Check for non-200 status codes
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		respBody, _ := io.ReadAll(resp.Body)
-		return &UpstreamError{
-			StatusCode: resp.StatusCode,
-			Body:       respBody,
-			URL:        url,
-		}
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxBodyBytes+1))
+	if err != nil {
+		return 0, nil, nil, &networkError{err: fmt.Errorf("failed to read response body: %w", err)}
 	}
-
-	if response != nil {
-		if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
-			return fmt.Errorf("failed to decode response: %w", err)
-		}
+	if int64(len(respBody)) > maxBodyBytes {
+		return 0, nil, nil, ErrResponseTooLarge
 	}
 
-	return nil
+	return resp.StatusCode, resp.Header, respBody, nil
 }
 
 type LineProcessor func(line string) error
@@ -84,6 +126,8 @@ func StreamRequest(ctx context.Context, client HTTPClient, method, url string, h
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "text/event-stream")
+	// a compressed event stream would be buffered by the decompressor
+	req.Header.Set("Accept-Encoding", "identity")
 	for k, v := range headers {
 		req.Header.Set(k, v)
 	}
@@ -99,11 +143,7 @@ func StreamRequest(ctx context.Context, client HTTPClient, method, url string, h
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		respBody, _ := io.ReadAll(resp.Body)
-		return &UpstreamError{
-			StatusCode: resp.StatusCode,
-			Body:       respBody,
-			URL:        url,
-		}
+		return newUpstreamError(resp.StatusCode, resp.Header, respBody, url)
 	}
 
 	scanner := bufio.NewScanner(resp.Body)
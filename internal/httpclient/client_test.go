@@ -0,0 +1,258 @@
+package httpclient
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withPolicy(t *testing.T, p policy) {
+	transportMu.Lock()
+	previous := sharedPolicy
+	sharedPolicy = p
+	transportMu.Unlock()
+
+	t.Cleanup(func() {
+		transportMu.Lock()
+		sharedPolicy = previous
+		transportMu.Unlock()
+	})
+}
+
+// truncatingServer cuts off the first `truncated` responses mid-body.
+func truncatingServer(truncated int32) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := `{"id":"resp-1"}`
+		if calls.Add(1) <= truncated {
+			// promise more than is sent, the client sees an unexpected EOF
+			w.Header().Set("Content-Length", "100")
+			_, _ = w.Write([]byte(body[:5]))
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	return srv, &calls
+}
+
+func TestSendRequest_TruncatedBodyIsNetworkError(t *testing.T) {
+	withPolicy(t, policy{maxBodyBytes: 1 << 20})
+	srv, calls := truncatingServer(1)
+	defer srv.Close()
+
+	var resp struct{ ID string }
+	err := SendRequest(context.Background(), srv.Client(), http.MethodPost, srv.URL, nil, map[string]string{}, &resp)
+
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+	var decodeErr *DecodeError
+	assert.False(t, errors.As(err, &decodeErr), "a cut off body is not a decode error")
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestSendRequest_RetriesTruncatedBody(t *testing.T) {
+	withPolicy(t, policy{maxBodyBytes: 1 << 20, retries: 2, backoff: time.Millisecond})
+	srv, calls := truncatingServer(1)
+	defer srv.Close()
+
+	var resp struct{ ID string }
+	err := SendRequest(context.Background(), srv.Client(), http.MethodPost, srv.URL, nil, map[string]string{}, &resp)
+
+	require.NoError(t, err)
+	assert.Equal(t, "resp-1", resp.ID)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestSendRequest_InvalidJSONIsDecodeError(t *testing.T) {
+	withPolicy(t, policy{maxBodyBytes: 1 << 20, retries: 2, backoff: time.Millisecond})
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		_, _ = w.Write([]byte(`<html>oops</html>`))
+	}))
+	defer srv.Close()
+
+	var resp struct{ ID string }
+	err := SendRequest(context.Background(), srv.Client(), http.MethodGet, srv.URL, nil, nil, &resp)
+
+	var decodeErr *DecodeError
+	require.True(t, errors.As(err, &decodeErr))
+	assert.Equal(t, "<html>oops</html>", string(decodeErr.Body))
+	// decode errors are not retried
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestSendRequest_BodyLimit(t *testing.T) {
+	withPolicy(t, policy{maxBodyBytes: 8})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"id":"longer than eight bytes"}`))
+	}))
+	defer srv.Close()
+
+	err := SendRequest(context.Background(), srv.Client(), http.MethodGet, srv.URL, nil, nil, nil)
+	assert.ErrorIs(t, err, ErrResponseTooLarge)
+}
+
+func TestGzipRequests(t *testing.T) {
+	withPolicy(t, policy{maxBodyBytes: 1 << 20})
+
+	type seen struct {
+		contentEncoding, acceptEncoding string
+		body                            string
+	}
+	requests := make(chan seen, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			require.NoError(t, err)
+			body = zr
+		}
+		b, _ := io.ReadAll(body)
+		requests <- seen{r.Header.Get("Content-Encoding"), r.Header.Get("Accept-Encoding"), string(b)}
+
+		if r.Header.Get("Accept") == "text/event-stream" {
+			_, _ = w.Write([]byte("data: {}\n\n"))
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	client := NewClient(time.Second, WithGzipRequests(1024))
+	body := map[string]string{"prompt": strings.Repeat("a large multimodal prompt ", 100)}
+	want, _ := json.Marshal(body)
+
+	require.NoError(t, SendRequest(context.Background(), client, http.MethodPost, srv.URL, nil, body, nil))
+	unary := <-requests
+	assert.Equal(t, "gzip", unary.contentEncoding)
+	assert.Equal(t, string(want), unary.body)
+
+	require.NoError(t, StreamRequest(context.Background(), client, http.MethodPost, srv.URL, nil, body, func(string) error { return nil }))
+	stream := <-requests
+	assert.Empty(t, stream.contentEncoding)
+	assert.Equal(t, "identity", stream.acceptEncoding)
+	assert.Equal(t, string(want), stream.body)
+
+	// small bodies are sent as is
+	require.NoError(t, SendRequest(context.Background(), client, http.MethodPost, srv.URL, nil, map[string]string{"prompt": "hi"}, nil))
+	assert.Empty(t, (<-requests).contentEncoding)
+}
+
+func TestGzipFromConfig(t *testing.T) {
+	_, err := GzipFromConfig("p", map[string]string{"gzip_requests": "yes please"})
+	assert.Error(t, err)
+	_, err = GzipFromConfig("p", map[string]string{"gzip_requests": "true", "gzip_min_bytes": "-1"})
+	assert.Error(t, err)
+
+	c := &http.Client{Transport: http.DefaultTransport}
+	opt, err := GzipFromConfig("p", map[string]string{"gzip_requests": "true", "gzip_min_bytes": "10"})
+	require.NoError(t, err)
+	opt(c)
+	require.IsType(t, &gzipTransport{}, c.Transport)
+	assert.Equal(t, int64(10), c.Transport.(*gzipTransport).minBytes)
+}
+
+// statusServer answers with the given statuses in turn, then with 200.
+func statusServer(retryAfter string, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		n := int(calls.Add(1))
+		if n <= len(statuses) {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		_, _ = w.Write(body) // echo, so a resent body can be checked
+	}))
+	return srv, &calls
+}
+
+func TestWithRetries_RetriesFailedStatuses(t *testing.T) {
+	withPolicy(t, policy{maxBodyBytes: 1 << 20, backoff: time.Millisecond, maxRetryDelay: time.Second})
+	srv, calls := statusServer("", http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	defer srv.Close()
+
+	var resp struct{ Prompt string }
+	client := NewClient(time.Second, WithGzipRequests(1<<20), WithRetries(3))
+	err := SendRequest(context.Background(), client, http.MethodPost, srv.URL, nil, map[string]string{"prompt": "hi"}, &resp)
+
+	require.NoError(t, err)
+	assert.Equal(t, "hi", resp.Prompt, "the body is sent again on every attempt")
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestWithRetries_GivesUpAfterMaxAttempts(t *testing.T) {
+	withPolicy(t, policy{maxBodyBytes: 1 << 20, backoff: time.Millisecond, maxRetryDelay: time.Second, maxAttempts: 2})
+	srv, calls := statusServer("", http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+	defer srv.Close()
+
+	// zero falls back to http_client.max_attempts
+	err := SendRequest(context.Background(), NewClient(time.Second, WithRetries(0)), http.MethodGet, srv.URL, nil, nil, nil)
+
+	var upstreamErr *UpstreamError
+	require.ErrorAs(t, err, &upstreamErr)
+	assert.Equal(t, http.StatusBadGateway, upstreamErr.StatusCode)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestWithRetries_DoesNotRetryClientErrors(t *testing.T) {
+	withPolicy(t, policy{maxBodyBytes: 1 << 20, backoff: time.Millisecond, maxRetryDelay: time.Second})
+	srv, calls := statusServer("", http.StatusBadRequest)
+	defer srv.Close()
+
+	err := SendRequest(context.Background(), NewClient(time.Second, WithRetries(3)), http.MethodGet, srv.URL, nil, nil, nil)
+	require.Error(t, err)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestWithRetries_RetryAfter(t *testing.T) {
+	withPolicy(t, policy{maxBodyBytes: 1 << 20, backoff: time.Millisecond, maxRetryDelay: 2 * time.Second})
+
+	// a wait within max_retry_delay is honoured
+	srv, calls := statusServer("1", http.StatusTooManyRequests)
+	defer srv.Close()
+	start := time.Now()
+	require.NoError(t, SendRequest(context.Background(), NewClient(5*time.Second, WithRetries(2)), http.MethodGet, srv.URL, nil, nil, nil))
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+	assert.Equal(t, int32(2), calls.Load())
+
+	// a longer one is returned to the caller
+	long, longCalls := statusServer("120", http.StatusTooManyRequests)
+	defer long.Close()
+	err := StreamRequest(context.Background(), NewClient(time.Second, WithRetries(3)), http.MethodPost, long.URL, nil, nil, func(string) error { return nil })
+
+	var upstreamErr *UpstreamError
+	require.ErrorAs(t, err, &upstreamErr)
+	assert.Equal(t, 2*time.Minute, upstreamErr.RetryAfter)
+	assert.Equal(t, int32(1), longCalls.Load())
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	d, ok := ParseRetryAfter("30")
+	require.True(t, ok)
+	assert.Equal(t, 30*time.Second, d)
+
+	d, ok = ParseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	require.True(t, ok)
+	assert.InDelta(t, time.Minute, d, float64(2*time.Second))
+
+	_, ok = ParseRetryAfter("soon")
+	assert.False(t, ok)
+	_, ok = ParseRetryAfter("")
+	assert.False(t, ok)
+}
@@ -0,0 +1,101 @@
+package httpclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// defaultGzipMinBytes is the smallest request body compressed when a provider
+// enables gzip without a threshold, smaller bodies gain nothing from it.
+const defaultGzipMinBytes = 1024
+
+// ClientOption customizes a client built by NewClient.
+type ClientOption func(*http.Client)
+
+// WithGzipRequests gzips request bodies of at least minBytes. Streaming
+// requests are never compressed.
+func WithGzipRequests(minBytes int64) ClientOption {
+	return func(c *http.Client) {
+		c.Transport = &gzipTransport{next: c.Transport, minBytes: minBytes}
+	}
+}
+
+// GzipFromConfig reads the gzip settings of a provider's config map:
+// gzip_requests ("true" to enable) and gzip_min_bytes. It returns a no-op
+// option when compression is not enabled.
+func GzipFromConfig(providerID string, cfg map[string]string) (ClientOption, error) {
+	noop := func(*http.Client) {}
+	raw, ok := cfg["gzip_requests"]
+	if !ok {
+		return noop, nil
+	}
+	enabled, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gzip_requests for provider %s: %w", providerID, err)
+	}
+	if !enabled {
+		return noop, nil
+	}
+
+	minBytes := int64(defaultGzipMinBytes)
+	if raw := cfg["gzip_min_bytes"]; raw != "" {
+		minBytes, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil || minBytes < 0 {
+			return nil, fmt.Errorf("invalid gzip_min_bytes for provider %s: %q", providerID, raw)
+		}
+	}
+	return WithGzipRequests(minBytes), nil
+}
+
+// gzipTransport compresses large unary request bodies. Streams are left
+// alone: they are identified by their event-stream Accept header.
+type gzipTransport struct {
+	next     http.RoundTripper
+	minBytes int64
+}
+
+func (t *gzipTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil || req.GetBody == nil || req.ContentLength < t.minBytes ||
+		req.Header.Get("Content-Encoding") != "" || req.Header.Get("Accept") == "text/event-stream" {
+		return t.next.RoundTrip(req)
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = body.Close()
+	}()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := io.Copy(zw, body); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	compressed := buf.Bytes()
+
+	// a RoundTripper must not modify the caller's request
+	_ = req.Body.Close()
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(compressed))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(compressed)), nil
+	}
+	req.ContentLength = int64(len(compressed))
+	req.Header.Set("Content-Encoding", "gzip")
+	return t.next.RoundTrip(req)
+}
+
+func (t *gzipTransport) CloseIdleConnections() {
+	if c, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
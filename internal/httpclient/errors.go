@@ -1,14 +1,65 @@
 package httpclient
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ErrResponseTooLarge is returned when a response body exceeds the
+// configured max_response_bytes.
+var ErrResponseTooLarge = errors.New("response body exceeds the size limit")
 
 // UpstreamError represents an error returned by an upstream service
 type UpstreamError struct {
 	StatusCode int
 	Body       []byte
 	URL        string
+	// RetryAfter is the wait the upstream asked for, zero when it set none.
+	RetryAfter time.Duration
+}
+
+func newUpstreamError(status int, header http.Header, body []byte, url string) *UpstreamError {
+	retryAfter, _ := ParseRetryAfter(header.Get("Retry-After"))
+	return &UpstreamError{StatusCode: status, Body: body, URL: url, RetryAfter: retryAfter}
 }
 
 func (e *UpstreamError) Error() string {
 	return fmt.Sprintf("upstream error: status %d from %s", e.StatusCode, e.URL)
 }
+
+// DecodeError is returned when a complete response body is not valid JSON.
+// Body holds the raw response for debugging.
+type DecodeError struct {
+	Body []byte
+	URL  string
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("failed to decode response from %s: %v", e.URL, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// networkError marks failures to send a request or read its response, the
+// only failures SendRequest retries.
+type networkError struct {
+	err error
+}
+
+func (e *networkError) Error() string {
+	return e.err.Error()
+}
+
+func (e *networkError) Unwrap() error {
+	return e.err
+}
+
+func isNetworkError(err error) bool {
+	var netErr *networkError
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,39 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MergeBody encodes body and deep merges patch into it, returning the result
+// ready to be sent. Nested objects are merged key by key, any other patch
+// value replaces the one in body.
+func MergeBody(body any, patch map[string]any) (json.RawMessage, error) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+	if len(patch) == 0 {
+		return raw, nil
+	}
+
+	var merged map[string]any
+	if err := json.Unmarshal(raw, &merged); err != nil {
+		return nil, fmt.Errorf("request body is not a JSON object: %w", err)
+	}
+	mergeObjects(merged, patch)
+
+	return json.Marshal(merged)
+}
+
+func mergeObjects(dst, patch map[string]any) {
+	for k, v := range patch {
+		if sub, ok := v.(map[string]any); ok {
+			if existing, ok := dst[k].(map[string]any); ok {
+				mergeObjects(existing, sub)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
@@ -0,0 +1,72 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+type (
+	baseURLKey struct{}
+	headersKey struct{}
+)
+
+// WithBaseURL returns a context whose outbound provider requests are sent to
+// base instead of the configured upstream. Only the scheme and host are
+// replaced, the request path is kept.
+func WithBaseURL(ctx context.Context, base *url.URL) context.Context {
+	return context.WithValue(ctx, baseURLKey{}, base)
+}
+
+// BaseURLFromContext returns the override set by WithBaseURL, if any.
+func BaseURLFromContext(ctx context.Context) (*url.URL, bool) {
+	base, ok := ctx.Value(baseURLKey{}).(*url.URL)
+	return base, ok && base != nil
+}
+
+// WithHeaders returns a context whose outbound provider requests carry the
+// given headers, such as correlation IDs. They are added to the headers set
+// by the adapter, which win on conflicts.
+func WithHeaders(ctx context.Context, headers http.Header) context.Context {
+	return context.WithValue(ctx, headersKey{}, headers)
+}
+
+// HeadersFromContext returns the headers set by WithHeaders, if any.
+func HeadersFromContext(ctx context.Context) (http.Header, bool) {
+	headers, ok := ctx.Value(headersKey{}).(http.Header)
+	return headers, ok && len(headers) > 0
+}
+
+// overrideTransport rewrites requests carrying a base URL override or extra
+// headers before handing them to the shared transport.
+type overrideTransport struct {
+	next http.RoundTripper
+}
+
+func (t *overrideTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base, hasBase := BaseURLFromContext(req.Context())
+	headers, hasHeaders := HeadersFromContext(req.Context())
+	if !hasBase && !hasHeaders {
+		return t.next.RoundTrip(req)
+	}
+
+	// a RoundTripper must not modify the caller's request
+	req = req.Clone(req.Context())
+	if hasBase {
+		req.URL.Scheme = base.Scheme
+		req.URL.Host = base.Host
+		req.Host = ""
+	}
+	for name, values := range headers {
+		if req.Header.Get(name) == "" {
+			req.Header[name] = values
+		}
+	}
+	return t.next.RoundTrip(req)
+}
+
+func (t *overrideTransport) CloseIdleConnections() {
+	if c, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,136 @@
+package httpclient
+
+import (
+	"bytes"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryableStatus reports whether a response status is worth sending the
+// request again for: rate limits and transient server failures.
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable:
+		return true
+	}
+	return false
+}
+
+// WithRetries resends requests answered with a 429, 500, 502 or 503, up to
+// maxAttempts in total, or http_client.max_attempts when it is zero. Retries
+// happen before the response is handed back, so a stream whose first bytes
+// were read is never sent again.
+func WithRetries(maxAttempts int) ClientOption {
+	return func(c *http.Client) {
+		c.Transport = &retryTransport{next: c.Transport, maxAttempts: maxAttempts}
+	}
+}
+
+// retryTransport waits between attempts with jittered exponential backoff,
+// or for the upstream Retry-After when it does not exceed max_retry_delay.
+// A longer Retry-After ends the retries, the caller can read it off the
+// returned response.
+type retryTransport struct {
+	next        http.RoundTripper
+	maxAttempts int
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	p := currentPolicy()
+	attempts := t.maxAttempts
+	if attempts <= 0 {
+		attempts = p.maxAttempts
+	}
+	if attempts <= 1 {
+		return t.next.RoundTrip(req)
+	}
+
+	// the body is sent again on every attempt
+	if req.Body != nil && req.GetBody == nil {
+		body, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		resp, err := t.next.RoundTrip(req)
+		if err != nil || attempt >= attempts || !retryableStatus(resp.StatusCode) {
+			return resp, err
+		}
+
+		delay := backoffDelay(p, attempt)
+		if after, ok := ParseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			if after > p.maxRetryDelay {
+				return resp, nil
+			}
+			delay = after
+		}
+
+		// the connection is reused only once the body is drained
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		_ = resp.Body.Close()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+func (t *retryTransport) CloseIdleConnections() {
+	if c, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// backoffDelay is the wait after the given failed attempt: the backoff
+// doubled per attempt and capped, half of it fixed and half random so
+// clients rejected together do not come back together.
+func backoffDelay(p policy, attempt int) time.Duration {
+	delay := p.backoff << (attempt - 1)
+	if delay <= 0 || delay > p.maxRetryDelay {
+		delay = p.maxRetryDelay
+	}
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// ParseRetryAfter reads a Retry-After header, given either in seconds or as
+// an HTTP date.
+func ParseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,95 @@
+package httpclient
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/config"
+)
+
+var (
+	transportMu     sync.RWMutex
+	sharedTransport = newTransport(config.HTTPClientConfig{
+		MaxIdleConns:        500,
+		MaxIdleConnsPerHost: 100,
+		MaxConnsPerHost:     500,
+		IdleConnTimeout:     90 * time.Second,
+	})
+	sharedPolicy = newPolicy(config.HTTPClientConfig{})
+)
+
+// policy is how SendRequest reads responses and retries network failures,
+// and how clients built WithRetries retry failed statuses.
+type policy struct {
+	maxBodyBytes  int64
+	retries       int
+	backoff       time.Duration
+	maxAttempts   int
+	maxRetryDelay time.Duration
+}
+
+func newPolicy(cfg config.HTTPClientConfig) policy {
+	p := policy{
+		maxBodyBytes:  cfg.MaxResponseBytes,
+		retries:       cfg.NetworkRetries,
+		backoff:       cfg.RetryBackoff,
+		maxAttempts:   cfg.MaxAttempts,
+		maxRetryDelay: cfg.MaxRetryDelay,
+	}
+	if p.maxBodyBytes <= 0 {
+		p.maxBodyBytes = 64 << 20
+	}
+	if p.backoff <= 0 {
+		p.backoff = 200 * time.Millisecond
+	}
+	if p.maxRetryDelay <= 0 {
+		p.maxRetryDelay = 10 * time.Second
+	}
+	return p
+}
+
+func currentPolicy() policy {
+	transportMu.RLock()
+	defer transportMu.RUnlock()
+	return sharedPolicy
+}
+
+// Configure replaces the shared outbound transport and request policy. It must be called before
+// the gateway service is created and providers are bootstrapped so every client picks up the
+// same settings.
+func Configure(cfg config.HTTPClientConfig) {
+	transportMu.Lock()
+	defer transportMu.Unlock()
+
+	sharedTransport.CloseIdleConnections()
+	sharedTransport = newTransport(cfg)
+	sharedPolicy = newPolicy(cfg)
+}
+
+// Transport returns the transport shared by all provider clients, so idle
+// connections are pooled and reaped in one place.
+func Transport() *http.Transport {
+	transportMu.RLock()
+	defer transportMu.RUnlock()
+	return sharedTransport
+}
+
+// NewClient returns a client using the shared transport. Requests honour a
+// base URL override set with WithBaseURL.
+func NewClient(timeout time.Duration, opts ...ClientOption) *http.Client {
+	c := &http.Client{Timeout: timeout, Transport: &overrideTransport{next: Transport()}}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func newTransport(cfg config.HTTPClientConfig) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = cfg.MaxIdleConns
+	t.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	t.MaxConnsPerHost = cfg.MaxConnsPerHost
+	t.IdleConnTimeout = cfg.IdleConnTimeout
+	return t
+}
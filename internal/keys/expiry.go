@@ -0,0 +1,144 @@
+package keys
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/httpclient"
+	"github.com/nulzo/model-router-api/internal/store"
+	"go.uber.org/zap"
+)
+
+// ExpiringEvent is posted to the webhook for each key about to expire.
+type ExpiringEvent struct {
+	Event     string    `json:"event"`
+	KeyID     string    `json:"key_id"`
+	UserID    string    `json:"user_id"`
+	Name      string    `json:"name"`
+	KeyPrefix string    `json:"key_prefix"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+const eventKeyExpiring = "api_key.expiring"
+
+// ExpiryJob deactivates expired API keys and reminds owners ahead of expiry.
+type ExpiryJob struct {
+	logger *zap.Logger
+	repo   store.Repository
+	cfg    config.KeyExpiryConfig
+	client *http.Client
+
+	// reminded holds the keys a reminder was sent for, so each key gets one
+	mu       sync.Mutex
+	reminded map[string]bool
+}
+
+func NewExpiryJob(logger *zap.Logger, repo store.Repository, cfg config.KeyExpiryConfig) *ExpiryJob {
+	return &ExpiryJob{
+		logger:   logger,
+		repo:     repo,
+		cfg:      cfg,
+		client:   httpclient.NewClient(10 * time.Second),
+		reminded: make(map[string]bool),
+	}
+}
+
+// Start runs the job every CheckInterval until ctx is done.
+func (j *ExpiryJob) Start(ctx context.Context) {
+	if j.cfg.CheckInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(j.cfg.CheckInterval)
+		defer ticker.Stop()
+
+		for {
+			j.Run(ctx, time.Now())
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Run performs a single pass: expired keys are deactivated, then reminders
+// go out for keys expiring within RemindBefore of now.
+func (j *ExpiryJob) Run(ctx context.Context, now time.Time) {
+	n, err := j.repo.APIKeys().DeactivateExpired(ctx, now)
+	if err != nil {
+		j.logger.Error("Failed to deactivate expired API keys", zap.Error(err))
+	} else if n > 0 {
+		j.logger.Info("Deactivated expired API keys", zap.Int64("count", n))
+	}
+
+	if j.cfg.RemindBefore <= 0 {
+		return
+	}
+
+	expiring, err := j.repo.APIKeys().ListExpiring(ctx, now.Add(j.cfg.RemindBefore))
+	if err != nil {
+		j.logger.Error("Failed to list expiring API keys", zap.Error(err))
+		return
+	}
+
+	for _, key := range expiring {
+		if !j.markReminded(key.ID) {
+			continue
+		}
+
+		event := ExpiringEvent{
+			Event:     eventKeyExpiring,
+			KeyID:     key.ID,
+			UserID:    key.UserID,
+			Name:      key.Name,
+			KeyPrefix: key.KeyPrefix,
+			ExpiresAt: key.ExpiresAt.Time,
+		}
+		j.logger.Warn("API key expires soon",
+			zap.String("key_id", key.ID),
+			zap.String("user_id", key.UserID),
+			zap.Time("expires_at", event.ExpiresAt),
+		)
+
+		if j.cfg.WebhookURL == "" {
+			continue
+		}
+		if err := j.notify(ctx, event); err != nil {
+			j.logger.Error("Failed to send API key expiry webhook", zap.String("key_id", key.ID), zap.Error(err))
+			// try again on the next run
+			j.forget(key.ID)
+		}
+	}
+}
+
+func (j *ExpiryJob) notify(ctx context.Context, event ExpiringEvent) error {
+	if err := httpclient.SendRequest(ctx, j.client, http.MethodPost, j.cfg.WebhookURL, nil, event, nil); err != nil {
+		return fmt.Errorf("webhook %s: %w", j.cfg.WebhookURL, err)
+	}
+	return nil
+}
+
+// markReminded records a reminder for id and reports whether it is the first.
+func (j *ExpiryJob) markReminded(id string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.reminded[id] {
+		return false
+	}
+	j.reminded[id] = true
+	return true
+}
+
+func (j *ExpiryJob) forget(id string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	delete(j.reminded, id)
+}
@@ -0,0 +1,76 @@
+package keys
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/internal/store/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestExpiryJob(t *testing.T) {
+	ctx := context.Background()
+	repo, err := sqlite.NewSQLiteStorage(":memory:", zap.NewNop())
+	require.NoError(t, err)
+	defer repo.Close()
+
+	now := time.Now()
+	require.NoError(t, repo.Users().Create(ctx, &model.User{ID: "user-1", Email: "one@example.com", Name: "One", Role: "user", CreatedAt: now, UpdatedAt: now}))
+	for _, k := range []*model.APIKey{
+		{ID: "expired", KeyHash: "h1", ExpiresAt: sql.NullTime{Time: now.Add(-time.Hour), Valid: true}},
+		{ID: "expiring", KeyHash: "h2", ExpiresAt: sql.NullTime{Time: now.Add(24 * time.Hour), Valid: true}},
+		{ID: "later", KeyHash: "h3", ExpiresAt: sql.NullTime{Time: now.Add(30 * 24 * time.Hour), Valid: true}},
+		{ID: "forever", KeyHash: "h4"},
+	} {
+		k.UserID, k.Name, k.KeyPrefix, k.IsActive = "user-1", k.ID, "sk-"+k.ID, true
+		k.CreatedAt, k.UpdatedAt = now, now
+		require.NoError(t, repo.APIKeys().Create(ctx, k))
+	}
+
+	// auth already refuses the expired key before the job runs
+	_, err = repo.APIKeys().GetByHash(ctx, "h1")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	var mu sync.Mutex
+	var events []ExpiringEvent
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e ExpiringEvent
+		_ = json.NewDecoder(r.Body).Decode(&e)
+		mu.Lock()
+		events = append(events, e)
+		mu.Unlock()
+	}))
+	defer webhook.Close()
+
+	job := NewExpiryJob(zap.NewNop(), repo, config.KeyExpiryConfig{
+		RemindBefore: 7 * 24 * time.Hour,
+		WebhookURL:   webhook.URL,
+	})
+	job.Run(ctx, now)
+	job.Run(ctx, now) // reminders are sent once per key
+
+	active := true
+	keys, _, err := repo.APIKeys().List(ctx, store.APIKeyFilter{Active: &active})
+	require.NoError(t, err)
+	var ids []string
+	for _, k := range keys {
+		ids = append(ids, k.ID)
+	}
+	assert.ElementsMatch(t, []string{"expiring", "later", "forever"}, ids)
+
+	require.Len(t, events, 1)
+	assert.Equal(t, "api_key.expiring", events[0].Event)
+	assert.Equal(t, "expiring", events[0].KeyID)
+	assert.Equal(t, "user-1", events[0].UserID)
+}
@@ -27,7 +27,7 @@ type Adapter struct {
 
 func NewAdapter(config config.ProviderConfig) (llm.Provider, error) {
 	if config.BaseURL == "" {
-		config.BaseURL = "https://api.anthropic.com/v1"
+		config.BaseURL = llm.DefaultBaseURL("anthropic")
 	}
 
 	timeout := 10 * time.Minute
@@ -39,9 +39,14 @@ func NewAdapter(config config.ProviderConfig) (llm.Provider, error) {
 		}
 	}
 
+	compress, err := httpclient.GzipFromConfig(config.ID, config.Config)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Adapter{
 		config: config,
-		client: &http.Client{Timeout: timeout},
+		client: httpclient.NewClient(timeout, compress, httpclient.WithRetries(config.MaxAttempts)),
 	}, nil
 }
 
@@ -53,11 +58,22 @@ type Message struct {
 	Content interface{} `json:"content"` // string or []Content
 }
 type Request struct {
-	Model     string    `json:"model"`
-	Messages  []Message `json:"messages"`
-	System    string    `json:"system,omitempty"`
-	MaxTokens int       `json:"max_tokens"`
-	Stream    bool      `json:"stream,omitempty"`
+	Model      string      `json:"model"`
+	Messages   []Message   `json:"messages"`
+	System     interface{} `json:"system,omitempty"` // string or []Content
+	MaxTokens  int         `json:"max_tokens"`
+	Stream     bool        `json:"stream,omitempty"`
+	Tools      []Tool      `json:"tools,omitempty"`
+	ToolChoice *ToolChoice `json:"tool_choice,omitempty"`
+}
+type Tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+type ToolChoice struct {
+	Type string `json:"type"` // "auto", "any", "tool" or "none"
+	Name string `json:"name,omitempty"`
 }
 type Response struct {
 	ID         string    `json:"id"`
@@ -70,6 +86,15 @@ type Content struct {
 	Type   string       `json:"type"`
 	Text   string       `json:"text,omitempty"`
 	Source *ImageSource `json:"source,omitempty"`
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+	// tool_result, the content is a string or []Content
+	ToolUseID string      `json:"tool_use_id,omitempty"`
+	Content   interface{} `json:"content,omitempty"`
+	// prompt caching breakpoint, caches the prompt up to this block
+	CacheControl *api.CacheControl `json:"cache_control,omitempty"`
 }
 type ImageSource struct {
 	Type      string `json:"type"`       // "base64"
@@ -79,33 +104,82 @@ type ImageSource struct {
 type Usage struct {
 	InputTokens  int `json:"input_tokens"`
 	OutputTokens int `json:"output_tokens"`
+	// Prompt caching, input_tokens excludes both of these
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
 }
 type StreamEvent struct {
-	Type         string   `json:"type"`
-	Delta        *Delta   `json:"delta,omitempty"`
-	ContentBlock *Content `json:"content_block,omitempty"` // For content_block_start
-	Index        int      `json:"index,omitempty"`
-	Usage        *Usage   `json:"usage,omitempty"` // For message_start
+	Type         string    `json:"type"`
+	Delta        *Delta    `json:"delta,omitempty"`
+	ContentBlock *Content  `json:"content_block,omitempty"` // For content_block_start
+	Index        int       `json:"index,omitempty"`
+	Message      *Response `json:"message,omitempty"` // For message_start, carries the prompt usage
+	Usage        *Usage    `json:"usage,omitempty"`   // For message_delta
 }
 type Delta struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type        string `json:"type"`
+	Text        string `json:"text"`
+	PartialJSON string `json:"partial_json,omitempty"` // For input_json_delta
+	StopReason  string `json:"stop_reason,omitempty"`  // For message_delta
+}
+
+// toUsage converts Anthropic usage to the unified shape. Unlike Anthropic,
+// prompt_tokens includes cached tokens, which are broken out in the details.
+func toUsage(u Usage) *api.ResponseUsage {
+	prompt := u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+	usage := &api.ResponseUsage{
+		PromptTokens:     prompt,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      prompt + u.OutputTokens,
+		// anthropic's input_tokens exclude the cached part of the prompt
+		NativeTokens: &api.NativeTokens{Prompt: u.InputTokens, Completion: u.OutputTokens},
+	}
+	if u.CacheCreationInputTokens > 0 || u.CacheReadInputTokens > 0 {
+		usage.PromptTokensDetails = &api.PromptTokensDetails{
+			CachedTokens:     u.CacheReadInputTokens,
+			CacheWriteTokens: u.CacheCreationInputTokens,
+		}
+	}
+	return usage
 }
 
 // Convert Unified -> Anthropic
 func toAnthropicReq(req *api.ChatRequest) Request {
+	// max_tokens is mandatory upstream, the gateway sanitizer fills in the configured default
 	ar := Request{
 		Model:     req.Model,
 		MaxTokens: req.MaxTokens,
 		Stream:    req.Stream,
 	}
 
-	if ar.MaxTokens == 0 {
-		ar.MaxTokens = 4096
+	for _, t := range req.Tools {
+		ar.Tools = append(ar.Tools, Tool{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			InputSchema: t.Function.Parameters,
+		})
 	}
+	if len(ar.Tools) > 0 {
+		ar.ToolChoice = toToolChoice(req.ToolChoice)
+	}
+
+	var system []Content
 	for _, m := range req.Messages {
 		if m.Role == "system" {
-			ar.System += m.Content.Text + "\n"
+			system = append(system, textBlocks(m.Content)...)
+		} else if m.Role == "tool" {
+			// tool results go back as user turns, consecutive results share one turn
+			result := Content{Type: "tool_result", ToolUseID: m.ToolCallID, Content: m.Content.Text}
+			if len(m.Content.Parts) > 0 {
+				result.Content = partBlocks(m.Content.Parts)
+			}
+			if n := len(ar.Messages); n > 0 && ar.Messages[n-1].Role == "user" {
+				if blocks, ok := ar.Messages[n-1].Content.([]Content); ok && blocks[len(blocks)-1].Type == "tool_result" {
+					ar.Messages[n-1].Content = append(blocks, result)
+					continue
+				}
+			}
+			ar.Messages = append(ar.Messages, Message{Role: "user", Content: []Content{result}})
 		} else {
 			var contentParts []Content
 
@@ -118,25 +192,19 @@ func toAnthropicReq(req *api.ChatRequest) Request {
 			}
 
 			// Handle multipart content
-			for _, part := range m.Content.Parts {
-				if part.Type == "text" {
-					contentParts = append(contentParts, Content{
-						Type: "text",
-						Text: part.Text,
-					})
-				} else if part.Type == "image_url" && part.ImageURL != nil {
-					imgData, err := processing.ProcessImageURL(part.ImageURL.URL)
-					if err == nil {
-						contentParts = append(contentParts, Content{
-							Type: "image",
-							Source: &ImageSource{
-								Type:      "base64",
-								MediaType: imgData.MediaType,
-								Data:      imgData.Data,
-							},
-						})
-					}
+			contentParts = append(contentParts, partBlocks(m.Content.Parts)...)
+
+			for _, tc := range m.ToolCalls {
+				input := json.RawMessage(tc.Function.Arguments)
+				if len(input) == 0 {
+					input = json.RawMessage("{}")
 				}
+				contentParts = append(contentParts, Content{
+					Type:  "tool_use",
+					ID:    tc.ID,
+					Name:  tc.Function.Name,
+					Input: input,
+				})
 			}
 
 			if len(contentParts) > 0 {
@@ -147,9 +215,111 @@ func toAnthropicReq(req *api.ChatRequest) Request {
 			}
 		}
 	}
+	ar.System = toSystem(system)
 	return ar
 }
 
+// partBlocks converts the text and image parts of a message to content
+// blocks, images that can not be loaded are left out.
+func partBlocks(parts []api.ContentPart) []Content {
+	var blocks []Content
+	for _, part := range parts {
+		if part.Type == "text" {
+			blocks = append(blocks, Content{
+				Type:         "text",
+				Text:         part.Text,
+				CacheControl: part.CacheControl,
+			})
+		} else if part.Type == "image_url" && part.ImageURL != nil {
+			imgData, err := processing.ProcessImageURL(part.ImageURL.URL)
+			if err == nil {
+				blocks = append(blocks, Content{
+					Type: "image",
+					Source: &ImageSource{
+						Type:      "base64",
+						MediaType: imgData.MediaType,
+						Data:      imgData.Data,
+					},
+					CacheControl: part.CacheControl,
+				})
+			}
+		}
+	}
+	return blocks
+}
+
+// textBlocks returns the text of a system message as content blocks, keeping
+// the cache breakpoints of its parts.
+func textBlocks(content api.Content) []Content {
+	if len(content.Parts) == 0 {
+		if content.Text == "" {
+			return nil
+		}
+		return []Content{{Type: "text", Text: content.Text}}
+	}
+
+	var blocks []Content
+	for _, part := range content.Parts {
+		if part.Type == "text" && part.Text != "" {
+			blocks = append(blocks, Content{Type: "text", Text: part.Text, CacheControl: part.CacheControl})
+		}
+	}
+	return blocks
+}
+
+// toSystem joins the system blocks into the plain system string, unless one
+// of them carries a cache breakpoint, which only the block form can express.
+func toSystem(blocks []Content) interface{} {
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	var text strings.Builder
+	for _, b := range blocks {
+		if b.CacheControl != nil {
+			return blocks
+		}
+		text.WriteString(b.Text + "\n")
+	}
+	return text.String()
+}
+
+// toToolChoice maps the OpenAI tool_choice ("none", "auto", "required" or
+// {"type":"function","function":{"name":...}}) to its Anthropic form.
+func toToolChoice(choice interface{}) *ToolChoice {
+	switch c := choice.(type) {
+	case string:
+		switch c {
+		case "none":
+			return &ToolChoice{Type: "none"}
+		case "required":
+			return &ToolChoice{Type: "any"}
+		case "auto":
+			return &ToolChoice{Type: "auto"}
+		}
+	case map[string]interface{}:
+		if fn, ok := c["function"].(map[string]interface{}); ok {
+			if name, ok := fn["name"].(string); ok && name != "" {
+				return &ToolChoice{Type: "tool", Name: name}
+			}
+		}
+	}
+	return nil
+}
+
+// finishReason maps an Anthropic stop_reason to the OpenAI finish_reason.
+func finishReason(stopReason string) string {
+	switch stopReason {
+	case "tool_use":
+		return string(api.FinishReasonToolCalls)
+	case "max_tokens":
+		return string(api.FinishReasonLength)
+	case "end_turn", "stop_sequence", "":
+		return string(api.FinishReasonStop)
+	}
+	return stopReason
+}
+
 func (a *Adapter) Chat(ctx context.Context, req *api.ChatRequest) (*api.ChatResponse, error) {
 	ar := toAnthropicReq(req)
 	ar.Stream = false
@@ -170,9 +340,17 @@ func (a *Adapter) Chat(ctx context.Context, req *api.ChatRequest) (*api.ChatResp
 
 	// Convert Anthropic -> Unified
 	fullText := ""
+	var toolCalls []api.ToolCall
 	for _, c := range anthroResp.Content {
-		if c.Type == "text" {
+		switch c.Type {
+		case "text":
 			fullText += c.Text
+		case "tool_use":
+			toolCalls = append(toolCalls, api.ToolCall{
+				ID:       c.ID,
+				Type:     "function",
+				Function: api.FunctionCall{Name: c.Name, Arguments: string(c.Input)},
+			})
 		}
 	}
 
@@ -189,14 +367,11 @@ func (a *Adapter) Chat(ctx context.Context, req *api.ChatRequest) (*api.ChatResp
 				Role:      "assistant",
 				Content:   api.Content{Text: content},
 				Reasoning: reasoning,
+				ToolCalls: toolCalls,
 			},
-			FinishReason: anthroResp.StopReason,
+			FinishReason: finishReason(anthroResp.StopReason),
 		}},
-		Usage: &api.ResponseUsage{
-			PromptTokens:     anthroResp.Usage.InputTokens,
-			CompletionTokens: anthroResp.Usage.OutputTokens,
-			TotalTokens:      anthroResp.Usage.InputTokens + anthroResp.Usage.OutputTokens,
-		},
+		Usage: toUsage(anthroResp.Usage),
 	}, nil
 }
 
@@ -219,6 +394,12 @@ func (a *Adapter) Stream(ctx context.Context, req *api.ChatRequest) (<-chan api.
 		defer close(ch)
 
 		parser := processing.NewStreamParser()
+		// prompt usage arrives in message_start, output tokens in message_delta
+		var usage Usage
+		var stopReason string
+		// content block index -> tool call index, tool calls are numbered from
+		// zero in the order their blocks start so clients get a stable index
+		toolIndex := make(map[int]int)
 
 		err := httpclient.StreamRequest(ctx, a.client, "POST", url, headers, ar, func(line string) error {
 			if !strings.HasPrefix(line, "data: ") {
@@ -234,15 +415,47 @@ func (a *Adapter) Stream(ctx context.Context, req *api.ChatRequest) (<-chan api.
 			// Map Anthropic Events to OpenAI-compatible chunks
 			switch event.Type {
 			case "message_start":
-				if event.Usage != nil {
-					// Input tokens are sent here
+				if event.Message != nil {
+					// Input and cache tokens are sent here
+					usage = event.Message.Usage
 					ch <- api.StreamResult{Response: &api.ChatResponse{
-						Usage: &api.ResponseUsage{
-							PromptTokens: event.Usage.InputTokens,
-						},
+						Usage: toUsage(usage),
+					}}
+				}
+			case "content_block_start":
+				if event.ContentBlock != nil && event.ContentBlock.Type == "tool_use" {
+					idx := len(toolIndex)
+					toolIndex[event.Index] = idx
+					ch <- api.StreamResult{Response: &api.ChatResponse{
+						Choices: []api.Choice{{
+							Delta: &api.ChatMessage{
+								ToolCalls: []api.ToolCall{{
+									Index:    &idx,
+									ID:       event.ContentBlock.ID,
+									Type:     "function",
+									Function: api.FunctionCall{Name: event.ContentBlock.Name},
+								}},
+							},
+						}},
 					}}
 				}
 			case "content_block_delta":
+				if event.Delta != nil && event.Delta.Type == "input_json_delta" {
+					idx, ok := toolIndex[event.Index]
+					if !ok {
+						return nil
+					}
+					ch <- api.StreamResult{Response: &api.ChatResponse{
+						Choices: []api.Choice{{
+							Delta: &api.ChatMessage{
+								ToolCalls: []api.ToolCall{{
+									Index:    &idx,
+									Function: api.FunctionCall{Arguments: event.Delta.PartialJSON},
+								}},
+							},
+						}},
+					}}
+				}
 				if event.Delta != nil && event.Delta.Type == "text_delta" {
 					c, r := parser.Process(event.Delta.Text)
 					ch <- api.StreamResult{Response: &api.ChatResponse{
@@ -257,20 +470,19 @@ func (a *Adapter) Stream(ctx context.Context, req *api.ChatRequest) (<-chan api.
 			case "message_delta":
 				// Output tokens and stop reason sent here
 				if event.Usage != nil {
+					// report the full usage so the last usage chunk is complete
+					usage.OutputTokens = event.Usage.OutputTokens
 					ch <- api.StreamResult{Response: &api.ChatResponse{
-						Usage: &api.ResponseUsage{
-							CompletionTokens: event.Usage.OutputTokens,
-						},
+						Usage: toUsage(usage),
 					}}
 				}
-				// if event.Delta != nil && event.Delta.Type == "stop_reason" {
-				// 	// stop reason logic handled in message_stop usually, but sometimes here?
-				// 	// Anthropic docs say stop_reason is in message_delta
-				// }
+				if event.Delta != nil && event.Delta.StopReason != "" {
+					stopReason = event.Delta.StopReason
+				}
 			case "message_stop":
 				ch <- api.StreamResult{Response: &api.ChatResponse{
 					Choices: []api.Choice{{
-						FinishReason: "stop",
+						FinishReason: finishReason(stopReason),
 						Delta:        &api.ChatMessage{},
 					}},
 				}}
@@ -0,0 +1,305 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToAnthropicReq_ImagePart(t *testing.T) {
+	req := &api.ChatRequest{
+		Model: "claude-sonnet-4-5",
+		Messages: []api.ChatMessage{
+			{Role: "system", Content: api.Content{Text: "Be brief."}},
+			{
+				Role: "user",
+				Content: api.Content{
+					Parts: []api.ContentPart{
+						{Type: "text", Text: "What is in this image?"},
+						{
+							Type: "image_url",
+							ImageURL: &api.ImageURL{
+								// Simple 1x1 red pixel png
+								URL: "data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mP8z8BQDwAEhQGAhKmMIQAAAABJRU5ErkJggg==",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	ar := toAnthropicReq(req)
+
+	require.Len(t, ar.Messages, 1)
+	blocks, ok := ar.Messages[0].Content.([]Content)
+	require.True(t, ok, "multipart content should be sent as content blocks")
+	require.Len(t, blocks, 2)
+
+	assert.Equal(t, "text", blocks[0].Type)
+	assert.Equal(t, "What is in this image?", blocks[0].Text)
+
+	assert.Equal(t, "image", blocks[1].Type)
+	require.NotNil(t, blocks[1].Source)
+	assert.Equal(t, "base64", blocks[1].Source.Type)
+	assert.Equal(t, "image/png", blocks[1].Source.MediaType)
+	assert.NotEmpty(t, blocks[1].Source.Data)
+
+	assert.Equal(t, "Be brief.\n", ar.System)
+}
+
+func TestToAnthropicReq_CacheControl(t *testing.T) {
+	ephemeral := &api.CacheControl{Type: "ephemeral"}
+	ar := toAnthropicReq(&api.ChatRequest{
+		Model: "claude-sonnet-4-5",
+		Messages: []api.ChatMessage{
+			{Role: "system", Content: api.Content{Parts: []api.ContentPart{
+				{Type: "text", Text: "You review contracts."},
+				{Type: "text", Text: "<the whole contract>", CacheControl: ephemeral},
+			}}},
+			{Role: "user", Content: api.Content{Parts: []api.ContentPart{
+				{Type: "text", Text: "<long context>", CacheControl: &api.CacheControl{Type: "ephemeral", TTL: "1h"}},
+				{Type: "text", Text: "Summarize clause 4."},
+			}}},
+		},
+	})
+
+	system, ok := ar.System.([]Content)
+	require.True(t, ok, "a cache breakpoint needs the block form of system")
+	require.Len(t, system, 2)
+	assert.Nil(t, system[0].CacheControl)
+	assert.Equal(t, ephemeral, system[1].CacheControl)
+
+	blocks := ar.Messages[0].Content.([]Content)
+	require.Len(t, blocks, 2)
+	assert.Equal(t, "1h", blocks[0].CacheControl.TTL)
+	assert.Nil(t, blocks[1].CacheControl)
+
+	body, err := json.Marshal(ar)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"system":[{"type":"text","text":"You review contracts."},{"type":"text","text":"\u003cthe whole contract\u003e","cache_control":{"type":"ephemeral"}}]`)
+}
+
+func TestToAnthropicReq_PlainText(t *testing.T) {
+	ar := toAnthropicReq(&api.ChatRequest{
+		Model:    "claude-sonnet-4-5",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+
+	require.Len(t, ar.Messages, 1)
+	assert.Equal(t, []Content{{Type: "text", Text: "Hi"}}, ar.Messages[0].Content)
+}
+
+func TestToUsage_CacheTokens(t *testing.T) {
+	var resp Response
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"id": "msg_1",
+		"usage": {
+			"input_tokens": 20,
+			"output_tokens": 50,
+			"cache_creation_input_tokens": 100,
+			"cache_read_input_tokens": 1000
+		}
+	}`), &resp))
+
+	usage := toUsage(resp.Usage)
+
+	assert.Equal(t, 1120, usage.PromptTokens)
+	assert.Equal(t, 50, usage.CompletionTokens)
+	assert.Equal(t, 1170, usage.TotalTokens)
+	require.NotNil(t, usage.PromptTokensDetails)
+	assert.Equal(t, 1000, usage.PromptTokensDetails.CachedTokens)
+	assert.Equal(t, 100, usage.PromptTokensDetails.CacheWriteTokens)
+	// the native prompt count leaves the cached tokens out
+	require.NotNil(t, usage.NativeTokens)
+	assert.Equal(t, api.NativeTokens{Prompt: 20, Completion: 50}, *usage.NativeTokens)
+}
+
+func TestToUsage_NoCache(t *testing.T) {
+	usage := toUsage(Usage{InputTokens: 10, OutputTokens: 5})
+
+	assert.Equal(t, 10, usage.PromptTokens)
+	assert.Nil(t, usage.PromptTokensDetails)
+}
+
+func TestStream_CacheUsageFromMessageStart(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintln(w, `data: {"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":20,"output_tokens":1,"cache_read_input_tokens":1000}}}`)
+		fmt.Fprintln(w, `data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hi"}}`)
+		fmt.Fprintln(w, `data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":7}}`)
+		fmt.Fprintln(w, `data: {"type":"message_stop"}`)
+	}))
+	defer srv.Close()
+
+	p, err := NewAdapter(config.ProviderConfig{ID: "anthropic", BaseURL: srv.URL})
+	require.NoError(t, err)
+
+	ch, err := p.Stream(context.Background(), &api.ChatRequest{
+		Model:     "claude-sonnet-4-5",
+		MaxTokens: 16,
+		Messages:  []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+
+	var last *api.ResponseUsage
+	for res := range ch {
+		require.NoError(t, res.Err)
+		if res.Response.Usage != nil {
+			last = res.Response.Usage
+		}
+	}
+
+	require.NotNil(t, last)
+	assert.Equal(t, 1020, last.PromptTokens)
+	assert.Equal(t, 7, last.CompletionTokens)
+	require.NotNil(t, last.PromptTokensDetails)
+	assert.Equal(t, 1000, last.PromptTokensDetails.CachedTokens)
+}
+
+func TestStream_ToolUseArgumentDeltas(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintln(w, `data: {"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":20,"output_tokens":1}}}`)
+		fmt.Fprintln(w, `data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`)
+		fmt.Fprintln(w, `data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Checking."}}`)
+		fmt.Fprintln(w, `data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}`)
+		fmt.Fprintln(w, `data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}`)
+		fmt.Fprintln(w, `data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}`)
+		fmt.Fprintln(w, `data: {"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_2","name":"get_time","input":{}}}`)
+		fmt.Fprintln(w, `data: {"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{}"}}`)
+		fmt.Fprintln(w, `data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":30}}`)
+		fmt.Fprintln(w, `data: {"type":"message_stop"}`)
+	}))
+	defer srv.Close()
+
+	p, err := NewAdapter(config.ProviderConfig{ID: "anthropic", BaseURL: srv.URL})
+	require.NoError(t, err)
+
+	ch, err := p.Stream(context.Background(), &api.ChatRequest{
+		Model:     "claude-sonnet-4-5",
+		MaxTokens: 64,
+		Messages:  []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Weather in Paris?"}}},
+	})
+	require.NoError(t, err)
+
+	var calls []api.ToolCall
+	var finish string
+	for res := range ch {
+		require.NoError(t, res.Err)
+		for _, c := range res.Response.Choices {
+			if c.Delta != nil {
+				calls = append(calls, c.Delta.ToolCalls...)
+			}
+			if c.FinishReason != "" {
+				finish = c.FinishReason
+			}
+		}
+	}
+
+	require.Len(t, calls, 5)
+
+	// the first delta of a call announces it
+	require.NotNil(t, calls[0].Index)
+	assert.Equal(t, 0, *calls[0].Index)
+	assert.Equal(t, "toolu_1", calls[0].ID)
+	assert.Equal(t, "function", calls[0].Type)
+	assert.Equal(t, "get_weather", calls[0].Function.Name)
+
+	// argument fragments follow with the same index
+	assert.Equal(t, 0, *calls[1].Index)
+	assert.Equal(t, `{"city":`, calls[1].Function.Arguments)
+	assert.Equal(t, 0, *calls[2].Index)
+	assert.Equal(t, `"Paris"}`, calls[2].Function.Arguments)
+
+	// the next tool block gets the next index, not the content block index
+	assert.Equal(t, 1, *calls[3].Index)
+	assert.Equal(t, "get_time", calls[3].Function.Name)
+	assert.Equal(t, 1, *calls[4].Index)
+	assert.Equal(t, `{}`, calls[4].Function.Arguments)
+
+	assert.Equal(t, "tool_calls", finish)
+
+	// OpenAI wire shape for a fragment
+	data, err := json.Marshal(calls[1])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"index":0,"function":{"arguments":"{\"city\":"}}`, string(data))
+}
+
+func TestToAnthropicReq_Tools(t *testing.T) {
+	ar := toAnthropicReq(&api.ChatRequest{
+		Model: "claude-sonnet-4-5",
+		Tools: []api.Tool{{
+			Type: "function",
+			Function: api.FunctionDescription{
+				Name:       "get_weather",
+				Parameters: map[string]interface{}{"type": "object"},
+			},
+		}},
+		ToolChoice: "required",
+		Messages: []api.ChatMessage{
+			{Role: "user", Content: api.Content{Text: "Weather in Paris and Rome?"}},
+			{Role: "assistant", ToolCalls: []api.ToolCall{
+				{ID: "toolu_1", Type: "function", Function: api.FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+				{ID: "toolu_2", Type: "function", Function: api.FunctionCall{Name: "get_weather", Arguments: `{"city":"Rome"}`}},
+			}},
+			{Role: "tool", ToolCallID: "toolu_1", Content: api.Content{Text: "18C"}},
+			{Role: "tool", ToolCallID: "toolu_2", Content: api.Content{Text: "24C"}},
+		},
+	})
+
+	require.Len(t, ar.Tools, 1)
+	assert.Equal(t, "get_weather", ar.Tools[0].Name)
+	assert.Equal(t, &ToolChoice{Type: "any"}, ar.ToolChoice)
+
+	require.Len(t, ar.Messages, 3)
+	uses := ar.Messages[1].Content.([]Content)
+	require.Len(t, uses, 2)
+	assert.Equal(t, "tool_use", uses[0].Type)
+	assert.JSONEq(t, `{"city":"Paris"}`, string(uses[0].Input))
+
+	// both results share a single user turn
+	assert.Equal(t, "user", ar.Messages[2].Role)
+	results := ar.Messages[2].Content.([]Content)
+	require.Len(t, results, 2)
+	assert.Equal(t, Content{Type: "tool_result", ToolUseID: "toolu_1", Content: "18C"}, results[0])
+	assert.Equal(t, "toolu_2", results[1].ToolUseID)
+}
+
+func TestToAnthropicReq_MultipartToolResult(t *testing.T) {
+	ar := toAnthropicReq(&api.ChatRequest{
+		Model: "claude-sonnet-4-5",
+		Messages: []api.ChatMessage{
+			{Role: "user", Content: api.Content{Text: "Take a screenshot"}},
+			{Role: "assistant", ToolCalls: []api.ToolCall{
+				{ID: "toolu_1", Type: "function", Function: api.FunctionCall{Name: "screenshot", Arguments: `{}`}},
+			}},
+			{Role: "tool", ToolCallID: "toolu_1", Content: api.Content{Parts: []api.ContentPart{
+				{Type: "text", Text: "The login page"},
+				{Type: "image_url", ImageURL: &api.ImageURL{
+					URL: "data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mP8z8BQDwAEhQGAhKmMIQAAAABJRU5ErkJggg==",
+				}},
+			}}},
+		},
+	})
+
+	require.Len(t, ar.Messages, 3)
+	results := ar.Messages[2].Content.([]Content)
+	require.Len(t, results, 1)
+	assert.Equal(t, "tool_result", results[0].Type)
+
+	blocks, ok := results[0].Content.([]Content)
+	require.True(t, ok, "multipart results are sent as content blocks")
+	require.Len(t, blocks, 2)
+	assert.Equal(t, "The login page", blocks[0].Text)
+	assert.Equal(t, "image", blocks[1].Type)
+	assert.Equal(t, "image/png", blocks[1].Source.MediaType)
+}
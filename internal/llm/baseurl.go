@@ -0,0 +1,57 @@
+package llm
+
+import (
+	"maps"
+	"strings"
+	"sync"
+)
+
+// builtinBaseURLs are the upstream endpoints used when a provider config does
+// not set base_url, keyed by provider type.
+var builtinBaseURLs = map[string]string{
+	"openai":     "https://api.openai.com/v1",
+	"anthropic":  "https://api.anthropic.com/v1",
+	"google":     "https://generativelanguage.googleapis.com/v1beta",
+	"moonshot":   "https://api.moonshot.ai/v1",
+	"perplexity": "https://api.perplexity.ai",
+	"groq":       "https://api.groq.com/openai/v1",
+	"deepseek":   "https://api.deepseek.com",
+	"elevenlabs": "https://api.elevenlabs.io/v1",
+	"openrouter": "https://openrouter.ai/api/v1",
+	"llamacpp":   "http://localhost:8080/v1",
+	"bfl":        "https://api.bfl.ai/v1",
+}
+
+var (
+	baseURLMu       sync.RWMutex
+	defaultBaseURLs = maps.Clone(builtinBaseURLs)
+)
+
+// SetDefaultBaseURLs overrides the default base URL of the given provider
+// types, e.g. to point every OpenAI provider at a proxy. Types not listed
+// keep their built-in default.
+func SetDefaultBaseURLs(overrides map[string]string) {
+	urls := maps.Clone(builtinBaseURLs)
+	for t, u := range overrides {
+		urls[t] = strings.TrimRight(u, "/")
+	}
+
+	baseURLMu.Lock()
+	defer baseURLMu.Unlock()
+	defaultBaseURLs = urls
+}
+
+// DefaultBaseURL returns the base URL used for providerType when none is
+// configured, or "" if the type has no default.
+func DefaultBaseURL(providerType string) string {
+	baseURLMu.RLock()
+	defer baseURLMu.RUnlock()
+	return defaultBaseURLs[providerType]
+}
+
+// DefaultBaseURLs returns a copy of the effective defaults.
+func DefaultBaseURLs() map[string]string {
+	baseURLMu.RLock()
+	defer baseURLMu.RUnlock()
+	return maps.Clone(defaultBaseURLs)
+}
@@ -0,0 +1,54 @@
+package llm_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/llm"
+	"github.com/nulzo/model-router-api/internal/llm/openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultBaseURL(t *testing.T) {
+	tests := map[string]string{
+		"openai":    "https://api.openai.com/v1",
+		"anthropic": "https://api.anthropic.com/v1",
+		"google":    "https://generativelanguage.googleapis.com/v1beta",
+		"unknown":   "",
+	}
+	for providerType, want := range tests {
+		assert.Equal(t, want, llm.DefaultBaseURL(providerType), providerType)
+	}
+}
+
+func TestSetDefaultBaseURLs(t *testing.T) {
+	var gotPath string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	}))
+	defer upstream.Close()
+
+	t.Cleanup(func() { llm.SetDefaultBaseURLs(nil) })
+	llm.SetDefaultBaseURLs(map[string]string{"openai": upstream.URL + "/proxy/"})
+
+	assert.Equal(t, upstream.URL+"/proxy", llm.DefaultBaseURL("openai"))
+	// types without an override keep the built-in default
+	assert.Equal(t, "https://api.anthropic.com/v1", llm.DefaultBaseURL("anthropic"))
+
+	// an adapter without base_url picks up the override
+	p, err := openai.NewAdapter(config.ProviderConfig{ID: "openai", Type: "openai"})
+	require.NoError(t, err)
+	_, err = p.Models(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "/proxy/models", gotPath)
+
+	// callers get a copy
+	urls := llm.DefaultBaseURLs()
+	urls["openai"] = "changed"
+	assert.Equal(t, upstream.URL+"/proxy", llm.DefaultBaseURL("openai"))
+}
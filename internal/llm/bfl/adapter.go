@@ -7,9 +7,11 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/httpclient"
 	"github.com/nulzo/model-router-api/internal/llm"
 	"github.com/nulzo/model-router-api/internal/llm/processing"
 	"github.com/nulzo/model-router-api/pkg/api"
@@ -28,7 +30,7 @@ type Adapter struct {
 
 func NewAdapter(config config.ProviderConfig) (llm.Provider, error) {
 	if config.BaseURL == "" {
-		config.BaseURL = "https://api.bfl.ai/v1"
+		config.BaseURL = llm.DefaultBaseURL(pn)
 	}
 
 	timeout := 5 * time.Minute
@@ -42,7 +44,7 @@ func NewAdapter(config config.ProviderConfig) (llm.Provider, error) {
 
 	return &Adapter{
 		config: config,
-		client: &http.Client{Timeout: timeout}, // Long timeout for generation + polling
+		client: httpclient.NewClient(timeout, httpclient.WithRetries(config.MaxAttempts)), // Long timeout for generation + polling
 	}, nil
 }
 
@@ -60,28 +62,72 @@ type PollingResult struct {
 }
 
 type PollingResponse struct {
-	Status  string         `json:"status"` // Ready, Processing, Pending, Error, Failed
-	Result  *PollingResult `json:"result,omitempty"`
-	Message string         `json:"message,omitempty"`
+	Status   string         `json:"status"` // Ready, Processing, Pending, Error, Failed
+	Result   *PollingResult `json:"result,omitempty"`
+	Message  string         `json:"message,omitempty"`
+	Progress *float64       `json:"progress,omitempty"` // fraction done from 0 to 1, not always reported
 }
 
+// progressFunc receives the progress of generation i while it is polled.
+type progressFunc func(i int, progress api.GenerationProgress)
+
 func (a *Adapter) Chat(ctx context.Context, req *api.ChatRequest) (*api.ChatResponse, error) {
+	return a.generate(ctx, req, nil)
+}
+
+// generate submits the generations and polls them until done, reporting
+// their progress to onProgress if set.
+func (a *Adapter) generate(ctx context.Context, req *api.ChatRequest, onProgress progressFunc) (*api.ChatResponse, error) {
 	prompt, inputImages, err := a.extractPromptAndImages(req)
 	if err != nil {
 		return nil, err
 	}
 
-	genResp, err := a.submitGenerationRequest(ctx, req.Model, prompt, inputImages)
-	if err != nil {
-		return nil, err
+	// every generation yields a single image, so several are generated concurrently
+	n := max(req.N, 1)
+	ids, imageURLs := make([]string, n), make([]string, n)
+
+	// the first failure fails the request, the other generations stop polling
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		failOnce sync.Once
+		failure  error
+	)
+	fail := func(err error) {
+		failOnce.Do(func() {
+			failure = err
+			cancel()
+		})
 	}
 
-	finalImageURL, err := a.pollForResult(ctx, genResp.PollingURL)
-	if err != nil {
-		return nil, err
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			genResp, err := a.submitGenerationRequest(ctx, req.Model, prompt, inputImages)
+			if err != nil {
+				fail(err)
+				return
+			}
+			ids[i] = genResp.ID
+			var report func(api.GenerationProgress)
+			if onProgress != nil {
+				report = func(p api.GenerationProgress) { onProgress(i, p) }
+			}
+			if imageURLs[i], err = a.pollForResult(ctx, genResp.PollingURL, report); err != nil {
+				fail(err)
+			}
+		}(i)
 	}
+	wg.Wait()
 
-	return a.constructResponse(req.Model, genResp.ID, finalImageURL)
+	if failure != nil {
+		return nil, failure
+	}
+
+	return a.constructResponse(req.Model, ids[0], imageURLs)
 }
 
 func (a *Adapter) extractPromptAndImages(req *api.ChatRequest) (string, []string, error) {
@@ -195,7 +241,9 @@ func (a *Adapter) enrichRequestWithImages(modelID string, inputImages []string,
 	}
 }
 
-func (a *Adapter) pollForResult(ctx context.Context, pollingURL string) (string, error) {
+// pollForResult polls until the image is ready. report, if set, is called
+// whenever the status or progress changed.
+func (a *Adapter) pollForResult(ctx context.Context, pollingURL string, report func(api.GenerationProgress)) (string, error) {
 	ticker := time.NewTicker(500 * time.Millisecond)
 	defer ticker.Stop()
 
@@ -204,6 +252,7 @@ func (a *Adapter) pollForResult(ctx context.Context, pollingURL string) (string,
 	timeout := time.NewTimer(10 * time.Minute)
 	defer timeout.Stop()
 
+	var last api.GenerationProgress
 	for {
 		select {
 		case <-ctx.Done():
@@ -211,28 +260,34 @@ func (a *Adapter) pollForResult(ctx context.Context, pollingURL string) (string,
 		case <-timeout.C:
 			return "", fmt.Errorf("polling timed out after 10 minutes")
 		case <-ticker.C:
-			res, err := a.checkPollStatus(ctx, pollingURL)
+			res, progress, err := a.checkPollStatus(ctx, pollingURL)
 			if err != nil {
 				return "", err
 			}
 			if res != "" {
 				return res, nil
 			}
+			if report != nil && progress != nil && !sameProgress(*progress, last) {
+				last = *progress
+				report(*progress)
+			}
 		}
 	}
 }
 
-func (a *Adapter) checkPollStatus(ctx context.Context, pollingURL string) (string, error) {
+// checkPollStatus returns the image URL once the generation is ready, or
+// else its progress.
+func (a *Adapter) checkPollStatus(ctx context.Context, pollingURL string) (string, *api.GenerationProgress, error) {
 	pollReq, err := http.NewRequestWithContext(ctx, "GET", pollingURL, nil)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 	pollReq.Header.Set("accept", "application/json")
 	pollReq.Header.Set("x-key", a.config.APIKey)
 
 	pollResp, err := a.client.Do(pollReq)
 	if err != nil {
-		return "", fmt.Errorf("polling failed: %w", err)
+		return "", nil, fmt.Errorf("polling failed: %w", err)
 	}
 
 	defer func() {
@@ -244,17 +299,17 @@ func (a *Adapter) checkPollStatus(ctx context.Context, pollingURL string) (strin
 	var pollResult PollingResponse
 	if err := json.Unmarshal(bodyBytes, &pollResult); err != nil {
 		if pollResp.StatusCode != http.StatusOK {
-			return "", fmt.Errorf("polling failed with status %d: %s", pollResp.StatusCode, string(bodyBytes))
+			return "", nil, fmt.Errorf("polling failed with status %d: %s", pollResp.StatusCode, string(bodyBytes))
 		}
-		return "", fmt.Errorf("failed to decode polling response: %w", err)
+		return "", nil, fmt.Errorf("failed to decode polling response: %w", err)
 	}
 
 	switch pollResult.Status {
 	case "Ready":
 		if pollResult.Result != nil {
-			return pollResult.Result.Sample, nil
+			return pollResult.Result.Sample, nil, nil
 		}
-		return "", fmt.Errorf("status is Ready but result is missing")
+		return "", nil, fmt.Errorf("status is Ready but result is missing")
 	case "Error", "Failed", "Request Moderated", "Content Moderated", "Task not found":
 		errMsg := pollResult.Message
 		if errMsg == "" {
@@ -262,23 +317,40 @@ func (a *Adapter) checkPollStatus(ctx context.Context, pollingURL string) (strin
 		} else {
 			errMsg = fmt.Sprintf("%s (%s)", errMsg, pollResult.Status)
 		}
-		return "", fmt.Errorf("generation failed: %s", errMsg)
+		return "", nil, fmt.Errorf("generation failed: %s", errMsg)
 	}
 
 	// Check for HTTP errors even if Status wasn't explicitly a failure state we know
 	if pollResp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("polling failed with status %d: %s", pollResp.StatusCode, pollResult.Message)
+		return "", nil, fmt.Errorf("polling failed with status %d: %s", pollResp.StatusCode, pollResult.Message)
 	}
 
-	return "", nil // Continue polling
+	// Continue polling
+	progress := &api.GenerationProgress{Status: pollResult.Status}
+	if p := pollResult.Progress; p != nil {
+		percent := *p * 100
+		progress.Percent = &percent
+	}
+	return "", progress, nil
 }
 
-func (a *Adapter) constructResponse(modelID, id, imageURL string) (*api.ChatResponse, error) {
-	// BFL URLs are ephemeral (10 min), so we fetch it now to provide a persistent result
-	// and stay consistent with other providers in this app.
-	imgData, err := processing.ProcessImageURL(imageURL)
-	if err == nil {
-		imageURL = fmt.Sprintf("data:%s;base64,%s", imgData.MediaType, imgData.Data)
+func sameProgress(a, b api.GenerationProgress) bool {
+	if a.Status != b.Status || (a.Percent == nil) != (b.Percent == nil) {
+		return false
+	}
+	return a.Percent == nil || *a.Percent == *b.Percent
+}
+
+func (a *Adapter) constructResponse(modelID, id string, imageURLs []string) (*api.ChatResponse, error) {
+	images := make([]api.ContentPart, 0, len(imageURLs))
+	for _, imageURL := range imageURLs {
+		// BFL URLs are ephemeral (10 min), so we fetch it now to provide a persistent result
+		// and stay consistent with other providers in this app.
+		imgData, err := processing.ProcessImageURL(imageURL)
+		if err == nil {
+			imageURL = fmt.Sprintf("data:%s;base64,%s", imgData.MediaType, imgData.Data)
+		}
+		images = append(images, processing.ImagePart(imageURL))
 	}
 
 	return &api.ChatResponse{
@@ -288,27 +360,11 @@ func (a *Adapter) constructResponse(modelID, id, imageURL string) (*api.ChatResp
 		Choices: []api.Choice{{
 			Index: 0,
 			Message: &api.ChatMessage{
-				Role: "assistant",
-				Content: api.Content{
-					Parts: []api.ContentPart{
-						{
-							Type: "image_url",
-							ImageURL: &api.ImageURL{
-								URL: imageURL,
-							},
-						},
-					},
-				},
-				Images: []api.ContentPart{
-					{
-						Type: "image_url",
-						ImageURL: &api.ImageURL{
-							URL: imageURL,
-						},
-					},
-				},
+				Role:    "assistant",
+				Content: processing.ImageContent("", images),
+				Images:  images,
 			},
-			FinishReason: "stop",
+			FinishReason: string(api.FinishReasonStop),
 		}},
 		Usage: &api.ResponseUsage{
 			TotalTokens: 0,
@@ -316,16 +372,38 @@ func (a *Adapter) constructResponse(modelID, id, imageURL string) (*api.ChatResp
 	}, nil
 }
 
+// Stream sends a progress delta whenever a generation's polled status
+// changes, so clients are not left with a silent stream for minutes, and
+// the images once all generations are done.
 func (a *Adapter) Stream(ctx context.Context, req *api.ChatRequest) (<-chan api.StreamResult, error) {
-
 	ch := make(chan api.StreamResult)
 	go func() {
 		defer close(ch)
-		resp, err := a.Chat(ctx, req)
+		resp, err := a.generate(ctx, req, func(i int, progress api.GenerationProgress) {
+			chunk := &api.ChatResponse{
+				Model:   req.Model,
+				Created: time.Now().Unix(),
+				Object:  "chat.completion.chunk",
+				Choices: []api.Choice{{
+					Index: i,
+					Delta: &api.ChatMessage{Role: string(api.Assistant), Progress: &progress},
+				}},
+			}
+			select {
+			case ch <- api.StreamResult{Response: chunk}:
+			case <-ctx.Done():
+			}
+		})
 		if err != nil {
 			ch <- api.StreamResult{Err: err}
 			return
 		}
+		// send the images as a single delta, the gateway may chunk them
+		for i, choice := range resp.Choices {
+			resp.Choices[i].Delta = &api.ChatMessage{Role: choice.Message.Role, Images: choice.Message.Images}
+			resp.Choices[i].Message = nil
+		}
+		resp.Object = "chat.completion.chunk"
 		ch <- api.StreamResult{Response: resp}
 	}()
 	return ch, nil
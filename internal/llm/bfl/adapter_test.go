@@ -0,0 +1,134 @@
+package bfl_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/llm/bfl"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sample = "data:image/png;base64,iVBORw0KGgo="
+
+// newServer serves a generation that is polled through the given replies,
+// the last of which is repeated.
+func newServer(t *testing.T, replies ...string) *httptest.Server {
+	t.Helper()
+	var polls atomic.Int32
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flux-pro-1.1":
+			_, _ = w.Write([]byte(`{"id":"gen-1","polling_url":"` + server.URL + `/get_result"}`))
+		case "/get_result":
+			i := min(int(polls.Add(1))-1, len(replies)-1)
+			_, _ = w.Write([]byte(replies[i]))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newAdapter(t *testing.T, server *httptest.Server) *bfl.Adapter {
+	t.Helper()
+	p, err := bfl.NewAdapter(config.ProviderConfig{ID: "bfl", Type: "bfl", BaseURL: server.URL, APIKey: "key"})
+	require.NoError(t, err)
+	return p.(*bfl.Adapter)
+}
+
+func imageRequest() *api.ChatRequest {
+	return &api.ChatRequest{
+		Model:    "flux-pro-1.1",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "A lighthouse at dusk"}}},
+	}
+}
+
+func TestStream_ReportsPollingProgress(t *testing.T) {
+	server := newServer(t,
+		`{"status":"Pending"}`,
+		`{"status":"Processing","progress":0.4}`,
+		`{"status":"Processing","progress":0.4}`,
+		`{"status":"Ready","result":{"sample":"`+sample+`"}}`,
+	)
+
+	ch, err := newAdapter(t, server).Stream(context.Background(), imageRequest())
+	require.NoError(t, err)
+
+	var chunks []*api.ChatResponse
+	for res := range ch {
+		require.NoError(t, res.Err)
+		chunks = append(chunks, res.Response)
+	}
+
+	// an unchanged poll is not reported again
+	require.Len(t, chunks, 3)
+
+	pending := chunks[0].Choices[0].Delta.Progress
+	require.NotNil(t, pending)
+	assert.Equal(t, "Pending", pending.Status)
+	assert.Nil(t, pending.Percent)
+
+	processing := chunks[1].Choices[0].Delta.Progress
+	require.NotNil(t, processing)
+	assert.Equal(t, "Processing", processing.Status)
+	require.NotNil(t, processing.Percent)
+	assert.InDelta(t, 40, *processing.Percent, 0.001)
+
+	final := chunks[2].Choices[0]
+	assert.Nil(t, final.Delta.Progress)
+	require.Len(t, final.Delta.Images, 1)
+	assert.Equal(t, sample, final.Delta.Images[0].ImageURL.URL)
+	assert.Equal(t, string(api.FinishReasonStop), final.FinishReason)
+}
+
+func TestChat_FailedGeneration(t *testing.T) {
+	server := newServer(t,
+		`{"status":"Processing"}`,
+		`{"status":"Content Moderated","message":"flagged"}`,
+	)
+
+	_, err := newAdapter(t, server).Chat(context.Background(), imageRequest())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "flagged (Content Moderated)")
+}
+
+func TestChat_FirstFailureStopsOtherGenerations(t *testing.T) {
+	var submissions atomic.Int32
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flux-pro-1.1":
+			if submissions.Add(1) > 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(`{"detail":"overloaded"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"id":"gen-1","polling_url":"` + server.URL + `/get_result"}`))
+		case "/get_result":
+			_, _ = w.Write([]byte(`{"status":"Pending"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req := imageRequest()
+	req.N = 2
+
+	// the pending generation is abandoned rather than polled to the end
+	_, err := newAdapter(t, server).Chat(ctx, req)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status 500")
+	assert.NoError(t, ctx.Err())
+}
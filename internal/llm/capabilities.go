@@ -0,0 +1,68 @@
+package llm
+
+import (
+	"context"
+	"slices"
+
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+// SpeechSynthesizer is implemented by providers that turn text into speech.
+type SpeechSynthesizer interface {
+	Speech(ctx context.Context, req *api.SpeechRequest) (*api.SpeechResponse, error)
+}
+
+// Transcriber is implemented by providers that transcribe audio.
+type Transcriber interface {
+	Transcribe(ctx context.Context, req *api.TranscriptionRequest) (*api.TranscriptionResponse, error)
+}
+
+// CapabilityReporter is implemented by providers that declare features which
+// can not be inferred from their models, e.g. tools on every model.
+type CapabilityReporter interface {
+	Capabilities() Capabilities
+}
+
+// Capabilities is the feature matrix of a provider.
+type Capabilities struct {
+	Chat          bool `json:"chat"`
+	Stream        bool `json:"stream"`
+	Tools         bool `json:"tools"`
+	JSONMode      bool `json:"json_mode"` // honors response_format json_object/json_schema
+	Vision        bool `json:"vision"`
+	Embeddings    bool `json:"embeddings"` // only as declared by the provider
+	Moderation    bool `json:"moderation"` // only as declared by the provider
+	Speech        bool `json:"speech"`
+	Transcription bool `json:"transcription"`
+}
+
+// DetectCapabilities derives what p supports from the optional interfaces it
+// implements and the configuration of the models it serves.
+func DetectCapabilities(p Provider, models []api.ModelDefinition) Capabilities {
+	caps := Capabilities{Chat: true, Stream: true}
+
+	for _, m := range models {
+		if m.Config.ToolUse {
+			caps.Tools = true
+		}
+		if m.Config.ImageSupport || slices.Contains(m.Architecture.InputModalities, "image") {
+			caps.Vision = true
+		}
+	}
+
+	_, caps.Speech = p.(SpeechSynthesizer)
+	_, caps.Transcription = p.(Transcriber)
+
+	if r, ok := p.(CapabilityReporter); ok {
+		declared := r.Capabilities()
+		caps.Tools = caps.Tools || declared.Tools
+		caps.JSONMode = caps.JSONMode || declared.JSONMode
+		caps.Vision = caps.Vision || declared.Vision
+		caps.Embeddings = caps.Embeddings || declared.Embeddings
+		caps.Moderation = caps.Moderation || declared.Moderation
+		caps.Speech = caps.Speech || declared.Speech
+		caps.Transcription = caps.Transcription || declared.Transcription
+	}
+
+	return caps
+}
@@ -0,0 +1,198 @@
+package compatible
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/httpclient"
+	"github.com/nulzo/model-router-api/internal/llm"
+	"github.com/nulzo/model-router-api/internal/llm/openai"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+// ProviderType is the type of self hosted OpenAI compatible backends such as
+// vLLM, LiteLLM, LocalAI and TGI.
+const ProviderType = "openai-compatible"
+
+func init() {
+	llm.Register(ProviderType, NewAdapter)
+}
+
+// Adapter serves chat through the OpenAI adapter and discovers its models by
+// probing the backend instead of relying on static model config.
+type Adapter struct {
+	*openai.Adapter
+	config config.ProviderConfig
+	client *http.Client
+}
+
+func NewAdapter(config config.ProviderConfig) (llm.Provider, error) {
+	if config.BaseURL == "" {
+		return nil, fmt.Errorf("provider %s: base_url is required for %s providers", config.ID, ProviderType)
+	}
+
+	base, err := openai.NewCompatibleAdapter(config, openai.Options{Type: ProviderType})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Adapter{
+		Adapter: base,
+		config:  config,
+		client:  httpclient.NewClient(30 * time.Second),
+	}, nil
+}
+
+// modelInfo is what the probes learned about a served model.
+type modelInfo struct {
+	contextLength int
+	toolUse       bool
+}
+
+// Models lists the backend's models from /models, with the context window and
+// tool support reported by whichever extension the backend has: vLLM's
+// max_model_len, LiteLLM's /model/info or TGI's /info, a window none of them
+// reports is left at 0, unknown. Configured models take precedence over
+// discovered ones.
+func (a *Adapter) Models(ctx context.Context) ([]api.ModelDefinition, error) {
+	var listed struct {
+		Data []struct {
+			ID          string `json:"id"`
+			MaxModelLen int    `json:"max_model_len"` // vLLM
+		} `json:"data"`
+	}
+	if err := httpclient.SendRequest(ctx, a.client, http.MethodGet, a.url("/models"), a.headers(), nil, &listed); err != nil {
+		// an unreachable backend still serves the configured models once it is up
+		return a.config.StaticModels, nil
+	}
+
+	extensions := a.probeLiteLLM(ctx)
+	tgi := a.probeTGI(ctx)
+
+	models := append([]api.ModelDefinition(nil), a.config.StaticModels...)
+	configured := make(map[string]bool, len(models))
+	for _, m := range models {
+		configured[m.UpstreamID] = true
+	}
+
+	for _, m := range listed.Data {
+		if configured[m.ID] {
+			continue
+		}
+
+		info := extensions[m.ID]
+		if info.contextLength == 0 {
+			info.contextLength = m.MaxModelLen
+		}
+		if info.contextLength == 0 && tgi != nil && tgi.id == m.ID {
+			info.contextLength = tgi.contextLength
+		}
+
+		models = append(models, a.definition(m.ID, info))
+	}
+
+	return models, nil
+}
+
+// probeLiteLLM reads LiteLLM's /model/info, keyed by the public model name.
+// Other backends answer 404 and yield no info.
+func (a *Adapter) probeLiteLLM(ctx context.Context) map[string]modelInfo {
+	var resp struct {
+		Data []struct {
+			ModelName string `json:"model_name"`
+			ModelInfo struct {
+				MaxInputTokens          int  `json:"max_input_tokens"`
+				MaxTokens               int  `json:"max_tokens"`
+				SupportsFunctionCalling bool `json:"supports_function_calling"`
+			} `json:"model_info"`
+		} `json:"data"`
+	}
+	if err := httpclient.SendRequest(ctx, a.client, http.MethodGet, a.rootURL("/model/info"), a.headers(), nil, &resp); err != nil {
+		return nil
+	}
+
+	infos := make(map[string]modelInfo, len(resp.Data))
+	for _, d := range resp.Data {
+		window := d.ModelInfo.MaxInputTokens
+		if window == 0 {
+			window = d.ModelInfo.MaxTokens
+		}
+		infos[d.ModelName] = modelInfo{contextLength: window, toolUse: d.ModelInfo.SupportsFunctionCalling}
+	}
+	return infos
+}
+
+// tgiInfo is the single model a TGI server serves.
+type tgiInfo struct {
+	id            string
+	contextLength int
+}
+
+// probeTGI reads TGI's /info, nil for other backends.
+func (a *Adapter) probeTGI(ctx context.Context) *tgiInfo {
+	var resp struct {
+		ModelID        string `json:"model_id"`
+		MaxInputTokens int    `json:"max_input_tokens"`
+		MaxTotalTokens int    `json:"max_total_tokens"`
+	}
+	if err := httpclient.SendRequest(ctx, a.client, http.MethodGet, a.rootURL("/info"), a.headers(), nil, &resp); err != nil || resp.ModelID == "" {
+		return nil
+	}
+	window := resp.MaxTotalTokens
+	if window == 0 {
+		window = resp.MaxInputTokens
+	}
+	return &tgiInfo{id: resp.ModelID, contextLength: window}
+}
+
+func (a *Adapter) definition(id string, info modelInfo) api.ModelDefinition {
+	return api.ModelDefinition{
+		ID:            fmt.Sprintf("%s/%s", a.config.ID, id),
+		Name:          id,
+		ProviderID:    a.config.ID,
+		UpstreamID:    id,
+		Enabled:       true,
+		Source:        "auto",
+		LastUpdated:   time.Now(),
+		ContextLength: info.contextLength,
+		Pricing: api.ModelPricing{
+			Prompt:     "0",
+			Completion: "0",
+		},
+		Config: api.ModelConfig{
+			ContextWindow:    info.contextLength,
+			Modality:         []string{"text"},
+			ToolUse:          info.toolUse,
+			StreamingSupport: true,
+		},
+		Architecture: api.ModelArchitecture{
+			InputModalities:  []string{"text"},
+			OutputModalities: []string{"text"},
+		},
+		TopProvider: api.ModelTopProvider{
+			ContextLength: info.contextLength,
+		},
+	}
+}
+
+func (a *Adapter) headers() map[string]string {
+	if a.config.APIKey == "" {
+		return nil
+	}
+	return map[string]string{"Authorization": "Bearer " + a.config.APIKey}
+}
+
+// url resolves path against the OpenAI API base, e.g. http://host/v1.
+func (a *Adapter) url(path string) string {
+	return strings.TrimRight(a.config.BaseURL, "/") + path
+}
+
+// rootURL resolves path against the server root, the extensions live outside
+// the /v1 prefix.
+func (a *Adapter) rootURL(path string) string {
+	return strings.TrimSuffix(strings.TrimRight(a.config.BaseURL, "/"), "/v1") + path
+}
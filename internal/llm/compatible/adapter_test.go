@@ -0,0 +1,108 @@
+package compatible_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/llm"
+	"github.com/nulzo/model-router-api/internal/llm/compatible"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// backend serves the given paths and answers 404 to everything else.
+func backend(t *testing.T, routes map[string]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := routes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCompatibleModels_Probing(t *testing.T) {
+	tests := []struct {
+		name   string
+		routes map[string]string
+		want   map[string][2]any // upstream ID -> context length, tool use
+	}{
+		{
+			name: "vllm",
+			routes: map[string]string{
+				"/v1/models": `{"data":[{"id":"meta-llama/Llama-3.1-8B-Instruct","max_model_len":32768}]}`,
+			},
+			want: map[string][2]any{"meta-llama/Llama-3.1-8B-Instruct": {32768, false}},
+		},
+		{
+			name: "litellm",
+			routes: map[string]string{
+				"/v1/models":  `{"data":[{"id":"gpt-4o"},{"id":"claude"}]}`,
+				"/model/info": `{"data":[{"model_name":"gpt-4o","model_info":{"max_input_tokens":128000,"supports_function_calling":true}},{"model_name":"claude","model_info":{"max_tokens":200000}}]}`,
+			},
+			want: map[string][2]any{"gpt-4o": {128000, true}, "claude": {200000, false}},
+		},
+		{
+			name: "tgi",
+			routes: map[string]string{
+				"/v1/models": `{"data":[{"id":"tgi"}]}`,
+				"/info":      `{"model_id":"tgi","max_input_tokens":4095,"max_total_tokens":8192}`,
+			},
+			want: map[string][2]any{"tgi": {8192, false}},
+		},
+		{
+			name:   "no extensions",
+			routes: map[string]string{"/v1/models": `{"data":[{"id":"local-model"}]}`},
+			want:   map[string][2]any{"local-model": {0, false}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := backend(t, tt.routes)
+			adapter, err := compatible.NewAdapter(config.ProviderConfig{ID: "local", Type: compatible.ProviderType, BaseURL: server.URL + "/v1"})
+			require.NoError(t, err)
+			assert.Equal(t, "openai-compatible", adapter.Type())
+
+			models, err := adapter.Models(context.Background())
+			require.NoError(t, err)
+			require.Len(t, models, len(tt.want))
+			for _, m := range models {
+				want, ok := tt.want[m.UpstreamID]
+				require.True(t, ok, m.UpstreamID)
+				assert.Equal(t, "local/"+m.UpstreamID, m.ID)
+				assert.Equal(t, want[0], m.ContextLength)
+				assert.Equal(t, want[0], m.Config.ContextWindow)
+				assert.Equal(t, want[1], m.Config.ToolUse)
+			}
+		})
+	}
+}
+
+func TestCompatibleModels_ConfiguredModelsWin(t *testing.T) {
+	server := backend(t, map[string]string{"/v1/models": `{"data":[{"id":"a","max_model_len":1000},{"id":"b"}]}`})
+	static := []api.ModelDefinition{{ID: "local/a", UpstreamID: "a", ContextLength: 2000}}
+	adapter, err := compatible.NewAdapter(config.ProviderConfig{ID: "local", Type: compatible.ProviderType, BaseURL: server.URL + "/v1", StaticModels: static})
+	require.NoError(t, err)
+
+	models, err := adapter.Models(context.Background())
+	require.NoError(t, err)
+	require.Len(t, models, 2)
+	assert.Equal(t, static[0], models[0])
+	assert.Equal(t, "b", models[1].UpstreamID)
+
+	_, isRaw := adapter.(llm.RawStreamer)
+	assert.True(t, isRaw, "chunks can be passed through like OpenAI's")
+}
+
+func TestCompatibleRequiresBaseURL(t *testing.T) {
+	_, err := compatible.NewAdapter(config.ProviderConfig{ID: "local", Type: compatible.ProviderType})
+	require.Error(t, err)
+}
@@ -0,0 +1,64 @@
+package deepseek
+
+import (
+	"encoding/json"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/llm"
+	"github.com/nulzo/model-router-api/internal/llm/openai"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+func init() {
+	llm.Register("deepseek", NewAdapter)
+}
+
+// reasoningMessage is the part of a DeepSeek message or delta carrying the
+// chain of thought of deepseek-reasoner.
+type reasoningMessage struct {
+	ReasoningContent string `json:"reasoning_content"`
+}
+
+type reasoningResponse struct {
+	Choices []struct {
+		Index   int               `json:"index"`
+		Message *reasoningMessage `json:"message"`
+		Delta   *reasoningMessage `json:"delta"`
+	} `json:"choices"`
+}
+
+// NewAdapter returns an adapter for DeepSeek, whose chat API is OpenAI
+// compatible. deepseek-reasoner returns its reasoning in a separate
+// reasoning_content field rather than in <think> tags, it is mapped onto
+// ChatMessage.Reasoning.
+func NewAdapter(config config.ProviderConfig) (llm.Provider, error) {
+	return openai.NewCompatibleAdapter(config, openai.Options{
+		Type:       "deepseek",
+		OnResponse: applyReasoning,
+	})
+}
+
+func applyReasoning(raw []byte, resp *api.ChatResponse) {
+	var decoded reasoningResponse
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return
+	}
+
+	for _, c := range decoded.Choices {
+		for i := range resp.Choices {
+			choice := &resp.Choices[i]
+			if choice.Index != c.Index {
+				continue
+			}
+			if c.Message != nil && c.Message.ReasoningContent != "" && choice.Message != nil {
+				choice.Message.Reasoning = c.Message.ReasoningContent
+			}
+			if c.Delta != nil && c.Delta.ReasoningContent != "" {
+				if choice.Delta == nil {
+					choice.Delta = &api.ChatMessage{}
+				}
+				choice.Delta.Reasoning = c.Delta.ReasoningContent
+			}
+		}
+	}
+}
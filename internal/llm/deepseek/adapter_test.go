@@ -0,0 +1,74 @@
+package deepseek_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/llm/deepseek"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeepSeekChat_ReasoningContent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/chat/completions", r.URL.Path)
+		_, _ = w.Write([]byte(`{
+			"id": "ds-1",
+			"object": "chat.completion",
+			"model": "deepseek-reasoner",
+			"choices": [{
+				"index": 0,
+				"message": {"role": "assistant", "content": "9.11 is smaller.", "reasoning_content": "Compare the decimals."},
+				"finish_reason": "stop"
+			}]
+		}`))
+	}))
+	defer server.Close()
+
+	adapter, err := deepseek.NewAdapter(config.ProviderConfig{ID: "deepseek", Type: "deepseek", BaseURL: server.URL})
+	require.NoError(t, err)
+	assert.Equal(t, "deepseek", adapter.Type())
+
+	resp, err := adapter.Chat(context.Background(), &api.ChatRequest{
+		Model:    "deepseek-reasoner",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "9.11 or 9.9?"}}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "9.11 is smaller.", resp.Choices[0].Message.Content.Text)
+	assert.Equal(t, "Compare the decimals.", resp.Choices[0].Message.Reasoning)
+}
+
+func TestDeepSeekStream_ReasoningContent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(`data: {"id":"ds-1","choices":[{"index":0,"delta":{"role":"assistant","content":null,"reasoning_content":"Compare "}}]}` + "\n\n"))
+		_, _ = w.Write([]byte(`data: {"id":"ds-1","choices":[{"index":0,"delta":{"content":null,"reasoning_content":"the decimals."}}]}` + "\n\n"))
+		_, _ = w.Write([]byte(`data: {"id":"ds-1","choices":[{"index":0,"delta":{"content":"9.11 is smaller.","reasoning_content":null}}]}` + "\n\n"))
+		_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	adapter, err := deepseek.NewAdapter(config.ProviderConfig{ID: "deepseek", Type: "deepseek", BaseURL: server.URL})
+	require.NoError(t, err)
+
+	ch, err := adapter.Stream(context.Background(), &api.ChatRequest{
+		Model:    "deepseek-reasoner",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "9.11 or 9.9?"}}},
+	})
+	require.NoError(t, err)
+
+	var content, reasoning string
+	for res := range ch {
+		require.NoError(t, res.Err)
+		for _, c := range res.Response.Choices {
+			content += c.Delta.Content.Text
+			reasoning += c.Delta.Reasoning
+		}
+	}
+	assert.Equal(t, "9.11 is smaller.", content)
+	assert.Equal(t, "Compare the decimals.", reasoning)
+}
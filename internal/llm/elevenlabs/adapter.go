@@ -0,0 +1,193 @@
+package elevenlabs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/httpclient"
+	"github.com/nulzo/model-router-api/internal/llm"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+func init() {
+	llm.Register(string(llm.ElevenLabs), NewAdapter)
+}
+
+// outputFormats maps the OpenAI response formats onto ElevenLabs output
+// formats and the content type of the audio they produce.
+var outputFormats = map[string]struct{ format, contentType string }{
+	"mp3":  {"mp3_44100_128", "audio/mpeg"},
+	"opus": {"opus_48000_128", "audio/opus"},
+	"pcm":  {"pcm_24000", "audio/pcm"},
+	"ulaw": {"ulaw_8000", "audio/basic"},
+}
+
+// Adapter synthesizes speech with ElevenLabs. It serves no chat, the voice
+// used when a request names none is set with config.default_voice_id.
+type Adapter struct {
+	config config.ProviderConfig
+	client *http.Client
+}
+
+func NewAdapter(config config.ProviderConfig) (llm.Provider, error) {
+	if config.BaseURL == "" {
+		config.BaseURL = llm.DefaultBaseURL(string(llm.ElevenLabs))
+	}
+
+	timeout := 2 * time.Minute
+	if config.Timeout != "" {
+		if d, err := time.ParseDuration(config.Timeout); err == nil {
+			timeout = d
+		}
+	}
+
+	return &Adapter{
+		config: config,
+		client: httpclient.NewClient(timeout, httpclient.WithRetries(config.MaxAttempts)),
+	}, nil
+}
+
+func (a *Adapter) Name() string {
+	return a.config.ID
+}
+
+func (a *Adapter) Type() string {
+	return string(llm.ElevenLabs)
+}
+
+func (a *Adapter) Chat(ctx context.Context, req *api.ChatRequest) (*api.ChatResponse, error) {
+	return nil, errSpeechOnly
+}
+
+func (a *Adapter) Stream(ctx context.Context, req *api.ChatRequest) (<-chan api.StreamResult, error) {
+	return nil, errSpeechOnly
+}
+
+var errSpeechOnly = api.NewError(http.StatusBadRequest, "Chat Not Supported", "ElevenLabs models only synthesize speech, use /audio/speech")
+
+type speechBody struct {
+	Text          string         `json:"text"`
+	ModelID       string         `json:"model_id"`
+	VoiceSettings *voiceSettings `json:"voice_settings,omitempty"`
+}
+
+type voiceSettings struct {
+	Speed float64 `json:"speed,omitempty"`
+}
+
+// Speech calls the text-to-speech endpoint of the requested voice.
+func (a *Adapter) Speech(ctx context.Context, req *api.SpeechRequest) (*api.SpeechResponse, error) {
+	voice := req.Voice
+	if voice == "" {
+		voice = a.config.Config["default_voice_id"]
+	}
+	if voice == "" {
+		return nil, api.BadRequestError("a voice is required, the provider has no default_voice_id")
+	}
+
+	format := req.ResponseFormat
+	if format == "" {
+		format = "mp3"
+	}
+	output, ok := outputFormats[format]
+	if !ok {
+		return nil, api.BadRequestError(fmt.Sprintf("unsupported response_format '%s'", format))
+	}
+
+	body := speechBody{Text: req.Input, ModelID: req.Model}
+	if req.Speed > 0 {
+		body.VoiceSettings = &voiceSettings{Speed: req.Speed}
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/text-to-speech/%s?output_format=%s",
+		strings.TrimRight(a.config.BaseURL, "/"), url.PathEscape(voice), output.format)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("xi-api-key", a.config.APIKey)
+
+	resp, err := a.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	audio, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, upstreamError(resp.StatusCode, audio)
+	}
+
+	return &api.SpeechResponse{
+		Audio:       audio,
+		ContentType: output.contentType,
+		Characters:  len([]rune(req.Input)),
+	}, nil
+}
+
+// upstreamError turns an ElevenLabs error body, {"detail": {"message": ...}}
+// or {"detail": "..."}, into a problem.
+func upstreamError(status int, body []byte) error {
+	var decoded struct {
+		Detail json.RawMessage `json:"detail"`
+	}
+	detail := string(body)
+	if err := json.Unmarshal(body, &decoded); err == nil && len(decoded.Detail) > 0 {
+		var message struct {
+			Message string `json:"message"`
+		}
+		var text string
+		switch {
+		case json.Unmarshal(decoded.Detail, &message) == nil && message.Message != "":
+			detail = message.Message
+		case json.Unmarshal(decoded.Detail, &text) == nil:
+			detail = text
+		}
+	}
+	return api.NewError(status, "Upstream Provider Error", detail)
+}
+
+// Models returns the configured models, they are picked per deployment.
+func (a *Adapter) Models(ctx context.Context) ([]api.ModelDefinition, error) {
+	return a.config.StaticModels, nil
+}
+
+func (a *Adapter) Health(ctx context.Context) error {
+	endpoint := fmt.Sprintf("%s/models", strings.TrimRight(a.config.BaseURL, "/"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("xi-api-key", a.config.APIKey)
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check failed with status: %d", resp.StatusCode)
+	}
+	return nil
+}
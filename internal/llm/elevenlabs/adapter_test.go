@@ -0,0 +1,69 @@
+package elevenlabs_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/llm"
+	"github.com/nulzo/model-router-api/internal/llm/elevenlabs"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestElevenLabsSpeech(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/text-to-speech/voice-1", r.URL.Path)
+		assert.Equal(t, "opus_48000_128", r.URL.Query().Get("output_format"))
+		assert.Equal(t, "xi-key", r.Header.Get("xi-api-key"))
+
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "Héllo", body["text"])
+		assert.Equal(t, "eleven_flash_v2_5", body["model_id"])
+		assert.Equal(t, map[string]any{"speed": 1.1}, body["voice_settings"])
+
+		_, _ = w.Write([]byte("OggS-audio"))
+	}))
+	defer server.Close()
+
+	provider, err := elevenlabs.NewAdapter(config.ProviderConfig{
+		ID: "elevenlabs", Type: "elevenlabs", APIKey: "xi-key", BaseURL: server.URL,
+		Config: map[string]string{"default_voice_id": "voice-1"},
+	})
+	require.NoError(t, err)
+	synth, ok := provider.(llm.SpeechSynthesizer)
+	require.True(t, ok)
+
+	resp, err := synth.Speech(context.Background(), &api.SpeechRequest{
+		Model: "eleven_flash_v2_5", Input: "Héllo", ResponseFormat: "opus", Speed: 1.1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("OggS-audio"), resp.Audio)
+	assert.Equal(t, "audio/opus", resp.ContentType)
+	assert.Equal(t, 5, resp.Characters)
+
+	_, err = provider.Chat(context.Background(), &api.ChatRequest{})
+	require.Error(t, err, "only speech is served")
+}
+
+func TestElevenLabsSpeech_UpstreamError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"detail":{"status":"invalid_api_key","message":"Invalid API key"}}`))
+	}))
+	defer server.Close()
+
+	provider, err := elevenlabs.NewAdapter(config.ProviderConfig{ID: "elevenlabs", Type: "elevenlabs", BaseURL: server.URL})
+	require.NoError(t, err)
+
+	_, err = provider.(llm.SpeechSynthesizer).Speech(context.Background(), &api.SpeechRequest{Model: "m", Input: "Hi", Voice: "v"})
+	var problem *api.Problem
+	require.ErrorAs(t, err, &problem)
+	assert.Equal(t, http.StatusUnauthorized, problem.Status)
+	assert.Equal(t, "Invalid API key", problem.Detail)
+}
@@ -2,6 +2,7 @@ package llm
 
 import (
 	"fmt"
+	"slices"
 	"sync"
 
 	"github.com/nulzo/model-router-api/internal/config"
@@ -49,3 +50,15 @@ func Get(providerType string) (Factory, error) {
 	}
 	return f, nil
 }
+
+// Types returns the sorted provider types that have a registered factory.
+func Types() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	types := make([]string, 0, len(factories))
+	for t := range factories {
+		types = append(types, t)
+	}
+	slices.Sort(types)
+	return types
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
@@ -29,7 +30,7 @@ type Adapter struct {
 
 func NewAdapter(config config.ProviderConfig) (llm.Provider, error) {
 	if config.BaseURL == "" {
-		config.BaseURL = "https://generativelanguage.googleapis.com/v1beta"
+		config.BaseURL = llm.DefaultBaseURL(pn)
 	}
 
 	timeout := 10 * time.Minute
@@ -41,18 +42,59 @@ func NewAdapter(config config.ProviderConfig) (llm.Provider, error) {
 		}
 	}
 
+	compress, err := httpclient.GzipFromConfig(config.ID, config.Config)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Adapter{
 		config: config,
-		client: &http.Client{Timeout: timeout},
+		client: httpclient.NewClient(timeout, compress, httpclient.WithRetries(config.MaxAttempts)),
 	}, nil
 }
 
 func (a *Adapter) Name() string { return a.config.ID }
 func (a *Adapter) Type() string { return pn }
 
+// Capabilities declares JSON mode, response_format maps to responseMimeType.
+func (a *Adapter) Capabilities() llm.Capabilities { return llm.Capabilities{JSONMode: true} }
+
 type GeminiPart struct {
-	Text       string      `json:"text,omitempty"`
-	InlineData *GeminiBlob `json:"inlineData,omitempty"`
+	Text             string                  `json:"text,omitempty"`
+	InlineData       *GeminiBlob             `json:"inlineData,omitempty"`
+	FunctionCall     *GeminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *GeminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type GeminiFunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type GeminiFunctionResponse struct {
+	ID       string          `json:"id,omitempty"`
+	Name     string          `json:"name"`
+	Response json.RawMessage `json:"response"` // must be a JSON object
+}
+
+type GeminiTool struct {
+	FunctionDeclarations []GeminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+type GeminiFunctionDeclaration struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"` // OpenAPI schema subset
+}
+
+type GeminiToolConfig struct {
+	FunctionCallingConfig GeminiFunctionCallingConfig `json:"functionCallingConfig"`
+}
+
+type GeminiFunctionCallingConfig struct {
+	Mode                 string   `json:"mode"` // "AUTO", "ANY" or "NONE"
+	AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
 }
 
 type GeminiBlob struct {
@@ -66,8 +108,20 @@ type GeminiContent struct {
 }
 
 type GeminiCandidate struct {
-	Content      GeminiContent `json:"content"`
-	FinishReason string        `json:"finishReason"`
+	Content       GeminiContent        `json:"content"`
+	FinishReason  string               `json:"finishReason"`
+	SafetyRatings []GeminiSafetyRating `json:"safetyRatings,omitempty"`
+}
+
+type GeminiSafetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked,omitempty"`
+}
+
+type GeminiPromptFeedback struct {
+	BlockReason   string               `json:"blockReason,omitempty"`
+	SafetyRatings []GeminiSafetyRating `json:"safetyRatings,omitempty"`
 }
 
 type GeminiUsageMetadata struct {
@@ -82,19 +136,26 @@ type GeminiSafetySetting struct {
 }
 
 type GeminiGenerationConfig struct {
-	ResponseModalities []string `json:"responseModalities,omitempty"`
-	Temperature        float64  `json:"temperature,omitempty"`
+	ResponseModalities []string               `json:"responseModalities,omitempty"`
+	Temperature        float64                `json:"temperature,omitempty"`
+	CandidateCount     int                    `json:"candidateCount,omitempty"`
+	ResponseMimeType   string                 `json:"responseMimeType,omitempty"`
+	ResponseSchema     map[string]interface{} `json:"responseSchema,omitempty"` // OpenAPI schema subset
 }
 
 type GeminiResponse struct {
-	Candidates    []GeminiCandidate   `json:"candidates"`
-	UsageMetadata GeminiUsageMetadata `json:"usageMetadata"`
+	Candidates     []GeminiCandidate     `json:"candidates"`
+	PromptFeedback *GeminiPromptFeedback `json:"promptFeedback,omitempty"`
+	UsageMetadata  GeminiUsageMetadata   `json:"usageMetadata"`
 }
 
 type GeminiRequest struct {
-	Contents         []GeminiContent         `json:"contents"`
-	SafetySettings   []GeminiSafetySetting   `json:"safetySettings,omitempty"`
-	GenerationConfig *GeminiGenerationConfig `json:"generationConfig,omitempty"`
+	SystemInstruction *GeminiContent          `json:"systemInstruction,omitempty"`
+	Contents          []GeminiContent         `json:"contents"`
+	Tools             []GeminiTool            `json:"tools,omitempty"`
+	ToolConfig        *GeminiToolConfig       `json:"toolConfig,omitempty"`
+	SafetySettings    []GeminiSafetySetting   `json:"safetySettings,omitempty"`
+	GenerationConfig  *GeminiGenerationConfig `json:"generationConfig,omitempty"`
 }
 
 func Shape(req *api.ChatRequest) (GeminiRequest, error) {
@@ -125,12 +186,89 @@ func Shape(req *api.ChatRequest) (GeminiRequest, error) {
 		gr.GenerationConfig.Temperature = req.Temperature
 	}
 
+	// several images are requested as several candidates
+	if req.N > 1 {
+		if gr.GenerationConfig == nil {
+			gr.GenerationConfig = &GeminiGenerationConfig{}
+		}
+		gr.GenerationConfig.CandidateCount = req.N
+	}
+
+	// JSON mode, optionally constrained to a schema
+	if f := req.ResponseFormat; f != nil && (f.Type == "json_object" || f.Type == "json_schema") {
+		if gr.GenerationConfig == nil {
+			gr.GenerationConfig = &GeminiGenerationConfig{}
+		}
+		gr.GenerationConfig.ResponseMimeType = "application/json"
+		if f.Type == "json_schema" && f.JSONSchema != nil {
+			gr.GenerationConfig.ResponseSchema = toSchema(f.JSONSchema.Schema)
+		}
+	}
+
+	if len(req.Tools) > 0 {
+		var declarations []GeminiFunctionDeclaration
+		for _, t := range req.Tools {
+			declarations = append(declarations, GeminiFunctionDeclaration{
+				Name:        t.Function.Name,
+				Description: t.Function.Description,
+				Parameters:  toSchema(t.Function.Parameters),
+			})
+		}
+		gr.Tools = []GeminiTool{{FunctionDeclarations: declarations}}
+		gr.ToolConfig = toToolConfig(req.ToolChoice)
+	}
+
+	// function responses are matched to their call by name, the OpenAI shape
+	// only carries the call ID
+	callNames := make(map[string]string)
+
 	for _, m := range req.Messages {
 		role := api.User
 		if m.Role == string(api.Assistant) {
 			role = api.ModelAssistant
 		}
 
+		// every system message goes into the one system instruction
+		if m.Role == "system" {
+			var parts []GeminiPart
+			if m.Content.Text != "" && len(m.Content.Parts) == 0 {
+				parts = append(parts, GeminiPart{Text: m.Content.Text})
+			}
+			for _, p := range m.Content.Parts {
+				if p.Type == "text" && p.Text != "" {
+					parts = append(parts, GeminiPart{Text: p.Text})
+				}
+			}
+			if len(parts) == 0 {
+				continue
+			}
+			if gr.SystemInstruction == nil {
+				gr.SystemInstruction = &GeminiContent{}
+			}
+			gr.SystemInstruction.Parts = append(gr.SystemInstruction.Parts, parts...)
+			continue
+		}
+
+		if m.Role == "tool" {
+			name := m.Name
+			if name == "" {
+				name = callNames[m.ToolCallID]
+			}
+			part := GeminiPart{FunctionResponse: &GeminiFunctionResponse{
+				ID:       m.ToolCallID,
+				Name:     name,
+				Response: functionResponse(m.Content.Text),
+			}}
+
+			// the responses to parallel calls go back in a single turn
+			if n := len(gr.Contents); n > 0 && gr.Contents[n-1].Parts[0].FunctionResponse != nil {
+				gr.Contents[n-1].Parts = append(gr.Contents[n-1].Parts, part)
+			} else {
+				gr.Contents = append(gr.Contents, GeminiContent{Role: string(api.User), Parts: []GeminiPart{part}})
+			}
+			continue
+		}
+
 		var parts []GeminiPart
 
 		if m.Content.Text != "" && len(m.Content.Parts) == 0 {
@@ -153,6 +291,19 @@ func Shape(req *api.ChatRequest) (GeminiRequest, error) {
 			}
 		}
 
+		for _, tc := range m.ToolCalls {
+			callNames[tc.ID] = tc.Function.Name
+			args := json.RawMessage(tc.Function.Arguments)
+			if len(args) == 0 {
+				args = json.RawMessage("{}")
+			}
+			parts = append(parts, GeminiPart{FunctionCall: &GeminiFunctionCall{
+				ID:   tc.ID,
+				Name: tc.Function.Name,
+				Args: args,
+			}})
+		}
+
 		if len(parts) > 0 {
 			gr.Contents = append(gr.Contents, GeminiContent{
 				Role:  string(role),
@@ -163,6 +314,147 @@ func Shape(req *api.ChatRequest) (GeminiRequest, error) {
 	return gr, nil
 }
 
+// unsupportedSchemaKeys are JSON Schema keywords Gemini's OpenAPI schema
+// subset rejects.
+var unsupportedSchemaKeys = []string{"$schema", "additionalProperties", "strict"}
+
+// toSchema returns a copy of a JSON Schema with the keywords Gemini rejects
+// removed, at every level.
+func toSchema(schema map[string]interface{}) map[string]interface{} {
+	if schema == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(schema))
+	for k, v := range schema {
+		if slices.Contains(unsupportedSchemaKeys, k) {
+			continue
+		}
+		out[k] = toSchemaValue(v)
+	}
+	return out
+}
+
+func toSchemaValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return toSchema(v)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = toSchemaValue(item)
+		}
+		return out
+	}
+	return v
+}
+
+// toToolConfig maps the OpenAI tool_choice ("none", "auto", "required" or
+// {"type":"function","function":{"name":...}}) to a function calling mode.
+func toToolConfig(choice interface{}) *GeminiToolConfig {
+	switch c := choice.(type) {
+	case string:
+		switch c {
+		case "none":
+			return &GeminiToolConfig{FunctionCallingConfig: GeminiFunctionCallingConfig{Mode: "NONE"}}
+		case "required":
+			return &GeminiToolConfig{FunctionCallingConfig: GeminiFunctionCallingConfig{Mode: "ANY"}}
+		case "auto":
+			return &GeminiToolConfig{FunctionCallingConfig: GeminiFunctionCallingConfig{Mode: "AUTO"}}
+		}
+	case map[string]interface{}:
+		if fn, ok := c["function"].(map[string]interface{}); ok {
+			if name, ok := fn["name"].(string); ok && name != "" {
+				return &GeminiToolConfig{FunctionCallingConfig: GeminiFunctionCallingConfig{
+					Mode:                 "ANY",
+					AllowedFunctionNames: []string{name},
+				}}
+			}
+		}
+	}
+	return nil
+}
+
+// functionResponse returns a tool result as the JSON object Gemini expects,
+// results that are not an object are wrapped in {"result": ...}.
+func functionResponse(result string) json.RawMessage {
+	trimmed := strings.TrimSpace(result)
+	if strings.HasPrefix(trimmed, "{") && json.Valid([]byte(trimmed)) {
+		return json.RawMessage(trimmed)
+	}
+	wrapped, _ := json.Marshal(map[string]string{"result": result})
+	return wrapped
+}
+
+// toolCalls converts the function calls of a candidate. Gemini does not
+// always assign call IDs, missing ones are generated so that results can
+// reference them. next is the index of the first call.
+func toolCalls(parts []GeminiPart, next int) []api.ToolCall {
+	var calls []api.ToolCall
+	for _, part := range parts {
+		if part.FunctionCall == nil {
+			continue
+		}
+		index := next + len(calls)
+		id := part.FunctionCall.ID
+		if id == "" {
+			id = fmt.Sprintf("call_%s_%d", part.FunctionCall.Name, index)
+		}
+		args := string(part.FunctionCall.Args)
+		if args == "" {
+			args = "{}"
+		}
+		calls = append(calls, api.ToolCall{
+			Index:    &index,
+			ID:       id,
+			Type:     "function",
+			Function: api.FunctionCall{Name: part.FunctionCall.Name, Arguments: args},
+		})
+	}
+	return calls
+}
+
+// checkBlocked inspects a Gemini response for a blocked prompt or a candidate
+// that was stopped by the safety filters, and converts it into a 422 problem
+// carrying the block reason and safety ratings so clients can see why.
+func checkBlocked(gResp *GeminiResponse) error {
+	if gResp.PromptFeedback != nil && gResp.PromptFeedback.BlockReason != "" {
+		return api.NewError(
+			http.StatusUnprocessableEntity,
+			"Content Blocked",
+			fmt.Sprintf("prompt was blocked by gemini: %s", gResp.PromptFeedback.BlockReason),
+			api.WithExtension("block_reason", gResp.PromptFeedback.BlockReason),
+			api.WithExtension("safety_ratings", gResp.PromptFeedback.SafetyRatings),
+		)
+	}
+
+	if len(gResp.Candidates) > 0 && gResp.Candidates[0].FinishReason == "SAFETY" {
+		return api.NewError(
+			http.StatusUnprocessableEntity,
+			"Content Blocked",
+			"response was blocked by gemini safety filters",
+			api.WithExtension("block_reason", "SAFETY"),
+			api.WithExtension("safety_ratings", gResp.Candidates[0].SafetyRatings),
+		)
+	}
+
+	return nil
+}
+
+// candidateImages returns the inline images of every candidate, in order.
+// The text of a response comes from the first candidate only, but each
+// requested image is a candidate of its own.
+func candidateImages(candidates []GeminiCandidate) []api.ContentPart {
+	var images []api.ContentPart
+	for _, c := range candidates {
+		for _, part := range c.Content.Parts {
+			if part.InlineData != nil {
+				images = append(images, processing.ImagePart(fmt.Sprintf("data:%s;base64,%s", part.InlineData.MimeType, part.InlineData.Data)))
+			}
+		}
+	}
+	return images
+}
+
 func (a *Adapter) Chat(ctx context.Context, req *api.ChatRequest) (*api.ChatResponse, error) {
 	var shape, _ = Shape(req)
 
@@ -177,30 +469,30 @@ func (a *Adapter) Chat(ctx context.Context, req *api.ChatRequest) (*api.ChatResp
 		return nil, err
 	}
 
+	if err := checkBlocked(&gResp); err != nil {
+		return nil, err
+	}
+
 	if len(gResp.Candidates) == 0 {
 		return nil, fmt.Errorf("no candidates from gemini")
 	}
 
 	var sb strings.Builder
-	var images []api.ContentPart
-
 	for _, part := range gResp.Candidates[0].Content.Parts {
 		if part.Text != "" {
 			sb.WriteString(part.Text)
 		}
-		if part.InlineData != nil {
-			dataURL := fmt.Sprintf("data:%s;base64,%s", part.InlineData.MimeType, part.InlineData.Data)
-			images = append(images, api.ContentPart{
-				Type: "image_url",
-				ImageURL: &api.ImageURL{
-					URL: dataURL,
-				},
-			})
-		}
 	}
+	images := candidateImages(gResp.Candidates)
 
 	content, reasoning := processing.ExtractThinking(sb.String())
 
+	calls := toolCalls(gResp.Candidates[0].Content.Parts, 0)
+	finish := string(api.FinishReasonStop)
+	if len(calls) > 0 {
+		finish = string(api.FinishReasonToolCalls)
+	}
+
 	return &api.ChatResponse{
 		ID:    fmt.Sprintf("gemini-%d", time.Now().Unix()),
 		Model: req.Model,
@@ -208,11 +500,12 @@ func (a *Adapter) Chat(ctx context.Context, req *api.ChatRequest) (*api.ChatResp
 			Index: 0,
 			Message: &api.ChatMessage{
 				Role:      string(api.Assistant),
-				Content:   api.Content{Text: content},
+				Content:   processing.ImageContent(content, images),
 				Reasoning: reasoning,
 				Images:    images,
+				ToolCalls: calls,
 			},
-			FinishReason: "stop",
+			FinishReason: finish,
 		}},
 		Usage: &api.ResponseUsage{
 			PromptTokens:     gResp.UsageMetadata.PromptTokenCount,
@@ -238,6 +531,7 @@ func (a *Adapter) Stream(ctx context.Context, req *api.ChatRequest) (<-chan api.
 
 		headers := map[string]string{}
 		parser := processing.NewStreamParser()
+		callCount := 0
 
 		err := httpclient.StreamRequest(ctx, a.client, "POST", url, headers, shape, func(line string) error {
 			if !strings.HasPrefix(line, "data: ") {
@@ -250,36 +544,39 @@ func (a *Adapter) Stream(ctx context.Context, req *api.ChatRequest) (<-chan api.
 				return nil
 			}
 
+			if err := checkBlocked(&gResp); err != nil {
+				return err
+			}
+
 			if len(gResp.Candidates) > 0 && len(gResp.Candidates[0].Content.Parts) > 0 {
 				var sb strings.Builder
-				var images []api.ContentPart
-
 				for _, part := range gResp.Candidates[0].Content.Parts {
 					if part.Text != "" {
 						sb.WriteString(part.Text)
 					}
-					if part.InlineData != nil {
-						dataURL := fmt.Sprintf("data:%s;base64,%s", part.InlineData.MimeType, part.InlineData.Data)
-						images = append(images, api.ContentPart{
-							Type: "image_url",
-							ImageURL: &api.ImageURL{
-								URL: dataURL,
-							},
-						})
-					}
 				}
+				images := candidateImages(gResp.Candidates)
 
 				text := sb.String()
 				c, r := parser.Process(text)
-				
+
+				// function calls arrive whole, each in a single chunk
+				calls := toolCalls(gResp.Candidates[0].Content.Parts, callCount)
+				callCount += len(calls)
+				choice := api.Choice{
+					Delta: &api.ChatMessage{
+						Content:   api.Content{Text: c},
+						Reasoning: r,
+						Images:    images,
+						ToolCalls: calls,
+					},
+				}
+				if callCount > 0 && gResp.Candidates[0].FinishReason != "" {
+					choice.FinishReason = string(api.FinishReasonToolCalls)
+				}
+
 				ch <- api.StreamResult{Response: &api.ChatResponse{
-					Choices: []api.Choice{{
-						Delta: &api.ChatMessage{
-							Content:   api.Content{Text: c},
-							Reasoning: r,
-							Images:    images,
-						},
-					}},
+					Choices: []api.Choice{choice},
 				}}
 			}
 
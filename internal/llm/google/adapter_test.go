@@ -1,10 +1,16 @@
 package google
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/nulzo/model-router-api/internal/config"
 	"github.com/nulzo/model-router-api/pkg/api"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestShape_ReferenceImage(t *testing.T) {
@@ -83,3 +89,281 @@ func TestShape_SimpleText(t *testing.T) {
 	// No generation config if not specified
 	assert.Nil(t, geminiReq.GenerationConfig)
 }
+
+func newTestAdapter(t *testing.T, body string) *Adapter {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	p, err := NewAdapter(config.ProviderConfig{
+		ID:      "google-test",
+		Type:    "google",
+		APIKey:  "test-key",
+		BaseURL: server.URL,
+	})
+	require.NoError(t, err)
+	return p.(*Adapter)
+}
+
+func TestChat_PromptBlocked(t *testing.T) {
+	adapter := newTestAdapter(t, `{
+		"promptFeedback": {
+			"blockReason": "SAFETY",
+			"safetyRatings": [
+				{"category": "HARM_CATEGORY_DANGEROUS_CONTENT", "probability": "HIGH", "blocked": true}
+			]
+		}
+	}`)
+
+	_, err := adapter.Chat(context.Background(), &api.ChatRequest{
+		Model:    "gemini-pro",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hello!"}}},
+	})
+
+	var problem *api.Problem
+	require.True(t, errors.As(err, &problem))
+	assert.Equal(t, http.StatusUnprocessableEntity, problem.Status)
+	assert.Equal(t, "SAFETY", problem.Extensions["block_reason"])
+
+	ratings, ok := problem.Extensions["safety_ratings"].([]GeminiSafetyRating)
+	require.True(t, ok)
+	require.Len(t, ratings, 1)
+	assert.Equal(t, "HARM_CATEGORY_DANGEROUS_CONTENT", ratings[0].Category)
+	assert.True(t, ratings[0].Blocked)
+}
+
+func TestChat_SafetyFinishedCandidate(t *testing.T) {
+	adapter := newTestAdapter(t, `{
+		"candidates": [{
+			"content": {"role": "model", "parts": []},
+			"finishReason": "SAFETY",
+			"safetyRatings": [
+				{"category": "HARM_CATEGORY_HARASSMENT", "probability": "MEDIUM", "blocked": true}
+			]
+		}]
+	}`)
+
+	_, err := adapter.Chat(context.Background(), &api.ChatRequest{
+		Model:    "gemini-pro",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hello!"}}},
+	})
+
+	var problem *api.Problem
+	require.True(t, errors.As(err, &problem))
+	assert.Equal(t, http.StatusUnprocessableEntity, problem.Status)
+	assert.Equal(t, "SAFETY", problem.Extensions["block_reason"])
+
+	ratings, ok := problem.Extensions["safety_ratings"].([]GeminiSafetyRating)
+	require.True(t, ok)
+	require.Len(t, ratings, 1)
+	assert.Equal(t, "MEDIUM", ratings[0].Probability)
+}
+
+func TestChat_MultipleImages(t *testing.T) {
+	adapter := newTestAdapter(t, `{
+		"candidates": [
+			{"content": {"role": "model", "parts": [
+				{"text": "Here you go."},
+				{"inlineData": {"mimeType": "image/png", "data": "AAAA"}}
+			]}, "finishReason": "STOP"},
+			{"content": {"role": "model", "parts": [
+				{"inlineData": {"mimeType": "image/jpeg", "data": "BBBB"}}
+			]}, "finishReason": "STOP"}
+		]
+	}`)
+
+	resp, err := adapter.Chat(context.Background(), &api.ChatRequest{
+		Model:      "gemini-2.5-flash-image",
+		Messages:   []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Draw two cats"}}},
+		Modalities: []string{"image", "text"},
+		N:          2,
+	})
+	require.NoError(t, err)
+
+	msg := resp.Choices[0].Message
+	require.Len(t, msg.Content.Parts, 3)
+	assert.Equal(t, api.ContentPart{Type: "text", Text: "Here you go."}, msg.Content.Parts[0])
+	assert.Equal(t, "data:image/png;base64,AAAA", msg.Content.Parts[1].ImageURL.URL)
+	assert.Equal(t, "data:image/jpeg;base64,BBBB", msg.Content.Parts[2].ImageURL.URL)
+	assert.Equal(t, msg.Content.Parts[1:], msg.Images)
+}
+
+func TestShape_ImageCountSetsCandidates(t *testing.T) {
+	gr, err := Shape(&api.ChatRequest{
+		Model:      "gemini-2.5-flash-image",
+		Messages:   []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Draw three cats"}}},
+		Modalities: []string{"image"},
+		N:          3,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, gr.GenerationConfig.CandidateCount)
+}
+
+func TestShape_FunctionCalling(t *testing.T) {
+	gr, err := Shape(&api.ChatRequest{
+		Model: "gemini-2.5-flash",
+		Tools: []api.Tool{{Type: "function", Function: api.FunctionDescription{
+			Name:        "get_weather",
+			Description: "Current weather for a city",
+			Parameters: map[string]interface{}{
+				"$schema":              "http://json-schema.org/draft-07/schema#",
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties": map[string]interface{}{
+					"city": map[string]interface{}{"type": "string"},
+				},
+			},
+		}}},
+		ToolChoice: map[string]interface{}{"type": "function", "function": map[string]interface{}{"name": "get_weather"}},
+		Messages: []api.ChatMessage{
+			{Role: "user", Content: api.Content{Text: "Weather in Paris and Rome?"}},
+			{Role: "assistant", ToolCalls: []api.ToolCall{
+				{ID: "call_1", Type: "function", Function: api.FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+				{ID: "call_2", Type: "function", Function: api.FunctionCall{Name: "get_weather", Arguments: `{"city":"Rome"}`}},
+			}},
+			{Role: "tool", ToolCallID: "call_1", Content: api.Content{Text: `{"temp_c": 18}`}},
+			{Role: "tool", ToolCallID: "call_2", Content: api.Content{Text: "sunny"}},
+		},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, gr.Tools, 1)
+	decl := gr.Tools[0].FunctionDeclarations[0]
+	assert.Equal(t, "get_weather", decl.Name)
+	assert.Equal(t, map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"city": map[string]interface{}{"type": "string"}},
+	}, decl.Parameters, "keywords gemini rejects are dropped")
+	require.NotNil(t, gr.ToolConfig)
+	assert.Equal(t, "ANY", gr.ToolConfig.FunctionCallingConfig.Mode)
+	assert.Equal(t, []string{"get_weather"}, gr.ToolConfig.FunctionCallingConfig.AllowedFunctionNames)
+
+	require.Len(t, gr.Contents, 3)
+	model := gr.Contents[1]
+	assert.Equal(t, "model", model.Role)
+	require.Len(t, model.Parts, 2)
+	assert.Equal(t, "get_weather", model.Parts[0].FunctionCall.Name)
+	assert.JSONEq(t, `{"city":"Paris"}`, string(model.Parts[0].FunctionCall.Args))
+
+	results := gr.Contents[2]
+	assert.Equal(t, "user", results.Role)
+	require.Len(t, results.Parts, 2, "parallel results share one turn")
+	assert.Equal(t, "get_weather", results.Parts[0].FunctionResponse.Name, "the name comes from the matching call")
+	assert.JSONEq(t, `{"temp_c": 18}`, string(results.Parts[0].FunctionResponse.Response))
+	assert.JSONEq(t, `{"result": "sunny"}`, string(results.Parts[1].FunctionResponse.Response))
+}
+
+func TestChat_FunctionCall(t *testing.T) {
+	adapter := newTestAdapter(t, `{
+		"candidates": [{
+			"content": {"role": "model", "parts": [
+				{"functionCall": {"name": "get_weather", "args": {"city": "Paris"}}}
+			]},
+			"finishReason": "STOP"
+		}],
+		"usageMetadata": {"promptTokenCount": 20, "candidatesTokenCount": 5, "totalTokenCount": 25}
+	}`)
+
+	resp, err := adapter.Chat(context.Background(), &api.ChatRequest{
+		Model:    "gemini-2.5-flash",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Weather in Paris?"}}},
+	})
+	require.NoError(t, err)
+
+	choice := resp.Choices[0]
+	assert.Equal(t, string(api.FinishReasonToolCalls), choice.FinishReason)
+	require.Len(t, choice.Message.ToolCalls, 1)
+	call := choice.Message.ToolCalls[0]
+	assert.Equal(t, "function", call.Type)
+	assert.NotEmpty(t, call.ID)
+	assert.Equal(t, "get_weather", call.Function.Name)
+	assert.JSONEq(t, `{"city":"Paris"}`, call.Function.Arguments)
+}
+
+func TestStream_FunctionCall(t *testing.T) {
+	adapter := newTestAdapter(t, `data: {"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"id":"fc-1","name":"get_weather","args":{"city":"Paris"}}}]},"finishReason":"STOP"}]}`+"\n\n")
+
+	ch, err := adapter.Stream(context.Background(), &api.ChatRequest{
+		Model:    "gemini-2.5-flash",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Weather in Paris?"}}},
+	})
+	require.NoError(t, err)
+
+	var calls []api.ToolCall
+	var finish string
+	for res := range ch {
+		require.NoError(t, res.Err)
+		for _, c := range res.Response.Choices {
+			calls = append(calls, c.Delta.ToolCalls...)
+			if c.FinishReason != "" {
+				finish = c.FinishReason
+			}
+		}
+	}
+	require.Len(t, calls, 1)
+	assert.Equal(t, "fc-1", calls[0].ID)
+	require.NotNil(t, calls[0].Index)
+	assert.Equal(t, 0, *calls[0].Index)
+	assert.Equal(t, string(api.FinishReasonToolCalls), finish)
+}
+
+func TestShape_SystemInstruction(t *testing.T) {
+	gr, err := Shape(&api.ChatRequest{
+		Model: "gemini-2.5-flash",
+		Messages: []api.ChatMessage{
+			{Role: "system", Content: api.Content{Text: "You are terse."}},
+			{Role: "user", Content: api.Content{Text: "Hi"}},
+			{Role: "system", Content: api.Content{Parts: []api.ContentPart{{Type: "text", Text: "Answer in French."}}}},
+		},
+	})
+	require.NoError(t, err)
+
+	require.NotNil(t, gr.SystemInstruction)
+	assert.Equal(t, []GeminiPart{{Text: "You are terse."}, {Text: "Answer in French."}}, gr.SystemInstruction.Parts)
+	require.Len(t, gr.Contents, 1, "system messages are not sent as user turns")
+	assert.Equal(t, "Hi", gr.Contents[0].Parts[0].Text)
+}
+
+func TestShape_JSONMode(t *testing.T) {
+	gr, err := Shape(&api.ChatRequest{
+		Model:          "gemini-2.5-flash",
+		Messages:       []api.ChatMessage{{Role: "user", Content: api.Content{Text: "List three colors"}}},
+		ResponseFormat: &api.ResponseFormat{Type: "json_object"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", gr.GenerationConfig.ResponseMimeType)
+	assert.Nil(t, gr.GenerationConfig.ResponseSchema)
+
+	gr, err = Shape(&api.ChatRequest{
+		Model:    "gemini-2.5-flash",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "List three colors"}}},
+		ResponseFormat: &api.ResponseFormat{Type: "json_schema", JSONSchema: &api.JSONSchemaFormat{
+			Name: "colors",
+			Schema: map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties": map[string]interface{}{
+					"colors": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+				},
+			},
+		}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", gr.GenerationConfig.ResponseMimeType)
+	assert.Equal(t, map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"colors": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		},
+	}, gr.GenerationConfig.ResponseSchema)
+
+	gr, err = Shape(&api.ChatRequest{
+		Model:          "gemini-2.5-flash",
+		Messages:       []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+		ResponseFormat: &api.ResponseFormat{Type: "text"},
+	})
+	require.NoError(t, err)
+	assert.Nil(t, gr.GenerationConfig)
+}
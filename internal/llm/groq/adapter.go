@@ -0,0 +1,79 @@
+package groq
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/llm"
+	"github.com/nulzo/model-router-api/internal/llm/openai"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+func init() {
+	llm.Register("groq", NewAdapter)
+}
+
+// groqUsage is Groq's usage block, token counts plus the seconds spent
+// queued, reading the prompt and generating.
+type groqUsage struct {
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	QueueTime        float64 `json:"queue_time"`
+	PromptTime       float64 `json:"prompt_time"`
+	CompletionTime   float64 `json:"completion_time"`
+	TotalTime        float64 `json:"total_time"`
+}
+
+// groqMetadata holds the fields Groq adds to the OpenAI response shape. Unary
+// responses carry the timings in usage, streams in x_groq on the last chunk.
+type groqMetadata struct {
+	Usage *groqUsage `json:"usage"`
+	XGroq *struct {
+		Usage *groqUsage `json:"usage"`
+	} `json:"x_groq"`
+}
+
+// NewAdapter returns an adapter for Groq, whose chat API is OpenAI compatible.
+// Groq's timing metadata is recorded as the upstream timing of the usage.
+func NewAdapter(config config.ProviderConfig) (llm.Provider, error) {
+	return openai.NewCompatibleAdapter(config, openai.Options{
+		Type:       "groq",
+		OnResponse: applyTiming,
+	})
+}
+
+// applyTiming copies Groq's timings onto the response usage, filling in the
+// token counts when they only came with x_groq.
+func applyTiming(raw []byte, resp *api.ChatResponse) {
+	var meta groqMetadata
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return
+	}
+	usage := meta.Usage
+	if meta.XGroq != nil && meta.XGroq.Usage != nil {
+		usage = meta.XGroq.Usage
+	}
+	if usage == nil || usage.TotalTime == 0 {
+		return
+	}
+
+	if resp.Usage == nil {
+		resp.Usage = &api.ResponseUsage{
+			PromptTokens:     usage.PromptTokens,
+			CompletionTokens: usage.CompletionTokens,
+			TotalTokens:      usage.TotalTokens,
+		}
+	}
+	resp.Usage.Timing = &api.UpstreamTiming{
+		Queue:      seconds(usage.QueueTime),
+		Prompt:     seconds(usage.PromptTime),
+		Completion: seconds(usage.CompletionTime),
+		Total:      seconds(usage.TotalTime),
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
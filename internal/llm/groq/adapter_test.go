@@ -0,0 +1,91 @@
+package groq_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/llm/groq"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroqChat_Timing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/chat/completions", r.URL.Path)
+		assert.Equal(t, "Bearer gsk-key", r.Header.Get("Authorization"))
+
+		_, _ = w.Write([]byte(`{
+			"id": "chatcmpl-1",
+			"object": "chat.completion",
+			"model": "llama-3.3-70b-versatile",
+			"choices": [{
+				"index": 0,
+				"message": {"role": "assistant", "content": "Hello"},
+				"finish_reason": "stop"
+			}],
+			"usage": {
+				"queue_time": 0.025, "prompt_tokens": 12, "prompt_time": 0.004,
+				"completion_tokens": 40, "completion_time": 0.16, "total_tokens": 52, "total_time": 0.164
+			}
+		}`))
+	}))
+	defer server.Close()
+
+	adapter, err := groq.NewAdapter(config.ProviderConfig{ID: "groq", Type: "groq", APIKey: "gsk-key", BaseURL: server.URL})
+	require.NoError(t, err)
+	assert.Equal(t, "groq", adapter.Type())
+
+	resp, err := adapter.Chat(context.Background(), &api.ChatRequest{
+		Model:    "llama-3.3-70b-versatile",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+
+	require.NotNil(t, resp.Usage)
+	assert.Equal(t, 52, resp.Usage.TotalTokens)
+	assert.Equal(t, &api.UpstreamTiming{
+		Queue:      25 * time.Millisecond,
+		Prompt:     4 * time.Millisecond,
+		Completion: 160 * time.Millisecond,
+		Total:      164 * time.Millisecond,
+	}, resp.Usage.Timing)
+}
+
+func TestGroqStream_Timing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(`data: {"id":"chatcmpl-1","choices":[{"index":0,"delta":{"content":"Hello"}}]}` + "\n\n"))
+		_, _ = w.Write([]byte(`data: {"id":"chatcmpl-1","choices":[{"index":0,"delta":{},"finish_reason":"stop"}],` +
+			`"x_groq":{"id":"req_1","usage":{"queue_time":0.01,"prompt_tokens":12,"prompt_time":0.002,"completion_tokens":40,"completion_time":0.2,"total_tokens":52,"total_time":0.202}}}` + "\n\n"))
+		_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	adapter, err := groq.NewAdapter(config.ProviderConfig{ID: "groq", Type: "groq", BaseURL: server.URL})
+	require.NoError(t, err)
+
+	ch, err := adapter.Stream(context.Background(), &api.ChatRequest{
+		Model:    "llama-3.3-70b-versatile",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+
+	var usage *api.ResponseUsage
+	for res := range ch {
+		require.NoError(t, res.Err)
+		if res.Response.Usage != nil {
+			usage = res.Response.Usage
+		}
+	}
+	require.NotNil(t, usage, "usage is taken from x_groq")
+	assert.Equal(t, 12, usage.PromptTokens)
+	assert.Equal(t, 40, usage.CompletionTokens)
+	require.NotNil(t, usage.Timing)
+	assert.Equal(t, 200*time.Millisecond, usage.Timing.Completion)
+	assert.Equal(t, 10*time.Millisecond, usage.Timing.Queue)
+}
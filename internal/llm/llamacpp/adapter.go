@@ -0,0 +1,251 @@
+package llamacpp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/httpclient"
+	"github.com/nulzo/model-router-api/internal/llm"
+	"github.com/nulzo/model-router-api/internal/llm/openai"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+// ProviderType is the type of llama.cpp llama-server providers.
+const ProviderType = "llamacpp"
+
+// defaultSaturationBackoff is how long the server counts as saturated after
+// it reported every slot busy, unless config.saturation_backoff says otherwise.
+const defaultSaturationBackoff = 5 * time.Second
+
+func init() {
+	llm.Register(ProviderType, NewAdapter)
+}
+
+// Adapter talks to a llama.cpp llama-server. Chat is served by the OpenAI
+// adapter, the server's slots are tracked so the router can skip it while
+// every slot is busy: requests in flight through the gateway are counted
+// against the slot count from /props, and the server is held saturated for
+// a backoff whenever /health or a request reports that no slot is free.
+type Adapter struct {
+	llm.Provider
+	config  config.ProviderConfig
+	client  *http.Client
+	backoff time.Duration
+
+	slots     atomic.Int64 // total slots, 0 until /props was read
+	inflight  atomic.Int64
+	saturated atomic.Int64 // unix nanos the current saturation lasts until
+}
+
+func NewAdapter(config config.ProviderConfig) (llm.Provider, error) {
+	backoff := defaultSaturationBackoff
+	if raw := config.Config["saturation_backoff"]; raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid saturation_backoff for provider %s: %w", config.ID, err)
+		}
+		backoff = d
+	}
+
+	base, err := openai.NewCompatibleAdapter(config, openai.Options{Type: ProviderType})
+	if err != nil {
+		return nil, err
+	}
+	if config.BaseURL == "" {
+		config.BaseURL = llm.DefaultBaseURL(ProviderType)
+	}
+
+	return &Adapter{
+		Provider: base,
+		config:   config,
+		client:   httpclient.NewClient(30 * time.Second),
+		backoff:  backoff,
+	}, nil
+}
+
+// Saturated reports whether every slot is taken, either by requests the
+// gateway has in flight or as last reported by the server.
+func (a *Adapter) Saturated() bool {
+	if slots := a.slots.Load(); slots > 0 && a.inflight.Load() >= slots {
+		return true
+	}
+	return time.Now().UnixNano() < a.saturated.Load()
+}
+
+func (a *Adapter) markSaturated() {
+	a.saturated.Store(time.Now().Add(a.backoff).UnixNano())
+}
+
+// observe marks the server saturated when it turned a request away with a
+// 503, which llama-server answers while it has no free slot or is loading.
+func (a *Adapter) observe(err error) {
+	var problem *api.Problem
+	if errors.As(err, &problem) && problem.Status == http.StatusServiceUnavailable {
+		a.markSaturated()
+	}
+}
+
+func (a *Adapter) Chat(ctx context.Context, req *api.ChatRequest) (*api.ChatResponse, error) {
+	a.inflight.Add(1)
+	defer a.inflight.Add(-1)
+
+	resp, err := a.Provider.Chat(ctx, req)
+	a.observe(err)
+	return resp, err
+}
+
+func (a *Adapter) Stream(ctx context.Context, req *api.ChatRequest) (<-chan api.StreamResult, error) {
+	a.inflight.Add(1)
+	upstream, err := a.Provider.Stream(ctx, req)
+	if err != nil {
+		a.inflight.Add(-1)
+		a.observe(err)
+		return nil, err
+	}
+
+	// the slot stays taken until the stream is drained
+	ch := make(chan api.StreamResult)
+	go func() {
+		defer close(ch)
+		defer a.inflight.Add(-1)
+
+		for res := range upstream {
+			a.observe(res.Err)
+			select {
+			case ch <- res:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// Health checks /health, asking it to fail when no slot is free. A server
+// without a free slot is healthy but saturated, one still loading its model
+// or otherwise failing is unhealthy. The slot count is refreshed from /props.
+func (a *Adapter) Health(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.rootURL("/health?fail_on_no_slot=1"), nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range a.headers() {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	switch {
+	case resp.StatusCode == http.StatusServiceUnavailable && strings.Contains(strings.ToLower(string(body)), "no slot"):
+		a.markSaturated()
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("health check failed with status: %d", resp.StatusCode)
+	}
+
+	if props, err := a.props(ctx); err == nil && props.TotalSlots > 0 {
+		a.slots.Store(int64(props.TotalSlots))
+	}
+	return nil
+}
+
+// serverProps is the part of /props describing the loaded model's context and
+// the number of parallel slots.
+type serverProps struct {
+	TotalSlots int `json:"total_slots"`
+	Settings   struct {
+		NCtx int `json:"n_ctx"`
+	} `json:"default_generation_settings"`
+}
+
+func (a *Adapter) props(ctx context.Context) (*serverProps, error) {
+	var props serverProps
+	if err := httpclient.SendRequest(ctx, a.client, http.MethodGet, a.rootURL("/props"), a.headers(), nil, &props); err != nil {
+		return nil, err
+	}
+	return &props, nil
+}
+
+// Models returns the configured models, or else the model the server has
+// loaded with the per slot context window from /props.
+func (a *Adapter) Models(ctx context.Context) ([]api.ModelDefinition, error) {
+	if len(a.config.StaticModels) > 0 {
+		return a.config.StaticModels, nil
+	}
+
+	var listed struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	url := strings.TrimRight(a.config.BaseURL, "/") + "/models"
+	if err := httpclient.SendRequest(ctx, a.client, http.MethodGet, url, a.headers(), nil, &listed); err != nil {
+		// an unreachable server serves its model once it is up
+		return nil, nil
+	}
+
+	contextLength := 0
+	if props, err := a.props(ctx); err == nil {
+		contextLength = props.Settings.NCtx
+		if props.TotalSlots > 0 {
+			a.slots.Store(int64(props.TotalSlots))
+		}
+	}
+
+	models := make([]api.ModelDefinition, 0, len(listed.Data))
+	for _, m := range listed.Data {
+		models = append(models, api.ModelDefinition{
+			ID:            fmt.Sprintf("%s/%s", a.config.ID, m.ID),
+			Name:          m.ID,
+			ProviderID:    a.config.ID,
+			UpstreamID:    m.ID,
+			Enabled:       true,
+			Source:        "auto",
+			LastUpdated:   time.Now(),
+			ContextLength: contextLength,
+			Pricing: api.ModelPricing{
+				Prompt:     "0",
+				Completion: "0",
+			},
+			Config: api.ModelConfig{
+				ContextWindow:    contextLength,
+				Modality:         []string{"text"},
+				StreamingSupport: true,
+			},
+			Architecture: api.ModelArchitecture{
+				InputModalities:  []string{"text"},
+				OutputModalities: []string{"text"},
+			},
+			TopProvider: api.ModelTopProvider{
+				ContextLength: contextLength,
+			},
+		})
+	}
+	return models, nil
+}
+
+func (a *Adapter) headers() map[string]string {
+	if a.config.APIKey == "" {
+		return nil
+	}
+	return map[string]string{"Authorization": "Bearer " + a.config.APIKey}
+}
+
+// rootURL resolves path against the server root, /health and /props live
+// outside the /v1 prefix.
+func (a *Adapter) rootURL(path string) string {
+	return strings.TrimSuffix(strings.TrimRight(a.config.BaseURL, "/"), "/v1") + path
+}
@@ -0,0 +1,145 @@
+package llamacpp_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/llm"
+	"github.com/nulzo/model-router-api/internal/llm/llamacpp"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newServer(t *testing.T, slotsFree *atomic.Bool, release chan struct{}) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			assert.Equal(t, "1", r.URL.Query().Get("fail_on_no_slot"))
+			if !slotsFree.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				_, _ = w.Write([]byte(`{"error":{"code":503,"message":"no slot available","type":"unavailable_error"}}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"status":"ok"}`))
+		case "/props":
+			_, _ = w.Write([]byte(`{"total_slots":1,"default_generation_settings":{"n_ctx":8192}}`))
+		case "/v1/models":
+			_, _ = w.Write([]byte(`{"object":"list","data":[{"id":"qwen2.5-7b-instruct-q4_k_m.gguf"}]}`))
+		case "/v1/chat/completions":
+			if release != nil {
+				<-release
+			}
+			_, _ = w.Write([]byte(`{"id":"c1","choices":[{"index":0,"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestLlamaCppHealth_NoFreeSlotSaturates(t *testing.T) {
+	var free atomic.Bool
+	server := newServer(t, &free, nil)
+
+	adapter, err := llamacpp.NewAdapter(config.ProviderConfig{ID: "llama", Type: "llamacpp", BaseURL: server.URL + "/v1"})
+	require.NoError(t, err)
+	load, ok := adapter.(llm.LoadReporter)
+	require.True(t, ok)
+
+	require.NoError(t, adapter.Health(context.Background()), "a busy server is still healthy")
+	assert.True(t, load.Saturated())
+
+	adapter, err = llamacpp.NewAdapter(config.ProviderConfig{ID: "llama", Type: "llamacpp", BaseURL: server.URL + "/v1", Config: map[string]string{"saturation_backoff": "0s"}})
+	require.NoError(t, err)
+	require.NoError(t, adapter.Health(context.Background()))
+	assert.False(t, adapter.(llm.LoadReporter).Saturated(), "saturation lasts for the configured backoff")
+}
+
+func TestLlamaCppChat_InflightRequestsFillSlots(t *testing.T) {
+	var free atomic.Bool
+	free.Store(true)
+	release := make(chan struct{})
+	server := newServer(t, &free, release)
+
+	adapter, err := llamacpp.NewAdapter(config.ProviderConfig{ID: "llama", Type: "llamacpp", BaseURL: server.URL + "/v1"})
+	require.NoError(t, err)
+	load := adapter.(llm.LoadReporter)
+
+	require.NoError(t, adapter.Health(context.Background()))
+	assert.False(t, load.Saturated())
+
+	done := make(chan error)
+	go func() {
+		_, err := adapter.Chat(context.Background(), &api.ChatRequest{
+			Model:    "qwen2.5-7b-instruct-q4_k_m.gguf",
+			Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+		})
+		done <- err
+	}()
+
+	assert.Eventually(t, load.Saturated, time.Second, 5*time.Millisecond, "the only slot is taken")
+	close(release)
+	require.NoError(t, <-done)
+	assert.False(t, load.Saturated())
+}
+
+func TestLlamaCppStream_AbandonedStreamFreesSlot(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			_, _ = w.Write([]byte(`{"status":"ok"}`))
+		case "/props":
+			_, _ = w.Write([]byte(`{"total_slots":1}`))
+		case "/v1/chat/completions":
+			w.Header().Set("Content-Type", "text/event-stream")
+			for range 3 {
+				_, _ = w.Write([]byte("data: {\"id\":\"c1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"}}]}\n\n"))
+			}
+			_, _ = w.Write([]byte("data: [DONE]\n\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	adapter, err := llamacpp.NewAdapter(config.ProviderConfig{ID: "llama", Type: "llamacpp", BaseURL: server.URL + "/v1"})
+	require.NoError(t, err)
+	load := adapter.(llm.LoadReporter)
+	require.NoError(t, adapter.Health(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err = adapter.Stream(ctx, &api.ChatRequest{
+		Model:    "qwen2.5-7b-instruct-q4_k_m.gguf",
+		Stream:   true,
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+	assert.True(t, load.Saturated())
+
+	// the client goes away without reading the stream
+	cancel()
+	assert.Eventually(t, func() bool { return !load.Saturated() }, time.Second, 5*time.Millisecond)
+}
+
+func TestLlamaCppModels_Discovered(t *testing.T) {
+	var free atomic.Bool
+	free.Store(true)
+	server := newServer(t, &free, nil)
+
+	adapter, err := llamacpp.NewAdapter(config.ProviderConfig{ID: "llama", Type: "llamacpp", BaseURL: server.URL + "/v1"})
+	require.NoError(t, err)
+
+	models, err := adapter.Models(context.Background())
+	require.NoError(t, err)
+	require.Len(t, models, 1)
+	assert.Equal(t, "llama/qwen2.5-7b-instruct-q4_k_m.gguf", models[0].ID)
+	assert.Equal(t, 8192, models[0].ContextLength)
+}
@@ -29,15 +29,7 @@ type Adapter struct {
 func NewAdapter(config config.ProviderConfig) (llm.Provider, error) {
 	fmt.Printf("DEBUG: Moonshot Adapter Init. ID=%s BaseURL='%s' APIKeyLen=%d\n", config.ID, config.BaseURL, len(config.APIKey))
 	if config.BaseURL == "" {
-		config.BaseURL = "https://api.moonshot.ai/v1"
-	}
-
-	// Use a custom transport to support high concurrency
-	transport := &http.Transport{
-		MaxIdleConns:        500,
-		MaxIdleConnsPerHost: 500,
-		MaxConnsPerHost:     500, // Limit total connections to prevent storm
-		IdleConnTimeout:     90 * time.Second,
+		config.BaseURL = llm.DefaultBaseURL("moonshot")
 	}
 
 	timeout := 10 * time.Minute
@@ -49,12 +41,14 @@ func NewAdapter(config config.ProviderConfig) (llm.Provider, error) {
 		}
 	}
 
+	compress, err := httpclient.GzipFromConfig(config.ID, config.Config)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Adapter{
 		config: config,
-		client: &http.Client{
-			Timeout:   timeout,
-			Transport: transport,
-		},
+		client: httpclient.NewClient(timeout, compress, httpclient.WithRetries(config.MaxAttempts)), // pooled transport, tuned via http_client config
 	}, nil
 }
 
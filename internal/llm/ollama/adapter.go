@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -30,10 +31,17 @@ func init() {
 	llm.Register(string(llm.Ollama), NewAdapter)
 }
 
+// Adapter talks to Ollama. Chat goes through its OpenAI compatible /v1 API by
+// default, with config.native set to "true" it uses the native /api/chat
+// instead, which takes images the way every vision model expects them and
+// honours config.keep_alive.
 type Adapter struct {
 	llm.Provider // embeds the OpenAI adapter for chat/stream capabilities
 	config       config.ProviderConfig
 	client       *http.Client
+
+	native     bool
+	chatClient *http.Client // native chat, with the generation timeout
 }
 
 func NewAdapter(config config.ProviderConfig) (llm.Provider, error) {
@@ -46,22 +54,52 @@ func NewAdapter(config config.ProviderConfig) (llm.Provider, error) {
 		return nil, err
 	}
 
+	native := false
+	if raw := config.Config["native"]; raw != "" {
+		if native, err = strconv.ParseBool(raw); err != nil {
+			return nil, fmt.Errorf("invalid native flag for provider %s: %w", config.ID, err)
+		}
+	}
+	chatTimeout := 10 * time.Minute
+
 	timeout := 30 * time.Second
 	if config.Timeout != "" {
 		if d, err := time.ParseDuration(config.Timeout); err == nil {
-			timeout = d
+			timeout, chatTimeout = d, d
 		} else {
 			fmt.Printf("Warning: Invalid timeout format for provider %s: %v. Using default %v.\n", config.ID, err, timeout)
 		}
 	}
 
 	return &Adapter{
-		Provider: oaAdapter,
-		config:   config,
-		client:   &http.Client{Timeout: timeout},
+		Provider:   oaAdapter,
+		config:     config,
+		client:     httpclient.NewClient(timeout),
+		native:     native,
+		chatClient: httpclient.NewClient(chatTimeout, httpclient.WithRetries(config.MaxAttempts)),
 	}, nil
 }
 
+func (a *Adapter) Chat(ctx context.Context, req *api.ChatRequest) (*api.ChatResponse, error) {
+	if a.native {
+		return a.nativeChat(ctx, req)
+	}
+	return a.Provider.Chat(ctx, req)
+}
+
+func (a *Adapter) Stream(ctx context.Context, req *api.ChatRequest) (<-chan api.StreamResult, error) {
+	if a.native {
+		return a.nativeStream(ctx, req)
+	}
+	return a.Provider.Stream(ctx, req)
+}
+
+// rootURL resolves path against the server root, the native API lives
+// outside the /v1 prefix.
+func (a *Adapter) rootURL(path string) string {
+	return strings.TrimSuffix(strings.TrimRight(a.config.BaseURL, "/"), "/v1") + path
+}
+
 func (a *Adapter) Models(ctx context.Context) ([]api.ModelDefinition, error) {
 	rootURL := a.config.BaseURL
 	rootURL = strings.TrimSuffix(strings.TrimRight(rootURL, "/"), "/v1")
@@ -214,6 +252,12 @@ func (a *Adapter) Type() string {
 	return string(llm.Ollama)
 }
 
+// Capabilities declares JSON mode, which both the OpenAI compatible and the
+// native API support.
+func (a *Adapter) Capabilities() llm.Capabilities {
+	return llm.Capabilities{JSONMode: true}
+}
+
 func (a *Adapter) Health(ctx context.Context) error {
 	rootURL := a.config.BaseURL
 	rootURL = strings.TrimSuffix(strings.TrimRight(rootURL, "/"), "/v1")
@@ -0,0 +1,136 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newNativeAdapter(t *testing.T, handler http.HandlerFunc) *Adapter {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	p, err := NewAdapter(config.ProviderConfig{
+		ID:      "ollama",
+		Type:    "ollama",
+		BaseURL: server.URL,
+		Config:  map[string]string{"native": "true", "keep_alive": "30m"},
+	})
+	require.NoError(t, err)
+	return p.(*Adapter)
+}
+
+func TestNativeChat_ImagesAndKeepAlive(t *testing.T) {
+	adapter := newNativeAdapter(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/chat", r.URL.Path)
+
+		var body nativeRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "llava:13b", body.Model)
+		assert.Equal(t, "30m", body.KeepAlive)
+		assert.False(t, body.Stream)
+		assert.Equal(t, 0.2, body.Options["temperature"])
+		assert.Equal(t, float64(128), body.Options["num_predict"])
+
+		require.Len(t, body.Messages, 1)
+		assert.Equal(t, "What is in this image?", body.Messages[0].Content)
+		assert.Equal(t, []string{"iVBORw0KGgo="}, body.Messages[0].Images, "images are raw base64")
+
+		_, _ = w.Write([]byte(`{
+			"model": "llava:13b",
+			"message": {"role": "assistant", "content": "A red pixel."},
+			"done": true, "done_reason": "stop",
+			"prompt_eval_count": 600, "eval_count": 5,
+			"total_duration": 2000000000, "load_duration": 500000000,
+			"prompt_eval_duration": 1000000000, "eval_duration": 400000000
+		}`))
+	})
+
+	resp, err := adapter.Chat(context.Background(), &api.ChatRequest{
+		Model:       "llava:13b",
+		Temperature: 0.2,
+		MaxTokens:   128,
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Parts: []api.ContentPart{
+			{Type: "text", Text: "What is in this image?"},
+			{Type: "image_url", ImageURL: &api.ImageURL{URL: "data:image/png;base64,iVBORw0KGgo="}},
+		}}}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "A red pixel.", resp.Choices[0].Message.Content.Text)
+	assert.Equal(t, string(api.FinishReasonStop), resp.Choices[0].FinishReason)
+	assert.Equal(t, 600, resp.Usage.PromptTokens)
+	assert.Equal(t, 5, resp.Usage.CompletionTokens)
+	require.NotNil(t, resp.Usage.Timing)
+	assert.Equal(t, time.Second, resp.Usage.Timing.Prompt)
+	assert.Equal(t, 500*time.Millisecond, resp.Usage.Timing.Queue)
+}
+
+func TestNativeStream_ToolCalls(t *testing.T) {
+	adapter := newNativeAdapter(t, func(w http.ResponseWriter, r *http.Request) {
+		var body nativeRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.True(t, body.Stream)
+		require.Len(t, body.Messages, 3)
+		assert.JSONEq(t, `{"city":"Paris"}`, string(body.Messages[1].ToolCalls[0].Function.Arguments))
+		assert.Equal(t, "get_weather", body.Messages[2].ToolName, "the tool name comes from the matching call")
+
+		_, _ = w.Write([]byte(`{"model":"qwen3","message":{"role":"assistant","content":"","thinking":"Need the weather."},"done":false}` + "\n"))
+		_, _ = w.Write([]byte(`{"model":"qwen3","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"get_weather","arguments":{"city":"Rome"}}}]},"done":false}` + "\n"))
+		_, _ = w.Write([]byte(`{"model":"qwen3","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":40,"eval_count":12}` + "\n"))
+	})
+
+	ch, err := adapter.Stream(context.Background(), &api.ChatRequest{
+		Model: "qwen3",
+		Messages: []api.ChatMessage{
+			{Role: "user", Content: api.Content{Text: "Weather in Paris, then Rome?"}},
+			{Role: "assistant", ToolCalls: []api.ToolCall{{ID: "call_1", Type: "function", Function: api.FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}}}},
+			{Role: "tool", ToolCallID: "call_1", Content: api.Content{Text: "18C"}},
+		},
+	})
+	require.NoError(t, err)
+
+	var (
+		reasoning string
+		calls     []api.ToolCall
+		last      *api.ChatResponse
+	)
+	for res := range ch {
+		require.NoError(t, res.Err)
+		reasoning += res.Response.Choices[0].Delta.Reasoning
+		calls = append(calls, res.Response.Choices[0].Delta.ToolCalls...)
+		last = res.Response
+	}
+
+	assert.Equal(t, "Need the weather.", reasoning)
+	require.Len(t, calls, 1)
+	assert.Equal(t, "get_weather", calls[0].Function.Name)
+	assert.JSONEq(t, `{"city":"Rome"}`, calls[0].Function.Arguments)
+	assert.Equal(t, string(api.FinishReasonToolCalls), last.Choices[0].FinishReason)
+	require.NotNil(t, last.Usage)
+	assert.Equal(t, 52, last.Usage.TotalTokens)
+}
+
+func TestNativeChat_Error(t *testing.T) {
+	adapter := newNativeAdapter(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":"model \"llava\" not found, try pulling it first"}`))
+	})
+
+	_, err := adapter.Chat(context.Background(), &api.ChatRequest{
+		Model:    "llava",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	var problem *api.Problem
+	require.ErrorAs(t, err, &problem)
+	assert.Equal(t, http.StatusNotFound, problem.Status)
+	assert.Contains(t, problem.Detail, "try pulling it first")
+}
@@ -0,0 +1,308 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/httpclient"
+	"github.com/nulzo/model-router-api/internal/llm/processing"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+// nativeMessage is a message of Ollama's /api/chat. Images are raw base64,
+// without a data URI prefix.
+type nativeMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Thinking  string           `json:"thinking,omitempty"`
+	Images    []string         `json:"images,omitempty"`
+	ToolCalls []nativeToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+type nativeToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"` // a JSON object, not a string
+	} `json:"function"`
+}
+
+type nativeRequest struct {
+	Model     string          `json:"model"`
+	Messages  []nativeMessage `json:"messages"`
+	Tools     []api.Tool      `json:"tools,omitempty"`
+	Format    interface{}     `json:"format,omitempty"` // "json" or a JSON schema
+	Options   map[string]any  `json:"options,omitempty"`
+	Stream    bool            `json:"stream"`
+	KeepAlive string          `json:"keep_alive,omitempty"`
+}
+
+// nativeResponse is a reply, or one line of a streamed reply, the last of
+// which has done set and carries the counts and timings.
+type nativeResponse struct {
+	Model              string        `json:"model"`
+	Message            nativeMessage `json:"message"`
+	Done               bool          `json:"done"`
+	DoneReason         string        `json:"done_reason"`
+	PromptEvalCount    int           `json:"prompt_eval_count"`
+	EvalCount          int           `json:"eval_count"`
+	TotalDuration      int64         `json:"total_duration"` // nanoseconds
+	LoadDuration       int64         `json:"load_duration"`
+	PromptEvalDuration int64         `json:"prompt_eval_duration"`
+	EvalDuration       int64         `json:"eval_duration"`
+	Error              string        `json:"error,omitempty"`
+}
+
+// toNativeRequest converts a chat request to /api/chat. Sampling parameters
+// go into options, the configured keep_alive controls how long the model
+// stays loaded after the request.
+func (a *Adapter) toNativeRequest(req *api.ChatRequest) nativeRequest {
+	nr := nativeRequest{
+		Model:     req.Model,
+		Tools:     req.Tools,
+		Stream:    req.Stream,
+		KeepAlive: a.config.Config["keep_alive"],
+	}
+
+	options := map[string]any{}
+	if req.Temperature != 0 {
+		options["temperature"] = req.Temperature
+	}
+	if req.TopP != 0 {
+		options["top_p"] = req.TopP
+	}
+	if req.TopK != 0 {
+		options["top_k"] = req.TopK
+	}
+	if req.MinP != 0 {
+		options["min_p"] = req.MinP
+	}
+	if req.Seed != 0 {
+		options["seed"] = req.Seed
+	}
+	if req.FrequencyPenalty != 0 {
+		options["frequency_penalty"] = req.FrequencyPenalty
+	}
+	if req.PresencePenalty != 0 {
+		options["presence_penalty"] = req.PresencePenalty
+	}
+	if req.RepetitionPenalty != 0 {
+		options["repeat_penalty"] = req.RepetitionPenalty
+	}
+	if req.MaxTokens > 0 {
+		options["num_predict"] = req.MaxTokens
+	}
+	if req.Stop != nil && len(req.Stop.Val) > 0 {
+		options["stop"] = req.Stop.Val
+	}
+	if len(options) > 0 {
+		nr.Options = options
+	}
+
+	if f := req.ResponseFormat; f != nil {
+		switch {
+		case f.Type == "json_schema" && f.JSONSchema != nil && f.JSONSchema.Schema != nil:
+			nr.Format = f.JSONSchema.Schema
+		case f.Type == "json_object" || f.Type == "json_schema":
+			nr.Format = "json"
+		}
+	}
+
+	callNames := make(map[string]string)
+	for _, m := range req.Messages {
+		msg := nativeMessage{Role: m.Role, Content: m.Content.Text}
+
+		if len(m.Content.Parts) > 0 {
+			var text []string
+			for _, part := range m.Content.Parts {
+				switch {
+				case part.Type == "text":
+					text = append(text, part.Text)
+				case part.Type == "image_url" && part.ImageURL != nil:
+					if img, err := processing.ProcessImageURL(part.ImageURL.URL); err == nil {
+						msg.Images = append(msg.Images, img.Data)
+					}
+				}
+			}
+			msg.Content = strings.Join(text, "\n")
+		}
+
+		for _, tc := range m.ToolCalls {
+			callNames[tc.ID] = tc.Function.Name
+			var call nativeToolCall
+			call.Function.Name = tc.Function.Name
+			call.Function.Arguments = json.RawMessage(tc.Function.Arguments)
+			if len(call.Function.Arguments) == 0 {
+				call.Function.Arguments = json.RawMessage("{}")
+			}
+			msg.ToolCalls = append(msg.ToolCalls, call)
+		}
+		if m.Role == "tool" {
+			msg.ToolName = m.Name
+			if msg.ToolName == "" {
+				msg.ToolName = callNames[m.ToolCallID]
+			}
+		}
+
+		nr.Messages = append(nr.Messages, msg)
+	}
+
+	return nr
+}
+
+// toolCalls converts Ollama's tool calls, which carry no IDs. IDs are
+// generated so results can reference them, next is the index of the first.
+func (m nativeMessage) toolCalls(next int) []api.ToolCall {
+	var calls []api.ToolCall
+	for _, tc := range m.ToolCalls {
+		index := next + len(calls)
+		args := string(tc.Function.Arguments)
+		if args == "" || args == "null" {
+			args = "{}"
+		}
+		calls = append(calls, api.ToolCall{
+			Index:    &index,
+			ID:       fmt.Sprintf("call_%s_%d", tc.Function.Name, index),
+			Type:     "function",
+			Function: api.FunctionCall{Name: tc.Function.Name, Arguments: args},
+		})
+	}
+	return calls
+}
+
+// usage returns the counts and timings of a final reply.
+func (r *nativeResponse) usage() *api.ResponseUsage {
+	return &api.ResponseUsage{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		TotalTokens:      r.PromptEvalCount + r.EvalCount,
+		Timing: &api.UpstreamTiming{
+			Queue:      time.Duration(r.LoadDuration),
+			Prompt:     time.Duration(r.PromptEvalDuration),
+			Completion: time.Duration(r.EvalDuration),
+			Total:      time.Duration(r.TotalDuration),
+		},
+	}
+}
+
+func nativeFinishReason(doneReason string, toolCalls bool) string {
+	switch {
+	case toolCalls:
+		return string(api.FinishReasonToolCalls)
+	case doneReason == "length":
+		return string(api.FinishReasonLength)
+	}
+	return string(api.FinishReasonStop)
+}
+
+// nativeError converts an Ollama error body, {"error": "..."}, to a problem.
+func nativeError(err error) error {
+	var upstreamErr *httpclient.UpstreamError
+	if !errors.As(err, &upstreamErr) {
+		return err
+	}
+
+	var body struct {
+		Error string `json:"error"`
+	}
+	detail := string(upstreamErr.Body)
+	if json.Unmarshal(upstreamErr.Body, &body) == nil && body.Error != "" {
+		detail = body.Error
+	}
+	return api.NewError(upstreamErr.StatusCode, "Upstream Provider Error", detail, api.WithLog(err))
+}
+
+func (a *Adapter) nativeChat(ctx context.Context, req *api.ChatRequest) (*api.ChatResponse, error) {
+	req.Stream = false
+	nr := a.toNativeRequest(req)
+
+	var resp nativeResponse
+	if err := httpclient.SendRequest(ctx, a.chatClient, "POST", a.rootURL("/api/chat"), nil, nr, &resp); err != nil {
+		return nil, nativeError(err)
+	}
+
+	content, reasoning := processing.ExtractThinking(resp.Message.Content)
+	if resp.Message.Thinking != "" {
+		reasoning = resp.Message.Thinking
+	}
+	calls := resp.Message.toolCalls(0)
+
+	return &api.ChatResponse{
+		ID:      fmt.Sprintf("ollama-%d", time.Now().UnixNano()),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   resp.Model,
+		Choices: []api.Choice{{
+			Message: &api.ChatMessage{
+				Role:      string(api.Assistant),
+				Content:   api.Content{Text: content},
+				Reasoning: reasoning,
+				ToolCalls: calls,
+			},
+			FinishReason:       nativeFinishReason(resp.DoneReason, len(calls) > 0),
+			NativeFinishReason: resp.DoneReason,
+		}},
+		Usage: resp.usage(),
+	}, nil
+}
+
+func (a *Adapter) nativeStream(ctx context.Context, req *api.ChatRequest) (<-chan api.StreamResult, error) {
+	req.Stream = true
+	nr := a.toNativeRequest(req)
+	ch := make(chan api.StreamResult)
+
+	go func() {
+		defer close(ch)
+
+		parser := processing.NewStreamParser()
+		callCount := 0
+
+		// the reply is newline delimited JSON, one object per line
+		err := httpclient.StreamRequest(ctx, a.chatClient, "POST", a.rootURL("/api/chat"), nil, nr, func(line string) error {
+			if strings.TrimSpace(line) == "" {
+				return nil
+			}
+
+			var chunk nativeResponse
+			if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+				return nil
+			}
+			if chunk.Error != "" {
+				return api.NewError(http.StatusBadGateway, "Upstream Provider Error", chunk.Error)
+			}
+
+			content, reasoning := parser.Process(chunk.Message.Content)
+			if chunk.Message.Thinking != "" {
+				reasoning += chunk.Message.Thinking
+			}
+			calls := chunk.Message.toolCalls(callCount)
+			callCount += len(calls)
+
+			choice := api.Choice{Delta: &api.ChatMessage{
+				Content:   api.Content{Text: content},
+				Reasoning: reasoning,
+				ToolCalls: calls,
+			}}
+			resp := &api.ChatResponse{Model: chunk.Model, Choices: []api.Choice{choice}}
+			if chunk.Done {
+				resp.Choices[0].FinishReason = nativeFinishReason(chunk.DoneReason, callCount > 0)
+				resp.Choices[0].NativeFinishReason = chunk.DoneReason
+				resp.Usage = chunk.usage()
+			}
+
+			ch <- api.StreamResult{Response: resp}
+			return nil
+		})
+
+		if err != nil {
+			ch <- api.StreamResult{Err: nativeError(err)}
+		}
+	}()
+
+	return ch, nil
+}
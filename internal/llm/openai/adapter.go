@@ -24,20 +24,43 @@ func init() {
 type Adapter struct {
 	config config.ProviderConfig
 	client *http.Client
+	opts   Options
+
+	// bodyMerge is deep merged into every chat request body, configured as a
+	// JSON object in the provider's config.body_merge.
+	bodyMerge map[string]any
+}
+
+// ResponseHook reads provider specific fields off a chat response or stream
+// chunk. It gets the raw JSON and the decoded response, after reasoning
+// extraction, and may modify the response.
+type ResponseHook func(raw []byte, resp *api.ChatResponse)
+
+// Options adapt the adapter to an OpenAI compatible provider.
+type Options struct {
+	// Type is reported by Type and picks the default base URL, "openai"
+	// when empty.
+	Type string
+	// OnResponse, when set, is called for every response and stream chunk.
+	OnResponse ResponseHook
+	// Body is deep merged into every chat request body, underneath the
+	// provider's config.body_merge.
+	Body map[string]any
 }
 
 func NewAdapter(config config.ProviderConfig) (llm.Provider, error) {
+	return NewCompatibleAdapter(config, Options{})
+}
+
+// NewCompatibleAdapter returns an adapter for a provider speaking the OpenAI
+// chat API, customised by opts.
+func NewCompatibleAdapter(config config.ProviderConfig, opts Options) (*Adapter, error) {
+	if opts.Type == "" {
+		opts.Type = "openai"
+	}
 	fmt.Printf("DEBUG: OpenAI Adapter Init. ID=%s BaseURL='%s' APIKeyLen=%d\n", config.ID, config.BaseURL, len(config.APIKey))
 	if config.BaseURL == "" {
-		config.BaseURL = "https://api.openai.com/v1"
-	}
-
-	// Use a custom transport to support high concurrency
-	transport := &http.Transport{
-		MaxIdleConns:        500,
-		MaxIdleConnsPerHost: 500,
-		MaxConnsPerHost:     500, // Limit total connections to prevent storm
-		IdleConnTimeout:     90 * time.Second,
+		config.BaseURL = llm.DefaultBaseURL(opts.Type)
 	}
 
 	timeout := 10 * time.Minute
@@ -49,12 +72,33 @@ func NewAdapter(config config.ProviderConfig) (llm.Provider, error) {
 		}
 	}
 
+	var bodyMerge map[string]any
+	if raw := config.Config["body_merge"]; raw != "" {
+		if err := json.Unmarshal([]byte(raw), &bodyMerge); err != nil {
+			return nil, fmt.Errorf("invalid body_merge for provider %s, expected a JSON object: %w", config.ID, err)
+		}
+	}
+	if opts.Body != nil {
+		merged, err := httpclient.MergeBody(opts.Body, bodyMerge)
+		if err != nil {
+			return nil, err
+		}
+		bodyMerge = nil
+		if err := json.Unmarshal(merged, &bodyMerge); err != nil {
+			return nil, err
+		}
+	}
+
+	compress, err := httpclient.GzipFromConfig(config.ID, config.Config)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Adapter{
-		config: config,
-		client: &http.Client{
-			Timeout:   timeout,
-			Transport: transport,
-		},
+		config:    config,
+		client:    httpclient.NewClient(timeout, compress, httpclient.WithRetries(config.MaxAttempts)), // pooled transport, tuned via http_client config
+		opts:      opts,
+		bodyMerge: bodyMerge,
 	}, nil
 }
 
@@ -63,7 +107,12 @@ func (a *Adapter) Name() string {
 }
 
 func (a *Adapter) Type() string {
-	return "openai"
+	return a.opts.Type
+}
+
+// Capabilities declares JSON mode, response_format is forwarded as is.
+func (a *Adapter) Capabilities() llm.Capabilities {
+	return llm.Capabilities{JSONMode: true}
 }
 
 // upstreamErrorResponse mirrors the standard OpenAI error shape
@@ -107,6 +156,17 @@ func (a *Adapter) handleUpstreamError(err error) error {
 	)
 }
 
+// toModalities returns the modalities to send upstream. Text only output is
+// the default and is left out, text only models reject the field.
+func toModalities(modalities []string) []string {
+	for _, m := range modalities {
+		if m != "text" {
+			return modalities
+		}
+	}
+	return nil
+}
+
 func (a *Adapter) Chat(ctx context.Context, req *api.ChatRequest) (*api.ChatResponse, error) {
 	var resp api.ChatResponse
 	headers := map[string]string{
@@ -122,10 +182,20 @@ func (a *Adapter) Chat(ctx context.Context, req *api.ChatRequest) (*api.ChatResp
 
 	// ensure stream is false for this method
 	req.Stream = false
+	req.Modalities = toModalities(req.Modalities)
 
-	if err := httpclient.SendRequest(ctx, a.client, "POST", url, headers, req, &resp); err != nil {
+	body, err := httpclient.MergeBody(req, a.bodyMerge)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw json.RawMessage
+	if err := httpclient.SendRequest(ctx, a.client, "POST", url, headers, body, &raw); err != nil {
 		return nil, a.handleUpstreamError(err)
 	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, &httpclient.DecodeError{Body: raw, URL: url, Err: err}
+	}
 
 	// Post-process to extract thinking content
 	for i := range resp.Choices {
@@ -136,6 +206,9 @@ func (a *Adapter) Chat(ctx context.Context, req *api.ChatRequest) (*api.ChatResp
 			choice.Message.Reasoning = reasoning
 		}
 	}
+	if a.opts.OnResponse != nil {
+		a.opts.OnResponse(raw, &resp)
+	}
 
 	return &resp, nil
 }
@@ -146,6 +219,7 @@ func (a *Adapter) Stream(ctx context.Context, req *api.ChatRequest) (<-chan api.
 	// ensure stream is true
 	req.Stream = true
 	req.StreamOptions = &api.StreamOptions{IncludeUsage: true}
+	req.Modalities = toModalities(req.Modalities)
 	url := fmt.Sprintf("%s/chat/completions", strings.TrimRight(a.config.BaseURL, "/"))
 
 	headers := map[string]string{
@@ -155,13 +229,18 @@ func (a *Adapter) Stream(ctx context.Context, req *api.ChatRequest) (<-chan api.
 		headers["OpenAI-Organization"] = org
 	}
 
+	body, err := httpclient.MergeBody(req, a.bodyMerge)
+	if err != nil {
+		return nil, err
+	}
+
 	go func() {
 		defer close(ch)
 
 		// Map of parsers for each choice index
 		parsers := make(map[int]*processing.StreamParser)
 
-		err := httpclient.StreamRequest(ctx, a.client, "POST", url, headers, req, func(line string) error {
+		err := httpclient.StreamRequest(ctx, a.client, "POST", url, headers, body, func(line string) error {
 			// SSE format: data: {...}
 			if !strings.HasPrefix(line, "data: ") {
 				return nil
@@ -195,6 +274,9 @@ func (a *Adapter) Stream(ctx context.Context, req *api.ChatRequest) (<-chan api.
 					choice.Delta.Reasoning = r
 				}
 			}
+			if a.opts.OnResponse != nil {
+				a.opts.OnResponse([]byte(data), &chatResp)
+			}
 
 			ch <- api.StreamResult{Response: &chatResp}
 			return nil
@@ -262,6 +344,7 @@ func (a *Adapter) Models(ctx context.Context) ([]api.ModelDefinition, error) {
 				ProviderID:  a.config.ID,
 				UpstreamID:  upstreamModel.ID,
 				Enabled:     true,
+				Source:      "auto",
 				ContextLength: 8192, // default fallback
 				Pricing: api.ModelPricing{
 					Prompt:     "0",
@@ -2,11 +2,13 @@ package openai_test
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/llm"
 	"github.com/nulzo/model-router-api/internal/llm/openai"
 	"github.com/nulzo/model-router-api/pkg/api"
 	"github.com/stretchr/testify/assert"
@@ -69,3 +71,194 @@ func TestOpenAIChat(t *testing.T) {
 	assert.Equal(t, "Hello there!", resp.Choices[0].Message.Content.Text)
 	assert.Equal(t, "openai-test", adapter.Name())
 }
+
+func TestOpenAIStream_ToolCallDeltasKeepIndex(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(`data: {"id":"c1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}` + "\n\n"))
+		_, _ = w.Write([]byte(`data: {"id":"c1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":\"Paris\"}"}}]}}]}` + "\n\n"))
+		_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	adapter, err := openai.NewAdapter(config.ProviderConfig{ID: "openai-test", Type: "openai", BaseURL: server.URL})
+	assert.NoError(t, err)
+
+	ch, err := adapter.Stream(context.Background(), &api.ChatRequest{
+		Model:    "gpt-4o",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	assert.NoError(t, err)
+
+	var calls []api.ToolCall
+	for res := range ch {
+		assert.NoError(t, res.Err)
+		calls = append(calls, res.Response.Choices[0].Delta.ToolCalls...)
+	}
+
+	if assert.Len(t, calls, 2) {
+		assert.Equal(t, 0, *calls[0].Index)
+		assert.Equal(t, "call_1", calls[0].ID)
+		assert.Equal(t, 0, *calls[1].Index)
+		assert.Equal(t, `{"city":"Paris"}`, calls[1].Function.Arguments)
+	}
+}
+
+func TestOpenAIStreamRaw_RunsResponseHook(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(`data: {"id":"c1","choices":[{"index":0,"delta":{"content":"Hi"}}]}` + "\n\n"))
+		_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	adapter, err := openai.NewCompatibleAdapter(config.ProviderConfig{ID: "hooked", Type: "deepseek", BaseURL: server.URL}, openai.Options{
+		Type: "deepseek",
+		OnResponse: func(raw []byte, resp *api.ChatResponse) {
+			resp.Choices[0].Delta.Reasoning = "hooked"
+		},
+	})
+	assert.NoError(t, err)
+
+	ch, err := adapter.StreamRaw(context.Background(), &api.ChatRequest{
+		Model:    "deepseek-reasoner",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	assert.NoError(t, err)
+
+	var results []api.StreamResult
+	for res := range ch {
+		assert.NoError(t, res.Err)
+		results = append(results, res)
+	}
+
+	if assert.Len(t, results, 1) {
+		assert.Nil(t, results[0].Raw)
+		assert.Equal(t, "hooked", results[0].Response.Choices[0].Delta.Reasoning)
+	}
+}
+
+func TestOpenAIChat_Modalities(t *testing.T) {
+	var sent []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		sent = append(sent, body)
+		_, _ = w.Write([]byte(`{"id":"c1","choices":[{"index":0,"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer server.Close()
+
+	adapter, err := openai.NewAdapter(config.ProviderConfig{ID: "openai-test", Type: "openai", BaseURL: server.URL})
+	assert.NoError(t, err)
+
+	for _, modalities := range [][]string{{"text"}, {"text", "audio"}} {
+		_, err := adapter.Chat(context.Background(), &api.ChatRequest{
+			Model:      "gpt-4o-audio-preview",
+			Messages:   []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+			Modalities: modalities,
+		})
+		assert.NoError(t, err)
+	}
+
+	if assert.Len(t, sent, 2) {
+		// text only is the default and is not sent to text only models
+		assert.NotContains(t, sent[0], "modalities")
+		assert.Equal(t, []interface{}{"text", "audio"}, sent[1]["modalities"])
+	}
+}
+
+func TestOpenAIChat_BodyMerge(t *testing.T) {
+	var sent map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&sent)
+		_, _ = w.Write([]byte(`{"id":"c1","choices":[{"index":0,"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer server.Close()
+
+	adapter, err := openai.NewAdapter(config.ProviderConfig{
+		ID:      "quirky",
+		Type:    "openai",
+		BaseURL: server.URL,
+		Config: map[string]string{
+			"body_merge": `{"provider_specific":{"region":"eu","tier":"fast"},"stream_options":{"chunk_size":8},"temperature":0}`,
+		},
+	})
+	assert.NoError(t, err)
+
+	_, err = adapter.Chat(context.Background(), &api.ChatRequest{
+		Model:       "local-model",
+		Messages:    []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+		Temperature: 0.7,
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{"region": "eu", "tier": "fast"}, sent["provider_specific"])
+	assert.Equal(t, map[string]interface{}{"chunk_size": float64(8)}, sent["stream_options"])
+	// merged values win over the translated request
+	assert.Equal(t, float64(0), sent["temperature"])
+	assert.Equal(t, "local-model", sent["model"])
+	assert.NotEmpty(t, sent["messages"])
+}
+
+func TestOpenAIAdapter_InvalidBodyMerge(t *testing.T) {
+	_, err := openai.NewAdapter(config.ProviderConfig{
+		ID:     "quirky",
+		Type:   "openai",
+		Config: map[string]string{"body_merge": `["not", "an", "object"]`},
+	})
+	assert.ErrorContains(t, err, "body_merge")
+}
+
+func TestOpenAITranscribe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/audio/transcriptions", r.URL.Path)
+		assert.Equal(t, "Bearer test-key", r.Header.Get("Authorization"))
+		if !assert.NoError(t, r.ParseMultipartForm(1<<20)) {
+			return
+		}
+		assert.Equal(t, "whisper-1", r.FormValue("model"))
+		assert.Equal(t, "en", r.FormValue("language"))
+
+		file, header, err := r.FormFile("file")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+		assert.Equal(t, "clip.mp3", header.Filename)
+
+		if r.FormValue("response_format") == "srt" {
+			_, _ = w.Write([]byte("1\n00:00:00,000 --> 00:00:04,200\nHello\n\n2\n00:00:04,200 --> 00:01:02,500\nthere\n"))
+			return
+		}
+		assert.Equal(t, "verbose_json", r.FormValue("response_format"), "plain formats ask for the duration")
+		_, _ = w.Write([]byte(`{"task":"transcribe","language":"english","duration":8.47,"text":"Hello there"}`))
+	}))
+	defer server.Close()
+
+	adapter, err := openai.NewAdapter(config.ProviderConfig{
+		ID:      "openai-test",
+		Type:    "openai",
+		APIKey:  "test-key",
+		BaseURL: server.URL + "/v1",
+	})
+	assert.NoError(t, err)
+	transcriber, ok := adapter.(llm.Transcriber)
+	if !assert.True(t, ok) {
+		return
+	}
+
+	req := &api.TranscriptionRequest{Model: "whisper-1", File: []byte("audio"), Filename: "clip.mp3", Language: "en", ResponseFormat: "json"}
+	resp, err := transcriber.Transcribe(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello there", resp.Text)
+	assert.Equal(t, "english", resp.Language)
+	assert.InDelta(t, 8.47, resp.Duration, 0.001)
+
+	req.ResponseFormat = "srt"
+	resp, err = transcriber.Transcribe(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Contains(t, resp.Text, "there")
+	assert.InDelta(t, 62.5, resp.Duration, 0.001, "duration comes from the last cue")
+}
@@ -0,0 +1,92 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/nulzo/model-router-api/internal/httpclient"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+// chunkTap holds the fields of a stream chunk needed for request logging.
+type chunkTap struct {
+	ID      string `json:"id"`
+	Choices []struct {
+		Index        int    `json:"index"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *api.ResponseUsage `json:"usage"`
+}
+
+// StreamRaw streams the upstream chunks without re-encoding them. Only the
+// first chunk and those carrying a finish reason or usage are decoded, into
+// a chunkTap, so the gateway can still log the request. Providers with an
+// OnResponse hook are streamed decoded instead, forwarding their chunks as
+// they are would skip the hook.
+func (a *Adapter) StreamRaw(ctx context.Context, req *api.ChatRequest) (<-chan api.StreamResult, error) {
+	if a.opts.OnResponse != nil {
+		return a.Stream(ctx, req)
+	}
+
+	ch := make(chan api.StreamResult)
+
+	req.Stream = true
+	req.StreamOptions = &api.StreamOptions{IncludeUsage: true}
+	req.Modalities = toModalities(req.Modalities)
+	url := fmt.Sprintf("%s/chat/completions", strings.TrimRight(a.config.BaseURL, "/"))
+
+	headers := map[string]string{
+		"Authorization": "Bearer " + a.config.APIKey,
+	}
+	if org, ok := a.config.Config["organization"]; ok {
+		headers["OpenAI-Organization"] = org
+	}
+
+	body, err := httpclient.MergeBody(req, a.bodyMerge)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		defer close(ch)
+
+		first := true
+		err := httpclient.StreamRequest(ctx, a.client, "POST", url, headers, body, func(line string) error {
+			data, ok := strings.CutPrefix(line, "data: ")
+			if !ok || data == "[DONE]" {
+				return nil
+			}
+
+			raw := []byte(data)
+			resp := &api.ChatResponse{}
+			if first || bytes.Contains(raw, []byte(`"usage":{`)) || bytes.Contains(raw, []byte(`"finish_reason":"`)) {
+				var tap chunkTap
+				if err := json.Unmarshal(raw, &tap); err != nil {
+					return nil
+				}
+				resp.ID = tap.ID
+				resp.Usage = tap.Usage
+				for _, c := range tap.Choices {
+					resp.Choices = append(resp.Choices, api.Choice{Index: c.Index, FinishReason: c.FinishReason})
+				}
+				first = false
+			}
+
+			select {
+			case ch <- api.StreamResult{Response: resp, Raw: raw}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			return nil
+		})
+
+		if err != nil && ctx.Err() == nil {
+			ch <- api.StreamResult{Err: a.handleUpstreamError(err)}
+		}
+	}()
+
+	return ch, nil
+}
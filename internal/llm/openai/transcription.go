@@ -0,0 +1,128 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/nulzo/model-router-api/internal/httpclient"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+// Transcribe sends the audio to /audio/transcriptions. The plain formats are
+// asked for as verbose_json so the audio duration is known for billing,
+// subtitles are returned as the provider formats them.
+func (a *Adapter) Transcribe(ctx context.Context, req *api.TranscriptionRequest) (*api.TranscriptionResponse, error) {
+	format := req.ResponseFormat
+	subtitles := format == "srt" || format == "vtt"
+	if !subtitles {
+		format = "verbose_json"
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, err := form.CreateFormFile("file", req.Filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := file.Write(req.File); err != nil {
+		return nil, fmt.Errorf("failed to write form file: %w", err)
+	}
+	fields := map[string]string{
+		"model":           req.Model,
+		"response_format": format,
+		"language":        req.Language,
+		"prompt":          req.Prompt,
+	}
+	if req.Temperature > 0 {
+		fields["temperature"] = strconv.FormatFloat(req.Temperature, 'f', -1, 64)
+	}
+	for name, value := range fields {
+		if value == "" {
+			continue
+		}
+		if err := form.WriteField(name, value); err != nil {
+			return nil, fmt.Errorf("failed to write form field: %w", err)
+		}
+	}
+	if err := form.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close form: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/audio/transcriptions", strings.TrimRight(a.config.BaseURL, "/"))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", form.FormDataContentType())
+	httpReq.Header.Set("Authorization", "Bearer "+a.config.APIKey)
+	if org, ok := a.config.Config["organization"]; ok {
+		httpReq.Header.Set("OpenAI-Organization", org)
+	}
+
+	resp, err := a.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, a.handleUpstreamError(&httpclient.UpstreamError{StatusCode: resp.StatusCode, Body: respBody, URL: url})
+	}
+
+	if subtitles {
+		text := string(respBody)
+		return &api.TranscriptionResponse{Text: text, Duration: subtitleDuration(text)}, nil
+	}
+
+	var verbose struct {
+		Text     string  `json:"text"`
+		Language string  `json:"language"`
+		Duration float64 `json:"duration"`
+	}
+	if err := json.Unmarshal(respBody, &verbose); err != nil {
+		return nil, &httpclient.DecodeError{Body: respBody, URL: url, Err: err}
+	}
+	return &api.TranscriptionResponse{
+		Text:     verbose.Text,
+		Language: verbose.Language,
+		Duration: verbose.Duration,
+		Verbose:  respBody,
+	}, nil
+}
+
+// subtitleDuration returns the end of the last srt or vtt cue in seconds,
+// which is as close to the audio length as subtitles get.
+func subtitleDuration(subtitles string) float64 {
+	idx := strings.LastIndex(subtitles, "-->")
+	if idx < 0 {
+		return 0
+	}
+	end := strings.Fields(subtitles[idx+len("-->"):])
+	if len(end) == 0 {
+		return 0
+	}
+
+	// hh:mm:ss,mmm (srt) or [hh:]mm:ss.mmm (vtt)
+	var seconds float64
+	for _, part := range strings.Split(strings.ReplaceAll(end[0], ",", "."), ":") {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0
+		}
+		seconds = seconds*60 + v
+	}
+	return seconds
+}
@@ -0,0 +1,42 @@
+package openrouter
+
+import (
+	"context"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/llm"
+	"github.com/nulzo/model-router-api/internal/llm/openai"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+func init() {
+	llm.Register("openrouter", NewAdapter)
+}
+
+// Adapter talks to OpenRouter, whose chat API is OpenAI compatible. Every
+// request asks for usage accounting, so responses carry what OpenRouter
+// charged in usage.cost and, for BYOK requests, cost_details; the gateway
+// records it as the upstream cost of the request. Only chat is exposed, the
+// OpenAI audio endpoints do not exist on OpenRouter.
+type Adapter struct {
+	llm.Provider
+	config config.ProviderConfig
+}
+
+func NewAdapter(config config.ProviderConfig) (llm.Provider, error) {
+	base, err := openai.NewCompatibleAdapter(config, openai.Options{
+		Type: "openrouter",
+		Body: map[string]any{"usage": map[string]any{"include": true}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Adapter{Provider: base, config: config}, nil
+}
+
+// Models returns the configured models only. OpenRouter lists hundreds of
+// models, the rest are reached through gateway.fallthrough_provider rather
+// than registered one by one.
+func (a *Adapter) Models(ctx context.Context) ([]api.ModelDefinition, error) {
+	return a.config.StaticModels, nil
+}
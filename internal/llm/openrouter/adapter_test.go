@@ -0,0 +1,70 @@
+package openrouter_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/llm"
+	"github.com/nulzo/model-router-api/internal/llm/openrouter"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenRouterChat_UsageAccounting(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/chat/completions", r.URL.Path)
+		assert.Equal(t, "Bearer or-key", r.Header.Get("Authorization"))
+
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, map[string]any{"include": true}, body["usage"], "usage accounting is always requested")
+		assert.Equal(t, "anthropic/claude-3.5-haiku", body["model"])
+
+		_, _ = w.Write([]byte(`{
+			"id": "gen-1",
+			"model": "anthropic/claude-3.5-haiku",
+			"choices": [{"index": 0, "message": {"role": "assistant", "content": "Hi"}, "finish_reason": "stop"}],
+			"usage": {
+				"prompt_tokens": 10, "completion_tokens": 2, "total_tokens": 12,
+				"cost": 0.00005, "is_byok": true,
+				"cost_details": {"upstream_inference_cost": 0.0012}
+			}
+		}`))
+	}))
+	defer server.Close()
+
+	adapter, err := openrouter.NewAdapter(config.ProviderConfig{ID: "openrouter", Type: "openrouter", APIKey: "or-key", BaseURL: server.URL})
+	require.NoError(t, err)
+	assert.Equal(t, "openrouter", adapter.Type())
+
+	resp, err := adapter.Chat(context.Background(), &api.ChatRequest{
+		Model:    "anthropic/claude-3.5-haiku",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Hi"}}},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, resp.Usage.Cost)
+	assert.InDelta(t, 0.00005, *resp.Usage.Cost, 1e-9)
+	require.NotNil(t, resp.Usage.CostDetails)
+	require.NotNil(t, resp.Usage.CostDetails.UpstreamInferenceCost)
+	assert.InDelta(t, 0.0012, *resp.Usage.CostDetails.UpstreamInferenceCost, 1e-9)
+	require.NotNil(t, resp.Usage.IsBYOK)
+	assert.True(t, *resp.Usage.IsBYOK)
+
+	_, transcribes := adapter.(llm.Transcriber)
+	assert.False(t, transcribes)
+}
+
+func TestOpenRouterModels_StaticOnly(t *testing.T) {
+	static := []api.ModelDefinition{{ID: "openrouter/auto", UpstreamID: "openrouter/auto"}}
+	adapter, err := openrouter.NewAdapter(config.ProviderConfig{ID: "openrouter", Type: "openrouter", BaseURL: "http://127.0.0.1:1", StaticModels: static})
+	require.NoError(t, err)
+
+	models, err := adapter.Models(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, static, models)
+}
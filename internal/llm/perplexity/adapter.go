@@ -0,0 +1,125 @@
+package perplexity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/httpclient"
+	"github.com/nulzo/model-router-api/internal/llm"
+	"github.com/nulzo/model-router-api/internal/llm/openai"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+func init() {
+	llm.Register("perplexity", NewAdapter)
+}
+
+// Adapter talks to Perplexity, whose chat API is OpenAI compatible. Chat and
+// Stream are served by the OpenAI adapter, the top level `citations` field
+// Perplexity adds is decoded straight into api.ChatResponse.Citations and the
+// searches it ran are reported as server tool use. The search_domain_filter
+// and return_citations request options are passed through as they are.
+type Adapter struct {
+	llm.Provider
+	config config.ProviderConfig
+	client *http.Client
+}
+
+func NewAdapter(config config.ProviderConfig) (llm.Provider, error) {
+	if config.BaseURL == "" {
+		config.BaseURL = llm.DefaultBaseURL("perplexity")
+	}
+
+	base, err := openai.NewCompatibleAdapter(config, openai.Options{
+		Type:       "perplexity",
+		OnResponse: applySearch,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Adapter{
+		Provider: base,
+		config:   config,
+		client:   httpclient.NewClient(30 * time.Second),
+	}, nil
+}
+
+func (a *Adapter) Type() string {
+	return "perplexity"
+}
+
+// Models returns the configured models, Perplexity has no models endpoint.
+func (a *Adapter) Models(ctx context.Context) ([]api.ModelDefinition, error) {
+	return a.config.StaticModels, nil
+}
+
+// Health checks that the API is reachable. There is no free authenticated
+// endpoint to call, so anything short of a server error counts as healthy.
+func (a *Adapter) Health(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(a.config.BaseURL, "/"), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("health check failed with status: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// searchResponse is the part of a Perplexity response describing the search
+// behind the answer. Newer models list search_results instead of citations.
+type searchResponse struct {
+	SearchResults []struct {
+		URL string `json:"url"`
+	} `json:"search_results"`
+	Usage *struct {
+		NumSearchQueries int `json:"num_search_queries"`
+	} `json:"usage"`
+}
+
+// applySearch fills the citations from search_results when Perplexity left
+// out the citations field, and reports the searches in the usage block. A
+// response with usage but no search count ran a single search if it cites
+// anything.
+func applySearch(raw []byte, resp *api.ChatResponse) {
+	var decoded searchResponse
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return
+	}
+
+	if len(resp.Citations) == 0 {
+		for _, r := range decoded.SearchResults {
+			resp.Citations = append(resp.Citations, r.URL)
+		}
+	}
+
+	if resp.Usage == nil {
+		return
+	}
+	searches := 0
+	if decoded.Usage != nil {
+		searches = decoded.Usage.NumSearchQueries
+	}
+	if searches == 0 && len(resp.Citations) > 0 {
+		searches = 1
+	}
+	if searches > 0 {
+		resp.Usage.ServerToolUse = &api.ServerToolUse{WebSearchRequests: searches}
+	}
+}
@@ -0,0 +1,149 @@
+package perplexity_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/llm/perplexity"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPerplexityChat_Citations(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/chat/completions", r.URL.Path)
+		assert.Equal(t, "Bearer pplx-key", r.Header.Get("Authorization"))
+
+		_, _ = w.Write([]byte(`{
+			"id": "pplx-1",
+			"object": "chat.completion",
+			"model": "sonar",
+			"citations": ["https://example.com/a", "https://example.com/b"],
+			"choices": [{
+				"index": 0,
+				"message": {"role": "assistant", "content": "Paris is the capital of France [1]."},
+				"finish_reason": "stop"
+			}],
+			"usage": {"prompt_tokens": 5, "completion_tokens": 9, "total_tokens": 14}
+		}`))
+	}))
+	defer server.Close()
+
+	adapter, err := perplexity.NewAdapter(config.ProviderConfig{ID: "perplexity", Type: "perplexity", APIKey: "pplx-key", BaseURL: server.URL})
+	require.NoError(t, err)
+	assert.Equal(t, "perplexity", adapter.Type())
+	assert.Equal(t, "perplexity", adapter.Name())
+
+	resp, err := adapter.Chat(context.Background(), &api.ChatRequest{
+		Model:    "sonar",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Capital of France?"}}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"https://example.com/a", "https://example.com/b"}, resp.Citations)
+	assert.Equal(t, "Paris is the capital of France [1].", resp.Choices[0].Message.Content.Text)
+}
+
+func TestPerplexityStream_Citations(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(`data: {"id":"pplx-1","citations":["https://example.com/a"],"choices":[{"index":0,"delta":{"content":"Paris"}}]}` + "\n\n"))
+		_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	adapter, err := perplexity.NewAdapter(config.ProviderConfig{ID: "perplexity", Type: "perplexity", BaseURL: server.URL})
+	require.NoError(t, err)
+
+	ch, err := adapter.Stream(context.Background(), &api.ChatRequest{
+		Model:    "sonar",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Capital of France?"}}},
+	})
+	require.NoError(t, err)
+
+	var citations []string
+	for res := range ch {
+		require.NoError(t, res.Err)
+		citations = append(citations, res.Response.Citations...)
+	}
+	assert.Equal(t, []string{"https://example.com/a"}, citations)
+}
+
+func TestPerplexityModels_Static(t *testing.T) {
+	static := []api.ModelDefinition{{ID: "perplexity/sonar", UpstreamID: "sonar"}}
+	adapter, err := perplexity.NewAdapter(config.ProviderConfig{ID: "perplexity", Type: "perplexity", StaticModels: static})
+	require.NoError(t, err)
+
+	models, err := adapter.Models(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, static, models)
+}
+
+func TestPerplexityChat_SearchOptionsAndUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, []any{"wikipedia.org", "-reddit.com"}, body["search_domain_filter"])
+		assert.Equal(t, true, body["return_citations"])
+
+		_, _ = w.Write([]byte(`{
+			"id": "pplx-2",
+			"model": "sonar-pro",
+			"search_results": [{"title": "Paris", "url": "https://wikipedia.org/wiki/Paris"}],
+			"choices": [{"index": 0, "message": {"role": "assistant", "content": "Paris [1]."}, "finish_reason": "stop"}],
+			"usage": {"prompt_tokens": 5, "completion_tokens": 3, "total_tokens": 8, "num_search_queries": 2}
+		}`))
+	}))
+	defer server.Close()
+
+	adapter, err := perplexity.NewAdapter(config.ProviderConfig{ID: "perplexity", Type: "perplexity", BaseURL: server.URL})
+	require.NoError(t, err)
+
+	returnCitations := true
+	resp, err := adapter.Chat(context.Background(), &api.ChatRequest{
+		Model:              "sonar-pro",
+		Messages:           []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Capital of France?"}}},
+		SearchDomainFilter: []string{"wikipedia.org", "-reddit.com"},
+		ReturnCitations:    &returnCitations,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"https://wikipedia.org/wiki/Paris"}, resp.Citations, "search_results fill in for citations")
+	require.NotNil(t, resp.Usage.ServerToolUse)
+	assert.Equal(t, 2, resp.Usage.ServerToolUse.WebSearchRequests)
+}
+
+func TestPerplexityStream_SearchUsageOnFinalChunk(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(`data: {"id":"pplx-1","citations":["https://example.com/a"],"choices":[{"index":0,"delta":{"content":"Paris"}}]}` + "\n\n"))
+		_, _ = w.Write([]byte(`data: {"id":"pplx-1","citations":["https://example.com/a"],"choices":[{"index":0,"delta":{},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":1,"total_tokens":6}}` + "\n\n"))
+		_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	adapter, err := perplexity.NewAdapter(config.ProviderConfig{ID: "perplexity", Type: "perplexity", BaseURL: server.URL})
+	require.NoError(t, err)
+
+	ch, err := adapter.Stream(context.Background(), &api.ChatRequest{
+		Model:    "sonar",
+		Messages: []api.ChatMessage{{Role: "user", Content: api.Content{Text: "Capital of France?"}}},
+	})
+	require.NoError(t, err)
+
+	var usage *api.ResponseUsage
+	for res := range ch {
+		require.NoError(t, res.Err)
+		if res.Response.Usage != nil {
+			usage = res.Response.Usage
+		}
+	}
+	require.NotNil(t, usage)
+	require.NotNil(t, usage.ServerToolUse, "a cited answer ran one search")
+	assert.Equal(t, 1, usage.ServerToolUse.WebSearchRequests)
+}
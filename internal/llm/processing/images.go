@@ -0,0 +1,22 @@
+package processing
+
+import "github.com/nulzo/model-router-api/pkg/api"
+
+// ImagePart wraps an image URL, usually a data URI, in a content part.
+func ImagePart(url string) api.ContentPart {
+	return api.ContentPart{Type: "image_url", ImageURL: &api.ImageURL{URL: url}}
+}
+
+// ImageContent builds the content of a message carrying generated images:
+// the text, when there is any, followed by every image as its own part.
+func ImageContent(text string, images []api.ContentPart) api.Content {
+	if len(images) == 0 {
+		return api.Content{Text: text}
+	}
+
+	parts := make([]api.ContentPart, 0, len(images)+1)
+	if text != "" {
+		parts = append(parts, api.ContentPart{Type: "text", Text: text})
+	}
+	return api.Content{Parts: append(parts, images...)}
+}
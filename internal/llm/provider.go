@@ -9,11 +9,17 @@ import (
 type ProviderName string
 
 const (
-	Ollama    ProviderName = "ollama"
-	OpenAI    ProviderName = "openai"
-	Anthropic ProviderName = "anthropic"
-	Google    ProviderName = "google"
-	Moonshot  ProviderName = "moonshot"
+	Ollama     ProviderName = "ollama"
+	OpenAI     ProviderName = "openai"
+	Anthropic  ProviderName = "anthropic"
+	Google     ProviderName = "google"
+	Moonshot   ProviderName = "moonshot"
+	Perplexity ProviderName = "perplexity"
+	Groq       ProviderName = "groq"
+	DeepSeek   ProviderName = "deepseek"
+	ElevenLabs ProviderName = "elevenlabs"
+	OpenRouter ProviderName = "openrouter"
+	LlamaCpp   ProviderName = "llamacpp"
 )
 
 type Provider interface {
@@ -24,3 +30,17 @@ type Provider interface {
 	Models(ctx context.Context) ([]api.ModelDefinition, error)
 	Health(ctx context.Context) error
 }
+
+// RawStreamer is implemented by OpenAI compatible providers that can forward
+// upstream stream chunks without decoding and re-encoding them.
+type RawStreamer interface {
+	StreamRaw(ctx context.Context, req *api.ChatRequest) (<-chan api.StreamResult, error)
+}
+
+// LoadReporter is implemented by providers that know when they have no free
+// capacity, such as a llama.cpp server with every slot busy. The router skips
+// a saturated provider rather than queueing behind it, so Saturated is called
+// on every request and must not block.
+type LoadReporter interface {
+	Saturated() bool
+}
@@ -0,0 +1,47 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCipher_RoundTrip(t *testing.T) {
+	c, err := NewCipher("secret")
+	require.NoError(t, err)
+
+	enc, err := c.Encrypt("sk-test-123")
+	require.NoError(t, err)
+	assert.NotContains(t, enc, "sk-test-123")
+
+	dec, err := c.Decrypt(enc)
+	require.NoError(t, err)
+	assert.Equal(t, "sk-test-123", dec)
+
+	// a fresh nonce per call
+	again, err := c.Encrypt("sk-test-123")
+	require.NoError(t, err)
+	assert.NotEqual(t, enc, again)
+}
+
+func TestCipher_WrongKey(t *testing.T) {
+	a, err := NewCipher("secret")
+	require.NoError(t, err)
+	b, err := NewCipher("other")
+	require.NoError(t, err)
+
+	enc, err := a.Encrypt("sk-test-123")
+	require.NoError(t, err)
+
+	_, err = b.Decrypt(enc)
+	assert.Error(t, err)
+
+	_, err = a.Decrypt("not base64!")
+	assert.ErrorIs(t, err, ErrMalformedCiphertext)
+}
+
+func TestNewCipher_EmptySecret(t *testing.T) {
+	_, err := NewCipher("")
+	assert.Error(t, err)
+}
@@ -0,0 +1,63 @@
+package logger
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Redacted replaces the value of every sensitive header.
+const Redacted = "***"
+
+// defaultRedactedHeaders carry credentials and are always redacted.
+var defaultRedactedHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"X-Api-Key",
+	"X-Provider-Key",
+	"Api-Key",
+	"Cookie",
+	"Set-Cookie",
+}
+
+var (
+	redactMu        sync.RWMutex
+	redactedHeaders = canonicalSet(defaultRedactedHeaders)
+)
+
+// SetRedactedHeaders adds headers to the redaction list. The defaults cannot
+// be removed.
+func SetRedactedHeaders(headers []string) {
+	set := canonicalSet(defaultRedactedHeaders)
+	for _, h := range headers {
+		set[http.CanonicalHeaderKey(h)] = struct{}{}
+	}
+
+	redactMu.Lock()
+	defer redactMu.Unlock()
+	redactedHeaders = set
+}
+
+// RedactHeaders returns a copy of h with the values of sensitive headers
+// replaced by Redacted. Use it wherever headers are logged or echoed.
+func RedactHeaders(h http.Header) http.Header {
+	redactMu.RLock()
+	defer redactMu.RUnlock()
+
+	out := make(http.Header, len(h))
+	for k, v := range h {
+		if _, ok := redactedHeaders[http.CanonicalHeaderKey(k)]; ok {
+			out[k] = []string{Redacted}
+			continue
+		}
+		out[k] = append([]string(nil), v...)
+	}
+	return out
+}
+
+func canonicalSet(headers []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(headers))
+	for _, h := range headers {
+		set[http.CanonicalHeaderKey(h)] = struct{}{}
+	}
+	return set
+}
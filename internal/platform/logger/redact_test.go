@@ -0,0 +1,40 @@
+package logger
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactHeaders(t *testing.T) {
+	h := http.Header{}
+	h.Set("Authorization", "Bearer sk-secret")
+	h.Set("x-api-key", "sk-ant-secret")
+	h.Set("X-Provider-Key", "sk-provider")
+	h.Set("Content-Type", "application/json")
+
+	out := RedactHeaders(h)
+
+	assert.Equal(t, Redacted, out.Get("Authorization"))
+	assert.Equal(t, Redacted, out.Get("X-Api-Key"))
+	assert.Equal(t, Redacted, out.Get("X-Provider-Key"))
+	assert.Equal(t, "application/json", out.Get("Content-Type"))
+
+	// the original headers are left untouched
+	assert.Equal(t, "Bearer sk-secret", h.Get("Authorization"))
+}
+
+func TestSetRedactedHeaders(t *testing.T) {
+	t.Cleanup(func() { SetRedactedHeaders(nil) })
+	SetRedactedHeaders([]string{"x-internal-token"})
+
+	h := http.Header{}
+	h.Set("X-Internal-Token", "secret")
+	h.Set("Authorization", "Bearer sk-secret")
+
+	out := RedactHeaders(h)
+	assert.Equal(t, Redacted, out.Get("X-Internal-Token"))
+	// configured headers extend the defaults, they never replace them
+	assert.Equal(t, Redacted, out.Get("Authorization"))
+}
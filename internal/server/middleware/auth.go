@@ -13,6 +13,7 @@ import (
 )
 
 // Auth checks for a valid Bearer token in the Authorization header using the database.
+// Anthropic style clients may send the key in X-Api-Key instead.
 func Auth(repo store.Repository, staticKeys []string) gin.HandlerFunc {
 	staticMap := make(map[string]bool)
 	for _, k := range staticKeys {
@@ -21,6 +22,11 @@ func Auth(repo store.Repository, staticKeys []string) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
+			if key := c.GetHeader("X-Api-Key"); key != "" {
+				authHeader = "Bearer " + key
+			}
+		}
 
 		if authHeader == "" {
 
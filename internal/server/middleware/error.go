@@ -45,4 +45,30 @@ func ErrorHandler() gin.HandlerFunc {
 			c.Abort()
 		}
 	}
-}
\ No newline at end of file
+}
+// NoRoute answers unknown paths with a 404 problem instead of gin's plain text.
+func NoRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_ = c.Error(api.NewError(
+			http.StatusNotFound,
+			"Not Found",
+			"No route matches "+c.Request.URL.Path+".",
+			api.WithInstance(c.Request.URL.Path),
+		))
+		c.Abort()
+	}
+}
+
+// NoMethod answers a known path called with an unsupported method with a 405
+// problem. gin has already set the Allow header.
+func NoMethod() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_ = c.Error(api.NewError(
+			http.StatusMethodNotAllowed,
+			"Method Not Allowed",
+			c.Request.Method+" is not supported on "+c.Request.URL.Path+".",
+			api.WithInstance(c.Request.URL.Path),
+		))
+		c.Abort()
+	}
+}
@@ -2,19 +2,66 @@ package middleware
 
 import (
 	"context"
+	"net/url"
+	"strings"
+	"unicode"
 
 	"github.com/gin-gonic/gin"
 	"github.com/nulzo/model-router-api/internal/store"
 )
 
-// Identity middleware extracts X-App-Name from headers
+const (
+	maxAppTitleLength   = 128
+	maxAppRefererLength = 512
+)
+
+// Identity middleware extracts X-App-Name from headers, along with the
+// OpenRouter style X-Title and HTTP-Referer attribution headers.
 func Identity() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		appName := c.GetHeader("X-App-Name")
-		if appName != "" {
-			ctx := context.WithValue(c.Request.Context(), store.ContextKeyAppName, appName)
-			c.Request = c.Request.WithContext(ctx)
+		ctx := c.Request.Context()
+
+		if appName := c.GetHeader("X-App-Name"); appName != "" {
+			ctx = context.WithValue(ctx, store.ContextKeyAppName, appName)
+		}
+		if title := normalizeTitle(c.GetHeader("X-Title")); title != "" {
+			ctx = context.WithValue(ctx, store.ContextKeyAppTitle, title)
 		}
+		if referer := normalizeReferer(c.GetHeader("HTTP-Referer")); referer != "" {
+			ctx = context.WithValue(ctx, store.ContextKeyAppReferer, referer)
+		}
+
+		c.Request = c.Request.WithContext(ctx)
 		c.Next()
 	}
 }
+
+// normalizeTitle strips control characters, collapses whitespace and caps the length.
+func normalizeTitle(title string) string {
+	title = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, title)
+	title = strings.Join(strings.Fields(title), " ")
+
+	if runes := []rune(title); len(runes) > maxAppTitleLength {
+		title = strings.TrimSpace(string(runes[:maxAppTitleLength]))
+	}
+	return title
+}
+
+// normalizeReferer only accepts absolute http(s) URLs within the length cap.
+func normalizeReferer(referer string) string {
+	referer = strings.TrimSpace(referer)
+	if referer == "" || len(referer) > maxAppRefererLength {
+		return ""
+	}
+
+	u, err := url.Parse(referer)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	return u.String()
+}
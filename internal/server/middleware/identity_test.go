@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func identityContext(t *testing.T, headers map[string]string) context.Context {
+	gin.SetMode(gin.TestMode)
+
+	var ctx context.Context
+	r := gin.New()
+	r.Use(Identity())
+	r.GET("/", func(c *gin.Context) { ctx = c.Request.Context() })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	return ctx
+}
+
+func TestIdentity_AttributionHeaders(t *testing.T) {
+	ctx := identityContext(t, map[string]string{
+		"X-Title":      "  My\tCool   App ",
+		"HTTP-Referer": "https://example.com/chat",
+	})
+
+	assert.Equal(t, "My Cool App", ctx.Value(store.ContextKeyAppTitle))
+	assert.Equal(t, "https://example.com/chat", ctx.Value(store.ContextKeyAppReferer))
+	// attribution must not be usable as an X-App-Name for auth
+	assert.Nil(t, ctx.Value(store.ContextKeyAppName))
+}
+
+func TestIdentity_AttributionHeadersNormalized(t *testing.T) {
+	ctx := identityContext(t, map[string]string{
+		"X-Title":      strings.Repeat("a", 300),
+		"HTTP-Referer": "javascript:alert(1)",
+	})
+
+	assert.Len(t, ctx.Value(store.ContextKeyAppTitle), maxAppTitleLength)
+	assert.Nil(t, ctx.Value(store.ContextKeyAppReferer))
+}
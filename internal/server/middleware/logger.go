@@ -37,6 +37,10 @@ func LoggerMiddleware() gin.HandlerFunc {
 			fields = append(fields, zap.String("user-agent", ua))
 		}
 
+		if logger.Get().Core().Enabled(zap.DebugLevel) {
+			fields = append(fields, zap.Any("headers", logger.RedactHeaders(c.Request.Header)))
+		}
+
 		if len(c.Errors) > 0 {
 			fields = append(fields, zap.String("errors", c.Errors.String()))
 		}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/httpclient"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+const HeaderProviderBaseURL = "X-Provider-Base-URL"
+
+// BaseURLOverride points a single request at another upstream, taken from
+// the X-Provider-Base-URL header. The provider's API key is sent along, so
+// the header is rejected unless the feature is enabled, the API key is
+// trusted with allow_base_url_override and the host is on the allow list.
+// Must run after Auth.
+func BaseURLOverride(cfg config.BaseURLOverrideConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		raw := ctx.GetHeader(HeaderProviderBaseURL)
+		if raw == "" {
+			ctx.Next()
+			return
+		}
+
+		if !cfg.Enabled {
+			abortOverride(ctx, "Base URL override is disabled")
+			return
+		}
+
+		key, ok := ctx.Request.Context().Value(store.ContextKeyAPIKey).(*model.APIKey)
+		if !ok || key == nil {
+			abortOverride(ctx, "Base URL override requires a trusted API key")
+			return
+		}
+		settings, err := key.Settings()
+		if err != nil || !settings.AllowBaseURLOverride {
+			abortOverride(ctx, "API key is not allowed to override the base URL")
+			return
+		}
+
+		base, err := url.Parse(raw)
+		if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" || (base.Path != "" && base.Path != "/") {
+			_ = ctx.Error(api.BadRequestError("X-Provider-Base-URL must be an absolute http(s) URL without a path"))
+			ctx.Abort()
+			return
+		}
+		if !slices.Contains(cfg.AllowedHosts, base.Hostname()) {
+			abortOverride(ctx, "Base URL host is not allowed")
+			return
+		}
+
+		ctx.Request = ctx.Request.WithContext(httpclient.WithBaseURL(ctx.Request.Context(), base))
+		ctx.Next()
+	}
+}
+
+func abortOverride(ctx *gin.Context, detail string) {
+	_ = ctx.Error(api.NewError(http.StatusForbidden, "Forbidden", detail))
+	ctx.Abort()
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/httpclient"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// overrideRouter calls a fixed upstream from its handler, the way provider
+// adapters do, and reports the path the sandbox upstream received.
+func overrideRouter(t *testing.T, cfg config.BaseURLOverrideConfig, key *model.APIKey) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(ErrorHandler())
+	r.Use(func(c *gin.Context) {
+		if key != nil {
+			c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), store.ContextKeyAPIKey, key))
+		}
+	})
+	r.Use(BaseURLOverride(cfg))
+	r.POST("/", func(c *gin.Context) {
+		client := httpclient.NewClient(time.Second)
+		err := httpclient.SendRequest(c.Request.Context(), client, http.MethodGet, "http://upstream.invalid/v1/models", nil, nil, nil)
+		if err != nil {
+			c.String(http.StatusBadGateway, err.Error())
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	return r
+}
+
+func TestBaseURLOverride(t *testing.T) {
+	var gotPath string
+	sandbox := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+	}))
+	defer sandbox.Close()
+
+	trusted := &model.APIKey{ID: "key-1", SettingsJSON: `{"allow_base_url_override":true}`}
+	untrusted := &model.APIKey{ID: "key-2"}
+	sandboxURL, err := url.Parse(sandbox.URL)
+	require.NoError(t, err)
+	enabled := config.BaseURLOverrideConfig{Enabled: true, AllowedHosts: []string{sandboxURL.Hostname()}}
+
+	tests := []struct {
+		name string
+		cfg  config.BaseURLOverrideConfig
+		key  *model.APIKey
+		code int
+	}{
+		{"disabled", config.BaseURLOverrideConfig{}, trusted, http.StatusForbidden},
+		{"no api key", enabled, nil, http.StatusForbidden},
+		{"untrusted key", enabled, untrusted, http.StatusForbidden},
+		{"host not allowed", config.BaseURLOverrideConfig{Enabled: true, AllowedHosts: []string{"staging.example.com"}}, trusted, http.StatusForbidden},
+		{"no hosts allowed", config.BaseURLOverrideConfig{Enabled: true}, trusted, http.StatusForbidden},
+		{"enabled and trusted", enabled, trusted, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotPath = ""
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.Header.Set(HeaderProviderBaseURL, sandbox.URL)
+
+			w := httptest.NewRecorder()
+			overrideRouter(t, tt.cfg, tt.key).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+			if tt.code == http.StatusOK {
+				// the host is swapped, the upstream path is kept
+				assert.Equal(t, "/v1/models", gotPath)
+			} else {
+				assert.Empty(t, gotPath)
+			}
+		})
+	}
+}
+
+func TestBaseURLOverride_RejectsPath(t *testing.T) {
+	key := &model.APIKey{ID: "key-1", SettingsJSON: `{"allow_base_url_override":true}`}
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set(HeaderProviderBaseURL, "https://staging.example.com/v1")
+
+	w := httptest.NewRecorder()
+	overrideRouter(t, config.BaseURLOverrideConfig{Enabled: true}, key).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/cache"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+const (
+	HeaderSignature          = "X-Signature"
+	HeaderSignatureTimestamp = "X-Signature-Timestamp"
+	HeaderSignatureNonce     = "X-Signature-Nonce"
+
+	maxNonceLength = 128
+	// maxSignedBody caps the body read into memory to check its signature,
+	// above the 25 MB transcription upload limit.
+	maxSignedBody = 32 << 20
+)
+
+// Signature verifies HMAC-SHA256 request signatures for API keys that carry a
+// signing secret. Clients sign "<timestamp>.<nonce>.<body>" with the secret and
+// send the hex digest along with the unix timestamp and a unique nonce.
+// Stale timestamps are rejected and seen nonces are cached to block replays.
+// Must run after Auth so the API key is in the request context.
+func Signature(c cache.CacheService, cfg config.SigningConfig) gin.HandlerFunc {
+	skew := cfg.MaxClockSkew
+	if skew <= 0 {
+		skew = 5 * time.Minute
+	}
+
+	return func(ctx *gin.Context) {
+		if !cfg.Enabled {
+			ctx.Next()
+			return
+		}
+
+		key, ok := ctx.Request.Context().Value(store.ContextKeyAPIKey).(*model.APIKey)
+		if !ok || key == nil {
+			ctx.Next()
+			return
+		}
+
+		settings, err := key.Settings()
+		if err != nil {
+			// fail closed, a broken settings blob must not disable signing
+			_ = ctx.Error(api.InternalError("Invalid API key settings", err.Error()))
+			ctx.Abort()
+			return
+		}
+		if settings.SigningSecret == "" {
+			ctx.Next()
+			return
+		}
+
+		timestamp := ctx.GetHeader(HeaderSignatureTimestamp)
+		nonce := ctx.GetHeader(HeaderSignatureNonce)
+		signature := ctx.GetHeader(HeaderSignature)
+		if timestamp == "" || nonce == "" || signature == "" {
+			abortSignature(ctx, "Missing request signature headers")
+			return
+		}
+		if len(nonce) > maxNonceLength {
+			abortSignature(ctx, "Signature nonce is too long")
+			return
+		}
+
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			abortSignature(ctx, "Invalid signature timestamp")
+			return
+		}
+		age := time.Since(time.Unix(ts, 0))
+		if age > skew || age < -skew {
+			abortSignature(ctx, "Signature timestamp is outside the allowed window")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxSignedBody))
+		if maxErr := (*http.MaxBytesError)(nil); errors.As(err, &maxErr) {
+			_ = ctx.Error(api.NewError(http.StatusRequestEntityTooLarge, "Request Too Large",
+				fmt.Sprintf("signed request bodies are limited to %d bytes", maxErr.Limit)))
+			ctx.Abort()
+			return
+		}
+		if err != nil {
+			_ = ctx.Error(api.BadRequestError("Failed to read request body"))
+			ctx.Abort()
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		given, err := hex.DecodeString(signature)
+		if err != nil || !hmac.Equal(given, Sign(settings.SigningSecret, timestamp, nonce, body)) {
+			abortSignature(ctx, "Invalid request signature")
+			return
+		}
+
+		// recorded atomically, of two identical requests racing each other
+		// only one passes. A nonce only has to outlive the window in which
+		// its timestamp is accepted.
+		nonceKey := fmt.Sprintf("nonce:%s:%s", key.ID, nonce)
+		fresh, err := c.SetNX(ctx.Request.Context(), nonceKey, true, 2*skew)
+		if err != nil {
+			_ = ctx.Error(api.InternalError("Failed to record signature nonce", err.Error()))
+			ctx.Abort()
+			return
+		}
+		if !fresh {
+			abortSignature(ctx, "Signature nonce has already been used")
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// Sign computes the HMAC-SHA256 of "<timestamp>.<nonce>.<body>" with the secret.
+func Sign(secret, timestamp, nonce string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write([]byte(nonce))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+func abortSignature(ctx *gin.Context, detail string) {
+	_ = ctx.Error(api.NewError(http.StatusUnauthorized, "Invalid Signature", detail))
+	ctx.Abort()
+}
@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/cache"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/stretchr/testify/assert"
+)
+
+const testSigningSecret = "s3cr3t"
+
+func signatureRouter(t *testing.T, c cache.CacheService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	key := &model.APIKey{ID: "key-1", SettingsJSON: `{"signing_secret":"` + testSigningSecret + `"}`}
+
+	r := gin.New()
+	r.Use(ErrorHandler())
+	r.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), store.ContextKeyAPIKey, key))
+	})
+	r.Use(Signature(c, config.SigningConfig{Enabled: true, MaxClockSkew: time.Minute}))
+	r.POST("/", func(c *gin.Context) {
+		body, _ := c.GetRawData()
+		c.String(http.StatusOK, string(body))
+	})
+	return r
+}
+
+func signedRequest(ts time.Time, nonce, body string) *http.Request {
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set(HeaderSignatureTimestamp, timestamp)
+	req.Header.Set(HeaderSignatureNonce, nonce)
+	req.Header.Set(HeaderSignature, hex.EncodeToString(Sign(testSigningSecret, timestamp, nonce, []byte(body))))
+	return req
+}
+
+func TestSignature_Valid(t *testing.T) {
+	r := signatureRouter(t, cache.NewMemoryCache())
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, signedRequest(time.Now(), "n-1", `{"model":"m"}`))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	// the body is still readable downstream
+	assert.Equal(t, `{"model":"m"}`, w.Body.String())
+}
+
+func TestSignature_InvalidSignature(t *testing.T) {
+	r := signatureRouter(t, cache.NewMemoryCache())
+
+	req := signedRequest(time.Now(), "n-1", `{"model":"m"}`)
+	// tamper with the body after signing
+	req.Body = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"model":"x"}`)).Body
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid request signature")
+}
+
+func TestSignature_StaleTimestamp(t *testing.T) {
+	r := signatureRouter(t, cache.NewMemoryCache())
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, signedRequest(time.Now().Add(-2*time.Minute), "n-1", `{}`))
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "outside the allowed window")
+}
+
+func TestSignature_ReplayedNonce(t *testing.T) {
+	r := signatureRouter(t, cache.NewMemoryCache())
+	now := time.Now()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, signedRequest(now, "n-1", `{}`))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, signedRequest(now, "n-1", `{}`))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "already been used")
+}
+
+func TestSignature_ConcurrentReplay(t *testing.T) {
+	r := signatureRouter(t, cache.NewMemoryCache())
+	now := time.Now()
+
+	codes := make(chan int, 20)
+	var wg sync.WaitGroup
+	for range cap(codes) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, signedRequest(now, "n-1", `{}`))
+			codes <- w.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	passed := 0
+	for code := range codes {
+		if code == http.StatusOK {
+			passed++
+		}
+	}
+	assert.Equal(t, 1, passed, "a nonce is accepted once")
+}
+
+func TestSignature_BodyTooLarge(t *testing.T) {
+	r := signatureRouter(t, cache.NewMemoryCache())
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, signedRequest(time.Now(), "n-1", strings.Repeat("x", maxSignedBody+1)))
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestSignature_KeyWithoutSecret(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(ErrorHandler())
+	r.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), store.ContextKeyAPIKey, &model.APIKey{ID: "key-2"}))
+	})
+	r.Use(Signature(cache.NewMemoryCache(), config.SigningConfig{Enabled: true}))
+	r.POST("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`)))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
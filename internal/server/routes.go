@@ -1,6 +1,7 @@
 package server
 
 import (
+	"github.com/nulzo/model-router-api/internal/flags"
 	"github.com/nulzo/model-router-api/internal/server/middleware"
 	v1 "github.com/nulzo/model-router-api/internal/server/v1"
 )
@@ -10,6 +11,10 @@ func (s *Server) SetupRoutes() {
 	s.router.Use(middleware.CORS())
 	s.router.Use(middleware.ErrorHandler())
 
+	s.router.HandleMethodNotAllowed = true
+	s.router.NoRoute(middleware.NoRoute())
+	s.router.NoMethod(middleware.NoMethod())
+
 	healthHandler := v1.NewHealthHandler()
 	s.router.GET("/health", healthHandler.Health)
 	s.router.GET("/routes", v1.NewRoutesHandler(s.router).List)
@@ -20,17 +25,56 @@ func (s *Server) SetupRoutes() {
 
 	if s.config.Server.AuthEnabled {
 		api.Use(middleware.Auth(s.repo, s.config.Server.APIKeys))
+		api.Use(middleware.Signature(s.cache, s.config.Signing))
 	}
+	api.Use(middleware.BaseURLOverride(s.config.BaseURLOverride))
 
-	chatHandler := v1.NewChatHandler(s.service, s.validator)
+	chatHandler := v1.NewChatHandler(s.service, s.validator, s.config.Server.StreamFlushInterval, s.cache, s.config.Server.StreamResume, s.config.Server.StripResponseFields, s.config.Server.StreamFirstChunkTimeout)
 	api.POST("/chat/completions", chatHandler.CreateCompletion)
 
+	if s.config.Server.MessagesEndpoint {
+		messagesHandler := v1.NewMessagesHandler(s.service, s.validator, s.config.Server.StreamFirstChunkTimeout)
+		api.POST("/messages", messagesHandler.CreateMessage)
+	}
+
+	audioHandler := v1.NewAudioHandler(s.service, s.validator)
+	api.POST("/audio/speech", audioHandler.CreateSpeech)
+	api.POST("/audio/transcriptions", audioHandler.CreateTranscription)
+
 	modelsHandler := v1.NewModelHandler(s.service)
 	api.GET("/models", modelsHandler.ListModels)
 
+	aliasesHandler := v1.NewAliasesHandler(s.service, s.repo)
+	api.GET("/aliases", aliasesHandler.List)
+	api.PUT("/aliases", aliasesHandler.Set)
+	api.DELETE("/aliases", aliasesHandler.Delete)
+
+	capabilitiesHandler := v1.NewCapabilitiesHandler(s.service)
+	api.GET("/capabilities", capabilitiesHandler.List)
+
+	providersHandler := v1.NewProvidersHandler(s.service, s.repo, s.config.Providers)
+	api.GET("/providers", providersHandler.List)
+	api.POST("/providers/health", providersHandler.CheckHealth)
+
+	// provider errors and load are for admins only
+	api.GET("/health/providers", v1.NewProviderHealthHandler(s.service, s.repo).List)
+	api.GET("/health/streams", v1.NewStreamsHandler(s.service, s.repo).Get)
+
+	selfTestHandler := v1.NewSelfTestHandler(s.service, s.repo)
+	api.GET("/selftest", selfTestHandler.Run)
+
+	flagsHandler := v1.NewFlagsHandler(flags.New(s.cache, s.config.Gateway.Flags), s.repo)
+	api.PUT("/flags/:flag", flagsHandler.Set)
+	api.DELETE("/flags/:flag", flagsHandler.Clear)
+
 	analyticsHandler := v1.NewAnalyticsHandler(s.analytics)
 	api.GET("/analytics/usage", analyticsHandler.GetUsage)
 
 	generationHandler := v1.NewGenerationHandler(s.repo)
 	api.GET("/generation", generationHandler.GetGeneration)
+
+	keysHandler := v1.NewKeysHandler(s.repo)
+	api.GET("/keys", keysHandler.ListKeys)
+	api.PUT("/keys/:id/models", keysHandler.SetModelPolicy)
+	api.PUT("/keys/:id/priority", keysHandler.SetPriority)
 }
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net/http"
 	"time"
@@ -13,6 +14,7 @@ import (
 	"github.com/nulzo/model-router-api/internal/gateway"
 	"github.com/nulzo/model-router-api/internal/server/validator"
 	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/cache"
 	"go.uber.org/zap"
 )
 
@@ -21,12 +23,13 @@ type Server struct {
 	config    *config.Config
 	logger    *zap.Logger
 	repo      store.Repository
+	cache     cache.CacheService
 	service   gateway.Service
 	analytics analytics.Service
 	validator *validator.Validator
 }
 
-func New(cfg *config.Config, logger *zap.Logger, repo store.Repository, service gateway.Service, analytics analytics.Service, v *validator.Validator) *Server {
+func New(cfg *config.Config, logger *zap.Logger, repo store.Repository, cache cache.CacheService, service gateway.Service, analytics analytics.Service, v *validator.Validator) *Server {
 
 	gin.SetMode(gin.ReleaseMode)
 
@@ -39,6 +42,7 @@ func New(cfg *config.Config, logger *zap.Logger, repo store.Repository, service
 	s := &Server{
 		router:    engine,
 		repo:      repo,
+		cache:     cache,
 		service:   service,
 		analytics: analytics,
 		logger:    logger,
@@ -66,3 +70,34 @@ func New(cfg *config.Config, logger *zap.Logger, repo store.Repository, service
 func (s *Server) Handler() http.Handler {
 	return s.router
 }
+
+// HTTPServer builds the http.Server for the API with the configured timeouts
+// and, when enabled, TLS settings.
+func (s *Server) HTTPServer() *http.Server {
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", s.config.Server.Port),
+		Handler:           s.router,
+		ReadTimeout:       s.config.Server.ReadTimeout,
+		ReadHeaderTimeout: s.config.Server.ReadHeaderTimeout,
+		WriteTimeout:      s.config.Server.WriteTimeout,
+		IdleTimeout:       s.config.Server.IdleTimeout,
+	}
+
+	if tlsCfg := s.config.Server.TLS; tlsCfg.Enabled {
+		srv.TLSConfig = NewTLSConfig(tlsCfg)
+		if !tlsCfg.HTTP2 {
+			// a non-nil empty map stops net/http from enabling HTTP/2
+			srv.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+		}
+	}
+
+	return srv
+}
+
+// ListenAndServe starts srv, over TLS when it is enabled in the config.
+func (s *Server) ListenAndServe(srv *http.Server) error {
+	if tlsCfg := s.config.Server.TLS; tlsCfg.Enabled {
+		return srv.ListenAndServeTLS(tlsCfg.CertFile, tlsCfg.KeyFile)
+	}
+	return srv.ListenAndServe()
+}
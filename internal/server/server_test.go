@@ -0,0 +1,113 @@
+package server
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/server/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestHTTPServer_Timeouts(t *testing.T) {
+	cfg := &config.Config{
+		Server: config.ServerConfig{
+			Port:              9090,
+			Env:               "development",
+			ReadTimeout:       30 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      10 * time.Minute,
+			IdleTimeout:       2 * time.Minute,
+		},
+	}
+
+	srv := New(cfg, zap.NewNop(), nil, nil, nil, nil, validator.New()).HTTPServer()
+
+	assert.Equal(t, ":9090", srv.Addr)
+	assert.Equal(t, 30*time.Second, srv.ReadTimeout)
+	assert.Equal(t, 5*time.Second, srv.ReadHeaderTimeout)
+	assert.Equal(t, 10*time.Minute, srv.WriteTimeout)
+	assert.Equal(t, 2*time.Minute, srv.IdleTimeout)
+	assert.NotNil(t, srv.Handler)
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        config.TLSConfig
+		minVersion uint16
+		nextProtos []string
+	}{
+		{name: "tls 1.3 with http2", cfg: config.TLSConfig{MinVersion: "1.3", HTTP2: true}, minVersion: tls.VersionTLS13, nextProtos: []string{"h2", "http/1.1"}},
+		{name: "tls 1.2 without http2", cfg: config.TLSConfig{MinVersion: "1.2"}, minVersion: tls.VersionTLS12, nextProtos: []string{"http/1.1"}},
+		{name: "default version", cfg: config.TLSConfig{}, minVersion: tls.VersionTLS12, nextProtos: []string{"http/1.1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlsCfg := NewTLSConfig(tt.cfg)
+
+			assert.Equal(t, tt.minVersion, tlsCfg.MinVersion)
+			assert.Equal(t, tt.nextProtos, tlsCfg.NextProtos)
+			assert.NotContains(t, tlsCfg.CipherSuites, tls.TLS_RSA_WITH_AES_128_CBC_SHA)
+		})
+	}
+}
+
+func TestHTTPServer_TLS(t *testing.T) {
+	cfg := &config.Config{
+		Server: config.ServerConfig{
+			Port: 8443,
+			Env:  "production",
+			TLS:  config.TLSConfig{Enabled: true, CertFile: "cert.pem", KeyFile: "key.pem", MinVersion: "1.3"},
+		},
+	}
+
+	srv := New(cfg, zap.NewNop(), nil, nil, nil, nil, validator.New()).HTTPServer()
+
+	require.NotNil(t, srv.TLSConfig)
+	assert.Equal(t, uint16(tls.VersionTLS13), srv.TLSConfig.MinVersion)
+	// http2 disabled
+	assert.NotNil(t, srv.TLSNextProto)
+	assert.Empty(t, srv.TLSNextProto)
+}
+
+func TestUnknownRoutesReturnProblems(t *testing.T) {
+	cfg := &config.Config{Server: config.ServerConfig{Port: 9090, Env: "development"}}
+	handler := New(cfg, zap.NewNop(), nil, nil, nil, nil, validator.New()).Handler()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		title  string
+	}{
+		{"unknown path", http.MethodGet, "/api/v1/nope", http.StatusNotFound, "Not Found"},
+		{"unsupported method", http.MethodDelete, "/health", http.StatusMethodNotAllowed, "Method Not Allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+
+			var problem map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, "about:blank", problem["type"])
+			assert.Equal(t, tt.title, problem["title"])
+			assert.Equal(t, float64(tt.status), problem["status"])
+			assert.Equal(t, tt.path, problem["instance"])
+			assert.NotEmpty(t, problem["detail"])
+		})
+	}
+}
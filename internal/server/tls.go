@@ -0,0 +1,44 @@
+package server
+
+import (
+	"crypto/tls"
+
+	"github.com/nulzo/model-router-api/internal/config"
+)
+
+// tlsVersions maps the configured min_version to its protocol constant.
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// secureCipherSuites are the TLS 1.2 suites we accept: forward secret AEAD
+// ciphers only. TLS 1.3 suites are not configurable in crypto/tls.
+var secureCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// NewTLSConfig builds the server TLS settings. The minimum version defaults
+// to TLS 1.2 and HTTP/2 is only advertised when enabled.
+func NewTLSConfig(cfg config.TLSConfig) *tls.Config {
+	minVersion, ok := tlsVersions[cfg.MinVersion]
+	if !ok {
+		minVersion = tls.VersionTLS12
+	}
+
+	nextProtos := []string{"http/1.1"}
+	if cfg.HTTP2 {
+		nextProtos = []string{"h2", "http/1.1"}
+	}
+
+	return &tls.Config{
+		MinVersion:   minVersion,
+		CipherSuites: secureCipherSuites,
+		NextProtos:   nextProtos,
+	}
+}
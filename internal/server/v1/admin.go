@@ -0,0 +1,28 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+// requireAdmin reports whether the caller's API key belongs to an admin,
+// recording a 401 or 403 on the context when it does not. action completes
+// the error detail, e.g. "listing providers".
+func requireAdmin(c *gin.Context, repo store.Repository, action string) bool {
+	caller, ok := c.Request.Context().Value(store.ContextKeyAPIKey).(*model.APIKey)
+	if !ok {
+		_ = c.Error(api.NewError(http.StatusUnauthorized, "Unauthorized", fmt.Sprintf("%s requires an API key", action)))
+		return false
+	}
+	user, err := repo.Users().Get(c.Request.Context(), caller.UserID)
+	if err != nil || user.Role != "admin" {
+		_ = c.Error(api.NewError(http.StatusForbidden, "Forbidden", fmt.Sprintf("%s requires an admin API key", action)))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nulzo/model-router-api/internal/gateway"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+type AliasesHandler struct {
+	service gateway.Service
+	repo    store.Repository
+}
+
+func NewAliasesHandler(service gateway.Service, repo store.Repository) *AliasesHandler {
+	return &AliasesHandler{service: service, repo: repo}
+}
+
+// modelAlias is a model alias as listed and set through the API.
+type modelAlias struct {
+	Alias string `json:"alias"`
+	Model string `json:"model"`
+}
+
+// List returns every model alias sorted by name. Admin only.
+// GET /api/v1/aliases
+func (h *AliasesHandler) List(c *gin.Context) {
+	if !requireAdmin(c, h.repo, "listing model aliases") {
+		return
+	}
+
+	aliases := []modelAlias{}
+	for alias, model := range h.service.ModelAliases() {
+		aliases = append(aliases, modelAlias{Alias: alias, Model: model})
+	}
+	sort.Slice(aliases, func(i, j int) bool { return aliases[i].Alias < aliases[j].Alias })
+
+	c.JSON(http.StatusOK, gin.H{
+		"object": "list",
+		"data":   aliases,
+	})
+}
+
+// Set creates or repoints a model alias. Aliases set at runtime are kept in
+// memory and lost on restart, configure model_aliases to keep them. Admin only.
+// PUT /api/v1/aliases
+func (h *AliasesHandler) Set(c *gin.Context) {
+	if !requireAdmin(c, h.repo, "setting model aliases") {
+		return
+	}
+
+	var req modelAlias
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		_ = c.Error(api.BadRequestError(fmt.Sprintf("invalid model alias: %s", err)))
+		return
+	}
+
+	if err := h.service.SetModelAlias(req.Alias, req.Model); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, req)
+}
+
+// Delete removes a model alias. Admin only.
+// DELETE /api/v1/aliases?alias=
+func (h *AliasesHandler) Delete(c *gin.Context) {
+	if !requireAdmin(c, h.repo, "deleting model aliases") {
+		return
+	}
+
+	alias := c.Query("alias")
+	if !h.service.DeleteModelAlias(alias) {
+		_ = c.Error(api.NewError(http.StatusNotFound, "Alias Not Found", fmt.Sprintf("no model alias named '%s'", alias)))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
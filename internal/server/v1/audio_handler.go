@@ -0,0 +1,137 @@
+package v1
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nulzo/model-router-api/internal/gateway"
+	"github.com/nulzo/model-router-api/internal/server/validator"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+// maxTranscriptionUpload matches the 25 MB file limit of the OpenAI API.
+const maxTranscriptionUpload = 25 << 20
+
+type AudioHandler struct {
+	service   gateway.Service
+	validator *validator.Validator
+}
+
+func NewAudioHandler(service gateway.Service, v *validator.Validator) *AudioHandler {
+	return &AudioHandler{service: service, validator: v}
+}
+
+// CreateSpeech synthesizes the input text and returns the audio bytes.
+// POST /api/v1/audio/speech
+func (h *AudioHandler) CreateSpeech(c *gin.Context) {
+	var req api.SpeechRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(api.ValidationError(h.validator.ParseError(err)))
+		return
+	}
+
+	resp, err := h.service.Speech(c.Request.Context(), &req)
+	if err != nil {
+		var problem *api.Problem
+		if errors.As(err, &problem) {
+			_ = c.Error(problem)
+			return
+		}
+		_ = c.Error(api.InternalError("Failed to synthesize speech", err.Error()))
+		return
+	}
+
+	c.Data(http.StatusOK, resp.ContentType, resp.Audio)
+}
+
+// CreateTranscription transcribes an uploaded audio file.
+// POST /api/v1/audio/transcriptions
+func (h *AudioHandler) CreateTranscription(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxTranscriptionUpload)
+
+	req, problem := parseTranscriptionForm(c)
+	if problem != nil {
+		_ = c.Error(problem)
+		return
+	}
+
+	resp, err := h.service.Transcribe(c.Request.Context(), req)
+	if err != nil {
+		var problem *api.Problem
+		if errors.As(err, &problem) {
+			_ = c.Error(problem)
+			return
+		}
+		_ = c.Error(api.InternalError("Failed to transcribe audio", err.Error()))
+		return
+	}
+
+	switch req.ResponseFormat {
+	case "text", "srt", "vtt":
+		c.String(http.StatusOK, resp.Text)
+	case "verbose_json":
+		if resp.Verbose != nil {
+			c.Data(http.StatusOK, "application/json", resp.Verbose)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"text": resp.Text, "language": resp.Language, "duration": resp.Duration})
+	default:
+		c.JSON(http.StatusOK, gin.H{"text": resp.Text})
+	}
+}
+
+func parseTranscriptionForm(c *gin.Context) (*api.TranscriptionRequest, *api.Problem) {
+	fields := make(map[string]string)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, api.NewError(http.StatusRequestEntityTooLarge, "Payload Too Large",
+				"audio files are limited to 25 MB")
+		}
+		fields["file"] = "required"
+	}
+
+	req := &api.TranscriptionRequest{
+		Model:          c.PostForm("model"),
+		Language:       c.PostForm("language"),
+		Prompt:         c.PostForm("prompt"),
+		ResponseFormat: c.DefaultPostForm("response_format", "json"),
+	}
+	if req.Model == "" {
+		fields["model"] = "required"
+	}
+	switch req.ResponseFormat {
+	case "json", "text", "verbose_json", "srt", "vtt":
+	default:
+		fields["response_format"] = "must be one of json text verbose_json srt vtt"
+	}
+	if raw := c.PostForm("temperature"); raw != "" {
+		t, err := strconv.ParseFloat(raw, 64)
+		if err != nil || t < 0 || t > 1 {
+			fields["temperature"] = "must be between 0 and 1"
+		}
+		req.Temperature = t
+	}
+	if len(fields) > 0 {
+		return nil, api.ValidationError(fields)
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return nil, api.BadRequestError("failed to read uploaded file")
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	if req.File, err = io.ReadAll(file); err != nil {
+		return nil, api.BadRequestError("failed to read uploaded file")
+	}
+	req.Filename = header.Filename
+
+	return req, nil
+}
@@ -0,0 +1,26 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nulzo/model-router-api/internal/gateway"
+)
+
+type CapabilitiesHandler struct {
+	service gateway.Service
+}
+
+func NewCapabilitiesHandler(service gateway.Service) *CapabilitiesHandler {
+	return &CapabilitiesHandler{service: service}
+}
+
+// List returns the feature matrix of every registered provider so clients
+// can decide where to route.
+// GET /api/v1/capabilities
+func (h *CapabilitiesHandler) List(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"object": "list",
+		"data":   h.service.Capabilities(),
+	})
+}
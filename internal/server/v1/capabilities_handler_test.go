@@ -0,0 +1,78 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/gateway"
+	"github.com/nulzo/model-router-api/internal/llm"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// capsProvider is a chat provider with a vision model that also embeds.
+type capsProvider struct {
+	id     string
+	models []api.ModelDefinition
+}
+
+func (p *capsProvider) Name() string { return p.id }
+func (p *capsProvider) Type() string { return "mock" }
+func (p *capsProvider) Chat(context.Context, *api.ChatRequest) (*api.ChatResponse, error) {
+	return nil, nil
+}
+func (p *capsProvider) Stream(context.Context, *api.ChatRequest) (<-chan api.StreamResult, error) {
+	return nil, nil
+}
+func (p *capsProvider) Models(context.Context) ([]api.ModelDefinition, error) { return p.models, nil }
+func (p *capsProvider) Health(context.Context) error                          { return nil }
+
+type embeddingProvider struct{ capsProvider }
+
+func (p *embeddingProvider) Capabilities() llm.Capabilities {
+	return llm.Capabilities{Embeddings: true}
+}
+
+func TestListCapabilities(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	svc := gateway.NewService(zap.NewNop(), nil, nil, nil, config.GatewayConfig{})
+	require.NoError(t, svc.RegisterProvider(context.Background(), &embeddingProvider{capsProvider{
+		id: "rich",
+		models: []api.ModelDefinition{{
+			ID:           "rich/vision",
+			Architecture: api.ModelArchitecture{InputModalities: []string{"text", "image"}},
+		}},
+	}}))
+	require.NoError(t, svc.RegisterProvider(context.Background(), &capsProvider{
+		id:     "plain",
+		models: []api.ModelDefinition{{ID: "plain/text"}},
+	}))
+
+	r := gin.New()
+	r.GET("/capabilities", NewCapabilitiesHandler(svc).List)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/capabilities", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var body struct {
+		Data []gateway.ProviderCapabilities `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Data, 2)
+
+	assert.Equal(t, "plain", body.Data[0].ID)
+	assert.Equal(t, llm.Capabilities{Chat: true, Stream: true}, body.Data[0].Capabilities)
+
+	assert.Equal(t, "rich", body.Data[1].ID)
+	assert.Equal(t, "mock", body.Data[1].Type)
+	assert.Equal(t, llm.Capabilities{Chat: true, Stream: true, Vision: true, Embeddings: true}, body.Data[1].Capabilities)
+}
@@ -1,38 +1,82 @@
 package v1
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"github.com/nulzo/model-router-api/internal/config"
 	"github.com/nulzo/model-router-api/internal/gateway"
 	"github.com/nulzo/model-router-api/internal/server/validator"
+	"github.com/nulzo/model-router-api/internal/store/cache"
 	"github.com/nulzo/model-router-api/pkg/api"
 )
 
+// maxFlushInterval bounds how long a coalesced chunk may wait before it is
+// flushed, so tuning cannot noticeably delay tokens.
+const maxFlushInterval = 50 * time.Millisecond
+
 type ChatHandler struct {
-	service   gateway.Service
-	validator *validator.Validator
+	service       gateway.Service
+	validator     *validator.Validator
+	flushInterval time.Duration
+	cache         cache.CacheService
+	resume        config.StreamResumeConfig
+	projection    *responseProjection
+	firstChunk    time.Duration
 }
 
-func NewChatHandler(service gateway.Service, v *validator.Validator) *ChatHandler {
+// NewChatHandler creates a chat handler. Stream chunks written within
+// flushInterval of each other are flushed together, zero flushes every chunk.
+// When resume is enabled, stream events are buffered in the cache so clients
+// can reconnect with a Last-Event-ID. stripFields are removed from every
+// response and stream chunk sent to the client. Streams sending nothing
+// within firstChunk fail with a 504, zero waits forever.
+func NewChatHandler(service gateway.Service, v *validator.Validator, flushInterval time.Duration, c cache.CacheService, resume config.StreamResumeConfig, stripFields []string, firstChunk time.Duration) *ChatHandler {
 	return &ChatHandler{
-		service:   service,
-		validator: v,
+		service:       service,
+		validator:     v,
+		flushInterval: flushInterval,
+		cache:         c,
+		resume:        resume,
+		projection:    newResponseProjection(stripFields),
+		firstChunk:    firstChunk,
 	}
 }
 
 func (h *ChatHandler) CreateCompletion(c *gin.Context) {
+	// a reconnecting client picks up the stream it lost instead of starting over
+	if h.resume.Enabled {
+		if lastEventID := c.GetHeader("Last-Event-ID"); lastEventID != "" {
+			h.resumeStream(c, lastEventID)
+			return
+		}
+	}
+
 	var req api.ChatRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
 		// returns RFC compliant error
 		_ = c.Error(api.ValidationError(h.validator.ParseError(err)))
 		return
 	}
 
+	// the calling key may provide a default model, so its settings are applied before validation
+	if err := h.service.ApplyKeySettings(c.Request.Context(), &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := binding.Validator.ValidateStruct(&req); err != nil {
+		_ = c.Error(api.ValidationError(h.validator.ParseError(err)))
+		return
+	}
+
 	// if we want to stream the response, roll down into streaming
 	if req.Stream {
 		h.handleStream(c, &req)
@@ -41,30 +85,222 @@ func (h *ChatHandler) CreateCompletion(c *gin.Context) {
 
 	resp, err := h.service.Chat(c.Request.Context(), &req)
 	if err != nil {
+		// domain problems (e.g. content blocked upstream) keep their status and extensions
+		var problem *api.Problem
+		if errors.As(err, &problem) {
+			_ = c.Error(problem)
+			return
+		}
+
 		// at this point we hit an upstream error, and we should surface it back
 		_ = c.Error(api.InternalError("Failed to process chat request", err.Error()))
 		return
 	}
 
-	c.JSON(http.StatusOK, resp)
+	if resp.Deprecation != nil {
+		c.Header("Warning", fmt.Sprintf("299 - %q", resp.Deprecation.Notice))
+	}
+	if h.projection == nil {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		_ = c.Error(api.InternalError("Failed to encode chat response", err.Error()))
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", h.projection.apply(data))
 }
 
 func (h *ChatHandler) handleStream(c *gin.Context, req *api.ChatRequest) {
+	ctx := c.Request.Context()
+	var buf *streamBuffer
+	if h.resume.Enabled {
+		// a resumable stream outlives its client so a reconnect can catch up
+		id, err := uuid.NewRandom()
+		if err != nil {
+			_ = c.Error(api.InternalError("Failed to start stream", err.Error()))
+			return
+		}
+		buf = newStreamBuffer(h.cache, h.resume, id.String(), callerKeyID(ctx))
+		ctx = context.WithoutCancel(ctx)
+	}
+	// the cause tells the gateway why the stream was abandoned
+	ctx, cancelCause := context.WithCancelCause(ctx)
+	cancel := func() { cancelCause(nil) }
+
 	// call the gateway (service)
-	streamChan, err := h.service.StreamChat(c.Request.Context(), req)
+	streamChan, err := h.service.StreamChat(ctx, req)
+	if err == nil {
+		streamChan, err = openStream(ctx, streamChan, h.firstChunk)
+	}
 	if err != nil {
-		// if this is a domain problem, we should still serialize it properly
+		cancelCause(err)
+		streamError(c, err)
+		return
+	}
+
+	startSSE(c)
+
+	gone := make(chan struct{})
+	defer close(gone)
+	h.writeEvents(c, req.Model, encodeStream(streamChan, h.projection, buf, gone, cancel))
+}
+
+// streamError answers a stream that failed before anything was written.
+func streamError(c *gin.Context, err error) {
+	// domain problems keep their status and extensions
+	var problem *api.Problem
+	if !errors.As(err, &problem) {
+		problem = api.InternalError("Failed to process chat request", err.Error())
+	}
+	c.JSON(problem.Status, problem)
+}
+
+// resumeStream replays the buffered events following lastEventID, then keeps
+// relaying new ones until the stream completes.
+func (h *ChatHandler) resumeStream(c *gin.Context, lastEventID string) {
+	streamID, after, ok := parseEventID(lastEventID)
+	if !ok {
+		_ = c.Error(api.BadRequestError(fmt.Sprintf("invalid Last-Event-ID '%s'", lastEventID)))
+		return
+	}
+
+	ctx := c.Request.Context()
+	state, err := loadResumable(ctx, h.cache, streamID, after)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	startSSE(c)
+
+	events := make(chan sseEvent)
+	go func() {
+		defer close(events)
+		for {
+			for _, e := range state.Events {
+				if e.Seq <= after {
+					continue
+				}
+				select {
+				case events <- sseEvent{ID: eventID(streamID, e.Seq), Data: e.Data}:
+					after = e.Seq
+				case <-ctx.Done():
+					return
+				}
+			}
+			if state.Done {
+				return
+			}
+
+			select {
+			case <-time.After(resumePollInterval):
+			case <-ctx.Done():
+				return
+			}
+			if err := h.cache.Get(ctx, streamBufferKey(streamID), state); err != nil {
+				return // expired
+			}
+		}
+	}()
+
+	h.writeEvents(c, "", events)
+}
+
+// encodeStream encodes the gateway stream into projected SSE events. With a
+// buffer, every event gets an ID and is buffered, and the stream keeps going
+// for the buffer TTL after the client is gone. cancel is called once it ends.
+func encodeStream(streamChan <-chan api.StreamResult, projection *responseProjection, buf *streamBuffer, gone <-chan struct{}, cancel context.CancelFunc) <-chan sseEvent {
+	out := make(chan sseEvent)
+
+	go func() {
+		defer close(out)
+		defer cancel()
+
+		seq, connected := 0, true
+		emit := func(data string) bool {
+			seq++
+			ev := sseEvent{Data: data}
+			if buf != nil {
+				ev.ID = eventID(buf.id, seq)
+				buf.append(seq, data)
+			}
+			if !connected {
+				return buf != nil
+			}
+			select {
+			case out <- ev:
+				return true
+			case <-gone:
+				connected = false
+				if buf == nil {
+					return false
+				}
+				time.AfterFunc(buf.ttl, cancel)
+				return true
+			}
+		}
+		if buf != nil {
+			defer buf.finish(context.Background())
+		}
+
+		for result := range streamChan {
+			data, last := encodeResult(result)
+			if data == "" {
+				continue
+			}
+			data = string(projection.apply([]byte(data)))
+			if !emit(data) {
+				return
+			}
+			// an error is the last event, still followed by [DONE] so clients stop reading
+			if last {
+				break
+			}
+		}
+		emit("[DONE]")
+	}()
+
+	return out
+}
+
+// encodeResult returns the SSE data of a stream result and whether it ends
+// the stream.
+func encodeResult(result api.StreamResult) (string, bool) {
+	if result.Err != nil {
+		status := http.StatusInternalServerError
 		var problem *api.Problem
-		if errors.As(err, &problem) {
-			c.JSON(problem.Status, problem)
-			return
+		if errors.As(result.Err, &problem) {
+			status = problem.Status
+		}
+		errResp := api.ChatResponse{
+			Object: "chat.completion.chunk",
+			Choices: []api.Choice{{
+				FinishReason: string(api.FinishReasonError),
+				Error:        &api.ErrorResponse{Code: status, Message: result.Err.Error()},
+			}},
+			Error: &api.ErrorResponse{Code: status, Message: result.Err.Error()},
 		}
+		data, _ := json.Marshal(errResp)
+		return string(data), true
+	}
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	// passthrough chunks are relayed byte for byte
+	if result.Raw != nil {
+		return string(result.Raw), false
 	}
 
-	// set headers for sse
+	if result.Response != nil {
+		if data, err := json.Marshal(result.Response); err == nil {
+			return string(data), false
+		}
+	}
+	return "", false
+}
+
+// startSSE writes the headers of an event stream.
+func startSSE(c *gin.Context) {
 	c.Writer.Header().Set("Content-Type", "text/event-stream")
 	c.Writer.Header().Set("Cache-Control", "no-cache")
 	c.Writer.Header().Set("Connection", "keep-alive")
@@ -73,48 +309,74 @@ func (h *ChatHandler) handleStream(c *gin.Context, req *api.ChatRequest) {
 
 	c.Writer.WriteHeader(http.StatusOK)
 	c.Writer.Flush()
+}
 
-	// consume the channel and flush to http
-	c.Stream(func(w io.Writer) bool {
+// writeEvents writes events to the client until the channel closes or the
+// client goes away, coalescing small chunks into fewer flushes.
+func (h *ChatHandler) writeEvents(c *gin.Context, modelID string, events <-chan sseEvent) {
+	interval := h.streamFlushInterval(modelID)
+
+	var pending bool
+	var timer *time.Timer
+	var timerC <-chan time.Time
+	flush := func() {
+		if timer != nil {
+			timer.Stop()
+			timer, timerC = nil, nil
+		}
+		if pending {
+			c.Writer.Flush()
+			pending = false
+		}
+	}
+	defer flush()
+
+	write := func(ev sseEvent) bool {
+		var err error
+		if ev.ID != "" {
+			_, err = fmt.Fprintf(c.Writer, "id: %s\ndata: %s\n\n", ev.ID, ev.Data)
+		} else {
+			_, err = fmt.Fprintf(c.Writer, "data: %s\n\n", ev.Data)
+		}
+		if err != nil {
+			return false
+		}
+		pending = true
+		if interval <= 0 {
+			flush()
+		} else if timer == nil {
+			timer = time.NewTimer(interval)
+			timerC = timer.C
+		}
+		return true
+	}
+
+	for {
 		select {
 		case <-c.Request.Context().Done():
 			// Client disconnected, stop processing
-			return false
-		case result, ok := <-streamChan:
+			return
+		case <-timerC:
+			timer, timerC = nil, nil
+			flush()
+		case ev, ok := <-events:
 			if !ok {
-				// channel is closed
-				_, err := io.WriteString(w, "data: [DONE]\n\n")
-				if err != nil {
-					return false
-				}
-				return false
+				return
 			}
-
-			if result.Err != nil {
-				errResp := api.ChatResponse{
-					Choices: []api.Choice{{
-						FinishReason: "error",
-						Error:        &api.ErrorResponse{Message: result.Err.Error()},
-					}},
-				}
-				data, _ := json.Marshal(errResp)
-				_, err := fmt.Fprintf(w, "data: %s\n\n", data)
-				if err != nil {
-					return false
-				}
-				// if there's an error we will stop streaming
-				return false
-			}
-
-			if result.Response != nil {
-				data, err := json.Marshal(result.Response)
-				if err == nil {
-					_, err := fmt.Fprintf(w, "data: %s\n\n", data)
-					return err == nil
-				}
+			if !write(ev) {
+				return
 			}
 		}
+	}
+}
 
-		return true
-	})
+// streamFlushInterval returns the coalescing window for a model. A model
+// override takes precedence over the server default, a negative override
+// disables coalescing, and the window is capped at maxFlushInterval.
+func (h *ChatHandler) streamFlushInterval(modelID string) time.Duration {
+	interval := h.flushInterval
+	if def, ok := h.service.GetModel(modelID); ok && def.Config.StreamFlushInterval != 0 {
+		interval = def.Config.StreamFlushInterval
+	}
+	return min(interval, maxFlushInterval)
 }
@@ -0,0 +1,415 @@
+package v1
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/gateway"
+	"github.com/nulzo/model-router-api/internal/server/middleware"
+	"github.com/nulzo/model-router-api/internal/server/validator"
+	"github.com/nulzo/model-router-api/internal/store/cache"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/internal/store/sqlite"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// streamService streams a fixed set of deltas, all of them ready at once.
+type streamService struct {
+	gateway.Service
+	deltas []string
+	model  api.ModelDefinition
+}
+
+func (s *streamService) ApplyKeySettings(context.Context, *api.ChatRequest) error { return nil }
+
+func (s *streamService) GetModel(id string) (api.ModelDefinition, bool) {
+	return s.model, id == s.model.ID
+}
+
+func (s *streamService) StreamChat(context.Context, *api.ChatRequest) (<-chan api.StreamResult, error) {
+	ch := make(chan api.StreamResult, len(s.deltas))
+	for _, d := range s.deltas {
+		ch <- api.StreamResult{Response: &api.ChatResponse{
+			Choices: []api.Choice{{Delta: &api.ChatMessage{Role: "assistant", Content: api.Content{Text: d}}}},
+		}}
+	}
+	close(ch)
+	return ch, nil
+}
+
+// flushRecorder counts the flushes reaching the client.
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushes int
+}
+
+func (r *flushRecorder) Flush() {
+	r.flushes++
+	r.ResponseRecorder.Flush()
+}
+
+func streamChunks(t *testing.T, svc *streamService, interval time.Duration) (string, int) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.POST("/chat", NewChatHandler(svc, validator.New(), interval, nil, config.StreamResumeConfig{}, nil, 0).CreateCompletion)
+
+	body := `{"model":"mock/model","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(body))
+	w := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var content strings.Builder
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		var chunk api.ChatResponse
+		require.NoError(t, json.Unmarshal([]byte(data), &chunk))
+		content.WriteString(chunk.Choices[0].Delta.Content.Text)
+	}
+	return content.String(), w.flushes
+}
+
+func TestStreamFlushCoalescing(t *testing.T) {
+	deltas := make([]string, 50)
+	for i := range deltas {
+		deltas[i] = "tok "
+	}
+	svc := &streamService{deltas: deltas, model: api.ModelDefinition{ID: "mock/model"}}
+
+	perChunk, perChunkFlushes := streamChunks(t, svc, 0)
+	coalesced, coalescedFlushes := streamChunks(t, svc, 20*time.Millisecond)
+
+	assert.Equal(t, strings.Repeat("tok ", 50), perChunk)
+	assert.Equal(t, perChunk, coalesced)
+	assert.Less(t, coalescedFlushes, perChunkFlushes)
+
+	// a negative model override restores per-chunk flushing
+	svc.model.Config.StreamFlushInterval = -1
+	overridden, overriddenFlushes := streamChunks(t, svc, 20*time.Millisecond)
+	assert.Equal(t, perChunk, overridden)
+	assert.Equal(t, perChunkFlushes, overriddenFlushes)
+}
+
+// sseEvents parses a stream body into its events.
+func sseEvents(t *testing.T, body string) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var ev sseEvent
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if id, ok := strings.CutPrefix(line, "id: "); ok {
+			ev.ID = id
+		} else if data, ok := strings.CutPrefix(line, "data: "); ok {
+			ev.Data = data
+		} else if line == "" && ev.Data != "" {
+			events = append(events, ev)
+			ev = sseEvent{}
+		}
+	}
+	return events
+}
+
+func TestStreamResume(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := &streamService{deltas: []string{"a", "b", "c", "d"}, model: api.ModelDefinition{ID: "mock/model"}}
+	handler := NewChatHandler(svc, validator.New(), 0, cache.NewMemoryCache(), config.StreamResumeConfig{Enabled: true}, nil, 0)
+
+	r := gin.New()
+	r.Use(middleware.ErrorHandler())
+	r.POST("/chat", handler.CreateCompletion)
+
+	body := `{"model":"mock/model","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	events := sseEvents(t, w.Body.String())
+	require.Len(t, events, 5)
+	streamID, _, ok := parseEventID(events[0].ID)
+	require.True(t, ok)
+	for i, ev := range events {
+		assert.Equal(t, eventID(streamID, i+1), ev.ID)
+	}
+	assert.Equal(t, "[DONE]", events[4].Data)
+
+	// reconnecting after the second event replays the rest
+	req := httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(body))
+	req.Header.Set("Last-Event-ID", events[1].ID)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, events[2:], sseEvents(t, w.Body.String()))
+
+	// unknown streams can not be resumed
+	req = httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(body))
+	req.Header.Set("Last-Event-ID", "missing:1")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// countingCache counts the writes reaching the cache.
+type countingCache struct {
+	cache.CacheService
+	mu   sync.Mutex
+	sets int
+}
+
+func (c *countingCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	c.mu.Lock()
+	c.sets++
+	c.mu.Unlock()
+	return c.CacheService.Set(ctx, key, value, ttl)
+}
+
+func TestStreamResume_BatchesBufferWrites(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deltas := make([]string, 100)
+	for i := range deltas {
+		deltas[i] = "x"
+	}
+	c := &countingCache{CacheService: cache.NewMemoryCache()}
+	svc := &streamService{deltas: deltas, model: api.ModelDefinition{ID: "mock/model"}}
+
+	r := gin.New()
+	r.POST("/chat", NewChatHandler(svc, validator.New(), 0, c, config.StreamResumeConfig{Enabled: true}, nil, 0).CreateCompletion)
+
+	body := `{"model":"mock/model","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, sseEvents(t, w.Body.String()), 101)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	assert.Less(t, c.sets, 10, "the buffer is not written on every event")
+}
+
+// fixedProvider answers every chat request with resp.
+type fixedProvider struct {
+	capsProvider
+	resp *api.ChatResponse
+}
+
+func (p *fixedProvider) Chat(context.Context, *api.ChatRequest) (*api.ChatResponse, error) {
+	resp := *p.resp
+	return &resp, nil
+}
+
+// logIngestor keeps the request logs written by the gateway.
+type logIngestor struct{ logs []*model.RequestLog }
+
+func (i *logIngestor) Log(log *model.RequestLog) { i.logs = append(i.logs, log) }
+func (i *logIngestor) Start(context.Context)     {}
+func (i *logIngestor) Stop()                     {}
+
+func TestChatStripsResponseFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo, err := sqlite.NewSQLiteStorage(":memory:", zap.NewNop())
+	require.NoError(t, err)
+	defer repo.Close()
+
+	ingestor := &logIngestor{}
+	svc := gateway.NewService(zap.NewNop(), repo, ingestor, nil, config.GatewayConfig{})
+	require.NoError(t, svc.RegisterProvider(context.Background(), &fixedProvider{
+		capsProvider: capsProvider{id: "mock", models: []api.ModelDefinition{{ID: "mock/model", ProviderID: "mock"}}},
+		resp: &api.ChatResponse{
+			ID:                "resp-1",
+			Object:            "chat.completion",
+			SystemFingerprint: "fp_secret",
+			Choices: []api.Choice{{
+				Message:      &api.ChatMessage{Role: "assistant", Content: api.Content{Text: "Hi there"}},
+				FinishReason: "stop",
+			}},
+			Usage: &api.ResponseUsage{PromptTokens: 5, CompletionTokens: 2, TotalTokens: 7},
+		},
+	}))
+
+	r := gin.New()
+	r.Use(middleware.ErrorHandler())
+	handler := NewChatHandler(svc, validator.New(), 0, nil, config.StreamResumeConfig{}, []string{"system_fingerprint", "usage", "choices.finish_reason"}, 0)
+	r.POST("/chat", handler.CreateCompletion)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/chat",
+		strings.NewReader(`{"model":"mock/model","messages":[{"role":"user","content":"Hi"}]}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.NotContains(t, body, "system_fingerprint")
+	assert.NotContains(t, body, "usage")
+	assert.NotEmpty(t, body["id"])
+	choice := body["choices"].([]any)[0].(map[string]any)
+	assert.NotContains(t, choice, "finish_reason")
+	assert.Contains(t, choice, "message")
+
+	// the internal log keeps what the client no longer sees
+	require.Len(t, ingestor.logs, 1)
+	log := ingestor.logs[0]
+	assert.Equal(t, 5, log.InputTokens)
+	assert.Equal(t, 2, log.OutputTokens)
+	assert.Equal(t, "stop", log.FinishReason)
+}
+
+func TestChatWarnsAboutDeprecatedModel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo, err := sqlite.NewSQLiteStorage(":memory:", zap.NewNop())
+	require.NoError(t, err)
+	defer repo.Close()
+
+	svc := gateway.NewService(zap.NewNop(), repo, &logIngestor{}, nil, config.GatewayConfig{})
+	require.NoError(t, svc.RegisterProvider(context.Background(), &fixedProvider{
+		capsProvider: capsProvider{id: "mock", models: []api.ModelDefinition{{
+			ID:          "mock/old",
+			ProviderID:  "mock",
+			Deprecation: &api.ModelDeprecation{Sunset: "2026-12-01", Replacement: "mock/new"},
+		}}},
+		resp: &api.ChatResponse{Choices: []api.Choice{{
+			Message:      &api.ChatMessage{Role: "assistant", Content: api.Content{Text: "Hi"}},
+			FinishReason: "stop",
+		}}},
+	}))
+
+	r := gin.New()
+	r.Use(middleware.ErrorHandler())
+	handler := NewChatHandler(svc, validator.New(), 0, nil, config.StreamResumeConfig{}, nil, 0)
+	r.POST("/chat", handler.CreateCompletion)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/chat",
+		strings.NewReader(`{"model":"mock/old","messages":[{"role":"user","content":"Hi"}]}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	notice := "model 'mock/old' is deprecated and will be removed on 2026-12-01, use 'mock/new' instead"
+	assert.Equal(t, `299 - "`+notice+`"`, w.Header().Get("Warning"))
+
+	var body api.ChatResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.NotNil(t, body.Deprecation)
+	assert.Equal(t, &api.DeprecationWarning{Model: "mock/old", Notice: notice, Sunset: "2026-12-01", Replacement: "mock/new"}, body.Deprecation)
+}
+
+func TestChatRejectsOversizedMetadata(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.ErrorHandler())
+	r.POST("/chat", NewChatHandler(&streamService{}, validator.New(), 0, nil, config.StreamResumeConfig{}, nil, 0).CreateCompletion)
+
+	for name, metadata := range map[string]string{
+		"key":   `{"` + strings.Repeat("k", 65) + `":"v"}`,
+		"value": `{"k":"` + strings.Repeat("v", 513) + `"}`,
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/chat",
+			strings.NewReader(`{"model":"mock/model","messages":[{"role":"user","content":"Hi"}],"metadata":`+metadata+`}`)))
+		assert.Equal(t, http.StatusBadRequest, w.Code, name)
+	}
+}
+
+func TestChatRejectsTooManyFallbackModels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.ErrorHandler())
+	r.POST("/chat", NewChatHandler(&streamService{}, validator.New(), 0, nil, config.StreamResumeConfig{}, nil, 0).CreateCompletion)
+
+	models := `"mock/model"` + strings.Repeat(`,"mock/model"`, 10)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/chat",
+		strings.NewReader(`{"model":"mock/model","messages":[{"role":"user","content":"Hi"}],"models":[`+models+`]}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// resultService streams fixed results, then leaves the stream open when hold
+// is set.
+type resultService struct {
+	gateway.Service
+	results []api.StreamResult
+	hold    bool
+}
+
+func (s *resultService) ApplyKeySettings(context.Context, *api.ChatRequest) error { return nil }
+
+func (s *resultService) GetModel(string) (api.ModelDefinition, bool) {
+	return api.ModelDefinition{}, false
+}
+
+func (s *resultService) StreamChat(context.Context, *api.ChatRequest) (<-chan api.StreamResult, error) {
+	ch := make(chan api.StreamResult, len(s.results))
+	for _, r := range s.results {
+		ch <- r
+	}
+	if !s.hold {
+		close(ch)
+	}
+	return ch, nil
+}
+
+func TestStreamErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body := `{"model":"mock/model","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	serve := func(svc gateway.Service, firstChunk time.Duration) *httptest.ResponseRecorder {
+		r := gin.New()
+		r.POST("/chat", NewChatHandler(svc, validator.New(), 0, nil, config.StreamResumeConfig{}, nil, firstChunk).CreateCompletion)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(body)))
+		return w
+	}
+	delta := api.StreamResult{Response: &api.ChatResponse{
+		Choices: []api.Choice{{Delta: &api.ChatMessage{Role: "assistant", Content: api.Content{Text: "Hel"}}}},
+	}}
+	unauthorized := api.NewError(http.StatusUnauthorized, "Unauthorized", "invalid provider key")
+
+	t.Run("before the first chunk", func(t *testing.T) {
+		w := serve(&resultService{results: []api.StreamResult{{Err: unauthorized}}}, 0)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+		var problem api.Problem
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+		assert.Equal(t, "invalid provider key", problem.Detail)
+	})
+
+	t.Run("after the first chunk", func(t *testing.T) {
+		w := serve(&resultService{results: []api.StreamResult{delta, {Err: unauthorized}}}, 0)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+		events := sseEvents(t, w.Body.String())
+		require.Len(t, events, 3)
+
+		var chunk api.ChatResponse
+		require.NoError(t, json.Unmarshal([]byte(events[1].Data), &chunk))
+		require.NotNil(t, chunk.Error)
+		assert.Equal(t, float64(http.StatusUnauthorized), chunk.Error.Code)
+		assert.Contains(t, chunk.Error.Message, "invalid provider key")
+		assert.Equal(t, string(api.FinishReasonError), chunk.Choices[0].FinishReason)
+		assert.Equal(t, "[DONE]", events[2].Data)
+	})
+
+	t.Run("no first chunk in time", func(t *testing.T) {
+		w := serve(&resultService{hold: true}, 10*time.Millisecond)
+
+		assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/llm"
 )
 
 type ConfigHandler struct {
@@ -16,11 +17,13 @@ func NewConfigHandler(cfg *config.Config) *ConfigHandler {
 	return &ConfigHandler{config: cfg}
 }
 
-// Get returns the current application configuration.
+// Get returns the current application configuration, along with the
+// effective default base URL of every provider type.
 //
 // GET /config
 func (h *ConfigHandler) Get(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
-		"config": h.config,
+		"config":            h.config,
+		"default_base_urls": llm.DefaultBaseURLs(),
 	})
 }
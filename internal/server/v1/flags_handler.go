@@ -0,0 +1,68 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nulzo/model-router-api/internal/flags"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+type FlagsHandler struct {
+	flags *flags.Flags
+	repo  store.Repository
+}
+
+func NewFlagsHandler(f *flags.Flags, repo store.Repository) *FlagsHandler {
+	return &FlagsHandler{flags: f, repo: repo}
+}
+
+// setFlagRequest is the body of a runtime flag override. With neither key_id
+// nor model it applies to everyone.
+type setFlagRequest struct {
+	KeyID      string `json:"key_id"`
+	Model      string `json:"model"`
+	Enabled    bool   `json:"enabled"`
+	TTLSeconds int    `json:"ttl_seconds"`
+}
+
+// Set overrides a flag at runtime, globally or for one key or model. Admin only.
+// PUT /api/v1/flags/:flag
+func (h *FlagsHandler) Set(c *gin.Context) {
+	if !requireAdmin(c, h.repo, "setting feature flags") {
+		return
+	}
+
+	var req setFlagRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		_ = c.Error(api.BadRequestError(fmt.Sprintf("invalid flag override: %s", err)))
+		return
+	}
+
+	o := flags.Override{Flag: c.Param("flag"), KeyID: req.KeyID, Model: req.Model, Enabled: req.Enabled}
+	if err := h.flags.Set(c.Request.Context(), o, time.Duration(req.TTLSeconds)*time.Second); err != nil {
+		_ = c.Error(api.InternalError("Failed to set feature flag", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, o)
+}
+
+// Clear removes a runtime override, the flag follows the configuration again.
+// Admin only.
+// DELETE /api/v1/flags/:flag?key_id=&model=
+func (h *FlagsHandler) Clear(c *gin.Context) {
+	if !requireAdmin(c, h.repo, "clearing feature flags") {
+		return
+	}
+
+	o := flags.Override{Flag: c.Param("flag"), KeyID: c.Query("key_id"), Model: c.Query("model")}
+	if err := h.flags.Clear(c.Request.Context(), o); err != nil {
+		_ = c.Error(api.InternalError("Failed to clear feature flag", err.Error()))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
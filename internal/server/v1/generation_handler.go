@@ -81,6 +81,12 @@ func mapRequestLogToGenerationResponse(log *model.RequestLog) api.GenerationResp
 		}
 
 		// Update natives with details
+		if log.UsageDetails.NativePromptTokens != nil {
+			data.NativeTokensPrompt = *log.UsageDetails.NativePromptTokens
+		}
+		if log.UsageDetails.NativeCompletionTokens != nil {
+			data.NativeTokensCompletion = *log.UsageDetails.NativeCompletionTokens
+		}
 		data.NativeTokensCached = &log.UsageDetails.PromptTokensCached
 		data.NativeTokensReasoning = &log.UsageDetails.CompletionTokensReasoning
 		
@@ -0,0 +1,48 @@
+package v1
+
+import (
+	"testing"
+
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapGeneration_NativeTokens(t *testing.T) {
+	nativePrompt, nativeCompletion := 20, 50
+	log := &model.RequestLog{
+		ID:           "gen-1",
+		InputTokens:  1120,
+		OutputTokens: 50,
+		UsageDetails: &model.UsageDetails{
+			PromptTokensCached:        1000,
+			CompletionTokensReasoning: 12,
+			NativePromptTokens:        &nativePrompt,
+			NativeCompletionTokens:    &nativeCompletion,
+		},
+	}
+
+	data := mapRequestLogToGenerationResponse(log).Data
+
+	assert.Equal(t, 1120, data.TokensPrompt)
+	assert.Equal(t, 20, data.NativeTokensPrompt)
+	assert.Equal(t, 50, data.NativeTokensCompletion)
+	require.NotNil(t, data.NativeTokensCached)
+	assert.Equal(t, 1000, *data.NativeTokensCached)
+	require.NotNil(t, data.NativeTokensReasoning)
+	assert.Equal(t, 12, *data.NativeTokensReasoning)
+}
+
+func TestMapGeneration_NativeTokensDefaultToStandard(t *testing.T) {
+	log := &model.RequestLog{
+		ID:           "gen-1",
+		InputTokens:  30,
+		OutputTokens: 40,
+		UsageDetails: &model.UsageDetails{},
+	}
+
+	data := mapRequestLogToGenerationResponse(log).Data
+
+	assert.Equal(t, 30, data.NativeTokensPrompt)
+	assert.Equal(t, 40, data.NativeTokensCompletion)
+}
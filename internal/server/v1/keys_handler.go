@@ -0,0 +1,203 @@
+package v1
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+const (
+	defaultKeysLimit = 20
+	maxKeysLimit     = 100
+)
+
+type KeysHandler struct {
+	repo store.Repository
+}
+
+func NewKeysHandler(repo store.Repository) *KeysHandler {
+	return &KeysHandler{repo: repo}
+}
+
+// ListKeys returns a page of API keys. Callers only see their own keys unless
+// the owning user is an admin, in which case all keys (or those of `user_id`)
+// are listed.
+// GET /api/v1/keys?active=true&limit=20&offset=0
+func (h *KeysHandler) ListKeys(c *gin.Context) {
+	caller, ok := c.Request.Context().Value(store.ContextKeyAPIKey).(*model.APIKey)
+	if !ok {
+		_ = c.Error(api.NewError(http.StatusUnauthorized, "Unauthorized", "listing keys requires an API key"))
+		return
+	}
+
+	filter := store.APIKeyFilter{UserID: caller.UserID, Limit: defaultKeysLimit}
+
+	user, err := h.repo.Users().Get(c.Request.Context(), caller.UserID)
+	if err == nil && user.Role == "admin" {
+		filter.UserID = c.Query("user_id")
+	}
+
+	if v := c.Query("active"); v != "" {
+		active, err := strconv.ParseBool(v)
+		if err != nil {
+			_ = c.Error(api.ValidationError(map[string]string{"active": "must be a boolean"}))
+			return
+		}
+		filter.Active = &active
+	}
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxKeysLimit {
+			_ = c.Error(api.ValidationError(map[string]string{"limit": "must be between 1 and 100"}))
+			return
+		}
+		filter.Limit = limit
+	}
+	if v := c.Query("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			_ = c.Error(api.ValidationError(map[string]string{"offset": "must be a non-negative integer"}))
+			return
+		}
+		filter.Offset = offset
+	}
+
+	keys, total, err := h.repo.APIKeys().List(c.Request.Context(), filter)
+	if err != nil {
+		_ = c.Error(api.InternalError("Failed to list API keys", err.Error()))
+		return
+	}
+
+	data := make([]api.APIKey, 0, len(keys))
+	for _, k := range keys {
+		data = append(data, mapAPIKey(k))
+	}
+
+	c.JSON(http.StatusOK, api.APIKeyList{
+		Object: "list",
+		Data:   data,
+		Total:  total,
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+	})
+}
+
+// SetModelPolicy replaces the models an API key may call, matched against
+// model IDs after alias resolution. Admin only.
+// PUT /api/v1/keys/:id/models
+func (h *KeysHandler) SetModelPolicy(c *gin.Context) {
+	if !requireAdmin(c, h.repo, "restricting key models") {
+		return
+	}
+
+	var policy api.APIKeyModelPolicy
+	if err := json.NewDecoder(c.Request.Body).Decode(&policy); err != nil {
+		_ = c.Error(api.BadRequestError(fmt.Sprintf("invalid model policy: %s", err)))
+		return
+	}
+	for _, glob := range slices.Concat(policy.AllowedModels, policy.DeniedModels) {
+		if strings.TrimSpace(glob) == "" {
+			_ = c.Error(api.BadRequestError("model policy entries must not be empty"))
+			return
+		}
+	}
+
+	allowed, _ := json.Marshal(nonNil(policy.AllowedModels))
+	denied, _ := json.Marshal(nonNil(policy.DeniedModels))
+	id := c.Param("id")
+	if err := h.repo.APIKeys().UpdateModelPolicy(c.Request.Context(), id, string(allowed), string(denied)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			_ = c.Error(api.NewError(http.StatusNotFound, "Key Not Found", fmt.Sprintf("no API key with id '%s'", id)))
+			return
+		}
+		_ = c.Error(api.InternalError("Failed to update API key", err.Error()))
+		return
+	}
+
+	key, err := h.repo.APIKeys().Get(c.Request.Context(), id)
+	if err != nil {
+		_ = c.Error(api.InternalError("Failed to load API key", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, mapAPIKey(*key))
+}
+
+// SetPriority sets the priority an API key's requests wait for provider
+// slots with, higher first. Admin only.
+// PUT /api/v1/keys/:id/priority
+func (h *KeysHandler) SetPriority(c *gin.Context) {
+	if !requireAdmin(c, h.repo, "setting key priority") {
+		return
+	}
+
+	var req api.APIKeyPriority
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		_ = c.Error(api.BadRequestError(fmt.Sprintf("invalid key priority: %s", err)))
+		return
+	}
+	if req.Priority == nil {
+		_ = c.Error(api.ValidationError(map[string]string{"priority": "is required"}))
+		return
+	}
+
+	id := c.Param("id")
+	if err := h.repo.APIKeys().UpdatePriority(c.Request.Context(), id, *req.Priority); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			_ = c.Error(api.NewError(http.StatusNotFound, "Key Not Found", fmt.Sprintf("no API key with id '%s'", id)))
+			return
+		}
+		_ = c.Error(api.InternalError("Failed to update API key", err.Error()))
+		return
+	}
+
+	key, err := h.repo.APIKeys().Get(c.Request.Context(), id)
+	if err != nil {
+		_ = c.Error(api.InternalError("Failed to load API key", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, mapAPIKey(*key))
+}
+
+// nonNil stores an absent list as an empty JSON array rather than null.
+func nonNil(globs []string) []string {
+	if globs == nil {
+		return []string{}
+	}
+	return globs
+}
+
+func mapAPIKey(k model.APIKey) api.APIKey {
+	out := api.APIKey{
+		ID:        k.ID,
+		UserID:    k.UserID,
+		Name:      k.Name,
+		KeyPrefix: k.KeyPrefix,
+		Scopes:    k.Scopes,
+		IsActive:  k.IsActive,
+		Priority:  k.Priority,
+		CreatedAt: k.CreatedAt,
+	}
+
+	if k.ExpiresAt.Valid {
+		out.ExpiresAt = &k.ExpiresAt.Time
+	}
+	if k.LastUsedAt.Valid {
+		out.LastUsedAt = &k.LastUsedAt.Time
+	}
+	if k.MonthlyLimitMicros.Valid {
+		out.MonthlyLimitMicros = &k.MonthlyLimitMicros.Int64
+	}
+	out.AllowedModels, out.DeniedModels, _ = k.ModelPolicy()
+
+	return out
+}
@@ -0,0 +1,203 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nulzo/model-router-api/internal/server/middleware"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/internal/store/sqlite"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func seedKeys(t *testing.T, repo store.Repository) (caller, admin *model.APIKey) {
+	ctx := context.Background()
+	now := time.Now()
+
+	for _, u := range []model.User{
+		{ID: "user-1", Email: "one@example.com", Name: "One", Role: "user"},
+		{ID: "user-2", Email: "two@example.com", Name: "Two", Role: "user"},
+		{ID: "admin", Email: "admin@example.com", Name: "Admin", Role: "admin"},
+	} {
+		u.CreatedAt, u.UpdatedAt = now, now
+		require.NoError(t, repo.Users().Create(ctx, &u))
+	}
+
+	keys := []*model.APIKey{
+		{ID: "key-1", UserID: "user-1", Name: "active", KeyHash: "h1", KeyPrefix: "sk-1", IsActive: true},
+		{ID: "key-2", UserID: "user-1", Name: "revoked", KeyHash: "h2", KeyPrefix: "sk-2", IsActive: false},
+		{ID: "key-3", UserID: "user-1", Name: "active too", KeyHash: "h3", KeyPrefix: "sk-3", IsActive: true},
+		{ID: "key-4", UserID: "user-2", Name: "other user", KeyHash: "h4", KeyPrefix: "sk-4", IsActive: true},
+		{ID: "key-5", UserID: "admin", Name: "admin", KeyHash: "h5", KeyPrefix: "sk-5", IsActive: true},
+	}
+	for i, k := range keys {
+		k.CreatedAt = now.Add(time.Duration(i) * time.Second)
+		k.UpdatedAt = k.CreatedAt
+		require.NoError(t, repo.APIKeys().Create(ctx, k))
+	}
+
+	return keys[0], keys[4]
+}
+
+func listKeys(t *testing.T, repo store.Repository, caller *model.APIKey, query string) (int, api.APIKeyList, string) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.ErrorHandler())
+	r.Use(func(c *gin.Context) {
+		if caller != nil {
+			c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), store.ContextKeyAPIKey, caller))
+		}
+	})
+	r.GET("/api/v1/keys", NewKeysHandler(repo).ListKeys)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/keys"+query, nil))
+
+	var out api.APIKeyList
+	if w.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &out))
+	}
+	return w.Code, out, w.Body.String()
+}
+
+func keyIDs(list api.APIKeyList) []string {
+	ids := make([]string, 0, len(list.Data))
+	for _, k := range list.Data {
+		ids = append(ids, k.ID)
+	}
+	return ids
+}
+
+func TestListKeys_ActiveVsAll(t *testing.T) {
+	repo, err := sqlite.NewSQLiteStorage(":memory:", zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = repo.Close() })
+	caller, admin := seedKeys(t, repo)
+
+	code, all, body := listKeys(t, repo, caller, "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 3, all.Total)
+	assert.Equal(t, []string{"key-3", "key-2", "key-1"}, keyIDs(all))
+	assert.NotContains(t, body, "key_hash")
+	assert.NotContains(t, body, "h1")
+
+	code, active, _ := listKeys(t, repo, caller, "?active=true")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 2, active.Total)
+	assert.Equal(t, []string{"key-3", "key-1"}, keyIDs(active))
+
+	code, page, _ := listKeys(t, repo, caller, "?limit=1&offset=1")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 3, page.Total)
+	assert.Equal(t, []string{"key-2"}, keyIDs(page))
+
+	// a non admin cannot widen the scope
+	_, scoped, _ := listKeys(t, repo, caller, "?user_id=user-2")
+	assert.Equal(t, 3, scoped.Total)
+
+	code, everything, _ := listKeys(t, repo, admin, "?active=true")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 4, everything.Total)
+
+	code, _, _ = listKeys(t, repo, caller, "?limit=500")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _, _ = listKeys(t, repo, nil, "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+func setModelPolicy(t *testing.T, repo store.Repository, caller *model.APIKey, keyID, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.ErrorHandler())
+	r.Use(func(c *gin.Context) {
+		if caller != nil {
+			c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), store.ContextKeyAPIKey, caller))
+		}
+	})
+	r.PUT("/api/v1/keys/:id/models", NewKeysHandler(repo).SetModelPolicy)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/api/v1/keys/"+keyID+"/models", strings.NewReader(body)))
+	return w
+}
+
+func TestSetModelPolicy(t *testing.T) {
+	repo, err := sqlite.NewSQLiteStorage(":memory:", zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = repo.Close() })
+	caller, admin := seedKeys(t, repo)
+
+	w := setModelPolicy(t, repo, admin, "key-1", `{"allowed_models": ["ollama/*", "openai/gpt-4o-mini"], "denied_models": ["ollama/llama3:70b"]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var out api.APIKey
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &out))
+	assert.Equal(t, []string{"ollama/*", "openai/gpt-4o-mini"}, out.AllowedModels)
+	assert.Equal(t, []string{"ollama/llama3:70b"}, out.DeniedModels)
+
+	// the policy is stored on the key row the auth middleware loads
+	stored, err := repo.APIKeys().GetByHash(context.Background(), "h1")
+	require.NoError(t, err)
+	allowed, denied, err := stored.ModelPolicy()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ollama/*", "openai/gpt-4o-mini"}, allowed)
+	assert.Equal(t, []string{"ollama/llama3:70b"}, denied)
+
+	// an empty policy lifts the restriction
+	w = setModelPolicy(t, repo, admin, "key-1", `{}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	stored, err = repo.APIKeys().GetByHash(context.Background(), "h1")
+	require.NoError(t, err)
+	assert.Equal(t, "[]", stored.AllowedModels)
+
+	assert.Equal(t, http.StatusNotFound, setModelPolicy(t, repo, admin, "missing", `{}`).Code)
+	assert.Equal(t, http.StatusBadRequest, setModelPolicy(t, repo, admin, "key-1", `{"allowed_models": [""]}`).Code)
+	assert.Equal(t, http.StatusForbidden, setModelPolicy(t, repo, caller, "key-1", `{}`).Code)
+}
+
+func TestSetPriority(t *testing.T) {
+	repo, err := sqlite.NewSQLiteStorage(":memory:", zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = repo.Close() })
+	caller, admin := seedKeys(t, repo)
+
+	set := func(caller *model.APIKey, keyID, body string) *httptest.ResponseRecorder {
+		gin.SetMode(gin.TestMode)
+		r := gin.New()
+		r.Use(middleware.ErrorHandler())
+		r.Use(func(c *gin.Context) {
+			if caller != nil {
+				c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), store.ContextKeyAPIKey, caller))
+			}
+		})
+		r.PUT("/api/v1/keys/:id/priority", NewKeysHandler(repo).SetPriority)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/api/v1/keys/"+keyID+"/priority", strings.NewReader(body)))
+		return w
+	}
+
+	w := set(admin, "key-1", `{"priority": 2}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var out api.APIKey
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &out))
+	assert.Equal(t, 2, out.Priority)
+
+	stored, err := repo.APIKeys().GetByHash(context.Background(), "h1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, stored.Priority)
+
+	assert.Equal(t, http.StatusBadRequest, set(admin, "key-1", `{}`).Code)
+	assert.Equal(t, http.StatusNotFound, set(admin, "missing", `{"priority": 1}`).Code)
+	assert.Equal(t, http.StatusForbidden, set(caller, "key-1", `{"priority": 1}`).Code)
+}
@@ -0,0 +1,508 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/nulzo/model-router-api/internal/gateway"
+	"github.com/nulzo/model-router-api/internal/server/validator"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+// MessagesHandler serves the Anthropic Messages API. Requests are translated
+// to the unified chat request, routed through the gateway like any other, and
+// the response (or stream) is encoded back into Anthropic's shapes.
+type MessagesHandler struct {
+	service    gateway.Service
+	validator  *validator.Validator
+	firstChunk time.Duration
+}
+
+// NewMessagesHandler creates a messages handler. Streams sending nothing
+// within firstChunk fail with a 504, zero waits forever.
+func NewMessagesHandler(service gateway.Service, v *validator.Validator, firstChunk time.Duration) *MessagesHandler {
+	return &MessagesHandler{
+		service:    service,
+		validator:  v,
+		firstChunk: firstChunk,
+	}
+}
+
+func (h *MessagesHandler) CreateMessage(c *gin.Context) {
+	var msgReq api.MessagesRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&msgReq); err != nil {
+		messagesError(c, api.ValidationError(h.validator.ParseError(err)))
+		return
+	}
+	if err := binding.Validator.ValidateStruct(&msgReq); err != nil {
+		messagesError(c, api.ValidationError(h.validator.ParseError(err)))
+		return
+	}
+
+	req := toChatRequest(&msgReq)
+	if err := h.service.ApplyKeySettings(c.Request.Context(), req); err != nil {
+		messagesError(c, err)
+		return
+	}
+
+	if req.Stream {
+		h.handleStream(c, req)
+		return
+	}
+
+	resp, err := h.service.Chat(c.Request.Context(), req)
+	if err != nil {
+		messagesError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toMessagesResponse(resp))
+}
+
+func (h *MessagesHandler) handleStream(c *gin.Context, req *api.ChatRequest) {
+	ctx, cancel := context.WithCancelCause(c.Request.Context())
+	defer cancel(nil)
+
+	streamChan, err := h.service.StreamChat(ctx, req)
+	if err == nil {
+		streamChan, err = openStream(ctx, streamChan, h.firstChunk)
+	}
+	if err != nil {
+		cancel(err)
+		messagesError(c, err)
+		return
+	}
+
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Writer.Header().Set("X-Accel-Buffering", "no")
+
+	c.Writer.WriteHeader(http.StatusOK)
+	c.Writer.Flush()
+
+	enc := &messagesEncoder{model: req.Model, write: func(event api.MessagesStreamEvent) bool {
+		data, _ := json.Marshal(event)
+		if _, err := fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+			return false
+		}
+		c.Writer.Flush()
+		return true
+	}}
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case result, ok := <-streamChan:
+			if !ok {
+				enc.finish()
+				return
+			}
+			if result.Err != nil {
+				enc.fail(result.Err)
+				return
+			}
+			if resp := decodedChunk(result); resp != nil && !enc.add(resp) {
+				return
+			}
+		}
+	}
+}
+
+// decodedChunk returns the chunk to re-encode. Passthrough chunks only carry
+// a tap of the upstream chunk in Response, their raw bytes are decoded
+// instead.
+func decodedChunk(result api.StreamResult) *api.ChatResponse {
+	if result.Raw == nil {
+		return result.Response
+	}
+	var resp api.ChatResponse
+	if err := json.Unmarshal(result.Raw, &resp); err != nil {
+		return nil
+	}
+	return &resp
+}
+
+// messagesEncoder re-encodes unified stream chunks as Anthropic events:
+// message_start, then a content_block_start/delta/stop run per text, thinking
+// or tool_use block, and finally message_delta and message_stop.
+type messagesEncoder struct {
+	model string
+	write func(api.MessagesStreamEvent) bool
+
+	started    bool
+	block      int    // index of the open block, -1 before the first one
+	blockType  string // type of the open block, empty when none is open
+	toolCall   int    // unified index of the tool call feeding the open block
+	stopReason string
+	usage      *api.ResponseUsage
+}
+
+func (e *messagesEncoder) start(resp *api.ChatResponse) bool {
+	e.started, e.block = true, -1
+	model := resp.Model
+	if model == "" {
+		model = e.model
+	}
+	return e.write(api.MessagesStreamEvent{
+		Type: "message_start",
+		Message: &api.MessagesResponse{
+			ID:      resp.ID,
+			Type:    "message",
+			Role:    "assistant",
+			Model:   model,
+			Content: []api.MessagesBlock{},
+		},
+	})
+}
+
+// open starts a new content block, closing the current one first.
+func (e *messagesEncoder) open(block api.MessagesBlock) bool {
+	if !e.close() {
+		return false
+	}
+	e.block++
+	e.blockType = block.Type
+	index := e.block
+	return e.write(api.MessagesStreamEvent{Type: "content_block_start", Index: &index, ContentBlock: &block})
+}
+
+func (e *messagesEncoder) close() bool {
+	if e.blockType == "" {
+		return true
+	}
+	e.blockType = ""
+	index := e.block
+	return e.write(api.MessagesStreamEvent{Type: "content_block_stop", Index: &index})
+}
+
+func (e *messagesEncoder) delta(delta api.MessagesDelta) bool {
+	index := e.block
+	return e.write(api.MessagesStreamEvent{Type: "content_block_delta", Index: &index, Delta: &delta})
+}
+
+func (e *messagesEncoder) add(resp *api.ChatResponse) bool {
+	if !e.started && !e.start(resp) {
+		return false
+	}
+	if resp.Usage != nil {
+		e.usage = resp.Usage
+	}
+
+	for _, choice := range resp.Choices {
+		if choice.FinishReason != "" {
+			e.stopReason = stopReason(choice.FinishReason)
+		}
+		d := choice.Delta
+		if d == nil {
+			continue
+		}
+
+		if d.Reasoning != "" {
+			if e.blockType != "thinking" && !e.open(api.MessagesBlock{Type: "thinking", Thinking: new(string)}) {
+				return false
+			}
+			if !e.delta(api.MessagesDelta{Type: "thinking_delta", Thinking: d.Reasoning}) {
+				return false
+			}
+		}
+
+		if text := contentText(d.Content); text != "" {
+			if e.blockType != "text" && !e.open(api.MessagesBlock{Type: "text", Text: new(string)}) {
+				return false
+			}
+			if !e.delta(api.MessagesDelta{Type: "text_delta", Text: text}) {
+				return false
+			}
+		}
+
+		for _, tc := range d.ToolCalls {
+			index := e.toolCall
+			if tc.Index != nil {
+				index = *tc.Index
+			}
+			if e.blockType != "tool_use" || index != e.toolCall || tc.ID != "" {
+				e.toolCall = index
+				block := api.MessagesBlock{Type: "tool_use", ID: tc.ID, Name: tc.Function.Name, Input: json.RawMessage("{}")}
+				if !e.open(block) {
+					return false
+				}
+			}
+			if tc.Function.Arguments != "" {
+				args := tc.Function.Arguments
+				if !e.delta(api.MessagesDelta{Type: "input_json_delta", PartialJSON: &args}) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// finish closes the open block and ends the message.
+func (e *messagesEncoder) finish() {
+	if !e.started && !e.start(&api.ChatResponse{}) {
+		return
+	}
+	if !e.close() {
+		return
+	}
+
+	stop := e.stopReason
+	if stop == "" {
+		stop = "end_turn"
+	}
+	usage := toMessagesUsage(e.usage)
+	if !e.write(api.MessagesStreamEvent{Type: "message_delta", Delta: &api.MessagesDelta{StopReason: &stop}, Usage: &usage}) {
+		return
+	}
+	e.write(api.MessagesStreamEvent{Type: "message_stop"})
+}
+
+func (e *messagesEncoder) fail(err error) {
+	e.write(api.MessagesStreamEvent{Type: "error", Error: &api.MessagesError{Type: "api_error", Message: err.Error()}})
+}
+
+// toChatRequest converts an Anthropic Messages request to the unified shape.
+// Tool results, which Anthropic sends inside user turns, become tool messages.
+func toChatRequest(req *api.MessagesRequest) *api.ChatRequest {
+	out := &api.ChatRequest{
+		Model:       req.Model,
+		MaxTokens:   req.MaxTokens,
+		Stream:      req.Stream,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		TopK:        req.TopK,
+	}
+	if req.Stream {
+		out.StreamOptions = &api.StreamOptions{IncludeUsage: true}
+	}
+	if len(req.StopSequences) > 0 {
+		out.Stop = &api.Stop{Val: req.StopSequences}
+	}
+	if req.Metadata != nil {
+		out.User = req.Metadata.UserID
+	}
+
+	if system := messagesText(req.System); system != "" {
+		out.Messages = append(out.Messages, api.ChatMessage{Role: "system", Content: api.Content{Text: system}})
+	}
+
+	for _, m := range req.Messages {
+		if m.Content.Blocks == nil {
+			out.Messages = append(out.Messages, api.ChatMessage{Role: m.Role, Content: api.Content{Text: m.Content.Text}})
+			continue
+		}
+
+		msg := api.ChatMessage{Role: m.Role}
+		var parts []api.ContentPart
+		for _, b := range m.Content.Blocks {
+			switch b.Type {
+			case "text":
+				if b.Text != nil {
+					parts = append(parts, api.ContentPart{Type: "text", Text: *b.Text})
+				}
+			case "thinking":
+				if b.Thinking != nil {
+					msg.Reasoning += *b.Thinking
+				}
+			case "image":
+				if url := imageURL(b.Source); url != "" {
+					parts = append(parts, api.ContentPart{Type: "image_url", ImageURL: &api.ImageURL{URL: url}})
+				}
+			case "tool_use":
+				args := string(b.Input)
+				if args == "" {
+					args = "{}"
+				}
+				msg.ToolCalls = append(msg.ToolCalls, api.ToolCall{
+					ID:       b.ID,
+					Type:     "function",
+					Function: api.FunctionCall{Name: b.Name, Arguments: args},
+				})
+			case "tool_result":
+				result := ""
+				if b.Content != nil {
+					result = messagesText(*b.Content)
+				}
+				out.Messages = append(out.Messages, api.ChatMessage{Role: "tool", ToolCallID: b.ToolUseID, Content: api.Content{Text: result}})
+			}
+		}
+
+		switch {
+		case len(parts) == 1 && parts[0].Type == "text":
+			msg.Content.Text = parts[0].Text
+		case len(parts) > 0:
+			msg.Content.Parts = parts
+		case len(msg.ToolCalls) == 0 && msg.Reasoning == "":
+			continue // only tool results
+		}
+		out.Messages = append(out.Messages, msg)
+	}
+
+	for _, t := range req.Tools {
+		out.Tools = append(out.Tools, api.Tool{
+			Type:     "function",
+			Function: api.FunctionDescription{Name: t.Name, Description: t.Description, Parameters: t.InputSchema},
+		})
+	}
+	if req.ToolChoice != nil {
+		switch req.ToolChoice.Type {
+		case "auto", "none":
+			out.ToolChoice = req.ToolChoice.Type
+		case "any":
+			out.ToolChoice = "required"
+		case "tool":
+			out.ToolChoice = map[string]interface{}{
+				"type":     "function",
+				"function": map[string]interface{}{"name": req.ToolChoice.Name},
+			}
+		}
+	}
+
+	return out
+}
+
+// toMessagesResponse converts a unified response to an Anthropic message.
+func toMessagesResponse(resp *api.ChatResponse) *api.MessagesResponse {
+	out := &api.MessagesResponse{
+		ID:      resp.ID,
+		Type:    "message",
+		Role:    "assistant",
+		Model:   resp.Model,
+		Content: []api.MessagesBlock{},
+		Usage:   toMessagesUsage(resp.Usage),
+	}
+
+	stop := "end_turn"
+	if len(resp.Choices) > 0 {
+		choice := resp.Choices[0]
+		stop = stopReason(choice.FinishReason)
+		if m := choice.Message; m != nil {
+			if m.Reasoning != "" {
+				out.Content = append(out.Content, api.MessagesBlock{Type: "thinking", Thinking: &m.Reasoning})
+			}
+			if text := contentText(m.Content); text != "" {
+				out.Content = append(out.Content, api.MessagesBlock{Type: "text", Text: &text})
+			}
+			for _, tc := range m.ToolCalls {
+				input := json.RawMessage(tc.Function.Arguments)
+				if !json.Valid(input) {
+					input = json.RawMessage("{}")
+				}
+				out.Content = append(out.Content, api.MessagesBlock{Type: "tool_use", ID: tc.ID, Name: tc.Function.Name, Input: input})
+			}
+		}
+	}
+	out.StopReason = &stop
+
+	return out
+}
+
+// toMessagesUsage converts unified usage to Anthropic's, whose input_tokens
+// exclude the cached part of the prompt.
+func toMessagesUsage(u *api.ResponseUsage) api.MessagesUsage {
+	if u == nil {
+		return api.MessagesUsage{}
+	}
+	usage := api.MessagesUsage{InputTokens: u.PromptTokens, OutputTokens: u.CompletionTokens}
+	if d := u.PromptTokensDetails; d != nil {
+		usage.CacheReadInputTokens = d.CachedTokens
+		usage.CacheCreationInputTokens = d.CacheWriteTokens
+		usage.InputTokens = max(u.PromptTokens-d.CachedTokens-d.CacheWriteTokens, 0)
+	}
+	return usage
+}
+
+// stopReason maps an OpenAI finish_reason to the Anthropic stop_reason.
+func stopReason(finishReason string) string {
+	switch api.FinishReason(finishReason) {
+	case api.FinishReasonToolCalls:
+		return "tool_use"
+	case api.FinishReasonLength, api.FinishReasonMaxCost, api.FinishReasonMaxDuration:
+		return "max_tokens"
+	case api.FinishReasonContentFilter:
+		return "refusal"
+	}
+	return "end_turn"
+}
+
+func contentText(c api.Content) string {
+	if c.Parts == nil {
+		return c.Text
+	}
+	var sb strings.Builder
+	for _, p := range c.Parts {
+		if p.Type == "text" {
+			sb.WriteString(p.Text)
+		}
+	}
+	return sb.String()
+}
+
+func messagesText(c api.MessagesContent) string {
+	if c.Blocks == nil {
+		return c.Text
+	}
+	var texts []string
+	for _, b := range c.Blocks {
+		if b.Type == "text" && b.Text != nil {
+			texts = append(texts, *b.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+func imageURL(src *api.MessagesImageSource) string {
+	if src == nil {
+		return ""
+	}
+	if src.Type == "url" {
+		return src.URL
+	}
+	return fmt.Sprintf("data:%s;base64,%s", src.MediaType, src.Data)
+}
+
+// messagesError writes err in Anthropic's error shape, which its SDKs parse
+// instead of problem details.
+func messagesError(c *gin.Context, err error) {
+	status, message := http.StatusInternalServerError, err.Error()
+	var problem *api.Problem
+	if errors.As(err, &problem) {
+		status = problem.Status
+		if problem.Detail != "" {
+			message = problem.Detail
+		}
+	}
+
+	errType := "api_error"
+	switch status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		errType = "invalid_request_error"
+	case http.StatusUnauthorized:
+		errType = "authentication_error"
+	case http.StatusForbidden:
+		errType = "permission_error"
+	case http.StatusNotFound:
+		errType = "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		errType = "request_too_large"
+	case http.StatusTooManyRequests:
+		errType = "rate_limit_error"
+	case http.StatusServiceUnavailable, 529:
+		errType = "overloaded_error"
+	}
+
+	c.AbortWithStatusJSON(status, api.MessagesErrorResponse{
+		Type:  "error",
+		Error: api.MessagesError{Type: errType, Message: message},
+	})
+}
@@ -0,0 +1,169 @@
+package v1
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nulzo/model-router-api/internal/gateway"
+	"github.com/nulzo/model-router-api/internal/server/validator"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// messagesService replays fixed stream chunks and records the request.
+type messagesService struct {
+	gateway.Service
+	chunks []*api.ChatResponse
+	raw    []string // passthrough chunks, sent after chunks
+	req    *api.ChatRequest
+}
+
+func (s *messagesService) ApplyKeySettings(context.Context, *api.ChatRequest) error { return nil }
+
+func (s *messagesService) StreamChat(_ context.Context, req *api.ChatRequest) (<-chan api.StreamResult, error) {
+	s.req = req
+	ch := make(chan api.StreamResult, len(s.chunks)+len(s.raw))
+	for _, c := range s.chunks {
+		ch <- api.StreamResult{Response: c}
+	}
+	for _, raw := range s.raw {
+		ch <- api.StreamResult{Response: &api.ChatResponse{}, Raw: []byte(raw)}
+	}
+	close(ch)
+	return ch, nil
+}
+
+func TestMessagesStreamEmitsAnthropicEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	zero := 0
+	svc := &messagesService{chunks: []*api.ChatResponse{
+		{ID: "msg-1", Model: "mock/model", Choices: []api.Choice{{Delta: &api.ChatMessage{Role: "assistant", Content: api.Content{Text: "Hel"}}}}},
+		{ID: "msg-1", Choices: []api.Choice{{Delta: &api.ChatMessage{Content: api.Content{Text: "lo"}}}}},
+		{ID: "msg-1", Choices: []api.Choice{{Delta: &api.ChatMessage{ToolCalls: []api.ToolCall{
+			{Index: &zero, ID: "call-1", Type: "function", Function: api.FunctionCall{Name: "get_weather"}},
+		}}}}},
+		{ID: "msg-1", Choices: []api.Choice{{Delta: &api.ChatMessage{ToolCalls: []api.ToolCall{
+			{Index: &zero, Function: api.FunctionCall{Arguments: `{"city":"Paris"}`}},
+		}}}}},
+		{ID: "msg-1", Choices: []api.Choice{{Delta: &api.ChatMessage{}, FinishReason: "tool_calls"}},
+			Usage: &api.ResponseUsage{PromptTokens: 12, CompletionTokens: 7, TotalTokens: 19}},
+	}}
+
+	r := gin.New()
+	r.POST("/messages", NewMessagesHandler(svc, validator.New(), 0).CreateMessage)
+
+	body := `{"model":"mock/model","max_tokens":256,"stream":true,"system":"Be brief.",
+		"messages":[{"role":"user","content":[{"type":"text","text":"Weather in Paris?"}]}]}`
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// the request reaches the gateway in the unified shape
+	require.NotNil(t, svc.req)
+	assert.Equal(t, 256, svc.req.MaxTokens)
+	require.Len(t, svc.req.Messages, 2)
+	assert.Equal(t, "system", svc.req.Messages[0].Role)
+	assert.Equal(t, "Be brief.", svc.req.Messages[0].Content.Text)
+	assert.Equal(t, "Weather in Paris?", svc.req.Messages[1].Content.Text)
+
+	var names []string
+	var events []api.MessagesStreamEvent
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			names = append(names, name)
+			continue
+		}
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			var event api.MessagesStreamEvent
+			require.NoError(t, json.Unmarshal([]byte(data), &event))
+			events = append(events, event)
+		}
+	}
+
+	assert.Equal(t, []string{
+		"message_start",
+		"content_block_start", "content_block_delta", "content_block_delta", "content_block_stop",
+		"content_block_start", "content_block_delta", "content_block_stop",
+		"message_delta",
+		"message_stop",
+	}, names)
+	require.Len(t, events, len(names))
+	for i, event := range events {
+		assert.Equal(t, names[i], event.Type)
+	}
+
+	assert.Equal(t, "msg-1", events[0].Message.ID)
+	assert.Equal(t, "mock/model", events[0].Message.Model)
+
+	assert.Equal(t, 0, *events[1].Index)
+	assert.Equal(t, "text", events[1].ContentBlock.Type)
+	assert.Equal(t, "Hel", events[2].Delta.Text)
+	assert.Equal(t, "lo", events[3].Delta.Text)
+
+	assert.Equal(t, 1, *events[5].Index)
+	assert.Equal(t, "tool_use", events[5].ContentBlock.Type)
+	assert.Equal(t, "call-1", events[5].ContentBlock.ID)
+	assert.Equal(t, "get_weather", events[5].ContentBlock.Name)
+	assert.Equal(t, "input_json_delta", events[6].Delta.Type)
+	assert.Equal(t, `{"city":"Paris"}`, *events[6].Delta.PartialJSON)
+
+	assert.Equal(t, "tool_use", *events[8].Delta.StopReason)
+	assert.Equal(t, 12, events[8].Usage.InputTokens)
+	assert.Equal(t, 7, events[8].Usage.OutputTokens)
+}
+
+func TestMessagesStreamDecodesPassthroughChunks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := &messagesService{raw: []string{
+		`{"id":"msg-1","model":"mock/model","choices":[{"index":0,"delta":{"role":"assistant","content":"Hi"}}]}`,
+		`{"id":"msg-1","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+	}}
+
+	r := gin.New()
+	r.POST("/messages", NewMessagesHandler(svc, validator.New(), 0).CreateMessage)
+
+	body := `{"model":"mock/model","max_tokens":256,"stream":true,"messages":[{"role":"user","content":"Hi"}]}`
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var deltas []string
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			var event api.MessagesStreamEvent
+			require.NoError(t, json.Unmarshal([]byte(data), &event))
+			if event.Type == "content_block_delta" {
+				deltas = append(deltas, event.Delta.Text)
+			}
+		}
+	}
+	assert.Equal(t, []string{"Hi"}, deltas)
+}
+
+func TestMessagesStreamErrorBeforeFirstChunk(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := &resultService{results: []api.StreamResult{{Err: api.NewError(http.StatusTooManyRequests, "Rate Limited", "slow down")}}}
+
+	r := gin.New()
+	r.POST("/messages", NewMessagesHandler(svc, validator.New(), 0).CreateMessage)
+
+	body := `{"model":"mock/model","max_tokens":256,"stream":true,"messages":[{"role":"user","content":"Hi"}]}`
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	var resp api.MessagesErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "rate_limit_error", resp.Error.Type)
+	assert.Equal(t, "slow down", resp.Error.Message)
+}
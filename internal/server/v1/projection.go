@@ -0,0 +1,68 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// responseProjection removes configured fields from the responses sent to
+// clients. Fields are JSON paths separated by dots, e.g. "usage" or
+// "choices.logprobs", arrays along the way apply to each of their elements.
+// The gateway logs the response before it is projected.
+type responseProjection struct {
+	paths [][]string
+}
+
+// newResponseProjection returns nil when no fields are stripped.
+func newResponseProjection(fields []string) *responseProjection {
+	if len(fields) == 0 {
+		return nil
+	}
+	p := &responseProjection{}
+	for _, f := range fields {
+		p.paths = append(p.paths, strings.Split(f, "."))
+	}
+	return p
+}
+
+// apply strips the fields from an encoded response. Data that is not a JSON
+// object, such as the [DONE] marker, is returned unchanged.
+func (p *responseProjection) apply(data []byte) []byte {
+	if p == nil {
+		return data
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber() // keep large integers intact
+	var v map[string]any
+	if err := dec.Decode(&v); err != nil {
+		return data
+	}
+	for _, path := range p.paths {
+		strip(v, path)
+	}
+
+	out, err := json.Marshal(v)
+	if err != nil {
+		return data
+	}
+	return out
+}
+
+func strip(v any, path []string) {
+	switch v := v.(type) {
+	case map[string]any:
+		if len(path) == 1 {
+			delete(v, path[0])
+			return
+		}
+		if next, ok := v[path[0]]; ok {
+			strip(next, path[1:])
+		}
+	case []any:
+		for _, elem := range v {
+			strip(elem, path)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nulzo/model-router-api/internal/gateway"
+	"github.com/nulzo/model-router-api/internal/store"
+)
+
+type ProviderHealthHandler struct {
+	service gateway.Service
+	repo    store.Repository
+}
+
+func NewProviderHealthHandler(service gateway.Service, repo store.Repository) *ProviderHealthHandler {
+	return &ProviderHealthHandler{service: service, repo: repo}
+}
+
+// List returns the cached health of every provider, as seen by the router.
+// Admin only, as it carries upstream error messages.
+// GET /api/v1/health/providers
+func (h *ProviderHealthHandler) List(c *gin.Context) {
+	if !requireAdmin(c, h.repo, "listing provider health") {
+		return
+	}
+
+	statuses := h.service.HealthStatus()
+
+	c.JSON(http.StatusOK, gin.H{
+		"object": "list",
+		"data":   statuses,
+	})
+}
@@ -0,0 +1,100 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/gateway"
+	"github.com/nulzo/model-router-api/internal/platform/logger"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+type ProvidersHandler struct {
+	service   gateway.Service
+	repo      store.Repository
+	providers []config.ProviderConfig
+}
+
+func NewProvidersHandler(service gateway.Service, repo store.Repository, providers []config.ProviderConfig) *ProvidersHandler {
+	return &ProvidersHandler{service: service, repo: repo, providers: providers}
+}
+
+// List returns the configured providers in priority order, followed by those
+// registered at runtime, with their last health check. Admin only.
+// GET /api/v1/providers
+func (h *ProvidersHandler) List(c *gin.Context) {
+	if !requireAdmin(c, h.repo, "listing providers") {
+		return
+	}
+
+	registered := h.service.Capabilities()
+	isRegistered := make(map[string]bool, len(registered))
+	for _, p := range registered {
+		isRegistered[p.ID] = true
+	}
+	health := make(map[string]gateway.ProviderHealth)
+	for _, status := range h.service.HealthStatus() {
+		health[status.ProviderID] = status
+	}
+
+	data := make([]api.Provider, 0, len(h.providers)+len(registered))
+	seen := make(map[string]bool, len(h.providers))
+	for i, p := range h.providers {
+		seen[p.ID] = true
+		out := api.Provider{
+			ID:       p.ID,
+			Name:     p.Name,
+			Type:     p.Type,
+			BaseURL:  p.BaseURL,
+			Enabled:  p.Enabled,
+			Priority: i + 1,
+		}
+		if p.APIKey != "" {
+			out.APIKey = logger.Redacted
+		}
+		out.Registered = isRegistered[p.ID]
+		data = append(data, withHealth(out, health))
+	}
+
+	// providers added through the database are not in the config file
+	for _, p := range registered {
+		if seen[p.ID] {
+			continue
+		}
+		data = append(data, withHealth(api.Provider{ID: p.ID, Type: p.Type, Enabled: true, Registered: true}, health))
+	}
+
+	c.JSON(http.StatusOK, api.ProviderList{Object: "list", Data: data})
+}
+
+// CheckHealth runs a health check against every registered provider right
+// away, instead of waiting for the background checker, and returns the
+// statuses routing now uses. Admin only.
+// POST /api/v1/providers/health
+func (h *ProvidersHandler) CheckHealth(c *gin.Context) {
+	if !requireAdmin(c, h.repo, "checking provider health") {
+		return
+	}
+
+	h.service.CheckHealth(c.Request.Context())
+	c.JSON(http.StatusOK, gin.H{
+		"object": "list",
+		"data":   h.service.HealthStatus(),
+	})
+}
+
+func withHealth(p api.Provider, health map[string]gateway.ProviderHealth) api.Provider {
+	if status, ok := health[p.ID]; ok {
+		p.Health = &api.ProviderHealth{
+			Healthy:             status.Healthy,
+			Status:              status.Status,
+			Error:               status.Error,
+			ConsecutiveFailures: status.ConsecutiveFailures,
+			LatencyMS:           status.LatencyMS,
+			CheckedAt:           status.CheckedAt,
+		}
+	}
+	return p
+}
@@ -0,0 +1,120 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/gateway"
+	"github.com/nulzo/model-router-api/internal/server/middleware"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/internal/store/sqlite"
+	"github.com/nulzo/model-router-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func listProviders(t *testing.T, svc gateway.Service, repo store.Repository, providers []config.ProviderConfig, caller *model.APIKey) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.ErrorHandler())
+	r.Use(func(c *gin.Context) {
+		if caller != nil {
+			c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), store.ContextKeyAPIKey, caller))
+		}
+	})
+	r.GET("/api/v1/providers", NewProvidersHandler(svc, repo, providers).List)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/providers", nil))
+	return w
+}
+
+func TestListProviders(t *testing.T) {
+	repo, err := sqlite.NewSQLiteStorage(":memory:", zap.NewNop())
+	require.NoError(t, err)
+	defer repo.Close()
+	caller, admin := seedKeys(t, repo)
+
+	svc := gateway.NewService(zap.NewNop(), nil, nil, nil, config.GatewayConfig{})
+	require.NoError(t, svc.RegisterProvider(context.Background(), &capsProvider{
+		id:     "main",
+		models: []api.ModelDefinition{{ID: "main/model"}},
+	}))
+	providers := []config.ProviderConfig{
+		{ID: "main", Name: "Main", Type: "openai", APIKey: "sk-secret-value", BaseURL: "https://api.openai.com/v1", Enabled: true},
+		{ID: "spare", Name: "Spare", Type: "anthropic", Enabled: false},
+	}
+
+	w := listProviders(t, svc, repo, providers, admin)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "sk-secret-value")
+
+	var out api.ProviderList
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &out))
+	require.Len(t, out.Data, 2)
+
+	primary := out.Data[0]
+	assert.Equal(t, "main", primary.ID)
+	assert.Equal(t, "openai", primary.Type)
+	assert.Equal(t, 1, primary.Priority)
+	assert.True(t, primary.Enabled)
+	assert.True(t, primary.Registered)
+	assert.Equal(t, "***", primary.APIKey)
+
+	spare := out.Data[1]
+	assert.Equal(t, 2, spare.Priority)
+	assert.False(t, spare.Registered)
+	assert.Empty(t, spare.APIKey)
+
+	// admin only
+	assert.Equal(t, http.StatusForbidden, listProviders(t, svc, repo, providers, caller).Code)
+	assert.Equal(t, http.StatusUnauthorized, listProviders(t, svc, repo, providers, nil).Code)
+}
+
+func TestCheckProviderHealth(t *testing.T) {
+	repo, err := sqlite.NewSQLiteStorage(":memory:", zap.NewNop())
+	require.NoError(t, err)
+	defer repo.Close()
+	caller, admin := seedKeys(t, repo)
+
+	svc := gateway.NewService(zap.NewNop(), nil, nil, nil, config.GatewayConfig{})
+	require.NoError(t, svc.RegisterProvider(context.Background(), &capsProvider{id: "main"}))
+
+	check := func(caller *model.APIKey) *httptest.ResponseRecorder {
+		gin.SetMode(gin.TestMode)
+		r := gin.New()
+		r.Use(middleware.ErrorHandler())
+		r.Use(func(c *gin.Context) {
+			if caller != nil {
+				c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), store.ContextKeyAPIKey, caller))
+			}
+		})
+		r.POST("/api/v1/providers/health", NewProvidersHandler(svc, repo, nil).CheckHealth)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/providers/health", nil))
+		return w
+	}
+
+	// admin only, and nothing is checked for other callers
+	assert.Equal(t, http.StatusForbidden, check(caller).Code)
+	assert.Empty(t, svc.HealthStatus())
+
+	w := check(admin)
+	require.Equal(t, http.StatusOK, w.Code)
+	var out struct {
+		Data []gateway.ProviderHealth `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &out))
+	require.Len(t, out.Data, 1)
+	assert.Equal(t, "main", out.Data[0].ProviderID)
+	assert.Equal(t, gateway.HealthHealthy, out.Data[0].Status)
+	assert.True(t, out.Data[0].Healthy)
+}
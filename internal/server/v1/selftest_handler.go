@@ -0,0 +1,32 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nulzo/model-router-api/internal/gateway"
+	"github.com/nulzo/model-router-api/internal/store"
+)
+
+type SelfTestHandler struct {
+	service gateway.Service
+	repo    store.Repository
+}
+
+func NewSelfTestHandler(service gateway.Service, repo store.Repository) *SelfTestHandler {
+	return &SelfTestHandler{service: service, repo: repo}
+}
+
+// Run sends a canary request through every provider and reports whether it
+// succeeded, its latency and its cost. Admin only.
+// GET /api/v1/selftest
+func (h *SelfTestHandler) Run(c *gin.Context) {
+	if !requireAdmin(c, h.repo, "running the self-test") {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"object": "list",
+		"data":   h.service.SelfTest(c.Request.Context()),
+	})
+}
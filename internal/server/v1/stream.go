@@ -0,0 +1,62 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+// openStream holds the response back until the stream produces its first
+// result. A stream failing before it, or sending nothing within timeout, is
+// returned as an error so the handler can still answer with a plain JSON
+// error and a meaningful status; once headers are sent, failures can only be
+// reported as events. On success the returned channel relays the whole
+// stream, first result included, until it ends or ctx is done.
+func openStream(ctx context.Context, streamChan <-chan api.StreamResult, timeout time.Duration) (<-chan api.StreamResult, error) {
+	var timeoutC <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutC = timer.C
+	}
+
+	var first api.StreamResult
+	select {
+	case result, ok := <-streamChan:
+		if !ok {
+			return streamChan, nil
+		}
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		first = result
+	case <-timeoutC:
+		return nil, api.NewError(http.StatusGatewayTimeout, "Provider Timeout",
+			fmt.Sprintf("the provider sent nothing within %s", timeout),
+			api.WithExtension("finish_reason", api.FinishReasonTimeout),
+		)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	out := make(chan api.StreamResult)
+	go func() {
+		defer close(out)
+		select {
+		case out <- first:
+		case <-ctx.Done():
+			return
+		}
+		for result := range streamChan {
+			select {
+			case out <- result:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
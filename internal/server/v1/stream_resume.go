@@ -0,0 +1,157 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/cache"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/pkg/api"
+)
+
+const (
+	defaultResumeBufferSize = 512
+	defaultResumeTTL        = 5 * time.Minute
+	// resumePollInterval is how often a resumed stream checks the buffer for
+	// events emitted since it last looked.
+	resumePollInterval = 50 * time.Millisecond
+	// resumeFlushInterval is how long appended events may wait before the
+	// buffer is written to the cache, so a fast stream is not re-serialized
+	// on every chunk.
+	resumeFlushInterval = 50 * time.Millisecond
+)
+
+// sseEvent is one encoded stream event. ID is only set on resumable streams.
+type sseEvent struct {
+	ID   string
+	Data string
+}
+
+// bufferedEvent is an emitted event kept for clients that reconnect.
+type bufferedEvent struct {
+	Seq  int    `json:"seq"`
+	Data string `json:"data"`
+}
+
+// bufferedStream is the cached state of a resumable stream.
+type bufferedStream struct {
+	KeyID  string          `json:"key_id"`
+	Events []bufferedEvent `json:"events"`
+	Done   bool            `json:"done"`
+}
+
+// streamBuffer keeps the most recent events of a stream in the cache, keyed
+// by stream ID, so a client reconnecting with the ID of the last event it
+// received can pick up where it left off.
+type streamBuffer struct {
+	cache cache.CacheService
+	id    string
+	size  int
+	ttl   time.Duration
+
+	mu      sync.Mutex
+	state   bufferedStream
+	pending *time.Timer // writes the events appended since the last write
+}
+
+func newStreamBuffer(c cache.CacheService, cfg config.StreamResumeConfig, id, keyID string) *streamBuffer {
+	size, ttl := cfg.BufferSize, cfg.TTL
+	if size <= 0 {
+		size = defaultResumeBufferSize
+	}
+	if ttl <= 0 {
+		ttl = defaultResumeTTL
+	}
+	return &streamBuffer{cache: c, id: id, size: size, ttl: ttl, state: bufferedStream{KeyID: keyID}}
+}
+
+func streamBufferKey(id string) string {
+	return "stream:" + id
+}
+
+// eventID formats the SSE id of the seq-th event of a stream.
+func eventID(streamID string, seq int) string {
+	return fmt.Sprintf("%s:%d", streamID, seq)
+}
+
+// parseEventID splits a Last-Event-ID into the stream ID and sequence.
+func parseEventID(id string) (string, int, bool) {
+	streamID, seq, ok := strings.Cut(id, ":")
+	if !ok || streamID == "" {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(seq)
+	if err != nil || n < 0 {
+		return "", 0, false
+	}
+	return streamID, n, true
+}
+
+// append records an event, dropping the oldest once the buffer is full. It
+// reaches the cache within resumeFlushInterval.
+func (b *streamBuffer) append(seq int, data string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state.Events = append(b.state.Events, bufferedEvent{Seq: seq, Data: data})
+	if n := len(b.state.Events); n > b.size {
+		b.state.Events = b.state.Events[n-b.size:]
+	}
+	if b.pending == nil {
+		b.pending = time.AfterFunc(resumeFlushInterval, b.flush)
+	}
+}
+
+func (b *streamBuffer) flush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.pending = nil
+	_ = b.cache.Set(context.Background(), streamBufferKey(b.id), b.state, b.ttl)
+}
+
+// finish marks the stream complete, resumed clients stop once they caught up.
+func (b *streamBuffer) finish(ctx context.Context) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.pending != nil {
+		b.pending.Stop()
+		b.pending = nil
+	}
+	b.state.Done = true
+	_ = b.cache.Set(ctx, streamBufferKey(b.id), b.state, b.ttl)
+}
+
+// callerKeyID returns the ID of the calling API key, empty when there is none.
+func callerKeyID(ctx context.Context) string {
+	if key, ok := ctx.Value(store.ContextKeyAPIKey).(*model.APIKey); ok {
+		return key.ID
+	}
+	return ""
+}
+
+// loadResumable returns the buffered state of the stream a Last-Event-ID
+// refers to, checking that it belongs to the caller and still holds every
+// event after the one last received.
+func loadResumable(ctx context.Context, c cache.CacheService, streamID string, after int) (*bufferedStream, error) {
+	var state bufferedStream
+	if err := c.Get(ctx, streamBufferKey(streamID), &state); err != nil || state.KeyID != callerKeyID(ctx) {
+		return nil, api.NewError(http.StatusNotFound, "Stream Not Found",
+			fmt.Sprintf("stream '%s' does not exist or has expired", streamID))
+	}
+	if len(state.Events) > 0 && state.Events[0].Seq > after+1 {
+		return nil, api.NewError(http.StatusGone, "Stream Events Expired",
+			fmt.Sprintf("events after %d are no longer buffered for stream '%s'", after, streamID),
+			api.WithExtension("oldest_event", state.Events[0].Seq),
+		)
+	}
+	return &state, nil
+}
@@ -0,0 +1,29 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nulzo/model-router-api/internal/gateway"
+	"github.com/nulzo/model-router-api/internal/store"
+)
+
+type StreamsHandler struct {
+	service gateway.Service
+	repo    store.Repository
+}
+
+func NewStreamsHandler(service gateway.Service, repo store.Repository) *StreamsHandler {
+	return &StreamsHandler{service: service, repo: repo}
+}
+
+// Get returns the number of open streams and the configured cap, for
+// capacity monitoring. Admin only.
+// GET /api/v1/health/streams
+func (h *StreamsHandler) Get(c *gin.Context) {
+	if !requireAdmin(c, h.repo, "reading stream stats") {
+		return
+	}
+
+	c.JSON(http.StatusOK, h.service.StreamStats())
+}
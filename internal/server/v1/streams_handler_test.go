@@ -0,0 +1,54 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nulzo/model-router-api/internal/config"
+	"github.com/nulzo/model-router-api/internal/gateway"
+	"github.com/nulzo/model-router-api/internal/server/middleware"
+	"github.com/nulzo/model-router-api/internal/store"
+	"github.com/nulzo/model-router-api/internal/store/model"
+	"github.com/nulzo/model-router-api/internal/store/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestGetStreamStats(t *testing.T) {
+	repo, err := sqlite.NewSQLiteStorage(":memory:", zap.NewNop())
+	require.NoError(t, err)
+	defer repo.Close()
+	caller, admin := seedKeys(t, repo)
+
+	svc := gateway.NewService(zap.NewNop(), nil, nil, nil, config.GatewayConfig{MaxActiveStreams: 8})
+
+	get := func(caller *model.APIKey) *httptest.ResponseRecorder {
+		gin.SetMode(gin.TestMode)
+		r := gin.New()
+		r.Use(middleware.ErrorHandler())
+		r.Use(func(c *gin.Context) {
+			if caller != nil {
+				c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), store.ContextKeyAPIKey, caller))
+			}
+		})
+		r.GET("/api/v1/health/streams", NewStreamsHandler(svc, repo).Get)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/health/streams", nil))
+		return w
+	}
+
+	w := get(admin)
+	require.Equal(t, http.StatusOK, w.Code)
+	var stats gateway.StreamStats
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, 8, stats.Max)
+
+	assert.Equal(t, http.StatusForbidden, get(caller).Code)
+	assert.Equal(t, http.StatusUnauthorized, get(nil).Code)
+}
@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
@@ -15,6 +16,19 @@ type CacheService interface {
 	// The implementation should marshal the value.
 	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
 
+	// SetNX stores a value like Set, but only when the key is missing or
+	// expired, and reports whether it was stored.
+	SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error)
+
 	// Delete removes a value from the cache.
 	Delete(ctx context.Context, key string) error
+
+	// IncrBy atomically adds delta to the integer stored at key and returns
+	// the new value, keeping the key's TTL. Missing keys are not created and
+	// fail with ErrNotFound, so counters are rebuilt by their owner instead
+	// of restarting at zero.
+	IncrBy(ctx context.Context, key string, delta int64) (int64, error)
 }
+
+// ErrNotFound is returned by IncrBy for a missing or expired key.
+var ErrNotFound = errors.New("cache: key not found")
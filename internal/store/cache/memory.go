@@ -56,9 +56,48 @@ func (c *MemoryCache) Set(ctx context.Context, key string, value interface{}, tt
 	return nil
 }
 
+func (c *MemoryCache) SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if it, exists := c.items[key]; exists && !time.Now().After(it.expiresAt) {
+		return false, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+
+	c.items[key] = item{
+		value:     data,
+		expiresAt: time.Now().Add(ttl),
+	}
+	return true, nil
+}
+
 func (c *MemoryCache) Delete(ctx context.Context, key string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	delete(c.items, key)
 	return nil
 }
+
+func (c *MemoryCache) IncrBy(ctx context.Context, key string, delta int64) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	it, exists := c.items[key]
+	if !exists || time.Now().After(it.expiresAt) {
+		return 0, ErrNotFound
+	}
+
+	var value int64
+	if err := json.Unmarshal(it.value, &value); err != nil {
+		return 0, err
+	}
+	value += delta
+	it.value, _ = json.Marshal(value)
+	c.items[key] = it
+	return value, nil
+}
@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -37,6 +38,31 @@ func (c *RedisCache) Set(ctx context.Context, key string, value interface{}, ttl
 	return c.client.Set(ctx, key, data, ttl).Err()
 }
 
+func (c *RedisCache) SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+	return c.client.SetNX(ctx, key, data, ttl).Result()
+}
+
 func (c *RedisCache) Delete(ctx context.Context, key string) error {
 	return c.client.Del(ctx, key).Err()
 }
+
+// incrExisting increments a key only when it exists, INCRBY alone would
+// create it at zero.
+var incrExisting = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return false
+end
+return redis.call("INCRBY", KEYS[1], ARGV[1])
+`)
+
+func (c *RedisCache) IncrBy(ctx context.Context, key string, delta int64) (int64, error) {
+	value, err := incrExisting.Run(ctx, c.client, []string{key}, delta).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, ErrNotFound
+	}
+	return value, err
+}
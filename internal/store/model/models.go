@@ -2,6 +2,8 @@ package model
 
 import (
 	"database/sql"
+	"encoding/json"
+	"slices"
 	"time"
 )
 
@@ -38,36 +40,115 @@ type APIKey struct {
 	ExpiresAt          sql.NullTime   `db:"expires_at" json:"expires_at,omitempty"`
 	LastUsedAt         sql.NullTime   `db:"last_used_at" json:"last_used_at,omitempty"`
 	MonthlyLimitMicros sql.NullInt64  `db:"monthly_limit_micros" json:"monthly_limit_micros,omitempty"`
+	SettingsJSON       string         `db:"settings_json" json:"settings_json"`   // APIKeySettings
+	AllowedModels      string         `db:"allowed_models" json:"allowed_models"` // JSON array of model globs
+	DeniedModels       string         `db:"denied_models" json:"denied_models"`   // JSON array of model globs
+	Priority           int            `db:"priority" json:"priority"`             // higher is admitted first
 	IsActive           bool           `db:"is_active" json:"is_active"`
 	CreatedAt          time.Time      `db:"created_at" json:"created_at"`
 	UpdatedAt          time.Time      `db:"updated_at" json:"updated_at"`
 }
 
+// APIKeySettings holds per-key preferences for keys owned by a specific app.
+type APIKeySettings struct {
+	// DefaultModel is used when a request does not specify a model.
+	DefaultModel string `json:"default_model,omitempty"`
+	// Overrides are forced onto every request made with the key.
+	Overrides *ParameterOverrides `json:"overrides,omitempty"`
+	// SigningSecret, when set, requires every request made with the key to be
+	// HMAC signed with it (if request signing is enabled).
+	SigningSecret string `json:"signing_secret,omitempty"`
+	// AllowBaseURLOverride lets the key redirect its requests to another
+	// upstream with X-Provider-Base-URL (if the override is enabled).
+	AllowBaseURLOverride bool `json:"allow_base_url_override,omitempty"`
+	// UseFallbackContent answers with the gateway's fallback content when
+	// every provider fails, unless the request opts out.
+	UseFallbackContent bool `json:"use_fallback_content,omitempty"`
+}
+
+// ParameterOverrides lists the sampling parameters a key may pin.
+// Nil fields leave the client supplied value untouched.
+type ParameterOverrides struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	TopK             *int     `json:"top_k,omitempty"`
+	MaxTokens        *int     `json:"max_tokens,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+}
+
+// Settings decodes the key's SettingsJSON. An empty value yields empty settings.
+func (k *APIKey) Settings() (*APIKeySettings, error) {
+	var settings APIKeySettings
+	if k.SettingsJSON == "" {
+		return &settings, nil
+	}
+	if err := json.Unmarshal([]byte(k.SettingsJSON), &settings); err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// ModelPolicy decodes the model globs the key may and may not call. An empty
+// allow list allows every model not denied.
+func (k *APIKey) ModelPolicy() (allowed, denied []string, err error) {
+	if k.AllowedModels != "" {
+		if err := json.Unmarshal([]byte(k.AllowedModels), &allowed); err != nil {
+			return nil, nil, err
+		}
+	}
+	if k.DeniedModels != "" {
+		if err := json.Unmarshal([]byte(k.DeniedModels), &denied); err != nil {
+			return nil, nil, err
+		}
+	}
+	return allowed, denied, nil
+}
+
+// HasScope reports whether the key's Scopes JSON array grants scope.
+func (k *APIKey) HasScope(scope string) bool {
+	if k.Scopes == "" {
+		return false
+	}
+	var scopes []string
+	if err := json.Unmarshal([]byte(k.Scopes), &scopes); err != nil {
+		return false
+	}
+	return slices.Contains(scopes, scope)
+}
+
 // Provider represents an upstream LLM service (OpenAI, Anthropic).
 type Provider struct {
-	ID         string    `db:"id" json:"id"`
-	Name       string    `db:"name" json:"name"`
-	BaseURL    string    `db:"base_url" json:"base_url"`
-	APIKeyEnc  string    `db:"api_key_enc" json:"-"` // Encrypted
-	ConfigJSON string    `db:"config_json" json:"config_json"`
-	IsEnabled  bool      `db:"is_enabled" json:"is_enabled"`
-	Priority   int       `db:"priority" json:"priority"`
-	CreatedAt  time.Time `db:"created_at" json:"created_at"`
-	UpdatedAt  time.Time `db:"updated_at" json:"updated_at"`
+	ID           string    `db:"id" json:"id"`
+	Type         string    `db:"type" json:"type"` // adapter type, empty for placeholder rows
+	Name         string    `db:"name" json:"name"`
+	BaseURL      string    `db:"base_url" json:"base_url"`
+	APIKeyEnc    string    `db:"api_key_enc" json:"-"` // Encrypted
+	ConfigJSON   string    `db:"config_json" json:"config_json"`
+	RequiresAuth bool      `db:"requires_auth" json:"requires_auth"`
+	Timeout      string    `db:"timeout" json:"timeout"`
+	IsEnabled    bool      `db:"is_enabled" json:"is_enabled"`
+	Priority     int       `db:"priority" json:"priority"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt    time.Time `db:"updated_at" json:"updated_at"`
 }
 
 // Model represents a specific model offered by a provider with pricing.
 type Model struct {
-	ID                    string    `db:"id" json:"id"`
-	ProviderID            string    `db:"provider_id" json:"provider_id"`
-	ProviderModelID       string    `db:"provider_model_id" json:"provider_model_id"`
-	IsEnabled             bool      `db:"is_enabled" json:"is_enabled"`
-	IsPublic              bool      `db:"is_public" json:"is_public"`
-	InputCostMicrosPer1k  int64     `db:"input_cost_micros_per_1k" json:"input_cost_micros_per_1k"`
-	OutputCostMicrosPer1k int64     `db:"output_cost_micros_per_1k" json:"output_cost_micros_per_1k"`
-	ContextWindow         int       `db:"context_window" json:"context_window"`
-	CreatedAt             time.Time `db:"created_at" json:"created_at"`
-	UpdatedAt             time.Time `db:"updated_at" json:"updated_at"`
+	ID                    string `db:"id" json:"id"`
+	ProviderID            string `db:"provider_id" json:"provider_id"`
+	ProviderModelID       string `db:"provider_model_id" json:"provider_model_id"`
+	IsEnabled             bool   `db:"is_enabled" json:"is_enabled"`
+	IsPublic              bool   `db:"is_public" json:"is_public"`
+	InputCostMicrosPer1k  int64  `db:"input_cost_micros_per_1k" json:"input_cost_micros_per_1k"`
+	OutputCostMicrosPer1k int64  `db:"output_cost_micros_per_1k" json:"output_cost_micros_per_1k"`
+	// Cache pricing, zero means cached prompt tokens are billed at the input rate.
+	CacheReadCostMicrosPer1k  int64     `db:"cache_read_cost_micros_per_1k" json:"cache_read_cost_micros_per_1k"`
+	CacheWriteCostMicrosPer1k int64     `db:"cache_write_cost_micros_per_1k" json:"cache_write_cost_micros_per_1k"`
+	ContextWindow             int       `db:"context_window" json:"context_window"`
+	CreatedAt                 time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt                 time.Time `db:"updated_at" json:"updated_at"`
 }
 
 // RequestLog captures the full detail of a completed inference request.
@@ -78,6 +159,7 @@ type RequestLog struct {
 	APIKeyID         string        `db:"api_key_id" json:"api_key_id"`
 	AppName          string        `db:"app_name" json:"app_name"`
 	ProviderID       string        `db:"provider_id" json:"provider_id"`
+	RequestedModelID string        `db:"requested_model_id" json:"requested_model_id"` // Before alias resolution
 	ModelID          string        `db:"model_id" json:"model_id"`
 	UpstreamModelID  string        `db:"upstream_model_id" json:"upstream_model_id"`
 	UpstreamRemoteID string        `db:"upstream_remote_id" json:"upstream_remote_id"`
@@ -93,10 +175,30 @@ type RequestLog struct {
 	IPAddress        string        `db:"ip_address" json:"ip_address"`
 	UserAgent        string        `db:"user_agent" json:"user_agent"`
 	MetaJSON         string        `db:"meta_json" json:"meta_json"`
+	PromptJSON       string        `db:"prompt_json" json:"prompt_json,omitempty"` // Only when prompt persistence is on
+	Completion       string        `db:"completion" json:"completion,omitempty"`
+	Reasoning        string        `db:"reasoning" json:"reasoning,omitempty"`
+	ErrorMessage     string        `db:"error_message" json:"error_message,omitempty"` // Set when the upstream failed
 	CreatedAt        time.Time     `db:"created_at" json:"created_at"`
 
 	// Detailed Usage (Joined but not in request_logs table)
 	UsageDetails *UsageDetails `db:"-" json:"usage_details,omitempty"`
+
+	// Routing lists every provider the gateway tried for this request, in order
+	Routing []RoutingAttempt `db:"-" json:"routing,omitempty"`
+}
+
+// RoutingAttempt records one provider the gateway tried while serving a request.
+type RoutingAttempt struct {
+	RequestID       string    `db:"request_id" json:"request_id"`
+	Attempt         int       `db:"attempt" json:"attempt"`
+	ProviderID      string    `db:"provider_id" json:"provider_id"`
+	ModelID         string    `db:"model_id" json:"model_id"`
+	UpstreamModelID string    `db:"upstream_model_id" json:"upstream_model_id"`
+	StatusCode      int       `db:"status_code" json:"status_code"`
+	ErrorMessage    string    `db:"error_message" json:"error_message,omitempty"`
+	LatencyMS       int64     `db:"latency_ms" json:"latency_ms"`
+	CreatedAt       time.Time `db:"created_at" json:"created_at"`
 }
 
 type UsageDetails struct {
@@ -118,6 +220,19 @@ type UsageDetails struct {
 	UpstreamCompletionCostMicros int64  `db:"upstream_completion_cost_micros" json:"upstream_completion_cost_micros"`
 
 	WebSearchRequests int `db:"web_search_requests" json:"web_search_requests"`
+
+	// Native counts as reported by the provider, nil when the provider's
+	// counts are the normalized ones.
+	NativePromptTokens     *int `db:"native_prompt_tokens" json:"native_prompt_tokens,omitempty"`
+	NativeCompletionTokens *int `db:"native_completion_tokens" json:"native_completion_tokens,omitempty"`
+
+	// Provider reported timings, nil when the provider does not report them.
+	UpstreamQueueMS      *int64 `db:"upstream_queue_ms" json:"upstream_queue_ms,omitempty"`
+	UpstreamPromptMS     *int64 `db:"upstream_prompt_ms" json:"upstream_prompt_ms,omitempty"`
+	UpstreamCompletionMS *int64 `db:"upstream_completion_ms" json:"upstream_completion_ms,omitempty"`
+	UpstreamTotalMS      *int64 `db:"upstream_total_ms" json:"upstream_total_ms,omitempty"`
+
+	CreatedAt time.Time `db:"created_at" json:"-"`
 }
 
 // AuditEvent represents a security or critical system event.
@@ -0,0 +1,3 @@
+ALTER TABLE request_logs DROP COLUMN reasoning;
+ALTER TABLE request_logs DROP COLUMN completion;
+ALTER TABLE request_logs DROP COLUMN prompt_json;
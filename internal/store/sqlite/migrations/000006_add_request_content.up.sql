@@ -0,0 +1,3 @@
+ALTER TABLE request_logs ADD COLUMN prompt_json TEXT DEFAULT '';
+ALTER TABLE request_logs ADD COLUMN completion TEXT DEFAULT '';
+ALTER TABLE request_logs ADD COLUMN reasoning TEXT DEFAULT '';
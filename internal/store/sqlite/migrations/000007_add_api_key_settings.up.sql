@@ -0,0 +1 @@
+ALTER TABLE api_keys ADD COLUMN settings_json TEXT NOT NULL DEFAULT '{}';
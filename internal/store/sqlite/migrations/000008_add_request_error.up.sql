@@ -0,0 +1 @@
+ALTER TABLE request_logs ADD COLUMN error_message TEXT DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE models DROP COLUMN cache_write_cost_micros_per_1k;
+ALTER TABLE models DROP COLUMN cache_read_cost_micros_per_1k;
@@ -0,0 +1,2 @@
+ALTER TABLE models ADD COLUMN cache_read_cost_micros_per_1k INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE models ADD COLUMN cache_write_cost_micros_per_1k INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS request_routing (
+    request_id TEXT NOT NULL REFERENCES request_logs(id) ON DELETE CASCADE,
+    attempt INTEGER NOT NULL, -- 1 based, in the order the gateway tried them
+    provider_id TEXT NOT NULL DEFAULT '',
+    model_id TEXT NOT NULL,
+    upstream_model_id TEXT NOT NULL DEFAULT '',
+    status_code INTEGER NOT NULL,
+    error_message TEXT DEFAULT '',
+    latency_ms INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (request_id, attempt)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE providers DROP COLUMN timeout;
+ALTER TABLE providers DROP COLUMN requires_auth;
+ALTER TABLE providers DROP COLUMN type;
@@ -0,0 +1,5 @@
+-- full provider config so the database can be the source of truth,
+-- rows with an empty type are placeholders from older config syncs
+ALTER TABLE providers ADD COLUMN type TEXT NOT NULL DEFAULT '';
+ALTER TABLE providers ADD COLUMN requires_auth BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE providers ADD COLUMN timeout TEXT NOT NULL DEFAULT '';
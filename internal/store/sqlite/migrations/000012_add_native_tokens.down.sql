@@ -0,0 +1,2 @@
+ALTER TABLE request_usage_details DROP COLUMN native_completion_tokens;
+ALTER TABLE request_usage_details DROP COLUMN native_prompt_tokens;
@@ -0,0 +1,3 @@
+-- token counts as reported by the provider, null when they match the normalized counts
+ALTER TABLE request_usage_details ADD COLUMN native_prompt_tokens INTEGER;
+ALTER TABLE request_usage_details ADD COLUMN native_completion_tokens INTEGER;
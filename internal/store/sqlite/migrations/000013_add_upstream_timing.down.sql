@@ -0,0 +1,4 @@
+ALTER TABLE request_usage_details DROP COLUMN upstream_total_ms;
+ALTER TABLE request_usage_details DROP COLUMN upstream_completion_ms;
+ALTER TABLE request_usage_details DROP COLUMN upstream_prompt_ms;
+ALTER TABLE request_usage_details DROP COLUMN upstream_queue_ms;
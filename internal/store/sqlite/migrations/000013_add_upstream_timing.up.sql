@@ -0,0 +1,5 @@
+-- time the provider reports spending on the request, null when it does not report it
+ALTER TABLE request_usage_details ADD COLUMN upstream_queue_ms INTEGER;
+ALTER TABLE request_usage_details ADD COLUMN upstream_prompt_ms INTEGER;
+ALTER TABLE request_usage_details ADD COLUMN upstream_completion_ms INTEGER;
+ALTER TABLE request_usage_details ADD COLUMN upstream_total_ms INTEGER;
@@ -0,0 +1 @@
+ALTER TABLE request_logs DROP COLUMN requested_model_id;
@@ -0,0 +1,2 @@
+-- the model ID the client asked for, differs from model_id when it was an alias
+ALTER TABLE request_logs ADD COLUMN requested_model_id TEXT DEFAULT '';
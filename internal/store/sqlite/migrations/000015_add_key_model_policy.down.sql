@@ -0,0 +1,2 @@
+ALTER TABLE api_keys DROP COLUMN denied_models;
+ALTER TABLE api_keys DROP COLUMN allowed_models;
@@ -0,0 +1,3 @@
+-- JSON arrays of model ID globs an API key may and may not call, empty allows every model
+ALTER TABLE api_keys ADD COLUMN allowed_models TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE api_keys ADD COLUMN denied_models TEXT NOT NULL DEFAULT '[]';
@@ -0,0 +1,2 @@
+-- requests of higher priority keys leave provider admission queues first
+ALTER TABLE api_keys ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
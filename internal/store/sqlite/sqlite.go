@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
@@ -84,18 +85,27 @@ type apiKeyRepo struct {
 func (r *apiKeyRepo) GetByHash(ctx context.Context, hash string) (*model.APIKey, error) {
 	var key model.APIKey
 	// active check is part of the query for speed
-	query := `SELECT * FROM api_keys WHERE key_hash = ? AND is_active = 1`
-	err := r.db.GetContext(ctx, &key, query, hash)
+	query := `SELECT * FROM api_keys WHERE key_hash = ? AND is_active = 1
+		AND (expires_at IS NULL OR julianday(expires_at) > julianday(?))`
+	err := r.db.GetContext(ctx, &key, query, hash, time.Now())
 	if err != nil {
 		return nil, err
 	}
 	return &key, nil
 }
 
+func (r *apiKeyRepo) Get(ctx context.Context, id string) (*model.APIKey, error) {
+	var key model.APIKey
+	if err := r.db.GetContext(ctx, &key, `SELECT * FROM api_keys WHERE id = ?`, id); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
 func (r *apiKeyRepo) Create(ctx context.Context, key *model.APIKey) error {
 	query := `
-	INSERT INTO api_keys (id, user_id, wallet_id, name, key_hash, key_prefix, scopes, created_at, updated_at)
-	VALUES (:id, :user_id, :wallet_id, :name, :key_hash, :key_prefix, :scopes, :created_at, :updated_at)`
+	INSERT INTO api_keys (id, user_id, wallet_id, name, key_hash, key_prefix, scopes, settings_json, allowed_models, denied_models, priority, expires_at, is_active, created_at, updated_at)
+	VALUES (:id, :user_id, :wallet_id, :name, :key_hash, :key_prefix, :scopes, :settings_json, :allowed_models, :denied_models, :priority, :expires_at, :is_active, :created_at, :updated_at)`
 	_, err := r.db.NamedExecContext(ctx, query, key)
 	return err
 }
@@ -106,67 +116,181 @@ func (r *apiKeyRepo) UpdateUsage(ctx context.Context, id string) error {
 	return err
 }
 
+func (r *apiKeyRepo) UpdateModelPolicy(ctx context.Context, id, allowedModels, deniedModels string) error {
+	query := `UPDATE api_keys SET allowed_models = ?, denied_models = ?, updated_at = ? WHERE id = ?`
+	res, err := r.db.ExecContext(ctx, query, allowedModels, deniedModels, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *apiKeyRepo) UpdatePriority(ctx context.Context, id string, priority int) error {
+	query := `UPDATE api_keys SET priority = ?, updated_at = ? WHERE id = ?`
+	res, err := r.db.ExecContext(ctx, query, priority, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 func (r *apiKeyRepo) ListByUserID(ctx context.Context, userID string) ([]model.APIKey, error) {
 	var keys []model.APIKey
 	err := r.db.SelectContext(ctx, &keys, `SELECT * FROM api_keys WHERE user_id = ?`, userID)
 	return keys, err
 }
 
+func (r *apiKeyRepo) List(ctx context.Context, filter store.APIKeyFilter) ([]model.APIKey, int, error) {
+	where := []string{"1 = 1"}
+	var args []interface{}
+
+	if filter.UserID != "" {
+		where = append(where, "user_id = ?")
+		args = append(args, filter.UserID)
+	}
+	if filter.Active != nil {
+		where = append(where, "is_active = ?")
+		args = append(args, *filter.Active)
+	}
+	clause := strings.Join(where, " AND ")
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM api_keys WHERE `+clause, args...); err != nil {
+		return nil, 0, err
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1 // sqlite: no limit
+	}
+
+	keys := []model.APIKey{}
+	query := `SELECT * FROM api_keys WHERE ` + clause + ` ORDER BY created_at DESC, id LIMIT ? OFFSET ?`
+	if err := r.db.SelectContext(ctx, &keys, query, append(args, limit, filter.Offset)...); err != nil {
+		return nil, 0, err
+	}
+
+	return keys, total, nil
+}
+
+// expiry times are compared with julianday so differing timezone offsets in
+// the stored strings do not matter
+func (r *apiKeyRepo) ListExpiring(ctx context.Context, before time.Time) ([]model.APIKey, error) {
+	keys := []model.APIKey{}
+	query := `SELECT * FROM api_keys WHERE is_active = 1 AND expires_at IS NOT NULL
+		AND julianday(expires_at) <= julianday(?) ORDER BY julianday(expires_at), id`
+	err := r.db.SelectContext(ctx, &keys, query, before)
+	return keys, err
+}
+
+func (r *apiKeyRepo) DeactivateExpired(ctx context.Context, now time.Time) (int64, error) {
+	query := `UPDATE api_keys SET is_active = 0, updated_at = ? WHERE is_active = 1
+		AND expires_at IS NOT NULL AND julianday(expires_at) <= julianday(?)`
+	res, err := r.db.ExecContext(ctx, query, now, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
 type requestRepo struct {
 	db DB
 }
 
-func (r *requestRepo) Log(ctx context.Context, log *model.RequestLog) error {
-	// Using NamedExec for cleaner mapping
-	query := `
+const insertRequestLogQuery = `
 	INSERT INTO request_logs (
-		id, user_id, api_key_id, app_name, provider_id, model_id,
+		id, user_id, api_key_id, app_name, provider_id, requested_model_id, model_id,
 		upstream_model_id, upstream_remote_id, finish_reason,
 		input_tokens, output_tokens, cached_tokens,
 		latency_ms, ttft_ms, status_code, total_cost_micros, is_streamed,
-		ip_address, user_agent, meta_json, created_at
+		ip_address, user_agent, meta_json, prompt_json, completion, reasoning, error_message, created_at
 	) VALUES (
-		:id, :user_id, :api_key_id, :app_name, :provider_id, :model_id,
+		:id, :user_id, :api_key_id, :app_name, :provider_id, :requested_model_id, :model_id,
 		:upstream_model_id, :upstream_remote_id, :finish_reason,
 		:input_tokens, :output_tokens, :cached_tokens,
 		:latency_ms, :ttft_ms, :status_code, :total_cost_micros, :is_streamed,
-		:ip_address, :user_agent, :meta_json, :created_at
+		:ip_address, :user_agent, :meta_json, :prompt_json, :completion, :reasoning, :error_message, :created_at
 	)`
-	if _, err := r.db.NamedExecContext(ctx, query, log); err != nil {
-		return err
-	}
 
-	if log.UsageDetails != nil {
-		log.UsageDetails.RequestID = log.ID
-		queryDetails := `
-		INSERT INTO request_usage_details (
-			request_id,
-			prompt_tokens_cached, prompt_tokens_cache_write, prompt_tokens_audio, prompt_tokens_video,
-			completion_tokens_reasoning, completion_tokens_image,
-			cost_micros, is_byok,
-			upstream_cost_micros, upstream_prompt_cost_micros, upstream_completion_cost_micros,
-			web_search_requests,
-			created_at
-		) VALUES (
-			:request_id,
-			:prompt_tokens_cached, :prompt_tokens_cache_write, :prompt_tokens_audio, :prompt_tokens_video,
-			:completion_tokens_reasoning, :completion_tokens_image,
-			:cost_micros, :is_byok,
-			:upstream_cost_micros, :upstream_prompt_cost_micros, :upstream_completion_cost_micros,
-			:web_search_requests,
-			CURRENT_TIMESTAMP
-		)`
-		if _, err := r.db.NamedExecContext(ctx, queryDetails, log.UsageDetails); err != nil {
-			// If details fail, we log it but don't fail the main log?
-			// Or return error? Returning error might cause ingestor retry loop to duplicate main log if it wasn't transactional.
-			// Since we aren't in a guaranteed TX here, duplication risk exists on retry.
-			return fmt.Errorf("failed to log usage details: %w", err)
+// maxBatchRows keeps a multi-row insert well under SQLite's bound parameter limit.
+const maxBatchRows = 200
+
+func (r *requestRepo) Log(ctx context.Context, log *model.RequestLog) error {
+	// Using NamedExec for cleaner mapping
+	_, err := r.db.NamedExecContext(ctx, insertRequestLogQuery, log)
+	return err
+}
+
+func (r *requestRepo) LogBatch(ctx context.Context, logs []*model.RequestLog) error {
+	for start := 0; start < len(logs); start += maxBatchRows {
+		end := min(start+maxBatchRows, len(logs))
+		// sqlx expands a slice argument into a multi-row VALUES list
+		if _, err := r.db.NamedExecContext(ctx, insertRequestLogQuery, logs[start:end]); err != nil {
+			return fmt.Errorf("failed to log request batch: %w", err)
 		}
 	}
+	return nil
+}
 
+func (r *requestRepo) LogUsageDetails(ctx context.Context, details *model.UsageDetails) error {
+	query := `
+	INSERT INTO request_usage_details (
+		request_id,
+		prompt_tokens_cached, prompt_tokens_cache_write, prompt_tokens_audio, prompt_tokens_video,
+		completion_tokens_reasoning, completion_tokens_image,
+		cost_micros, is_byok,
+		upstream_cost_micros, upstream_prompt_cost_micros, upstream_completion_cost_micros,
+		web_search_requests,
+		native_prompt_tokens, native_completion_tokens,
+		upstream_queue_ms, upstream_prompt_ms, upstream_completion_ms, upstream_total_ms,
+		created_at
+	) VALUES (
+		:request_id,
+		:prompt_tokens_cached, :prompt_tokens_cache_write, :prompt_tokens_audio, :prompt_tokens_video,
+		:completion_tokens_reasoning, :completion_tokens_image,
+		:cost_micros, :is_byok,
+		:upstream_cost_micros, :upstream_prompt_cost_micros, :upstream_completion_cost_micros,
+		:web_search_requests,
+		:native_prompt_tokens, :native_completion_tokens,
+		:upstream_queue_ms, :upstream_prompt_ms, :upstream_completion_ms, :upstream_total_ms,
+		CURRENT_TIMESTAMP
+	)`
+	if _, err := r.db.NamedExecContext(ctx, query, details); err != nil {
+		return fmt.Errorf("failed to log usage details: %w", err)
+	}
 	return nil
 }
 
+func (r *requestRepo) LogRouting(ctx context.Context, attempts []model.RoutingAttempt) error {
+	query := `
+	INSERT INTO request_routing (
+		request_id, attempt, provider_id, model_id, upstream_model_id,
+		status_code, error_message, latency_ms, created_at
+	) VALUES (
+		:request_id, :attempt, :provider_id, :model_id, :upstream_model_id,
+		:status_code, :error_message, :latency_ms, :created_at
+	)`
+	for _, a := range attempts {
+		if _, err := r.db.NamedExecContext(ctx, query, a); err != nil {
+			return fmt.Errorf("failed to log routing attempt %d: %w", a.Attempt, err)
+		}
+	}
+	return nil
+}
+
+func (r *requestRepo) GetRouting(ctx context.Context, requestID string) ([]model.RoutingAttempt, error) {
+	attempts := []model.RoutingAttempt{}
+	query := `SELECT * FROM request_routing WHERE request_id = ? ORDER BY attempt`
+	err := r.db.SelectContext(ctx, &attempts, query, requestID)
+	return attempts, err
+}
+
 func (r *requestRepo) GetByID(ctx context.Context, id string) (*model.RequestLog, error) {
 	var log model.RequestLog
 	query := `SELECT * FROM request_logs WHERE id = ?`
@@ -185,6 +309,10 @@ func (r *requestRepo) GetByID(ctx context.Context, id string) (*model.RequestLog
 	// 	// For now, return what we have, maybe details aren't there.
 	// }
 
+	if routing, err := r.GetRouting(ctx, id); err == nil && len(routing) > 0 {
+		log.Routing = routing
+	}
+
 	return &log, nil
 }
 
@@ -214,6 +342,20 @@ func (r *requestRepo) GetDailyStats(ctx context.Context, days int) ([]model.Dail
 	return stats, err
 }
 
+func (r *requestRepo) SumUserCost(ctx context.Context, userID string, since time.Time) (int64, error) {
+	var total int64
+	query := `SELECT COALESCE(SUM(total_cost_micros), 0) FROM request_logs WHERE user_id = ? AND created_at >= ?`
+	err := r.db.GetContext(ctx, &total, query, userID, since)
+	return total, err
+}
+
+func (r *requestRepo) SumKeyCost(ctx context.Context, keyID string, since time.Time) (int64, error) {
+	var total int64
+	query := `SELECT COALESCE(SUM(total_cost_micros), 0) FROM request_logs WHERE api_key_id = ? AND created_at >= ?`
+	err := r.db.GetContext(ctx, &total, query, keyID, since)
+	return total, err
+}
+
 type providerRepo struct {
 	db DB
 }
@@ -224,6 +366,44 @@ func (r *providerRepo) ListActive(ctx context.Context) ([]model.Provider, error)
 	return providers, err
 }
 
+func (r *providerRepo) ListAll(ctx context.Context) ([]model.Provider, error) {
+	providers := []model.Provider{}
+	err := r.db.SelectContext(ctx, &providers, `SELECT * FROM providers ORDER BY priority DESC, id`)
+	return providers, err
+}
+
+func (r *providerRepo) GetByID(ctx context.Context, id string) (*model.Provider, error) {
+	var p model.Provider
+	if err := r.db.GetContext(ctx, &p, `SELECT * FROM providers WHERE id = ?`, id); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *providerRepo) Upsert(ctx context.Context, provider *model.Provider) error {
+	query := `
+	INSERT INTO providers (
+		id, type, name, base_url, api_key_enc, config_json, requires_auth, timeout,
+		is_enabled, priority, created_at, updated_at
+	) VALUES (
+		:id, :type, :name, :base_url, :api_key_enc, :config_json, :requires_auth, :timeout,
+		:is_enabled, :priority, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+	)
+	ON CONFLICT(id) DO UPDATE SET
+		type = excluded.type,
+		name = excluded.name,
+		base_url = excluded.base_url,
+		api_key_enc = excluded.api_key_enc,
+		config_json = excluded.config_json,
+		requires_auth = excluded.requires_auth,
+		timeout = excluded.timeout,
+		is_enabled = excluded.is_enabled,
+		priority = excluded.priority,
+		updated_at = CURRENT_TIMESTAMP`
+	_, err := r.db.NamedExecContext(ctx, query, provider)
+	return err
+}
+
 func (r *providerRepo) GetModelPricing(ctx context.Context, modelID string) (*model.Model, error) {
 	var m model.Model
 	err := r.db.GetContext(ctx, &m, `SELECT * FROM models WHERE id = ?`, modelID)
@@ -239,11 +419,13 @@ func (r *providerRepo) SyncModels(ctx context.Context, models []model.Model) err
 	query := `
 	INSERT INTO models (
 		id, provider_id, provider_model_id, is_enabled, is_public,
-		input_cost_micros_per_1k, output_cost_micros_per_1k, context_window,
+		input_cost_micros_per_1k, output_cost_micros_per_1k,
+		cache_read_cost_micros_per_1k, cache_write_cost_micros_per_1k, context_window,
 		created_at, updated_at
 	) VALUES (
 		:id, :provider_id, :provider_model_id, :is_enabled, :is_public,
-		:input_cost_micros_per_1k, :output_cost_micros_per_1k, :context_window,
+		:input_cost_micros_per_1k, :output_cost_micros_per_1k,
+		:cache_read_cost_micros_per_1k, :cache_write_cost_micros_per_1k, :context_window,
 		CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
 	)
 	ON CONFLICT(id) DO UPDATE SET
@@ -253,6 +435,8 @@ func (r *providerRepo) SyncModels(ctx context.Context, models []model.Model) err
 		is_public = excluded.is_public,
 		input_cost_micros_per_1k = excluded.input_cost_micros_per_1k,
 		output_cost_micros_per_1k = excluded.output_cost_micros_per_1k,
+		cache_read_cost_micros_per_1k = excluded.cache_read_cost_micros_per_1k,
+		cache_write_cost_micros_per_1k = excluded.cache_write_cost_micros_per_1k,
 		context_window = excluded.context_window,
 		updated_at = CURRENT_TIMESTAMP`
 
@@ -316,6 +500,14 @@ func (r *userRepo) GetWallet(ctx context.Context, userID string) (*model.Wallet,
 	return &w, err
 }
 
+func (r *userRepo) CreateWallet(ctx context.Context, wallet *model.Wallet) error {
+	query := `
+	INSERT INTO wallets (id, user_id, balance_micros, currency, is_frozen, created_at, updated_at)
+	VALUES (:id, :user_id, :balance_micros, :currency, :is_frozen, :created_at, :updated_at)`
+	_, err := r.db.NamedExecContext(ctx, query, wallet)
+	return err
+}
+
 type auditRepo struct {
 	db DB
 }
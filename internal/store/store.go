@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"time"
 
 	"github.com/nulzo/model-router-api/internal/store/model"
 )
@@ -11,6 +12,10 @@ type contextKey string
 const (
 	ContextKeyAPIKey  contextKey = "api_key"
 	ContextKeyAppName contextKey = "app_name"
+
+	// attribution only, these never authenticate a request
+	ContextKeyAppTitle   contextKey = "app_title"
+	ContextKeyAppReferer contextKey = "app_referer"
 )
 
 // Repository is the main contract for the data layer.
@@ -35,23 +40,57 @@ type AuditRepository interface {
 type APIKeyRepository interface {
 	// GetByHash retrieves a key by its hashed value (for auth).
 	GetByHash(ctx context.Context, hash string) (*model.APIKey, error)
+	// Get retrieves a key by ID, active or not.
+	Get(ctx context.Context, id string) (*model.APIKey, error)
 	// Create issues a new API key.
 	Create(ctx context.Context, key *model.APIKey) error
 	// UpdateUsage increments usage stats.
 	UpdateUsage(ctx context.Context, id string) error
+	// UpdateModelPolicy replaces the JSON arrays of model globs the key may
+	// and may not call.
+	UpdateModelPolicy(ctx context.Context, id, allowedModels, deniedModels string) error
+	// UpdatePriority sets the priority the key's requests are admitted with.
+	UpdatePriority(ctx context.Context, id string, priority int) error
 	// ListByUserID returns all keys for a user.
 	ListByUserID(ctx context.Context, userID string) ([]model.APIKey, error)
+	// List returns a page of keys matching the filter along with the total match count.
+	List(ctx context.Context, filter APIKeyFilter) ([]model.APIKey, int, error)
+	// ListExpiring returns active keys that expire at or before the given time.
+	ListExpiring(ctx context.Context, before time.Time) ([]model.APIKey, error)
+	// DeactivateExpired deactivates active keys that expired at or before now
+	// and returns how many were deactivated.
+	DeactivateExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// APIKeyFilter narrows down an API key listing. Zero values match everything.
+type APIKeyFilter struct {
+	UserID string
+	Active *bool
+	Limit  int
+	Offset int
 }
 
 type RequestRepository interface {
-	// Log stores a completed request.
+	// Log stores a completed request. Usage details are stored separately via LogUsageDetails.
 	Log(ctx context.Context, log *model.RequestLog) error
-	// GetByID returns a single request log by ID, including usage details if available.
+	// LogBatch stores several completed requests with multi-row inserts.
+	LogBatch(ctx context.Context, logs []*model.RequestLog) error
+	// LogUsageDetails stores the detailed usage breakdown for an already logged request.
+	LogUsageDetails(ctx context.Context, details *model.UsageDetails) error
+	// LogRouting stores the ordered provider attempts made for an already logged request.
+	LogRouting(ctx context.Context, attempts []model.RoutingAttempt) error
+	// GetRouting returns the provider attempts recorded for a request, in order.
+	GetRouting(ctx context.Context, requestID string) ([]model.RoutingAttempt, error)
+	// GetByID returns a single request log by ID, including usage details and routing if available.
 	GetByID(ctx context.Context, id string) (*model.RequestLog, error)
 	// GetRecent returns the last N logs for a user.
 	GetRecent(ctx context.Context, userID string, limit int) ([]model.RequestLog, error)
 	// GetDailyStats returns aggregated stats grouped by day.
 	GetDailyStats(ctx context.Context, days int) ([]model.DailyStats, error)
+	// SumUserCost returns the total cost of a user's requests made since the given time.
+	SumUserCost(ctx context.Context, userID string, since time.Time) (int64, error)
+	// SumKeyCost returns the total cost of an API key's requests made since the given time.
+	SumKeyCost(ctx context.Context, keyID string, since time.Time) (int64, error)
 }
 
 type ProviderRepository interface {
@@ -63,10 +102,17 @@ type ProviderRepository interface {
 	SyncModels(ctx context.Context, models []model.Model) error
 	// SyncProviders syncs the providers from the configuration to the database.
 	SyncProviders(ctx context.Context, providers []model.Provider) error
+	// ListAll returns every provider, enabled or not.
+	ListAll(ctx context.Context) ([]model.Provider, error)
+	// GetByID returns a single provider.
+	GetByID(ctx context.Context, id string) (*model.Provider, error)
+	// Upsert creates or fully replaces a provider's configuration.
+	Upsert(ctx context.Context, provider *model.Provider) error
 }
 
 type UserRepository interface {
 	Get(ctx context.Context, id string) (*model.User, error)
 	Create(ctx context.Context, user *model.User) error
 	GetWallet(ctx context.Context, userID string) (*model.Wallet, error)
+	CreateWallet(ctx context.Context, wallet *model.Wallet) error
 }
\ No newline at end of file
@@ -0,0 +1,44 @@
+package api
+
+// SpeechRequest mirrors the OpenAI audio speech request.
+type SpeechRequest struct {
+	Model string `json:"model" binding:"required"`
+	Input string `json:"input" binding:"required,max=10000"`
+	// Voice is the provider's voice ID, the provider default when empty.
+	Voice string `json:"voice,omitempty"`
+	// ResponseFormat is the audio encoding, defaults to mp3.
+	ResponseFormat string  `json:"response_format,omitempty" binding:"omitempty,oneof=mp3 opus pcm ulaw"`
+	Speed          float64 `json:"speed,omitempty" binding:"omitempty,min=0.7,max=1.2"`
+}
+
+// SpeechResponse is the synthesized audio.
+type SpeechResponse struct {
+	Audio       []byte
+	ContentType string
+	// Characters is the number of input characters billed.
+	Characters int
+}
+
+// TranscriptionRequest mirrors the OpenAI audio transcription request, sent
+// as multipart form data.
+type TranscriptionRequest struct {
+	Model    string
+	File     []byte
+	Filename string
+	Language string
+	Prompt   string
+	// ResponseFormat is json (default), text, verbose_json, srt or vtt.
+	ResponseFormat string
+	Temperature    float64
+}
+
+// TranscriptionResponse is the transcript of an audio file.
+type TranscriptionResponse struct {
+	// Text is the transcript, or the subtitles for the srt and vtt formats.
+	Text     string
+	Language string
+	// Duration is the length of the audio in seconds, as billed.
+	Duration float64
+	// Verbose is the provider's verbose_json body, when it returned one.
+	Verbose []byte
+}
@@ -85,6 +85,13 @@ func WithType(uri string) ProblemOption {
 	}
 }
 
+// WithInstance sets the RFC "instance" URI of this occurrence
+func WithInstance(uri string) ProblemOption {
+	return func(p *Problem) {
+		p.Instance = uri
+	}
+}
+
 // AppError defines a standard error shape for the API
 type Error struct {
 	// HTTP Status Code (e.g., 400, 429, 500)
@@ -0,0 +1,88 @@
+package api
+
+// FinishReason is the canonical reason a choice stopped generating, as sent in
+// the finish_reason field of responses and stream chunks and recorded in
+// request logs. Provider specific reasons are mapped onto these, the original
+// is kept in native_finish_reason.
+type FinishReason string
+
+const (
+	// FinishReasonStop means the model ended its answer or hit a stop sequence.
+	FinishReasonStop FinishReason = "stop"
+	// FinishReasonLength means max_tokens or the context window cut the answer.
+	FinishReasonLength FinishReason = "length"
+	// FinishReasonToolCalls means the model stopped to call tools, the client
+	// should run them and send back the results.
+	FinishReasonToolCalls FinishReason = "tool_calls"
+	// FinishReasonContentFilter means the provider withheld or cut the answer
+	// for safety reasons.
+	FinishReasonContentFilter FinishReason = "content_filter"
+	// FinishReasonError means the provider or the gateway failed mid request,
+	// the choice carries the error.
+	FinishReasonError FinishReason = "error"
+	// FinishReasonTimeout means the request ran out of time before the
+	// provider finished.
+	FinishReasonTimeout FinishReason = "timeout"
+	// FinishReasonClientDisconnect means the client went away before the
+	// answer was complete. It only appears in request logs.
+	FinishReasonClientDisconnect FinishReason = "client_disconnect"
+	// FinishReasonMaxCost means the gateway stopped the request once it
+	// reached the caller's spending limit.
+	FinishReasonMaxCost FinishReason = "max_cost"
+	// FinishReasonMaxDuration means the gateway stopped the request once it
+	// reached its maximum duration.
+	FinishReasonMaxDuration FinishReason = "max_duration"
+	// FinishReasonFallback means every provider failed and the choice holds
+	// the gateway's canned fallback content instead of a model answer.
+	FinishReasonFallback FinishReason = "fallback"
+)
+
+// FinishReasons lists every canonical finish reason.
+var FinishReasons = []FinishReason{
+	FinishReasonStop,
+	FinishReasonLength,
+	FinishReasonToolCalls,
+	FinishReasonContentFilter,
+	FinishReasonError,
+	FinishReasonTimeout,
+	FinishReasonClientDisconnect,
+	FinishReasonMaxCost,
+	FinishReasonMaxDuration,
+	FinishReasonFallback,
+}
+
+// IsKnown reports whether r is a canonical finish reason.
+func (r FinishReason) IsKnown() bool {
+	for _, known := range FinishReasons {
+		if r == known {
+			return true
+		}
+	}
+	return false
+}
+
+// IsError reports whether the answer was cut by a failure rather than by the
+// model, in which case retrying the request may help.
+func (r FinishReason) IsError() bool {
+	switch r {
+	case FinishReasonError, FinishReasonTimeout, FinishReasonClientDisconnect, FinishReasonFallback:
+		return true
+	}
+	return false
+}
+
+// IsTruncated reports whether the answer is incomplete because a limit was
+// reached, the content is usable but ends abruptly.
+func (r FinishReason) IsTruncated() bool {
+	switch r {
+	case FinishReasonLength, FinishReasonMaxCost, FinishReasonMaxDuration:
+		return true
+	}
+	return false
+}
+
+// IsComplete reports whether the model finished on its own terms, either with
+// a full answer or with tool calls for the client to run.
+func (r FinishReason) IsComplete() bool {
+	return r == FinishReasonStop || r == FinishReasonToolCalls
+}
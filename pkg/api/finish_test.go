@@ -0,0 +1,40 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFinishReasonPredicates(t *testing.T) {
+	tests := []struct {
+		reason    FinishReason
+		isError   bool
+		truncated bool
+		complete  bool
+	}{
+		{FinishReasonStop, false, false, true},
+		{FinishReasonLength, false, true, false},
+		{FinishReasonToolCalls, false, false, true},
+		{FinishReasonContentFilter, false, false, false},
+		{FinishReasonError, true, false, false},
+		{FinishReasonTimeout, true, false, false},
+		{FinishReasonClientDisconnect, true, false, false},
+		{FinishReasonMaxCost, false, true, false},
+		{FinishReasonMaxDuration, false, true, false},
+		{FinishReasonFallback, true, false, false},
+	}
+	assert.Len(t, tests, len(FinishReasons), "every reason is covered")
+
+	for _, tt := range tests {
+		t.Run(string(tt.reason), func(t *testing.T) {
+			assert.True(t, tt.reason.IsKnown())
+			assert.Equal(t, tt.isError, tt.reason.IsError())
+			assert.Equal(t, tt.truncated, tt.reason.IsTruncated())
+			assert.Equal(t, tt.complete, tt.reason.IsComplete())
+		})
+	}
+
+	assert.False(t, FinishReason("end_turn").IsKnown())
+	assert.False(t, FinishReason("").IsError())
+}
@@ -0,0 +1,44 @@
+package api
+
+import "time"
+
+// APIKey is the public view of an API key. The key hash is never exposed.
+type APIKey struct {
+	ID                 string     `json:"id"`
+	UserID             string     `json:"user_id"`
+	Name               string     `json:"name"`
+	KeyPrefix          string     `json:"key_prefix"`
+	Scopes             string     `json:"scopes"`
+	IsActive           bool       `json:"is_active"`
+	ExpiresAt          *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt         *time.Time `json:"last_used_at,omitempty"`
+	MonthlyLimitMicros *int64     `json:"monthly_limit_micros,omitempty"`
+	AllowedModels      []string   `json:"allowed_models,omitempty"` // model ID globs, empty allows all
+	DeniedModels       []string   `json:"denied_models,omitempty"`
+	Priority           int        `json:"priority"` // higher is admitted first when providers are at capacity
+	CreatedAt          time.Time  `json:"created_at"`
+}
+
+// APIKeyPriority sets the priority an API key's requests are admitted with
+// when providers are at their concurrency cap, e.g. 2 for enterprise, 1 for
+// standard and 0, the default, for free keys.
+type APIKeyPriority struct {
+	Priority *int `json:"priority"`
+}
+
+// APIKeyModelPolicy restricts the models an API key may call. Both lists hold
+// model ID globs such as "ollama/*", denied ones win over allowed ones and an
+// empty allow list allows every model.
+type APIKeyModelPolicy struct {
+	AllowedModels []string `json:"allowed_models"`
+	DeniedModels  []string `json:"denied_models"`
+}
+
+// APIKeyList is a page of API keys.
+type APIKeyList struct {
+	Object string   `json:"object"`
+	Data   []APIKey `json:"data"`
+	Total  int      `json:"total"`
+	Limit  int      `json:"limit"`
+	Offset int      `json:"offset"`
+}
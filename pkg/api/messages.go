@@ -0,0 +1,136 @@
+package api
+
+import "encoding/json"
+
+// Anthropic Messages API shapes, served by the /messages endpoint so clients
+// built on Anthropic's SDKs can use the gateway unchanged.
+
+type MessagesRequest struct {
+	Model         string              `json:"model" binding:"required"`
+	Messages      []MessagesMessage   `json:"messages" binding:"required,min=1,dive"`
+	System        MessagesContent     `json:"system,omitempty"`
+	MaxTokens     int                 `json:"max_tokens" binding:"required,gt=0"`
+	Stream        bool                `json:"stream,omitempty"`
+	Temperature   float64             `json:"temperature,omitempty"`
+	TopP          float64             `json:"top_p,omitempty"`
+	TopK          int                 `json:"top_k,omitempty"`
+	StopSequences []string            `json:"stop_sequences,omitempty"`
+	Tools         []MessagesTool      `json:"tools,omitempty"`
+	ToolChoice    *MessagesToolChoice `json:"tool_choice,omitempty"`
+	Metadata      *MessagesMetadata   `json:"metadata,omitempty"`
+}
+
+type MessagesMessage struct {
+	Role    string          `json:"role" binding:"required,oneof=user assistant"`
+	Content MessagesContent `json:"content"`
+}
+
+// MessagesContent handles the union type: string | []MessagesBlock
+type MessagesContent struct {
+	Text   string
+	Blocks []MessagesBlock
+}
+
+func (c *MessagesContent) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &c.Text)
+	}
+	if len(data) > 0 && data[0] == '[' {
+		return json.Unmarshal(data, &c.Blocks)
+	}
+	return nil
+}
+
+func (c MessagesContent) MarshalJSON() ([]byte, error) {
+	if c.Blocks != nil {
+		return json.Marshal(c.Blocks)
+	}
+	return json.Marshal(c.Text)
+}
+
+// MessagesBlock is a content block: text, thinking, image, tool_use or
+// tool_result.
+type MessagesBlock struct {
+	Type     string               `json:"type"`
+	Text     *string              `json:"text,omitempty"`
+	Thinking *string              `json:"thinking,omitempty"`
+	Source   *MessagesImageSource `json:"source,omitempty"`
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+	// tool_result, content is a string or text blocks
+	ToolUseID string           `json:"tool_use_id,omitempty"`
+	Content   *MessagesContent `json:"content,omitempty"`
+}
+
+type MessagesImageSource struct {
+	Type      string `json:"type"` // "base64" or "url"
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type MessagesTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+type MessagesToolChoice struct {
+	Type string `json:"type"` // "auto", "any", "tool" or "none"
+	Name string `json:"name,omitempty"`
+}
+
+type MessagesMetadata struct {
+	UserID string `json:"user_id,omitempty"`
+}
+
+type MessagesResponse struct {
+	ID           string          `json:"id"`
+	Type         string          `json:"type"` // "message"
+	Role         string          `json:"role"`
+	Model        string          `json:"model"`
+	Content      []MessagesBlock `json:"content"`
+	StopReason   *string         `json:"stop_reason"`
+	StopSequence *string         `json:"stop_sequence"`
+	Usage        MessagesUsage   `json:"usage"`
+}
+
+type MessagesUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
+}
+
+// MessagesStreamEvent is the payload of every server-sent event, its Type is
+// also the SSE event name.
+type MessagesStreamEvent struct {
+	Type         string            `json:"type"`
+	Message      *MessagesResponse `json:"message,omitempty"`       // message_start
+	Index        *int              `json:"index,omitempty"`         // content_block_*
+	ContentBlock *MessagesBlock    `json:"content_block,omitempty"` // content_block_start
+	Delta        *MessagesDelta    `json:"delta,omitempty"`         // content_block_delta, message_delta
+	Usage        *MessagesUsage    `json:"usage,omitempty"`         // message_delta
+	Error        *MessagesError    `json:"error,omitempty"`         // error
+}
+
+type MessagesDelta struct {
+	Type        string  `json:"type,omitempty"` // text_delta, thinking_delta, input_json_delta
+	Text        string  `json:"text,omitempty"`
+	Thinking    string  `json:"thinking,omitempty"`
+	PartialJSON *string `json:"partial_json,omitempty"`
+	StopReason  *string `json:"stop_reason,omitempty"` // message_delta
+}
+
+type MessagesError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// MessagesErrorResponse is the body of a failed request.
+type MessagesErrorResponse struct {
+	Type  string        `json:"type"` // "error"
+	Error MessagesError `json:"error"`
+}
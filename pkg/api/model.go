@@ -13,6 +13,11 @@ type Model struct {
 	Pricing          Pricing           `json:"pricing"`
 	TopProvider      TopProvider       `json:"top_provider"`
 	PerRequestLimits *PerRequestLimits `json:"per_request_limits,omitempty"`
+	AliasOf          string            `json:"alias_of,omitempty"` // Set on aliases, the model they resolve to
+	// Available is false while every provider serving the model is failing
+	// health checks, Degraded while the best of them is only degraded.
+	Available bool `json:"available"`
+	Degraded  bool `json:"degraded,omitempty"`
 }
 
 type ModelFilter struct {
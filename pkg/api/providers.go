@@ -0,0 +1,34 @@
+package api
+
+import "time"
+
+// Provider describes a configured provider for dashboards. Secrets are never
+// returned, APIKey only tells whether one is set.
+type Provider struct {
+	ID       string `json:"id"`
+	Name     string `json:"name,omitempty"`
+	Type     string `json:"type"`
+	BaseURL  string `json:"base_url,omitempty"`
+	APIKey   string `json:"api_key,omitempty"` // redacted
+	Enabled  bool   `json:"enabled"`
+	Priority int    `json:"priority"` // position in the configured list, 1 first; 0 when not configured
+
+	// Registered reports whether the router is currently serving the provider.
+	Registered bool            `json:"registered"`
+	Health     *ProviderHealth `json:"health,omitempty"`
+}
+
+// ProviderHealth is the result of the last health check of a provider.
+type ProviderHealth struct {
+	Healthy             bool      `json:"healthy"`
+	Status              string    `json:"status"` // healthy, degraded or unhealthy
+	Error               string    `json:"error,omitempty"`
+	ConsecutiveFailures int       `json:"consecutive_failures,omitempty"`
+	LatencyMS           int64     `json:"latency_ms"`
+	CheckedAt           time.Time `json:"checked_at"`
+}
+
+type ProviderList struct {
+	Object string     `json:"object"`
+	Data   []Provider `json:"data"`
+}
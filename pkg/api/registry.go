@@ -1,6 +1,9 @@
 package api
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // ModelDefinition represents the static configuration for a model in models.yaml
 type ModelDefinition struct {
@@ -18,6 +21,9 @@ type ModelDefinition struct {
 	Architecture  ModelArchitecture `mapstructure:"architecture" json:"architecture"`
 	TopProvider   ModelTopProvider  `mapstructure:"top_provider" json:"top_provider"`
 
+	// Deprecation is set once the provider announced the model's removal.
+	Deprecation *ModelDeprecation `mapstructure:"deprecation" json:"deprecation,omitempty"`
+
 	// Metadata for management
 	Source      string    `mapstructure:"source" json:"source"` // "auto" or "manual"
 	LastUpdated time.Time `mapstructure:"last_updated" json:"last_updated"`
@@ -54,4 +60,37 @@ type ModelConfig struct {
 	ImageSupport     bool     `mapstructure:"image_support" json:"image_support"`
 	ToolUse          bool     `mapstructure:"tool_use" json:"tool_use"`
 	StreamingSupport bool     `mapstructure:"streaming_support" json:"streaming_support"`
+	DefaultMaxTokens int      `mapstructure:"default_max_tokens" json:"default_max_tokens,omitempty"` // used when the provider mandates max_tokens
+	SystemPrefix     string   `mapstructure:"system_prefix" json:"system_prefix,omitempty"`           // prepended to the system prompt
+	SystemSuffix     string   `mapstructure:"system_suffix" json:"system_suffix,omitempty"`           // appended to the system prompt
+
+	// StreamFlushInterval overrides the server stream flush window for this
+	// model, a negative value flushes every chunk.
+	StreamFlushInterval time.Duration `mapstructure:"stream_flush_interval" json:"stream_flush_interval,omitempty"`
+
+	// Quality is an operator assigned score compared against the gateway's
+	// min_quality floor under cost routing.
+	Quality float64 `mapstructure:"quality" json:"quality,omitempty"`
+}
+
+// ModelDeprecation describes a provider's announced removal of a model.
+type ModelDeprecation struct {
+	Message     string `mapstructure:"message" json:"message,omitempty"`
+	Sunset      string `mapstructure:"sunset" json:"sunset,omitempty"`           // date the model is removed, YYYY-MM-DD
+	Replacement string `mapstructure:"replacement" json:"replacement,omitempty"` // model ID to migrate to
+}
+
+// Notice returns a one line warning for clients of the deprecated model.
+func (d *ModelDeprecation) Notice(modelID string) string {
+	notice := fmt.Sprintf("model '%s' is deprecated", modelID)
+	if d.Sunset != "" {
+		notice += " and will be removed on " + d.Sunset
+	}
+	if d.Replacement != "" {
+		notice += fmt.Sprintf(", use '%s' instead", d.Replacement)
+	}
+	if d.Message != "" {
+		notice += ": " + d.Message
+	}
+	return notice
 }
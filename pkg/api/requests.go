@@ -44,24 +44,59 @@ type ChatRequest struct {
 
 	// OpenRouter-only parameters
 	Transforms []string             `json:"transforms,omitempty"`
-	Models     []string             `json:"models,omitempty"`
-	Route      string               `json:"route,omitempty"` // 'fallback'
+	Models     []string             `json:"models,omitempty" binding:"omitempty,max=10"`
+	Route      string               `json:"route,omitempty" binding:"omitempty,oneof=fallback"` // 'fallback'
 	Provider   *ProviderPreferences `json:"provider,omitempty"`
 	User       string               `json:"user,omitempty"`
-	Modalities []string             `json:"modalities,omitempty"`
+
+	// Perplexity-only parameters, restricting which domains the search may
+	// use and whether sources are returned.
+	SearchDomainFilter []string `json:"search_domain_filter,omitempty" binding:"omitempty,max=20"`
+	ReturnCitations    *bool    `json:"return_citations,omitempty"`
+
+	// Output modalities the model should produce, defaults to text only.
+	// Translated per provider (OpenAI `modalities`, Gemini responseModalities).
+	Modalities []string `json:"modalities,omitempty" binding:"omitempty,dive,oneof=text image audio"`
+
+	// Number of images to generate on models that output images, defaults
+	// to one. It is dropped for every other model.
+	N int `json:"n,omitempty" binding:"omitempty,min=1,max=10"`
+
+	// Return the model's reasoning to the client, defaults to the gateway
+	// setting. Reasoning is always extracted for logging.
+	IncludeReasoning *bool `json:"include_reasoning,omitempty"`
+
+	// OpenAI-only parameters, stored completions show up in their dashboard.
+	// Metadata is also recorded in the request log for correlation.
+	Store    bool              `json:"store,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty" binding:"omitempty,max=16,dive,keys,max=64,endkeys,max=512"`
+
+	// Answer with the gateway's canned fallback content instead of an error
+	// when every provider fails, defaults to the key setting.
+	UseFallbackContent *bool `json:"use_fallback_content,omitempty"`
 
 	// Debug options
 	Debug *DebugOptions `json:"debug,omitempty"`
 }
 
 type ChatMessage struct {
-	Role       string        `json:"role" binding:"required,oneof=user assistant system"`
+	Role       string        `json:"role" binding:"required,oneof=user assistant system tool"`
 	Content    Content       `json:"content"` // string or []ContentPart
 	Reasoning  string        `json:"reasoning,omitempty"`
 	Name       string        `json:"name,omitempty"`
 	ToolCallID string        `json:"tool_call_id,omitempty"`
 	ToolCalls  []ToolCall    `json:"tool_calls,omitempty"` // For assistant messages
 	Images     []ContentPart `json:"images,omitempty"`     // For image generation results
+
+	// Progress is set on stream deltas of generations that are polled
+	// upstream (BFL), while the result is not ready yet.
+	Progress *GenerationProgress `json:"progress,omitempty"`
+}
+
+// GenerationProgress reports how far along a long running generation is.
+type GenerationProgress struct {
+	Status  string   `json:"status"`            // as reported upstream, e.g. Pending or Processing
+	Percent *float64 `json:"percent,omitempty"` // 0 to 100, when the provider reports it
 }
 
 // Content handles the union type: string | []ContentPart
@@ -94,6 +129,22 @@ type ContentPart struct {
 	Type     string    `json:"type"`
 	Text     string    `json:"text,omitempty"`
 	ImageURL *ImageURL `json:"image_url,omitempty"`
+
+	// Index is set on the images of a streamed delta when large images are
+	// chunked. It is stable for the whole stream, every fragment with the
+	// same index appends its URL to the one received before.
+	Index *int `json:"index,omitempty"`
+
+	// CacheControl marks the end of a prompt prefix the provider should
+	// cache (Anthropic). Dropped for providers without prompt caching hints.
+	CacheControl *CacheControl `json:"cache_control,omitempty"`
+}
+
+// CacheControl is an Anthropic style prompt cache breakpoint.
+type CacheControl struct {
+	Type string `json:"type" binding:"oneof=ephemeral"`
+	// TTL is "5m" (default) or "1h".
+	TTL string `json:"ttl,omitempty" binding:"omitempty,oneof=5m 1h"`
 }
 
 type ImageURL struct {
@@ -102,7 +153,16 @@ type ImageURL struct {
 }
 
 type ResponseFormat struct {
-	Type string `json:"type"`
+	Type string `json:"type"` // "text", "json_object" or "json_schema"
+	// JSONSchema is the schema the output must follow, for json_schema.
+	JSONSchema *JSONSchemaFormat `json:"json_schema,omitempty"`
+}
+
+type JSONSchemaFormat struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Schema      map[string]interface{} `json:"schema,omitempty"`
+	Strict      *bool                  `json:"strict,omitempty"`
 }
 
 type Stop struct {
@@ -144,9 +204,13 @@ type Prediction struct {
 	Content string `json:"content"`
 }
 
+// ProviderPreferences steers which providers serve a request. Order lists
+// provider IDs to try first, AllowFallbacks (default true) permits other
+// providers and configured fallback models after them, RequireParameters
+// only routes to providers supporting every parameter sent.
 type ProviderPreferences struct {
 	Order             []string `json:"order,omitempty"`
-	AllowFallbacks    bool     `json:"allow_fallbacks,omitempty"`
+	AllowFallbacks    *bool    `json:"allow_fallbacks,omitempty"`
 	RequireParameters bool     `json:"require_parameters,omitempty"`
 	DataCollection    string   `json:"data_collection,omitempty"` // "deny" | "allow"
 }
@@ -1,5 +1,7 @@
 package api
 
+import "time"
+
 type ChatResponse struct {
 	ID                string         `json:"id"`
 	Choices           []Choice       `json:"choices"`
@@ -9,9 +11,36 @@ type ChatResponse struct {
 	SystemFingerprint string         `json:"system_fingerprint,omitempty"`
 	Usage             *ResponseUsage `json:"usage,omitempty"`
 
+	// Citations lists the sources used by search backed models (Perplexity).
+	Citations []string `json:"citations,omitempty"`
+
+	// Debug is set when the request asked for debug output and the caller
+	// may see it.
+	Debug *DebugInfo `json:"debug,omitempty"`
+
+	// Deprecation is set when the requested model is deprecated, on the
+	// first chunk of streams.
+	Deprecation *DeprecationWarning `json:"deprecation,omitempty"`
+
 	Error *ErrorResponse `json:"error,omitempty"`
 }
 
+// DeprecationWarning tells clients the model they used is going away.
+type DeprecationWarning struct {
+	Model       string `json:"model"`
+	Notice      string `json:"notice"`
+	Sunset      string `json:"sunset,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// DebugInfo carries the debug output of a request.
+type DebugInfo struct {
+	// EchoUpstreamBody is the redacted request body sent to the provider.
+	EchoUpstreamBody string `json:"echo_upstream_body"`
+	// Truncated reports whether the echo was cut at the configured cap.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
 func (e *ErrorResponse) Error() string {
 	return e.Message
 }
@@ -42,6 +71,29 @@ type ResponseUsage struct {
 
 	// Server Tool Usage
 	ServerToolUse *ServerToolUse `json:"server_tool_use,omitempty"`
+
+	// Native counts as the provider reports them, set by adapters whose
+	// native counting differs from the normalized counts above. Internal,
+	// surfaced through the generation endpoint only.
+	NativeTokens *NativeTokens `json:"-"`
+
+	// Timing is the provider's own breakdown of where the request spent its
+	// time, set by adapters whose provider reports it (Groq). Internal.
+	Timing *UpstreamTiming `json:"-"`
+}
+
+// UpstreamTiming is the time a provider reports spending on a request.
+type UpstreamTiming struct {
+	Queue      time.Duration
+	Prompt     time.Duration
+	Completion time.Duration
+	Total      time.Duration
+}
+
+// NativeTokens are prompt and completion counts in the provider's own terms.
+type NativeTokens struct {
+	Prompt     int
+	Completion int
 }
 
 type PromptTokensDetails struct {
@@ -73,17 +125,26 @@ type ErrorResponse struct {
 }
 
 type ToolCall struct {
-	ID       string       `json:"id"`
-	Type     string       `json:"type"`
+	// Index identifies the call a streamed delta belongs to, it is stable for
+	// the whole stream. Only the first delta of a call carries ID, Type and Name,
+	// the following ones append fragments to Arguments.
+	Index    *int         `json:"index,omitempty"`
+	ID       string       `json:"id,omitempty"`
+	Type     string       `json:"type,omitempty"`
 	Function FunctionCall `json:"function"`
 }
 
 type FunctionCall struct {
-	Name      string `json:"name"`
+	Name      string `json:"name,omitempty"`
 	Arguments string `json:"arguments"` // JSON string
 }
 
 type StreamResult struct {
 	Response *ChatResponse
 	Err      error
+
+	// Raw is the upstream SSE data payload in passthrough mode. It is written
+	// to the client as is, Response then only carries the fields tapped for
+	// logging: id, finish reason and usage.
+	Raw []byte
 }
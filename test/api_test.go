@@ -165,10 +165,10 @@ func setupTestServer(t *testing.T) (*httptest.Server, *MockProvider) {
 	cacheSvc := cache.NewMemoryCache()
 
 	// Create Ingestor for analytics (required by Gateway service)
-	ingestor := analytics.NewIngestor(log, repo)
+	ingestor := analytics.NewIngestor(log, repo, config.AnalyticsConfig{})
 	ingestor.Start(context.Background())
 
-	routerSvc := gateway.NewService(log, repo, ingestor, cacheSvc)
+	routerSvc := gateway.NewService(log, repo, ingestor, cacheSvc, config.GatewayConfig{})
 	analyticsSvc := analytics.NewService(repo)
 	val := validator.New()
 
@@ -202,7 +202,7 @@ func setupTestServer(t *testing.T) (*httptest.Server, *MockProvider) {
 	require.NoError(t, err)
 
 	// 6. Server
-	srv := server.New(cfg, log, repo, routerSvc, analyticsSvc, val)
+	srv := server.New(cfg, log, repo, cacheSvc, routerSvc, analyticsSvc, val)
 	ts := httptest.NewServer(srv.Handler())
 
 	return ts, mockP
@@ -324,3 +324,22 @@ func TestValidationError(t *testing.T) {
 	assert.Contains(t, errors, "messages[0].role")
 	assert.Contains(t, errors, "model")
 }
+
+func TestValidationError_Modalities(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	defer ts.Close()
+
+	payload := map[string]interface{}{
+		"model":      "test-model",
+		"messages":   []map[string]interface{}{{"role": "user", "content": "hello"}},
+		"modalities": []string{"text", "video"},
+	}
+
+	var errResp map[string]interface{}
+	code := makeRequest(t, ts, "POST", "/api/v1/chat/completions", payload, &errResp)
+
+	assert.Equal(t, http.StatusBadRequest, code)
+	errors, ok := errResp["errors"].(map[string]interface{})
+	require.True(t, ok, "Should contain 'errors' map")
+	assert.Contains(t, errors, "modalities[1]")
+}